@@ -0,0 +1,8 @@
+// Package weatherpb contains Go bindings for api/proto/weather.proto.
+//
+// The bindings are written by hand rather than generated, so the module does not depend on the
+// protobuf runtime or protoc. They implement proto3 binary encoding for exactly the messages in the
+// schema: default values are omitted on the wire and unknown fields are skipped when decoding.
+// Any change to weather.proto must be mirrored here: TestBindingsMatchSchema reads the .proto file
+// and fails when a field's number, type or presence differs between the two.
+package weatherpb
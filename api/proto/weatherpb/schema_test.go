@@ -0,0 +1,197 @@
+package weatherpb
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// message is implemented by every binding.
+type message interface {
+	AppendTo(b []byte) []byte
+	Unmarshal(b []byte) error
+}
+
+// bindings maps each message of weather.proto to its Go binding.
+var bindings = map[string]message{
+	"WeatherResponse":  &WeatherResponse{},
+	"ForecastEntry":    &ForecastEntry{},
+	"ForecastResponse": &ForecastResponse{},
+	"Error":            &Error{},
+	"Quality":          &Quality{},
+	"Envelope":         &Envelope{},
+}
+
+// protoField is a field declared in weather.proto.
+type protoField struct {
+	name     string
+	typ      string
+	num      int
+	repeated bool
+	optional bool
+}
+
+var protoFieldPattern = regexp.MustCompile(`^(?:(repeated|optional)\s+)?(\w+)\s+(\w+)\s*=\s*(\d+);`)
+
+// parseSchema returns the fields of every message in weather.proto, oneof members included. It
+// understands just enough of the syntax for this schema.
+func parseSchema(t *testing.T) map[string][]protoField {
+	t.Helper()
+	f, err := os.Open("../weather.proto")
+	if err != nil {
+		t.Fatalf("Open schema: %v", err)
+	}
+	defer f.Close()
+
+	schema := make(map[string][]protoField)
+	var current string
+	depth := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "message ") && depth == 0:
+			current = strings.Fields(line)[1]
+			schema[current] = nil
+			depth++
+		case strings.HasSuffix(line, "{"):
+			depth++
+		case line == "}":
+			if depth--; depth == 0 {
+				current = ""
+			}
+		case current != "":
+			if m := protoFieldPattern.FindStringSubmatch(line); m != nil {
+				num, _ := strconv.Atoi(m[4])
+				schema[current] = append(schema[current], protoField{name: m[3], typ: m[2], num: num, repeated: m[1] == "repeated", optional: m[1] == "optional"})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Read schema: %v", err)
+	}
+	return schema
+}
+
+// goInitialisms are the name parts written in capitals in Go, as in UVIndex.
+var goInitialisms = map[string]bool{"uv": true, "url": true}
+
+// goFieldName converts a proto field name such as "last_modified" to "LastModified".
+func goFieldName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		switch {
+		case goInitialisms[part]:
+			b.WriteString(strings.ToUpper(part))
+		case part != "":
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// sampleValue returns a non-default value of a Go field for proto type typ, and the wire type it
+// must be encoded with.
+func sampleValue(t *testing.T, typ string, goType reflect.Type) (reflect.Value, int) {
+	t.Helper()
+	var v reflect.Value
+	wire := -1
+	switch typ {
+	case "string":
+		v, wire = reflect.ValueOf("x"), wireBytes
+	case "double":
+		v, wire = reflect.ValueOf(1.5), wireFixed64
+	case "bool":
+		v, wire = reflect.ValueOf(true), wireVarint
+	case "int64":
+		v, wire = reflect.ValueOf(int64(7)), wireVarint
+	default:
+		if _, ok := bindings[typ]; !ok {
+			t.Fatalf("Unsupported proto type %s", typ)
+		}
+		if goType.Kind() != reflect.Pointer || goType.Elem().Name() != typ {
+			t.Fatalf("Expected *%s for a %s field, got %v", typ, typ, goType)
+		}
+		v, wire = reflect.New(goType.Elem()), wireBytes
+	}
+	if !v.Type().AssignableTo(goType) {
+		t.Fatalf("Expected a Go %v for proto type %s, got %v", v.Type(), typ, goType)
+	}
+	return v, wire
+}
+
+// TestBindingsMatchSchema keeps the hand-written bindings in step with weather.proto: every field
+// must exist on both sides, and setting it alone must encode exactly one field with the declared
+// number and wire type that decodes back to the same value.
+func TestBindingsMatchSchema(t *testing.T) {
+	schema := parseSchema(t)
+	for name := range bindings {
+		if _, ok := schema[name]; !ok {
+			t.Errorf("Binding %s has no message in weather.proto", name)
+		}
+	}
+	for name, fields := range schema {
+		binding, ok := bindings[name]
+		if !ok {
+			t.Errorf("Message %s has no binding", name)
+			continue
+		}
+		typ := reflect.TypeOf(binding).Elem()
+		if typ.NumField() != len(fields) {
+			t.Errorf("%s: expected %d fields as in weather.proto, got %d", name, len(fields), typ.NumField())
+		}
+		for _, pf := range fields {
+			t.Run(name+"."+pf.name, func(t *testing.T) {
+				sf, ok := typ.FieldByName(goFieldName(pf.name))
+				if !ok {
+					t.Fatalf("No Go field %s", goFieldName(pf.name))
+				}
+				m := reflect.New(typ)
+				goType := sf.Type
+				if pf.repeated {
+					if goType.Kind() != reflect.Slice {
+						t.Fatalf("Expected a slice for a repeated field, got %v", goType)
+					}
+					goType = goType.Elem()
+				}
+				if pf.optional {
+					if goType.Kind() != reflect.Pointer {
+						t.Fatalf("Expected a pointer for an optional field, got %v", goType)
+					}
+					goType = goType.Elem()
+				}
+				v, wire := sampleValue(t, pf.typ, goType)
+				switch {
+				case pf.repeated:
+					v = reflect.Append(reflect.MakeSlice(sf.Type, 0, 1), v)
+				case pf.optional:
+					// Optional fields are sent when set, even to their default
+					v = reflect.New(goType)
+				}
+				m.Elem().FieldByIndex(sf.Index).Set(v)
+
+				encoded := m.Interface().(message).AppendTo(nil)
+				var got []field
+				if err := forEachField(encoded, func(f field) error { got = append(got, f); return nil }); err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if len(got) != 1 || got[0].num != pf.num || got[0].wire != wire {
+					t.Fatalf("Expected field %d with wire type %d, got %+v", pf.num, wire, got)
+				}
+
+				decoded := reflect.New(typ)
+				if err := decoded.Interface().(message).Unmarshal(encoded); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				if !reflect.DeepEqual(decoded.Elem().FieldByIndex(sf.Index).Interface(), v.Interface()) {
+					t.Errorf("Expected %v to round-trip, got %v", v, decoded.Elem().FieldByIndex(sf.Index))
+				}
+			})
+		}
+	}
+}
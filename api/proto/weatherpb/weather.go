@@ -0,0 +1,318 @@
+package weatherpb
+
+// WeatherResponse mirrors weather.v1.WeatherResponse.
+type WeatherResponse struct {
+	Location       string
+	Temperature    float64
+	Description    string
+	Cached         bool
+	Stale          bool
+	ResolvedFrom   string
+	UVIndex        *float64
+	FeelsLike      *float64
+	TempMin        *float64
+	TempMax        *float64
+	Humidity       *float64
+	Pressure       *float64
+	WindSpeed      *float64
+	WindDirection  *float64
+	Sunrise        int64
+	Sunset         int64
+	DayLength      int64
+	TimezoneOffset *int64
+	ObservedAt     int64
+	Condition      string
+	IconURL        string
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *WeatherResponse) AppendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Location)
+	b = appendDouble(b, 2, m.Temperature)
+	b = appendString(b, 3, m.Description)
+	b = appendBool(b, 4, m.Cached)
+	b = appendBool(b, 5, m.Stale)
+	b = appendString(b, 6, m.ResolvedFrom)
+	b = appendOptionalDouble(b, 7, m.UVIndex)
+	b = appendOptionalDouble(b, 8, m.FeelsLike)
+	b = appendOptionalDouble(b, 9, m.TempMin)
+	b = appendOptionalDouble(b, 10, m.TempMax)
+	b = appendOptionalDouble(b, 11, m.Humidity)
+	b = appendOptionalDouble(b, 12, m.Pressure)
+	b = appendOptionalDouble(b, 13, m.WindSpeed)
+	b = appendOptionalDouble(b, 14, m.WindDirection)
+	b = appendInt64(b, 15, m.Sunrise)
+	b = appendInt64(b, 16, m.Sunset)
+	b = appendInt64(b, 17, m.DayLength)
+	b = appendOptionalInt64(b, 18, m.TimezoneOffset)
+	b = appendInt64(b, 19, m.ObservedAt)
+	b = appendString(b, 20, m.Condition)
+	return appendString(b, 21, m.IconURL)
+}
+
+// Marshal returns the wire encoding of m.
+func (m *WeatherResponse) Marshal() []byte { return m.AppendTo(nil) }
+
+// Unmarshal decodes b into m.
+func (m *WeatherResponse) Unmarshal(b []byte) error {
+	*m = WeatherResponse{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Location = f.str()
+		case 2:
+			m.Temperature = f.double()
+		case 3:
+			m.Description = f.str()
+		case 4:
+			m.Cached = f.bool()
+		case 5:
+			m.Stale = f.bool()
+		case 6:
+			m.ResolvedFrom = f.str()
+		case 7:
+			m.UVIndex = f.optionalDouble()
+		case 8:
+			m.FeelsLike = f.optionalDouble()
+		case 9:
+			m.TempMin = f.optionalDouble()
+		case 10:
+			m.TempMax = f.optionalDouble()
+		case 11:
+			m.Humidity = f.optionalDouble()
+		case 12:
+			m.Pressure = f.optionalDouble()
+		case 13:
+			m.WindSpeed = f.optionalDouble()
+		case 14:
+			m.WindDirection = f.optionalDouble()
+		case 15:
+			m.Sunrise = int64(f.u64)
+		case 16:
+			m.Sunset = int64(f.u64)
+		case 17:
+			m.DayLength = int64(f.u64)
+		case 18:
+			m.TimezoneOffset = f.optionalInt64()
+		case 19:
+			m.ObservedAt = int64(f.u64)
+		case 20:
+			m.Condition = f.str()
+		case 21:
+			m.IconURL = f.str()
+		}
+		return nil
+	})
+}
+
+// ForecastEntry mirrors weather.v1.ForecastEntry.
+type ForecastEntry struct {
+	Time        int64
+	Temperature float64
+	Description string
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *ForecastEntry) AppendTo(b []byte) []byte {
+	b = appendInt64(b, 1, m.Time)
+	b = appendDouble(b, 2, m.Temperature)
+	return appendString(b, 3, m.Description)
+}
+
+// Unmarshal decodes b into m.
+func (m *ForecastEntry) Unmarshal(b []byte) error {
+	*m = ForecastEntry{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Time = int64(f.u64)
+		case 2:
+			m.Temperature = f.double()
+		case 3:
+			m.Description = f.str()
+		}
+		return nil
+	})
+}
+
+// ForecastResponse mirrors weather.v1.ForecastResponse.
+type ForecastResponse struct {
+	Location string
+	Entries  []*ForecastEntry
+	Cached   bool
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *ForecastResponse) AppendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Location)
+	for _, e := range m.Entries {
+		b = appendMessage(b, 2, e)
+	}
+	return appendBool(b, 3, m.Cached)
+}
+
+// Marshal returns the wire encoding of m.
+func (m *ForecastResponse) Marshal() []byte { return m.AppendTo(nil) }
+
+// Unmarshal decodes b into m.
+func (m *ForecastResponse) Unmarshal(b []byte) error {
+	*m = ForecastResponse{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Location = f.str()
+		case 2:
+			e := &ForecastEntry{}
+			if err := e.Unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Entries = append(m.Entries, e)
+		case 3:
+			m.Cached = f.bool()
+		}
+		return nil
+	})
+}
+
+// Error mirrors weather.v1.Error.
+type Error struct {
+	Code     string
+	Provider string
+	Location string
+	Message  string
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *Error) AppendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Code)
+	b = appendString(b, 2, m.Provider)
+	b = appendString(b, 3, m.Location)
+	return appendString(b, 4, m.Message)
+}
+
+// Unmarshal decodes b into m.
+func (m *Error) Unmarshal(b []byte) error {
+	*m = Error{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Code = f.str()
+		case 2:
+			m.Provider = f.str()
+		case 3:
+			m.Location = f.str()
+		case 4:
+			m.Message = f.str()
+		}
+		return nil
+	})
+}
+
+// Quality mirrors weather.v1.Quality.
+type Quality struct {
+	Score          float64
+	Freshness      float64
+	ProviderHealth float64
+	Completeness   float64
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *Quality) AppendTo(b []byte) []byte {
+	b = appendDouble(b, 1, m.Score)
+	b = appendDouble(b, 2, m.Freshness)
+	b = appendDouble(b, 3, m.ProviderHealth)
+	return appendDouble(b, 4, m.Completeness)
+}
+
+// Unmarshal decodes b into m.
+func (m *Quality) Unmarshal(b []byte) error {
+	*m = Quality{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Score = f.double()
+		case 2:
+			m.Freshness = f.double()
+		case 3:
+			m.ProviderHealth = f.double()
+		case 4:
+			m.Completeness = f.double()
+		}
+		return nil
+	})
+}
+
+// Envelope mirrors weather.v1.Envelope. At most one of Weather and Forecast is set.
+type Envelope struct {
+	Weather     *WeatherResponse
+	Forecast    *ForecastResponse
+	Error       string
+	Errors      []*Error
+	Warnings    []string
+	Message     string
+	Suggestions []string
+	Quality     *Quality
+}
+
+// AppendTo appends the wire encoding of m to b.
+func (m *Envelope) AppendTo(b []byte) []byte {
+	switch {
+	case m.Weather != nil:
+		b = appendMessage(b, 1, m.Weather)
+	case m.Forecast != nil:
+		b = appendMessage(b, 2, m.Forecast)
+	}
+	b = appendString(b, 3, m.Error)
+	for _, e := range m.Errors {
+		b = appendMessage(b, 4, e)
+	}
+	for _, w := range m.Warnings {
+		b = appendTag(b, 5, wireBytes)
+		b = appendLen(b, w)
+	}
+	b = appendString(b, 6, m.Message)
+	for _, s := range m.Suggestions {
+		b = appendTag(b, 7, wireBytes)
+		b = appendLen(b, s)
+	}
+	if m.Quality != nil {
+		b = appendMessage(b, 8, m.Quality)
+	}
+	return b
+}
+
+// Marshal returns the wire encoding of m.
+func (m *Envelope) Marshal() []byte { return m.AppendTo(nil) }
+
+// Unmarshal decodes b into m.
+func (m *Envelope) Unmarshal(b []byte) error {
+	*m = Envelope{}
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Weather, m.Forecast = &WeatherResponse{}, nil
+			return m.Weather.Unmarshal(f.bytes)
+		case 2:
+			m.Forecast, m.Weather = &ForecastResponse{}, nil
+			return m.Forecast.Unmarshal(f.bytes)
+		case 3:
+			m.Error = f.str()
+		case 4:
+			e := &Error{}
+			if err := e.Unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Errors = append(m.Errors, e)
+		case 5:
+			m.Warnings = append(m.Warnings, f.str())
+		case 6:
+			m.Message = f.str()
+		case 7:
+			m.Suggestions = append(m.Suggestions, f.str())
+		case 8:
+			m.Quality = &Quality{}
+			return m.Quality.Unmarshal(f.bytes)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package weatherpb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWeatherResponse_WireFormat(t *testing.T) {
+	m := &WeatherResponse{Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true}
+
+	want := []byte{0x0a, 6}
+	want = append(want, "London"...)
+	want = append(want, 0x11)
+	want = binary.LittleEndian.AppendUint64(want, math.Float64bits(15.2))
+	want = append(want, 0x1a, 9)
+	want = append(want, "clear sky"...)
+	want = append(want, 0x20, 0x01)
+
+	if got := m.Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("Unexpected encoding\n got: % x\nwant: % x", got, want)
+	}
+	if got := (&WeatherResponse{}).Marshal(); len(got) != 0 {
+		t.Errorf("Expected default values to be omitted, got % x", got)
+	}
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	in := &Envelope{
+		Weather:     &WeatherResponse{Location: "Oslo", Temperature: -3.5, Stale: true, ResolvedFrom: "ip", UVIndex: new(float64)},
+		Errors:      []*Error{{Code: "not_found", Provider: "openweathermap", Location: "Atlantis", Message: "city not found"}},
+		Warnings:    []string{"deprecated", ""},
+		Message:     "Success",
+		Suggestions: []string{"Makassar", "Manila"},
+		Quality:     &Quality{Score: 0.75, Freshness: 1, ProviderHealth: 0.5},
+	}
+	var out Envelope
+	if err := out.Unmarshal(in.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(in, &out) {
+		t.Errorf("Round trip mismatch\n got: %+v\nwant: %+v", out, *in)
+	}
+
+	forecast := &Envelope{Forecast: &ForecastResponse{
+		Location: "Oslo",
+		Entries:  []*ForecastEntry{{Time: 1700000000, Temperature: 1.5, Description: "snow"}, {Time: -1}},
+	}}
+	out = Envelope{}
+	if err := out.Unmarshal(forecast.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(forecast, &out) {
+		t.Errorf("Forecast round trip mismatch\n got: %+v\nwant: %+v", out, *forecast)
+	}
+}
+
+func TestUnmarshal_SkipsUnknownAndRejectsTruncated(t *testing.T) {
+	b := (&WeatherResponse{Location: "Rome"}).Marshal()
+	// Unknown fields 15 (varint) and 16 (fixed32) must be skipped
+	b = append(b, 0x78, 0x2a, 0x85, 0x01, 1, 2, 3, 4)
+	var m WeatherResponse
+	if err := m.Unmarshal(b); err != nil || m.Location != "Rome" {
+		t.Errorf("Expected unknown fields to be skipped, got %+v (%v)", m, err)
+	}
+
+	if err := m.Unmarshal([]byte{0x0a, 10, 'R'}); err == nil {
+		t.Error("Expected an error for a truncated string")
+	}
+	if err := m.Unmarshal([]byte{0x11, 1, 2}); err == nil {
+		t.Error("Expected an error for a truncated double")
+	}
+}
@@ -0,0 +1,149 @@
+package weatherpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Wire types from the protobuf encoding spec.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("weatherpb: truncated message")
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendLen(appendTag(b, field, wireBytes), s)
+}
+
+// appendLen writes s with its length prefix. Repeated strings use it directly so that empty
+// elements are kept.
+func appendLen(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendDouble(b []byte, field int, f float64) []byte {
+	if f == 0 && !math.Signbit(f) {
+		return b
+	}
+	b = appendTag(b, field, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+// appendOptionalDouble writes an optional field whenever it is set, even to zero.
+func appendOptionalDouble(b []byte, field int, f *float64) []byte {
+	if f == nil {
+		return b
+	}
+	b = appendTag(b, field, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(*f))
+}
+
+func appendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return append(appendTag(b, field, wireVarint), 1)
+}
+
+func appendInt64(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendTag(b, field, wireVarint), uint64(v))
+}
+
+// appendOptionalInt64 writes an optional field whenever it is set, even to zero.
+func appendOptionalInt64(b []byte, field int, v *int64) []byte {
+	if v == nil {
+		return b
+	}
+	return binary.AppendUvarint(appendTag(b, field, wireVarint), uint64(*v))
+}
+
+// appendMessage writes a length-delimited submessage. Submessages are always written, even when
+// empty, so that a set oneof member is preserved.
+func appendMessage(b []byte, field int, m interface{ AppendTo([]byte) []byte }) []byte {
+	body := m.AppendTo(nil)
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(body)))
+	return append(b, body...)
+}
+
+// field is one decoded key/value pair.
+type field struct {
+	num   int
+	wire  int
+	u64   uint64 // varint and fixed values
+	bytes []byte // length-delimited values
+}
+
+// forEachField decodes the fields of a message in order.
+func forEachField(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			f.u64, b = v, b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.u64, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.u64, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			f.bytes, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return errors.New("weatherpb: unsupported wire type")
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f field) double() float64 { return math.Float64frombits(f.u64) }
+
+// optionalDouble decodes an optional double field, which is set whenever it is present.
+func (f field) optionalDouble() *float64 {
+	d := f.double()
+	return &d
+}
+
+// optionalInt64 decodes an optional int64 field, which is set whenever it is present.
+func (f field) optionalInt64() *int64 {
+	v := int64(f.u64)
+	return &v
+}
+func (f field) bool() bool  { return f.u64 != 0 }
+func (f field) str() string { return string(f.bytes) }
@@ -0,0 +1,190 @@
+// Package client is a Go SDK for the weather API.
+//
+//	c := client.New("https://weather.example.com")
+//	c.APIKey = os.Getenv("WEATHER_API_KEY")
+//	w, err := c.GetWeather(ctx, "London")
+//
+// Pass more base URLs to New to fail over between regions:
+//
+//	c := client.New("https://eu.weather.example.com", "https://us.weather.example.com")
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxResponseBytes caps how much of a response body is read.
+const maxResponseBytes = 1 << 20
+
+// Weather is the current weather for a location. Temperatures are in °C. The optional details are
+// nil when the provider did not report them.
+type Weather struct {
+	Location    string  `json:"location"`
+	Temperature float64 `json:"temperature"`
+	Description string  `json:"description"`
+	// Condition classes the weather the same way whichever provider reported it, e.g. "rain",
+	// and IconURL pictures it. They are empty when the provider reported no condition.
+	Condition string   `json:"condition,omitempty"`
+	IconURL   string   `json:"icon_url,omitempty"`
+	Cached    bool     `json:"cached"`
+	Stale     bool     `json:"stale,omitempty"`
+	UVIndex   *float64 `json:"uv_index,omitempty"`
+	FeelsLike *float64 `json:"feels_like,omitempty"`
+	TempMin   *float64 `json:"temp_min,omitempty"`
+	TempMax   *float64 `json:"temp_max,omitempty"`
+	// Humidity is in percent, Pressure in hPa at sea level and WindSpeed in m/s. WindDirection
+	// is in degrees, where the wind blows from.
+	Humidity      *float64 `json:"humidity,omitempty"`
+	Pressure      *float64 `json:"pressure,omitempty"`
+	WindSpeed     *float64 `json:"wind_speed,omitempty"`
+	WindDirection *float64 `json:"wind_direction,omitempty"`
+	// Sunrise and Sunset are in the location's time zone. DayLength is in seconds.
+	Sunrise   *time.Time `json:"sunrise,omitempty"`
+	Sunset    *time.Time `json:"sunset,omitempty"`
+	DayLength int64      `json:"day_length,omitempty"`
+	// TimezoneOffset is the location's offset from UTC in seconds. ObservedAt is when the weather
+	// was observed, in the location's time zone.
+	TimezoneOffset *int64     `json:"timezone_offset,omitempty"`
+	ObservedAt     *time.Time `json:"observed_at,omitempty"`
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("weather api: %d %s", e.StatusCode, e.Message)
+}
+
+// Client calls the weather API. Set fields before first use.
+type Client struct {
+	// BaseURL is the API root, e.g. https://weather.example.com.
+	BaseURL string
+	// FallbackURLs are other regions tried, in order, when BaseURL fails. See failover.go.
+	FallbackURLs []string
+	// MaxRetries is the per-request retry budget: how many other regions a request may try after
+	// the first one fails. New sets it to DefaultMaxRetries.
+	MaxRetries int
+	// Cooldown is how long a failed region is skipped before it is health-checked again.
+	// Defaults to DefaultCooldown.
+	Cooldown time.Duration
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+	// APIKey is sent in X-API-Key when set.
+	APIKey string
+	// Verifier, when set, rejects responses whose X-Signature does not verify.
+	Verifier Verifier
+
+	regions regionHealth
+}
+
+// New creates a Client for baseURL, failing over to fallbackURLs when it is unavailable.
+func New(baseURL string, fallbackURLs ...string) *Client {
+	c := &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		MaxRetries: DefaultMaxRetries,
+		Cooldown:   DefaultCooldown,
+	}
+	for _, u := range fallbackURLs {
+		c.FallbackURLs = append(c.FallbackURLs, strings.TrimRight(u, "/"))
+	}
+	return c
+}
+
+// envelope is the API response wrapper.
+type envelope struct {
+	Data    json.RawMessage `json:"data"`
+	Error   *string         `json:"error"`
+	Message string          `json:"message"`
+}
+
+// GetWeather returns the current weather for location.
+func (c *Client) GetWeather(ctx context.Context, location string) (*Weather, error) {
+	var w Weather
+	if err := c.get(ctx, "/v1/weather?location="+url.QueryEscape(location), &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// get performs a GET request against path, failing over between regions, and decodes the
+// envelope's data into out.
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	var lastErr error
+	for i, base := range c.orderedRegions(ctx) {
+		if i > c.MaxRetries {
+			break
+		}
+		retry, err := c.getFrom(ctx, base, path, out)
+		if !retry {
+			c.regions.succeeded(base)
+			return err
+		}
+		c.regions.failed(base, c.cooldown())
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return lastErr
+}
+
+// getFrom performs the request against one region. retry reports whether the failure is the
+// region's fault (a transport error, 5xx or 429) so another region may answer instead.
+func (c *Client) getFrom(ctx context.Context, base, path string, out interface{}) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return true, err
+	}
+	regionFault := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	if c.Verifier != nil {
+		if err := c.Verifier.Verify(resp.Header.Get(SignatureHeader), body); err != nil {
+			return regionFault, err
+		}
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return regionFault, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || env.Error != nil {
+		msg := env.Message
+		if env.Error != nil {
+			msg = *env.Error
+		}
+		return regionFault, &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+	return false, json.Unmarshal(env.Data, out)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
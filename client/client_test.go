@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_GetWeather(t *testing.T) {
+	const body = `{"data":{"location":"London","temperature":15.2,"description":"clear sky","cached":true},"message":"Success"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/weather" || r.URL.Query().Get("location") != "New York" || r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"bad request","message":"Error"}`))
+			return
+		}
+		w.Header().Set(SignatureHeader, hmacHeader("s3cret", time.Now().Unix(), body))
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL + "/")
+	c.APIKey = "key"
+	c.Verifier = HMACVerifier{Secret: []byte("s3cret")}
+	w, err := c.GetWeather(context.Background(), "New York")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Location != "London" || w.Temperature != 15.2 || !w.Cached {
+		t.Errorf("Unexpected weather %+v", w)
+	}
+
+	c.Verifier = HMACVerifier{Secret: []byte("wrong")}
+	if _, err := c.GetWeather(context.Background(), "New York"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	c.Verifier = nil
+	var apiErr *APIError
+	if _, err := c.GetWeather(context.Background(), "Paris"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "bad request" {
+		t.Errorf("Expected APIError 400, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries lets a request try up to two other regions.
+	DefaultMaxRetries = 2
+	// DefaultCooldown is how long a failed region is skipped before it is health-checked again.
+	DefaultCooldown = 30 * time.Second
+	// healthPath is probed before a failed region is used again.
+	healthPath = "/readyz"
+	// healthTimeout bounds a single health check.
+	healthTimeout = 2 * time.Second
+)
+
+// regionState tracks one base URL. A region is down from its first retryable failure until
+// downUntil has passed and a health check succeeds.
+type regionState struct {
+	failures  int
+	downUntil time.Time
+}
+
+// regionHealth is the client's view of every region, shared by concurrent requests.
+type regionHealth struct {
+	mu     sync.Mutex
+	states map[string]*regionState
+	now    func() time.Time
+}
+
+func (h *regionHealth) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+// state returns the state for base. h.mu must be held.
+func (h *regionHealth) state(base string) *regionState {
+	if h.states == nil {
+		h.states = map[string]*regionState{}
+	}
+	s, ok := h.states[base]
+	if !ok {
+		s = &regionState{}
+		h.states[base] = s
+	}
+	return s
+}
+
+func (h *regionHealth) succeeded(base string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.state(base) = regionState{}
+}
+
+func (h *regionHealth) failed(base string, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.state(base)
+	s.failures++
+	s.downUntil = h.clock().Add(cooldown)
+}
+
+// status reports whether base is down and, if so, whether its cooldown has passed so it is due a
+// health check.
+func (h *regionHealth) status(base string) (down, due bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.state(base)
+	if s.failures == 0 {
+		return false, false
+	}
+	return true, !h.clock().Before(s.downUntil)
+}
+
+// Region is the health of one base URL as seen by the client.
+type Region struct {
+	BaseURL string
+	Healthy bool
+	// ConsecutiveFailures counts failed requests and health checks since the last success.
+	ConsecutiveFailures int
+}
+
+// Regions returns every configured base URL in failover order with its current health.
+func (c *Client) Regions() []Region {
+	bases := c.baseURLs()
+	regions := make([]Region, 0, len(bases))
+	c.regions.mu.Lock()
+	defer c.regions.mu.Unlock()
+	for _, base := range bases {
+		s := c.regions.state(base)
+		regions = append(regions, Region{BaseURL: base, Healthy: s.failures == 0, ConsecutiveFailures: s.failures})
+	}
+	return regions
+}
+
+func (c *Client) baseURLs() []string {
+	return append([]string{c.BaseURL}, c.FallbackURLs...)
+}
+
+func (c *Client) cooldown() time.Duration {
+	if c.Cooldown <= 0 {
+		return DefaultCooldown
+	}
+	return c.Cooldown
+}
+
+// orderedRegions returns the base URLs to try for one request: healthy regions in configured
+// order, then regions that are still down as a last resort. Down regions whose cooldown has passed
+// are health-checked first and move back to the healthy list if they answer.
+func (c *Client) orderedRegions(ctx context.Context) []string {
+	var healthy, down []string
+	for _, base := range c.baseURLs() {
+		isDown, due := c.regions.status(base)
+		if isDown && due {
+			if c.checkHealth(ctx, base) {
+				c.regions.succeeded(base)
+				isDown = false
+			} else {
+				c.regions.failed(base, c.cooldown())
+			}
+		}
+		if isDown {
+			down = append(down, base)
+		} else {
+			healthy = append(healthy, base)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// checkHealth reports whether base answers its readiness endpoint with a 2xx.
+func (c *Client) checkHealth(ctx context.Context, base string) bool {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+healthPath, nil)
+	if err != nil {
+		return false
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode <= 299
+}
+
+// CheckHealth health-checks every configured region now, regardless of cooldowns, and returns
+// the result. Call it periodically to detect recovered regions without waiting for traffic.
+func (c *Client) CheckHealth(ctx context.Context) []Region {
+	for _, base := range c.baseURLs() {
+		if c.checkHealth(ctx, base) {
+			c.regions.succeeded(base)
+		} else {
+			c.regions.failed(base, c.cooldown())
+		}
+	}
+	return c.Regions()
+}
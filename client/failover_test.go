@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const weatherBody = `{"data":{"location":"London","temperature":15.2,"description":"clear sky"},"message":"Success"}`
+
+// region is a test server whose weather and readiness responses can be switched.
+type region struct {
+	*httptest.Server
+	status  atomic.Int32
+	ready   atomic.Bool
+	weather atomic.Int32 // weather requests served
+}
+
+func newRegion(t *testing.T, status int) *region {
+	t.Helper()
+	r := &region{}
+	r.status.Store(int32(status))
+	r.ready.Store(true)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == healthPath {
+			if !r.ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		r.weather.Add(1)
+		w.WriteHeader(int(r.status.Load()))
+		if r.status.Load() == http.StatusOK {
+			_, _ = w.Write([]byte(weatherBody))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error":"failed","message":"Error"}`))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestClient_Failover(t *testing.T) {
+	primary := newRegion(t, http.StatusBadGateway)
+	secondary := newRegion(t, http.StatusOK)
+	c := New(primary.URL, secondary.URL)
+	now := time.Unix(1700000000, 0)
+	c.regions.now = func() time.Time { return now }
+
+	if w, err := c.GetWeather(context.Background(), "London"); err != nil || w.Location != "London" {
+		t.Fatalf("Expected the secondary region to answer, got %+v, %v", w, err)
+	}
+	if regions := c.Regions(); regions[0].Healthy || regions[0].ConsecutiveFailures != 1 || !regions[1].Healthy {
+		t.Errorf("Expected the primary marked down, got %+v", regions)
+	}
+
+	// Within the cooldown the primary is skipped.
+	_, _ = c.GetWeather(context.Background(), "London")
+	if primary.weather.Load() != 1 {
+		t.Errorf("Expected the down primary skipped, got %d requests", primary.weather.Load())
+	}
+
+	// After the cooldown a failed health check keeps it down...
+	now = now.Add(DefaultCooldown)
+	primary.status.Store(http.StatusOK)
+	primary.ready.Store(false)
+	_, _ = c.GetWeather(context.Background(), "London")
+	if primary.weather.Load() != 1 {
+		t.Errorf("Expected the primary skipped after a failed health check, got %d requests", primary.weather.Load())
+	}
+
+	// ...and a passing one brings it back.
+	now = now.Add(DefaultCooldown)
+	primary.ready.Store(true)
+	_, _ = c.GetWeather(context.Background(), "London")
+	if primary.weather.Load() != 2 || !c.Regions()[0].Healthy {
+		t.Errorf("Expected the recovered primary used again, got %d requests, %+v", primary.weather.Load(), c.Regions())
+	}
+}
+
+func TestClient_RetryBudget(t *testing.T) {
+	a := newRegion(t, http.StatusServiceUnavailable)
+	b := newRegion(t, http.StatusTooManyRequests)
+	ok := newRegion(t, http.StatusOK)
+
+	c := New(a.URL, b.URL, ok.URL)
+	c.MaxRetries = 1
+	var apiErr *APIError
+	if _, err := c.GetWeather(context.Background(), "London"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the last region's 429 once the budget is spent, got %v", err)
+	}
+	if ok.weather.Load() != 0 {
+		t.Error("Expected the third region not tried")
+	}
+
+	// The failed regions now go last, so the healthy one answers first time.
+	if _, err := c.GetWeather(context.Background(), "London"); err != nil || ok.weather.Load() != 1 {
+		t.Errorf("Expected the third region to answer, got %v", err)
+	}
+}
+
+func TestClient_NoFailoverOnClientError(t *testing.T) {
+	primary := newRegion(t, http.StatusBadRequest)
+	secondary := newRegion(t, http.StatusOK)
+	c := New(primary.URL, secondary.URL)
+
+	var apiErr *APIError
+	if _, err := c.GetWeather(context.Background(), "London"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected the 400 returned, got %v", err)
+	}
+	if secondary.weather.Load() != 0 || !c.Regions()[0].Healthy {
+		t.Error("Expected a client error not to fail over or mark the region down")
+	}
+}
+
+func TestClient_CheckHealth(t *testing.T) {
+	primary := newRegion(t, http.StatusOK)
+	secondary := newRegion(t, http.StatusOK)
+	secondary.ready.Store(false)
+	c := New(primary.URL, secondary.URL)
+
+	regions := c.CheckHealth(context.Background())
+	if !regions[0].Healthy || regions[1].Healthy {
+		t.Errorf("Unexpected health %+v", regions)
+	}
+}
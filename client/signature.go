@@ -0,0 +1,152 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the response signature:
+//
+//	X-Signature: t=<unix seconds>,alg=<hmac-sha256|ed25519>,kid=<key id>,sig=<base64url>
+//
+// The signature covers "<t>.<body>".
+const SignatureHeader = "X-Signature"
+
+// Signature algorithms.
+const (
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+)
+
+var (
+	// ErrMissingSignature is returned when a response carries no X-Signature header.
+	ErrMissingSignature = errors.New("weather api: response is not signed")
+	// ErrInvalidSignature is returned when a signature does not match the body.
+	ErrInvalidSignature = errors.New("weather api: invalid response signature")
+	// ErrSignatureExpired is returned when a signature is older than the verifier's MaxAge.
+	ErrSignatureExpired = errors.New("weather api: response signature expired")
+)
+
+// Signature is a parsed X-Signature header.
+type Signature struct {
+	Timestamp int64
+	Algorithm string
+	KeyID     string
+	Value     []byte
+}
+
+// ParseSignature parses an X-Signature header value.
+func ParseSignature(header string) (Signature, error) {
+	if header == "" {
+		return Signature{}, ErrMissingSignature
+	}
+	var sig Signature
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Signature{}, fmt.Errorf("weather api: malformed signature part %q", part)
+		}
+		switch k {
+		case "t":
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return Signature{}, fmt.Errorf("weather api: malformed signature timestamp: %w", err)
+			}
+			sig.Timestamp = ts
+		case "alg":
+			sig.Algorithm = v
+		case "kid":
+			sig.KeyID = v
+		case "sig":
+			b, err := base64.RawURLEncoding.DecodeString(v)
+			if err != nil {
+				return Signature{}, fmt.Errorf("weather api: malformed signature value: %w", err)
+			}
+			sig.Value = b
+		}
+	}
+	if sig.Timestamp == 0 || sig.Algorithm == "" || len(sig.Value) == 0 {
+		return Signature{}, fmt.Errorf("weather api: incomplete signature %q", header)
+	}
+	return sig, nil
+}
+
+// SignedPayload returns the bytes covered by a signature made at timestamp over body.
+func SignedPayload(timestamp int64, body []byte) []byte {
+	payload := strconv.AppendInt(nil, timestamp, 10)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
+
+// FormatSignature renders an X-Signature header value.
+func FormatSignature(sig Signature) string {
+	return "t=" + strconv.FormatInt(sig.Timestamp, 10) +
+		",alg=" + sig.Algorithm +
+		",kid=" + sig.KeyID +
+		",sig=" + base64.RawURLEncoding.EncodeToString(sig.Value)
+}
+
+// Verifier checks a response signature header against the response body.
+type Verifier interface {
+	Verify(header string, body []byte) error
+}
+
+// HMACVerifier verifies hmac-sha256 signatures made with a shared secret.
+type HMACVerifier struct {
+	Secret []byte
+	// MaxAge rejects signatures older than this, when positive.
+	MaxAge time.Duration
+}
+
+func (v HMACVerifier) Verify(header string, body []byte) error {
+	sig, err := parseFor(header, AlgHMACSHA256, v.MaxAge)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write(SignedPayload(sig.Timestamp, body))
+	if !hmac.Equal(mac.Sum(nil), sig.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Ed25519Verifier verifies ed25519 signatures with the server's public key.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+	// MaxAge rejects signatures older than this, when positive.
+	MaxAge time.Duration
+}
+
+func (v Ed25519Verifier) Verify(header string, body []byte) error {
+	sig, err := parseFor(header, AlgEd25519, v.MaxAge)
+	if err != nil {
+		return err
+	}
+	if len(v.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(v.PublicKey, SignedPayload(sig.Timestamp, body), sig.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// parseFor parses header and checks its algorithm and age.
+func parseFor(header, alg string, maxAge time.Duration) (Signature, error) {
+	sig, err := ParseSignature(header)
+	if err != nil {
+		return Signature{}, err
+	}
+	if sig.Algorithm != alg {
+		return Signature{}, fmt.Errorf("%w: expected %s, got %s", ErrInvalidSignature, alg, sig.Algorithm)
+	}
+	if maxAge > 0 && time.Since(time.Unix(sig.Timestamp, 0)) > maxAge {
+		return Signature{}, ErrSignatureExpired
+	}
+	return sig, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+func hmacHeader(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(SignedPayload(ts, []byte(body)))
+	return FormatSignature(Signature{Timestamp: ts, Algorithm: AlgHMACSHA256, KeyID: "k", Value: mac.Sum(nil)})
+}
+
+func TestParseSignature(t *testing.T) {
+	sig, err := ParseSignature(hmacHeader("s", 1700000000, "x"))
+	if err != nil || sig.Timestamp != 1700000000 || sig.Algorithm != AlgHMACSHA256 || sig.KeyID != "k" || len(sig.Value) != sha256.Size {
+		t.Errorf("Unexpected parse %+v, %v", sig, err)
+	}
+	if _, err := ParseSignature(""); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+	for _, bad := range []string{"t=1,alg=ed25519", "t=x,alg=a,sig=AA", "garbage", "t=1,alg=a,sig=%%%"} {
+		if _, err := ParseSignature(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestHMACVerifier(t *testing.T) {
+	now := time.Now().Unix()
+	v := HMACVerifier{Secret: []byte("s3cret"), MaxAge: time.Minute}
+
+	if err := v.Verify(hmacHeader("s3cret", now, "body"), []byte("body")); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := v.Verify(hmacHeader("other", now, "body"), []byte("body")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for the wrong secret, got %v", err)
+	}
+	if err := v.Verify(hmacHeader("s3cret", now-3600, "body"), []byte("body")); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("Expected ErrSignatureExpired, got %v", err)
+	}
+}
+
+func TestEd25519Verifier(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	ts := time.Now().Unix()
+	header := FormatSignature(Signature{Timestamp: ts, Algorithm: AlgEd25519, Value: ed25519.Sign(priv, SignedPayload(ts, []byte("body")))})
+
+	if err := (Ed25519Verifier{PublicKey: pub}).Verify(header, []byte("body")); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := (Ed25519Verifier{PublicKey: pub}).Verify(header, []byte("b0dy")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+	if err := (HMACVerifier{Secret: []byte("x")}).Verify(header, []byte("body")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected an algorithm mismatch to fail, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Webhook delivery headers. The signature is "v1=" followed by the hex HMAC-SHA256, keyed with the
+// subscription secret, of "<timestamp>.<nonce>.<body>".
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookNonceHeader     = "X-Webhook-Nonce"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// DefaultWebhookTolerance is how old a delivery may be before WebhookVerifier rejects it.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+var (
+	// ErrWebhookExpired is returned for deliveries outside the verifier's tolerance.
+	ErrWebhookExpired = errors.New("weather api: webhook timestamp outside tolerance")
+	// ErrWebhookReplayed is returned for a nonce that was already accepted.
+	ErrWebhookReplayed = errors.New("weather api: webhook nonce already seen")
+)
+
+// WebhookSignature computes the X-Webhook-Signature value of a delivery.
+func WebhookSignature(secret []byte, timestamp int64, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(strconv.AppendInt(nil, timestamp, 10))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookVerifier authenticates webhook deliveries and rejects replays: the signature must match,
+// the timestamp must be within Tolerance, and each nonce is accepted once. Nonces are remembered
+// in memory for Tolerance, so receivers running several instances should also deduplicate on
+// X-Webhook-ID.
+type WebhookVerifier struct {
+	Secret []byte
+	// Tolerance defaults to DefaultWebhookTolerance.
+	Tolerance time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// Verify checks the delivery headers h against body.
+func (v *WebhookVerifier) Verify(h http.Header, body []byte) error {
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	ts, err := strconv.ParseInt(h.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	nonce := h.Get(WebhookNonceHeader)
+	if nonce == "" || h.Get(WebhookSignatureHeader) == "" {
+		return ErrMissingSignature
+	}
+	want := WebhookSignature(v.Secret, ts, nonce, body)
+	if !hmac.Equal([]byte(want), []byte(h.Get(WebhookSignatureHeader))) {
+		return ErrInvalidSignature
+	}
+	now := time.Now()
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrWebhookExpired
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	for n, at := range v.seen {
+		if now.Sub(at) > tolerance {
+			delete(v.seen, n)
+		}
+	}
+	if _, dup := v.seen[nonce]; dup {
+		return ErrWebhookReplayed
+	}
+	v.seen[nonce] = now
+	return nil
+}
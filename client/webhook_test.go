@@ -0,0 +1,39 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func webhookHeaders(secret string, ts int64, nonce, body string) http.Header {
+	h := http.Header{}
+	h.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	h.Set(WebhookNonceHeader, nonce)
+	h.Set(WebhookSignatureHeader, WebhookSignature([]byte(secret), ts, nonce, []byte(body)))
+	return h
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	v := &WebhookVerifier{Secret: []byte("whsec")}
+	now := time.Now().Unix()
+	body := `{"id":"evt_1"}`
+
+	if err := v.Verify(webhookHeaders("whsec", now, "n1", body), []byte(body)); err != nil {
+		t.Fatalf("Expected a valid delivery, got %v", err)
+	}
+	if err := v.Verify(webhookHeaders("whsec", now, "n1", body), []byte(body)); !errors.Is(err, ErrWebhookReplayed) {
+		t.Errorf("Expected the same nonce to be rejected, got %v", err)
+	}
+	if err := v.Verify(webhookHeaders("whsec", now, "n2", body), []byte(`{"id":"evt_2"}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a tampered body to fail, got %v", err)
+	}
+	if err := v.Verify(webhookHeaders("whsec", now-3600, "n3", body), []byte(body)); !errors.Is(err, ErrWebhookExpired) {
+		t.Errorf("Expected an old delivery to fail, got %v", err)
+	}
+	if err := v.Verify(http.Header{}, []byte(body)); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+}
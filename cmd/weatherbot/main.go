@@ -0,0 +1,21 @@
+// Command weatherbot serves Slack slash commands and Telegram webhooks that answer
+// "/weather <city>" using the same service, cache and provider as the HTTP API.
+package main
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/bot"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func main() {
+	b := bot.New()
+	mux := http.NewServeMux()
+	mux.Handle("/slack/command", b.SlackHandler(config.GetSlackSigningSecret()))
+	mux.Handle("/telegram/webhook", b.TelegramHandler(config.GetTelegramWebhookSecret()))
+
+	port := config.GetBotPort()
+	config.GetLogger().Infow("Weather bot running", "port", port)
+	config.GetLogger().Fatalw("Bot exited", "error", http.ListenAndServe(":"+port, mux))
+}
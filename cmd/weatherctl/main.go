@@ -0,0 +1,81 @@
+// Command weatherctl holds operational tools for the weather API. `weatherctl config migrate`
+// rewrites a config.yaml from an older release in the current layout and reports deprecated keys,
+// so deployments can be upgraded safely across config-breaking releases.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+const usage = `Usage:
+  weatherctl config migrate [-in config.yaml] [-out file] [-w]
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "config" || os.Args[2] != "migrate" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(migrate(os.Args[3:], os.Stdout, os.Stderr))
+}
+
+// migrate runs `config migrate`. The migrated config is written to -out, back to -in with -w, or
+// to stdout; the report goes to stderr. It exits 1 when a key could not be migrated.
+func migrate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	in := fs.String("in", "config.yaml", "config file to migrate")
+	out := fs.String("out", "", "write the migrated config to this file instead of stdout")
+	inPlace := fs.Bool("w", false, "write the migrated config back to -in")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	src, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	migrated, report, err := config.MigrateConfig(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *in, err)
+		return 1
+	}
+
+	for _, m := range report.Moved {
+		fmt.Fprintf(stderr, "moved %s to %s\n", m.From, m.To)
+	}
+	for _, m := range report.Conflicts {
+		fmt.Fprintf(stderr, "not migrated: %s is deprecated for %s, which is already set or cannot hold it; merge them by hand\n", m.From, m.To)
+	}
+	for _, d := range report.Deprecated {
+		fmt.Fprintf(stderr, "deprecated: %s: %s\n", d.Key, d.Note)
+	}
+	if !report.Changed() && len(report.Conflicts) == 0 && len(report.Deprecated) == 0 {
+		fmt.Fprintf(stderr, "%s is up to date\n", *in)
+	}
+
+	switch {
+	case *inPlace:
+		if report.Changed() {
+			err = os.WriteFile(*in, migrated, 0o644)
+		}
+	case *out != "":
+		err = os.WriteFile(*out, migrated, 0o644)
+	default:
+		_, err = stdout.Write(migrated)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if len(report.Conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
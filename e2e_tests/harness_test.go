@@ -0,0 +1,243 @@
+//go:build e2e
+
+package e2etest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// The end-to-end tests run the real binary against a real Redis in Docker, for behaviour
+// miniredis cannot reproduce: hung connections, AUTH and eviction policies. They need the docker
+// CLI and are skipped without it. Run them with `make e2e` or `go test -tags e2e ./e2e_tests/`.
+
+// redisImage is the image Redis runs from, as in docker-compose.yml.
+const redisImage = "redis:7-alpine"
+
+// apiKey is the provider key the service is started with and the mock provider expects.
+const apiKey = "e2e-key"
+
+// binary is the service built once for every test by TestMain.
+var binary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "weather-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "e2e:", err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "weather-api")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = ".."
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "e2e: build failed:", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// dockerOnce checks once whether the docker CLI can reach a daemon.
+var dockerOnce = sync.OnceValue(func() error {
+	return exec.Command("docker", "info").Run()
+})
+
+func requireDocker(t *testing.T) {
+	t.Helper()
+	if err := dockerOnce(); err != nil {
+		t.Skipf("Docker unavailable: %v", err)
+	}
+}
+
+// docker runs the docker CLI and returns its trimmed output.
+func docker(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("docker %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// redisContainer is a Redis server running in Docker.
+type redisContainer struct {
+	t    *testing.T
+	id   string
+	Addr string
+}
+
+// startRedis runs Redis with the given redis-server arguments, e.g. "--requirepass", "secret",
+// and waits until it accepts connections. It is removed when the test ends.
+func startRedis(t *testing.T, args ...string) *redisContainer {
+	t.Helper()
+	requireDocker(t)
+	run := append([]string{"run", "-d", "--rm", "-p", "127.0.0.1::6379", redisImage, "redis-server"}, args...)
+	c := &redisContainer{t: t, id: docker(t, run...)}
+	t.Cleanup(func() { _ = exec.Command("docker", "rm", "-f", c.id).Run() })
+	c.Addr, _, _ = strings.Cut(docker(t, "port", c.id, "6379/tcp"), "\n")
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		// Without the password, a server requiring one answers NOAUTH, which is up all the same
+		out, _ := exec.Command("docker", "exec", c.id, "redis-cli", "ping").CombinedOutput()
+		if strings.Contains(string(out), "PONG") || strings.Contains(string(out), "NOAUTH") {
+			return c
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Redis did not start: %s", out)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Client returns a client to the container, authenticating with password when not empty.
+func (c *redisContainer) Client(password string) *redisv9.Client {
+	client := redisv9.NewClient(&redisv9.Options{Addr: c.Addr, Password: password})
+	c.t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// Stop stops the server; connections are refused from then on.
+func (c *redisContainer) Stop() { docker(c.t, "stop", c.id) }
+
+// Pause freezes the server: connections stay open but nothing is answered.
+func (c *redisContainer) Pause() { docker(c.t, "pause", c.id) }
+
+// Unpause resumes a paused server.
+func (c *redisContainer) Unpause() { docker(c.t, "unpause", c.id) }
+
+// weatherService is the service binary running against a Redis and a mock provider.
+type weatherService struct {
+	URL  string
+	cmd  *exec.Cmd
+	logs *bytes.Buffer
+}
+
+// serviceConfig is the config.yaml the service runs with. Its directory also holds an empty
+// go.mod, which is how the service finds its config file.
+const serviceConfig = `openweathermap:
+  api_url: "%[1]s/weather"
+  forecast_url: "%[1]s/forecast"
+  hourly_forecast_url: "%[1]s/forecast/hourly"
+logging:
+  level: info
+redis:
+  addr: "%[2]s"
+server:
+  port: "%[3]d"
+cache:
+  timeouts:
+    read: 50ms
+    write: 100ms
+  write_queue:
+    size: 0
+rate_limiter:
+  global: { rate: 1000, burst: 1000 }
+  param: { rate: 1000, burst: 1000 }
+`
+
+// startService runs the service against Redis at redisAddr and provider, and waits until it
+// answers. It is stopped with SIGTERM when the test ends, and its logs are printed if it failed.
+func startService(t *testing.T, redisAddr string, provider *testutil.MockProvider) *weatherService {
+	t.Helper()
+	providerServer := provider.Start()
+	t.Cleanup(providerServer.Close)
+
+	dir := t.TempDir()
+	port := freePort(t)
+	files := map[string]string{
+		"go.mod":      "module e2e\n",
+		"config.yaml": fmt.Sprintf(serviceConfig, providerServer.URL, redisAddr, port),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &weatherService{URL: fmt.Sprintf("http://127.0.0.1:%d", port), logs: &bytes.Buffer{}}
+	s.cmd = exec.Command(binary)
+	s.cmd.Dir = dir
+	s.cmd.Env = append(os.Environ(), "OPENWEATHERMAP_API_KEY="+apiKey, "REDIS_ADDR="+redisAddr)
+	s.cmd.Stdout, s.cmd.Stderr = s.logs, s.logs
+	if err := s.cmd.Start(); err != nil {
+		t.Fatalf("Start service: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.cmd.Process.Signal(syscall.SIGTERM)
+		done := make(chan error, 1)
+		go func() { done <- s.cmd.Wait() }()
+		select {
+		case <-done:
+		case <-time.After(15 * time.Second):
+			_ = s.cmd.Process.Kill()
+			t.Error("Expected the service to shut down on SIGTERM")
+		}
+		if t.Failed() {
+			t.Logf("Service logs:\n%s", s.logs)
+		}
+	})
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		resp, err := http.Get(s.URL + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Service did not start: %v\n%s", err, s.logs)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Get requests path from the service and returns the response with its body read, failing the
+// test if it takes longer than timeout.
+func (s *weatherService) Get(t *testing.T, path string, timeout time.Duration) (*http.Response, []byte) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp, body
+}
+
+// freePort returns a TCP port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
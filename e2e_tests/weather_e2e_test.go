@@ -0,0 +1,174 @@
+//go:build e2e
+
+package e2etest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+)
+
+// requestTimeout bounds every request; the /weather route budget is 2s.
+const requestTimeout = 5 * time.Second
+
+func newProvider() *testutil.MockProvider {
+	return &testutil.MockProvider{Seed: 42, APIKey: apiKey, Cities: []string{"Jakarta", "Bandung", "Surabaya", "Medan"}}
+}
+
+func TestE2E_HappyPath(t *testing.T) {
+	redis := startRedis(t)
+	provider := newProvider()
+	svc := startService(t, redis.Addr, provider)
+	ctx := context.Background()
+
+	resp, body := svc.Get(t, "/weather?location=Jakarta", requestTimeout)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != "MISS" {
+		t.Fatalf("Expected a 200 cache miss, got %d %s: %s", resp.StatusCode, resp.Header.Get("X-Cache"), body)
+	}
+	var weather struct {
+		Data struct {
+			Location    string  `json:"location"`
+			Temperature float64 `json:"temperature"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &weather); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := provider.Conditions("Jakarta", time.Now()); weather.Data.Location != "Jakarta" || weather.Data.Temperature != want.Temperature {
+		t.Errorf("Expected %+v, got %s", want, body)
+	}
+	if ttl := redis.Client("").TTL(ctx, "weather:Jakarta").Val(); ttl <= 0 {
+		t.Errorf("Expected weather:Jakarta stored in Redis with a TTL, got %v", ttl)
+	}
+
+	if resp, _ := svc.Get(t, "/weather?location=Jakarta", requestTimeout); resp.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("Expected a cache hit, got %s", resp.Header.Get("X-Cache"))
+	}
+	if provider.Calls() != 1 {
+		t.Errorf("Expected one provider call, got %d", provider.Calls())
+	}
+
+	resp, body = svc.Get(t, "/forecast?location=Bandung&hours=6", requestTimeout)
+	if resp.StatusCode != http.StatusOK || strings.Count(string(body), `"time"`) != 6 {
+		t.Errorf("Expected a 6 hour forecast, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = svc.Get(t, "/weather/batch?locations=Jakarta,Surabaya,Atlantis", requestTimeout)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"not_found"`) || strings.Count(string(body), `"weather"`) != 2 {
+		t.Errorf("Expected two results and one not found, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = svc.Get(t, "/weather/compare?locations=Jakarta,Surabaya", requestTimeout)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"baseline":"Jakarta"`) || strings.Count(string(body), `"delta"`) != 1 {
+		t.Errorf("Expected Surabaya compared with Jakarta, got %d: %s", resp.StatusCode, body)
+	}
+
+	if resp, body := svc.Get(t, "/readyz", requestTimeout); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected ready, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestE2E_RedisStopped(t *testing.T) {
+	redis := startRedis(t)
+	provider := newProvider()
+	svc := startService(t, redis.Addr, provider)
+	redis.Stop()
+
+	// Without a cache, every request goes to the provider
+	for i := 0; i < 2; i++ {
+		if resp, body := svc.Get(t, "/weather?location=Medan", requestTimeout); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected weather from the provider, got %d: %s", resp.StatusCode, body)
+		}
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", provider.Calls())
+	}
+	if resp, _ := svc.Get(t, "/readyz", requestTimeout); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready, got %d", resp.StatusCode)
+	}
+}
+
+func TestE2E_RedisHangs(t *testing.T) {
+	redis := startRedis(t)
+	provider := newProvider()
+	svc := startService(t, redis.Addr, provider)
+	if resp, _ := svc.Get(t, "/weather?location=Jakarta", requestTimeout); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	redis.Pause()
+	defer redis.Unpause()
+
+	// Cache reads give up after cache.timeouts.read, so a hung Redis costs milliseconds, not the
+	// route budget
+	start := time.Now()
+	resp, body := svc.Get(t, "/weather?location=Bandung", requestTimeout)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected weather from the provider, got %d: %s", resp.StatusCode, body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the cache timeouts to bound the request, took %v", elapsed)
+	}
+	if resp, _ := svc.Get(t, "/readyz", requestTimeout); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while Redis hangs, got %d", resp.StatusCode)
+	}
+}
+
+func TestE2E_RedisRequiresAuth(t *testing.T) {
+	redis := startRedis(t, "--requirepass", "secret")
+	provider := newProvider()
+	svc := startService(t, redis.Addr, provider)
+
+	// Every command fails with NOAUTH, so nothing is cached but requests are still served
+	for i := 0; i < 2; i++ {
+		if resp, body := svc.Get(t, "/weather?location=Surabaya", requestTimeout); resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != "MISS" {
+			t.Fatalf("Expected an uncached 200, got %d %s: %s", resp.StatusCode, resp.Header.Get("X-Cache"), body)
+		}
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", provider.Calls())
+	}
+	resp, body := svc.Get(t, "/readyz", requestTimeout)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), "NOAUTH") {
+		t.Errorf("Expected not ready with NOAUTH, got %d: %s", resp.StatusCode, body)
+	}
+	if n := redis.Client("secret").Exists(context.Background(), "weather:Surabaya").Val(); n != 0 {
+		t.Errorf("Expected nothing cached without the password, got %d keys", n)
+	}
+}
+
+func TestE2E_EvictionPolicies(t *testing.T) {
+	for _, tt := range []struct {
+		policy string
+		cached bool
+	}{
+		// A full Redis refusing writes leaves the entry uncached, but the request succeeds
+		{"noeviction", false},
+		// An evicting one makes room for it
+		{"allkeys-lru", true},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			redis := startRedis(t, "--maxmemory", "4mb", "--maxmemory-policy", tt.policy)
+			client := redis.Client("")
+			ctx := context.Background()
+			filler := strings.Repeat("x", 64<<10)
+			for i := 0; i < 128; i++ {
+				if err := client.Set(ctx, "filler:"+time.Now().Format(time.RFC3339Nano), filler, 0).Err(); err != nil {
+					break
+				}
+			}
+
+			svc := startService(t, redis.Addr, newProvider())
+			if resp, body := svc.Get(t, "/weather?location=Jakarta", requestTimeout); resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200 with Redis full, got %d: %s", resp.StatusCode, body)
+			}
+			if cached := client.Exists(ctx, "weather:Jakarta").Val() == 1; cached != tt.cached {
+				t.Errorf("Expected cached = %v under %s, got %v", tt.cached, tt.policy, cached)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
-	"time"
 
 	"github.com/fakhrymubarak/weather-api-redis/internal/config"
 	"github.com/fakhrymubarak/weather-api-redis/internal/handler"
@@ -57,13 +56,14 @@ func setupIntegrationTestServer() *httptest.Server {
 	mux := http.NewServeMux()
 	mux.Handle("/weather", middleware.RateLimitMiddleware(http.HandlerFunc(weatherHandler.HandleWeather)))
 
+	timeouts := config.GetServerTimeouts()
 	srv := &http.Server{
 		Addr:              config.GetServerPort(),
 		Handler:           mux,
-		ReadHeaderTimeout: parseDurationOrDefault(config.GetServerTimeout("read_header_timeout"), 15*time.Second),
-		ReadTimeout:       parseDurationOrDefault(config.GetServerTimeout("read_timeout"), 15*time.Second),
-		WriteTimeout:      parseDurationOrDefault(config.GetServerTimeout("write_timeout"), 10*time.Second),
-		IdleTimeout:       parseDurationOrDefault(config.GetServerTimeout("idle_timeout"), 30*time.Second),
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
 	}
 
 	// Create a channel to communicate server startup
@@ -81,14 +81,6 @@ func setupIntegrationTestServer() *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
-func parseDurationOrDefault(s string, def time.Duration) time.Duration {
-	d, err := time.ParseDuration(s)
-	if err != nil {
-		return def
-	}
-	return d
-}
-
 // ResetRateLimiterForIntegration Add a helper to reset the rate limiter before integration tests
 func ResetRateLimiterForIntegration() {
 	middleware.ResetVisitors()
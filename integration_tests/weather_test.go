@@ -198,19 +198,20 @@ func (suite *WeatherAPITestSuite) TestWeatherEndpoint() {
 				// Clear any cached data for this test
 				client := redis.GetClient()
 				ctx := redis.GetContext()
-				client.Del(ctx, "weather:InvalidCity12345")
+				client.Del(ctx, "weather:Invalid_City")
 			},
 			setupRequest: func() *http.Request {
-				req, _ := http.NewRequest(http.MethodGet, suite.httpServer.URL+"/weather?location=InvalidCity12345", nil)
+				req, _ := http.NewRequest(http.MethodGet, suite.httpServer.URL+"/weather?location=Invalid_City", nil)
 				return req
 			},
-			wantStatus: http.StatusNotFound,
+			// Rejected by the embedded geodata check before any upstream call
+			wantStatus: http.StatusBadRequest,
 			validate: func(t *testing.T, resp *http.Response) {
 				var response model.Response
 				err := json.NewDecoder(resp.Body).Decode(&response)
 				assert.NoError(t, err)
 				assert.NotNil(t, response.Error)
-				assert.Equal(t, "city not found", *response.Error)
+				assert.Equal(t, "invalid location: unexpected character '_'", *response.Error)
 			},
 		},
 		{
@@ -0,0 +1,166 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Code is a stable, machine-readable identifier for a class of errors.
+type Code string
+
+const (
+	CodeNotFound      Code = "not_found"
+	CodeInvalidInput  Code = "invalid_input"
+	CodeUpstream      Code = "upstream_error"
+	CodeUnavailable   Code = "unavailable"
+	CodeTimeout       Code = "timeout"
+	CodeInternal      Code = "internal_error"
+	CodeAPIKeyMissing Code = "api_key_missing"
+)
+
+// Error is a single error tagged with a code and, optionally, the provider and location it relates to.
+type Error struct {
+	Code     Code   `json:"code"`
+	Provider string `json:"provider,omitempty"`
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+	Err      error  `json:"-"`
+}
+
+// New creates a coded error for the given provider and location. Either may be empty.
+func New(code Code, provider, location string, err error) *Error {
+	e := &Error{Code: code, Provider: provider, Location: location, Err: err}
+	if err != nil {
+		e.Message = err.Error()
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(string(e.Code))
+	if e.Provider != "" {
+		b.WriteString(" [provider=" + e.Provider + "]")
+	}
+	if e.Location != "" {
+		b.WriteString(" [location=" + e.Location + "]")
+	}
+	if e.Message != "" {
+		b.WriteString(": " + e.Message)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler so coded errors log as structured fields.
+func (e *Error) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("code", string(e.Code))
+	if e.Provider != "" {
+		enc.AddString("provider", e.Provider)
+	}
+	if e.Location != "" {
+		enc.AddString("location", e.Location)
+	}
+	enc.AddString("message", e.Message)
+	return nil
+}
+
+// Multi aggregates errors collected across providers or locations, preserving every failure
+// rather than only the last one encountered. The zero value is ready to use.
+type Multi struct {
+	Errors []*Error
+}
+
+// Add records err under the given code, provider and location. Nil errors are ignored.
+func (m *Multi) Add(code Code, provider, location string, err error) {
+	if err == nil {
+		return
+	}
+	m.Errors = append(m.Errors, New(code, provider, location, err))
+}
+
+// Append records err as-is when it is already a coded error (or another Multi), and as
+// CodeInternal otherwise. Nil errors are ignored.
+func (m *Multi) Append(err error) {
+	if err == nil {
+		return
+	}
+	var multi *Multi
+	if errors.As(err, &multi) {
+		m.Errors = append(m.Errors, multi.Errors...)
+		return
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		m.Errors = append(m.Errors, coded)
+		return
+	}
+	m.Add(CodeInternal, "", "", err)
+}
+
+// Len returns the number of aggregated errors.
+func (m *Multi) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.Errors)
+}
+
+// ErrorOrNil returns m as an error when it holds at least one error, and nil otherwise.
+func (m *Multi) ErrorOrNil() error {
+	if m.Len() == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *Multi) Error() string {
+	switch m.Len() {
+	case 0:
+		return "no errors"
+	case 1:
+		return m.Errors[0].Error()
+	}
+	msgs := make([]string, len(m.Errors))
+	for i, e := range m.Errors {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(m.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap exposes the aggregated errors to errors.Is and errors.As.
+func (m *Multi) Unwrap() []error {
+	errs := make([]error, len(m.Errors))
+	for i, e := range m.Errors {
+		errs[i] = e
+	}
+	return errs
+}
+
+// MarshalJSON serializes the aggregated errors as a JSON array.
+func (m *Multi) MarshalJSON() ([]byte, error) {
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(m.Errors)
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler so aggregated errors log as one structured field.
+func (m *Multi) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("count", m.Len())
+	return enc.AddArray("errors", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, e := range m.Errors {
+			if err := arr.AppendObject(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
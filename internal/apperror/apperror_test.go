@@ -0,0 +1,113 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var errBoom = errors.New("boom")
+
+func TestMulti_AddAndErrorOrNil(t *testing.T) {
+	var m Multi
+	if m.ErrorOrNil() != nil {
+		t.Fatal("Expected nil error for empty Multi")
+	}
+
+	m.Add(CodeUpstream, "openweathermap", "Jakarta", errBoom)
+	m.Add(CodeNotFound, "openweathermap", "Atlantis", errors.New("city not found"))
+	m.Add(CodeInternal, "", "", nil)
+
+	if m.Len() != 2 {
+		t.Fatalf("Expected 2 errors, got %d", m.Len())
+	}
+	err := m.ErrorOrNil()
+	if err == nil {
+		t.Fatal("Expected non-nil error")
+	}
+	if !strings.Contains(err.Error(), "2 errors occurred") {
+		t.Errorf("Expected aggregated message, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), "location=Atlantis") {
+		t.Errorf("Expected message to mention every location, got %q", err.Error())
+	}
+}
+
+func TestMulti_AppendFlattensAndPreservesCodes(t *testing.T) {
+	var inner Multi
+	inner.Add(CodeTimeout, "p1", "Bandung", errBoom)
+
+	var m Multi
+	m.Append(&inner)
+	m.Append(New(CodeNotFound, "p2", "Medan", errBoom))
+	m.Append(errBoom)
+	m.Append(nil)
+
+	if m.Len() != 3 {
+		t.Fatalf("Expected 3 errors, got %d", m.Len())
+	}
+	want := []Code{CodeTimeout, CodeNotFound, CodeInternal}
+	for i, code := range want {
+		if m.Errors[i].Code != code {
+			t.Errorf("Expected code %s at %d, got %s", code, i, m.Errors[i].Code)
+		}
+	}
+}
+
+func TestMulti_ErrorsIsAndAs(t *testing.T) {
+	var m Multi
+	m.Add(CodeUpstream, "openweathermap", "Jakarta", errBoom)
+
+	if !errors.Is(&m, errBoom) {
+		t.Error("Expected errors.Is to find the wrapped error")
+	}
+	var coded *Error
+	if !errors.As(&m, &coded) {
+		t.Fatal("Expected errors.As to find a coded error")
+	}
+	if coded.Location != "Jakarta" {
+		t.Errorf("Expected location Jakarta, got %s", coded.Location)
+	}
+}
+
+func TestMulti_MarshalJSON(t *testing.T) {
+	var empty Multi
+	b, err := json.Marshal(&empty)
+	if err != nil || string(b) != "[]" {
+		t.Errorf("Expected empty array, got %s (%v)", b, err)
+	}
+
+	var m Multi
+	m.Add(CodeNotFound, "openweathermap", "Atlantis", errors.New("city not found"))
+	b, err = json.Marshal(&m)
+	if err != nil {
+		t.Fatalf("Unexpected marshal error: %v", err)
+	}
+	var decoded []map[string]string
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unexpected unmarshal error: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0]["code"] != "not_found" || decoded[0]["message"] != "city not found" {
+		t.Errorf("Unexpected JSON: %s", b)
+	}
+}
+
+func TestMulti_MarshalLogObject(t *testing.T) {
+	var m Multi
+	m.Add(CodeUpstream, "openweathermap", "Jakarta", errBoom)
+	m.Add(CodeTimeout, "openweathermap", "Bandung", errBoom)
+
+	enc := zapcore.NewMapObjectEncoder()
+	if err := m.MarshalLogObject(enc); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if enc.Fields["count"] != 2 {
+		t.Errorf("Expected count 2, got %v", enc.Fields["count"])
+	}
+	if errs, ok := enc.Fields["errors"].([]interface{}); !ok || len(errs) != 2 {
+		t.Errorf("Expected 2 logged errors, got %v", enc.Fields["errors"])
+	}
+}
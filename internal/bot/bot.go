@@ -0,0 +1,102 @@
+// Package bot answers "/weather <location>" chat commands from Slack and Telegram using the
+// weather service, so teams can get conditions in chat without calling the HTTP API.
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+	"golang.org/x/time/rate"
+)
+
+// commandTimeout bounds a single lookup; chat platforms expect a reply within a few seconds.
+const commandTimeout = 2500 * time.Millisecond
+
+// Bot turns chat commands into replies. It is safe for concurrent use.
+type Bot struct {
+	WeatherService service.WeatherServiceInterface
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter // key: platform:chat
+}
+
+// New creates a Bot using svc, or the default weather service when svc is omitted.
+func New(svc ...service.WeatherServiceInterface) *Bot {
+	var weatherService service.WeatherServiceInterface
+	if len(svc) > 0 && svc[0] != nil {
+		weatherService = svc[0]
+	} else {
+		weatherService = service.NewWeatherService()
+	}
+	return &Bot{WeatherService: weatherService, limiters: make(map[string]*rate.Limiter)}
+}
+
+// Reply returns the message to send back for text received in chat. Formatting is plain text
+// with *bold* markers, which both Slack mrkdwn and Telegram Markdown render.
+func (b *Bot) Reply(ctx context.Context, chat, text string) string {
+	location, ok := parseCommand(text)
+	if !ok {
+		return "Usage: /weather <city>, e.g. /weather Jakarta"
+	}
+	if !b.allow(chat) {
+		return "You're asking too quickly, please try again in a minute."
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	weather, err := b.WeatherService.GetWeather(ctx, location)
+	if err != nil {
+		var notFound *repository.LocationNotFoundError
+		if errors.As(err, &notFound) || errors.Is(err, repository.ErrLocationNotFound) || errors.Is(err, geodata.ErrInvalidLocation) {
+			return fmt.Sprintf("I couldn't find a place called %q.", location)
+		}
+		logger().Warnw("Bot lookup failed", "chat", chat, "location", location, "error", err)
+		return "Sorry, the weather service is unavailable right now."
+	}
+
+	msg := fmt.Sprintf("*%s*: %.1f°C, %s", weather.Location, weather.Temperature, weather.Description)
+	if weather.Stale {
+		msg += " (cached, may be out of date)"
+	}
+	return msg
+}
+
+// parseCommand extracts the location from "/weather <location>". A bare location is accepted too,
+// as Slack strips the command name from slash command text.
+func parseCommand(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if cmd, rest, found := strings.Cut(text, " "); strings.HasPrefix(cmd, "/") {
+		if !found || !isWeatherCommand(cmd) {
+			return "", false
+		}
+		text = strings.TrimSpace(rest)
+	}
+	return text, text != ""
+}
+
+// isWeatherCommand matches /weather and Telegram's /weather@BotName form.
+func isWeatherCommand(cmd string) bool {
+	name, _, _ := strings.Cut(cmd, "@")
+	return strings.EqualFold(name, "/weather")
+}
+
+// allow applies the per-chat rate limit (bot.rate_limiter).
+func (b *Bot) allow(chat string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.limiters[chat]
+	if !ok {
+		r, burst := config.GetBotRateLimiterConfig()
+		l = rate.NewLimiter(rate.Limit(r/60.0), burst)
+		b.limiters[chat] = l
+	}
+	return l.Allow()
+}
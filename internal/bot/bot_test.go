@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/spf13/viper"
+)
+
+type mockWeatherService struct {
+	err      error
+	location string
+}
+
+func (m *mockWeatherService) GetWeather(_ context.Context, location string) (*model.WeatherResponse, error) {
+	m.location = location
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.WeatherResponse{Location: location, Temperature: 31.04, Description: "scattered clouds"}, nil
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"/weather Jakarta", "Jakarta", true},
+		{"  /weather   New York  ", "New York", true},
+		{"/weather@WeatherBot Oslo", "Oslo", true},
+		{"/WEATHER Rome", "Rome", true},
+		{"Jakarta", "Jakarta", true},
+		{"/weather", "", false},
+		{"/forecast Jakarta", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCommand(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseCommand(%q) = %q, %v; want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReply(t *testing.T) {
+	svc := &mockWeatherService{}
+	b := New(svc)
+
+	if got := b.Reply(context.Background(), "c1", "/weather Jakarta"); got != "*Jakarta*: 31.0°C, scattered clouds" {
+		t.Errorf("Unexpected reply %q", got)
+	}
+	if got := b.Reply(context.Background(), "c1", "/weather"); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("Expected usage help, got %q", got)
+	}
+
+	svc.err = &repository.LocationNotFoundError{Message: "city not found"}
+	if got := b.Reply(context.Background(), "c2", "/weather Atlantis"); !strings.Contains(got, "couldn't find") {
+		t.Errorf("Expected a not-found reply, got %q", got)
+	}
+	svc.err = repository.ErrExternalAPI
+	if got := b.Reply(context.Background(), "c2", "/weather Jakarta"); !strings.Contains(got, "unavailable") {
+		t.Errorf("Expected an unavailable reply, got %q", got)
+	}
+}
+
+func TestReply_PerChatRateLimit(t *testing.T) {
+	viper.Set("bot.rate_limiter.burst", 2)
+	defer viper.Set("bot.rate_limiter.burst", nil)
+	b := New(&mockWeatherService{})
+
+	for i := 0; i < 2; i++ {
+		if got := b.Reply(context.Background(), "busy", "/weather Oslo"); strings.Contains(got, "too quickly") {
+			t.Fatalf("Request %d unexpectedly limited", i+1)
+		}
+	}
+	if got := b.Reply(context.Background(), "busy", "/weather Oslo"); !strings.Contains(got, "too quickly") {
+		t.Errorf("Expected the third command to be limited, got %q", got)
+	}
+	if got := b.Reply(context.Background(), "quiet", "/weather Oslo"); strings.Contains(got, "too quickly") {
+		t.Error("Expected other chats to have their own limit")
+	}
+}
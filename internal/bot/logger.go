@@ -0,0 +1,11 @@
+package bot
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the bot module logger, whose level is set by logging.levels.bot.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("bot")
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// slackMaxSkew rejects replayed requests whose timestamp is older than this.
+const slackMaxSkew = 5 * time.Minute
+
+// SlackHandler answers Slack slash commands. Requests are verified with the app's signing secret
+// (https://api.slack.com/authentication/verifying-requests-from-slack).
+func (b *Bot) SlackHandler(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !verifySlackSignature(signingSecret, r.Header, body, time.Now()) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		chat := "slack:" + form.Get("team_id") + ":" + form.Get("channel_id")
+		reply := b.Reply(r.Context(), chat, form.Get("text"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"response_type": "in_channel", "text": reply})
+	})
+}
+
+// verifySlackSignature checks X-Slack-Signature against HMAC-SHA256 of "v0:<timestamp>:<body>".
+func verifySlackSignature(secret string, h http.Header, body []byte, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(h.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(ts, 10) + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(h.Get("X-Slack-Signature")))
+}
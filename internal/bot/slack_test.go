@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signSlack(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(ts, 10) + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte("text=Jakarta")
+	h := http.Header{}
+	h.Set("X-Slack-Request-Timestamp", "1700000000")
+	h.Set("X-Slack-Signature", signSlack("s3cret", 1700000000, string(body)))
+
+	if !verifySlackSignature("s3cret", h, body, now) {
+		t.Error("Expected a valid signature to verify")
+	}
+	if verifySlackSignature("other", h, body, now) {
+		t.Error("Expected a wrong secret to fail")
+	}
+	if verifySlackSignature("s3cret", h, []byte("text=Oslo"), now) {
+		t.Error("Expected a tampered body to fail")
+	}
+	if verifySlackSignature("s3cret", h, body, now.Add(10*time.Minute)) {
+		t.Error("Expected an old timestamp to be rejected as a replay")
+	}
+	if verifySlackSignature("", h, body, now) {
+		t.Error("Expected an unconfigured secret to reject everything")
+	}
+}
+
+func TestSlackHandler(t *testing.T) {
+	b := New(&mockWeatherService{})
+	h := b.SlackHandler("s3cret")
+
+	body := "command=%2Fweather&text=Jakarta&team_id=T1&channel_id=C1"
+	ts := time.Now().Unix()
+	req := httptest.NewRequest(http.MethodPost, "/slack/command", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Slack-Signature", signSlack("s3cret", ts, body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var resp map[string]string
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["response_type"] != "in_channel" || !strings.HasPrefix(resp["text"], "*Jakarta*") {
+		t.Errorf("Unexpected Slack response %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/slack/command", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a signature, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// telegramUpdate is the subset of a Telegram Update the bot reads.
+type telegramUpdate struct {
+	Message *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// TelegramHandler answers Telegram webhook updates. The reply is returned in the webhook response
+// as a sendMessage call, so the bot needs no outbound access to the Bot API. Updates must carry
+// the X-Telegram-Bot-Api-Secret-Token configured with setWebhook.
+func (b *Bot) TelegramHandler(secretToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if secretToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secretToken)) != 1 {
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+		var update telegramUpdate
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&update); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// Only commands get a reply; other messages and update types are acknowledged silently
+		if update.Message == nil || len(update.Message.Text) == 0 || update.Message.Text[0] != '/' {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		chatID := update.Message.Chat.ID
+		reply := b.Reply(r.Context(), "telegram:"+strconv.FormatInt(chatID, 10), update.Message.Text)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":     "sendMessage",
+			"chat_id":    chatID,
+			"text":       reply,
+			"parse_mode": "Markdown",
+		})
+	})
+}
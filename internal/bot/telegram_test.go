@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramHandler(t *testing.T) {
+	svc := &mockWeatherService{}
+	h := New(svc).TelegramHandler("tg-secret")
+
+	send := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("tg-secret", `{"update_id":1,"message":{"chat":{"id":42},"text":"/weather@WeatherBot Jakarta"}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var resp map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode reply: %v", err)
+	}
+	if resp["method"] != "sendMessage" || resp["chat_id"] != float64(42) || !strings.HasPrefix(resp["text"].(string), "*Jakarta*") {
+		t.Errorf("Unexpected reply %v", resp)
+	}
+
+	// Non-command messages are acknowledged without a reply
+	svc.location = ""
+	rr = send("tg-secret", `{"update_id":2,"message":{"chat":{"id":42},"text":"hello"}}`)
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 || svc.location != "" {
+		t.Errorf("Expected a silent acknowledgement, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if rr := send("wrong", `{}`); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong secret token, got %d", rr.Code)
+	}
+	if rr := send("tg-secret", `{not json`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed update, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,53 @@
+// Package bufpool pools byte buffers in size classes, so that response bodies of very different
+// sizes each reuse a buffer of about their own size. A single pool either drops every large buffer,
+// and large bodies allocate on every request, or keeps them, and a 300-byte response holds on to a
+// buffer sized for a 2 MB batch.
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// classes are the buffer capacities pooled, each eight times the last. Buffers that outgrow the
+// largest class are dropped.
+var classes = [...]int{512, 4 << 10, 32 << 10, 256 << 10, 2 << 20}
+
+var pools [len(classes)]sync.Pool
+
+// Get returns an empty buffer that holds at least sizeHint bytes without growing, when sizeHint
+// is within the largest class. Pass 0 when the size is not known; the buffer grows as it must.
+func Get(sizeHint int) *bytes.Buffer {
+	i := classFor(sizeHint)
+	if i == len(classes) {
+		return bytes.NewBuffer(make([]byte, 0, sizeHint))
+	}
+	if b, ok := pools[i].Get().(*bytes.Buffer); ok {
+		return b
+	}
+	return bytes.NewBuffer(make([]byte, 0, classes[i]))
+}
+
+// Put returns b to the pool of the largest class it holds. b must not be used afterwards.
+func Put(b *bytes.Buffer) {
+	c := b.Cap()
+	if c < classes[0] || c > classes[len(classes)-1] {
+		return
+	}
+	i := classFor(c)
+	if classes[i] > c {
+		i--
+	}
+	b.Reset()
+	pools[i].Put(b)
+}
+
+// classFor returns the index of the smallest class of at least size bytes, or len(classes).
+func classFor(size int) int {
+	for i, c := range classes {
+		if size <= c {
+			return i
+		}
+	}
+	return len(classes)
+}
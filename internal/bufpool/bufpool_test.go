@@ -0,0 +1,68 @@
+package bufpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGet_SizesByClass(t *testing.T) {
+	tests := []struct {
+		hint    int
+		wantCap int
+	}{
+		{0, 512},
+		{512, 512},
+		{513, 4 << 10},
+		{100 << 10, 256 << 10},
+		{2 << 20, 2 << 20},
+		{3 << 20, 3 << 20},
+	}
+	for _, tt := range tests {
+		b := Get(tt.hint)
+		if b.Len() != 0 || b.Cap() < tt.wantCap {
+			t.Errorf("Get(%d): expected an empty buffer of at least %d bytes, got len %d cap %d", tt.hint, tt.wantCap, b.Len(), b.Cap())
+		}
+	}
+}
+
+func TestPut_ReusesByClass(t *testing.T) {
+	// Pools drop their contents at random under the race detector, so only the outcome of a
+	// successful reuse is checked.
+	b := Get(40 << 10)
+	b.WriteString("leftover")
+	Put(b)
+	got := Get(40 << 10)
+	if got.Len() != 0 {
+		t.Errorf("Expected a reset buffer, got %q", got.Bytes())
+	}
+	if got.Cap() < 40<<10 {
+		t.Errorf("Expected a buffer of at least 40KB, got %d", got.Cap())
+	}
+
+	// A buffer that grew past its class is pooled in the class it now fills
+	grown := Get(0)
+	grown.Write(make([]byte, 10<<10))
+	Put(grown)
+	if small := Get(0); small.Cap() > 32<<10 {
+		t.Errorf("Expected a small buffer for a small hint, got %d bytes", small.Cap())
+	}
+}
+
+func TestPut_DropsOutsideClasses(t *testing.T) {
+	Put(bytes.NewBuffer(make([]byte, 0, 16)))
+	Put(bytes.NewBuffer(make([]byte, 0, 4<<20)))
+	if b := Get(3 << 20); b.Cap() != 3<<20 {
+		t.Errorf("Expected buffers over the largest class not to be pooled, got %d bytes", b.Cap())
+	}
+}
+
+func TestGetPut_Allocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		b := Get(20 << 10)
+		b.Write(make([]byte, 20<<10))
+		Put(b)
+	})
+	if allocs > 0 {
+		t.Errorf("Expected pooled buffers not to allocate, got %v allocs", allocs)
+	}
+}
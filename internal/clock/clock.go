@@ -0,0 +1,143 @@
+// Package clock abstracts the passage of time, so that TTLs, rate limits and schedules can be
+// tested by advancing a Fake clock instead of sleeping.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and makes tickers.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// NewTicker returns a ticker that ticks every d, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                   { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration  { return time.Since(t) }
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{t: time.NewTicker(d)} }
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Fake is a Clock that only moves when told to. Its tickers tick as Advance passes their
+// deadlines. Like a time.Ticker, a ticker whose previous tick has not been received drops ticks.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Advance moves the clock forward by d and ticks every ticker whose deadline it passes, in
+// deadline order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for {
+		next := f.nextTicker(end)
+		if next == nil {
+			break
+		}
+		f.now = next.next
+		next.tick(f.now)
+	}
+	f.now = end
+}
+
+// nextTicker returns the ticker due first at or before end, or nil.
+func (f *Fake) nextTicker(end time.Time) *fakeTicker {
+	due := make([]*fakeTicker, 0, len(f.tickers))
+	for _, t := range f.tickers {
+		if !t.next.After(end) {
+			due = append(due, t)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].next.Before(due[j].next) })
+	return due[0]
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Tickers returns the number of running tickers, so a test can wait for a goroutine to start one
+// before advancing the clock.
+func (f *Fake) Tickers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tickers)
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// tick sends now without blocking and schedules the next tick. The clock's lock is held.
+func (t *fakeTicker) tick(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+	t.next = t.next.Add(t.period)
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.tickers {
+		if other == t {
+			f.tickers = append(f.tickers[:i], f.tickers[i+1:]...)
+			return
+		}
+	}
+}
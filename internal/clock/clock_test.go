@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	c.Advance(90 * time.Second)
+	if got := c.Now(); !got.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Expected the clock to advance, got %v", got)
+	}
+	if got := c.Since(start); got != 90*time.Second {
+		t.Errorf("Expected Since 90s, got %v", got)
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	ticker := c.NewTicker(time.Minute)
+	if c.Tickers() != 1 {
+		t.Fatalf("Expected one ticker, got %d", c.Tickers())
+	}
+
+	c.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("Expected no tick before the interval")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case got := <-ticker.C():
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Errorf("Expected the tick at the deadline, got %v", got)
+		}
+	default:
+		t.Fatal("Expected a tick at the interval")
+	}
+
+	// Ticks nobody received are dropped, as with time.Ticker
+	c.Advance(3 * time.Minute)
+	if got := <-ticker.C(); !got.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Expected the first missed tick, got %v", got)
+	}
+	select {
+	case <-ticker.C():
+		t.Error("Expected later ticks to be dropped")
+	default:
+	}
+
+	ticker.Stop()
+	c.Advance(time.Hour)
+	if c.Tickers() != 0 {
+		t.Errorf("Expected the ticker to be removed, got %d", c.Tickers())
+	}
+	select {
+	case <-ticker.C():
+		t.Error("Expected no ticks after Stop")
+	default:
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	if now := Real.Now(); now.Before(before) {
+		t.Errorf("Expected the wall clock, got %v before %v", now, before)
+	}
+	ticker := Real.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	<-ticker.C()
+}
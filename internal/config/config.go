@@ -2,47 +2,73 @@ package config
 
 import (
 	"flag"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-var once sync.Once
+// configMu serializes loading the config; configLoaded lets getters skip it once it is loaded.
+var (
+	configMu     sync.Mutex
+	configLoaded atomic.Bool
+)
 var logger *zap.SugaredLogger
 var loggerOnce sync.Once
 
+var (
+	moduleLoggers   = make(map[string]*zap.SugaredLogger)
+	muModuleLoggers sync.Mutex
+)
+
 // isTestRun returns true if the current process is a Go test binary.
 func isTestRun() bool {
 	return flag.Lookup("test.v") != nil || filepath.Ext(os.Args[0]) == ".test"
 }
 
 func initConfig() {
-	once.Do(func() {
-		root := getProjectRoot()
-		GetLogger().Infow("Loading config from", "path", root)
+	if configLoaded.Load() {
+		return
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	if !configLoaded.Load() {
+		loadConfig()
+		configLoaded.Store(true)
+	}
+}
 
-		viper.SetConfigType("yaml")
-		viper.SetConfigName("config")
-		viper.AddConfigPath(root)
-		if err := viper.ReadInConfig(); err != nil {
-			GetLogger().Errorw("Error reading config file: %v", err)
-		}
+// loadConfig reads config.yaml, and config_test.yaml over it in tests. configMu is held.
+func loadConfig() {
+	root := getProjectRoot()
+	GetLogger().Infow("Loading config from", "path", root)
 
-		if isTestRun() {
-			viper.SetConfigName("config_test")
-			viper.AddConfigPath(root)
-		}
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("config")
+	viper.AddConfigPath(root)
+	if err := viper.ReadInConfig(); err != nil {
+		GetLogger().Errorw("Error reading config file: %v", err)
+	}
 
-		err := viper.MergeInConfig()
-		if err != nil {
-			GetLogger().Errorw("Error reading config file", "error", err)
-		}
-	})
+	if isTestRun() {
+		viper.SetConfigName("config_test")
+		viper.AddConfigPath(root)
+	}
+
+	err := viper.MergeInConfig()
+	if err != nil {
+		GetLogger().Errorw("Error reading config file", "error", err)
+	}
 }
 
 func getProjectRoot() string {
@@ -69,6 +95,179 @@ func GetOpenWeatherApiUrl() string {
 	return viper.GetString("openweathermap.api_url")
 }
 
+// GetOpenWeatherForecastURL returns the OpenWeatherMap 5 day / 3 hour forecast endpoint.
+func GetOpenWeatherForecastURL() string {
+	initConfig()
+	if u := viper.GetString("openweathermap.forecast_url"); u != "" {
+		return u
+	}
+	return "https://api.openweathermap.org/data/2.5/forecast"
+}
+
+// GetOpenWeatherHourlyForecastURL returns the OpenWeatherMap hourly forecast endpoint.
+func GetOpenWeatherHourlyForecastURL() string {
+	initConfig()
+	if u := viper.GetString("openweathermap.hourly_forecast_url"); u != "" {
+		return u
+	}
+	return "https://pro.openweathermap.org/data/2.5/forecast/hourly"
+}
+
+// GetOpenWeatherOneCallURL returns the OpenWeatherMap One Call endpoint, which serves alerts.
+func GetOpenWeatherOneCallURL() string {
+	initConfig()
+	if u := viper.GetString("openweathermap.onecall_url"); u != "" {
+		return u
+	}
+	return "https://api.openweathermap.org/data/3.0/onecall"
+}
+
+// GetOpenWeatherGeocodingURL returns the OpenWeatherMap direct geocoding endpoint.
+func GetOpenWeatherGeocodingURL() string {
+	initConfig()
+	if u := viper.GetString("openweathermap.geocoding_url"); u != "" {
+		return u
+	}
+	return "https://api.openweathermap.org/geo/1.0/direct"
+}
+
+// GetOpenWeatherIconURL returns the URL of condition icons, with {icon} standing for an
+// OpenWeatherMap icon code such as "10d".
+func GetOpenWeatherIconURL() string {
+	initConfig()
+	if u := viper.GetString("openweathermap.icon_url"); u != "" {
+		return u
+	}
+	return "https://openweathermap.org/img/wn/{icon}@2x.png"
+}
+
+// Weather providers a location can be routed to.
+const (
+	ProviderOpenWeatherMap = "openweathermap"
+	ProviderMetNo          = "metno"
+)
+
+// ProviderRoutingConfig holds which provider serves the current weather of each country.
+type ProviderRoutingConfig struct {
+	// Routes maps upper-case ISO 3166-1 alpha-2 country codes to providers. Other countries are
+	// served by OpenWeatherMap.
+	Routes map[string]string
+	// MetNoURL is the Met.no (MET Norway) Locationforecast compact endpoint.
+	MetNoURL string
+	// MetNoUserAgent identifies the service to Met.no, whose terms require a contact.
+	MetNoUserAgent string
+}
+
+// GetProviderRoutingConfig returns providers.routing and the settings of the providers it may
+// route to. Routes to unknown providers are logged and left out.
+func GetProviderRoutingConfig() ProviderRoutingConfig {
+	initConfig()
+	cfg := ProviderRoutingConfig{
+		Routes:         make(map[string]string),
+		MetNoURL:       viper.GetString("providers.metno.url"),
+		MetNoUserAgent: viper.GetString("providers.metno.user_agent"),
+	}
+	for country, provider := range viper.GetStringMapString("providers.routing") {
+		provider = strings.ToLower(provider)
+		if provider != ProviderOpenWeatherMap && provider != ProviderMetNo {
+			GetLogger().Errorw("Invalid provider route, ignoring it", "country", country, "provider", provider)
+			continue
+		}
+		cfg.Routes[strings.ToUpper(country)] = provider
+	}
+	if cfg.MetNoURL == "" {
+		cfg.MetNoURL = "https://api.met.no/weatherapi/locationforecast/2.0/compact"
+	}
+	if cfg.MetNoUserAgent == "" {
+		cfg.MetNoUserAgent = "weather-api-redis/1.0 github.com/fakhrymubarak/weather-api-redis"
+	}
+	return cfg
+}
+
+// ProviderResolveConfig controls how the provider's hostname is resolved, for environments where
+// the default DNS fails or is slow.
+type ProviderResolveConfig struct {
+	// DNSServer is a resolver ("host:port") used instead of the system one.
+	DNSServer string
+	// Static maps lower-case hostnames to IP addresses used when DNS fails.
+	Static map[string][]string
+	// Pin uses the Static addresses without asking DNS first.
+	Pin bool
+}
+
+// Configured reports whether resolution differs from the system default.
+func (c ProviderResolveConfig) Configured() bool {
+	return c.DNSServer != "" || len(c.Static) > 0
+}
+
+// GetProviderResolveConfig returns the provider host resolution settings (openweathermap.resolve).
+func GetProviderResolveConfig() ProviderResolveConfig {
+	initConfig()
+	cfg := ProviderResolveConfig{
+		DNSServer: viper.GetString("openweathermap.resolve.dns_server"),
+		Pin:       viper.GetBool("openweathermap.resolve.pin"),
+	}
+	for host, ips := range viper.GetStringMapStringSlice("openweathermap.resolve.static") {
+		if len(ips) == 0 {
+			continue
+		}
+		if cfg.Static == nil {
+			cfg.Static = map[string][]string{}
+		}
+		cfg.Static[strings.ToLower(host)] = ips
+	}
+	return cfg
+}
+
+// CoolDownConfig bounds how long provider calls pause after the provider answers 429.
+type CoolDownConfig struct {
+	// Default is the first pause when the response has no Retry-After; it doubles with every
+	// further 429 in a row.
+	Default time.Duration
+	// Max caps every pause, including one asked for by Retry-After.
+	Max time.Duration
+}
+
+// GetCoolDownConfig returns the provider cool-down settings (openweathermap.cool_down). Default
+// defaults to 30s and Max to 10m.
+func GetCoolDownConfig() CoolDownConfig {
+	initConfig()
+	cfg := CoolDownConfig{
+		Default: viper.GetDuration("openweathermap.cool_down.default"),
+		Max:     viper.GetDuration("openweathermap.cool_down.max"),
+	}
+	if cfg.Default <= 0 {
+		cfg.Default = 30 * time.Second
+	}
+	if cfg.Max < cfg.Default {
+		cfg.Max = max(10*time.Minute, cfg.Default)
+	}
+	return cfg
+}
+
+// UVIndexConfig holds settings for adding the UV index to current weather.
+type UVIndexConfig struct {
+	// Enabled fetches the UV index with every current weather call, at the cost of a second
+	// provider call.
+	Enabled bool
+	// URL is the One Call API, or the older UV index API, which answer the same query.
+	URL string
+}
+
+// GetUVIndexConfig returns the UV index settings (openweathermap.uv_index). URL defaults to the
+// One Call 3.0 API.
+func GetUVIndexConfig() UVIndexConfig {
+	initConfig()
+	cfg := UVIndexConfig{
+		Enabled: viper.GetBool("openweathermap.uv_index.enabled"),
+		URL:     viper.GetString("openweathermap.uv_index.url"),
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://api.openweathermap.org/data/3.0/onecall"
+	}
+	return cfg
+}
+
 func GetOpenWeatherMapAPIKey() string {
 	_ = godotenv.Load()
 	return os.Getenv("OPENWEATHERMAP_API_KEY")
@@ -83,6 +282,117 @@ func GetRedisAddr() string {
 	return viper.GetString("redis.addr")
 }
 
+// EnableEmbeddedRedis sets redis.embedded, for the --embedded-redis flag. It must be called before
+// the Redis client is first used.
+func EnableEmbeddedRedis() {
+	initConfig()
+	viper.Set("redis.embedded", true)
+}
+
+// RedisConfig describes how to reach Redis.
+type RedisConfig struct {
+	// Mode is "standalone" (default), "sentinel" or "cluster".
+	Mode string
+	// Addr is the standalone server (REDIS_ADDR or redis.addr).
+	Addr string
+	// Addrs are the sentinels in sentinel mode and the seed nodes in cluster mode.
+	Addrs []string
+	// MasterName is the sentinel master name.
+	MasterName string
+	// Replicas are standalone read replicas that serve cache reads.
+	Replicas []string
+	// ReadFromReplicas routes cache reads to replicas in sentinel and cluster modes.
+	ReadFromReplicas bool
+	// HealthInterval is how often the connection pools are sampled and Redis is pinged.
+	HealthInterval time.Duration
+	// Embedded runs an in-memory Redis inside the process instead of connecting to one, for demos,
+	// smoke tests and local development. It implies standalone mode without replicas.
+	Embedded bool
+}
+
+// GetRedisConfig returns the Redis connection configuration. Unknown modes fall back to standalone
+// and HealthInterval defaults to 15s. With redis.embedded, the addresses are ignored.
+func GetRedisConfig() RedisConfig {
+	initConfig()
+	cfg := RedisConfig{Addr: GetRedisAddr()}
+	cfg.Mode = viper.GetString("redis.mode")
+	cfg.Addrs = viper.GetStringSlice("redis.addrs")
+	cfg.MasterName = viper.GetString("redis.master_name")
+	cfg.Replicas = viper.GetStringSlice("redis.replicas")
+	cfg.ReadFromReplicas = viper.GetBool("redis.read_from_replicas")
+	cfg.HealthInterval = viper.GetDuration("redis.health_interval")
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 15 * time.Second
+	}
+	if viper.GetBool("redis.embedded") {
+		return RedisConfig{Mode: "standalone", Embedded: true, HealthInterval: cfg.HealthInterval}
+	}
+	switch cfg.Mode {
+	case "sentinel", "cluster":
+	default:
+		if cfg.Mode != "" && cfg.Mode != "standalone" {
+			GetLogger().Errorw("Unknown redis.mode, using standalone", "mode", cfg.Mode)
+		}
+		cfg.Mode = "standalone"
+	}
+	return cfg
+}
+
+// StartupConfig holds the dependency checks run before the server starts listening.
+type StartupConfig struct {
+	// WaitForRedis retries the Redis check until Redis answers or Retries is exhausted, so the
+	// server does not start while every request would fail.
+	WaitForRedis bool
+	// Retries is how many times a failed check is retried while waiting.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles after each one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// ExitOnFailure stops the process when Redis is still unreachable after the retries, instead
+	// of starting with /readyz reporting it.
+	ExitOnFailure bool
+	// Warmup opens Redis and provider connections before the server starts listening, so the
+	// first requests after a deploy do not pay for connecting.
+	Warmup bool
+	// WarmupConnections is how many connections are opened to each Redis server.
+	WarmupConnections int
+	// WarmupTimeout bounds the warmup; the server starts anyway when it runs out.
+	WarmupTimeout time.Duration
+}
+
+// GetStartupConfig returns the startup dependency check settings (startup). Retries defaults to
+// 10, Backoff to 500ms and MaxBackoff to 10s. Warmup is on unless set to false, with
+// WarmupConnections defaulting to 4 and WarmupTimeout to 5s.
+func GetStartupConfig() StartupConfig {
+	initConfig()
+	cfg := StartupConfig{
+		WaitForRedis:      viper.GetBool("startup.wait_for_redis"),
+		Retries:           viper.GetInt("startup.retries"),
+		Backoff:           viper.GetDuration("startup.backoff"),
+		MaxBackoff:        viper.GetDuration("startup.max_backoff"),
+		ExitOnFailure:     viper.GetBool("startup.exit_on_failure"),
+		Warmup:            !viper.IsSet("startup.warmup") || viper.GetBool("startup.warmup"),
+		WarmupConnections: viper.GetInt("startup.warmup_connections"),
+		WarmupTimeout:     viper.GetDuration("startup.warmup_timeout"),
+	}
+	if cfg.WarmupConnections <= 0 {
+		cfg.WarmupConnections = 4
+	}
+	if cfg.WarmupTimeout <= 0 {
+		cfg.WarmupTimeout = 5 * time.Second
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = 10
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 500 * time.Millisecond
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = max(10*time.Second, cfg.Backoff)
+	}
+	return cfg
+}
+
 func GetServerPort() string {
 	initConfig()
 	serverPort := viper.GetString("server.port")
@@ -94,15 +404,52 @@ func GetCacheExpiration() string {
 	return viper.GetString("cache.expiration")
 }
 
-func GetServerTimeout(key string) string {
+// ServerTimeouts holds the HTTP server timeouts (server.timeouts).
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+	// Shutdown is how long in-flight requests get to finish on SIGTERM or SIGINT.
+	Shutdown time.Duration
+}
+
+// GetServerTimeouts returns the HTTP server timeouts. ReadHeader and Read default to 15s, Write to
+// 10s, Idle and Shutdown to 30s.
+func GetServerTimeouts() ServerTimeouts {
 	initConfig()
-	return viper.GetString("server." + key)
+	return ServerTimeouts{
+		ReadHeader: serverTimeout("read_header", 15*time.Second),
+		Read:       serverTimeout("read", 15*time.Second),
+		Write:      serverTimeout("write", 10*time.Second),
+		Idle:       serverTimeout("idle", 30*time.Second),
+		Shutdown:   serverTimeout("shutdown", 30*time.Second),
+	}
+}
+
+// serverTimeout returns server.timeouts.<name>, or def when it is not positive. Configs not yet
+// migrated by `weatherctl config migrate` set server.<name>_timeout instead, which is read in its
+// place.
+func serverTimeout(name string, def time.Duration) time.Duration {
+	for _, key := range []string{"server.timeouts." + name, "server." + name + "_timeout"} {
+		if d := viper.GetDuration(key); d > 0 {
+			return d
+		}
+	}
+	return def
 }
 
-// ReloadConfigForTest resets the config singleton and reloads Viper config. Use only in tests.
+// ReloadConfigForTest reloads the config files and drops the module loggers, so their levels are
+// read again. Viper is not safe for concurrent writes, so it must not run while other goroutines
+// read the config. Use only in tests.
 func ReloadConfigForTest() {
-	once = sync.Once{}
-	initConfig()
+	configMu.Lock()
+	loadConfig()
+	configLoaded.Store(true)
+	configMu.Unlock()
+	muModuleLoggers.Lock()
+	moduleLoggers = make(map[string]*zap.SugaredLogger)
+	muModuleLoggers.Unlock()
 }
 
 func GetLogger() *zap.SugaredLogger {
@@ -116,6 +463,41 @@ func GetLogger() *zap.SugaredLogger {
 	return logger
 }
 
+// GetModuleLogger returns a named child logger for a package (e.g. "repository", "middleware").
+// Its level comes from logging.levels.<module>, falling back to logging.level and then debug, so
+// verbose logging can be enabled for one module without flooding the others.
+func GetModuleLogger(module string) *zap.SugaredLogger {
+	muModuleLoggers.Lock()
+	defer muModuleLoggers.Unlock()
+	if l, ok := moduleLoggers[module]; ok {
+		return l
+	}
+	l := GetLogger().Desugar().Named(module).WithOptions(zap.IncreaseLevel(GetModuleLogLevel(module))).Sugar()
+	moduleLoggers[module] = l
+	return l
+}
+
+// GetModuleDebugLogger returns the named child logger for module at debug level, ignoring
+// logging.levels, for requests whose debug tracing was forced.
+func GetModuleDebugLogger(module string) *zap.SugaredLogger {
+	return GetLogger().Named(module)
+}
+
+// GetModuleLogLevel returns the configured log level for module. Invalid or missing values fall back
+// to logging.level, and then to debug.
+func GetModuleLogLevel(module string) zapcore.Level {
+	initConfig()
+	for _, key := range []string{"logging.levels." + module, "logging.level"} {
+		if raw := viper.GetString(key); raw != "" {
+			if lvl, err := zapcore.ParseLevel(raw); err == nil {
+				return lvl
+			}
+			GetLogger().Warnw("Invalid log level", "key", key, "value", raw)
+		}
+	}
+	return zapcore.DebugLevel
+}
+
 // GetRateLimiterCleanupTimeout returns the rate limiter cleanup timeout as a time.Duration.
 // Defaults to 3m if not set or invalid.
 func GetRateLimiterCleanupTimeout() time.Duration {
@@ -131,6 +513,25 @@ func GetRateLimiterCleanupTimeout() time.Duration {
 	return dur
 }
 
+// GetRateLimitDocsURL returns the documentation link sent with 429 responses.
+func GetRateLimitDocsURL() string {
+	initConfig()
+	if url := viper.GetString("rate_limiter.docs_url"); url != "" {
+		return url
+	}
+	return "https://github.com/fakhrymubarak/weather-api-redis#rate-limiting"
+}
+
+// GetRateLimitKey returns the KeyExtractor names combined into the rate limiting key: "ip",
+// "api_key" or "header:<name>". Defaults to ["ip"].
+func GetRateLimitKey() []string {
+	initConfig()
+	if key := viper.GetStringSlice("rate_limiter.key"); len(key) > 0 {
+		return key
+	}
+	return []string{"ip"}
+}
+
 // GetGlobalRateLimiterConfig returns the rate and burst for the global rate limiter from config.
 func GetGlobalRateLimiterConfig() (rate float64, burst int) {
 	initConfig()
@@ -158,3 +559,1324 @@ func GetParamRateLimiterConfig() (rate float64, burst int) {
 	}
 	return
 }
+
+// GetRefreshRateLimiterConfig returns the rate and burst for the force-refresh rate limiter from config.
+func GetRefreshRateLimiterConfig() (rate float64, burst int) {
+	initConfig()
+	rate = viper.GetFloat64("rate_limiter.refresh.rate")
+	if rate == 0 {
+		rate = 1
+	}
+	burst = viper.GetInt("rate_limiter.refresh.burst")
+	if burst == 0 {
+		burst = 1
+	}
+	return
+}
+
+// CachedRateLimitConfig holds the limit charged instead of the global and per-location ones to
+// requests the cache will answer (rate_limiter.cached).
+type CachedRateLimitConfig struct {
+	Enabled bool
+	// Rate is in requests per minute; 0 exempts cached requests from rate limiting.
+	Rate  float64
+	Burst int
+}
+
+// GetCachedRateLimitConfig returns the rate limit for requests served from cache. The rate
+// defaults to 60 per minute and the burst to 60.
+func GetCachedRateLimitConfig() CachedRateLimitConfig {
+	initConfig()
+	cfg := CachedRateLimitConfig{
+		Enabled: viper.GetBool("rate_limiter.cached.enabled"),
+		Rate:    60,
+		Burst:   viper.GetInt("rate_limiter.cached.burst"),
+	}
+	if viper.IsSet("rate_limiter.cached.rate") {
+		cfg.Rate = max(viper.GetFloat64("rate_limiter.cached.rate"), 0)
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 60
+	}
+	return cfg
+}
+
+// IsOfflineMode reports whether the service must never call the upstream provider and serve
+// (possibly stale) cached data only, e.g. during provider maintenance windows.
+func IsOfflineMode() bool {
+	initConfig()
+	return viper.GetBool("offline_mode")
+}
+
+// IsReadOnly reports whether the service only serves reads, e.g. during Redis maintenance or
+// migrations: write endpoints answer 503 and background writers do not run.
+func IsReadOnly() bool {
+	initConfig()
+	return viper.GetBool("read_only")
+}
+
+// IsGeodataStrict reports whether locations missing from the embedded city dataset are rejected
+// with a 400 instead of being looked up upstream (geodata.strict).
+func IsGeodataStrict() bool {
+	initConfig()
+	return viper.GetBool("geodata.strict")
+}
+
+// GetResponseCase returns the key casing applied to JSON responses: "snake" (default) or "camel".
+func GetResponseCase() string {
+	initConfig()
+	switch c := viper.GetString("server.response_case"); c {
+	case "camel", "snake":
+		return c
+	default:
+		return "snake"
+	}
+}
+
+// GetResponseEncoding returns the name of the default response encoder (server.response_encoding),
+// used when the client's Accept header does not ask for a specific one. Defaults to "json".
+func GetResponseEncoding() string {
+	initConfig()
+	if e := viper.GetString("server.response_encoding"); e != "" {
+		return e
+	}
+	return "json"
+}
+
+// IsResponseEnvelopeEnabled reports whether successful responses are wrapped in the standard
+// {data, message} envelope. Defaults to true; disable it to return the bare object for legacy clients.
+func IsResponseEnvelopeEnabled() bool {
+	initConfig()
+	if !viper.IsSet("server.response_envelope") {
+		return true
+	}
+	return viper.GetBool("server.response_envelope")
+}
+
+// IsJSONPEnabled reports whether /weather answers ?callback=fn with JSONP (server.jsonp), for
+// legacy embedded clients that cannot use CORS. Defaults to false.
+func IsJSONPEnabled() bool {
+	initConfig()
+	return viper.GetBool("server.jsonp")
+}
+
+// GetTemperaturePrecision returns the number of decimals temperatures are rounded to
+// (server.temperature_precision), between 0 and 6. Defaults to 2, the precision OpenWeatherMap reports.
+func GetTemperaturePrecision() int {
+	initConfig()
+	if !viper.IsSet("server.temperature_precision") {
+		return 2
+	}
+	return min(max(viper.GetInt("server.temperature_precision"), 0), 6)
+}
+
+// GetShutdownTimeout returns how long the server waits for in-flight requests on shutdown
+// (server.timeouts.shutdown). Defaults to 30s.
+func GetShutdownTimeout() time.Duration {
+	return GetServerTimeouts().Shutdown
+}
+
+// GetRouteTimeouts returns the time budget of each route (server.route_timeouts), keyed by path.
+// A path ending in "/" also covers everything below it. Entries with an invalid or non-positive
+// duration are ignored. Defaults to 2s for /weather and /v1/weather, 4s for /forecast and 60s for
+// /admin/export.
+func GetRouteTimeouts() map[string]time.Duration {
+	initConfig()
+	if !viper.IsSet("server.route_timeouts") {
+		return map[string]time.Duration{
+			"/weather":      2 * time.Second,
+			"/v1/weather":   2 * time.Second,
+			"/forecast":     4 * time.Second,
+			"/admin/export": time.Minute,
+		}
+	}
+	timeouts := make(map[string]time.Duration)
+	for path, raw := range viper.GetStringMapString("server.route_timeouts") {
+		dur, err := time.ParseDuration(raw)
+		if err != nil || dur <= 0 {
+			GetLogger().Errorw("Invalid route timeout", "path", path, "timeout", raw)
+			continue
+		}
+		timeouts[path] = dur
+	}
+	return timeouts
+}
+
+// CacheEncryptionConfig holds settings for encrypting values stored in Redis.
+type CacheEncryptionConfig struct {
+	Enabled bool
+	// Key is the base64-encoded AES-128, AES-192 or AES-256 key, read from CACHE_ENCRYPTION_KEY.
+	Key string
+	// AllowPlaintext lets values written before encryption was enabled still be read.
+	AllowPlaintext bool
+}
+
+// GetCacheEncryptionConfig returns the cache encryption configuration (cache.encryption).
+func GetCacheEncryptionConfig() CacheEncryptionConfig {
+	initConfig()
+	_ = godotenv.Load()
+	return CacheEncryptionConfig{
+		Enabled:        viper.GetBool("cache.encryption.enabled"),
+		Key:            os.Getenv("CACHE_ENCRYPTION_KEY"),
+		AllowPlaintext: viper.GetBool("cache.encryption.allow_plaintext"),
+	}
+}
+
+// CacheTimeouts bound single Redis cache operations so a slow Redis cannot dominate request
+// latency. Zero disables the bound.
+type CacheTimeouts struct {
+	// Read bounds a cache lookup; a lookup that times out is treated as a miss.
+	Read time.Duration
+	// Write bounds storing an entry; a write that times out is dropped.
+	Write time.Duration
+}
+
+// GetCacheTimeouts returns the cache operation timeouts (cache.timeouts). Read defaults to 50ms and
+// Write to 100ms when unset or negative.
+func GetCacheTimeouts() CacheTimeouts {
+	initConfig()
+	timeouts := CacheTimeouts{Read: 50 * time.Millisecond, Write: 100 * time.Millisecond}
+	if d := viper.GetDuration("cache.timeouts.read"); viper.IsSet("cache.timeouts.read") && d >= 0 {
+		timeouts.Read = d
+	}
+	if d := viper.GetDuration("cache.timeouts.write"); viper.IsSet("cache.timeouts.write") && d >= 0 {
+		timeouts.Write = d
+	}
+	return timeouts
+}
+
+// CacheWriteQueueConfig holds settings for writing cache entries in the background.
+type CacheWriteQueueConfig struct {
+	// Size is how many writes may wait; writes arriving at a full queue are dropped. Zero writes
+	// entries synchronously, before the response is sent.
+	Size int
+	// Workers is how many goroutines drain the queue.
+	Workers int
+	// BatchSize is the most write-behind writes sent to Redis in one pipeline.
+	BatchSize int
+	// FlushInterval is how long write-behind writes may wait for a batch to fill.
+	FlushInterval time.Duration
+}
+
+// GetCacheWriteQueueConfig returns the cache write queue settings (cache.write_queue). Size
+// defaults to 1024 when unset or negative, Workers to 4, BatchSize to 100 and FlushInterval to 1s.
+func GetCacheWriteQueueConfig() CacheWriteQueueConfig {
+	initConfig()
+	cfg := CacheWriteQueueConfig{
+		Size:          1024,
+		Workers:       viper.GetInt("cache.write_queue.workers"),
+		BatchSize:     viper.GetInt("cache.write_queue.batch_size"),
+		FlushInterval: viper.GetDuration("cache.write_queue.flush_interval"),
+	}
+	if size := viper.GetInt("cache.write_queue.size"); viper.IsSet("cache.write_queue.size") && size >= 0 {
+		cfg.Size = size
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	return cfg
+}
+
+// GetCacheStaleTTL returns how long an expired cache entry is retained past its expiration so it can
+// be revalidated with the provider. Defaults to 1h if not set or invalid.
+func GetCacheStaleTTL() time.Duration {
+	initConfig()
+	dur, err := time.ParseDuration(viper.GetString("cache.stale_ttl"))
+	if err != nil || dur < 0 {
+		return time.Hour
+	}
+	return dur
+}
+
+// AdaptiveTTLConfig holds settings for adjusting a location's cache TTL to how much its weather changes.
+type AdaptiveTTLConfig struct {
+	Enabled bool
+	Min     time.Duration
+	Max     time.Duration
+	// StableDelta is the largest temperature change (°C) between fetches that still counts as stable.
+	StableDelta float64
+	// VolatileDelta is the smallest temperature change (°C) between fetches that counts as volatile.
+	VolatileDelta float64
+}
+
+// GetAdaptiveTTLConfig returns the adaptive cache TTL configuration. Min defaults to 1m, Max to 1h,
+// StableDelta to 0.5 and VolatileDelta to 2.
+func GetAdaptiveTTLConfig() AdaptiveTTLConfig {
+	initConfig()
+	cfg := AdaptiveTTLConfig{
+		Enabled:       viper.GetBool("cache.adaptive_ttl.enabled"),
+		Min:           viper.GetDuration("cache.adaptive_ttl.min"),
+		Max:           viper.GetDuration("cache.adaptive_ttl.max"),
+		StableDelta:   viper.GetFloat64("cache.adaptive_ttl.stable_delta"),
+		VolatileDelta: viper.GetFloat64("cache.adaptive_ttl.volatile_delta"),
+	}
+	if cfg.Min <= 0 {
+		cfg.Min = time.Minute
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = time.Hour
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.StableDelta <= 0 {
+		cfg.StableDelta = 0.5
+	}
+	if cfg.VolatileDelta <= cfg.StableDelta {
+		cfg.VolatileDelta = max(2, cfg.StableDelta*4)
+	}
+	return cfg
+}
+
+// GetWarmCities returns the warm-listed cities (cache.warm_cities): locations the service keeps
+// track of beyond answering requests, e.g. by publishing an Atom feed of their conditions.
+func GetWarmCities() []string {
+	initConfig()
+	return viper.GetStringSlice("cache.warm_cities")
+}
+
+// MemoryConfig holds settings for the memory guard.
+type MemoryConfig struct {
+	// SoftLimit is the heap size in bytes above which caches are trimmed; 0 disables it. It is
+	// also passed to the Go runtime as its soft memory limit.
+	SoftLimit uint64
+	// Interval is how often memory is sampled.
+	Interval time.Duration
+}
+
+// GetMemoryConfig returns the memory guard settings. memory.soft_limit accepts sizes such as
+// "256MB"; Interval defaults to 15s.
+func GetMemoryConfig() MemoryConfig {
+	initConfig()
+	cfg := MemoryConfig{
+		SoftLimit: uint64(viper.GetSizeInBytes("memory.soft_limit")),
+		Interval:  viper.GetDuration("memory.interval"),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	return cfg
+}
+
+// LeaderConfig holds settings for leader election between replicas.
+type LeaderConfig struct {
+	// Enabled elects one replica to run cluster-wide background jobs. When false, every replica
+	// considers itself the leader, which is right for a single instance.
+	Enabled bool
+	// TTL is how long a lease lasts without a heartbeat; it is renewed every TTL/3.
+	TTL time.Duration
+}
+
+// GetLeaderConfig returns the leader election settings. TTL defaults to 15s.
+func GetLeaderConfig() LeaderConfig {
+	initConfig()
+	cfg := LeaderConfig{
+		Enabled: viper.GetBool("leader.enabled"),
+		TTL:     viper.GetDuration("leader.ttl"),
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 15 * time.Second
+	}
+	return cfg
+}
+
+// WarmerConfig holds settings for the cache warmer, which periodically refreshes warm-listed cities.
+type WarmerConfig struct {
+	Enabled bool
+	// Interval is the time between the start of one warm cycle and the next.
+	Interval time.Duration
+	// Delay is the pause between two cities, spreading upstream calls over the cycle.
+	Delay time.Duration
+}
+
+// GetWarmerConfig returns the cache warmer settings under cache.warmer. Interval defaults to 10m
+// and Delay to 1s.
+func GetWarmerConfig() WarmerConfig {
+	initConfig()
+	cfg := WarmerConfig{
+		Enabled:  viper.GetBool("cache.warmer.enabled"),
+		Interval: viper.GetDuration("cache.warmer.interval"),
+		Delay:    viper.GetDuration("cache.warmer.delay"),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	if cfg.Delay < 0 || !viper.IsSet("cache.warmer.delay") {
+		cfg.Delay = time.Second
+	}
+	return cfg
+}
+
+// FeedConfig holds settings for the per-city Atom feeds of warm-listed cities.
+type FeedConfig struct {
+	// MaxItems is how many entries each feed keeps.
+	MaxItems int
+	// TempDelta is the smallest temperature change (°C) that counts as a material change; a new
+	// description always does.
+	TempDelta float64
+}
+
+// GetFeedConfig returns the feed configuration. MaxItems defaults to 20 and TempDelta to 1.
+func GetFeedConfig() FeedConfig {
+	initConfig()
+	cfg := FeedConfig{
+		MaxItems:  viper.GetInt("feed.max_items"),
+		TempDelta: viper.GetFloat64("feed.temp_delta"),
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 20
+	}
+	if cfg.TempDelta <= 0 {
+		cfg.TempDelta = 1
+	}
+	return cfg
+}
+
+// HistoryConfig holds settings for the per-location history of fetched conditions that
+// /weather/changes diffs against.
+type HistoryConfig struct {
+	// MaxItems is how many snapshots each location keeps.
+	MaxItems int
+	// Retention is how long a location's history is kept after its last fetch.
+	Retention time.Duration
+}
+
+// GetHistoryConfig returns the history configuration. MaxItems defaults to 100 and Retention to
+// 24h.
+func GetHistoryConfig() HistoryConfig {
+	initConfig()
+	cfg := HistoryConfig{
+		MaxItems:  viper.GetInt("history.max_items"),
+		Retention: viper.GetDuration("history.retention"),
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 100
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	return cfg
+}
+
+// OverrideConfig holds settings for operator-pinned weather overrides.
+type OverrideConfig struct {
+	// MaxTTL caps how long an override may stay in force.
+	MaxTTL time.Duration
+	// SyncInterval is how often each replica reloads the overrides from Redis.
+	SyncInterval time.Duration
+}
+
+// GetOverrideConfig returns the override configuration. MaxTTL defaults to 24h and SyncInterval
+// to 5s.
+func GetOverrideConfig() OverrideConfig {
+	initConfig()
+	cfg := OverrideConfig{
+		MaxTTL:       viper.GetDuration("overrides.max_ttl"),
+		SyncInterval: viper.GetDuration("overrides.sync_interval"),
+	}
+	if cfg.MaxTTL <= 0 {
+		cfg.MaxTTL = 24 * time.Hour
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = 5 * time.Second
+	}
+	return cfg
+}
+
+// ForecastConfig holds settings for the forecast subsystem.
+type ForecastConfig struct {
+	// CacheTTL is how long a location's forecast is cached.
+	CacheTTL time.Duration
+}
+
+// GetForecastConfig returns the forecast configuration. CacheTTL defaults to 30m.
+func GetForecastConfig() ForecastConfig {
+	initConfig()
+	cfg := ForecastConfig{CacheTTL: viper.GetDuration("forecast.cache_ttl")}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 30 * time.Minute
+	}
+	return cfg
+}
+
+// AlertsConfig holds settings for severe weather alerts.
+type AlertsConfig struct {
+	// CacheTTL is how long a location's alerts are cached. Alerts are issued and lifted at short
+	// notice, so it is kept short.
+	CacheTTL time.Duration
+}
+
+// GetAlertsConfig returns the alerts configuration. CacheTTL defaults to 5m.
+func GetAlertsConfig() AlertsConfig {
+	initConfig()
+	cfg := AlertsConfig{CacheTTL: viper.GetDuration("alerts.cache_ttl")}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 5 * time.Minute
+	}
+	return cfg
+}
+
+// GeocodeConfig holds settings for resolving place names to coordinates.
+type GeocodeConfig struct {
+	// CacheTTL is how long the candidates of a query are cached.
+	CacheTTL time.Duration
+	// Limit is the number of candidates asked for, at most 5 as the provider allows.
+	Limit int
+	// Disambiguate resolves weather locations without a country code that the city dataset does
+	// not know to the geocoder's best match.
+	Disambiguate bool
+}
+
+// GetGeocodeConfig returns the geocoding configuration under geocode. CacheTTL defaults to 168h
+// and Limit to 5.
+func GetGeocodeConfig() GeocodeConfig {
+	initConfig()
+	cfg := GeocodeConfig{
+		CacheTTL:     viper.GetDuration("geocode.cache_ttl"),
+		Limit:        viper.GetInt("geocode.limit"),
+		Disambiguate: viper.GetBool("geocode.disambiguate"),
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 168 * time.Hour
+	}
+	if cfg.Limit <= 0 || cfg.Limit > 5 {
+		cfg.Limit = 5
+	}
+	return cfg
+}
+
+// GeoIPConfig holds settings for locating callers by IP address.
+type GeoIPConfig struct {
+	// Database is the path of a MaxMind DB City file, e.g. GeoLite2-City.mmdb. GeoIP is disabled
+	// when it is empty or cannot be opened.
+	Database string
+}
+
+// GetGeoIPConfig returns the GeoIP configuration under geoip.
+func GetGeoIPConfig() GeoIPConfig {
+	initConfig()
+	return GeoIPConfig{Database: viper.GetString("geoip.database")}
+}
+
+// WidgetTheme holds the colours of an SVG weather widget, as CSS hex codes or colour names.
+type WidgetTheme struct {
+	Background string `mapstructure:"background"`
+	Text       string `mapstructure:"text"`
+	// Accent fills the temperature, which is written in the background colour.
+	Accent string `mapstructure:"accent"`
+}
+
+// widgetColor matches the colour values a theme may use, so they are safe to write into SVG.
+var widgetColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// defaultWidgetThemes are always available; widget.themes may override them.
+var defaultWidgetThemes = map[string]WidgetTheme{
+	"light": {Background: "#ffffff", Text: "#24292f", Accent: "#0969da"},
+	"dark":  {Background: "#0d1117", Text: "#e6edf3", Accent: "#58a6ff"},
+}
+
+// WidgetConfig holds settings for the SVG weather widgets.
+type WidgetConfig struct {
+	// MaxAge is the Cache-Control max-age of widgets, honoured by image proxies and browsers.
+	MaxAge time.Duration
+	// Theme is the theme used without ?theme=.
+	Theme  string
+	Themes map[string]WidgetTheme
+}
+
+// GetWidgetConfig returns the widget configuration. MaxAge defaults to 10m and Theme to light.
+// Themes holds the built-in light and dark themes and those of widget.themes; a theme with a
+// missing or invalid colour is logged and left out.
+func GetWidgetConfig() WidgetConfig {
+	initConfig()
+	cfg := WidgetConfig{
+		MaxAge: viper.GetDuration("widget.max_age"),
+		Theme:  strings.ToLower(viper.GetString("widget.theme")),
+		Themes: maps.Clone(defaultWidgetThemes),
+	}
+	var themes map[string]WidgetTheme
+	if err := viper.UnmarshalKey("widget.themes", &themes); err != nil {
+		GetLogger().Errorw("Invalid widget.themes config", "error", err)
+	}
+	for name, theme := range themes {
+		if !widgetColor.MatchString(theme.Background) || !widgetColor.MatchString(theme.Text) || !widgetColor.MatchString(theme.Accent) {
+			GetLogger().Errorw("Invalid widget theme, ignoring it", "theme", name)
+			continue
+		}
+		cfg.Themes[strings.ToLower(name)] = theme
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 10 * time.Minute
+	}
+	if _, ok := cfg.Themes[cfg.Theme]; !ok {
+		cfg.Theme = "light"
+	}
+	return cfg
+}
+
+// PollConfig holds settings for long-polling weather changes.
+type PollConfig struct {
+	// DefaultTimeout is how long a request is held without ?timeout=.
+	DefaultTimeout time.Duration
+	// MaxTimeout caps ?timeout=.
+	MaxTimeout time.Duration
+	// CheckInterval is how often held requests re-read the cache, to see changes fetched by other
+	// replicas, which are not announced in-process.
+	CheckInterval time.Duration
+}
+
+// GetPollConfig returns the long-polling configuration. MaxTimeout defaults to 60s,
+// DefaultTimeout to 30s (at most MaxTimeout) and CheckInterval to 5s.
+func GetPollConfig() PollConfig {
+	initConfig()
+	cfg := PollConfig{
+		DefaultTimeout: viper.GetDuration("poll.default_timeout"),
+		MaxTimeout:     viper.GetDuration("poll.max_timeout"),
+		CheckInterval:  viper.GetDuration("poll.check_interval"),
+	}
+	if cfg.MaxTimeout <= 0 {
+		cfg.MaxTimeout = time.Minute
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = 30 * time.Second
+	}
+	cfg.DefaultTimeout = min(cfg.DefaultTimeout, cfg.MaxTimeout)
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 5 * time.Second
+	}
+	return cfg
+}
+
+// QualityConfig holds settings for the quality score of weather responses.
+type QualityConfig struct {
+	// Enabled adds the score to the envelope of /weather responses.
+	Enabled bool
+	// MaxAge is the age at which data no longer counts as fresh at all.
+	MaxAge time.Duration
+}
+
+// GetQualityConfig returns the quality score configuration. MaxAge defaults to 1h.
+func GetQualityConfig() QualityConfig {
+	initConfig()
+	cfg := QualityConfig{
+		Enabled: viper.GetBool("quality.enabled"),
+		MaxAge:  viper.GetDuration("quality.max_age"),
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Hour
+	}
+	return cfg
+}
+
+// TravelConfig holds settings for the travel weather endpoint.
+type TravelConfig struct {
+	// SpeedKmh is the average speed used to estimate arrival times at waypoints.
+	SpeedKmh float64
+	// MaxWaypoints caps the number of waypoints of a route.
+	MaxWaypoints int
+}
+
+// GetTravelConfig returns the travel configuration. SpeedKmh defaults to 60 and MaxWaypoints to 10.
+func GetTravelConfig() TravelConfig {
+	initConfig()
+	cfg := TravelConfig{
+		SpeedKmh:     viper.GetFloat64("travel.speed_kmh"),
+		MaxWaypoints: viper.GetInt("travel.max_waypoints"),
+	}
+	if cfg.SpeedKmh <= 0 {
+		cfg.SpeedKmh = 60
+	}
+	if cfg.MaxWaypoints <= 0 {
+		cfg.MaxWaypoints = 10
+	}
+	return cfg
+}
+
+// BatchConfig holds settings for the batch weather endpoint.
+type BatchConfig struct {
+	// MaxLocations caps the number of locations of a batch.
+	MaxLocations int
+	// Concurrency caps the provider calls a batch makes at once for its cache misses.
+	Concurrency int
+}
+
+// GetBatchConfig returns the batch configuration. MaxLocations defaults to 20 and Concurrency to 5.
+func GetBatchConfig() BatchConfig {
+	initConfig()
+	cfg := BatchConfig{
+		MaxLocations: viper.GetInt("batch.max_locations"),
+		Concurrency:  viper.GetInt("batch.concurrency"),
+	}
+	if cfg.MaxLocations <= 0 {
+		cfg.MaxLocations = 20
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 5
+	}
+	return cfg
+}
+
+// SLOConfig holds the service level objectives tracked for API routes.
+type SLOConfig struct {
+	// Routes are the request paths counted towards the SLO.
+	Routes []string
+	// Availability is the share of requests that must not fail with a 5xx.
+	Availability float64
+	// LatencyTarget is the response time a request must beat to count as fast.
+	LatencyTarget time.Duration
+	// Latency is the share of requests that must beat LatencyTarget.
+	Latency float64
+}
+
+// GetSLOConfig returns the SLO configuration. Routes default to /weather and /v1/weather,
+// Availability to 0.999, LatencyTarget to 300ms and Latency to 0.99.
+func GetSLOConfig() SLOConfig {
+	initConfig()
+	cfg := SLOConfig{
+		Routes:        viper.GetStringSlice("slo.routes"),
+		Availability:  viper.GetFloat64("slo.availability"),
+		LatencyTarget: viper.GetDuration("slo.latency_target"),
+		Latency:       viper.GetFloat64("slo.latency"),
+	}
+	if len(cfg.Routes) == 0 {
+		cfg.Routes = []string{"/weather", "/v1/weather"}
+	}
+	if cfg.Availability <= 0 || cfg.Availability >= 1 {
+		cfg.Availability = 0.999
+	}
+	if cfg.LatencyTarget <= 0 {
+		cfg.LatencyTarget = 300 * time.Millisecond
+	}
+	if cfg.Latency <= 0 || cfg.Latency >= 1 {
+		cfg.Latency = 0.99
+	}
+	return cfg
+}
+
+// ProviderPricing is the estimated price of one upstream call and the calls included per day in
+// the provider plan.
+type ProviderPricing struct {
+	PricePerCall float64 `mapstructure:"price_per_call"`
+	DailyQuota   int64   `mapstructure:"daily_quota"`
+}
+
+// CostConfig holds settings for upstream cost accounting.
+type CostConfig struct {
+	Currency string
+	// FlushInterval is how often call counts are aggregated into Redis.
+	FlushInterval time.Duration
+	// QuotaWarning is the share of a daily quota after which a provider is reported as near it.
+	QuotaWarning float64
+	Providers    map[string]ProviderPricing
+}
+
+// GetCostConfig returns the cost accounting configuration. Currency defaults to USD,
+// FlushInterval to 10s and QuotaWarning to 0.8.
+func GetCostConfig() CostConfig {
+	initConfig()
+	cfg := CostConfig{
+		Currency:      viper.GetString("costs.currency"),
+		FlushInterval: viper.GetDuration("costs.flush_interval"),
+		QuotaWarning:  viper.GetFloat64("costs.quota_warning"),
+	}
+	if err := viper.UnmarshalKey("costs.providers", &cfg.Providers); err != nil {
+		GetLogger().Errorw("Invalid costs.providers config", "error", err)
+	}
+	if cfg.Currency == "" {
+		cfg.Currency = "USD"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10 * time.Second
+	}
+	if cfg.QuotaWarning <= 0 || cfg.QuotaWarning > 1 {
+		cfg.QuotaWarning = 0.8
+	}
+	return cfg
+}
+
+// DegradationConfig holds the steps taken as the upstream daily quota runs out. Each threshold is
+// a share of the provider's costs.providers.<name>.daily_quota.
+type DegradationConfig struct {
+	Enabled bool
+	// ExtendTTLAt multiplies fresh cache TTLs by TTLMultiplier.
+	ExtendTTLAt   float64
+	TTLMultiplier float64
+	// DisableRefreshAt ignores refresh=true.
+	DisableRefreshAt float64
+	// CacheOnlyAt serves every request from the cache only.
+	CacheOnlyAt float64
+}
+
+// GetDegradationConfig returns the degradation ladder. Thresholds default to 0.75, 0.9 and 1 and
+// TTLMultiplier to 4.
+func GetDegradationConfig() DegradationConfig {
+	initConfig()
+	cfg := DegradationConfig{
+		Enabled:          viper.GetBool("degradation.enabled"),
+		ExtendTTLAt:      viper.GetFloat64("degradation.extend_ttl_at"),
+		TTLMultiplier:    viper.GetFloat64("degradation.ttl_multiplier"),
+		DisableRefreshAt: viper.GetFloat64("degradation.disable_refresh_at"),
+		CacheOnlyAt:      viper.GetFloat64("degradation.cache_only_at"),
+	}
+	if cfg.ExtendTTLAt <= 0 {
+		cfg.ExtendTTLAt = 0.75
+	}
+	if cfg.TTLMultiplier < 1 {
+		cfg.TTLMultiplier = 4
+	}
+	if cfg.DisableRefreshAt <= 0 {
+		cfg.DisableRefreshAt = 0.9
+	}
+	if cfg.CacheOnlyAt <= 0 {
+		cfg.CacheOnlyAt = 1
+	}
+	return cfg
+}
+
+// AdaptiveRateLimitConfig holds settings for scaling the global rate limit with upstream health.
+type AdaptiveRateLimitConfig struct {
+	Enabled bool
+	// Interval is how often upstream health is evaluated.
+	Interval time.Duration
+	// MinRequests is the fewest upstream calls in an interval needed to judge its health.
+	MinRequests int
+	// ErrorRate and Latency are the upstream error share and mean latency that count as unhealthy.
+	ErrorRate float64
+	Latency   time.Duration
+	// TightenFactor multiplies the limit after an unhealthy interval, down to MinFactor.
+	TightenFactor float64
+	MinFactor     float64
+	// RelaxStep is added back to the factor after each healthy interval, up to 1.
+	RelaxStep float64
+}
+
+// GetAdaptiveRateLimitConfig returns the adaptive rate limit settings under rate_limiter.adaptive.
+// Defaults: every 15s with at least 10 calls, unhealthy at a 20% error rate or 2s mean latency,
+// halving down to 20% of the configured limit and recovering 10 points per healthy interval.
+func GetAdaptiveRateLimitConfig() AdaptiveRateLimitConfig {
+	initConfig()
+	cfg := AdaptiveRateLimitConfig{
+		Enabled:       viper.GetBool("rate_limiter.adaptive.enabled"),
+		Interval:      viper.GetDuration("rate_limiter.adaptive.interval"),
+		MinRequests:   viper.GetInt("rate_limiter.adaptive.min_requests"),
+		ErrorRate:     viper.GetFloat64("rate_limiter.adaptive.error_rate"),
+		Latency:       viper.GetDuration("rate_limiter.adaptive.latency"),
+		TightenFactor: viper.GetFloat64("rate_limiter.adaptive.tighten_factor"),
+		MinFactor:     viper.GetFloat64("rate_limiter.adaptive.min_factor"),
+		RelaxStep:     viper.GetFloat64("rate_limiter.adaptive.relax_step"),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 10
+	}
+	if cfg.ErrorRate <= 0 {
+		cfg.ErrorRate = 0.2
+	}
+	if cfg.Latency <= 0 {
+		cfg.Latency = 2 * time.Second
+	}
+	if cfg.TightenFactor <= 0 || cfg.TightenFactor >= 1 {
+		cfg.TightenFactor = 0.5
+	}
+	if cfg.MinFactor <= 0 || cfg.MinFactor > 1 {
+		cfg.MinFactor = 0.2
+	}
+	if cfg.RelaxStep <= 0 {
+		cfg.RelaxStep = 0.1
+	}
+	return cfg
+}
+
+// PriorityConfig holds settings for request priority classes ("low", "normal" or "high").
+type PriorityConfig struct {
+	// Default is the class of requests without an API key or whose tier is not in Tiers.
+	Default string
+	// Tiers maps API key tiers (lower-case) to classes.
+	Tiers map[string]string
+	// MaxInFlight caps concurrent requests; 0 disables load shedding. High-priority requests may use
+	// all of it, normal ones NormalShare and low ones LowShare.
+	MaxInFlight int
+	NormalShare float64
+	LowShare    float64
+	// LowBudgetShare makes low-priority requests step down the degradation ladder once this share
+	// of each threshold is used.
+	LowBudgetShare float64
+}
+
+// GetPriorityConfig returns the priority settings. Shares default to 0.9 (normal), 0.6 (low) and
+// 0.75 (low budget share).
+func GetPriorityConfig() PriorityConfig {
+	initConfig()
+	cfg := PriorityConfig{
+		Default:        viper.GetString("priority.default"),
+		Tiers:          map[string]string{},
+		MaxInFlight:    viper.GetInt("priority.max_in_flight"),
+		NormalShare:    viper.GetFloat64("priority.normal_share"),
+		LowShare:       viper.GetFloat64("priority.low_share"),
+		LowBudgetShare: viper.GetFloat64("priority.low_budget_share"),
+	}
+	for tier, class := range viper.GetStringMapString("priority.tiers") {
+		cfg.Tiers[strings.ToLower(tier)] = class
+	}
+	if cfg.Default == "" {
+		cfg.Default = "normal"
+	}
+	if cfg.NormalShare <= 0 || cfg.NormalShare > 1 {
+		cfg.NormalShare = 0.9
+	}
+	if cfg.LowShare <= 0 || cfg.LowShare > 1 {
+		cfg.LowShare = 0.6
+	}
+	if cfg.LowBudgetShare <= 0 || cfg.LowBudgetShare > 1 {
+		cfg.LowBudgetShare = 0.75
+	}
+	return cfg
+}
+
+// MirrorConfig holds settings for replaying a sample of requests against a canary deployment.
+type MirrorConfig struct {
+	Enabled      bool
+	TargetURL    string
+	Percentage   float64
+	Timeout      time.Duration
+	MaxInFlight  int
+	IgnoreFields []string
+	// SkipPaths are never mirrored: streamed and held responses, such as /admin/export and
+	// /weather/poll, make no sense to replay.
+	SkipPaths []string
+	// MaxBodyBytes caps the primary response body kept for the comparison. Longer responses are
+	// not compared; zero keeps any body.
+	MaxBodyBytes int
+}
+
+// GetMirrorConfig returns the request mirroring configuration. Mirroring is only enabled when
+// mirror.enabled is true and a target URL is configured.
+func GetMirrorConfig() MirrorConfig {
+	initConfig()
+	cfg := MirrorConfig{
+		Enabled:      viper.GetBool("mirror.enabled"),
+		TargetURL:    viper.GetString("mirror.target_url"),
+		Percentage:   viper.GetFloat64("mirror.percentage"),
+		Timeout:      viper.GetDuration("mirror.timeout"),
+		MaxInFlight:  viper.GetInt("mirror.max_in_flight"),
+		IgnoreFields: viper.GetStringSlice("mirror.ignore_fields"),
+		SkipPaths:    viper.GetStringSlice("mirror.skip_paths"),
+		MaxBodyBytes: viper.GetInt("mirror.max_body_bytes"),
+	}
+	if cfg.TargetURL == "" {
+		cfg.Enabled = false
+	}
+	if cfg.Percentage < 0 {
+		cfg.Percentage = 0
+	} else if cfg.Percentage > 100 {
+		cfg.Percentage = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 10
+	}
+	if !viper.IsSet("mirror.ignore_fields") {
+		cfg.IgnoreFields = []string{"cached"}
+	}
+	if !viper.IsSet("mirror.skip_paths") {
+		cfg.SkipPaths = []string{"/weather/poll", "/admin/export"}
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	return cfg
+}
+
+// CaptureConfig holds settings for capturing request/response pairs to debug client issues.
+type CaptureConfig struct {
+	Enabled bool
+	// Locations, Statuses and APIKeys filter the captured requests; an empty filter matches every
+	// request, and a request is captured when it matches them all.
+	Locations []string
+	Statuses  []int
+	APIKeys   []string // API key names
+	// MaxEntries is the most captures kept; older ones are dropped.
+	MaxEntries int
+	// MaxBodyBytes caps the request and response bodies kept per capture.
+	MaxBodyBytes int
+}
+
+// GetCaptureConfig returns the capture configuration. MaxEntries defaults to 100 and MaxBodyBytes
+// to 4096 when unset or not positive.
+func GetCaptureConfig() CaptureConfig {
+	initConfig()
+	cfg := CaptureConfig{
+		Enabled:      viper.GetBool("capture.enabled"),
+		Locations:    viper.GetStringSlice("capture.locations"),
+		Statuses:     viper.GetIntSlice("capture.statuses"),
+		APIKeys:      viper.GetStringSlice("capture.api_keys"),
+		MaxEntries:   viper.GetInt("capture.max_entries"),
+		MaxBodyBytes: viper.GetInt("capture.max_body_bytes"),
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 100
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 4096
+	}
+	return cfg
+}
+
+// Deprecation marks a route, or a query parameter on a route, as deprecated.
+type Deprecation struct {
+	Path    string    `mapstructure:"path"`
+	Param   string    `mapstructure:"param"`
+	Since   time.Time `mapstructure:"since"`
+	Sunset  time.Time `mapstructure:"sunset"`
+	Link    string    `mapstructure:"link"`
+	Message string    `mapstructure:"message"`
+}
+
+// GetDeprecations returns the configured deprecation rules. Entries without a path are ignored.
+func GetDeprecations() []Deprecation {
+	initConfig()
+	var raw []Deprecation
+	if err := viper.UnmarshalKey("deprecations", &raw, viper.DecodeHook(mapstructure.StringToTimeHookFunc(time.RFC3339))); err != nil {
+		GetLogger().Errorw("Invalid deprecations config", "error", err)
+		return nil
+	}
+	rules := raw[:0]
+	for _, d := range raw {
+		if d.Path != "" {
+			rules = append(rules, d)
+		}
+	}
+	return rules
+}
+
+// Experiment routes a share of weather requests through an alternate code path.
+type Experiment struct {
+	ID string `mapstructure:"id"`
+	// Percent is the share of clients, 0 to 100, in the experiment.
+	Percent float64 `mapstructure:"percent"`
+	// Variant is the alternate code path: "adaptive_ttl" applies adaptive cache TTLs, and
+	// "provider_url" calls the provider at APIURL.
+	Variant string `mapstructure:"variant"`
+	APIURL  string `mapstructure:"api_url"`
+}
+
+// GetExperiments returns the configured experiments. Entries without an ID, with an unknown
+// variant, or that would take the experiments past 100% of clients are ignored with an error.
+func GetExperiments() []Experiment {
+	initConfig()
+	var raw []Experiment
+	if err := viper.UnmarshalKey("experiments", &raw); err != nil {
+		GetLogger().Errorw("Invalid experiments config", "error", err)
+		return nil
+	}
+	experiments := raw[:0]
+	total := 0.0
+	for _, e := range raw {
+		valid := e.ID != "" && e.Percent > 0 && total+e.Percent <= 100
+		switch e.Variant {
+		case "adaptive_ttl":
+		case "provider_url":
+			valid = valid && e.APIURL != ""
+		default:
+			valid = false
+		}
+		if !valid {
+			GetLogger().Errorw("Invalid experiment, ignored", "id", e.ID, "variant", e.Variant, "percent", e.Percent)
+			continue
+		}
+		total += e.Percent
+		experiments = append(experiments, e)
+	}
+	return experiments
+}
+
+// GetDisabledMiddlewares returns the names of middlewares removed from the default chain
+// (middleware.disabled). Recovery cannot be disabled.
+func GetDisabledMiddlewares() []string {
+	initConfig()
+	return viper.GetStringSlice("middleware.disabled")
+}
+
+// CORSConfig holds the cross-origin settings applied by the CORS middleware.
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+}
+
+// GetCORSConfig returns the CORS configuration. Any origin is allowed by default.
+func GetCORSConfig() CORSConfig {
+	initConfig()
+	cfg := CORSConfig{
+		AllowedOrigins: viper.GetStringSlice("cors.allowed_origins"),
+		AllowedHeaders: viper.GetStringSlice("cors.allowed_headers"),
+		MaxAge:         viper.GetDuration("cors.max_age"),
+	}
+	if len(cfg.AllowedOrigins) == 0 {
+		cfg.AllowedOrigins = []string{"*"}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Content-Type", "X-API-Key", "X-Request-ID"}
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 10 * time.Minute
+	}
+	return cfg
+}
+
+// APIKey is a client credential accepted by the auth middleware.
+type APIKey struct {
+	Key  string `mapstructure:"key"`
+	Name string `mapstructure:"name"`
+	Tier string `mapstructure:"tier"`
+	// Format is the default response shape for this key ("" or "homeassistant").
+	Format string `mapstructure:"format"`
+	// Admin allows the key to call admin endpoints and to force debug tracing of its requests with
+	// X-Debug-Trace.
+	Admin bool `mapstructure:"admin"`
+	// Provider, when set, bills the key's upstream calls to its own provider account instead of
+	// the shared one.
+	Provider *TenantProvider `mapstructure:"provider"`
+}
+
+// TenantProvider is the provider account an API key's upstream calls are made with.
+type TenantProvider struct {
+	// Name is the provider. Only "openweathermap" is supported.
+	Name string `mapstructure:"name"`
+	// APIKeyEnv names the environment variable holding the tenant's provider API key.
+	APIKeyEnv string `mapstructure:"api_key_env"`
+	// APIURL overrides openweathermap.api_url for the tenant.
+	APIURL string `mapstructure:"api_url"`
+	// DailyQuota is the tenant's daily call quota, which drives the degradation ladder for its
+	// requests instead of the shared quota. Zero means unlimited.
+	DailyQuota int64 `mapstructure:"daily_quota"`
+}
+
+// APIKey returns the tenant's provider API key from the environment.
+func (p TenantProvider) APIKey() string {
+	_ = godotenv.Load()
+	return os.Getenv(p.APIKeyEnv)
+}
+
+// IsAuthEnabled reports whether requests must present a valid API key.
+func IsAuthEnabled() bool {
+	initConfig()
+	return viper.GetBool("auth.enabled")
+}
+
+// Per-route authentication requirements in auth.routes.
+const (
+	AuthPublic   = "public"
+	AuthRequired = "required"
+)
+
+// GetAuthRoutes returns the routes whose authentication requirement differs from auth.enabled,
+// mapped to AuthPublic or AuthRequired. As in server.route_timeouts, a route ending in "/" covers
+// everything below it. Entries with any other value are ignored.
+func GetAuthRoutes() map[string]string {
+	initConfig()
+	routes := make(map[string]string)
+	for path, raw := range viper.GetStringMapString("auth.routes") {
+		switch req := strings.ToLower(raw); req {
+		case AuthPublic, AuthRequired:
+			routes[path] = req
+		default:
+			GetLogger().Errorw("Invalid auth requirement for route", "path", path, "requirement", raw)
+		}
+	}
+	return routes
+}
+
+// GetAPIKeys returns the configured API keys. Entries without a key are ignored. A provider name
+// defaults to "openweathermap"; keys with an unsupported provider or without api_key_env are
+// ignored too, so that they are rejected rather than billed to the shared account.
+func GetAPIKeys() []APIKey {
+	initConfig()
+	var raw []APIKey
+	if err := viper.UnmarshalKey("auth.api_keys", &raw); err != nil {
+		GetLogger().Errorw("Invalid auth.api_keys config", "error", err)
+		return nil
+	}
+	keys := raw[:0]
+	for _, k := range raw {
+		if k.Key == "" {
+			continue
+		}
+		if p := k.Provider; p != nil {
+			if p.Name == "" {
+				p.Name = "openweathermap"
+			}
+			if p.Name != "openweathermap" || p.APIKeyEnv == "" {
+				GetLogger().Errorw("Invalid provider for API key, key disabled", "name", k.Name, "provider", p.Name)
+				continue
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// GetExportFlushInterval returns how often streamed exports flush buffered rows to the client.
+func GetExportFlushInterval() time.Duration {
+	initConfig()
+	d := viper.GetDuration("export.flush_interval")
+	if d <= 0 {
+		return time.Second
+	}
+	return d
+}
+
+// GetBotPort returns the port the chat bot (cmd/weatherbot) listens on. Defaults to "8081".
+func GetBotPort() string {
+	initConfig()
+	if port := viper.GetString("bot.port"); port != "" {
+		return port
+	}
+	return "8081"
+}
+
+// GetBotRateLimiterConfig returns the per-chat rate (commands per minute) and burst for the chat bot.
+func GetBotRateLimiterConfig() (rate float64, burst int) {
+	initConfig()
+	rate = viper.GetFloat64("bot.rate_limiter.rate")
+	if rate == 0 {
+		rate = 5
+	}
+	burst = viper.GetInt("bot.rate_limiter.burst")
+	if burst == 0 {
+		burst = 3
+	}
+	return
+}
+
+// GetSlackSigningSecret returns the Slack app signing secret used to verify slash command requests.
+func GetSlackSigningSecret() string {
+	_ = godotenv.Load()
+	return os.Getenv("SLACK_SIGNING_SECRET")
+}
+
+// GetTelegramWebhookSecret returns the secret token Telegram sends with every webhook update.
+func GetTelegramWebhookSecret() string {
+	_ = godotenv.Load()
+	return os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+}
+
+// SigningConfig holds settings for signing response bodies.
+type SigningConfig struct {
+	Enabled bool
+	// Algorithm is "hmac-sha256" or "ed25519".
+	Algorithm string
+	// KeyID is sent with every signature so verifiers can pick the right key during rotation.
+	KeyID string
+	// Key is the HMAC secret, or the base64-encoded Ed25519 seed or private key, read from
+	// RESPONSE_SIGNING_KEY.
+	Key string
+}
+
+// GetSigningConfig returns the response signing configuration. Algorithm defaults to hmac-sha256
+// and KeyID to "default".
+func GetSigningConfig() SigningConfig {
+	initConfig()
+	_ = godotenv.Load()
+	cfg := SigningConfig{
+		Enabled:   viper.GetBool("signing.enabled"),
+		Algorithm: viper.GetString("signing.algorithm"),
+		KeyID:     viper.GetString("signing.key_id"),
+		Key:       os.Getenv("RESPONSE_SIGNING_KEY"),
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = "hmac-sha256"
+	}
+	if cfg.KeyID == "" {
+		cfg.KeyID = "default"
+	}
+	return cfg
+}
+
+// WebhookConfig holds settings for webhook deliveries to subscriptions.
+type WebhookConfig struct {
+	Enabled bool
+	// MaxAttempts is how many times a delivery is tried before it goes to the dead-letter list.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles per attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration
+	// DeadLetterLimit is how many dead letters are kept.
+	DeadLetterLimit int
+	// Workers is how many deliveries each replica makes at once.
+	Workers int
+	// PollInterval is how long an idle worker waits for a delivery before checking for abandoned
+	// ones, and how often retries that are due are queued again.
+	PollInterval time.Duration
+	// ClaimAfter is how long a delivery read by a worker can go unacknowledged before another
+	// worker takes it over, as when its replica stopped mid-delivery.
+	ClaimAfter time.Duration
+	// QueueMaxLen caps, approximately, the entries kept in the delivery stream.
+	QueueMaxLen int64
+	// AllowPrivateTargets lets deliveries reach loopback, private and link-local addresses, for
+	// local development. Off, such targets are refused when subscribing and when connecting.
+	AllowPrivateTargets bool
+}
+
+// GetWebhookConfig returns the webhook configuration. MaxAttempts defaults to 5, InitialBackoff to
+// 1s, MaxBackoff to 5m, Timeout to 5s, DeadLetterLimit to 1000, Workers to 4, PollInterval to 1s,
+// ClaimAfter to 1m and QueueMaxLen to 100000. ClaimAfter is raised to twice Timeout, so a slow
+// delivery is not taken over while it is still in progress.
+func GetWebhookConfig() WebhookConfig {
+	initConfig()
+	cfg := WebhookConfig{
+		Enabled:         viper.GetBool("webhooks.enabled"),
+		MaxAttempts:     viper.GetInt("webhooks.max_attempts"),
+		InitialBackoff:  viper.GetDuration("webhooks.initial_backoff"),
+		MaxBackoff:      viper.GetDuration("webhooks.max_backoff"),
+		Timeout:         viper.GetDuration("webhooks.timeout"),
+		DeadLetterLimit: viper.GetInt("webhooks.dead_letter_limit"),
+		Workers:         viper.GetInt("webhooks.workers"),
+		PollInterval:    viper.GetDuration("webhooks.poll_interval"),
+		ClaimAfter:      viper.GetDuration("webhooks.claim_after"),
+		QueueMaxLen:     viper.GetInt64("webhooks.queue_max_len"),
+
+		AllowPrivateTargets: viper.GetBool("webhooks.allow_private_targets"),
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = max(5*time.Minute, cfg.InitialBackoff)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.DeadLetterLimit <= 0 {
+		cfg.DeadLetterLimit = 1000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.ClaimAfter <= 0 {
+		cfg.ClaimAfter = time.Minute
+	}
+	cfg.ClaimAfter = max(cfg.ClaimAfter, 2*cfg.Timeout)
+	if cfg.QueueMaxLen <= 0 {
+		cfg.QueueMaxLen = 100000
+	}
+	return cfg
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -60,11 +62,11 @@ func TestGetCacheExpiration(t *testing.T) {
 	}
 }
 
-func TestGetServerTimeout(t *testing.T) {
-	want := "15s"
-	got := GetServerTimeout("read_header_timeout")
-	if got != want {
-		t.Errorf("Expected read_header_timeout %s, got %s", want, got)
+func TestGetServerTimeouts(t *testing.T) {
+	ReloadConfigForTest()
+	want := ServerTimeouts{ReadHeader: 15 * time.Second, Read: 15 * time.Second, Write: 10 * time.Second, Idle: 30 * time.Second, Shutdown: 30 * time.Second}
+	if got := GetServerTimeouts(); got != want {
+		t.Errorf("Expected server timeouts %+v, got %+v", want, got)
 	}
 }
 
@@ -197,3 +199,789 @@ func TestGetParamRateLimiterConfig_Default(t *testing.T) {
 		t.Errorf("Expected default param burst %v, got %v", wantBurst, burst)
 	}
 }
+
+func TestGetResponseCase(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, "snake", GetResponseCase())
+
+	viper.Set("server.response_case", "camel")
+	assert.Equal(t, "camel", GetResponseCase())
+
+	viper.Set("server.response_case", "kebab")
+	assert.Equal(t, "snake", GetResponseCase(), "Unknown casing should fall back to snake")
+	viper.Set("server.response_case", nil)
+}
+
+func TestIsResponseEnvelopeEnabled(t *testing.T) {
+	ReloadConfigForTest()
+	assert.True(t, IsResponseEnvelopeEnabled())
+
+	viper.Set("server.response_envelope", false)
+	assert.False(t, IsResponseEnvelopeEnabled())
+	viper.Set("server.response_envelope", nil)
+}
+
+func TestIsJSONPEnabled(t *testing.T) {
+	ReloadConfigForTest()
+	assert.False(t, IsJSONPEnabled())
+
+	viper.Set("server.jsonp", true)
+	assert.True(t, IsJSONPEnabled())
+	viper.Set("server.jsonp", nil)
+}
+
+func TestGetCacheStaleTTL(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, time.Hour, GetCacheStaleTTL())
+
+	viper.Set("cache.stale_ttl", "5m")
+	assert.Equal(t, 5*time.Minute, GetCacheStaleTTL())
+
+	viper.Set("cache.stale_ttl", "invalid")
+	assert.Equal(t, time.Hour, GetCacheStaleTTL())
+	viper.Set("cache.stale_ttl", nil)
+}
+
+func TestGetMirrorConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetMirrorConfig()
+	assert.False(t, cfg.Enabled)
+	assert.Equal(t, []string{"cached"}, cfg.IgnoreFields)
+	assert.Equal(t, []string{"/weather/poll", "/admin/export"}, cfg.SkipPaths)
+	assert.Equal(t, 1<<20, cfg.MaxBodyBytes)
+
+	viper.Set("mirror.enabled", true)
+	viper.Set("mirror.target_url", "http://canary:8080")
+	viper.Set("mirror.percentage", 250)
+	defer func() {
+		viper.Set("mirror.enabled", nil)
+		viper.Set("mirror.target_url", nil)
+		viper.Set("mirror.percentage", nil)
+	}()
+	cfg = GetMirrorConfig()
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, 100.0, cfg.Percentage)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Equal(t, 10, cfg.MaxInFlight)
+}
+
+func TestGetCaptureConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetCaptureConfig()
+	assert.False(t, cfg.Enabled)
+	assert.Empty(t, cfg.Locations)
+	assert.Equal(t, 100, cfg.MaxEntries)
+	assert.Equal(t, 4096, cfg.MaxBodyBytes)
+
+	viper.Set("capture.statuses", []int{500, 502})
+	viper.Set("capture.max_entries", -1)
+	defer func() {
+		viper.Set("capture.statuses", nil)
+		viper.Set("capture.max_entries", nil)
+	}()
+	cfg = GetCaptureConfig()
+	assert.Equal(t, []int{500, 502}, cfg.Statuses)
+	assert.Equal(t, 100, cfg.MaxEntries)
+}
+
+func TestGetDeprecations(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Empty(t, GetDeprecations())
+
+	viper.Set("deprecations", []map[string]interface{}{
+		{"path": "/weather", "sunset": "2025-12-31T00:00:00Z"},
+		{"message": "missing path is ignored"},
+	})
+	defer viper.Set("deprecations", nil)
+	rules := GetDeprecations()
+	assert.Len(t, rules, 1)
+	assert.Equal(t, 2025, rules[0].Sunset.Year())
+}
+
+func TestIsOfflineMode(t *testing.T) {
+	ReloadConfigForTest()
+	assert.False(t, IsOfflineMode())
+
+	viper.Set("offline_mode", true)
+	assert.True(t, IsOfflineMode())
+	viper.Set("offline_mode", nil)
+}
+
+func TestGetBatchConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, BatchConfig{MaxLocations: 20, Concurrency: 5}, GetBatchConfig())
+
+	viper.Set("batch.max_locations", 3)
+	viper.Set("batch.concurrency", 0)
+	defer func() {
+		viper.Set("batch.max_locations", nil)
+		viper.Set("batch.concurrency", nil)
+	}()
+	assert.Equal(t, BatchConfig{MaxLocations: 3, Concurrency: 5}, GetBatchConfig())
+}
+
+func TestGetForecastAndTravelConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, "https://api.openweathermap.org/data/2.5/forecast", GetOpenWeatherForecastURL())
+	assert.Equal(t, "https://pro.openweathermap.org/data/2.5/forecast/hourly", GetOpenWeatherHourlyForecastURL())
+	assert.Equal(t, "https://openweathermap.org/img/wn/{icon}@2x.png", GetOpenWeatherIconURL())
+	assert.Equal(t, ForecastConfig{CacheTTL: 30 * time.Minute}, GetForecastConfig())
+	assert.Equal(t, TravelConfig{SpeedKmh: 60, MaxWaypoints: 10}, GetTravelConfig())
+
+	viper.Set("travel.speed_kmh", 80)
+	viper.Set("travel.max_waypoints", -1)
+	defer func() {
+		viper.Set("travel.speed_kmh", nil)
+		viper.Set("travel.max_waypoints", nil)
+	}()
+	assert.Equal(t, TravelConfig{SpeedKmh: 80, MaxWaypoints: 10}, GetTravelConfig())
+}
+
+func TestGetGeocodeConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, "https://api.openweathermap.org/geo/1.0/direct", GetOpenWeatherGeocodingURL())
+	assert.Equal(t, GeocodeConfig{CacheTTL: 168 * time.Hour, Limit: 5}, GetGeocodeConfig())
+
+	viper.Set("geocode.limit", 9)
+	viper.Set("geocode.cache_ttl", "1h")
+	viper.Set("geocode.disambiguate", true)
+	defer func() {
+		viper.Set("geocode.limit", nil)
+		viper.Set("geocode.cache_ttl", nil)
+		viper.Set("geocode.disambiguate", nil)
+	}()
+	assert.Equal(t, GeocodeConfig{CacheTTL: time.Hour, Limit: 5, Disambiguate: true}, GetGeocodeConfig())
+}
+
+func TestGetGeoIPConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, GeoIPConfig{}, GetGeoIPConfig())
+
+	viper.Set("geoip.database", "/data/GeoLite2-City.mmdb")
+	defer viper.Set("geoip.database", nil)
+	assert.Equal(t, GeoIPConfig{Database: "/data/GeoLite2-City.mmdb"}, GetGeoIPConfig())
+}
+
+func TestGetWidgetConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetWidgetConfig()
+	assert.Equal(t, 10*time.Minute, cfg.MaxAge)
+	assert.Equal(t, "light", cfg.Theme)
+	assert.Equal(t, defaultWidgetThemes, cfg.Themes)
+
+	viper.Set("widget.max_age", "1m")
+	viper.Set("widget.theme", "Brand")
+	viper.Set("widget.themes", map[string]any{
+		"brand":  map[string]any{"background": "#0b3d91", "text": "white", "accent": "#fc3d21"},
+		"broken": map[string]any{"background": `"/><script>`, "text": "white", "accent": "red"},
+		"dark":   map[string]any{"background": "black", "text": "white", "accent": "orange"},
+	})
+	defer func() {
+		viper.Set("widget.max_age", nil)
+		viper.Set("widget.theme", nil)
+		viper.Set("widget.themes", nil)
+	}()
+	cfg = GetWidgetConfig()
+	assert.Equal(t, time.Minute, cfg.MaxAge)
+	assert.Equal(t, "brand", cfg.Theme)
+	assert.Equal(t, WidgetTheme{Background: "#0b3d91", Text: "white", Accent: "#fc3d21"}, cfg.Themes["brand"])
+	assert.Equal(t, WidgetTheme{Background: "black", Text: "white", Accent: "orange"}, cfg.Themes["dark"])
+	assert.Equal(t, defaultWidgetThemes["light"], cfg.Themes["light"])
+	assert.NotContains(t, cfg.Themes, "broken")
+
+	viper.Set("widget.theme", "missing")
+	assert.Equal(t, "light", GetWidgetConfig().Theme)
+}
+
+func TestGetPollConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, PollConfig{DefaultTimeout: 30 * time.Second, MaxTimeout: time.Minute, CheckInterval: 5 * time.Second}, GetPollConfig())
+
+	viper.Set("poll.default_timeout", "2m")
+	viper.Set("poll.max_timeout", "90s")
+	viper.Set("poll.check_interval", "-1s")
+	defer func() {
+		viper.Set("poll.default_timeout", nil)
+		viper.Set("poll.max_timeout", nil)
+		viper.Set("poll.check_interval", nil)
+	}()
+	assert.Equal(t, PollConfig{DefaultTimeout: 90 * time.Second, MaxTimeout: 90 * time.Second, CheckInterval: 5 * time.Second}, GetPollConfig())
+}
+
+func TestGetProviderRoutingConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetProviderRoutingConfig()
+	assert.Empty(t, cfg.Routes)
+	assert.Equal(t, "https://api.met.no/weatherapi/locationforecast/2.0/compact", cfg.MetNoURL)
+	assert.NotEmpty(t, cfg.MetNoUserAgent)
+
+	viper.Set("providers.routing", map[string]any{"no": "metno", "SE": "MetNo", "US": "openweathermap", "FR": "meteofrance"})
+	viper.Set("providers.metno.user_agent", "acme-weather ops@acme.example")
+	defer func() {
+		viper.Set("providers.routing", nil)
+		viper.Set("providers.metno.user_agent", nil)
+	}()
+	cfg = GetProviderRoutingConfig()
+	assert.Equal(t, map[string]string{"NO": ProviderMetNo, "SE": ProviderMetNo, "US": ProviderOpenWeatherMap}, cfg.Routes)
+	assert.Equal(t, "acme-weather ops@acme.example", cfg.MetNoUserAgent)
+}
+
+func TestGetOverrideConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, OverrideConfig{MaxTTL: 24 * time.Hour, SyncInterval: 5 * time.Second}, GetOverrideConfig())
+
+	viper.Set("overrides.max_ttl", "1h")
+	viper.Set("overrides.sync_interval", "-1s")
+	defer func() {
+		viper.Set("overrides.max_ttl", nil)
+		viper.Set("overrides.sync_interval", nil)
+	}()
+	assert.Equal(t, OverrideConfig{MaxTTL: time.Hour, SyncInterval: 5 * time.Second}, GetOverrideConfig())
+}
+
+func TestIsReadOnly(t *testing.T) {
+	ReloadConfigForTest()
+	assert.False(t, IsReadOnly())
+
+	viper.Set("read_only", true)
+	assert.True(t, IsReadOnly())
+	viper.Set("read_only", nil)
+}
+
+func TestGetModuleLogLevel(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, zapcore.DebugLevel, GetModuleLogLevel("repository"))
+
+	viper.Set("logging.level", "info")
+	viper.Set("logging.levels.repository", "warn")
+	viper.Set("logging.levels.handler", "not-a-level")
+	defer func() {
+		viper.Set("logging.level", nil)
+		viper.Set("logging.levels.repository", nil)
+		viper.Set("logging.levels.handler", nil)
+		ReloadConfigForTest()
+	}()
+	assert.Equal(t, zapcore.WarnLevel, GetModuleLogLevel("repository"))
+	assert.Equal(t, zapcore.InfoLevel, GetModuleLogLevel("handler"), "Invalid module level should fall back to logging.level")
+	assert.Equal(t, zapcore.InfoLevel, GetModuleLogLevel("middleware"))
+}
+
+func TestGetModuleLogger(t *testing.T) {
+	viper.Set("logging.levels.middleware", "error")
+	defer func() {
+		viper.Set("logging.levels.middleware", nil)
+		ReloadConfigForTest()
+	}()
+	ReloadConfigForTest()
+
+	l := GetModuleLogger("middleware")
+	assert.Same(t, l, GetModuleLogger("middleware"), "Module loggers should be cached")
+	assert.False(t, l.Desugar().Core().Enabled(zapcore.WarnLevel))
+	assert.True(t, l.Desugar().Core().Enabled(zapcore.ErrorLevel))
+	assert.True(t, GetModuleLogger("repository").Desugar().Core().Enabled(zapcore.DebugLevel))
+}
+
+func TestGetCORSConfig_Defaults(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetCORSConfig()
+	assert.Equal(t, []string{"*"}, cfg.AllowedOrigins)
+	assert.Contains(t, cfg.AllowedHeaders, "X-API-Key")
+	assert.Equal(t, 10*time.Minute, cfg.MaxAge)
+}
+
+func TestGetCacheTimeouts(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, CacheTimeouts{Read: 50 * time.Millisecond, Write: 100 * time.Millisecond}, GetCacheTimeouts())
+
+	viper.Set("cache.timeouts.read", "0")
+	viper.Set("cache.timeouts.write", "-1s")
+	defer func() {
+		viper.Set("cache.timeouts.read", nil)
+		viper.Set("cache.timeouts.write", nil)
+	}()
+	timeouts := GetCacheTimeouts()
+	assert.Zero(t, timeouts.Read)
+	assert.Equal(t, 100*time.Millisecond, timeouts.Write)
+}
+
+func TestGetCacheWriteQueueConfig(t *testing.T) {
+	ReloadConfigForTest()
+	want := CacheWriteQueueConfig{Size: 0, Workers: 4, BatchSize: 100, FlushInterval: time.Second}
+	assert.Equal(t, want, GetCacheWriteQueueConfig()) // size from config_test.yaml
+
+	viper.Set("cache.write_queue.size", -1)
+	viper.Set("cache.write_queue.workers", 2)
+	viper.Set("cache.write_queue.batch_size", 0)
+	viper.Set("cache.write_queue.flush_interval", "250ms")
+	defer func() {
+		for _, key := range []string{"size", "workers", "batch_size", "flush_interval"} {
+			viper.Set("cache.write_queue."+key, nil)
+		}
+	}()
+	want = CacheWriteQueueConfig{Size: 1024, Workers: 2, BatchSize: 100, FlushInterval: 250 * time.Millisecond}
+	assert.Equal(t, want, GetCacheWriteQueueConfig())
+}
+
+func TestGetStartupConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetStartupConfig()
+	assert.False(t, cfg.WaitForRedis)
+	assert.Equal(t, 10, cfg.Retries)
+	assert.Equal(t, 500*time.Millisecond, cfg.Backoff)
+	assert.Equal(t, 10*time.Second, cfg.MaxBackoff)
+	assert.True(t, cfg.Warmup)
+	assert.Equal(t, 4, cfg.WarmupConnections)
+	assert.Equal(t, 5*time.Second, cfg.WarmupTimeout)
+
+	viper.Set("startup.backoff", "20s")
+	viper.Set("startup.max_backoff", "1s")
+	viper.Set("startup.warmup", false)
+	defer func() {
+		viper.Set("startup.backoff", nil)
+		viper.Set("startup.max_backoff", nil)
+		viper.Set("startup.warmup", nil)
+	}()
+	cfg = GetStartupConfig()
+	assert.Equal(t, 20*time.Second, cfg.MaxBackoff, "MaxBackoff should not be below Backoff")
+	assert.False(t, cfg.Warmup)
+}
+
+func TestGetRedisConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetRedisConfig()
+	assert.Equal(t, "standalone", cfg.Mode)
+	assert.Empty(t, cfg.Replicas)
+	assert.Equal(t, 15*time.Second, cfg.HealthInterval)
+
+	viper.Set("redis.mode", "sentinel")
+	viper.Set("redis.addrs", []string{"s1:26379", "s2:26379"})
+	viper.Set("redis.master_name", "mymaster")
+	viper.Set("redis.read_from_replicas", true)
+	defer func() {
+		for _, key := range []string{"redis.mode", "redis.addrs", "redis.master_name", "redis.read_from_replicas"} {
+			viper.Set(key, nil)
+		}
+	}()
+	cfg = GetRedisConfig()
+	assert.Equal(t, "sentinel", cfg.Mode)
+	assert.Equal(t, []string{"s1:26379", "s2:26379"}, cfg.Addrs)
+	assert.Equal(t, "mymaster", cfg.MasterName)
+	assert.True(t, cfg.ReadFromReplicas)
+
+	viper.Set("redis.mode", "bogus")
+	assert.Equal(t, "standalone", GetRedisConfig().Mode)
+
+	EnableEmbeddedRedis()
+	defer viper.Set("redis.embedded", nil)
+	viper.Set("redis.mode", "sentinel")
+	cfg = GetRedisConfig()
+	assert.True(t, cfg.Embedded)
+	assert.Equal(t, "standalone", cfg.Mode, "embedded Redis is a single server")
+	assert.Empty(t, cfg.Addrs)
+	assert.False(t, cfg.ReadFromReplicas)
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	ReloadConfigForTest()
+	assert.False(t, IsAuthEnabled())
+	assert.Empty(t, GetAPIKeys())
+
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "k1", "name": "team-a", "tier": "free"},
+		{"name": "no key"},
+	})
+	defer viper.Set("auth.api_keys", nil)
+	keys := GetAPIKeys()
+	assert.Equal(t, []APIKey{{Key: "k1", Name: "team-a", Tier: "free"}}, keys)
+
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "k1", "name": "acme", "provider": map[string]interface{}{"api_key_env": "ACME_OWM_KEY", "daily_quota": 500}},
+		{"key": "k2", "name": "no env", "provider": map[string]interface{}{"name": "openweathermap"}},
+		{"key": "k3", "name": "other", "provider": map[string]interface{}{"name": "weatherapi", "api_key_env": "X"}},
+	})
+	t.Setenv("ACME_OWM_KEY", "acme-secret")
+	keys = GetAPIKeys()
+	if assert.Len(t, keys, 1) && assert.NotNil(t, keys[0].Provider) {
+		assert.Equal(t, TenantProvider{Name: "openweathermap", APIKeyEnv: "ACME_OWM_KEY", DailyQuota: 500}, *keys[0].Provider)
+		assert.Equal(t, "acme-secret", keys[0].Provider.APIKey())
+	}
+}
+
+func TestGetAuthRoutes(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Empty(t, GetAuthRoutes())
+
+	viper.Set("auth.routes", map[string]interface{}{"/weather": "Public", "/admin/": "required", "/forecast": "sometimes"})
+	defer viper.Set("auth.routes", nil)
+	assert.Equal(t, map[string]string{"/weather": AuthPublic, "/admin/": AuthRequired}, GetAuthRoutes())
+}
+
+func TestGetAdaptiveTTLConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetAdaptiveTTLConfig()
+	assert.False(t, cfg.Enabled)
+	assert.Equal(t, time.Minute, cfg.Min)
+	assert.Equal(t, time.Hour, cfg.Max)
+
+	viper.Set("cache.adaptive_ttl.max", "30s")
+	viper.Set("cache.adaptive_ttl.volatile_delta", 0.1)
+	defer func() {
+		viper.Set("cache.adaptive_ttl.max", nil)
+		viper.Set("cache.adaptive_ttl.volatile_delta", nil)
+	}()
+	cfg = GetAdaptiveTTLConfig()
+	assert.Equal(t, time.Minute, cfg.Max, "max is raised to min")
+	assert.Equal(t, 2.0, cfg.VolatileDelta, "volatile delta must exceed stable delta")
+}
+
+func TestGetFeedConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetFeedConfig()
+	assert.Equal(t, 20, cfg.MaxItems)
+	assert.Equal(t, 1.0, cfg.TempDelta)
+
+	viper.Set("cache.warm_cities", []string{"London", "Tokyo"})
+	viper.Set("feed.max_items", 5)
+	defer func() {
+		viper.Set("cache.warm_cities", nil)
+		viper.Set("feed.max_items", nil)
+	}()
+	assert.Equal(t, []string{"London", "Tokyo"}, GetWarmCities())
+	assert.Equal(t, 5, GetFeedConfig().MaxItems)
+}
+
+func TestGetHistoryConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, HistoryConfig{MaxItems: 100, Retention: 24 * time.Hour}, GetHistoryConfig())
+
+	viper.Set("history.max_items", 10)
+	viper.Set("history.retention", "1h")
+	defer func() {
+		viper.Set("history.max_items", nil)
+		viper.Set("history.retention", nil)
+	}()
+	assert.Equal(t, HistoryConfig{MaxItems: 10, Retention: time.Hour}, GetHistoryConfig())
+}
+
+func TestGetSLOConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetSLOConfig()
+	assert.Equal(t, []string{"/weather", "/v1/weather"}, cfg.Routes)
+	assert.Equal(t, 0.999, cfg.Availability)
+	assert.Equal(t, 300*time.Millisecond, cfg.LatencyTarget)
+
+	viper.Set("slo.availability", 1.5)
+	viper.Set("slo.latency_target", "1s")
+	defer func() {
+		viper.Set("slo.availability", nil)
+		viper.Set("slo.latency_target", nil)
+	}()
+	cfg = GetSLOConfig()
+	assert.Equal(t, 0.999, cfg.Availability, "objectives must be below 1")
+	assert.Equal(t, time.Second, cfg.LatencyTarget)
+}
+
+func TestGetCostConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetCostConfig()
+	assert.Equal(t, "USD", cfg.Currency)
+	assert.Equal(t, 10*time.Second, cfg.FlushInterval)
+	assert.Equal(t, 0.8, cfg.QuotaWarning)
+
+	viper.Set("costs.providers", map[string]interface{}{
+		"openweathermap": map[string]interface{}{"price_per_call": 0.0015, "daily_quota": 1000},
+	})
+	defer viper.Set("costs.providers", nil)
+	cfg = GetCostConfig()
+	assert.Equal(t, ProviderPricing{PricePerCall: 0.0015, DailyQuota: 1000}, cfg.Providers["openweathermap"])
+}
+
+func TestGetDegradationConfig(t *testing.T) {
+	ReloadConfigForTest()
+	cfg := GetDegradationConfig()
+	assert.False(t, cfg.Enabled)
+	assert.Equal(t, DegradationConfig{ExtendTTLAt: 0.75, TTLMultiplier: 4, DisableRefreshAt: 0.9, CacheOnlyAt: 1}, cfg)
+
+	viper.Set("degradation.ttl_multiplier", 0.5)
+	defer viper.Set("degradation.ttl_multiplier", nil)
+	assert.Equal(t, 4.0, GetDegradationConfig().TTLMultiplier, "TTLs are never shortened")
+}
+
+func TestGetSigningConfig(t *testing.T) {
+	ReloadConfigForTest()
+	t.Setenv("RESPONSE_SIGNING_KEY", "s3cret")
+	cfg := GetSigningConfig()
+	assert.False(t, cfg.Enabled)
+	assert.Equal(t, "hmac-sha256", cfg.Algorithm)
+	assert.Equal(t, "default", cfg.KeyID)
+	assert.Equal(t, "s3cret", cfg.Key)
+}
+
+func TestGetWebhookConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, WebhookConfig{
+		MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Minute, Timeout: 5 * time.Second, DeadLetterLimit: 1000,
+		Workers: 4, PollInterval: time.Second, ClaimAfter: time.Minute, QueueMaxLen: 100000,
+	}, GetWebhookConfig())
+
+	viper.Set("webhooks.initial_backoff", "10m")
+	viper.Set("webhooks.timeout", "45s")
+	defer func() {
+		viper.Set("webhooks.initial_backoff", nil)
+		viper.Set("webhooks.timeout", nil)
+	}()
+	cfg := GetWebhookConfig()
+	assert.Equal(t, 10*time.Minute, cfg.MaxBackoff, "max backoff is raised to the initial backoff")
+	assert.Equal(t, 90*time.Second, cfg.ClaimAfter, "claim_after is raised to twice the timeout")
+}
+
+func TestGetMemoryConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, MemoryConfig{Interval: 15 * time.Second}, GetMemoryConfig())
+
+	viper.Set("memory.soft_limit", "256MB")
+	viper.Set("memory.interval", "1m")
+	defer func() {
+		viper.Set("memory.soft_limit", nil)
+		viper.Set("memory.interval", nil)
+	}()
+	assert.Equal(t, MemoryConfig{SoftLimit: 256 << 20, Interval: time.Minute}, GetMemoryConfig())
+}
+
+func TestGetLeaderConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, LeaderConfig{TTL: 15 * time.Second}, GetLeaderConfig())
+
+	viper.Set("leader.enabled", true)
+	viper.Set("leader.ttl", "30s")
+	defer func() {
+		viper.Set("leader.enabled", nil)
+		viper.Set("leader.ttl", nil)
+	}()
+	assert.Equal(t, LeaderConfig{Enabled: true, TTL: 30 * time.Second}, GetLeaderConfig())
+}
+
+func TestGetWarmerConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, WarmerConfig{Interval: 10 * time.Minute, Delay: time.Second}, GetWarmerConfig())
+
+	viper.Set("cache.warmer.enabled", true)
+	viper.Set("cache.warmer.interval", "1h")
+	viper.Set("cache.warmer.delay", "0s")
+	defer func() {
+		viper.Set("cache.warmer.enabled", nil)
+		viper.Set("cache.warmer.interval", nil)
+		viper.Set("cache.warmer.delay", nil)
+	}()
+	assert.Equal(t, WarmerConfig{Enabled: true, Interval: time.Hour}, GetWarmerConfig())
+}
+
+func TestGetCachedRateLimitConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, CachedRateLimitConfig{Rate: 60, Burst: 60}, GetCachedRateLimitConfig())
+
+	viper.Set("rate_limiter.cached.enabled", true)
+	viper.Set("rate_limiter.cached.rate", 0)
+	viper.Set("rate_limiter.cached.burst", -1)
+	defer func() {
+		viper.Set("rate_limiter.cached.enabled", nil)
+		viper.Set("rate_limiter.cached.rate", nil)
+		viper.Set("rate_limiter.cached.burst", nil)
+	}()
+	assert.Equal(t, CachedRateLimitConfig{Enabled: true, Rate: 0, Burst: 60}, GetCachedRateLimitConfig())
+}
+
+func TestGetRateLimitKey(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, []string{"ip"}, GetRateLimitKey())
+
+	viper.Set("rate_limiter.key", []string{"api_key", "header:X-Client-ID"})
+	defer viper.Set("rate_limiter.key", nil)
+	assert.Equal(t, []string{"api_key", "header:X-Client-ID"}, GetRateLimitKey())
+}
+
+func TestGetRateLimitDocsURL(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, "https://github.com/fakhrymubarak/weather-api-redis#rate-limiting", GetRateLimitDocsURL())
+
+	viper.Set("rate_limiter.docs_url", "https://docs.example.com/limits")
+	defer viper.Set("rate_limiter.docs_url", nil)
+	assert.Equal(t, "https://docs.example.com/limits", GetRateLimitDocsURL())
+}
+
+func TestGetAdaptiveRateLimitConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, AdaptiveRateLimitConfig{
+		Interval: 15 * time.Second, MinRequests: 10, ErrorRate: 0.2, Latency: 2 * time.Second,
+		TightenFactor: 0.5, MinFactor: 0.2, RelaxStep: 0.1,
+	}, GetAdaptiveRateLimitConfig())
+
+	viper.Set("rate_limiter.adaptive.tighten_factor", 2)
+	defer viper.Set("rate_limiter.adaptive.tighten_factor", nil)
+	assert.Equal(t, 0.5, GetAdaptiveRateLimitConfig().TightenFactor, "the limit is never raised when unhealthy")
+}
+
+func TestGetPriorityConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, PriorityConfig{
+		Default: "normal", Tiers: map[string]string{}, NormalShare: 0.9, LowShare: 0.6, LowBudgetShare: 0.75,
+	}, GetPriorityConfig())
+
+	viper.Set("priority.tiers", map[string]string{"Pro": "high"})
+	defer viper.Set("priority.tiers", nil)
+	assert.Equal(t, "high", GetPriorityConfig().Tiers["pro"])
+}
+
+func TestGetTemperaturePrecision(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, 2, GetTemperaturePrecision())
+
+	defer viper.Set("server.temperature_precision", nil)
+	viper.Set("server.temperature_precision", 1)
+	assert.Equal(t, 1, GetTemperaturePrecision())
+	viper.Set("server.temperature_precision", 0)
+	assert.Equal(t, 0, GetTemperaturePrecision())
+	viper.Set("server.temperature_precision", 12)
+	assert.Equal(t, 6, GetTemperaturePrecision())
+}
+
+func TestGetRouteTimeouts(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, 2*time.Second, GetRouteTimeouts()["/weather"])
+	assert.Equal(t, time.Minute, GetRouteTimeouts()["/admin/export"])
+
+	defer viper.Set("server.route_timeouts", nil)
+	viper.Set("server.route_timeouts", map[string]string{"/weather": "500ms", "/feed/": "5s", "/status": "soon", "/admin/jobs": "0s"})
+	assert.Equal(t, map[string]time.Duration{"/weather": 500 * time.Millisecond, "/feed/": 5 * time.Second}, GetRouteTimeouts())
+}
+
+func TestGetShutdownTimeout(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, 30*time.Second, GetShutdownTimeout())
+
+	defer viper.Set("server.timeouts.shutdown", nil)
+	viper.Set("server.timeouts.shutdown", "5s")
+	assert.Equal(t, 5*time.Second, GetShutdownTimeout())
+
+	// A config not migrated yet still sets server.shutdown_timeout
+	defer viper.Set("server.shutdown_timeout", nil)
+	viper.Set("server.timeouts.shutdown", "0s")
+	viper.Set("server.shutdown_timeout", "7s")
+	assert.Equal(t, 7*time.Second, GetShutdownTimeout())
+}
+
+func TestGetResponseEncoding(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, "json", GetResponseEncoding())
+	viper.Set("server.response_encoding", "msgpack")
+	defer viper.Set("server.response_encoding", nil)
+	assert.Equal(t, "msgpack", GetResponseEncoding())
+}
+
+func TestGetExperiments(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Empty(t, GetExperiments())
+
+	viper.Set("experiments", []map[string]interface{}{
+		{"id": "ttl", "percent": 60, "variant": "adaptive_ttl"},
+		{"id": "edge", "percent": 10, "variant": "provider_url"},
+		{"id": "edge", "percent": 10, "variant": "provider_url", "api_url": "https://edge.example.com"},
+		{"id": "unknown", "percent": 10, "variant": "new_cache"},
+		{"id": "too-big", "percent": 40, "variant": "adaptive_ttl"},
+		{"percent": 5, "variant": "adaptive_ttl"},
+	})
+	defer viper.Set("experiments", nil)
+	assert.Equal(t, []Experiment{
+		{ID: "ttl", Percent: 60, Variant: "adaptive_ttl"},
+		{ID: "edge", Percent: 10, Variant: "provider_url", APIURL: "https://edge.example.com"},
+	}, GetExperiments())
+}
+
+func TestGetCoolDownConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, CoolDownConfig{Default: 30 * time.Second, Max: 10 * time.Minute}, GetCoolDownConfig())
+
+	viper.Set("openweathermap.cool_down.default", "1h")
+	defer viper.Set("openweathermap.cool_down.default", nil)
+	assert.Equal(t, time.Hour, GetCoolDownConfig().Max, "Max should not be below Default")
+}
+
+func TestGetUVIndexConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, UVIndexConfig{URL: "https://api.openweathermap.org/data/3.0/onecall"}, GetUVIndexConfig())
+
+	viper.Set("openweathermap.uv_index.enabled", true)
+	viper.Set("openweathermap.uv_index.url", "https://api.openweathermap.org/data/2.5/uvi")
+	defer func() {
+		viper.Set("openweathermap.uv_index.enabled", nil)
+		viper.Set("openweathermap.uv_index.url", nil)
+	}()
+	assert.Equal(t, UVIndexConfig{Enabled: true, URL: "https://api.openweathermap.org/data/2.5/uvi"}, GetUVIndexConfig())
+}
+
+func TestGetAlertsConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, AlertsConfig{CacheTTL: 5 * time.Minute}, GetAlertsConfig())
+	assert.Equal(t, "https://api.openweathermap.org/data/3.0/onecall", GetOpenWeatherOneCallURL())
+
+	viper.Set("alerts.cache_ttl", "90s")
+	viper.Set("openweathermap.onecall_url", "http://onecall.test")
+	defer func() {
+		viper.Set("alerts.cache_ttl", nil)
+		viper.Set("openweathermap.onecall_url", nil)
+	}()
+	assert.Equal(t, AlertsConfig{CacheTTL: 90 * time.Second}, GetAlertsConfig())
+	assert.Equal(t, "http://onecall.test", GetOpenWeatherOneCallURL())
+}
+
+func TestGetProviderResolveConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.False(t, GetProviderResolveConfig().Configured())
+
+	viper.Set("openweathermap.resolve.static", map[string]interface{}{"API.openweathermap.org": []string{"203.0.113.10"}})
+	viper.Set("openweathermap.resolve.pin", true)
+	defer func() {
+		viper.Set("openweathermap.resolve.static", nil)
+		viper.Set("openweathermap.resolve.pin", nil)
+	}()
+	cfg := GetProviderResolveConfig()
+	assert.True(t, cfg.Configured())
+	assert.True(t, cfg.Pin)
+	assert.Equal(t, []string{"203.0.113.10"}, cfg.Static["api.openweathermap.org"])
+}
+
+func TestInitConfig_ConcurrentFirstLoad(t *testing.T) {
+	// Getters racing to load the config must all see it loaded exactly once
+	configLoaded.Store(false)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if got := GetServerTimeouts().ReadHeader; got != 15*time.Second {
+				t.Errorf("Expected the loaded read_header timeout, got %v", got)
+			}
+			if got := GetRateLimitKey(); len(got) == 0 {
+				t.Error("Expected the loaded rate limit key")
+			}
+			GetModuleLogger([]string{"handler", "repository", "middleware"}[i%3]).Debugw("Loaded")
+		}(i)
+	}
+	wg.Wait()
+	if !configLoaded.Load() {
+		t.Error("Expected the config to be marked loaded")
+	}
+}
+
+func TestGetQualityConfig(t *testing.T) {
+	ReloadConfigForTest()
+	assert.Equal(t, QualityConfig{MaxAge: time.Hour}, GetQualityConfig())
+
+	viper.Set("quality.enabled", true)
+	viper.Set("quality.max_age", "15m")
+	defer func() {
+		viper.Set("quality.enabled", nil)
+		viper.Set("quality.max_age", nil)
+	}()
+	assert.Equal(t, QualityConfig{Enabled: true, MaxAge: 15 * time.Minute}, GetQualityConfig())
+}
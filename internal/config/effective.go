@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/viper"
+)
+
+// Setting sources reported by EffectiveSettings.
+const (
+	SourceFile     = "file"
+	SourceEnv      = "env"
+	SourceDefault  = "default"
+	SourceOverride = "override"
+)
+
+// Redacted replaces the value of secret settings.
+const Redacted = "[REDACTED]"
+
+// Setting is one live configuration value and where it came from.
+type Setting struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	// Source is "file" (config.yaml), "env", "default" (built in) or "override" (set at runtime).
+	Source string `json:"source"`
+	// Env names the environment variable a setting is read from.
+	Env string `json:"env,omitempty"`
+}
+
+// envSettings are read from the environment rather than config.yaml.
+var envSettings = []struct {
+	key, env string
+	secret   bool
+}{
+	{"openweathermap.api_key", "OPENWEATHERMAP_API_KEY", true},
+	{"redis.addr", "REDIS_ADDR", false},
+	{"signing.key", "RESPONSE_SIGNING_KEY", true},
+	{"cache.encryption.key", "CACHE_ENCRYPTION_KEY", true},
+	{"bot.slack_signing_secret", "SLACK_SIGNING_SECRET", true},
+	{"bot.telegram_webhook_secret", "TELEGRAM_WEBHOOK_SECRET", true},
+}
+
+// defaultSettings report the built-in value of settings missing from the config file.
+var defaultSettings = map[string]func() interface{}{
+	"server.port":                  func() interface{} { return "8080" },
+	"server.response_case":         func() interface{} { return GetResponseCase() },
+	"server.response_encoding":     func() interface{} { return GetResponseEncoding() },
+	"server.temperature_precision": func() interface{} { return GetTemperaturePrecision() },
+	"server.route_timeouts":        func() interface{} { return GetRouteTimeouts() },
+	"server.timeouts.read_header":  func() interface{} { return GetServerTimeouts().ReadHeader },
+	"server.timeouts.read":         func() interface{} { return GetServerTimeouts().Read },
+	"server.timeouts.write":        func() interface{} { return GetServerTimeouts().Write },
+	"server.timeouts.idle":         func() interface{} { return GetServerTimeouts().Idle },
+	"server.timeouts.shutdown":     func() interface{} { return GetServerTimeouts().Shutdown },
+	"cache.stale_ttl":              func() interface{} { return GetCacheStaleTTL() },
+	"rate_limiter.cleanup_timeout": func() interface{} { return GetRateLimiterCleanupTimeout() },
+	"rate_limiter.docs_url":        func() interface{} { return GetRateLimitDocsURL() },
+	"rate_limiter.key":             func() interface{} { return GetRateLimitKey() },
+	"export.flush_interval":        func() interface{} { return GetExportFlushInterval() },
+	"leader.ttl":                   func() interface{} { return GetLeaderConfig().TTL },
+	"memory.interval":              func() interface{} { return GetMemoryConfig().Interval },
+	"cache.warmer.interval":        func() interface{} { return GetWarmerConfig().Interval },
+	"history.max_items":            func() interface{} { return GetHistoryConfig().MaxItems },
+	"history.retention":            func() interface{} { return GetHistoryConfig().Retention },
+	"signing.algorithm":            func() interface{} { return GetSigningConfig().Algorithm },
+	"webhooks.max_attempts":        func() interface{} { return GetWebhookConfig().MaxAttempts },
+	"bot.port":                     func() interface{} { return GetBotPort() },
+}
+
+// EffectiveSettings returns the merged configuration sorted by key, with the source of each value.
+// Settings missing from the config file are listed only when they have a built-in default or an
+// environment variable. Secrets are redacted.
+func EffectiveSettings() []Setting {
+	initConfig()
+	_ = godotenv.Load()
+	settings := make(map[string]Setting)
+	for _, key := range viper.AllKeys() {
+		if viper.Get(key) == nil {
+			continue
+		}
+		source := SourceFile
+		if !viper.InConfig(key) {
+			source = SourceOverride
+		}
+		settings[key] = Setting{Key: key, Value: viper.Get(key), Source: source}
+	}
+	for key, value := range defaultSettings {
+		if !hasSetting(settings, key) {
+			settings[key] = Setting{Key: key, Value: value(), Source: SourceDefault}
+		}
+	}
+	for _, e := range envSettings {
+		s := Setting{Key: e.key, Source: SourceDefault, Env: e.env}
+		if v, ok := settings[e.key]; ok {
+			s = v
+			s.Env = e.env
+		}
+		if value := os.Getenv(e.env); value != "" {
+			s.Value, s.Source = value, SourceEnv
+		}
+		if e.secret && s.Value != nil && s.Value != "" {
+			s.Value = Redacted
+		}
+		settings[e.key] = s
+	}
+
+	out := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		s.Value = redactSetting(s.Key, printable(s.Value))
+		out = append(out, s)
+	}
+	slices.SortFunc(out, func(a, b Setting) int { return strings.Compare(a.Key, b.Key) })
+	return out
+}
+
+// hasSetting reports whether key is set, either directly or, for maps such as
+// server.route_timeouts, through the entries viper flattens into keys of their own.
+func hasSetting(settings map[string]Setting, key string) bool {
+	for k := range settings {
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// printable renders durations as strings such as "2s" rather than nanoseconds.
+func printable(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case map[string]time.Duration:
+		out := make(map[string]string, len(v))
+		for k, d := range v {
+			out[k] = d.String()
+		}
+		return out
+	}
+	return v
+}
+
+// secretField reports whether a setting or field name holds a secret, e.g. the key of an
+// auth.api_keys entry.
+func secretField(name string) bool {
+	return strings.Contains(name, "secret") || strings.Contains(name, "password") || strings.Contains(name, "token")
+}
+
+// redactSetting hides secret values, including the keys of nested entries such as auth.api_keys.
+func redactSetting(key string, v interface{}) interface{} {
+	if secretField(key[strings.LastIndex(key, ".")+1:]) {
+		return Redacted
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactEntry(item)
+		}
+		return out
+	case map[string]interface{}:
+		return redactEntry(v)
+	}
+	return v
+}
+
+func redactEntry(v interface{}) interface{} {
+	entry, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	out := make(map[string]interface{}, len(entry))
+	for k, field := range entry {
+		if k == "key" || secretField(k) {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = field
+	}
+	return out
+}
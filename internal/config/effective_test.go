@@ -0,0 +1,63 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func findSetting(settings []Setting, key string) (Setting, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+func TestEffectiveSettings(t *testing.T) {
+	ReloadConfigForTest()
+	t.Setenv("OPENWEATHERMAP_API_KEY", "owm-secret")
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	t.Setenv("CACHE_ENCRYPTION_KEY", "c2VjcmV0LWtleS0xMjM0NQ==")
+	viper.Set("custom.setting", "x")
+	viper.Set("auth.api_keys", []interface{}{map[string]interface{}{"key": "k-123", "name": "ci"}})
+	defer viper.Set("custom.setting", nil)
+	viper.Set("server.route_timeouts", map[string]interface{}{"/weather": "2s"})
+	defer viper.Set("auth.api_keys", nil)
+	defer viper.Set("server.route_timeouts", nil)
+	defaultSettings["custom.default"] = func() interface{} { return "built-in" }
+	defer delete(defaultSettings, "custom.default")
+
+	settings := EffectiveSettings()
+
+	port, _ := findSetting(settings, "server.port")
+	assert.Equal(t, Setting{Key: "server.port", Value: "18080", Source: SourceFile}, port)
+	owm, _ := findSetting(settings, "openweathermap.api_key")
+	assert.Equal(t, Setting{Key: "openweathermap.api_key", Value: Redacted, Source: SourceEnv, Env: "OPENWEATHERMAP_API_KEY"}, owm)
+	cacheKey, _ := findSetting(settings, "cache.encryption.key")
+	assert.Equal(t, Setting{Key: "cache.encryption.key", Value: Redacted, Source: SourceEnv, Env: "CACHE_ENCRYPTION_KEY"}, cacheKey)
+	redisAddr, _ := findSetting(settings, "redis.addr")
+	assert.Equal(t, Setting{Key: "redis.addr", Value: "redis:6379", Source: SourceEnv, Env: "REDIS_ADDR"}, redisAddr)
+	custom, _ := findSetting(settings, "custom.setting")
+	assert.Equal(t, SourceOverride, custom.Source)
+	builtIn, _ := findSetting(settings, "custom.default")
+	assert.Equal(t, Setting{Key: "custom.default", Value: "built-in", Source: SourceDefault}, builtIn)
+	timeout, _ := findSetting(settings, "server.route_timeouts./weather")
+	assert.Equal(t, "2s", timeout.Value)
+	_, dup := findSetting(settings, "server.route_timeouts")
+	assert.False(t, dup, "a map set in the file should not also be listed as a default")
+
+	keys, _ := findSetting(settings, "auth.api_keys")
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": Redacted, "name": "ci"}}, keys.Value)
+	for i := 1; i < len(settings); i++ {
+		assert.Less(t, settings[i-1].Key, settings[i].Key)
+	}
+}
+
+func TestRedactSetting(t *testing.T) {
+	assert.Equal(t, Redacted, redactSetting("bot.webhook_secret", "abc"))
+	assert.Equal(t, []interface{}{"ip"}, redactSetting("rate_limiter.key", []interface{}{"ip"}))
+	assert.Equal(t, map[string]interface{}{"name": "n", "token": Redacted}, redactSetting("x.y", map[string]interface{}{"name": "n", "token": "t"}))
+}
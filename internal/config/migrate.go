@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyMigration moves a setting whose key changed in a config-breaking release.
+type KeyMigration struct {
+	From string // dotted key, e.g. "rate_limiter.global.rate"
+	To   string
+}
+
+// DeprecatedKey is a setting that is no longer read, or will stop being read.
+type DeprecatedKey struct {
+	Key  string
+	Note string // what to do instead
+}
+
+// keyMigrations lists renamed settings in the order they are applied. Add an entry with every
+// release that moves a key.
+var keyMigrations = []KeyMigration{
+	// The server timeouts were grouped under server.timeouts, as cache.timeouts are
+	{From: "server.read_header_timeout", To: "server.timeouts.read_header"},
+	{From: "server.read_timeout", To: "server.timeouts.read"},
+	{From: "server.write_timeout", To: "server.timeouts.write"},
+	{From: "server.idle_timeout", To: "server.timeouts.idle"},
+	{From: "server.shutdown_timeout", To: "server.timeouts.shutdown"},
+}
+
+// deprecatedKeys lists settings that are reported but left in place by MigrateConfig. A key still
+// set after the migrations ran is one that could not be moved.
+var deprecatedKeys = []DeprecatedKey{
+	{Key: "server.read_header_timeout", Note: "ignored once server.timeouts.read_header is set"},
+	{Key: "server.read_timeout", Note: "ignored once server.timeouts.read is set"},
+	{Key: "server.write_timeout", Note: "ignored once server.timeouts.write is set"},
+	{Key: "server.idle_timeout", Note: "ignored once server.timeouts.idle is set"},
+	{Key: "server.shutdown_timeout", Note: "ignored once server.timeouts.shutdown is set"},
+}
+
+// MigrationReport describes what MigrateConfig changed and what needs attention.
+type MigrationReport struct {
+	Moved      []KeyMigration
+	Deprecated []DeprecatedKey
+	// Conflicts are migrations skipped because both the old and the new key are set.
+	Conflicts []KeyMigration
+}
+
+// Changed reports whether the migrated config differs from the input.
+func (r MigrationReport) Changed() bool {
+	return len(r.Moved) > 0
+}
+
+// MigrateConfig rewrites a config.yaml in the current layout: every renamed key is moved to its new
+// place, keeping its comments, and deprecated keys are reported. Keys already in the current
+// layout are left as they are, so migrating twice is harmless.
+func MigrateConfig(src []byte) ([]byte, MigrationReport, error) {
+	var report MigrationReport
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, report, fmt.Errorf("parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return src, report, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, report, fmt.Errorf("parse config: top level is not a mapping")
+	}
+
+	for _, m := range keyMigrations {
+		parent, i := findKey(root, m.From)
+		if parent == nil {
+			continue
+		}
+		path := strings.Split(m.To, ".")
+		if dst, _ := findKey(root, m.To); dst != nil || !canHold(root, path[:len(path)-1]) {
+			report.Conflicts = append(report.Conflicts, m)
+			continue
+		}
+		key, value := parent.Content[i], parent.Content[i+1]
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+		key.Value = path[len(path)-1]
+		target := ensureMapping(root, path[:len(path)-1])
+		target.Content = append(target.Content, key, value)
+		report.Moved = append(report.Moved, m)
+	}
+	for _, d := range deprecatedKeys {
+		if parent, _ := findKey(root, d.Key); parent != nil {
+			report.Deprecated = append(report.Deprecated, d)
+		}
+	}
+	if !report.Changed() {
+		return src, report, nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, report, fmt.Errorf("write config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, report, fmt.Errorf("write config: %w", err)
+	}
+	return out.Bytes(), report, nil
+}
+
+// findKey returns the mapping holding the dotted key and the index of its key node, or nil when
+// the key is not set.
+func findKey(root *yaml.Node, dotted string) (*yaml.Node, int) {
+	node := root
+	path := strings.Split(dotted, ".")
+	for depth, name := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, 0
+		}
+		i := mappingIndex(node, name)
+		if i < 0 {
+			return nil, 0
+		}
+		if depth == len(path)-1 {
+			return node, i
+		}
+		node = node.Content[i+1]
+	}
+	return nil, 0
+}
+
+// canHold reports whether every existing level of path is a mapping, so a key can be placed below
+// it without overwriting a value.
+func canHold(root *yaml.Node, path []string) bool {
+	node := root
+	for _, name := range path {
+		i := mappingIndex(node, name)
+		if i < 0 {
+			return true
+		}
+		if node = node.Content[i+1]; node.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureMapping returns the mapping at path, creating missing levels. Existing levels must be
+// mappings (see canHold).
+func ensureMapping(root *yaml.Node, path []string) *yaml.Node {
+	node := root
+	for _, name := range path {
+		i := mappingIndex(node, name)
+		if i < 0 {
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, child)
+			node = child
+			continue
+		}
+		node = node.Content[i+1]
+	}
+	return node
+}
+
+// mappingIndex returns the index of the key node named name in a mapping node, or -1.
+func mappingIndex(node *yaml.Node, name string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// withMigrations replaces the migration tables for one test.
+func withMigrations(t *testing.T, moves []KeyMigration, deprecated []DeprecatedKey) {
+	t.Helper()
+	prevMoves, prevDeprecated := keyMigrations, deprecatedKeys
+	keyMigrations, deprecatedKeys = moves, deprecated
+	t.Cleanup(func() { keyMigrations, deprecatedKeys = prevMoves, prevDeprecated })
+}
+
+func TestMigrateConfig(t *testing.T) {
+	withMigrations(t, []KeyMigration{
+		{From: "cache.expiration", To: "cache.ttl"},
+		{From: "rate_limiter.global", To: "limits.global"},
+		{From: "missing.key", To: "other.key"},
+	}, []DeprecatedKey{
+		{Key: "redis.addr", Note: "set REDIS_ADDR instead"},
+		{Key: "not.set", Note: "unused"},
+	})
+
+	src := `cache:
+  expiration: 10m # how long entries stay fresh
+rate_limiter:
+  global:
+    rate: 10
+    burst: 10
+redis:
+  addr: "localhost:6379"
+`
+	out, report, err := MigrateConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, []KeyMigration{{From: "cache.expiration", To: "cache.ttl"}, {From: "rate_limiter.global", To: "limits.global"}}, report.Moved)
+	assert.Equal(t, []DeprecatedKey{{Key: "redis.addr", Note: "set REDIS_ADDR instead"}}, report.Deprecated)
+	assert.Empty(t, report.Conflicts)
+
+	var got map[string]map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(out, &got))
+	assert.Equal(t, "10m", got["cache"]["ttl"])
+	assert.NotContains(t, got["cache"], "expiration")
+	assert.Equal(t, map[string]interface{}{"rate": 10, "burst": 10}, got["limits"]["global"])
+	assert.Empty(t, got["rate_limiter"])
+	assert.Equal(t, "localhost:6379", got["redis"]["addr"], "deprecated keys are left in place")
+	assert.Contains(t, string(out), "ttl: 10m # how long entries stay fresh", "comments are kept")
+
+	// Migrating the result again changes nothing
+	again, report, err := MigrateConfig(out)
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+	assert.Equal(t, string(out), string(again))
+}
+
+func TestMigrateConfig_Conflicts(t *testing.T) {
+	withMigrations(t, []KeyMigration{
+		{From: "cache.expiration", To: "cache.ttl"},
+		{From: "server.port", To: "redis.addr.port"},
+	}, nil)
+
+	src := "cache:\n  expiration: 10m\n  ttl: 5m\nserver:\n  port: \"8080\"\nredis:\n  addr: localhost:6379\n"
+	out, report, err := MigrateConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Len(t, report.Conflicts, 2)
+	assert.False(t, report.Changed())
+	assert.Equal(t, src, string(out), "conflicting keys are left for the operator")
+}
+
+func TestMigrateConfig_CurrentLayout(t *testing.T) {
+	src := "# comment\nserver:\n  port: \"8080\"\n"
+	out, report, err := MigrateConfig([]byte(src))
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+	assert.Equal(t, src, string(out))
+
+	// The config.yaml shipped with the service is in the current layout
+	shipped, err := os.ReadFile("../../config.yaml")
+	require.NoError(t, err)
+	_, report, err = MigrateConfig(shipped)
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+	assert.Empty(t, report.Deprecated)
+
+	_, _, err = MigrateConfig([]byte("- not\n- a mapping\n"))
+	assert.Error(t, err)
+	_, _, err = MigrateConfig([]byte("server: [unclosed"))
+	assert.True(t, err != nil && strings.Contains(err.Error(), "parse config"))
+}
+
+func TestMigrateConfig_ServerTimeouts(t *testing.T) {
+	src := `server:
+  port: "8080"
+  read_header_timeout: 15s
+  read_timeout: 15s
+  write_timeout: 10s
+  idle_timeout: 30s
+  # in-flight requests get this long on SIGTERM
+  shutdown_timeout: 20s
+`
+	out, report, err := MigrateConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, keyMigrations, report.Moved)
+	assert.Empty(t, report.Deprecated, "moved keys are not left behind")
+	assert.Empty(t, report.Conflicts)
+
+	var got struct {
+		Server struct {
+			Port     string                 `yaml:"port"`
+			Timeouts map[string]string      `yaml:"timeouts"`
+			Rest     map[string]interface{} `yaml:",inline"`
+		} `yaml:"server"`
+	}
+	require.NoError(t, yaml.Unmarshal(out, &got))
+	assert.Equal(t, "8080", got.Server.Port)
+	assert.Equal(t, map[string]string{"read_header": "15s", "read": "15s", "write": "10s", "idle": "30s", "shutdown": "20s"}, got.Server.Timeouts)
+	assert.Empty(t, got.Server.Rest)
+	assert.Contains(t, string(out), "# in-flight requests get this long on SIGTERM\n    shutdown: 20s", "comments are kept")
+
+	// An operator who already added server.timeouts.write keeps both write keys to merge by hand
+	src = "server:\n  write_timeout: 10s\n  timeouts:\n    write: 5s\n"
+	_, report, err = MigrateConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, []KeyMigration{{From: "server.write_timeout", To: "server.timeouts.write"}}, report.Conflicts)
+	assert.Equal(t, []DeprecatedKey{{Key: "server.write_timeout", Note: "ignored once server.timeouts.write is set"}}, report.Deprecated)
+}
@@ -0,0 +1,111 @@
+// Package encoding is the registry of wire formats the API can answer in. Encoders are selected by
+// name from config or negotiated from a request's Accept header, so every transport shares the same
+// set of formats.
+package encoding
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder serializes response values into one wire format.
+type Encoder interface {
+	// Name is the short name used in config, e.g. "json".
+	Name() string
+	// ContentType is the media type written in the Content-Type header.
+	ContentType() string
+	// Append appends the encoding of v to dst.
+	Append(dst []byte, v interface{}) ([]byte, error)
+}
+
+var (
+	mu       sync.RWMutex
+	byName   = make(map[string]Encoder)
+	byMedia  = make(map[string]Encoder)
+	mediaFor = make(map[string][]string) // encoder name -> accepted media types
+)
+
+// Register makes e available under its name, its content type and any extra media type aliases.
+// Registering a name twice replaces the previous encoder.
+func Register(e Encoder, aliases ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	byName[e.Name()] = e
+	media := append([]string{e.ContentType()}, aliases...)
+	for _, m := range media {
+		byMedia[strings.ToLower(m)] = e
+	}
+	mediaFor[e.Name()] = media
+}
+
+// Lookup returns the encoder registered under name.
+func Lookup(name string) (Encoder, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := byName[name]
+	return e, ok
+}
+
+// Names returns the names of all registered encoders, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Negotiate picks the encoder that best matches an Accept header, honouring q-values. An empty
+// header, or wildcards, select fallback. ok is false when the header only lists media types that
+// no encoder supports (or that have q=0).
+func Negotiate(accept string, fallback Encoder) (e Encoder, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return fallback, true
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		media, q := parseMediaRange(part)
+		if q <= 0 || q <= bestQ {
+			continue
+		}
+		var candidate Encoder
+		switch {
+		case media == "*/*":
+			candidate = fallback
+		case strings.HasSuffix(media, "/*"):
+			if strings.HasPrefix(fallback.ContentType(), strings.TrimSuffix(media, "*")) {
+				candidate = fallback
+			}
+		default:
+			candidate = byMedia[media]
+		}
+		if candidate != nil {
+			e, bestQ = candidate, q
+		}
+	}
+	return e, e != nil
+}
+
+// parseMediaRange splits "type/subtype;q=0.5;charset=x" into the lower-cased media type and its
+// quality, which defaults to 1.
+func parseMediaRange(s string) (string, float64) {
+	media, params, _ := strings.Cut(s, ";")
+	q := 1.0
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, "q") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(media)), q
+}
@@ -0,0 +1,54 @@
+package encoding
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "json", true},
+		{"*/*", "json", true},
+		{"application/*", "json", true},
+		{"application/json", "json", true},
+		{"application/msgpack", "msgpack", true},
+		{"application/x-msgpack", "msgpack", true},
+		{"application/json;q=0.5, application/msgpack", "msgpack", true},
+		{"application/msgpack;q=0.2, application/json;q=0.9", "json", true},
+		{"text/html, application/msgpack;q=0.1", "msgpack", true},
+		{"text/html", "", false},
+		{"application/json;q=0", "", false},
+		{"Application/JSON; charset=utf-8", "json", true},
+	}
+	for _, tt := range tests {
+		e, ok := Negotiate(tt.accept, JSON)
+		if ok != tt.ok {
+			t.Errorf("Negotiate(%q) ok = %v, want %v", tt.accept, ok, tt.ok)
+			continue
+		}
+		if ok && e.Name() != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.accept, e.Name(), tt.want)
+		}
+	}
+}
+
+func TestLookupAndNames(t *testing.T) {
+	if e, ok := Lookup("msgpack"); !ok || e.ContentType() != "application/msgpack" {
+		t.Errorf("Expected msgpack encoder, got %v %v", e, ok)
+	}
+	if _, ok := Lookup("xml"); ok {
+		t.Error("Expected no xml encoder")
+	}
+	names := Names()
+	if len(names) < 2 || names[0] != "json" {
+		t.Errorf("Expected sorted names starting with json, got %v", names)
+	}
+}
+
+func TestJSON_Append(t *testing.T) {
+	got, err := JSON.Append([]byte("prefix:"), map[string]int{"a": 1})
+	if err != nil || string(got) != "prefix:{\"a\":1}\n" {
+		t.Errorf("Unexpected JSON output %q (%v)", got, err)
+	}
+}
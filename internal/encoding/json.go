@@ -0,0 +1,26 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSON is the default encoder. Output matches json.Encoder, including the trailing newline.
+var JSON Encoder = jsonEncoder{}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Name() string        { return "json" }
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Append(dst []byte, v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func init() {
+	Register(JSON)
+}
@@ -0,0 +1,76 @@
+package encoding
+
+import "errors"
+
+// maxCallbackLength caps JSONP callback names; real callbacks are far shorter.
+const maxCallbackLength = 64
+
+// ErrInvalidCallback is returned for JSONP callback names that are not plain JavaScript
+// identifiers or dotted paths of them.
+var ErrInvalidCallback = errors.New("invalid JSONP callback")
+
+// jsonpPrefix starts every JSONP body with an empty comment, so a response can never begin with
+// attacker-chosen bytes (the Rosetta Flash attack).
+var jsonpPrefix = []byte("/**/")
+
+// JSONP wraps JSON output in a call to a callback, for legacy clients that load responses with a
+// <script> tag because they cannot use CORS. It is never negotiated from Accept; the callback
+// comes from the request.
+type JSONP struct {
+	Callback string
+}
+
+// NewJSONP returns a JSONP encoder calling callback, which must pass ValidCallback.
+func NewJSONP(callback string) (JSONP, error) {
+	if !ValidCallback(callback) {
+		return JSONP{}, ErrInvalidCallback
+	}
+	return JSONP{Callback: callback}, nil
+}
+
+func (JSONP) Name() string        { return "jsonp" }
+func (JSONP) ContentType() string { return "application/javascript" }
+
+// Append writes /**/callback(<json>); followed by a newline.
+func (e JSONP) Append(dst []byte, v interface{}) ([]byte, error) {
+	dst = append(dst, jsonpPrefix...)
+	dst = append(dst, e.Callback...)
+	dst = append(dst, '(')
+	dst, err := JSON.Append(dst, v)
+	if err != nil {
+		return dst, err
+	}
+	// json.Encoder ends with a newline; the call closes before it
+	dst = dst[:len(dst)-1]
+	return append(dst, ");\n"...), nil
+}
+
+// ValidCallback reports whether name is a safe JSONP callback: ASCII identifiers made of letters,
+// digits, "_" and "$", not starting with a digit, optionally joined by single dots (e.g.
+// "jQuery123.cb"), and at most 64 characters long. Anything else, such as brackets, quotes or
+// parentheses, could inject script.
+func ValidCallback(name string) bool {
+	if name == "" || len(name) > maxCallbackLength {
+		return false
+	}
+	start := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '.':
+			if start {
+				return false
+			}
+			start = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '$':
+			start = false
+		case c >= '0' && c <= '9':
+			if start {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return !start
+}
@@ -0,0 +1,65 @@
+package encoding
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidCallback(t *testing.T) {
+	tests := map[string]bool{
+		"cb":                     true,
+		"jQuery1234_5678":        true,
+		"$widget.onWeather":      true,
+		"a.b.c":                  true,
+		"":                       false,
+		"1cb":                    false,
+		"cb.2":                   false,
+		".cb":                    false,
+		"cb.":                    false,
+		"a..b":                   false,
+		"cb()":                   false,
+		"alert(1);cb":            false,
+		"cb[0]":                  false,
+		"cb space":               false,
+		"ünïcode":                false,
+		string(make([]byte, 65)): false,
+	}
+	for name, want := range tests {
+		if got := ValidCallback(name); got != want {
+			t.Errorf("ValidCallback(%q) = %v, want %v", name, got, want)
+		}
+	}
+	long := "a"
+	for len(long) < maxCallbackLength {
+		long += "b"
+	}
+	if !ValidCallback(long) || ValidCallback(long+"c") {
+		t.Errorf("Expected callbacks of up to %d characters", maxCallbackLength)
+	}
+}
+
+func TestJSONP_Append(t *testing.T) {
+	e, err := NewJSONP("widget.show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Append([]byte("x"), map[string]string{"location": "</script>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// json.Encoder escapes <, > and & so the payload cannot close a surrounding script element
+	want := "x/**/widget.show({\"location\":\"\\u003c/script\\u003e\"});\n"
+	if string(got) != want {
+		t.Errorf("Append = %q, want %q", got, want)
+	}
+	if e.ContentType() != "application/javascript" {
+		t.Errorf("Unexpected content type %s", e.ContentType())
+	}
+
+	if _, err := NewJSONP("alert(1)"); !errors.Is(err, ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback, got %v", err)
+	}
+	if _, ok := Lookup("jsonp"); ok {
+		t.Error("Expected JSONP not to be registered for negotiation")
+	}
+}
@@ -0,0 +1,143 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MsgPack encodes values as MessagePack (https://msgpack.org). Values are first reduced to their
+// JSON data model, so struct tags and custom JSON marshalers apply and field names match the JSON
+// output. Map keys are written in sorted order.
+var MsgPack Encoder = msgpackEncoder{}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) Name() string        { return "msgpack" }
+func (msgpackEncoder) ContentType() string { return "application/msgpack" }
+
+func (msgpackEncoder) Append(dst []byte, v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return dst, err
+	}
+	return appendMsgPack(dst, generic)
+}
+
+// toGeneric converts v to maps, slices, strings, bools, json.Number and nil.
+func toGeneric(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func appendMsgPack(dst []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(dst, 0xc0), nil
+	case bool:
+		if t {
+			return append(dst, 0xc3), nil
+		}
+		return append(dst, 0xc2), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			return appendMsgPackInt(dst, i), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, 0xcb)
+		return binary.BigEndian.AppendUint64(dst, math.Float64bits(f)), nil
+	case string:
+		return appendMsgPackString(dst, t), nil
+	case []interface{}:
+		dst = appendMsgPackHeader(dst, len(t), 0x90, 0xdc)
+		for _, e := range t {
+			var err error
+			if dst, err = appendMsgPack(dst, e); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dst = appendMsgPackHeader(dst, len(t), 0x80, 0xde)
+		for _, k := range keys {
+			dst = appendMsgPackString(dst, k)
+			var err error
+			if dst, err = appendMsgPack(dst, t[k]); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	default:
+		return dst, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+// appendMsgPackHeader writes a fixarray/fixmap header (fix | n) for n < 16, else the 16- or 32-bit
+// form whose marker is wide and wide+1.
+func appendMsgPackHeader(dst []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(dst, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, wide), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, wide+1), uint32(n))
+	}
+}
+
+func appendMsgPackString(dst []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xda), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xdb), uint32(n))
+	}
+	return append(dst, s...)
+}
+
+func appendMsgPackInt(dst []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(dst, byte(i))
+	case i < 0 && i >= -32:
+		return append(dst, byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(dst, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xd1), uint16(int16(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xd2), uint32(int32(i)))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, 0xd3), uint64(i))
+	}
+}
+
+func init() {
+	Register(MsgPack, "application/x-msgpack", "application/vnd.msgpack")
+}
@@ -0,0 +1,69 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMsgPack_Append(t *testing.T) {
+	v := map[string]interface{}{
+		"a": 1,
+		"b": []interface{}{true, nil},
+		"c": "x",
+		"d": 1.5,
+	}
+	got, err := MsgPack.Append(nil, v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []byte{
+		0x84,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0x92, 0xc3, 0xc0,
+		0xa1, 'c', 0xa1, 'x',
+		0xa1, 'd', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Unexpected encoding\n got: % x\nwant: % x", got, want)
+	}
+}
+
+func TestMsgPack_Scalars(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want []byte
+	}{
+		{-1, []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{300, []byte{0xd1, 0x01, 0x2c}},
+		{70000, []byte{0xd2, 0x00, 0x01, 0x11, 0x70}},
+		{false, []byte{0xc2}},
+		{strings.Repeat("x", 40), append([]byte{0xd9, 40}, strings.Repeat("x", 40)...)},
+	}
+	for _, tt := range tests {
+		got, err := MsgPack.Append(nil, tt.v)
+		if err != nil {
+			t.Fatalf("Append(%v) failed: %v", tt.v, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("Append(%v) = % x, want % x", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestMsgPack_UsesJSONFieldNames(t *testing.T) {
+	type item struct {
+		FeelsLike float64 `json:"feels_like"`
+		Skip      string  `json:"-"`
+	}
+	got, err := MsgPack.Append(nil, item{FeelsLike: 2, Skip: "no"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := append([]byte{0x81, 0xaa}, "feels_like"...)
+	want = append(want, 0x02)
+	if !bytes.Equal(got, want) {
+		t.Errorf("Unexpected encoding % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/api/proto/weatherpb"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// FixedSchema is implemented by encoders whose field names come from a schema rather than the
+// JSON data model, so server.response_case does not apply to them.
+type FixedSchema interface {
+	FixedSchema()
+}
+
+// ErrNoSchema is returned by FixedSchema encoders for data their schema has no message for.
+// Handlers then respond in JSON instead.
+var ErrNoSchema = errors.New("no schema for the response data")
+
+// Protobuf encodes responses with the messages in api/proto/weather.proto: a model.Response
+// becomes a weather.v1.Envelope, a bare *model.WeatherResponse a weather.v1.WeatherResponse and a
+// bare *model.ForecastResponse a weather.v1.ForecastResponse. Other data fails with ErrNoSchema.
+var Protobuf Encoder = protobufEncoder{}
+
+type protobufEncoder struct{}
+
+func (protobufEncoder) Name() string        { return "protobuf" }
+func (protobufEncoder) ContentType() string { return "application/x-protobuf" }
+func (protobufEncoder) FixedSchema()        {}
+
+func (protobufEncoder) Append(dst []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case model.Response:
+		env, err := envelopeToProto(&t)
+		if err != nil {
+			return dst, err
+		}
+		return env.AppendTo(dst), nil
+	case *model.Response:
+		env, err := envelopeToProto(t)
+		if err != nil {
+			return dst, err
+		}
+		return env.AppendTo(dst), nil
+	case *model.WeatherResponse:
+		return weatherToProto(t).AppendTo(dst), nil
+	case *model.ForecastResponse:
+		return forecastToProto(t).AppendTo(dst), nil
+	default:
+		return dst, fmt.Errorf("protobuf: %w: %T", ErrNoSchema, v)
+	}
+}
+
+func weatherToProto(w *model.WeatherResponse) *weatherpb.WeatherResponse {
+	return &weatherpb.WeatherResponse{
+		Location:       w.Location,
+		Temperature:    w.Temperature,
+		Description:    w.Description,
+		Cached:         w.Cached,
+		Stale:          w.Stale,
+		ResolvedFrom:   w.ResolvedFrom,
+		UVIndex:        w.UVIndex,
+		FeelsLike:      w.FeelsLike,
+		TempMin:        w.TempMin,
+		TempMax:        w.TempMax,
+		Humidity:       w.Humidity,
+		Pressure:       w.Pressure,
+		WindSpeed:      w.WindSpeed,
+		WindDirection:  w.WindDirection,
+		Sunrise:        unixSeconds(w.Sunrise),
+		Sunset:         unixSeconds(w.Sunset),
+		DayLength:      w.DayLength,
+		TimezoneOffset: w.TimezoneOffset,
+		ObservedAt:     unixSeconds(w.ObservedAt),
+		Condition:      string(w.Condition),
+		IconURL:        w.IconURL,
+	}
+}
+
+func forecastToProto(f *model.ForecastResponse) *weatherpb.ForecastResponse {
+	pb := &weatherpb.ForecastResponse{Location: f.Location, Cached: f.Cached, Entries: make([]*weatherpb.ForecastEntry, len(f.Entries))}
+	for i, e := range f.Entries {
+		pb.Entries[i] = &weatherpb.ForecastEntry{Time: e.Time.Unix(), Temperature: e.Temperature, Description: e.Description}
+	}
+	return pb
+}
+
+// unixSeconds returns t in Unix seconds, or 0 when it is unset.
+func unixSeconds(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func envelopeToProto(resp *model.Response) (*weatherpb.Envelope, error) {
+	env := &weatherpb.Envelope{Warnings: resp.Warnings, Message: resp.Message, Suggestions: resp.Suggestions}
+	if q := resp.Quality; q != nil {
+		env.Quality = &weatherpb.Quality{Score: q.Score, Freshness: q.Freshness, ProviderHealth: q.ProviderHealth, Completeness: q.Completeness}
+	}
+	switch data := resp.Data.(type) {
+	case nil:
+	case *model.WeatherResponse:
+		env.Weather = weatherToProto(data)
+	case *model.ForecastResponse:
+		env.Forecast = forecastToProto(data)
+	default:
+		return nil, fmt.Errorf("protobuf: %w: %T", ErrNoSchema, resp.Data)
+	}
+	if resp.Error != nil {
+		env.Error = *resp.Error
+	}
+	for _, e := range resp.Errors {
+		env.Errors = append(env.Errors, &weatherpb.Error{
+			Code:     string(e.Code),
+			Provider: e.Provider,
+			Location: e.Location,
+			Message:  e.Message,
+		})
+	}
+	return env, nil
+}
+
+func init() {
+	Register(Protobuf, "application/protobuf", "application/vnd.google.protobuf")
+}
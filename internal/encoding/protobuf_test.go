@@ -0,0 +1,91 @@
+package encoding
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/api/proto/weatherpb"
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func TestProtobuf_Envelope(t *testing.T) {
+	errMsg := "Weather lookup failed"
+	resp := model.Response{
+		Data:        &model.WeatherResponse{Location: "London", Temperature: 15.2, Cached: true},
+		Error:       &errMsg,
+		Errors:      []*apperror.Error{{Code: apperror.CodeNotFound, Provider: "openweathermap", Location: "Atlantis", Message: "city not found"}},
+		Message:     "Error",
+		Suggestions: []string{"Athens"},
+	}
+	b, err := Protobuf.Append(nil, resp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var env weatherpb.Envelope
+	if err := env.Unmarshal(b); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if env.Weather == nil || env.Weather.Location != "London" || !env.Weather.Cached {
+		t.Errorf("Unexpected weather %+v", env.Weather)
+	}
+	if env.Error != errMsg || len(env.Errors) != 1 || env.Errors[0].Code != "not_found" || env.Message != "Error" || len(env.Suggestions) != 1 {
+		t.Errorf("Unexpected envelope %+v", env)
+	}
+}
+
+func TestProtobuf_BareWeatherAndUnsupported(t *testing.T) {
+	sunrise := time.Unix(1736928300, 0).In(time.FixedZone("", 3600))
+	offset := int64(3600)
+	b, err := Protobuf.Append(nil, &model.WeatherResponse{Location: "Oslo", Sunrise: &sunrise, DayLength: 23700, TimezoneOffset: &offset, ObservedAt: &sunrise,
+		Condition: model.ConditionClear, IconURL: "https://openweathermap.org/img/wn/01d@2x.png"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var w weatherpb.WeatherResponse
+	if err := w.Unmarshal(b); err != nil || w.Location != "Oslo" || w.Sunrise != 1736928300 || w.Sunset != 0 || w.DayLength != 23700 ||
+		w.TimezoneOffset == nil || *w.TimezoneOffset != 3600 || w.ObservedAt != 1736928300 ||
+		w.Condition != "clear" || w.IconURL != "https://openweathermap.org/img/wn/01d@2x.png" {
+		t.Errorf("Expected bare weather message, got %+v (%v)", w, err)
+	}
+
+	if _, err := Protobuf.Append(nil, map[string]int{"a": 1}); !errors.Is(err, ErrNoSchema) {
+		t.Errorf("Expected ErrNoSchema for a type without a schema, got %v", err)
+	}
+	if _, err := Protobuf.Append(nil, model.Response{Data: []string{"a"}}); !errors.Is(err, ErrNoSchema) {
+		t.Errorf("Expected ErrNoSchema for data without a schema, got %v", err)
+	}
+	if e, ok := Negotiate("application/protobuf", JSON); !ok || e != Protobuf {
+		t.Error("Expected application/protobuf to select the protobuf encoder")
+	}
+}
+
+func TestProtobuf_Forecast(t *testing.T) {
+	t0 := time.Unix(1717221600, 0)
+	forecast := &model.ForecastResponse{Location: "Jakarta", Cached: true, Entries: []model.ForecastEntry{
+		{Time: t0, Temperature: 28.4, Description: "light rain"},
+		{Time: t0.Add(3 * time.Hour), Temperature: 31.2, Description: "clouds"},
+	}}
+	b, err := Protobuf.Append(nil, model.Response{Data: forecast, Message: "Success"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var env weatherpb.Envelope
+	if err := env.Unmarshal(b); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	f := env.Forecast
+	if env.Weather != nil || f == nil || f.Location != "Jakarta" || !f.Cached || len(f.Entries) != 2 {
+		t.Fatalf("Expected the forecast in the envelope, got %+v", env)
+	}
+	if e := f.Entries[1]; e.Time != 1717232400 || e.Temperature != 31.2 || e.Description != "clouds" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	b, err = Protobuf.Append(nil, forecast)
+	var bare weatherpb.ForecastResponse
+	if err != nil || bare.Unmarshal(b) != nil || bare.Location != "Jakarta" || len(bare.Entries) != 2 {
+		t.Errorf("Expected bare forecast message, got %+v (%v)", bare, err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package events is an in-process publish/subscribe bus for things that happen in the service,
+// such as cache updates, so features like feeds and webhooks can react without coupling to the
+// repository.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// subscriberBuffer is how many undelivered events a subscriber may fall behind before new events
+// are dropped for it.
+const subscriberBuffer = 256
+
+// CacheUpdate is published whenever fresh weather for a location is fetched from the provider
+// and written to the cache.
+type CacheUpdate struct {
+	Location string
+	Weather  model.WeatherResponse
+	At       time.Time
+}
+
+// CacheUpdates carries every CacheUpdate.
+var CacheUpdates = NewBus[CacheUpdate]("cache_update")
+
+// Bus delivers published events to every subscriber. Each subscriber has its own goroutine and
+// buffer, so a slow subscriber never blocks the publisher or other subscribers.
+type Bus[T any] struct {
+	name string
+	mu   sync.RWMutex
+	subs map[*subscriber[T]]struct{}
+}
+
+type subscriber[T any] struct {
+	ch   chan T
+	done chan struct{}
+}
+
+// NewBus creates a Bus; name identifies it in logs.
+func NewBus[T any](name string) *Bus[T] {
+	return &Bus[T]{name: name, subs: make(map[*subscriber[T]]struct{})}
+}
+
+// Subscribe calls fn for every event published after it returns, in publish order. The returned
+// function unsubscribes and waits for fn to finish its current event.
+func (b *Bus[T]) Subscribe(fn func(T)) (unsubscribe func()) {
+	s := &subscriber[T]{ch: make(chan T, subscriberBuffer), done: make(chan struct{})}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+		for e := range s.ch {
+			fn(e)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, s)
+			close(s.ch)
+			b.mu.Unlock()
+			<-s.done
+		})
+	}
+}
+
+// Publish delivers e to every subscriber without blocking. Events for a subscriber whose buffer
+// is full are dropped.
+func (b *Bus[T]) Publish(e T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		select {
+		case s.ch <- e:
+		default:
+			logger().Warnw("Subscriber falling behind, dropping event", "bus", b.name)
+		}
+	}
+}
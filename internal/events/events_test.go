@@ -0,0 +1,58 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBus_DeliversInOrder(t *testing.T) {
+	bus := NewBus[int]("test")
+	var mu sync.Mutex
+	var got []int
+	unsubscribe := bus.Subscribe(func(i int) {
+		mu.Lock()
+		got = append(got, i)
+		mu.Unlock()
+	})
+	for i := 0; i < 10; i++ {
+		bus.Publish(i)
+	}
+	unsubscribe() // waits for delivery of everything already buffered
+
+	if len(got) != 10 {
+		t.Fatalf("Expected 10 events, got %v", got)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Errorf("Expected events in publish order, got %v", got)
+			break
+		}
+	}
+	bus.Publish(99)
+	if len(got) != 10 {
+		t.Error("Expected no delivery after unsubscribe")
+	}
+	unsubscribe() // idempotent
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus[int]("test")
+	release := make(chan struct{})
+	unsubscribe := bus.Subscribe(func(int) { <-release })
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			bus.Publish(i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+	close(release)
+	unsubscribe()
+}
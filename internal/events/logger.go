@@ -0,0 +1,11 @@
+package events
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the events module logger, whose level is set by logging.levels.events.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("events")
+}
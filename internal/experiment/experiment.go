@@ -0,0 +1,70 @@
+// Package experiment assigns clients to configured experiments, so a share of traffic can be
+// routed through an alternate code path and compared with the rest before a full rollout.
+package experiment
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// Variants are the alternate code paths an experiment can route requests through.
+const (
+	// AdaptiveTTL applies adaptive cache TTLs to the entries the request stores.
+	AdaptiveTTL = "adaptive_ttl"
+	// ProviderURL calls the provider at the experiment's API URL.
+	ProviderURL = "provider_url"
+)
+
+// Control is the ID and variant of requests outside every experiment.
+const Control = "control"
+
+// buckets is the resolution of experiment percentages: 0.01%.
+const buckets = 10000
+
+// Assignment is the experiment a request takes part in.
+type Assignment struct {
+	ID      string
+	Variant string
+	APIURL  string
+}
+
+// Assign places unit (a stable client identifier) in one of experiments, or in the control group.
+// Experiments take consecutive ranges of a hash of unit, so a client always gets the same
+// assignment while the configuration is unchanged, and takes part in at most one experiment.
+func Assign(experiments []config.Experiment, unit string) Assignment {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(unit))
+	bucket := float64(h.Sum32()%buckets) / buckets * 100
+	start := 0.0
+	for _, e := range experiments {
+		if bucket < start+e.Percent {
+			return Assignment{ID: e.ID, Variant: e.Variant, APIURL: e.APIURL}
+		}
+		start += e.Percent
+	}
+	return Assignment{ID: Control, Variant: Control}
+}
+
+type assignmentKey struct{}
+
+// WithAssignment returns a copy of ctx carrying a.
+func WithAssignment(ctx context.Context, a Assignment) context.Context {
+	return context.WithValue(ctx, assignmentKey{}, a)
+}
+
+// FromContext returns the assignment carried by ctx; requests outside experiments carry none.
+func FromContext(ctx context.Context) (Assignment, bool) {
+	if ctx == nil {
+		return Assignment{}, false
+	}
+	a, ok := ctx.Value(assignmentKey{}).(Assignment)
+	return a, ok
+}
+
+// VariantFrom returns the variant of the experiment the request takes part in, or "".
+func VariantFrom(ctx context.Context) string {
+	a, _ := FromContext(ctx)
+	return a.Variant
+}
@@ -0,0 +1,47 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestAssign(t *testing.T) {
+	experiments := []config.Experiment{
+		{ID: "ttl", Percent: 20, Variant: AdaptiveTTL},
+		{ID: "edge", Percent: 10, Variant: ProviderURL, APIURL: "https://edge.example.com"},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		unit := fmt.Sprintf("client-%d", i)
+		a := Assign(experiments, unit)
+		if a != Assign(experiments, unit) {
+			t.Fatalf("Expected a stable assignment for %s", unit)
+		}
+		if a.ID == "edge" && a.APIURL != "https://edge.example.com" {
+			t.Errorf("Expected the experiment's API URL, got %+v", a)
+		}
+		counts[a.ID]++
+	}
+	for id, want := range map[string]int{"ttl": 2000, "edge": 1000, Control: 7000} {
+		if got := counts[id]; got < want*8/10 || got > want*12/10 {
+			t.Errorf("Expected about %d clients in %s, got %d", want, id, got)
+		}
+	}
+
+	if a := Assign(nil, "client-1"); a.ID != Control || a.Variant != Control {
+		t.Errorf("Expected the control group without experiments, got %+v", a)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok || VariantFrom(context.Background()) != "" {
+		t.Error("Expected no assignment on a bare context")
+	}
+	ctx := WithAssignment(context.Background(), Assignment{ID: "ttl", Variant: AdaptiveTTL})
+	if a, ok := FromContext(ctx); !ok || a.ID != "ttl" || VariantFrom(ctx) != AdaptiveTTL {
+		t.Errorf("Expected the assignment back, got %+v", a)
+	}
+}
@@ -0,0 +1,75 @@
+// Package fanout calls a function for each of a list of items concurrently, a bounded number at
+// a time, and collects the results in the order of the items, so that batch lookups, warmups and
+// the like do not each keep their own worker pool.
+package fanout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Options bound a fan-out.
+type Options struct {
+	// Limit caps the calls in progress at once. Zero or less makes one call at a time.
+	Limit int
+	// Timeout bounds each call through its context. Zero leaves calls bounded by the parent
+	// context alone.
+	Timeout time.Duration
+}
+
+// Result is the outcome of the call for one item.
+type Result[R any] struct {
+	Value R
+	Err   error
+}
+
+// Map calls fn with each item, at most opts.Limit calls at a time, and waits for them all. The
+// results are in the order of items. Once ctx is done, no further call is started, and the items
+// left get ctx's error; calls in progress see it through their context.
+func Map[T, R any](ctx context.Context, items []T, opts Options, fn func(ctx context.Context, item T) (R, error)) []Result[R] {
+	results := make([]Result[R], len(items))
+	slots := make(chan struct{}, max(opts.Limit, 1))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		if err := acquire(ctx, slots); err != nil {
+			for j := i; j < len(items); j++ {
+				results[j].Err = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i].Value, results[i].Err = call(ctx, item, opts.Timeout, fn)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// acquire takes a slot, or returns ctx's error when ctx is done first.
+func acquire(ctx context.Context, slots chan struct{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// call calls fn with item, within timeout when it is set.
+func call[T, R any](ctx context.Context, item T, timeout time.Duration, fn func(context.Context, T) (R, error)) (R, error) {
+	if timeout <= 0 {
+		return fn(ctx, item)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx, item)
+}
@@ -0,0 +1,98 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMap_OrdersResults(t *testing.T) {
+	items := []int{5, 1, 4, 2, 3}
+	results := Map(context.Background(), items, Options{Limit: 3}, func(_ context.Context, n int) (string, error) {
+		// Later items finish first
+		time.Sleep(time.Duration(n) * time.Millisecond)
+		if n == 4 {
+			return "", errors.New("four")
+		}
+		return strconv.Itoa(n), nil
+	})
+	for i, n := range items {
+		if n == 4 {
+			if results[i].Err == nil {
+				t.Errorf("Expected the error of item %d", i)
+			}
+			continue
+		}
+		if results[i].Value != strconv.Itoa(n) || results[i].Err != nil {
+			t.Errorf("Expected %d at %d, got %+v", n, i, results[i])
+		}
+	}
+}
+
+func TestMap_BoundsConcurrency(t *testing.T) {
+	for _, limit := range []int{0, 1, 3} {
+		var running, peak, calls atomic.Int32
+		Map(context.Background(), make([]struct{}, 20), Options{Limit: limit}, func(context.Context, struct{}) (struct{}, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			calls.Add(1)
+			return struct{}{}, nil
+		})
+		if calls.Load() != 20 {
+			t.Errorf("Limit %d: expected 20 calls, got %d", limit, calls.Load())
+		}
+		if want := int32(max(limit, 1)); peak.Load() > want {
+			t.Errorf("Limit %d: expected at most %d calls at once, got %d", limit, want, peak.Load())
+		}
+	}
+}
+
+func TestMap_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	results := Map(ctx, make([]int, 5), Options{Limit: 1}, func(ctx context.Context, _ int) (int, error) {
+		if calls.Add(1) == 2 {
+			cancel()
+		}
+		return 0, ctx.Err()
+	})
+	if calls.Load() != 2 {
+		t.Errorf("Expected no call started after cancellation, got %d calls", calls.Load())
+	}
+	if results[0].Err != nil {
+		t.Errorf("Expected the first call to succeed, got %v", results[0].Err)
+	}
+	for i, r := range results[1:] {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("Expected item %d cancelled, got %v", i+1, r.Err)
+		}
+	}
+}
+
+func TestMap_TimesOutEachCall(t *testing.T) {
+	results := Map(context.Background(), []time.Duration{0, time.Second}, Options{Limit: 2, Timeout: 20 * time.Millisecond},
+		func(ctx context.Context, d time.Duration) (bool, error) {
+			select {
+			case <-time.After(d):
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		})
+	if !results[0].Value || results[0].Err != nil {
+		t.Errorf("Expected the quick call to succeed, got %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, context.DeadlineExceeded) {
+		t.Errorf("Expected the slow call to time out, got %+v", results[1])
+	}
+}
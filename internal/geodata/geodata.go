@@ -0,0 +1,238 @@
+// Package geodata bundles a compact city dataset used to validate and canonicalize locations
+// before any upstream call is made.
+package geodata
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLocationLength caps the length of a location in runes; real place names are far shorter.
+const maxLocationLength = 100
+
+// ErrInvalidLocation is returned for locations that cannot name a real place.
+var ErrInvalidLocation = errors.New("invalid location")
+
+// City is one entry of the embedded dataset.
+type City struct {
+	Name    string
+	Country string // ISO 3166-1 alpha-2
+	Lat     float64
+	Lon     float64
+	// ID is the OpenWeatherMap city ID.
+	ID int
+}
+
+//go:embed cities.csv
+var citiesCSV string
+
+var (
+	loadOnce sync.Once
+	cities   []City
+	byName   map[string][]int // normalized name -> indexes into cities
+)
+
+func load() {
+	r := csv.NewReader(strings.NewReader(citiesCSV))
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		panic("geodata: invalid cities.csv: " + err.Error())
+	}
+	byName = make(map[string][]int, len(records))
+	for _, rec := range records {
+		city, err := parseCity(rec)
+		if err != nil {
+			panic("geodata: invalid cities.csv: " + err.Error())
+		}
+		key := normalize(city.Name)
+		byName[key] = append(byName[key], len(cities))
+		cities = append(cities, city)
+	}
+}
+
+func parseCity(rec []string) (City, error) {
+	if len(rec) != 5 {
+		return City{}, fmt.Errorf("expected 5 fields, got %d", len(rec))
+	}
+	lat, err := strconv.ParseFloat(rec[2], 64)
+	if err != nil {
+		return City{}, err
+	}
+	lon, err := strconv.ParseFloat(rec[3], 64)
+	if err != nil {
+		return City{}, err
+	}
+	id, err := strconv.Atoi(rec[4])
+	if err != nil {
+		return City{}, err
+	}
+	return City{Name: rec[0], Country: rec[1], Lat: lat, Lon: lon, ID: id}, nil
+}
+
+// Cities returns every city in the dataset.
+func Cities() []City {
+	loadOnce.Do(load)
+	return cities
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// Distance returns the great-circle distance between a and b in kilometres.
+func Distance(a, b City) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLon := rad(b.Lat-a.Lat), rad(b.Lon-a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(a.Lat))*math.Cos(rad(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Lookup finds location, written as "<city>" or "<city>,<country code>", in the dataset. Matching
+// ignores case and surrounding or repeated whitespace.
+func Lookup(location string) (City, bool) {
+	loadOnce.Do(load)
+	name, country := splitLocation(location)
+	for _, i := range byName[normalize(name)] {
+		if country == "" || strings.EqualFold(cities[i].Country, country) {
+			return cities[i], true
+		}
+	}
+	return City{}, false
+}
+
+// Canonicalize validates location and returns the form used for caching and upstream calls.
+// Known cities get the dataset's spelling, keeping an explicit state and country code; other
+// locations are only trimmed, unless strict is set, in which case they are rejected. Every error
+// wraps ErrInvalidLocation.
+func Canonicalize(location string, strict bool) (string, error) {
+	location = strings.Join(strings.Fields(location), " ")
+	if err := validate(location); err != nil {
+		return "", err
+	}
+	city, ok := Lookup(location)
+	if !ok {
+		if strict {
+			return "", fmt.Errorf("%w: %q is not a known city", ErrInvalidLocation, location)
+		}
+		return location, nil
+	}
+	switch parts := strings.Split(location, ","); {
+	case len(parts) == 3:
+		// The dataset has no states, so the one given is kept
+		return city.Name + "," + strings.TrimSpace(parts[1]) + "," + city.Country, nil
+	case len(parts) == 2 && len(strings.TrimSpace(parts[1])) == 2:
+		return city.Name + "," + city.Country, nil
+	}
+	return city.Name, nil
+}
+
+// validate rejects locations that cannot be place names: empty, too long, without letters, with
+// more than the two commas of "city,state,country", or containing symbols or control characters.
+func validate(location string) error {
+	if location == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidLocation)
+	}
+	if utf8.RuneCountInString(location) > maxLocationLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidLocation, maxLocationLength)
+	}
+	letters := 0
+	for _, r := range location {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsMark(r), unicode.IsDigit(r), r == ' ', strings.ContainsRune("-'’.,()", r):
+		default:
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidLocation, r)
+		}
+	}
+	if letters == 0 {
+		return fmt.Errorf("%w: no letters", ErrInvalidLocation)
+	}
+	if strings.Count(location, ",") > 2 {
+		return fmt.Errorf("%w: too many commas", ErrInvalidLocation)
+	}
+	return nil
+}
+
+// splitLocation splits "London, GB" into "London" and "GB", and "Portland,OR,US" into "Portland"
+// and "US". The country is only recognized as a two-letter code in the last place.
+func splitLocation(location string) (name, country string) {
+	parts := strings.Split(location, ",")
+	country = strings.TrimSpace(parts[len(parts)-1])
+	if len(parts) == 1 || len(country) != 2 {
+		return strings.TrimSpace(location), ""
+	}
+	return strings.TrimSpace(parts[0]), country
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Suggest returns up to n dataset cities whose names are within a few typos of location, closest
+// first, for "did you mean" hints. A country code in location is ignored.
+func Suggest(location string, n int) []string {
+	loadOnce.Do(load)
+	name, _ := splitLocation(location)
+	query := []rune(normalize(name))
+	if len(query) == 0 || n <= 0 {
+		return nil
+	}
+	// Allow one edit per three characters, and at least two.
+	maxDistance := max(2, len(query)/3)
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range cities {
+		d := levenshtein(query, []rune(normalize(c.Name)))
+		if d > 0 && d <= maxDistance {
+			matches = append(matches, match{c.Name, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	suggestions := make([]string, 0, min(n, len(matches)))
+	for _, m := range matches {
+		if len(suggestions) == n {
+			break
+		}
+		if !slices.Contains(suggestions, m.name) {
+			suggestions = append(suggestions, m.name)
+		}
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
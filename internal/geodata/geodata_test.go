@@ -0,0 +1,129 @@
+package geodata
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCities(t *testing.T) {
+	seen := map[int]bool{}
+	for _, c := range Cities() {
+		if c.Name == "" || len(c.Country) != 2 || c.ID == 0 || c.Lat < -90 || c.Lat > 90 || c.Lon < -180 || c.Lon > 180 {
+			t.Errorf("Invalid city %+v", c)
+		}
+		if seen[c.ID] {
+			t.Errorf("Duplicate ID %d", c.ID)
+		}
+		seen[c.ID] = true
+	}
+	if len(seen) < 100 {
+		t.Errorf("Expected at least 100 cities, got %d", len(seen))
+	}
+}
+
+func TestLookup(t *testing.T) {
+	city, ok := Lookup("  new   YORK ")
+	if !ok || city.Name != "New York" || city.Country != "US" || city.ID != 5128581 {
+		t.Errorf("Unexpected lookup result %+v, %v", city, ok)
+	}
+	if _, ok := Lookup("London,gb"); !ok {
+		t.Error("Expected London,gb to match")
+	}
+	if _, ok := Lookup("London,FR"); ok {
+		t.Error("Expected London,FR not to match")
+	}
+	if _, ok := Lookup("Atlantis"); ok {
+		t.Error("Expected Atlantis not to match")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	london, _ := Lookup("London")
+	paris, _ := Lookup("Paris")
+	if d := Distance(london, paris); d < 335 || d > 350 {
+		t.Errorf("Expected London-Paris to be about 343km, got %.1f", d)
+	}
+	if d := Distance(paris, paris); d != 0 {
+		t.Errorf("Expected no distance to the same city, got %v", d)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		location string
+		strict   bool
+		want     string
+		wantErr  bool
+	}{
+		{"london", false, "London", false},
+		{" makassar , id ", false, "Makassar,ID", false},
+		{"Springfield", false, "Springfield", false},
+		{"Springfield", true, "", true},
+		{"São  Tomé", false, "São Tomé", false},
+		{"Val-d'Or", false, "Val-d'Or", false},
+		{"12345", false, "", true},
+		{"<script>", false, "", true},
+		{"   ", false, "", true},
+		{"Portland,OR,US", false, "Portland,OR,US", false},
+		{"boston, MA, us", true, "Boston,MA,US", false},
+		{"Boston,MA,GB", true, "", true},
+		{"10 Downing Street,London", false, "10 Downing Street,London", false},
+		{"a,b,c,d", false, "", true},
+		{strings.Repeat("a", 101), false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			got, err := Canonicalize(tt.location, tt.strict)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Canonicalize(%q, %v) = %q, %v; want %q", tt.location, tt.strict, got, err, tt.want)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidLocation) {
+				t.Errorf("Expected ErrInvalidLocation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		location string
+		want     []string
+	}{
+		{"Makasar", []string{"Makassar"}},
+		{"londn,GB", []string{"London"}},
+		{"Tokio", []string{"Tokyo"}},
+		{"Pari", []string{"Paris"}},
+		{"Sidney", []string{"Sydney"}},
+		{"London", nil},
+		{"Xqzvbnm", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			got := Suggest(tt.location, 5)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Suggest(%q) = %v, want %v", tt.location, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Suggest(%q) = %v, want %v", tt.location, got, tt.want)
+				}
+			}
+		})
+	}
+	if got := Suggest("Bandun", 1); len(got) != 1 {
+		t.Errorf("Expected the limit applied, got %v", got)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{{"", "abc", 3}, {"kitten", "sitting", 3}, {"São", "Sao", 1}, {"same", "same", 0}} {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package geoip locates client IP addresses with a MaxMind DB (.mmdb) file, such as GeoLite2
+// City, so requests without a location can default to the caller's approximate city. The reader
+// is self-contained: it understands the MaxMind DB format without a third-party dependency.
+package geoip
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// ErrNotFound is returned for addresses the database has no place for, e.g. private ones.
+var ErrNotFound = errors.New("geoip: address not found")
+
+// Place is where an address is located.
+type Place struct {
+	// City is the English city name, empty when the database only knows the country.
+	City string
+	// Country is the ISO 3166-1 alpha-2 code.
+	Country string
+	Lat     float64
+	Lon     float64
+	// HasCoordinates reports whether Lat and Lon are known.
+	HasCoordinates bool
+}
+
+// Locator locates IP addresses.
+type Locator interface {
+	Locate(addr netip.Addr) (Place, error)
+}
+
+// DB is an opened MaxMind DB. It is safe for concurrent use.
+type DB struct {
+	reader *reader
+}
+
+// Open reads the database at path into memory.
+func Open(path string) (*DB, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse reads a database from its bytes.
+func Parse(b []byte) (*DB, error) {
+	r, err := newReader(b)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: r}, nil
+}
+
+// Locate returns the place of addr from a City or Country database.
+func (db *DB) Locate(addr netip.Addr) (Place, error) {
+	record, err := db.reader.lookup(addr)
+	if err != nil {
+		return Place{}, err
+	}
+	fields, ok := record.(map[string]any)
+	if !ok {
+		return Place{}, ErrNotFound
+	}
+	var place Place
+	place.City, _ = path(fields, "city", "names", "en").(string)
+	place.Country, _ = path(fields, "country", "iso_code").(string)
+	lat, latOK := path(fields, "location", "latitude").(float64)
+	lon, lonOK := path(fields, "location", "longitude").(float64)
+	if latOK && lonOK {
+		place.Lat, place.Lon, place.HasCoordinates = lat, lon, true
+	}
+	if place.City == "" && place.Country == "" && !place.HasCoordinates {
+		return Place{}, ErrNotFound
+	}
+	return place, nil
+}
+
+// path follows keys through nested maps, returning nil when one is missing.
+func path(v any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+var (
+	configuredOnce sync.Once
+	configured     Locator
+)
+
+// Configured returns the database at geoip.database, opened once, or nil when none is configured
+// or it cannot be opened. GeoIP is a soft dependency: a missing database only disables it.
+func Configured() Locator {
+	configuredOnce.Do(func() {
+		dbPath := config.GetGeoIPConfig().Database
+		if dbPath == "" {
+			return
+		}
+		db, err := Open(dbPath)
+		if err != nil {
+			logger().Warnw("GeoIP database unavailable, locations will not default to the caller's", "path", dbPath, "error", err)
+			return
+		}
+		logger().Infow("GeoIP database loaded", "path", dbPath)
+		configured = db
+	})
+	return configured
+}
@@ -0,0 +1,271 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testNode is a search tree node of testDB; leaves hold the data offset of their record.
+type testNode struct {
+	children [2]*testNode
+	leaf     bool
+	offset   int
+	index    int
+}
+
+// testDB writes a MaxMind DB with an IPv6 tree mapping each prefix to a record. A record may be
+// a testPointer, encoded as a pointer to a value written once before the records.
+type testDB struct {
+	recordSize int
+	shared     map[string]any
+	records    map[string]map[string]any
+}
+
+type testPointer string
+
+func (db testDB) bytes(t *testing.T) []byte {
+	t.Helper()
+	var data []byte
+	sharedAt := map[string]int{}
+	for name, v := range db.shared {
+		sharedAt[name] = len(data)
+		data = appendValue(data, v, sharedAt)
+	}
+
+	root := &testNode{}
+	prefixes := make([]string, 0, len(db.records))
+	for p := range db.records {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		prefix := netip.MustParsePrefix(p)
+		addr, bits := prefix.Addr().As16(), prefix.Bits()
+		if prefix.Addr().Is4() {
+			// IPv4 addresses live under ::a.b.c.d in an IPv6 tree.
+			a4 := prefix.Addr().As4()
+			addr = [16]byte{}
+			copy(addr[12:], a4[:])
+			bits += 96
+		}
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &testNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.offset = len(data)
+		data = appendValue(data, db.records[p], sharedAt)
+	}
+
+	var nodes []*testNode
+	var number func(n *testNode)
+	number = func(n *testNode) {
+		if n == nil || n.leaf {
+			return
+		}
+		n.index = len(nodes)
+		nodes = append(nodes, n)
+		number(n.children[0])
+		number(n.children[1])
+	}
+	number(root)
+
+	nodeCount := len(nodes)
+	recordValue := func(n *testNode) uint32 {
+		switch {
+		case n == nil:
+			return uint32(nodeCount)
+		case n.leaf:
+			return uint32(nodeCount + dataSectionSeparator + n.offset)
+		default:
+			return uint32(n.index)
+		}
+	}
+	var out []byte
+	for _, n := range nodes {
+		l, r := recordValue(n.children[0]), recordValue(n.children[1])
+		switch db.recordSize {
+		case 24:
+			out = append(out, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			out = append(out, byte(l>>16), byte(l>>8), byte(l), byte(l>>24)<<4|byte(r>>24)&0x0F, byte(r>>16), byte(r>>8), byte(r))
+		default:
+			out = binary.BigEndian.AppendUint32(out, l)
+			out = binary.BigEndian.AppendUint32(out, r)
+		}
+	}
+	out = append(out, make([]byte, dataSectionSeparator)...)
+	out = append(out, data...)
+	out = append(out, metadataMarker...)
+	return appendValue(out, map[string]any{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(db.recordSize),
+		"ip_version":    uint16(6),
+		"database_type": "GeoLite2-City",
+	}, nil)
+}
+
+func appendControl(b []byte, typ, size int) []byte {
+	sizeBits, ext := size, -1
+	if size >= 29 {
+		sizeBits, ext = 29, size-29
+	}
+	if typ < 8 {
+		b = append(b, byte(typ<<5|sizeBits))
+	} else {
+		b = append(b, byte(sizeBits), byte(typ-7))
+	}
+	if ext >= 0 {
+		b = append(b, byte(ext))
+	}
+	return b
+}
+
+func appendValue(b []byte, v any, shared map[string]int) []byte {
+	switch v := v.(type) {
+	case testPointer:
+		ptr := shared[string(v)]
+		return append(b, byte(typePointer<<5|ptr>>8&0x7), byte(ptr))
+	case string:
+		return append(appendControl(b, typeString, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(appendControl(b, typeDouble, 8), math.Float64bits(v))
+	case float32:
+		return binary.BigEndian.AppendUint32(appendControl(b, typeFloat, 4), math.Float32bits(v))
+	case uint16:
+		return binary.BigEndian.AppendUint16(appendControl(b, typeUint16, 2), v)
+	case uint32:
+		return binary.BigEndian.AppendUint32(appendControl(b, typeUint32, 4), v)
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		return appendControl(b, typeBool, n)
+	case []any:
+		b = appendControl(b, typeArray, len(v))
+		for _, e := range v {
+			b = appendValue(b, e, shared)
+		}
+		return b
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendControl(b, typeMap, len(v))
+		for _, k := range keys {
+			b = appendValue(b, k, shared)
+			b = appendValue(b, v[k], shared)
+		}
+		return b
+	default:
+		panic("unsupported test value")
+	}
+}
+
+func cityRecord(city, country string, lat, lon float64) map[string]any {
+	return map[string]any{
+		"city":     map[string]any{"names": map[string]any{"en": city, "id": city}},
+		"country":  map[string]any{"iso_code": country, "is_in_european_union": false},
+		"location": map[string]any{"latitude": lat, "longitude": lon, "accuracy_radius": uint16(20)},
+		"subdivisions": []any{
+			map[string]any{"iso_code": "XX"},
+		},
+	}
+}
+
+func testDatabase(recordSize int) testDB {
+	return testDB{
+		recordSize: recordSize,
+		shared: map[string]any{
+			"indonesia": map[string]any{"iso_code": "ID"},
+		},
+		records: map[string]map[string]any{
+			"203.0.113.0/24":  cityRecord("Jakarta", "ID", -6.2146, 106.8451),
+			"198.51.100.0/25": cityRecord("London", "GB", 51.5085, -0.1257),
+			"2001:db8::/32":   cityRecord("Paris", "FR", 48.8534, 2.3488),
+			"192.0.2.0/24": {
+				"country":  testPointer("indonesia"),
+				"location": map[string]any{"latitude": float32(-2.5), "longitude": float32(118)},
+			},
+		},
+	}
+}
+
+func TestDB_Locate(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		db, err := Parse(testDatabase(recordSize).bytes(t))
+		if err != nil {
+			t.Fatalf("record size %d: Expected the database to parse, got %v", recordSize, err)
+		}
+		tests := []struct {
+			addr string
+			want Place
+		}{
+			{"203.0.113.7", Place{City: "Jakarta", Country: "ID", Lat: -6.2146, Lon: 106.8451, HasCoordinates: true}},
+			{"::ffff:203.0.113.200", Place{City: "Jakarta", Country: "ID", Lat: -6.2146, Lon: 106.8451, HasCoordinates: true}},
+			{"198.51.100.1", Place{City: "London", Country: "GB", Lat: 51.5085, Lon: -0.1257, HasCoordinates: true}},
+			{"2001:db8:1::1", Place{City: "Paris", Country: "FR", Lat: 48.8534, Lon: 2.3488, HasCoordinates: true}},
+			{"192.0.2.10", Place{Country: "ID", Lat: -2.5, Lon: 118, HasCoordinates: true}},
+		}
+		for _, tt := range tests {
+			got, err := db.Locate(netip.MustParseAddr(tt.addr))
+			if err != nil {
+				t.Errorf("record size %d: Expected %s to be located, got %v", recordSize, tt.addr, err)
+				continue
+			}
+			if got != tt.want {
+				t.Errorf("record size %d: Expected %s to be at %+v, got %+v", recordSize, tt.addr, tt.want, got)
+			}
+		}
+		for _, addr := range []string{"198.51.100.200", "10.0.0.1", "2001:db9::1", "::1"} {
+			if _, err := db.Locate(netip.MustParseAddr(addr)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("record size %d: Expected %s not to be found, got %v", recordSize, addr, err)
+			}
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	if _, err := Parse([]byte("not a database")); err == nil {
+		t.Error("Expected a file without metadata to be rejected")
+	}
+	b := testDatabase(24).bytes(t)
+	if _, err := Parse(b[len(b)/2:]); err == nil {
+		t.Error("Expected a truncated database to be rejected")
+	}
+	bad := append([]byte{}, metadataMarker...)
+	bad = appendValue(bad, map[string]any{"node_count": uint32(1), "record_size": uint16(20), "ip_version": uint16(6)}, nil)
+	if _, err := Parse(bad); err == nil {
+		t.Error("Expected an unsupported record size to be rejected")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	if err := os.WriteFile(path, testDatabase(28).bytes(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected the database to open, got %v", err)
+	}
+	if place, err := db.Locate(netip.MustParseAddr("203.0.113.1")); err != nil || place.City != "Jakarta" {
+		t.Errorf("Expected Jakarta, got %+v, %v", place, err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Expected a missing database to fail to open")
+	}
+}
@@ -0,0 +1,11 @@
+package geoip
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the geoip module logger, whose level is set by logging.levels.geoip.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("geoip")
+}
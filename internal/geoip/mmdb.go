@@ -0,0 +1,314 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+)
+
+// The MaxMind DB format is a binary search tree over the address bits, followed by a data section
+// holding the records, followed by metadata; see https://maxmind.github.io/MaxMind-DB/.
+
+// metadataMarker starts the metadata section, within the last 128KiB of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the size of the zeroes between the search tree and the data section.
+const dataSectionSeparator = 16
+
+// maxDepth bounds the nesting of decoded values, against corrupt or malicious files.
+const maxDepth = 32
+
+// Data section field types.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+var errCorrupt = errors.New("geoip: corrupt database")
+
+type reader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 addresses start from in an IPv6 tree, after the 96 zero bits of
+	// ::a.b.c.d.
+	ipv4Start uint
+}
+
+func newReader(b []byte) (*reader, error) {
+	start := bytes.LastIndex(b, metadataMarker)
+	if start < 0 {
+		return nil, errors.New("geoip: not a MaxMind DB file")
+	}
+	meta, _, err := decode(b[start+len(metadataMarker):], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: invalid metadata: %w", err)
+	}
+	fields, ok := meta.(map[string]any)
+	if !ok {
+		return nil, errors.New("geoip: invalid metadata")
+	}
+	uintField := func(name string) uint {
+		v, _ := fields[name].(uint64)
+		return uint(v)
+	}
+	r := &reader{nodeCount: uintField("node_count"), recordSize: uintField("record_size"), ipVersion: uintField("ip_version")}
+	switch {
+	case r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32:
+		return nil, fmt.Errorf("geoip: unsupported record size %d", r.recordSize)
+	case r.ipVersion != 4 && r.ipVersion != 6:
+		return nil, fmt.Errorf("geoip: unsupported IP version %d", r.ipVersion)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, errCorrupt
+	}
+	r.tree = b[:treeSize]
+	r.data = b[treeSize+dataSectionSeparator : start]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *reader) record(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		o := node*6 + bit*3
+		return uint(r.tree[o])<<16 | uint(r.tree[o+1])<<8 | uint(r.tree[o+2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			return uint(r.tree[o+3]&0xF0)<<20 | uint(r.tree[o])<<16 | uint(r.tree[o+1])<<8 | uint(r.tree[o+2])
+		}
+		return uint(r.tree[o+3]&0x0F)<<24 | uint(r.tree[o+4])<<16 | uint(r.tree[o+5])<<8 | uint(r.tree[o+6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+// lookup walks the search tree along addr's bits and decodes the record it ends at.
+func (r *reader) lookup(addr netip.Addr) (any, error) {
+	if !addr.IsValid() {
+		return nil, ErrNotFound
+	}
+	addr = addr.Unmap()
+	var bits []byte
+	node := uint(0)
+	if addr.Is4() {
+		b := addr.As4()
+		bits = b[:]
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.ipVersion == 4 {
+			return nil, ErrNotFound
+		}
+		b := addr.As16()
+		bits = b[:]
+	}
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, uint(bits[i/8]>>(7-i%8)&1))
+	}
+	if node == r.nodeCount {
+		return nil, ErrNotFound
+	}
+	if node < r.nodeCount {
+		return nil, errCorrupt
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, errCorrupt
+	}
+	value, _, err := decode(r.data, offset, 0)
+	return value, err
+}
+
+// decode decodes the value at offset of section, returning it and the offset after it. Maps
+// decode to map[string]any, arrays to []any, unsigned integers to uint64, except uint128 to
+// *big.Int, int32 to int64, and floats to float64.
+func decode(section []byte, offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errCorrupt
+	}
+	if offset >= uint(len(section)) {
+		return nil, 0, errCorrupt
+	}
+	ctrl := section[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == typePointer {
+		ptr, next, err := decodePointer(section, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decode(section, ptr, depth+1)
+		return value, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(section)) {
+			return nil, 0, errCorrupt
+		}
+		typ = 7 + uint(section[offset])
+		offset++
+	}
+	size, offset, err := decodeSize(section, ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			var key, value any
+			if key, offset, err = decode(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			if value, offset, err = decode(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for i := uint(0); i < size; i++ {
+			var value any
+			if value, offset, err = decode(section, offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(section)) || end < offset {
+		return nil, 0, errCorrupt
+	}
+	b := section[offset:end]
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return bytes.Clone(b), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errCorrupt
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errCorrupt
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), end, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, errCorrupt
+		}
+		return new(big.Int).SetBytes(b), end, nil
+	default:
+		return nil, 0, errCorrupt
+	}
+}
+
+// decodeSize reads the payload size from the control byte and the extension bytes after it.
+func decodeSize(section []byte, ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(section)) {
+		return 0, 0, errCorrupt
+	}
+	var ext uint
+	for _, c := range section[offset : offset+n] {
+		ext = ext<<8 | uint(c)
+	}
+	switch n {
+	case 1:
+		size = 29 + ext
+	case 2:
+		size = 285 + ext
+	default:
+		size = 65821 + ext
+	}
+	return size, offset + n, nil
+}
+
+// decodePointer reads a pointer into the data section, returning its target and the offset after
+// the pointer itself.
+func decodePointer(section []byte, ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	if offset+n > uint(len(section)) {
+		return 0, 0, errCorrupt
+	}
+	var ptr uint
+	if n < 4 {
+		ptr = uint(ctrl & 0x7)
+	}
+	for _, c := range section[offset : offset+n] {
+		ptr = ptr<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		ptr += 2048
+	case 3:
+		ptr += 526336
+	}
+	return ptr, offset + n, nil
+}
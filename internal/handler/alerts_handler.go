@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// AlertsHandler serves the government weather alerts for a location.
+type AlertsHandler struct {
+	Alerts repository.AlertsRepository
+}
+
+func NewAlertsHandler(alerts ...repository.AlertsRepository) *AlertsHandler {
+	if len(alerts) > 0 && alerts[0] != nil {
+		return &AlertsHandler{Alerts: alerts[0]}
+	}
+	return &AlertsHandler{Alerts: repository.NewAlertsRepository()}
+}
+
+// HandleAlerts serves GET /alerts?location=Oslo, the alerts in force or announced for the
+// location, soonest first. A location without alerts gets an empty list.
+func (h *AlertsHandler) HandleAlerts(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	location := r.URL.Query().Get("location")
+	if location == "" {
+		errMsg := "Missing 'location' query parameter"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	alerts, err := h.Alerts.GetAlerts(r.Context(), location)
+	if err != nil {
+		var failures apperror.Multi
+		failures.Add(forecastErrorCode(err), "", location, err)
+		writeProviderErrors(w, r, &failures)
+		return
+	}
+	if alerts.Cached {
+		w.Header().Set(cacheHeader, "HIT")
+	} else {
+		w.Header().Set(cacheHeader, "MISS")
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: alerts, Message: "Success"})
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockAlertsRepository struct {
+	alerts []model.WeatherAlert
+	cached bool
+	err    error
+}
+
+func (m *mockAlertsRepository) GetAlerts(_ context.Context, location string) (*model.AlertsResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.AlertsResponse{Location: location, Alerts: m.alerts, Cached: m.cached}, nil
+}
+
+func TestHandleAlerts(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := NewAlertsHandler(&mockAlertsRepository{alerts: []model.WeatherAlert{
+		{Event: "Orange avalanche warning", Severity: model.SeveritySevere, Start: start, End: start.Add(24 * time.Hour), Description: "Considerable avalanche danger."},
+	}, cached: true})
+
+	w := httptest.NewRecorder()
+	h.HandleAlerts(w, httptest.NewRequest(http.MethodGet, "/alerts?location=Oslo", nil))
+	if w.Code != http.StatusOK || w.Header().Get(cacheHeader) != "HIT" {
+		t.Fatalf("Expected a cached 200, got %d %q: %s", w.Code, w.Header().Get(cacheHeader), w.Body)
+	}
+	var resp struct {
+		Data model.AlertsResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if resp.Data.Location != "Oslo" || len(resp.Data.Alerts) != 1 || resp.Data.Alerts[0].Severity != model.SeveritySevere || !resp.Data.Alerts[0].End.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("Unexpected alerts %+v", resp.Data)
+	}
+}
+
+func TestHandleAlerts_Errors(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewAlertsHandler(&mockAlertsRepository{}).HandleAlerts(w, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a location, got %d", w.Code)
+	}
+
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{&repository.LocationNotFoundError{Message: "city not found"}, http.StatusNotFound},
+		{&repository.CoolDownError{RetryAfter: 10 * time.Second}, http.StatusServiceUnavailable},
+		{repository.ErrExternalAPI, http.StatusBadGateway},
+	} {
+		w := httptest.NewRecorder()
+		NewAlertsHandler(&mockAlertsRepository{err: tt.err}).HandleAlerts(w, httptest.NewRequest(http.MethodGet, "/alerts?location=Atlantis", nil))
+		if w.Code != tt.want {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.want, w.Code)
+		}
+	}
+}
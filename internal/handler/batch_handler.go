@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+// maxBatchBody caps the size of batch request bodies.
+const maxBatchBody = 16 << 10
+
+// batchResultBytes is about the encoded size of one batch result with every detail, to size the
+// response buffer by.
+const batchResultBytes = 640
+
+// BatchHandler serves the weather of several locations in one request.
+type BatchHandler struct {
+	Service      service.BatchWeatherService
+	MaxLocations int
+}
+
+func NewBatchHandler(svc ...service.BatchWeatherService) *BatchHandler {
+	h := &BatchHandler{MaxLocations: config.GetBatchConfig().MaxLocations}
+	if len(svc) > 0 && svc[0] != nil {
+		h.Service = svc[0]
+	} else {
+		h.Service = service.New()
+	}
+	return h
+}
+
+// batchRequest is the body of POST /weather/batch.
+type batchRequest struct {
+	Locations []string `json:"locations"`
+}
+
+// batchResult is the outcome of one location of a batch; exactly one of Weather and Error is set.
+type batchResult struct {
+	Location string                 `json:"location"`
+	Weather  *model.WeatherResponse `json:"weather,omitempty"`
+	Error    *apperror.Error        `json:"error,omitempty"`
+}
+
+// batchResponse is the response of /weather/batch.
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// HandleBatch serves GET /weather/batch?locations=Jakarta,Bandung,Surabaya and POST /weather/batch
+// with {"locations": ["Jakarta", "Bandung"]}. Results are in the order requested, each with its
+// weather or its own error, so one failed location does not fail the others.
+func (h *BatchHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet, http.MethodPost) {
+		return
+	}
+	var locations []string
+	if r.Method == http.MethodPost {
+		var req batchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+			errMsg := "Invalid JSON body"
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		locations = req.Locations
+	} else if raw := r.URL.Query().Get("locations"); raw != "" {
+		locations = strings.Split(raw, ",")
+	}
+	if len(locations) == 0 {
+		errMsg := "'locations' must list at least one location"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if len(locations) > h.MaxLocations {
+		errMsg := "'locations' may list at most " + strconv.Itoa(h.MaxLocations) + " locations"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	for i := range locations {
+		locations[i] = strings.TrimSpace(locations[i])
+	}
+
+	resp := batchResponse{Results: make([]batchResult, 0, len(locations))}
+	var failures apperror.Multi
+	for _, result := range h.Service.GetWeatherBatch(r.Context(), locations) {
+		res := batchResult{Location: result.Location, Weather: result.Weather}
+		if result.Err != nil {
+			res.Weather = nil
+			res.Error = apperror.New(weatherErrorCode(result.Err), "", result.Location, result.Err)
+			failures.Errors = append(failures.Errors, res.Error)
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	if failures.Len() > 0 {
+		logger(r.Context()).Debugw("Batch locations failed", "errors", &failures)
+	}
+	writeSizedResponse(w, http.StatusOK, model.Response{Data: resp, Message: "Success"}, len(resp.Results)*batchResultBytes)
+}
+
+// weatherErrorCode classifies a failed weather lookup.
+func weatherErrorCode(err error) apperror.Code {
+	var notFound *repository.LocationNotFoundError
+	var coolDown *repository.CoolDownError
+	switch {
+	case errors.Is(err, geodata.ErrInvalidLocation), errors.Is(err, service.ErrRejected):
+		return apperror.CodeInvalidInput
+	case errors.As(err, &notFound), errors.Is(err, repository.ErrLocationNotFound), errors.Is(err, repository.ErrCacheOnlyMiss):
+		return apperror.CodeNotFound
+	case errors.As(err, &coolDown):
+		return apperror.CodeUnavailable
+	case errors.Is(err, repository.ErrAPIKeyMissing):
+		return apperror.CodeAPIKeyMissing
+	default:
+		return apperror.CodeUpstream
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+// mockBatchService has weather for every location but Atlantis.
+type mockBatchService struct {
+	requested []string
+}
+
+func (m *mockBatchService) GetWeatherBatch(_ context.Context, locations []string) []service.BatchResult {
+	m.requested = locations
+	results := make([]service.BatchResult, len(locations))
+	for i, location := range locations {
+		results[i].Location = location
+		if location == "Atlantis" {
+			results[i].Err = &repository.LocationNotFoundError{Message: "city not found"}
+			continue
+		}
+		results[i].Weather = &model.WeatherResponse{Location: location, Temperature: 25}
+	}
+	return results
+}
+
+func decodeBatch(t *testing.T, w *httptest.ResponseRecorder) batchResponse {
+	t.Helper()
+	var resp struct {
+		Data batchResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	return resp.Data
+}
+
+func TestHandleBatch(t *testing.T) {
+	for name, req := range map[string]*http.Request{
+		"GET":  httptest.NewRequest(http.MethodGet, "/weather/batch?locations=Jakarta,%20Atlantis,Bandung", nil),
+		"POST": httptest.NewRequest(http.MethodPost, "/weather/batch", strings.NewReader(`{"locations": ["Jakarta", "Atlantis", "Bandung"]}`)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc := &mockBatchService{}
+			w := httptest.NewRecorder()
+			(&BatchHandler{Service: svc, MaxLocations: 3}).HandleBatch(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+			}
+			if strings.Join(svc.requested, "|") != "Jakarta|Atlantis|Bandung" {
+				t.Errorf("Unexpected locations %q", svc.requested)
+			}
+			results := decodeBatch(t, w).Results
+			if len(results) != 3 {
+				t.Fatalf("Expected 3 results, got %+v", results)
+			}
+			if results[0].Weather == nil || results[0].Weather.Location != "Jakarta" || results[0].Error != nil {
+				t.Errorf("Expected Jakarta's weather, got %+v", results[0])
+			}
+			if results[1].Weather != nil || results[1].Error == nil || results[1].Error.Code != apperror.CodeNotFound || results[1].Location != "Atlantis" {
+				t.Errorf("Expected Atlantis not found, got %+v", results[1])
+			}
+			if results[2].Weather == nil || results[2].Weather.Location != "Bandung" {
+				t.Errorf("Expected Bandung's weather, got %+v", results[2])
+			}
+		})
+	}
+}
+
+func TestHandleBatch_Invalid(t *testing.T) {
+	h := &BatchHandler{Service: &mockBatchService{}, MaxLocations: 2}
+	for name, req := range map[string]*http.Request{
+		"missing":   httptest.NewRequest(http.MethodGet, "/weather/batch", nil),
+		"too many":  httptest.NewRequest(http.MethodGet, "/weather/batch?locations=A,B,C", nil),
+		"empty":     httptest.NewRequest(http.MethodPost, "/weather/batch", strings.NewReader(`{"locations": []}`)),
+		"malformed": httptest.NewRequest(http.MethodPost, "/weather/batch", strings.NewReader(`{"locations": "A"}`)),
+	} {
+		w := httptest.NewRecorder()
+		h.HandleBatch(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleBatch(w, httptest.NewRequest(http.MethodDelete, "/weather/batch", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
+
+// fixedBatchService returns the same results for every batch, so benchmarks count only what the
+// handler allocates.
+type fixedBatchService struct {
+	results []service.BatchResult
+}
+
+func (f fixedBatchService) GetWeatherBatch(context.Context, []string) []service.BatchResult {
+	return f.results
+}
+
+// benchmarkWeather returns weather with every detail set, as large as responses get.
+func benchmarkWeather(location string) *model.WeatherResponse {
+	feels, low, high, humidity, pressure, wind, direction := 14.6, 13.9, 16.4, 72.0, 1012.0, 4.1, 240.0
+	sunrise := time.Date(2025, 6, 21, 4, 43, 9, 0, time.FixedZone("", 3600))
+	sunset, observed := sunrise.Add(16*time.Hour+38*time.Minute), sunrise.Add(9*time.Hour+49*time.Minute)
+	offset := int64(3600)
+	return &model.WeatherResponse{
+		Location: location, Temperature: 15.2, Description: "clear sky", Condition: model.ConditionClear,
+		IconURL: "https://openweathermap.org/img/wn/01d@2x.png", Cached: true,
+		FeelsLike: &feels, TempMin: &low, TempMax: &high, Humidity: &humidity, Pressure: &pressure,
+		WindSpeed: &wind, WindDirection: &direction, Sunrise: &sunrise, Sunset: &sunset, DayLength: 59880,
+		TimezoneOffset: &offset, ObservedAt: &observed,
+	}
+}
+
+func BenchmarkHandleBatch(b *testing.B) {
+	locations := make([]string, 50)
+	results := make([]service.BatchResult, len(locations))
+	for i := range locations {
+		locations[i] = "City" + strconv.Itoa(i)
+		results[i] = service.BatchResult{Location: locations[i], Weather: benchmarkWeather(locations[i])}
+	}
+	h := &BatchHandler{Service: fixedBatchService{results: results}, MaxLocations: len(locations)}
+	req := httptest.NewRequest(http.MethodGet, "/weather/batch?locations="+strings.Join(locations, ","), nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	b.ReportAllocs()
+	for b.Loop() {
+		h.HandleBatch(w, req)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// CacheInspectHandler describes single weather cache entries, to debug stale or wrong data.
+type CacheInspectHandler struct {
+	Inspector repository.CacheInspector
+}
+
+func NewCacheInspectHandler(inspector ...repository.CacheInspector) *CacheInspectHandler {
+	if len(inspector) > 0 && inspector[0] != nil {
+		return &CacheInspectHandler{Inspector: inspector[0]}
+	}
+	return &CacheInspectHandler{Inspector: repository.NewCacheInspector()}
+}
+
+// HandleInspect serves GET /admin/cache/inspect?key=weather:<location> with the stored payload,
+// its TTL, size, schema version, write time and source provider.
+func (h *CacheInspectHandler) HandleInspect(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		errMsg := "'key' is required, e.g. key=weather:London"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	info, err := h.Inspector.Inspect(r.Context(), key)
+	switch {
+	case errors.Is(err, repository.ErrNotWeatherKey):
+		errMsg := "Only weather cache keys (weather:<location>) can be inspected"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+	case errors.Is(err, repository.ErrCacheKeyNotFound):
+		errMsg := "Cache key not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to inspect cache entry", "cacheKey", key, "error", err)
+		errMsg := "Failed to inspect cache entry"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+	default:
+		writeResponse(w, http.StatusOK, model.Response{Data: info, Message: "Success"})
+	}
+}
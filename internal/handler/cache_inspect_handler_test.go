@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockCacheInspector struct {
+	key string
+	err error
+}
+
+func (m *mockCacheInspector) Inspect(_ context.Context, key string) (*repository.CacheEntryInfo, error) {
+	m.key = key
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &repository.CacheEntryInfo{Key: key, SizeBytes: 42, SchemaVersion: 1, Provider: "openweathermap", Fresh: true}, nil
+}
+
+func TestHandleInspect(t *testing.T) {
+	inspector := &mockCacheInspector{}
+	h := NewCacheInspectHandler(inspector)
+
+	w := httptest.NewRecorder()
+	h.HandleInspect(w, httptest.NewRequest(http.MethodGet, "/admin/cache/inspect?key=weather:Jakarta", nil))
+	if w.Code != http.StatusOK || inspector.key != "weather:Jakarta" {
+		t.Fatalf("Expected 200 for weather:Jakarta, got %d for %q", w.Code, inspector.key)
+	}
+	var resp struct {
+		Data repository.CacheEntryInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if resp.Data.SizeBytes != 42 || resp.Data.Provider != "openweathermap" {
+		t.Errorf("Unexpected entry %+v", resp.Data)
+	}
+}
+
+func TestHandleInspect_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		err        error
+		wantStatus int
+	}{
+		{name: "Missing key", target: "/admin/cache/inspect", wantStatus: http.StatusBadRequest},
+		{name: "Not a weather key", target: "/admin/cache/inspect?key=subscription:1", err: repository.ErrNotWeatherKey, wantStatus: http.StatusBadRequest},
+		{name: "Not found", target: "/admin/cache/inspect?key=weather:Nowhere", err: repository.ErrCacheKeyNotFound, wantStatus: http.StatusNotFound},
+		{name: "Redis error", target: "/admin/cache/inspect?key=weather:Jakarta", err: errors.New("redis down"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCacheInspectHandler(&mockCacheInspector{err: tt.err})
+			w := httptest.NewRecorder()
+			h.HandleInspect(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
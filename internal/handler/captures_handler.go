@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// CapturesHandler lists the request/response pairs recorded by the capture middleware.
+type CapturesHandler struct {
+	Store repository.CaptureStore
+}
+
+func NewCapturesHandler(store ...repository.CaptureStore) *CapturesHandler {
+	if len(store) > 0 && store[0] != nil {
+		return &CapturesHandler{Store: store[0]}
+	}
+	return &CapturesHandler{Store: repository.NewCaptureStore()}
+}
+
+// HandleCaptures serves GET /admin/captures?limit=N with the newest captures first, and DELETE
+// /admin/captures to clear them.
+func (h *CapturesHandler) HandleCaptures(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet, http.MethodDelete) {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := h.Store.Clear(r.Context()); err != nil {
+			logger(r.Context()).Errorw("Failed to clear captures", "error", err)
+			errMsg := "Failed to clear captures"
+			writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			errMsg := "'limit' must be a positive integer"
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		limit = n
+	}
+	captures, err := h.Store.List(r.Context(), limit)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to list captures", "error", err)
+		errMsg := "Failed to list captures"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: captures, Message: "Success"})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+type mockCaptureStore struct {
+	captures []model.Capture
+	limit    int
+	err      error
+}
+
+func (m *mockCaptureStore) Add(_ context.Context, c model.Capture) error {
+	m.captures = append([]model.Capture{c}, m.captures...)
+	return m.err
+}
+
+func (m *mockCaptureStore) List(_ context.Context, limit int) ([]model.Capture, error) {
+	m.limit = limit
+	if limit > 0 && limit < len(m.captures) {
+		return m.captures[:limit], m.err
+	}
+	return m.captures, m.err
+}
+
+func (m *mockCaptureStore) Clear(context.Context) error {
+	m.captures = nil
+	return m.err
+}
+
+func TestHandleCaptures(t *testing.T) {
+	store := &mockCaptureStore{captures: []model.Capture{{RequestID: "req-2", Status: 502}, {RequestID: "req-1", Status: 500}}}
+	h := NewCapturesHandler(store)
+
+	w := httptest.NewRecorder()
+	h.HandleCaptures(w, httptest.NewRequest(http.MethodGet, "/admin/captures?limit=1", nil))
+	var resp struct {
+		Data []model.Capture `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with JSON, got %d: %s", w.Code, w.Body)
+	}
+	if store.limit != 1 || len(resp.Data) != 1 || resp.Data[0].RequestID != "req-2" {
+		t.Errorf("Expected the newest capture, got %+v", resp.Data)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleCaptures(w, httptest.NewRequest(http.MethodGet, "/admin/captures?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for limit=0, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleCaptures(w, httptest.NewRequest(http.MethodDelete, "/admin/captures", nil))
+	if w.Code != http.StatusNoContent || store.captures != nil {
+		t.Errorf("Expected captures to be cleared with 204, got %d", w.Code)
+	}
+
+	store.err = errors.New("redis down")
+	w = httptest.NewRecorder()
+	h.HandleCaptures(w, httptest.NewRequest(http.MethodGet, "/admin/captures", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when the store fails, got %d", w.Code)
+	}
+}
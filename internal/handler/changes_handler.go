@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// ChangesHandler reports what changed in a location's conditions since a point in time, from the
+// history of fetched snapshots, so clients syncing many cities only download the differences.
+type ChangesHandler struct {
+	Store repository.HistoryStore
+}
+
+func NewChangesHandler(store ...repository.HistoryStore) *ChangesHandler {
+	if len(store) > 0 && store[0] != nil {
+		return &ChangesHandler{Store: store[0]}
+	}
+	return &ChangesHandler{Store: repository.NewHistoryStore()}
+}
+
+// weatherChanges is the response of GET /weather/changes.
+type weatherChanges struct {
+	Location string    `json:"location"`
+	Since    time.Time `json:"since"`
+	// AsOf is the time of the latest known conditions; clients pass it as the next since.
+	AsOf time.Time `json:"as_of"`
+	// Changed holds the fields that differ from the conditions at since, with their new values.
+	Changed map[string]any `json:"changed"`
+	// Complete is set when the history does not reach back to since, so every field is returned.
+	Complete bool `json:"complete,omitempty"`
+}
+
+// unchangingFields are response fields that describe the request rather than the conditions.
+var unchangingFields = []string{"location", "cached", "stale"}
+
+// HandleChanges serves GET /weather/changes?location=X&since=<RFC 3339 or Unix seconds>. Locations
+// not fetched within history.retention have no history and get a 404.
+func (h *ChangesHandler) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	location := q.Get("location")
+	if location == "" {
+		errMsg := "'location' is required"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	location, err := geodata.Canonicalize(location, config.IsGeodataStrict())
+	if err != nil {
+		errMsg := err.Error()
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	since, ok := parseSince(q.Get("since"))
+	if !ok {
+		errMsg := "'since' must be an RFC 3339 timestamp or Unix seconds"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	snapshots, err := h.Store.Snapshots(r.Context(), location)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to read history", "location", location, "error", err)
+		errMsg := "Failed to read history"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	} else if len(snapshots) == 0 {
+		errMsg := "No history for location; it has not been fetched recently"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	// Snapshots are newest first: the baseline is the newest one at or before since
+	latest := snapshots[0]
+	changes := weatherChanges{Location: location, Since: since, AsOf: latest.At}
+	var baseline *repository.Snapshot
+	for i := range snapshots {
+		if !snapshots[i].At.After(since) {
+			baseline = &snapshots[i]
+			break
+		}
+	}
+	if baseline == nil {
+		changes.Complete = true
+		changes.Changed = changedFields(nil, &latest.Weather)
+	} else {
+		changes.Changed = changedFields(&baseline.Weather, &latest.Weather)
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: changes, Message: "Success"})
+}
+
+// parseSince accepts an RFC 3339 timestamp or Unix seconds.
+func parseSince(raw string) (time.Time, bool) {
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	return t.UTC(), err == nil
+}
+
+// changedFields returns the JSON fields of next whose values differ from prev, or all of them
+// when prev is nil. Comparing the encoded forms keeps new response fields covered.
+func changedFields(prev, next *model.WeatherResponse) map[string]any {
+	nextFields := weatherFields(next)
+	for _, f := range unchangingFields {
+		delete(nextFields, f)
+	}
+	if prev == nil {
+		return nextFields
+	}
+	prevFields := weatherFields(prev)
+	for name, v := range nextFields {
+		if old, ok := prevFields[name]; ok && reflect.DeepEqual(old, v) {
+			delete(nextFields, name)
+		}
+	}
+	return nextFields
+}
+
+func weatherFields(weather *model.WeatherResponse) map[string]any {
+	fields := map[string]any{}
+	b, _ := json.Marshal(weather)
+	_ = json.Unmarshal(b, &fields)
+	return fields
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockHistoryStore struct {
+	snapshots map[string][]repository.Snapshot
+	err       error
+}
+
+func (m *mockHistoryStore) Record(ctx context.Context, update events.CacheUpdate) error { return nil }
+
+func (m *mockHistoryStore) Snapshots(ctx context.Context, location string) ([]repository.Snapshot, error) {
+	return m.snapshots[location], m.err
+}
+
+func TestHandleChanges(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := NewChangesHandler(&mockHistoryStore{snapshots: map[string][]repository.Snapshot{
+		"London": {
+			{Weather: model.WeatherResponse{Location: "London", Temperature: 9, Description: "rain"}, At: t0.Add(2 * time.Hour)},
+			{Weather: model.WeatherResponse{Location: "London", Temperature: 9, Description: "clouds"}, At: t0.Add(time.Hour)},
+			{Weather: model.WeatherResponse{Location: "London", Temperature: 7, Description: "clouds"}, At: t0},
+		},
+	}})
+
+	tests := []struct {
+		name         string
+		since        string
+		wantChanged  map[string]any
+		wantComplete bool
+	}{
+		{name: "Since the first item", since: t0.Format(time.RFC3339), wantChanged: map[string]any{"temperature": 9.0, "description": "rain"}},
+		{name: "Between items, as Unix seconds", since: "1735736400", wantChanged: map[string]any{"description": "rain"}},
+		{name: "Up to date", since: t0.Add(3 * time.Hour).Format(time.RFC3339), wantChanged: map[string]any{}},
+		{name: "Before the history", since: t0.Add(-time.Hour).Format(time.RFC3339), wantChanged: map[string]any{"temperature": 9.0, "description": "rain"}, wantComplete: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleChanges(w, httptest.NewRequest(http.MethodGet, "/weather/changes?location=london&since="+tt.since, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Data weatherChanges `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Expected JSON, got %v", err)
+			}
+			if len(resp.Data.Changed) != len(tt.wantChanged) {
+				t.Errorf("Expected changes %v, got %v", tt.wantChanged, resp.Data.Changed)
+			}
+			for k, v := range tt.wantChanged {
+				if resp.Data.Changed[k] != v {
+					t.Errorf("Expected %s = %v, got %v", k, v, resp.Data.Changed[k])
+				}
+			}
+			if resp.Data.Complete != tt.wantComplete || !resp.Data.AsOf.Equal(t0.Add(2*time.Hour)) {
+				t.Errorf("Unexpected complete %v or as_of %v", resp.Data.Complete, resp.Data.AsOf)
+			}
+		})
+	}
+}
+
+func TestHandleChanges_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      *mockHistoryStore
+		target     string
+		wantStatus int
+	}{
+		{name: "Missing location", store: &mockHistoryStore{}, target: "/weather/changes?since=0", wantStatus: http.StatusBadRequest},
+		{name: "Invalid location", store: &mockHistoryStore{}, target: "/weather/changes?location=London_&since=0", wantStatus: http.StatusBadRequest},
+		{name: "Invalid since", store: &mockHistoryStore{}, target: "/weather/changes?location=London&since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "No history", store: &mockHistoryStore{}, target: "/weather/changes?location=Oslo&since=0", wantStatus: http.StatusNotFound},
+		{name: "Store error", store: &mockHistoryStore{err: errors.New("redis down")}, target: "/weather/changes?location=London&since=0", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewChangesHandler(tt.store).HandleChanges(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+// CompareHandler serves the weather of several locations side by side, with the differences
+// from the first.
+type CompareHandler struct {
+	Service      service.BatchWeatherService
+	MaxLocations int
+}
+
+func NewCompareHandler(svc ...service.BatchWeatherService) *CompareHandler {
+	h := &CompareHandler{MaxLocations: config.GetBatchConfig().MaxLocations}
+	if len(svc) > 0 && svc[0] != nil {
+		h.Service = svc[0]
+	} else {
+		h.Service = service.New()
+	}
+	return h
+}
+
+// weatherDelta is how a location's weather differs from the baseline's: its value minus the
+// baseline's. Details are left out unless both locations have them.
+type weatherDelta struct {
+	Temperature float64  `json:"temperature"`
+	FeelsLike   *float64 `json:"feels_like,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+	Pressure    *float64 `json:"pressure,omitempty"`
+	WindSpeed   *float64 `json:"wind_speed,omitempty"`
+	UVIndex     *float64 `json:"uv_index,omitempty"`
+	// DayLength is in seconds.
+	DayLength *int64 `json:"day_length,omitempty"`
+}
+
+// comparedLocation is one location of a comparison. Delta is unset for the baseline.
+type comparedLocation struct {
+	Location string                 `json:"location"`
+	Weather  *model.WeatherResponse `json:"weather"`
+	Delta    *weatherDelta          `json:"delta,omitempty"`
+}
+
+// comparison is the response of GET /weather/compare.
+type comparison struct {
+	// Baseline is the first location requested, which the others are compared with.
+	Baseline  string             `json:"baseline"`
+	Locations []comparedLocation `json:"locations"`
+}
+
+// HandleCompare serves GET /weather/compare?locations=Jakarta,Tokyo. The locations are looked up
+// as by /weather/batch, so cached ones cost no provider call. A comparison is only returned whole:
+// when a location fails, every failure is returned instead, as a 400 for invalid locations and as
+// for /forecast otherwise.
+func (h *CompareHandler) HandleCompare(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	var locations []string
+	if raw := r.URL.Query().Get("locations"); raw != "" {
+		locations = strings.Split(raw, ",")
+	}
+	if len(locations) < 2 {
+		errMsg := "'locations' must list at least two comma-separated locations"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if len(locations) > h.MaxLocations {
+		errMsg := "'locations' may list at most " + strconv.Itoa(h.MaxLocations) + " locations"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	for i := range locations {
+		locations[i] = strings.TrimSpace(locations[i])
+	}
+
+	results := h.Service.GetWeatherBatch(r.Context(), locations)
+	var failures apperror.Multi
+	invalid := false
+	for _, result := range results {
+		if result.Err != nil {
+			code := weatherErrorCode(result.Err)
+			invalid = invalid || code == apperror.CodeInvalidInput
+			failures.Add(code, "", result.Location, result.Err)
+		}
+	}
+	if failures.Len() > 0 {
+		if invalid {
+			errMsg := failures.Error()
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Errors: failures.Errors, Message: "Error"})
+			return
+		}
+		writeProviderErrors(w, r, &failures)
+		return
+	}
+
+	precision := config.GetTemperaturePrecision()
+	baseline := results[0].Weather
+	resp := comparison{Baseline: results[0].Location, Locations: make([]comparedLocation, len(results))}
+	for i, result := range results {
+		resp.Locations[i] = comparedLocation{Location: result.Location, Weather: result.Weather}
+		if i > 0 {
+			resp.Locations[i].Delta = compareWeather(baseline, result.Weather, precision)
+		}
+	}
+	writeSizedResponse(w, http.StatusOK, model.Response{Data: resp, Message: "Success"}, len(resp.Locations)*batchResultBytes)
+}
+
+// compareWeather returns how weather differs from baseline. Temperatures are rounded to precision
+// decimals, as in responses, and the other details to one decimal.
+func compareWeather(baseline, weather *model.WeatherResponse, precision int) *weatherDelta {
+	temperature := func(v, base float64) float64 { return model.RoundTemperature(v-base, precision) }
+	detail := func(v, base float64) float64 { return math.Round((v-base)*10) / 10 }
+	d := &weatherDelta{
+		Temperature: temperature(weather.Temperature, baseline.Temperature),
+		FeelsLike:   deltaOf(weather.FeelsLike, baseline.FeelsLike, temperature),
+		Humidity:    deltaOf(weather.Humidity, baseline.Humidity, detail),
+		Pressure:    deltaOf(weather.Pressure, baseline.Pressure, detail),
+		WindSpeed:   deltaOf(weather.WindSpeed, baseline.WindSpeed, detail),
+		UVIndex:     deltaOf(weather.UVIndex, baseline.UVIndex, detail),
+	}
+	if weather.DayLength != 0 && baseline.DayLength != 0 {
+		dayLength := weather.DayLength - baseline.DayLength
+		d.DayLength = &dayLength
+	}
+	return d
+}
+
+// deltaOf returns diff(*v, *base), or nil when either is unset.
+func deltaOf(v, base *float64, diff func(v, base float64) float64) *float64 {
+	if v == nil || base == nil {
+		return nil
+	}
+	d := diff(*v, *base)
+	return &d
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+	"github.com/spf13/viper"
+)
+
+// compareService has the weather of the locations it holds, and no other.
+type compareService map[string]*model.WeatherResponse
+
+func (s compareService) GetWeatherBatch(_ context.Context, locations []string) []service.BatchResult {
+	results := make([]service.BatchResult, len(locations))
+	for i, location := range locations {
+		results[i].Location = location
+		switch weather, ok := s[location]; {
+		case ok:
+			results[i].Weather = weather
+		case location == "!!":
+			results[i].Err = geodata.ErrInvalidLocation
+		default:
+			results[i].Err = &repository.LocationNotFoundError{Message: "city not found"}
+		}
+	}
+	return results
+}
+
+func TestHandleCompare(t *testing.T) {
+	jakartaHumidity, tokyoHumidity, tokyoWind := 78.0, 61.5, 3.2
+	h := &CompareHandler{MaxLocations: 3, Service: compareService{
+		"Jakarta": {Location: "Jakarta", Temperature: 31.2, Humidity: &jakartaHumidity, DayLength: 43500},
+		"Tokyo":   {Location: "Tokyo", Temperature: 18.9, Humidity: &tokyoHumidity, WindSpeed: &tokyoWind, DayLength: 50400},
+	}}
+
+	w := httptest.NewRecorder()
+	h.HandleCompare(w, httptest.NewRequest(http.MethodGet, "/weather/compare?locations=Jakarta,%20Tokyo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data comparison `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got := resp.Data
+	if got.Baseline != "Jakarta" || len(got.Locations) != 2 || got.Locations[0].Delta != nil || got.Locations[1].Location != "Tokyo" {
+		t.Fatalf("Expected Tokyo compared with Jakarta, got %s", w.Body)
+	}
+	delta := got.Locations[1].Delta
+	if delta == nil || delta.Temperature != -12.3 {
+		t.Fatalf("Expected Tokyo 12.3 degrees cooler, got %+v", delta)
+	}
+	if delta.Humidity == nil || *delta.Humidity != -16.5 {
+		t.Errorf("Expected 16.5 points less humid, got %v", delta.Humidity)
+	}
+	if delta.DayLength == nil || *delta.DayLength != 6900 {
+		t.Errorf("Expected days 6900s longer, got %v", delta.DayLength)
+	}
+	// Jakarta reports no wind, so there is nothing to compare
+	if delta.WindSpeed != nil || delta.Pressure != nil {
+		t.Errorf("Expected no delta for details the baseline lacks, got %+v", delta)
+	}
+}
+
+func TestHandleCompare_Errors(t *testing.T) {
+	h := &CompareHandler{MaxLocations: 3, Service: compareService{
+		"Jakarta": {Location: "Jakarta", Temperature: 31.2},
+		"Tokyo":   {Location: "Tokyo", Temperature: 18.9},
+	}}
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       string
+	}{
+		{"", http.StatusBadRequest, "at least two"},
+		{"locations=Jakarta", http.StatusBadRequest, "at least two"},
+		{"locations=A,B,C,D", http.StatusBadRequest, "at most 3"},
+		{"locations=Jakarta,Atlantis", http.StatusNotFound, `"not_found"`},
+		{"locations=Jakarta,!!,Atlantis", http.StatusBadRequest, `"invalid_input"`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.HandleCompare(w, httptest.NewRequest(http.MethodGet, "/weather/compare?"+tt.query, nil))
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%q: expected %d with %s, got %d: %s", tt.query, tt.wantStatus, tt.want, w.Code, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleCompare(w, httptest.NewRequest(http.MethodPost, "/weather/compare?locations=Jakarta,Tokyo", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestHandleCompare_ProtobufFallsBackToJSON(t *testing.T) {
+	viper.Set("server.response_encoding", "protobuf")
+	defer viper.Set("server.response_encoding", nil)
+	h := &CompareHandler{MaxLocations: 3, Service: compareService{
+		"Jakarta": {Location: "Jakarta", Temperature: 31.2},
+		"Tokyo":   {Location: "Tokyo", Temperature: 18.9},
+	}}
+
+	// The protobuf schema has no comparison message
+	w := httptest.NewRecorder()
+	h.HandleCompare(w, httptest.NewRequest(http.MethodGet, "/weather/compare?locations=Jakarta,Tokyo", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"baseline":"Jakarta"`) {
+		t.Errorf("Expected a 200 JSON comparison, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// ConfigHandler reports the live configuration.
+type ConfigHandler struct {
+	Settings func() []config.Setting
+}
+
+func NewConfigHandler() *ConfigHandler {
+	return &ConfigHandler{Settings: config.EffectiveSettings}
+}
+
+// HandleConfig serves GET /admin/config with every effective setting, its source (file, env,
+// default or override) and secrets redacted.
+func (h *ConfigHandler) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: h.Settings(), Message: "Success"})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestConfigHandler_HandleConfig(t *testing.T) {
+	h := &ConfigHandler{Settings: func() []config.Setting {
+		return []config.Setting{
+			{Key: "openweathermap.api_key", Value: config.Redacted, Source: config.SourceEnv, Env: "OPENWEATHERMAP_API_KEY"},
+			{Key: "server.port", Value: "8080", Source: config.SourceFile},
+		}
+	}}
+
+	rr := httptest.NewRecorder()
+	h.HandleConfig(rr, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var body struct {
+		Data []config.Setting `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(body.Data) != 2 || body.Data[0].Source != "env" || body.Data[0].Value != config.Redacted || body.Data[1].Value != "8080" {
+		t.Errorf("Unexpected settings %+v", body.Data)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleConfig(rr, httptest.NewRequest(http.MethodPost, "/admin/config", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+const (
+	defaultCostDays = 7
+	maxCostDays     = 90
+)
+
+// CostsHandler reports estimated upstream costs and the savings from caching.
+type CostsHandler struct {
+	Ledger repository.CostLedger
+}
+
+func NewCostsHandler(ledger ...repository.CostLedger) *CostsHandler {
+	if len(ledger) > 0 && ledger[0] != nil {
+		return &CostsHandler{Ledger: ledger[0]}
+	}
+	return &CostsHandler{Ledger: repository.NewCostLedger()}
+}
+
+// HandleCosts serves GET /admin/costs?days=N with per-provider daily calls, estimated cost,
+// savings from cache hits and how close today is to the provider's daily quota.
+func (h *CostsHandler) HandleCosts(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	days := defaultCostDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCostDays {
+			errMsg := "'days' must be between 1 and " + strconv.Itoa(maxCostDays)
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		days = n
+	}
+
+	reports, err := h.Ledger.Report(r.Context(), days)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to read costs", "error", err)
+		errMsg := "Failed to read costs"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	for _, report := range reports {
+		if report.NearQuota {
+			logger(r.Context()).Warnw("Provider near its daily quota", "provider", report.Provider, "quotaUsed", report.QuotaUsed)
+		}
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: reports, Message: "Success"})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockCostLedger struct {
+	days int
+	err  error
+}
+
+func (m *mockCostLedger) Flush(context.Context) error { return nil }
+
+func (m *mockCostLedger) Report(_ context.Context, days int) ([]repository.ProviderCosts, error) {
+	m.days = days
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []repository.ProviderCosts{{
+		Provider: "openweathermap", Currency: "USD", PricePerCall: 0.01,
+		Total: repository.DailyCost{Calls: 3, CallsSaved: 7, Cost: 0.03, Savings: 0.07},
+	}}, nil
+}
+
+func TestHandleCosts(t *testing.T) {
+	ledger := &mockCostLedger{}
+	h := NewCostsHandler(ledger)
+
+	w := httptest.NewRecorder()
+	h.HandleCosts(w, httptest.NewRequest(http.MethodGet, "/admin/costs", nil))
+	if w.Code != http.StatusOK || ledger.days != defaultCostDays {
+		t.Fatalf("Expected 200 over %d days, got %d over %d", defaultCostDays, w.Code, ledger.days)
+	}
+	var resp struct {
+		Data []repository.ProviderCosts `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Total.CallsSaved != 7 {
+		t.Errorf("Unexpected report %+v", resp.Data)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleCosts(w, httptest.NewRequest(http.MethodGet, "/admin/costs?days=30", nil))
+	if w.Code != http.StatusOK || ledger.days != 30 {
+		t.Errorf("Expected 30 days, got %d", ledger.days)
+	}
+}
+
+func TestHandleCosts_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		err    error
+		status int
+	}{
+		{"method", http.MethodPost, "/admin/costs", nil, http.StatusMethodNotAllowed},
+		{"days not a number", http.MethodGet, "/admin/costs?days=week", nil, http.StatusBadRequest},
+		{"days too large", http.MethodGet, "/admin/costs?days=365", nil, http.StatusBadRequest},
+		{"ledger error", http.MethodGet, "/admin/costs", errors.New("redis down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewCostsHandler(&mockCostLedger{err: tt.err}).HandleCosts(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
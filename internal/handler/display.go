@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/encoding"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// formatDisplay selects the human-readable response shape, localized from Accept-Language.
+const formatDisplay = "display"
+
+// varyAcceptLanguage is the Vary header of display responses.
+var varyAcceptLanguage = []string{"Accept", "Accept-Language"}
+
+// displayWeather is weather ready to show to a person: numbers are formatted and conditions
+// translated for the negotiated language.
+type displayWeather struct {
+	Location    string `json:"location"`
+	Temperature string `json:"temperature"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	Cached      bool   `json:"cached"`
+	Stale       bool   `json:"stale,omitempty"`
+}
+
+// displayLocale holds the formatting rules for one language.
+type displayLocale struct {
+	tag     string
+	decimal string
+	// conditions translates lower-case OpenWeatherMap descriptions.
+	conditions map[string]string
+}
+
+// defaultLocale is used when Accept-Language names no supported language.
+var defaultLocale = displayLocale{tag: "en", decimal: "."}
+
+var displayLocales = map[string]displayLocale{
+	"en": defaultLocale,
+	"de": {tag: "de", decimal: ",", conditions: map[string]string{
+		"clear sky": "Klarer Himmel", "few clouds": "Ein paar Wolken", "scattered clouds": "Vereinzelte Wolken",
+		"broken clouds": "Aufgelockerte Bewölkung", "overcast clouds": "Bedeckt", "light rain": "Leichter Regen",
+		"moderate rain": "Mäßiger Regen", "heavy intensity rain": "Starker Regen", "shower rain": "Regenschauer",
+		"rain": "Regen", "thunderstorm": "Gewitter", "snow": "Schnee", "mist": "Dunst", "fog": "Nebel",
+		"haze": "Dunstig", "drizzle": "Nieselregen",
+	}},
+	"fr": {tag: "fr", decimal: ",", conditions: map[string]string{
+		"clear sky": "Ciel dégagé", "few clouds": "Peu nuageux", "scattered clouds": "Nuages épars",
+		"broken clouds": "Nuageux", "overcast clouds": "Couvert", "light rain": "Légère pluie",
+		"moderate rain": "Pluie modérée", "heavy intensity rain": "Forte pluie", "shower rain": "Averses",
+		"rain": "Pluie", "thunderstorm": "Orage", "snow": "Neige", "mist": "Brume", "fog": "Brouillard",
+		"haze": "Brume sèche", "drizzle": "Bruine",
+	}},
+	"es": {tag: "es", decimal: ",", conditions: map[string]string{
+		"clear sky": "Cielo claro", "few clouds": "Algo de nubes", "scattered clouds": "Nubes dispersas",
+		"broken clouds": "Muy nuboso", "overcast clouds": "Cubierto", "light rain": "Lluvia ligera",
+		"moderate rain": "Lluvia moderada", "heavy intensity rain": "Lluvia intensa", "shower rain": "Chubascos",
+		"rain": "Lluvia", "thunderstorm": "Tormenta", "snow": "Nieve", "mist": "Neblina", "fog": "Niebla",
+		"haze": "Calima", "drizzle": "Llovizna",
+	}},
+	"id": {tag: "id", decimal: ",", conditions: map[string]string{
+		"clear sky": "Cerah", "few clouds": "Sedikit berawan", "scattered clouds": "Awan tersebar",
+		"broken clouds": "Berawan", "overcast clouds": "Mendung", "light rain": "Hujan ringan",
+		"moderate rain": "Hujan sedang", "heavy intensity rain": "Hujan lebat", "shower rain": "Hujan lokal",
+		"rain": "Hujan", "thunderstorm": "Badai petir", "snow": "Salju", "mist": "Kabut tipis", "fog": "Kabut",
+		"haze": "Kabut asap", "drizzle": "Gerimis",
+	}},
+	"pt": {tag: "pt", decimal: ",", conditions: map[string]string{
+		"clear sky": "Céu limpo", "few clouds": "Algumas nuvens", "scattered clouds": "Nuvens dispersas",
+		"broken clouds": "Nublado", "overcast clouds": "Encoberto", "light rain": "Chuva leve",
+		"moderate rain": "Chuva moderada", "heavy intensity rain": "Chuva forte", "shower rain": "Aguaceiros",
+		"rain": "Chuva", "thunderstorm": "Trovoada", "snow": "Neve", "mist": "Névoa", "fog": "Nevoeiro",
+		"haze": "Névoa seca", "drizzle": "Garoa",
+	}},
+}
+
+// negotiateLocale picks the supported language the client prefers most, by q-value and then
+// order. Only the primary subtag is considered, so "de-AT" selects German.
+func negotiateLocale(acceptLanguage string) displayLocale {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary != "" && q > 0 {
+			candidates = append(candidates, candidate{primary, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		if loc, ok := displayLocales[c.tag]; ok {
+			return loc
+		}
+	}
+	return defaultLocale
+}
+
+// formatTemperature formats a Celsius temperature with one decimal and the locale's separator.
+func (l displayLocale) formatTemperature(celsius float64) string {
+	s := strconv.FormatFloat(celsius, 'f', 1, 64)
+	if s == "-0.0" {
+		s = "0.0"
+	}
+	return strings.Replace(s, ".", l.decimal, 1) + " °C"
+}
+
+// condition translates an OpenWeatherMap description, falling back to the original text.
+func (l displayLocale) condition(description string) string {
+	if t, ok := l.conditions[strings.ToLower(description)]; ok {
+		return t
+	}
+	return description
+}
+
+func toDisplay(weather *model.WeatherResponse, loc displayLocale) displayWeather {
+	return displayWeather{
+		Location:    weather.Location,
+		Temperature: loc.formatTemperature(weather.Temperature),
+		Description: loc.condition(weather.Description),
+		Language:    loc.tag,
+		Cached:      weather.Cached,
+		Stale:       weather.Stale,
+	}
+}
+
+// writeDisplay writes weather in the display shape for the request's Accept-Language. The
+// protobuf schema has no display message, so protobuf clients get JSON.
+func (h *WeatherHandler) writeDisplay(w http.ResponseWriter, r *http.Request, weather *model.WeatherResponse) {
+	loc := negotiateLocale(r.Header.Get("Accept-Language"))
+	format, _ := h.responseFormat(r)
+	if _, fixed := format.encoder.(encoding.FixedSchema); fixed {
+		format.encoder = encoding.JSON
+	}
+	w.Header()["Vary"] = varyAcceptLanguage
+	w.Header().Set("Content-Language", loc.tag)
+	writeFormattedResponse(w, http.StatusOK, model.Response{Data: toDisplay(weather, loc), Message: "Success"}, format, 0)
+}
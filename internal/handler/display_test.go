@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de-DE", "de"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr"},
+		{"ja,es;q=0.5", "es"},
+		{"en;q=0.2, id;q=0.9", "id"},
+		{"pt-BR;q=0, es", "es"},
+		{"xx, *", "en"},
+		{"de;q=abc, fr", "fr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := negotiateLocale(tt.header).tag; got != tt.want {
+				t.Errorf("negotiateLocale(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToDisplay(t *testing.T) {
+	weather := &model.WeatherResponse{Location: "Berlin", Temperature: 15.24, Description: "light rain", Cached: true}
+	tests := []struct {
+		lang            string
+		wantTemperature string
+		wantDescription string
+	}{
+		{"en", "15.2 °C", "light rain"},
+		{"de", "15,2 °C", "Leichter Regen"},
+		{"fr", "15,2 °C", "Légère pluie"},
+		{"es", "15,2 °C", "Lluvia ligera"},
+		{"id", "15,2 °C", "Hujan ringan"},
+		{"pt", "15,2 °C", "Chuva leve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			got := toDisplay(weather, displayLocales[tt.lang])
+			if got.Temperature != tt.wantTemperature || got.Description != tt.wantDescription || got.Language != tt.lang || !got.Cached {
+				t.Errorf("Unexpected display %+v", got)
+			}
+		})
+	}
+}
+
+func TestFormatTemperature(t *testing.T) {
+	de := displayLocales["de"]
+	tests := []struct {
+		celsius float64
+		want    string
+	}{
+		{-3.46, "-3,5 °C"},
+		{-0.04, "0,0 °C"},
+		{0, "0,0 °C"},
+		{30, "30,0 °C"},
+	}
+	for _, tt := range tests {
+		if got := de.formatTemperature(tt.celsius); got != tt.want {
+			t.Errorf("formatTemperature(%v) = %q, want %q", tt.celsius, got, tt.want)
+		}
+	}
+	if got := defaultLocale.condition("tornado"); got != "tornado" {
+		t.Errorf("Expected untranslated conditions kept, got %q", got)
+	}
+}
+
+func TestWeatherHandler_HandleWeather_Display(t *testing.T) {
+	handler := &WeatherHandler{WeatherService: &mockWeatherService{
+		mockData: &model.WeatherResponse{Location: "Paris", Temperature: 12.5, Description: "Clear sky"},
+	}}
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=Paris&format=display", nil)
+	req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9")
+	req.Header.Set("Accept", "application/x-protobuf")
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Language") != "fr" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response %d %v: %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	var resp struct {
+		Data displayWeather `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if resp.Data.Temperature != "12,5 °C" || resp.Data.Description != "Ciel dégagé" {
+		t.Errorf("Unexpected display %+v", resp.Data)
+	}
+	if vary := rr.Header().Values("Vary"); len(vary) != 2 || vary[1] != "Accept-Language" {
+		t.Errorf("Expected Vary to include Accept-Language, got %v", vary)
+	}
+}
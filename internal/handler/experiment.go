@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var experimentRequests = metrics.NewCounterVec("weather_experiment_requests_total",
+	"Weather requests by experiment, variant and outcome (ok or error); requests outside experiments are the control group.",
+	"experiment", "variant", "outcome")
+
+// assignExperiment places the request's client in one of h.Experiments, or in the control group,
+// and tags ctx for logging and for the repository's alternate code paths. Without experiments,
+// ctx is returned unchanged.
+func (h *WeatherHandler) assignExperiment(ctx context.Context, r *http.Request) context.Context {
+	if len(h.Experiments) == 0 {
+		return ctx
+	}
+	a := experiment.Assign(h.Experiments, experimentUnit(ctx, r))
+	return logctx.WithExperiment(experiment.WithAssignment(ctx, a), a.ID+"/"+a.Variant)
+}
+
+// recordExperiment counts the outcome of a request taking part in an experiment.
+func recordExperiment(ctx context.Context, err error) {
+	a, ok := experiment.FromContext(ctx)
+	if !ok {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	experimentRequests.WithLabelValues(a.ID, a.Variant, outcome).Inc()
+}
+
+// experimentUnit identifies the client so it stays in the same group across requests: the
+// authenticated API key's name, or the client IP.
+func experimentUnit(ctx context.Context, r *http.Request) string {
+	fields := logctx.FieldsFrom(ctx)
+	if fields.APIKey != "" {
+		return "key:" + fields.APIKey
+	}
+	if fields.ClientIP != "" {
+		return "ip:" + fields.ClientIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+)
+
+func TestWeatherHandler_Experiments(t *testing.T) {
+	svc := &ctxCapturingService{}
+	handler := &WeatherHandler{WeatherService: svc}
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+
+	handler.HandleWeather(httptest.NewRecorder(), req)
+	if _, ok := experiment.FromContext(svc.ctx); ok {
+		t.Error("Expected no assignment without experiments")
+	}
+
+	handler.Experiments = []config.Experiment{{ID: "ttl", Percent: 100, Variant: experiment.AdaptiveTTL}}
+	before := experimentRequests.WithLabelValues("ttl", experiment.AdaptiveTTL, "ok").Value()
+	handler.HandleWeather(httptest.NewRecorder(), req)
+	if a, ok := experiment.FromContext(svc.ctx); !ok || a.ID != "ttl" {
+		t.Errorf("Expected the request in the experiment, got %+v", a)
+	}
+	if got := logctx.FieldsFrom(svc.ctx).Experiment; got != "ttl/adaptive_ttl" {
+		t.Errorf("Expected the experiment on the log fields, got %q", got)
+	}
+	if experimentRequests.WithLabelValues("ttl", experiment.AdaptiveTTL, "ok").Value() != before+1 {
+		t.Error("Expected the request to be counted")
+	}
+}
+
+func TestExperimentUnit(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := experimentUnit(req.Context(), req); got != "ip:10.0.0.1" {
+		t.Errorf("Expected the remote IP, got %q", got)
+	}
+	ctx := logctx.WithClientIP(req.Context(), "203.0.113.5")
+	if got := experimentUnit(ctx, req); got != "ip:203.0.113.5" {
+		t.Errorf("Expected the client IP, got %q", got)
+	}
+	if got := experimentUnit(logctx.WithAPIKey(ctx, "team-a"), req); got != "key:team-a" {
+		t.Errorf("Expected the API key name, got %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/bufpool"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// exportRowBytes is about the encoded size of one exported row with every detail.
+const exportRowBytes = 640
+
+// ExportHandler streams the weather cache as newline-delimited JSON.
+type ExportHandler struct {
+	Exporter      repository.CacheExporter
+	FlushInterval time.Duration
+	// WriteTimeout is server.timeouts.write, which every flush extends the write deadline by, so
+	// that an export may run for as long as it keeps making progress. Zero leaves it alone.
+	WriteTimeout time.Duration
+}
+
+func NewExportHandler(exporter ...repository.CacheExporter) *ExportHandler {
+	var e repository.CacheExporter
+	if len(exporter) > 0 && exporter[0] != nil {
+		e = exporter[0]
+	} else {
+		e = repository.NewCacheExporter()
+	}
+	return &ExportHandler{
+		Exporter:      e,
+		FlushInterval: config.GetExportFlushInterval(),
+		WriteTimeout:  config.GetServerTimeouts().Write,
+	}
+}
+
+// HandleExport writes one JSON object per cached location. Rows are written straight to the
+// connection and flushed every FlushInterval, so a slow client applies backpressure to the cache
+// scan instead of the server buffering the whole export. A client disconnect cancels the scan.
+// Each row is encoded without reflection into the same pooled buffer, and each flush extends the
+// write deadline, so an export is not cut off by server.timeouts.write while it progresses.
+func (h *ExportHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+	buf := bufpool.Get(exportRowBytes)
+	defer func() { bufpool.Put(buf) }()
+	lastFlush := time.Now()
+	rows := 0
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	err := h.Exporter.Export(ctx, func(weather *model.WeatherResponse) error {
+		row, err := weather.AppendJSON(buf.AvailableBuffer())
+		if err != nil {
+			return err
+		}
+		row = append(row, '\n')
+		if cap(row) > buf.Cap() {
+			// The row outgrew the buffer; keep the larger one for the rows after it
+			buf = bytes.NewBuffer(row[:0])
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+		rows++
+		if time.Since(lastFlush) >= h.FlushInterval {
+			lastFlush = time.Now()
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			if h.WriteTimeout > 0 {
+				if err := rc.SetWriteDeadline(time.Now().Add(h.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+					return err
+				}
+			}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		if rows == 0 && ctx.Err() == nil {
+			errMsg := "Failed to export cache"
+			writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		}
+		logger(ctx).Warnw("Export aborted", "rows", rows, "error", err)
+		return
+	}
+	_ = rc.Flush()
+	logger(ctx).Debugw("Export finished", "rows", rows)
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// mockExporter emits total rows (or rows until cancelled when total < 0) and reports how many it
+// produced on done.
+type mockExporter struct {
+	total int
+	err   error
+	done  chan int
+}
+
+func (m *mockExporter) Export(ctx context.Context, fn func(*model.WeatherResponse) error) error {
+	n := 0
+	defer func() {
+		if m.done != nil {
+			m.done <- n
+		}
+	}()
+	if m.err != nil {
+		return m.err
+	}
+	for i := 0; m.total < 0 || i < m.total; i++ {
+		if err := fn(&model.WeatherResponse{Location: "City" + strconv.Itoa(i), Cached: true}); err != nil {
+			return err
+		}
+		n++
+	}
+	return nil
+}
+
+// throttledReader hands out at most chunk bytes per Read and sleeps between reads, simulating
+// a slow consumer.
+type throttledReader struct {
+	r     io.Reader
+	chunk int
+	delay time.Duration
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	time.Sleep(t.delay)
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+	return t.r.Read(p)
+}
+
+func TestHandleExport_SlowConsumerReceivesAllRows(t *testing.T) {
+	h := &ExportHandler{Exporter: &mockExporter{total: 50}, FlushInterval: time.Millisecond}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleExport))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON content type, got %s", ct)
+	}
+
+	scanner := bufio.NewScanner(&throttledReader{r: resp.Body, chunk: 64, delay: 100 * time.Microsecond})
+	rows := 0
+	for scanner.Scan() {
+		var w model.WeatherResponse
+		if err := json.Unmarshal(scanner.Bytes(), &w); err != nil {
+			t.Fatalf("Row %d is not valid JSON: %v", rows, err)
+		}
+		if w.Location != "City"+strconv.Itoa(rows) {
+			t.Errorf("Expected row %d to be City%d, got %s", rows, rows, w.Location)
+		}
+		rows++
+	}
+	if rows != 50 {
+		t.Errorf("Expected 50 rows, got %d", rows)
+	}
+}
+
+// slowExporter emits rows rows, pausing before each.
+type slowExporter struct {
+	rows  int
+	pause time.Duration
+}
+
+func (e slowExporter) Export(ctx context.Context, fn func(*model.WeatherResponse) error) error {
+	for i := range e.rows {
+		time.Sleep(e.pause)
+		if err := fn(&model.WeatherResponse{Location: "City" + strconv.Itoa(i), Cached: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandleExport_OutlastsWriteTimeout(t *testing.T) {
+	const writeTimeout = 100 * time.Millisecond
+	h := &ExportHandler{Exporter: slowExporter{rows: 20, pause: 20 * time.Millisecond}, FlushInterval: time.Millisecond, WriteTimeout: writeTimeout}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(h.HandleExport))
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	rows := 0
+	for scanner.Scan() {
+		rows++
+	}
+	if rows != 20 {
+		t.Errorf("Expected all 20 rows of an export running past the write timeout, got %d (%v)", rows, scanner.Err())
+	}
+}
+
+func TestHandleExport_ClientDisconnectStopsExport(t *testing.T) {
+	done := make(chan int, 1)
+	h := &ExportHandler{Exporter: &mockExporter{total: -1, done: done}, FlushInterval: time.Millisecond}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleExport))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if !bufio.NewScanner(resp.Body).Scan() {
+		t.Fatal("Expected at least one row before disconnecting")
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case n := <-done:
+		if n == 0 {
+			t.Error("Expected some rows to have been produced")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Export kept running after the client disconnected")
+	}
+}
+
+func TestHandleExport_Errors(t *testing.T) {
+	h := &ExportHandler{Exporter: &mockExporter{err: errors.New("redis down")}, FlushInterval: time.Second}
+
+	rr := httptest.NewRecorder()
+	h.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/admin/export", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when the export fails before any row, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleExport(rr, httptest.NewRequest(http.MethodPost, "/admin/export", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rr.Code)
+	}
+}
+
+// rowsExporter exports the same rows on every call.
+type rowsExporter []*model.WeatherResponse
+
+func (e rowsExporter) Export(_ context.Context, fn func(*model.WeatherResponse) error) error {
+	for _, weather := range e {
+		if err := fn(weather); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func BenchmarkHandleExport(b *testing.B) {
+	rows := make(rowsExporter, 1000)
+	for i := range rows {
+		rows[i] = benchmarkWeather("City" + strconv.Itoa(i))
+	}
+	h := &ExportHandler{Exporter: rows, FlushInterval: time.Hour}
+	req := httptest.NewRequest(http.MethodGet, "/admin/export", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	b.ReportAllocs()
+	for b.Loop() {
+		h.HandleExport(w, req)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// FeedHandler serves an Atom feed of material condition changes for each warm-listed city.
+type FeedHandler struct {
+	Store repository.FeedStore
+}
+
+func NewFeedHandler(store ...repository.FeedStore) *FeedHandler {
+	if len(store) > 0 && store[0] != nil {
+		return &FeedHandler{Store: store[0]}
+	}
+	return &FeedHandler{Store: repository.NewFeedStore()}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// HandleFeed serves GET /feed/{city}.atom.
+func (h *FeedHandler) HandleFeed(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	name, _ := strings.CutPrefix(r.URL.Path, "/feed/")
+	city, ok := strings.CutSuffix(name, ".atom")
+	if !ok || city == "" || strings.Contains(city, "/") {
+		errMsg := "Feed not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	ctx := r.Context()
+	items, err := h.Store.Items(ctx, city)
+	if errors.Is(err, repository.ErrFeedNotFound) {
+		errMsg := "Feed not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	} else if err != nil {
+		logger(ctx).Errorw("Failed to read feed", "city", city, "error", err)
+		errMsg := "Failed to read feed"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	body, err := xml.MarshalIndent(buildAtomFeed(city, feedURL(r), items), "", "  ")
+	if err != nil {
+		logger(ctx).Errorw("Failed to encode feed", "city", city, "error", err)
+		errMsg := "Failed to encode feed"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
+
+// buildAtomFeed renders items (newest first) as an Atom feed. An empty feed is dated now, as
+// Atom requires an updated timestamp.
+func buildAtomFeed(city, selfURL string, items []repository.FeedItem) atomFeed {
+	key := strings.ToLower(city)
+	title := city
+	updated := time.Now().UTC()
+	if len(items) > 0 {
+		title = items[0].Weather.Location
+		updated = items[0].At
+	}
+	feed := atomFeed{
+		ID:      "urn:weather-api:feed:" + key,
+		Title:   "Weather conditions in " + title,
+		Updated: updated.Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: selfURL},
+		Author:  atomAuthor{Name: "weather-api"},
+	}
+	for _, item := range items {
+		w := item.Weather
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:weather-api:feed:%s:%d", key, item.At.Unix()),
+			Title:   fmt.Sprintf("%s: %.1f°C, %s", w.Location, w.Temperature, w.Description),
+			Updated: item.At.Format(time.RFC3339),
+			Content: atomContent{
+				Type: "text",
+				Body: fmt.Sprintf("Conditions in %s changed to %s at %.1f°C.", w.Location, w.Description, w.Temperature),
+			},
+		})
+	}
+	return feed
+}
+
+// feedURL reconstructs the absolute URL of the request for the feed's self link.
+func feedURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockFeedStore struct {
+	items map[string][]repository.FeedItem
+	err   error
+}
+
+func (m *mockFeedStore) Record(ctx context.Context, update events.CacheUpdate) error { return nil }
+
+func (m *mockFeedStore) Items(ctx context.Context, city string) ([]repository.FeedItem, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	items, ok := m.items[strings.ToLower(city)]
+	if !ok {
+		return nil, repository.ErrFeedNotFound
+	}
+	return items, nil
+}
+
+func TestHandleFeed(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := NewFeedHandler(&mockFeedStore{items: map[string][]repository.FeedItem{
+		"london": {
+			{Weather: model.WeatherResponse{Location: "London", Temperature: 16.2, Description: "light rain"}, At: at.Add(time.Hour)},
+			{Weather: model.WeatherResponse{Location: "London", Temperature: 15, Description: "clear sky"}, At: at},
+		},
+		"tokyo": nil,
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/feed/London.atom", nil)
+	w := httptest.NewRecorder()
+	h.HandleFeed(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Expected Atom content type, got %q", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Expected valid XML, got %v", err)
+	}
+	if feed.Title != "Weather conditions in London" || feed.Updated != "2025-06-01T13:00:00Z" {
+		t.Errorf("Unexpected feed header %+v", feed)
+	}
+	if feed.Link.Href != "http://example.com/feed/London.atom" {
+		t.Errorf("Unexpected self link %q", feed.Link.Href)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].Title != "London: 16.2°C, light rain" {
+		t.Errorf("Unexpected entries %+v", feed.Entries)
+	}
+	if feed.Entries[0].ID == feed.Entries[1].ID {
+		t.Error("Expected unique entry IDs")
+	}
+
+	// A warm-listed city with no changes yet still gets a valid, empty feed
+	w = httptest.NewRecorder()
+	h.HandleFeed(w, httptest.NewRequest(http.MethodGet, "/feed/tokyo.atom", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Weather conditions in tokyo") {
+		t.Errorf("Expected empty feed, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleFeed_Errors(t *testing.T) {
+	h := NewFeedHandler(&mockFeedStore{items: map[string][]repository.FeedItem{}})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"not warm-listed", http.MethodGet, "/feed/paris.atom", http.StatusNotFound},
+		{"missing extension", http.MethodGet, "/feed/paris", http.StatusNotFound},
+		{"empty city", http.MethodGet, "/feed/.atom", http.StatusNotFound},
+		{"method", http.MethodPost, "/feed/paris.atom", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleFeed(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	NewFeedHandler(&mockFeedStore{err: errors.New("redis down")}).HandleFeed(w, httptest.NewRequest(http.MethodGet, "/feed/london.atom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 on store error, got %d", w.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// ForecastHandler serves a location's forecast, 3-hourly over five days or hourly over a horizon.
+type ForecastHandler struct {
+	Forecasts repository.ForecastRepository
+}
+
+func NewForecastHandler(forecasts ...repository.ForecastRepository) *ForecastHandler {
+	if len(forecasts) > 0 && forecasts[0] != nil {
+		return &ForecastHandler{Forecasts: forecasts[0]}
+	}
+	return &ForecastHandler{Forecasts: repository.NewForecastRepository()}
+}
+
+// HandleForecast serves GET /forecast?location=Jakarta, the provider's 5 day / 3 hour forecast,
+// and GET /forecast?location=Jakarta&hours=12, the next 1 to 48 hours hour by hour. Responses
+// are encoded as the Accept header asks, like /weather's.
+func (h *ForecastHandler) HandleForecast(w http.ResponseWriter, r *http.Request) {
+	format, acceptable := negotiate(r, currentResponseFormat())
+	respond := func(w http.ResponseWriter, status int, resp model.Response) {
+		writeFormattedResponse(w, status, resp, format, 0)
+	}
+	if !serveMethods(w, r, respond, http.MethodGet) {
+		return
+	}
+	w.Header()["Vary"] = varyAccept
+	if !acceptable {
+		errMsg := "None of the accepted media types are supported: " + strings.Join(acceptableMediaTypes(), ", ")
+		respond(w, http.StatusNotAcceptable, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	q := r.URL.Query()
+	location := q.Get("location")
+	if location == "" {
+		errMsg := "Missing 'location' query parameter"
+		respond(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	hours := 0
+	if q.Has("hours") {
+		n, err := strconv.Atoi(q.Get("hours"))
+		if err != nil || n < 1 || n > repository.MaxForecastHours {
+			errMsg := "'hours' must be a whole number from 1 to " + strconv.Itoa(repository.MaxForecastHours)
+			respond(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		hours = n
+	}
+
+	var forecast *model.ForecastResponse
+	var err error
+	if hours > 0 {
+		forecast, err = h.Forecasts.GetHourlyForecast(r.Context(), location, hours)
+	} else {
+		forecast, err = h.Forecasts.GetForecast(r.Context(), location)
+	}
+	if err != nil {
+		var failures apperror.Multi
+		failures.Add(forecastErrorCode(err), "", location, err)
+		respondProviderErrors(w, r, respond, &failures)
+		return
+	}
+	if forecast.Cached {
+		w.Header().Set(cacheHeader, "HIT")
+	} else {
+		w.Header().Set(cacheHeader, "MISS")
+	}
+	respond(w, http.StatusOK, model.Response{Data: forecast, Message: "Success"})
+}
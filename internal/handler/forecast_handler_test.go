@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/api/proto/weatherpb"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+func TestHandleForecast(t *testing.T) {
+	start := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	h := NewForecastHandler(&mockForecastRepository{start: start})
+
+	for target, want := range map[string]int{
+		"/forecast?location=Jakarta":          8,
+		"/forecast?location=Jakarta&hours=12": 12,
+		"/forecast?location=Jakarta&hours=48": 48,
+	} {
+		w := httptest.NewRecorder()
+		h.HandleForecast(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", target, w.Code, w.Body)
+		}
+		var resp struct {
+			Data model.ForecastResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if len(resp.Data.Entries) != want || resp.Data.Location != "Jakarta" {
+			t.Errorf("%s: expected %d entries, got %+v", target, want, resp.Data)
+		}
+	}
+}
+
+func TestHandleForecast_Protobuf(t *testing.T) {
+	start := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	h := NewForecastHandler(&mockForecastRepository{start: start})
+
+	req := httptest.NewRequest(http.MethodGet, "/forecast?location=Jakarta", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	h.HandleForecast(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("Expected a 200 protobuf response, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var env weatherpb.Envelope
+	if err := env.Unmarshal(w.Body.Bytes()); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if env.Forecast == nil || env.Forecast.Location != "Jakarta" || len(env.Forecast.Entries) != 8 || env.Forecast.Entries[0].Time != start.Unix() {
+		t.Errorf("Expected the forecast in the envelope, got %+v", env)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/forecast?location=Jakarta", nil)
+	req.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	h.HandleForecast(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 for an unsupported Accept, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleForecast_Invalid(t *testing.T) {
+	h := NewForecastHandler(&mockForecastRepository{})
+	for _, target := range []string{
+		"/forecast",
+		"/forecast?location=Jakarta&hours=0",
+		"/forecast?location=Jakarta&hours=49",
+		"/forecast?location=Jakarta&hours=6h",
+		"/forecast?location=Jakarta&hours=",
+	} {
+		w := httptest.NewRecorder()
+		h.HandleForecast(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestHandleForecast_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{&repository.LocationNotFoundError{Message: "city not found"}, http.StatusNotFound},
+		{&repository.CoolDownError{RetryAfter: 10 * time.Second}, http.StatusServiceUnavailable},
+		{repository.ErrExternalAPI, http.StatusBadGateway},
+	} {
+		w := httptest.NewRecorder()
+		NewForecastHandler(&mockForecastRepository{err: tt.err}).HandleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?location=Atlantis&hours=6", nil))
+		if w.Code != tt.want {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.want, w.Code)
+		}
+	}
+}
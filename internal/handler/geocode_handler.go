@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// GeocodeHandler resolves free-text place names into candidate places.
+type GeocodeHandler struct {
+	Geocoder repository.GeocodeRepository
+}
+
+func NewGeocodeHandler(geocoder ...repository.GeocodeRepository) *GeocodeHandler {
+	if len(geocoder) > 0 && geocoder[0] != nil {
+		return &GeocodeHandler{Geocoder: geocoder[0]}
+	}
+	return &GeocodeHandler{Geocoder: repository.NewGeocodeRepository()}
+}
+
+// HandleGeocode serves GET /geocode?q=springfield with the places q may refer to, best match
+// first, each with its name, country, state and coordinates. A query matching nothing gets an
+// empty list.
+func (h *GeocodeHandler) HandleGeocode(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		errMsg := "Missing 'q' query parameter"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	// Nonsense never reaches the provider, and known cities share an entry whatever their case
+	query, err := geodata.Canonicalize(q, false)
+	if err != nil {
+		errMsg := err.Error()
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	geocode, err := h.Geocoder.Geocode(r.Context(), query)
+	if err != nil {
+		var failures apperror.Multi
+		failures.Add(forecastErrorCode(err), "", query, err)
+		writeProviderErrors(w, r, &failures)
+		return
+	}
+	if geocode.Cached {
+		w.Header().Set(cacheHeader, "HIT")
+	} else {
+		w.Header().Set(cacheHeader, "MISS")
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: geocode, Message: "Success"})
+}
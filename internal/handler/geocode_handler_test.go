@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockGeocodeRepository struct {
+	query string
+	err   error
+}
+
+func (m *mockGeocodeRepository) Geocode(_ context.Context, query string) (*model.GeocodeResponse, error) {
+	m.query = query
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.GeocodeResponse{Query: query, Candidates: []model.GeocodeCandidate{
+		{Name: "London", Country: "GB", State: "England", Lat: 51.5073, Lon: -0.1276},
+		{Name: "London", Country: "CA", State: "Ontario", Lat: 42.9834, Lon: -81.233},
+	}}, nil
+}
+
+func TestHandleGeocode(t *testing.T) {
+	geocoder := &mockGeocodeRepository{}
+	h := NewGeocodeHandler(geocoder)
+
+	w := httptest.NewRecorder()
+	h.HandleGeocode(w, httptest.NewRequest(http.MethodGet, "/geocode?q=london", nil))
+	if w.Code != http.StatusOK || w.Header().Get(cacheHeader) != "MISS" {
+		t.Fatalf("Expected a 200 miss, got %d %s: %s", w.Code, w.Header().Get(cacheHeader), w.Body)
+	}
+	var resp struct {
+		Data model.GeocodeResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(resp.Data.Candidates) != 2 || resp.Data.Candidates[1].Country != "CA" || resp.Data.Candidates[0].Lat != 51.5073 {
+		t.Errorf("Unexpected candidates %+v", resp.Data)
+	}
+	// Known cities are canonicalized before geocoding
+	if geocoder.query != "London" {
+		t.Errorf("Expected the canonical query, got %q", geocoder.query)
+	}
+
+	for _, target := range []string{"/geocode", "/geocode?q=!!!"} {
+		w := httptest.NewRecorder()
+		h.HandleGeocode(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+
+	geocoder.err = &repository.CoolDownError{RetryAfter: 30 * time.Second}
+	w = httptest.NewRecorder()
+	h.HandleGeocode(w, httptest.NewRequest(http.MethodGet, "/geocode?q=Paris", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected 503 with Retry-After, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	geocoder.err = repository.ErrExternalAPI
+	w = httptest.NewRecorder()
+	h.HandleGeocode(w, httptest.NewRequest(http.MethodGet, "/geocode?q=Paris", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", w.Code)
+	}
+}
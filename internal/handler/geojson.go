@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// formatGeoJSON selects the GeoJSON (RFC 7946) response shape, for mapping libraries such as
+// Leaflet or Mapbox.
+const formatGeoJSON = "geojson"
+
+const geoJSONContentType = "application/geo+json"
+
+// geoJSONFeature is one location's weather as a GeoJSON Feature. Geometry is null for locations
+// outside the city dataset, which have no known coordinates.
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   *geoJSONPoint     `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+// geoJSONPoint holds its coordinates in GeoJSON order: longitude, then latitude.
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Location       string     `json:"location"`
+	Country        string     `json:"country,omitempty"`
+	Temperature    float64    `json:"temperature"`
+	Description    string     `json:"description"`
+	Condition      string     `json:"condition,omitempty"`
+	IconURL        string     `json:"icon_url,omitempty"`
+	UVIndex        *float64   `json:"uv_index,omitempty"`
+	FeelsLike      *float64   `json:"feels_like,omitempty"`
+	TempMin        *float64   `json:"temp_min,omitempty"`
+	TempMax        *float64   `json:"temp_max,omitempty"`
+	Humidity       *float64   `json:"humidity,omitempty"`
+	Pressure       *float64   `json:"pressure,omitempty"`
+	WindSpeed      *float64   `json:"wind_speed,omitempty"`
+	WindDirection  *float64   `json:"wind_direction,omitempty"`
+	Sunrise        *time.Time `json:"sunrise,omitempty"`
+	Sunset         *time.Time `json:"sunset,omitempty"`
+	DayLength      int64      `json:"day_length,omitempty"`
+	TimezoneOffset *int64     `json:"timezone_offset,omitempty"`
+	ObservedAt     *time.Time `json:"observed_at,omitempty"`
+	Cached         bool       `json:"cached"`
+	Stale          bool       `json:"stale,omitempty"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// toGeoJSONFeature places weather at its location's coordinates in the city dataset.
+func toGeoJSONFeature(weather *model.WeatherResponse) geoJSONFeature {
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Properties: geoJSONProperties{
+			Location:       weather.Location,
+			Temperature:    weather.Temperature,
+			Description:    weather.Description,
+			Condition:      string(weather.Condition),
+			IconURL:        weather.IconURL,
+			UVIndex:        weather.UVIndex,
+			FeelsLike:      weather.FeelsLike,
+			TempMin:        weather.TempMin,
+			TempMax:        weather.TempMax,
+			Humidity:       weather.Humidity,
+			Pressure:       weather.Pressure,
+			WindSpeed:      weather.WindSpeed,
+			WindDirection:  weather.WindDirection,
+			Sunrise:        weather.Sunrise,
+			Sunset:         weather.Sunset,
+			DayLength:      weather.DayLength,
+			TimezoneOffset: weather.TimezoneOffset,
+			ObservedAt:     weather.ObservedAt,
+			Cached:         weather.Cached,
+			Stale:          weather.Stale,
+		},
+	}
+	if city, ok := geodata.Lookup(weather.Location); ok {
+		feature.Geometry = &geoJSONPoint{Type: "Point", Coordinates: [2]float64{city.Lon, city.Lat}}
+		feature.Properties.Country = city.Country
+	}
+	return feature
+}
+
+// toGeoJSONFeatureCollection wraps the weather of several locations, in order.
+func toGeoJSONFeatureCollection(weather []*model.WeatherResponse) geoJSONFeatureCollection {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(weather))}
+	for _, wr := range weather {
+		collection.Features = append(collection.Features, toGeoJSONFeature(wr))
+	}
+	return collection
+}
+
+// writeGeoJSON writes v as a bare GeoJSON object. Like the Home Assistant shape it is fixed by the
+// specification, so the configured key casing, envelope and encoding do not apply.
+func writeGeoJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		errMsg := "Failed to encode response"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	w.Header().Set("Content-Type", geoJSONContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(body, '\n'))
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func TestHandleWeather_GeoJSONFormat(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "Jakarta", Temperature: 30.5, Description: "light rain", Condition: model.ConditionRain, Cached: true,
+	}})
+
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=Jakarta&format=geojson", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != geoJSONContentType {
+		t.Errorf("Expected Content-Type %s, got %s", geoJSONContentType, ct)
+	}
+	var feature geoJSONFeature
+	if err := json.NewDecoder(rr.Body).Decode(&feature); err != nil {
+		t.Fatalf("Failed to decode feature: %v", err)
+	}
+	if feature.Type != "Feature" || feature.Geometry == nil || feature.Geometry.Type != "Point" {
+		t.Fatalf("Unexpected feature %+v", feature)
+	}
+	if feature.Geometry.Coordinates != [2]float64{106.8451, -6.2146} {
+		t.Errorf("Expected [lon, lat] coordinates, got %v", feature.Geometry.Coordinates)
+	}
+	if p := feature.Properties; p.Location != "Jakarta" || p.Country != "ID" || p.Temperature != 30.5 || p.Description != "light rain" || p.Condition != "rain" || !p.Cached {
+		t.Errorf("Unexpected properties %+v", p)
+	}
+}
+
+func TestToGeoJSONFeature_UnknownLocation(t *testing.T) {
+	body, err := json.Marshal(toGeoJSONFeature(&model.WeatherResponse{Location: "Atlantis"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(body, &decoded)
+	if g, ok := decoded["geometry"]; !ok || g != nil {
+		t.Errorf("Expected a null geometry, got %s", body)
+	}
+}
+
+func TestToGeoJSONFeatureCollection(t *testing.T) {
+	collection := toGeoJSONFeatureCollection([]*model.WeatherResponse{{Location: "Jakarta"}, {Location: "London"}})
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 || collection.Features[1].Properties.Location != "London" {
+		t.Errorf("Unexpected collection %+v", collection)
+	}
+	if empty := toGeoJSONFeatureCollection(nil); empty.Features == nil {
+		t.Error("Expected an empty features array, not null")
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/encoding"
+	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// formatHomeAssistant selects the Home Assistant response shape, via ?format= or an API key's format.
+const formatHomeAssistant = "homeassistant"
+
+// homeAssistantState is the entity-style shape read by Home Assistant's RESTful sensor: the
+// condition is the state and everything else is an attribute, so `value_template` and
+// `json_attributes_path` can be left at their defaults.
+type homeAssistantState struct {
+	State      string                  `json:"state"`
+	Attributes homeAssistantAttributes `json:"attributes"`
+}
+
+type homeAssistantAttributes struct {
+	FriendlyName    string   `json:"friendly_name"`
+	Temperature     float64  `json:"temperature"`
+	TemperatureUnit string   `json:"temperature_unit"`
+	Description     string   `json:"description"`
+	UVIndex         *float64 `json:"uv_index,omitempty"`
+	// The details use the names of Home Assistant's weather entity attributes.
+	ApparentTemperature *float64 `json:"apparent_temperature,omitempty"`
+	Humidity            *float64 `json:"humidity,omitempty"`
+	Pressure            *float64 `json:"pressure,omitempty"`
+	PressureUnit        string   `json:"pressure_unit,omitempty"`
+	WindSpeed           *float64 `json:"wind_speed,omitempty"`
+	WindSpeedUnit       string   `json:"wind_speed_unit,omitempty"`
+	WindBearing         *float64 `json:"wind_bearing,omitempty"`
+	Attribution         string   `json:"attribution"`
+	Cached              bool     `json:"cached"`
+	Stale               bool     `json:"stale"`
+}
+
+// responseShape returns the requested response shape: the format query parameter, else the
+// format configured for the authenticated API key. ok is false for an unknown format.
+func responseShape(r *http.Request, format string) (shape string, ok bool) {
+	if format == "" {
+		if key, found := middleware.APIKeyFromContext(r.Context()); found {
+			format = key.Format
+		}
+	}
+	switch strings.ToLower(format) {
+	case "", "default":
+		return "", true
+	case formatHomeAssistant:
+		return formatHomeAssistant, true
+	case formatDisplay:
+		return formatDisplay, true
+	case formatGeoJSON:
+		return formatGeoJSON, true
+	default:
+		return "", false
+	}
+}
+
+// writeHomeAssistant writes weather as a bare Home Assistant state object. The shape is fixed, so
+// the configured key casing, envelope and encoding do not apply.
+func writeHomeAssistant(w http.ResponseWriter, weather *model.WeatherResponse) {
+	state := homeAssistantState{
+		State: homeAssistantCondition(weather.Description),
+		Attributes: homeAssistantAttributes{
+			FriendlyName:        weather.Location,
+			Temperature:         weather.Temperature,
+			TemperatureUnit:     "°C",
+			Description:         weather.Description,
+			UVIndex:             weather.UVIndex,
+			ApparentTemperature: weather.FeelsLike,
+			Humidity:            weather.Humidity,
+			Pressure:            weather.Pressure,
+			WindSpeed:           weather.WindSpeed,
+			WindBearing:         weather.WindDirection,
+			Attribution:         "Data provided by OpenWeatherMap",
+			Cached:              weather.Cached,
+			Stale:               weather.Stale,
+		},
+	}
+	if weather.Pressure != nil {
+		state.Attributes.PressureUnit = "hPa"
+	}
+	if weather.WindSpeed != nil {
+		state.Attributes.WindSpeedUnit = "m/s"
+	}
+	writeFormattedResponse(w, http.StatusOK, model.Response{Data: state}, responseFormat{keyCase: "snake", encoder: encoding.JSON}, 0)
+}
+
+// homeAssistantCondition maps an OpenWeatherMap description onto Home Assistant's weather
+// condition vocabulary, falling back to "exceptional".
+func homeAssistantCondition(description string) string {
+	d := strings.ToLower(description)
+	switch {
+	case strings.Contains(d, "thunderstorm") && (strings.Contains(d, "rain") || strings.Contains(d, "drizzle")):
+		return "lightning-rainy"
+	case strings.Contains(d, "thunderstorm"):
+		return "lightning"
+	case strings.Contains(d, "sleet") || (strings.Contains(d, "snow") && strings.Contains(d, "rain")):
+		return "snowy-rainy"
+	case strings.Contains(d, "snow"):
+		return "snowy"
+	case strings.Contains(d, "hail"):
+		return "hail"
+	case strings.Contains(d, "heavy") && strings.Contains(d, "rain"), strings.Contains(d, "extreme rain"):
+		return "pouring"
+	case strings.Contains(d, "rain"), strings.Contains(d, "drizzle"):
+		return "rainy"
+	case strings.Contains(d, "fog"), strings.Contains(d, "mist"), strings.Contains(d, "haze"), strings.Contains(d, "smoke"):
+		return "fog"
+	case strings.Contains(d, "squall"), strings.Contains(d, "tornado"), strings.Contains(d, "wind"):
+		return "windy"
+	case strings.Contains(d, "few clouds"), strings.Contains(d, "scattered clouds"):
+		return "partlycloudy"
+	case strings.Contains(d, "cloud"):
+		return "cloudy"
+	case strings.Contains(d, "clear"):
+		return "sunny"
+	default:
+		return "exceptional"
+	}
+}
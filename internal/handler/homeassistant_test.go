@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+func TestHomeAssistantCondition(t *testing.T) {
+	tests := map[string]string{
+		"clear sky":                     "sunny",
+		"few clouds":                    "partlycloudy",
+		"overcast clouds":               "cloudy",
+		"light rain":                    "rainy",
+		"heavy intensity rain":          "pouring",
+		"thunderstorm with light rain":  "lightning-rainy",
+		"thunderstorm":                  "lightning",
+		"light snow":                    "snowy",
+		"light rain and snow":           "snowy-rainy",
+		"mist":                          "fog",
+		"squalls":                       "windy",
+		"volcanic ash":                  "exceptional",
+		"Clear Sky (capitalised input)": "sunny",
+	}
+	for in, want := range tests {
+		if got := homeAssistantCondition(in); got != want {
+			t.Errorf("homeAssistantCondition(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func decodeHomeAssistant(t *testing.T, rr *httptest.ResponseRecorder) homeAssistantState {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var state homeAssistantState
+	if err := json.NewDecoder(rr.Body).Decode(&state); err != nil {
+		t.Fatalf("Failed to decode Home Assistant state: %v", err)
+	}
+	return state
+}
+
+func TestHandleWeather_HomeAssistantFormat(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true,
+	}})
+
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&format=homeassistant", nil))
+	state := decodeHomeAssistant(t, rr)
+	if state.State != "sunny" || state.Attributes.FriendlyName != "London" || state.Attributes.Temperature != 15.2 {
+		t.Errorf("Unexpected state %+v", state)
+	}
+	if state.Attributes.TemperatureUnit != "°C" || !state.Attributes.Cached {
+		t.Errorf("Unexpected attributes %+v", state.Attributes)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", rr.Code)
+	}
+}
+
+func TestHandleWeather_HomeAssistantFormatFromAPIKey(t *testing.T) {
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "ha-key", "name": "home", "format": "homeassistant"},
+	})
+	defer func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	}()
+
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{Location: "Oslo", Description: "light snow"}})
+	route := middleware.AuthMiddleware(http.HandlerFunc(h.HandleWeather))
+
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=Oslo", nil)
+	req.Header.Set(middleware.APIKeyHeader, "ha-key")
+	rr := httptest.NewRecorder()
+	route.ServeHTTP(rr, req)
+	if state := decodeHomeAssistant(t, rr); state.State != "snowy" {
+		t.Errorf("Expected the key's format to apply, got %+v", state)
+	}
+
+	// An explicit format overrides the key's default
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=Oslo&format=default", nil)
+	req.Header.Set(middleware.APIKeyHeader, "ha-key")
+	rr = httptest.NewRecorder()
+	route.ServeHTTP(rr, req)
+	var resp model.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.Message != "Success" {
+		t.Errorf("Expected the standard envelope, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// includeBlocks are the optional blocks of a weather response that ?include= selects, by name,
+// each with the function that leaves it out. The location, temperature, description and condition
+// are always returned.
+var includeBlocks = map[string]func(*model.WeatherResponse){
+	"wind":       func(w *model.WeatherResponse) { w.WindSpeed, w.WindDirection = nil, nil },
+	"humidity":   func(w *model.WeatherResponse) { w.Humidity = nil },
+	"pressure":   func(w *model.WeatherResponse) { w.Pressure = nil },
+	"uv":         func(w *model.WeatherResponse) { w.UVIndex = nil },
+	"feels_like": func(w *model.WeatherResponse) { w.FeelsLike = nil },
+	"temp_range": func(w *model.WeatherResponse) { w.TempMin, w.TempMax = nil, nil },
+	"sun":        func(w *model.WeatherResponse) { w.Sunrise, w.Sunset, w.DayLength = nil, nil, 0 },
+	"time":       func(w *model.WeatherResponse) { w.TimezoneOffset, w.ObservedAt = nil, nil },
+}
+
+// includeSet holds the blocks named by ?include=. A nil set includes every block.
+type includeSet map[string]bool
+
+// includeParam reads ?include=, a comma-separated list of blocks. It returns a nil set when the
+// parameter is absent, and the error message to answer with when it names an unknown block.
+func includeParam(query url.Values) (includeSet, string) {
+	if !query.Has("include") {
+		return nil, ""
+	}
+	include := includeSet{}
+	for _, name := range strings.Split(query.Get("include"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := includeBlocks[name]; !ok {
+			return nil, "Invalid 'include' query parameter: unknown block '" + name + "', want one of " +
+				strings.Join(slices.Sorted(maps.Keys(includeBlocks)), ", ")
+		}
+		include[name] = true
+	}
+	return include, ""
+}
+
+// apply returns weather with the blocks outside s left out. The service may return shared cached
+// values, so they are left out of a copy; weather itself is returned when s includes everything.
+func (s includeSet) apply(weather *model.WeatherResponse) *model.WeatherResponse {
+	if s == nil {
+		return weather
+	}
+	filtered := *weather
+	for name, leaveOut := range includeBlocks {
+		if !s[name] {
+			leaveOut(&filtered)
+		}
+	}
+	return &filtered
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestIncludeParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    includeSet
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"include=", includeSet{}, ""},
+		{"include=wind,humidity,pressure", includeSet{"wind": true, "humidity": true, "pressure": true}, ""},
+		{"include=Wind,%20sun,,", includeSet{"wind": true, "sun": true}, ""},
+		{"include=wind,rain", nil, "unknown block 'rain'"},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, errMsg := includeParam(query)
+		if !reflect.DeepEqual(got, tt.want) || !strings.Contains(errMsg, tt.wantErr) || (errMsg == "") != (tt.wantErr == "") {
+			t.Errorf("%q: expected %v %q, got %v %q", tt.query, tt.want, tt.wantErr, got, errMsg)
+		}
+	}
+}
+
+func TestWeatherHandler_HandleWeather_Include(t *testing.T) {
+	cached := benchmarkWeather("London")
+	original := *cached
+	handler := &WeatherHandler{
+		WeatherService: &mockWeatherService{mockData: cached},
+		Quality:        &config.QualityConfig{Enabled: true, MaxAge: time.Hour},
+	}
+
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&include=wind,humidity", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp struct {
+		Data    map[string]any `json:"data"`
+		Quality struct {
+			Completeness float64 `json:"completeness"`
+		} `json:"quality"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range resp.Data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	want := []string{"cached", "condition", "description", "humidity", "icon_url", "location", "temperature", "wind_direction", "wind_speed"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("Expected fields %v, got %v", want, keys)
+	}
+	// The blocks left out still count as known
+	if resp.Quality.Completeness != 1 {
+		t.Errorf("Expected completeness scored on the full weather, got %v", resp.Quality.Completeness)
+	}
+	if !reflect.DeepEqual(*cached, original) {
+		t.Errorf("Expected the cached weather left intact, got %+v", *cached)
+	}
+
+	// Without include every block is returned
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+	if !strings.Contains(rr.Body.String(), `"sunrise"`) || !strings.Contains(rr.Body.String(), `"pressure"`) {
+		t.Errorf("Expected every block without include, got %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&include=rain", nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "unknown block 'rain'") {
+		t.Errorf("Expected 400 for an unknown block, got %d: %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// JobsHandler shows and triggers background jobs.
+type JobsHandler struct{}
+
+func NewJobsHandler() *JobsHandler {
+	return &JobsHandler{}
+}
+
+// HandleJobs serves GET /admin/jobs: every background job with its last run, duration, outcome
+// and next scheduled run.
+func (h *JobsHandler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: jobs.List(), Message: "Success"})
+}
+
+// HandleJob serves POST /admin/jobs/{name}/run, which starts the job immediately and returns 202
+// without waiting for it to finish. A job that is already running gets a 409.
+func (h *JobsHandler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/jobs/"), "/")
+	if _, ok := jobs.Get(name); !ok || action != "run" {
+		errMsg := "Job not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if !serveMethods(w, r, writeResponse, http.MethodPost) {
+		return
+	}
+
+	if err := jobs.Trigger(name); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, jobs.ErrRunning):
+			status = http.StatusConflict
+		case errors.Is(err, jobs.ErrNotFound):
+			status = http.StatusNotFound
+		}
+		errMsg := err.Error()
+		writeResponse(w, status, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	logger(r.Context()).Infow("Background job triggered via admin API", "job", name)
+	status, _ := jobs.Get(name)
+	writeResponse(w, http.StatusAccepted, model.Response{Data: status, Message: "Accepted"})
+}
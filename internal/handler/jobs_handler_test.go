@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+)
+
+func TestJobsHandler(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+	jobs.New("handler_test", time.Hour, func(context.Context) error {
+		<-release
+		close(done)
+		return nil
+	})
+	h := NewJobsHandler()
+
+	w := httptest.NewRecorder()
+	h.HandleJob(w, httptest.NewRequest(http.MethodPost, "/admin/jobs/handler_test/run", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.HandleJob(w, httptest.NewRequest(http.MethodPost, "/admin/jobs/handler_test/run", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while running, got %d", w.Code)
+	}
+	close(release)
+	<-done
+
+	var resp struct {
+		Data []jobs.Status `json:"data"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w = httptest.NewRecorder()
+		h.HandleJobs(w, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Expected JSON, got %v", err)
+		}
+		var found *jobs.Status
+		for i := range resp.Data {
+			if resp.Data[i].Name == "handler_test" {
+				found = &resp.Data[i]
+			}
+		}
+		if found == nil {
+			t.Fatalf("Expected the job listed, got %+v", resp.Data)
+		}
+		if found.Runs == 1 {
+			if found.LastOutcome != jobs.OutcomeSuccess || found.LastRun == nil || found.Interval != "1h0m0s" {
+				t.Errorf("Unexpected status %+v", found)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the run recorded, got %+v", found)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobsHandler_Errors(t *testing.T) {
+	jobs.New("handler_test_errors", time.Hour, func(context.Context) error { return nil })
+	h := NewJobsHandler()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"unknown job", http.MethodPost, "/admin/jobs/nope/run", http.StatusNotFound},
+		{"unknown action", http.MethodPost, "/admin/jobs/handler_test_errors/pause", http.StatusNotFound},
+		{"wrong method", http.MethodGet, "/admin/jobs/handler_test_errors/run", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleJob(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+	w := httptest.NewRecorder()
+	h.HandleJobs(w, httptest.NewRequest(http.MethodPost, "/admin/jobs", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"go.uber.org/zap"
+)
+
+// logger returns the handler module logger, whose level is set by logging.levels.handler, annotated
+// with the request fields carried by ctx. Requests with forced debug tracing log at debug level.
+func logger(ctx context.Context) *zap.SugaredLogger {
+	return logctx.Module(ctx, "handler")
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// allowedMethods returns the Allow list of a route serving methods: HEAD is added wherever GET is
+// served, and OPTIONS always.
+func allowedMethods(methods ...string) []string {
+	allowed := slices.Clone(methods)
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	return append(allowed, http.MethodOptions)
+}
+
+// methodServed reports whether a route serving methods serves method itself, HEAD included
+// wherever GET is. It does not allocate, so hot routes can check it before building a responder.
+func methodServed(method string, methods ...string) bool {
+	return slices.Contains(methods, method) || method == http.MethodHead && slices.Contains(methods, http.MethodGet)
+}
+
+// serveMethods answers the methods a route does not implement itself and reports whether r should
+// be served. HEAD is served wherever GET is; net/http drops the body of HEAD responses. OPTIONS
+// gets 204 with the Allow list, and any other method a 405 written by respond.
+func serveMethods(w http.ResponseWriter, r *http.Request, respond func(w http.ResponseWriter, status int, resp model.Response), methods ...string) bool {
+	if methodServed(r.Method, methods...) {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(allowedMethods(methods...), ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	errMsg := "Method not allowed"
+	respond(w, http.StatusMethodNotAllowed, model.Response{Error: &errMsg, Message: "Error"})
+	return false
+}
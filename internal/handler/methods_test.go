@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeMethods(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		served     []string
+		wantServe  bool
+		wantStatus int
+		wantAllow  string
+	}{
+		{"get", http.MethodGet, []string{http.MethodGet}, true, http.StatusOK, ""},
+		{"head with get", http.MethodHead, []string{http.MethodGet}, true, http.StatusOK, ""},
+		{"options", http.MethodOptions, []string{http.MethodGet}, false, http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"not allowed", http.MethodDelete, []string{http.MethodGet, http.MethodPost}, false, http.StatusMethodNotAllowed, "GET, POST, HEAD, OPTIONS"},
+		{"head without get", http.MethodHead, []string{http.MethodPost}, false, http.StatusMethodNotAllowed, "POST, OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			serve := serveMethods(rr, httptest.NewRequest(tt.method, "/", nil), writeResponse, tt.served...)
+			if serve != tt.wantServe {
+				t.Errorf("Expected serve %v, got %v", tt.wantServe, serve)
+			}
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.wantAllow, got)
+			}
+		})
+	}
+}
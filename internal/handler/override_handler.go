@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// maxOverrideBody caps the size of override request bodies.
+const maxOverrideBody = 16 << 10
+
+// OverrideHandler lets operators pin the weather of a location, e.g. while the provider reports
+// garbage for it.
+type OverrideHandler struct {
+	Store  repository.OverrideStore
+	MaxTTL time.Duration
+}
+
+func NewOverrideHandler(store ...repository.OverrideStore) *OverrideHandler {
+	h := &OverrideHandler{MaxTTL: config.GetOverrideConfig().MaxTTL}
+	if len(store) > 0 && store[0] != nil {
+		h.Store = store[0]
+	} else {
+		h.Store = repository.NewOverrideStore()
+	}
+	return h
+}
+
+// overrideRequest is the body of PUT /admin/override. Exactly one of ExpiresAt and TTL is required.
+type overrideRequest struct {
+	Temperature *float64   `json:"temperature"`
+	Description string     `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	TTL         string     `json:"ttl"`
+	Reason      string     `json:"reason"`
+}
+
+// HandleOverride serves /admin/override?location=X. PUT pins the weather of the location until
+// the override expires, DELETE lifts it, and GET shows it, or every override without a location.
+// Only requests authenticated with an API key are served.
+func (h *OverrideHandler) HandleOverride(w http.ResponseWriter, r *http.Request) {
+	h.serveOverride(w, r, r.URL.Query().Get("location"))
+}
+
+// HandleOverrides serves the same as HandleOverride with the location as the ID of the
+// resource: GET /admin/overrides lists the overrides, and GET, PUT and DELETE
+// /admin/overrides/{location} manage one, under its canonical spelling.
+func (h *OverrideHandler) HandleOverrides(w http.ResponseWriter, r *http.Request) {
+	location := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/overrides"), "/")
+	if location == "" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		serveMethods(w, r, writeResponse, http.MethodGet)
+		return
+	}
+	h.serveOverride(w, r, location)
+}
+
+// serveOverride serves the override of location, or every override for a GET without one.
+// Writes honour If-Match and If-None-Match, see writePrecondition.
+func (h *OverrideHandler) serveOverride(w http.ResponseWriter, r *http.Request, location string) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	key, ok := middleware.APIKeyFromContext(r.Context())
+	if !ok {
+		errMsg := "Overrides require an API key"
+		writeResponse(w, http.StatusUnauthorized, model.Response{Error: &errMsg, Message: "Unauthorized"})
+		return
+	}
+
+	if location == "" && r.Method == http.MethodGet {
+		h.listOverrides(w, r)
+		return
+	}
+	location, err := geodata.Canonicalize(location, config.IsGeodataStrict())
+	if err != nil {
+		errMsg := "Invalid location: " + err.Error()
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	current, err := h.Store.Get(r.Context(), location)
+	if err != nil && !errors.Is(err, repository.ErrOverrideNotFound) {
+		logger(r.Context()).Errorw("Failed to read override", "location", location, "error", err)
+		errMsg := "Failed to read override"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if r.Method != http.MethodPut && current == nil {
+		errMsg := "No override for location"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if notModified(w, r, current.Revision) {
+			return
+		}
+		setResourceETag(w, current.Revision)
+		writeResponse(w, http.StatusOK, model.Response{Data: current, Message: "Success"})
+		return
+	}
+
+	var currentRevision string
+	if current != nil {
+		currentRevision = current.Revision
+	}
+	revision, ok := writePrecondition(w, r, currentRevision)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodPut {
+		h.setOverride(w, r, location, key.Name, revision, current == nil)
+		return
+	}
+	h.deleteOverride(w, r, location, revision)
+}
+
+// setOverride stores the override in the body of a PUT, at revision when given. A new override
+// gets 201 Created.
+func (h *OverrideHandler) setOverride(w http.ResponseWriter, r *http.Request, location, setBy, revision string, created bool) {
+	var req overrideRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOverrideBody)).Decode(&req); err != nil {
+		errMsg := "Invalid JSON body"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	now := time.Now().UTC()
+	expiresAt, errMsg := h.overrideExpiry(&req, now)
+	if errMsg == "" && req.Temperature == nil {
+		errMsg = "'temperature' is required"
+	}
+	if errMsg == "" && strings.TrimSpace(req.Description) == "" {
+		errMsg = "'description' is required"
+	}
+	if errMsg != "" {
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	o := &repository.Override{
+		Location: location,
+		Weather: model.WeatherResponse{
+			Location:    location,
+			Temperature: model.RoundTemperature(*req.Temperature, config.GetTemperaturePrecision()),
+			Description: strings.TrimSpace(req.Description),
+		},
+		ExpiresAt: expiresAt,
+		Reason:    req.Reason,
+		SetBy:     setBy,
+		SetAt:     now,
+		Revision:  revision,
+	}
+	err := h.Store.Set(r.Context(), o)
+	switch {
+	case errors.Is(err, repository.ErrRevisionMismatch), errors.Is(err, repository.ErrOverrideNotFound):
+		// Changed, created or lifted since it was read
+		writePreconditionFailed(w, "")
+		return
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to set override", "location", location, "error", err)
+		errMsg := "Failed to set override"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	logger(r.Context()).Warnw("Weather override set", "location", location, "expiresAt", expiresAt, "setBy", setBy, "reason", req.Reason)
+	setResourceETag(w, o.Revision)
+	status := http.StatusOK
+	if created {
+		w.Header().Set("Location", "/admin/overrides/"+url.PathEscape(location))
+		status = http.StatusCreated
+	}
+	writeResponse(w, status, model.Response{Data: o, Message: "Success"})
+}
+
+// overrideExpiry returns when the override in req expires, or a message explaining why its
+// expiry is invalid. The expiry is mandatory and at most MaxTTL away.
+func (h *OverrideHandler) overrideExpiry(req *overrideRequest, now time.Time) (time.Time, string) {
+	var expiresAt time.Time
+	switch {
+	case req.ExpiresAt != nil && req.TTL != "":
+		return time.Time{}, "Set either 'expires_at' or 'ttl', not both"
+	case req.ExpiresAt != nil:
+		expiresAt = req.ExpiresAt.UTC()
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return time.Time{}, "'ttl' must be a duration such as 30m"
+		}
+		expiresAt = now.Add(ttl)
+	default:
+		return time.Time{}, "An expiry is required: set 'expires_at' or 'ttl'"
+	}
+	if !expiresAt.After(now) {
+		return time.Time{}, "The expiry must be in the future"
+	}
+	if expiresAt.Sub(now) > h.MaxTTL {
+		return time.Time{}, "The expiry must be at most " + h.MaxTTL.String() + " away"
+	}
+	return expiresAt, ""
+}
+
+// deleteOverride lifts the override of location, at revision when given.
+func (h *OverrideHandler) deleteOverride(w http.ResponseWriter, r *http.Request, location, revision string) {
+	err := h.Store.Delete(r.Context(), location, revision)
+	switch {
+	case errors.Is(err, repository.ErrRevisionMismatch):
+		writePreconditionFailed(w, "")
+	case errors.Is(err, repository.ErrOverrideNotFound):
+		errMsg := "No override for location"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to delete override", "location", location, "error", err)
+		errMsg := "Failed to delete override"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+	default:
+		logger(r.Context()).Warnw("Weather override lifted", "location", location)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *OverrideHandler) listOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := h.Store.List(r.Context())
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to list overrides", "error", err)
+		errMsg := "Failed to list overrides"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if overrides == nil {
+		overrides = []*repository.Override{}
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: overrides, Message: "Success"})
+}
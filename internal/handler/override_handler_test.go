@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/spf13/viper"
+)
+
+type mockOverrideStore struct {
+	overrides map[string]*repository.Override
+	// writes numbers the revisions of stored overrides.
+	writes int
+}
+
+func (m *mockOverrideStore) Set(_ context.Context, o *repository.Override) error {
+	current, ok := m.overrides[o.Location]
+	switch {
+	case o.Revision == repository.RevisionNone && ok:
+		return repository.ErrRevisionMismatch
+	case o.Revision != "" && o.Revision != repository.RevisionNone && !ok:
+		return repository.ErrOverrideNotFound
+	case o.Revision != "" && o.Revision != repository.RevisionNone && o.Revision != current.Revision:
+		return repository.ErrRevisionMismatch
+	}
+	m.writes++
+	o.Revision = "rev-" + strconv.Itoa(m.writes)
+	m.overrides[o.Location] = o
+	return nil
+}
+
+func (m *mockOverrideStore) Get(_ context.Context, location string) (*repository.Override, error) {
+	if o, ok := m.overrides[location]; ok {
+		return o, nil
+	}
+	return nil, repository.ErrOverrideNotFound
+}
+
+func (m *mockOverrideStore) Delete(_ context.Context, location, revision string) error {
+	current, ok := m.overrides[location]
+	if !ok {
+		return repository.ErrOverrideNotFound
+	}
+	if revision != "" && revision != current.Revision {
+		return repository.ErrRevisionMismatch
+	}
+	delete(m.overrides, location)
+	return nil
+}
+
+func (m *mockOverrideStore) List(context.Context) ([]*repository.Override, error) {
+	var out []*repository.Override
+	for _, o := range m.overrides {
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// newOverrideRoute serves h behind auth with the key "ops-key", named "ops".
+func newOverrideRoute(t *testing.T, h *OverrideHandler) http.Handler {
+	t.Helper()
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{{"key": "ops-key", "name": "ops"}})
+	t.Cleanup(func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	})
+	return middleware.AuthMiddleware(http.HandlerFunc(h.HandleOverride))
+}
+
+func TestHandleOverride(t *testing.T) {
+	store := &mockOverrideStore{overrides: map[string]*repository.Override{}}
+	route := newOverrideRoute(t, &OverrideHandler{Store: store, MaxTTL: 24 * time.Hour})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(middleware.APIKeyHeader, "ops-key")
+		w := httptest.NewRecorder()
+		route.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/admin/override?location=Jakarta", `{"temperature": 30.123, "description": "clear sky", "ttl": "2h", "reason": "provider reports snow"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/admin/overrides/Jakarta" {
+		t.Fatalf("Expected 201 with a Location, got %d: %s", w.Code, w.Body)
+	}
+	o := store.overrides["Jakarta"]
+	if o == nil || o.Weather.Description != "clear sky" || o.SetBy != "ops" {
+		t.Fatalf("Unexpected override %+v", o)
+	}
+	if ttl := time.Until(o.ExpiresAt); ttl < time.Hour || ttl > 2*time.Hour {
+		t.Errorf("Expected the override to expire in 2h, got %v", ttl)
+	}
+
+	if w := do(http.MethodGet, "/admin/override?location=Jakarta", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	w = do(http.MethodGet, "/admin/override", "")
+	var list struct {
+		Data []repository.Override `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Data) != 1 {
+		t.Errorf("Expected one override listed, got %s", w.Body)
+	}
+
+	if w := do(http.MethodDelete, "/admin/override?location=Jakarta", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/override?location=Jakarta", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleOverride_Invalid(t *testing.T) {
+	store := &mockOverrideStore{overrides: map[string]*repository.Override{}}
+	route := newOverrideRoute(t, &OverrideHandler{Store: store, MaxTTL: time.Hour})
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Invalid JSON", body: `{`},
+		{name: "No expiry", body: `{"temperature": 1, "description": "x"}`},
+		{name: "Both expiries", body: `{"temperature": 1, "description": "x", "ttl": "1m", "expires_at": "` + past + `"}`},
+		{name: "Expired", body: `{"temperature": 1, "description": "x", "expires_at": "` + past + `"}`},
+		{name: "Beyond max TTL", body: `{"temperature": 1, "description": "x", "ttl": "2h"}`},
+		{name: "Invalid TTL", body: `{"temperature": 1, "description": "x", "ttl": "soon"}`},
+		{name: "No temperature", body: `{"description": "x", "ttl": "1m"}`},
+		{name: "No description", body: `{"temperature": 1, "ttl": "1m"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/admin/override?location=Jakarta", strings.NewReader(tt.body))
+			req.Header.Set(middleware.APIKeyHeader, "ops-key")
+			w := httptest.NewRecorder()
+			route.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", w.Code)
+			}
+		})
+	}
+	if len(store.overrides) != 0 {
+		t.Errorf("Expected no override stored, got %v", store.overrides)
+	}
+}
+
+func TestHandleOverride_RequiresAPIKey(t *testing.T) {
+	h := &OverrideHandler{Store: &mockOverrideStore{overrides: map[string]*repository.Override{}}, MaxTTL: time.Hour}
+	w := httptest.NewRecorder()
+	h.HandleOverride(w, httptest.NewRequest(http.MethodPut, "/admin/override?location=Jakarta", strings.NewReader(`{}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without an API key, got %d", w.Code)
+	}
+}
+
+func TestHandleOverrides_ByLocation(t *testing.T) {
+	store := &mockOverrideStore{overrides: map[string]*repository.Override{}}
+	h := &OverrideHandler{Store: store, MaxTTL: 24 * time.Hour}
+	newOverrideRoute(t, h)
+	route := middleware.AuthMiddleware(http.HandlerFunc(h.HandleOverrides))
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(middleware.APIKeyHeader, "ops-key")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		route.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"temperature": 30, "description": "clear sky", "ttl": "2h"}`
+
+	w := do(http.MethodPut, "/admin/overrides/jakarta", body, "If-None-Match", "*")
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") != `"rev-1"` || store.overrides["Jakarta"] == nil {
+		t.Fatalf("Expected 201 creating the override under its canonical location, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	if w := do(http.MethodPut, "/admin/overrides/Jakarta", body, "If-None-Match", "*"); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 creating an existing override, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/overrides/Jakarta", "", "If-None-Match", `"rev-1"`); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for the current ETag, got %d", w.Code)
+	}
+
+	w = do(http.MethodPut, "/admin/overrides/Jakarta", body, "If-Match", `"rev-1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"rev-2"` {
+		t.Errorf("Expected 200 replacing the current revision, got %d %v", w.Code, w.Header())
+	}
+	if w := do(http.MethodPut, "/admin/overrides/Jakarta", body, "If-Match", `"rev-1"`); w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"rev-2"` {
+		t.Errorf("Expected 412 with the current ETag for a stale If-Match, got %d %v", w.Code, w.Header())
+	}
+	if w := do(http.MethodDelete, "/admin/overrides/Jakarta", "", "If-Match", `"rev-1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 lifting a stale revision, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/admin/overrides", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"location":"Jakarta"`) {
+		t.Errorf("Expected the override listed, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodDelete, "/admin/overrides", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 deleting the collection, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/overrides/Jakarta", "", "If-Match", `"rev-2"`); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/overrides/Jakarta", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after lifting, got %d", w.Code)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build perf
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+)
+
+func TestPerf_CacheHit(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true,
+	}})
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	testutil.CheckPerf(t, "CacheHit", func() { h.HandleWeather(w, req) })
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+// PollHandler holds weather requests until a location's conditions change, a middle ground
+// between polling /weather and a push connection.
+type PollHandler struct {
+	WeatherService service.WeatherServiceInterface
+	// Config is read once by NewPollHandler. When nil, the current config is used.
+	Config *config.PollConfig
+}
+
+func NewPollHandler(svc ...service.WeatherServiceInterface) *PollHandler {
+	cfg := config.GetPollConfig()
+	if len(svc) > 0 && svc[0] != nil {
+		return &PollHandler{WeatherService: svc[0], Config: &cfg}
+	}
+	return &PollHandler{WeatherService: service.NewWeatherService(), Config: &cfg}
+}
+
+func (h *PollHandler) pollConfig() config.PollConfig {
+	if h.Config == nil {
+		return config.GetPollConfig()
+	}
+	return *h.Config
+}
+
+// HandlePoll serves GET /weather/poll?location=X&etag=E&timeout=30. Without an etag, or with one
+// that no longer matches, the current weather is returned at once with its ETag. Otherwise the
+// request is held until the cached weather changes, answered 200 with the new weather, or the
+// timeout (in seconds, up to poll.max_timeout) elapses, answered 304. The etag may also be sent
+// as If-None-Match.
+func (h *PollHandler) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	cfg := h.pollConfig()
+	q := r.URL.Query()
+	location := q.Get("location")
+	if location == "" {
+		errMsg := "Missing 'location' query parameter"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	location, err := geodata.Canonicalize(location, config.IsGeodataStrict())
+	if err != nil {
+		errMsg := err.Error()
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	timeout := cfg.DefaultTimeout
+	if q.Has("timeout") {
+		maxSeconds := int(cfg.MaxTimeout / time.Second)
+		n, err := strconv.Atoi(q.Get("timeout"))
+		if err != nil || n < 1 || n > maxSeconds {
+			errMsg := "'timeout' must be a whole number of seconds from 1 to " + strconv.Itoa(maxSeconds)
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+	etag := q.Get("etag")
+	if etag == "" {
+		etag = r.Header.Get("If-None-Match")
+	}
+	etag = strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+
+	// Subscribe before the first lookup, so a change in between is not missed
+	ctx := r.Context()
+	changed := make(chan model.WeatherResponse, 1)
+	unsubscribe := events.CacheUpdates.Subscribe(func(update events.CacheUpdate) {
+		if !strings.EqualFold(update.Location, location) {
+			return
+		}
+		// Keep only the latest update
+		select {
+		case <-changed:
+		default:
+		}
+		changed <- update.Weather
+	})
+	defer unsubscribe()
+
+	weather, err := h.WeatherService.GetWeather(ctx, location)
+	if err != nil {
+		var failures apperror.Multi
+		failures.Add(weatherErrorCode(err), "", location, err)
+		writeProviderErrors(w, r, &failures)
+		return
+	}
+	if current := weatherETag(weather); etag != current {
+		writePolledWeather(w, weather, current)
+		return
+	}
+
+	// Held requests outlive server.timeouts.write, which is then only the time left to write
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + config.GetServerTimeouts().Write)); err != nil {
+		logger(ctx).Debugw("Write deadline not extended, the poll may be cut short", "error", err)
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	check := time.NewTicker(cfg.CheckInterval)
+	defer check.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			w.Header().Set("ETag", `"`+etag+`"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case update := <-changed:
+			if current := weatherETag(&update); current != etag {
+				writePolledWeather(w, &update, current)
+				return
+			}
+		case <-check.C:
+			// Updates fetched by other replicas are only seen in the cache
+			weather, err := h.WeatherService.GetWeather(repository.WithCachePolicy(ctx, repository.CachePolicyCacheOnly), location)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger(ctx).Debugw("Poll cache check failed", "location", location, "error", err)
+				}
+				continue
+			}
+			if current := weatherETag(weather); current != etag {
+				writePolledWeather(w, weather, current)
+				return
+			}
+		}
+	}
+}
+
+func writePolledWeather(w http.ResponseWriter, weather *model.WeatherResponse, etag string) {
+	w.Header().Set("ETag", `"`+etag+`"`)
+	writeResponse(w, http.StatusOK, model.Response{Data: weather, Message: "Success"})
+}
+
+// weatherETag identifies the conditions of weather, not how they were served: the cached and
+// stale flags are left out, so a cache hit and the fetch it came from share an ETag.
+func weatherETag(weather *model.WeatherResponse) string {
+	conditions := *weather
+	conditions.Cached, conditions.Stale, conditions.ResolvedFrom = false, false, ""
+	b, _ := json.Marshal(&conditions)
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// changingService returns weather that tests can change, recording the cache policy of each call.
+type changingService struct {
+	mu       sync.Mutex
+	weather  model.WeatherResponse
+	policies []repository.CachePolicy
+}
+
+func (s *changingService) GetWeather(ctx context.Context, _ string) (*model.WeatherResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies = append(s.policies, repository.CachePolicyFrom(ctx))
+	weather := s.weather
+	return &weather, nil
+}
+
+func (s *changingService) set(weather model.WeatherResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weather = weather
+}
+
+func poll(h *PollHandler, target, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rr := httptest.NewRecorder()
+	h.HandlePoll(rr, req)
+	return rr
+}
+
+func TestPollHandler_HandlePoll(t *testing.T) {
+	svc := &changingService{weather: model.WeatherResponse{Location: "Reykjavik", Temperature: 2, Description: "snow"}}
+	h := &PollHandler{WeatherService: svc, Config: &config.PollConfig{DefaultTimeout: time.Second, MaxTimeout: 5 * time.Second, CheckInterval: time.Hour}}
+
+	// Without an etag the current weather is returned at once
+	rr := poll(h, "/weather/poll?location=Reykjavik", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" || !strings.Contains(rr.Body.String(), `"snow"`) {
+		t.Fatalf("Expected 200 with an ETag, got %d %q: %s", rr.Code, etag, rr.Body)
+	}
+
+	// Serving from the cache does not change the ETag, so an unchanged location is held until the timeout
+	svc.set(model.WeatherResponse{Location: "Reykjavik", Temperature: 2, Description: "snow", Cached: true})
+	start := time.Now()
+	rr = poll(h, "/weather/poll?location=Reykjavik&timeout=1&etag="+strings.Trim(etag, `"`), "")
+	if rr.Code != http.StatusNotModified || rr.Header().Get("ETag") != etag {
+		t.Fatalf("Expected 304 with the same ETag, got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body)
+	}
+	if held := time.Since(start); held < time.Second {
+		t.Errorf("Expected the request to be held for the timeout, was %v", held)
+	}
+
+	// A cache update for the location answers the held request
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- poll(h, "/weather/poll?location=reykjavik&timeout=5", etag) }()
+	time.Sleep(50 * time.Millisecond)
+	events.CacheUpdates.Publish(events.CacheUpdate{Location: "Oslo", Weather: model.WeatherResponse{Location: "Oslo", Temperature: 9}})
+	events.CacheUpdates.Publish(events.CacheUpdate{Location: "Reykjavik", Weather: model.WeatherResponse{Location: "Reykjavik", Temperature: 2, Description: "snow"}})
+	events.CacheUpdates.Publish(events.CacheUpdate{Location: "Reykjavik", Weather: model.WeatherResponse{Location: "Reykjavik", Temperature: -1, Description: "snow"}})
+	select {
+	case rr = <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the held request to be answered on the update")
+	}
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"temperature":-1`) || rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with the changed weather and a new ETag, got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body)
+	}
+
+	// An outdated etag gets the current weather at once
+	rr = poll(h, "/weather/poll?location=Reykjavik", `"outdated"`)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != etag {
+		t.Errorf("Expected 200 with the current ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestPollHandler_HandlePoll_ChecksCache(t *testing.T) {
+	svc := &changingService{weather: model.WeatherResponse{Location: "Lima", Temperature: 18, Description: "mist"}}
+	h := &PollHandler{WeatherService: svc, Config: &config.PollConfig{DefaultTimeout: 5 * time.Second, MaxTimeout: 5 * time.Second, CheckInterval: 20 * time.Millisecond}}
+	etag := poll(h, "/weather/poll?location=Lima", "").Header().Get("ETag")
+
+	// Changes fetched by another replica are only seen by re-reading the cache
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		svc.set(model.WeatherResponse{Location: "Lima", Temperature: 19, Description: "mist", Cached: true})
+	}()
+	rr := poll(h, "/weather/poll?location=Lima", etag)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"temperature":19`) {
+		t.Fatalf("Expected 200 with the changed weather, got %d: %s", rr.Code, rr.Body)
+	}
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if last := svc.policies[len(svc.policies)-1]; last != repository.CachePolicyCacheOnly {
+		t.Errorf("Expected cache checks to be cache-only, got policy %v", last)
+	}
+}
+
+func TestPollHandler_HandlePoll_OutlastsWriteTimeout(t *testing.T) {
+	svc := &changingService{weather: model.WeatherResponse{Location: "Reykjavik", Temperature: 2, Description: "snow"}}
+	h := &PollHandler{WeatherService: svc, Config: &config.PollConfig{DefaultTimeout: time.Second, MaxTimeout: 5 * time.Second, CheckInterval: time.Hour}}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(h.HandlePoll))
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	etag := poll(h, "/weather/poll?location=Reykjavik", "").Header().Get("ETag")
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/weather/poll?location=Reykjavik&timeout=1", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected the held request to be answered past the write timeout, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304 after the poll timeout, got %d", resp.StatusCode)
+	}
+}
+
+func TestPollHandler_HandlePoll_BadRequest(t *testing.T) {
+	h := &PollHandler{WeatherService: &mockWeatherService{}, Config: &config.PollConfig{DefaultTimeout: time.Second, MaxTimeout: time.Minute, CheckInterval: time.Second}}
+	for target, want := range map[string]string{
+		"/weather/poll":                          "Missing 'location' query parameter",
+		"/weather/poll?location=Oslo&timeout=0":  "'timeout' must be a whole number of seconds from 1 to 60",
+		"/weather/poll?location=Oslo&timeout=61": "'timeout' must be a whole number of seconds from 1 to 60",
+	} {
+		rr := poll(h, target, "")
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%s: expected 400 %q, got %d: %s", target, want, rr.Code, rr.Body)
+		}
+	}
+
+	h.WeatherService = &mockWeatherService{error: repository.ErrLocationNotFound}
+	if rr := poll(h, "/weather/poll?location=Atlantis", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown location, got %d: %s", rr.Code, rr.Body)
+	}
+}
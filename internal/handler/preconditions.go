@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// Managed resources (subscriptions and overrides) follow the same conditional request rules, so
+// declarative tooling can manage them without overwriting changes it has not seen:
+//
+//   - GET by ID serves the resource's revision as a strong ETag, and 304 for a matching
+//     If-None-Match.
+//   - Writes with If-Match only apply to a listed revision ("*" for any), and writes with
+//     If-None-Match: * only create. Otherwise they get 412 Precondition Failed.
+//   - The stores check the revision again as they write, so a concurrent change between the
+//     check and the write also gets 412.
+
+// resourceETag is the ETag of a stored resource at revision.
+func resourceETag(revision string) string {
+	return `"` + revision + `"`
+}
+
+// setResourceETag sets the ETag of a resource just read or written.
+func setResourceETag(w http.ResponseWriter, revision string) {
+	if revision != "" {
+		w.Header().Set("ETag", resourceETag(revision))
+	}
+}
+
+// notModified writes 304 for a GET whose If-None-Match lists the resource's revision.
+func notModified(w http.ResponseWriter, r *http.Request, revision string) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" || revision == "" || !etagMatches(inm, resourceETag(revision)) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writePrecondition checks If-Match and If-None-Match on a write to a resource stored at
+// revision, "" when there is none. It returns the revision the store must still find when
+// writing: revision for If-Match, repository.RevisionNone for If-None-Match: *, and "" for an
+// unconditional write. When a precondition fails it writes 412 and returns false.
+func writePrecondition(w http.ResponseWriter, r *http.Request, revision string) (string, bool) {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if revision != "" && ifMatch(im, resourceETag(revision)) {
+			return revision, true
+		}
+		writePreconditionFailed(w, revision)
+		return "", false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if strings.TrimSpace(inm) != "*" {
+			errMsg := "Only 'If-None-Match: *' is supported on writes"
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return "", false
+		}
+		if revision != "" {
+			writePreconditionFailed(w, revision)
+			return "", false
+		}
+		return repository.RevisionNone, true
+	}
+	return "", true
+}
+
+// ifMatch reports whether an If-Match header lists etag, strongly compared, or is "*".
+func ifMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writePreconditionFailed answers a write whose precondition does not hold, with the current
+// ETag, if any, so the client can re-read and retry.
+func writePreconditionFailed(w http.ResponseWriter, revision string) {
+	setResourceETag(w, revision)
+	errMsg := "Precondition failed: the resource has changed"
+	writeResponse(w, http.StatusPreconditionFailed, model.Response{Error: &errMsg, Message: "Error"})
+}
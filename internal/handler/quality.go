@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"math"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// Weights of the quality score components. Freshness weighs most: old data is wrong data.
+const (
+	freshnessWeight      = 0.5
+	providerHealthWeight = 0.25
+	completenessWeight   = 0.25
+)
+
+var responseQuality = metrics.NewHistogram("weather_response_quality",
+	"Quality scores of weather responses, from 0 to 1.", 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1)
+
+// qualityNow is the clock freshness is measured against. Tests replace it.
+var qualityNow = time.Now
+
+// weatherQuality scores weather and records the score in weather_response_quality. It returns nil
+// when quality.enabled is off.
+func weatherQuality(cfg config.QualityConfig, weather *model.WeatherResponse) *model.Quality {
+	if !cfg.Enabled {
+		return nil
+	}
+	q := model.Quality{
+		Freshness:      qualityFreshness(weather, qualityNow(), cfg.MaxAge),
+		ProviderHealth: qualityProviderHealth(repository.GetProviderHealth()),
+		Completeness:   qualityCompleteness(weather),
+	}
+	q.Score = freshnessWeight*q.Freshness + providerHealthWeight*q.ProviderHealth + completenessWeight*q.Completeness
+	responseQuality.Observe(q.Score)
+	q.Score, q.Freshness, q.ProviderHealth, q.Completeness = roundQuality(q.Score), roundQuality(q.Freshness), roundQuality(q.ProviderHealth), roundQuality(q.Completeness)
+	return &q
+}
+
+// qualityFreshness falls linearly from 1 for data just confirmed by the provider to 0 at maxAge.
+// Without a fetch time, as for entries cached by older builds, stale data scores 0 and other
+// cached data 0.5; data not from the cache, such as an operator override, is current.
+func qualityFreshness(weather *model.WeatherResponse, now time.Time, maxAge time.Duration) float64 {
+	if weather.FetchedAt.IsZero() {
+		switch {
+		case weather.Stale:
+			return 0
+		case weather.Cached:
+			return 0.5
+		default:
+			return 1
+		}
+	}
+	age := now.Sub(weather.FetchedAt)
+	return math.Max(0, math.Min(1, 1-float64(age)/float64(maxAge)))
+}
+
+// qualityProviderHealth falls with each provider failure in a row, to 1/(1+n) after n failures.
+func qualityProviderHealth(health repository.ProviderHealth) float64 {
+	return 1 / float64(1+health.ConsecutiveFailures)
+}
+
+// qualityCompleteness is the share of the fields the response could carry that are set. The
+// details every provider reports count, and the UV index only when it is fetched at all.
+func qualityCompleteness(weather *model.WeatherResponse) float64 {
+	var expected, present int
+	field := func(set bool) {
+		expected++
+		if set {
+			present++
+		}
+	}
+	field(weather.Location != "")
+	field(weather.Description != "")
+	field(weather.Humidity != nil)
+	field(weather.Pressure != nil)
+	field(weather.WindSpeed != nil)
+	if config.GetUVIndexConfig().Enabled {
+		field(weather.UVIndex != nil)
+	}
+	return float64(present) / float64(expected)
+}
+
+// roundQuality rounds a score to two decimals, which is all the precision it has.
+func roundQuality(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+func TestWeatherHandler_HandleWeather_Quality(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	qualityNow = func() time.Time { return now }
+	defer func() { qualityNow = time.Now }()
+
+	weather := &model.WeatherResponse{Location: "Oslo", Temperature: -3, Cached: true, FetchedAt: now.Add(-15 * time.Minute)}
+	h := &WeatherHandler{WeatherService: &mockWeatherService{mockData: weather}, Quality: &config.QualityConfig{Enabled: true, MaxAge: time.Hour}}
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=Oslo", nil))
+
+	var resp struct {
+		Quality *model.Quality `json:"quality"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Quality == nil {
+		t.Fatalf("Expected a quality in the envelope, got %s", rr.Body)
+	}
+	health := qualityProviderHealth(repository.GetProviderHealth())
+	want := model.Quality{Freshness: 0.75, ProviderHealth: health, Completeness: 0.2}
+	want.Score = roundQuality(0.5*0.75 + 0.25*health + 0.25*0.2)
+	if *resp.Quality != want {
+		t.Errorf("Expected quality %+v, got %+v", want, *resp.Quality)
+	}
+
+	h.Quality = &config.QualityConfig{MaxAge: time.Hour}
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=Oslo", nil))
+	if strings.Contains(rr.Body.String(), "quality") {
+		t.Errorf("Expected no quality when disabled, got %s", rr.Body)
+	}
+}
+
+func TestQualityFreshness(t *testing.T) {
+	now := time.Now()
+	for name, tc := range map[string]struct {
+		weather model.WeatherResponse
+		want    float64
+	}{
+		"just fetched":       {model.WeatherResponse{FetchedAt: now}, 1},
+		"half way":           {model.WeatherResponse{Cached: true, FetchedAt: now.Add(-30 * time.Minute)}, 0.5},
+		"past max age":       {model.WeatherResponse{Cached: true, Stale: true, FetchedAt: now.Add(-2 * time.Hour)}, 0},
+		"override":           {model.WeatherResponse{}, 1},
+		"unknown age cached": {model.WeatherResponse{Cached: true}, 0.5},
+		"unknown age stale":  {model.WeatherResponse{Cached: true, Stale: true}, 0},
+	} {
+		if got := qualityFreshness(&tc.weather, now, time.Hour); got != tc.want {
+			t.Errorf("%s: expected freshness %v, got %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestQualityProviderHealthAndCompleteness(t *testing.T) {
+	if got := qualityProviderHealth(repository.ProviderHealth{ConsecutiveFailures: 3}); got != 0.25 {
+		t.Errorf("Expected 0.25 after three failures, got %v", got)
+	}
+	humidity, pressure, wind := 86.0, 1003.4, 0.0
+	complete := model.WeatherResponse{Location: "Oslo", Description: "snow", Humidity: &humidity, Pressure: &pressure, WindSpeed: &wind}
+	if got := qualityCompleteness(&complete); got != 1 {
+		t.Errorf("Expected a complete response, got %v", got)
+	}
+	if got := qualityCompleteness(&model.WeatherResponse{Location: "Oslo", Description: "snow", Humidity: &humidity}); got != 0.6 {
+		t.Errorf("Expected 3 of 5 fields, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// readyCheckTimeout bounds each dependency check so a hung Redis fails the probe instead of
+// stalling it.
+const readyCheckTimeout = 2 * time.Second
+
+// Pinger is implemented by the Redis client.
+type Pinger interface {
+	Ping(ctx context.Context) *redisv9.StatusCmd
+}
+
+// ReadyHandler answers readiness probes.
+type ReadyHandler struct {
+	Redis Pinger
+}
+
+func NewReadyHandler(pinger ...Pinger) *ReadyHandler {
+	if len(pinger) > 0 && pinger[0] != nil {
+		return &ReadyHandler{Redis: pinger[0]}
+	}
+	return &ReadyHandler{Redis: redis.GetClient()}
+}
+
+type readiness struct {
+	Status      string                      `json:"status"`
+	Checks      map[string]string           `json:"checks"`
+	Degradation repository.DegradationState `json:"degradation"`
+}
+
+// HandleReadyz serves GET /readyz: 200 when Redis answers, 503 otherwise. The body reports each
+// check and the current quota degradation level. A degraded service is still ready, since it
+// keeps serving from the cache.
+func (h *ReadyHandler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	state := readiness{
+		Status:      "ready",
+		Checks:      map[string]string{"redis": "ok"},
+		Degradation: repository.CurrentDegradation(),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+	if err := h.Redis.Ping(ctx).Err(); err != nil {
+		state.Status = "not_ready"
+		state.Checks["redis"] = err.Error()
+		errMsg := "Redis unavailable"
+		writeResponse(w, http.StatusServiceUnavailable, model.Response{Data: state, Error: &errMsg, Message: "Error"})
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: state, Message: "Success"})
+}
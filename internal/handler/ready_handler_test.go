@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestHandleReadyz(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	defer client.Close()
+	h := NewReadyHandler(client)
+
+	w := httptest.NewRecorder()
+	h.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data readiness `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Status != "ready" || resp.Data.Checks["redis"] != "ok" || resp.Data.Degradation.Level != "normal" {
+		t.Errorf("Unexpected readiness %+v", resp.Data)
+	}
+
+	mr.Close()
+	w = httptest.NewRecorder()
+	h.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with Redis down, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleReadyz(w, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
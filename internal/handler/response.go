@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/bufpool"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/encoding"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// responseFormat holds the response settings (server.response_case, server.response_envelope,
+// server.response_encoding).
+type responseFormat struct {
+	keyCase  string
+	envelope bool
+	encoder  encoding.Encoder
+	// jsonp allows ?callback= to select JSONP output (server.jsonp).
+	jsonp bool
+}
+
+func currentResponseFormat() responseFormat {
+	encoder, ok := encoding.Lookup(config.GetResponseEncoding())
+	if !ok {
+		encoder = encoding.JSON
+	}
+	return responseFormat{keyCase: config.GetResponseCase(), envelope: config.IsResponseEnvelopeEnabled(), encoder: encoder, jsonp: config.IsJSONPEnabled()}
+}
+
+// negotiate returns format with its encoder replaced by the best match for the request's Accept
+// header. ok is false when the client accepts none of the registered encodings. When JSONP is
+// allowed, a valid ?callback= selects it whatever the Accept header says.
+func negotiate(r *http.Request, format responseFormat) (responseFormat, bool) {
+	if format.jsonp {
+		if e, err := encoding.NewJSONP(r.URL.Query().Get("callback")); err == nil {
+			format.encoder = e
+			return format, true
+		}
+	}
+	encoder, ok := encoding.Negotiate(r.Header.Get("Accept"), format.encoder)
+	if ok {
+		format.encoder = encoder
+	}
+	return format, ok
+}
+
+// acceptableMediaTypes lists the content types of every registered encoder.
+func acceptableMediaTypes() []string {
+	var types []string
+	for _, name := range encoding.Names() {
+		e, _ := encoding.Lookup(name)
+		types = append(types, e.ContentType())
+	}
+	return types
+}
+
+func (f responseFormat) isJSON() bool {
+	return f.encoder == nil || f.encoder == encoding.JSON
+}
+
+var (
+	// jsonContentType and varyAccept are shared by every response to avoid allocating header values.
+	jsonContentType = []string{"application/json"}
+	varyAccept      = []string{"Accept"}
+	// Precomputed parts of the success envelope used by the cache-hit fast path.
+	envelopeDataPrefix    = []byte(`{"data":`)
+	envelopeSuccessSuffix = []byte(`,"message":"Success"}` + "\n")
+	envelopeMessagePrefix = []byte(`,"message":`)
+	envelopeQualityPrefix = []byte(`,"quality":`)
+)
+
+// writeResponse encodes resp with the configured encoder, key casing and envelope style.
+// When the envelope is disabled, successful responses carry only the bare data object; error
+// responses always keep the envelope so clients can still read the error message.
+func writeResponse(w http.ResponseWriter, statusCode int, resp model.Response) {
+	writeFormattedResponse(w, statusCode, resp, currentResponseFormat(), 0)
+}
+
+// writeSizedResponse is writeResponse for a body of about sizeHint bytes, which is then encoded
+// into a pooled buffer of its size from the start instead of growing into one.
+func writeSizedResponse(w http.ResponseWriter, statusCode int, resp model.Response, sizeHint int) {
+	writeFormattedResponse(w, statusCode, resp, currentResponseFormat(), sizeHint)
+}
+
+func writeFormattedResponse(w http.ResponseWriter, statusCode int, resp model.Response, format responseFormat, sizeHint int) {
+	resp.Warnings = append(resp.Warnings, headerWarnings(w.Header())...)
+
+	buf := bufpool.Get(sizeHint)
+	body, err := appendResponse(buf.AvailableBuffer(), resp, format)
+	if errors.Is(err, encoding.ErrNoSchema) {
+		// The encoder's schema has no message for this data, so it is sent as JSON instead
+		format.encoder = encoding.JSON
+		body, err = appendResponse(buf.AvailableBuffer(), resp, format)
+	}
+	if cap(body) > buf.Cap() {
+		// The body outgrew the buffer; pool the larger one it was appended to instead
+		buf = bytes.NewBuffer(body[:0])
+	}
+	defer func() { bufpool.Put(buf) }()
+	if err != nil {
+		logger(context.Background()).Errorw("Failed to encode response", "error", err)
+		w.Header()["Content-Type"] = jsonContentType
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"Failed to encode response","message":"Error"}` + "\n"))
+		return
+	}
+
+	if format.isJSON() {
+		w.Header()["Content-Type"] = jsonContentType
+	} else {
+		w.Header().Set("Content-Type", format.encoder.ContentType())
+	}
+	if _, ok := format.encoder.(encoding.JSONP); ok {
+		// A <script> tag cannot see the status, and fails to load anything but a 2xx, so errors
+		// reach the callback through the envelope instead. nosniff stops browsers from running
+		// the body as anything but script.
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// appendResponse appends the encoded response to dst. Successful weather responses in snake_case
+// JSON (the cache-hit hot path) are written with precomputed envelope parts and no reflection;
+// everything else goes through appendEncodedJSON or the format's encoder.
+func appendResponse(dst []byte, resp model.Response, format responseFormat) ([]byte, error) {
+	weather, ok := resp.Data.(*model.WeatherResponse)
+	if ok && weather != nil && format.isJSON() && format.keyCase == "snake" && resp.Error == nil && len(resp.Errors) == 0 && len(resp.Warnings) == 0 {
+		if !format.envelope {
+			dst, err := weather.AppendJSON(dst)
+			return append(dst, '\n'), err
+		}
+		dst = append(dst, envelopeDataPrefix...)
+		dst, err := weather.AppendJSON(dst)
+		if err != nil {
+			return dst, err
+		}
+		if resp.Quality != nil {
+			dst = append(dst, envelopeQualityPrefix...)
+			if dst, err = resp.Quality.AppendJSON(dst); err != nil {
+				return dst, err
+			}
+		}
+		if resp.Message == "Success" {
+			return append(dst, envelopeSuccessSuffix...), nil
+		}
+		dst = append(dst, envelopeMessagePrefix...)
+		msg, err := json.Marshal(resp.Message)
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, msg...)
+		return append(dst, '}', '\n'), nil
+	}
+
+	var payload interface{} = resp
+	if !format.envelope && resp.Error == nil && resp.Data != nil {
+		payload = resp.Data
+	}
+	if !format.isJSON() {
+		if _, fixed := format.encoder.(encoding.FixedSchema); format.keyCase == "camel" && !fixed {
+			generic, err := toCamelCase(payload)
+			if err != nil {
+				return dst, err
+			}
+			payload = generic
+		}
+		return format.encoder.Append(dst, payload)
+	}
+	return appendEncodedJSON(dst, payload, format.keyCase)
+}
+
+// headerWarnings extracts the text of `Warning: 299 - "<text>"` headers set by upstream middleware
+// (e.g. deprecation notices) so they can be repeated in the envelope.
+func headerWarnings(h http.Header) []string {
+	var warnings []string
+	for _, v := range h.Values("Warning") {
+		text, ok := strings.CutPrefix(v, "299 - ")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(text); err == nil {
+			text = unquoted
+		}
+		warnings = append(warnings, text)
+	}
+	return warnings
+}
+
+// appendEncodedJSON appends the JSON encoding of v to dst and, for camelCase output, rewrites every
+// object key. The trailing newline matches json.Encoder output. v is encoded into a pooled buffer
+// sized like dst, then copied.
+func appendEncodedJSON(dst []byte, v interface{}, keyCase string) ([]byte, error) {
+	buf := bufpool.Get(cap(dst) - len(dst))
+	defer bufpool.Put(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return dst, err
+	}
+	if keyCase != "camel" {
+		return append(dst, buf.Bytes()...), nil
+	}
+
+	generic, err := decodeGeneric(buf)
+	if err != nil {
+		return dst, err
+	}
+	buf.Reset()
+	if err := json.NewEncoder(buf).Encode(convertKeys(generic, snakeToCamel)); err != nil {
+		return dst, err
+	}
+	return append(dst, buf.Bytes()...), nil
+}
+
+// toCamelCase returns the JSON data model of v with every object key converted to camelCase.
+func toCamelCase(v interface{}) (interface{}, error) {
+	buf := bufpool.Get(0)
+	defer bufpool.Put(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	generic, err := decodeGeneric(buf)
+	if err != nil {
+		return nil, err
+	}
+	return convertKeys(generic, snakeToCamel), nil
+}
+
+func decodeGeneric(buf *bytes.Buffer) (interface{}, error) {
+	dec := json.NewDecoder(buf)
+	dec.UseNumber()
+	var generic interface{}
+	err := dec.Decode(&generic)
+	return generic, err
+}
+
+// convertKeys recursively rewrites object keys using fn.
+func convertKeys(v interface{}, fn func(string) string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[fn(k)] = convertKeys(val, fn)
+		}
+		return out
+	case []interface{}:
+		for i, val := range t {
+			t[i] = convertKeys(val, fn)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// snakeToCamel converts "feels_like" into "feelsLike".
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+	return b.String()
+}
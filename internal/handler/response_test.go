@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/encoding"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+func TestSnakeToCamel(t *testing.T) {
+	tests := map[string]string{
+		"location":    "location",
+		"feels_like":  "feelsLike",
+		"temp_min_c":  "tempMinC",
+		"trailing_":   "trailing",
+		"":            "",
+		"already_Cap": "alreadyCap",
+	}
+	for in, want := range tests {
+		if got := snakeToCamel(in); got != want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAppendEncodedJSON_CamelCase(t *testing.T) {
+	v := map[string]interface{}{
+		"feels_like": 14.8,
+		"nested":     []interface{}{map[string]interface{}{"temp_max": 18}},
+	}
+	b, err := appendEncodedJSON(nil, v, "camel")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body := string(b)
+	if !strings.Contains(body, `"feelsLike":14.8`) || !strings.Contains(body, `"tempMax":18`) {
+		t.Errorf("Expected camelCase keys, got %s", body)
+	}
+
+	b, err = appendEncodedJSON(nil, v, "snake")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(b), `"feels_like"`) {
+		t.Errorf("Expected snake_case keys to be untouched, got %s", b)
+	}
+}
+
+func TestWriteResponse_EnvelopeDisabled(t *testing.T) {
+	viper.Set("server.response_envelope", false)
+	defer viper.Set("server.response_envelope", nil)
+
+	weather := &model.WeatherResponse{Location: "London", Temperature: 15.2, Description: "clear sky"}
+	rr := httptest.NewRecorder()
+	writeResponse(rr, http.StatusOK, model.Response{Data: weather, Message: "Success"})
+
+	var bare model.WeatherResponse
+	if err := json.NewDecoder(rr.Body).Decode(&bare); err != nil {
+		t.Fatalf("Failed to decode bare response: %v", err)
+	}
+	if bare.Location != "London" {
+		t.Errorf("Expected bare weather object, got %+v", bare)
+	}
+
+	// Errors keep the envelope
+	errMsg := "Missing 'location' query parameter"
+	rr = httptest.NewRecorder()
+	writeResponse(rr, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+	var resp model.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if resp.Error == nil || *resp.Error != errMsg {
+		t.Errorf("Expected enveloped error, got %+v", resp)
+	}
+}
+
+func TestWriteResponse_CamelCaseConfig(t *testing.T) {
+	viper.Set("server.response_case", "camel")
+	defer viper.Set("server.response_case", nil)
+
+	rr := httptest.NewRecorder()
+	writeResponse(rr, http.StatusOK, model.Response{
+		Data:    map[string]interface{}{"feels_like": 1},
+		Message: "Success",
+	})
+	if !strings.Contains(rr.Body.String(), `"feelsLike":1`) {
+		t.Errorf("Expected camelCase body, got %s", rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %s", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestWriteResponse_SurfacesWarningHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Add("Warning", `299 - "/weather is deprecated"`)
+	rr.Header().Add("Warning", `199 - "ignored"`)
+	writeResponse(rr, http.StatusOK, model.Response{Data: map[string]string{"location": "London"}, Message: "Success"})
+
+	var resp model.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0] != "/weather is deprecated" {
+		t.Errorf("Expected deprecation warning in envelope, got %v", resp.Warnings)
+	}
+}
+
+// discardResponseWriter is a reusable ResponseWriter that drops the body, so allocation counts
+// reflect only the handler.
+type discardResponseWriter struct{ header http.Header }
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+func TestWriteSizedResponse_OutgrowsPooledBuffer(t *testing.T) {
+	rows := make([]string, 2000)
+	for i := range rows {
+		rows[i] = "row " + strconv.Itoa(i)
+	}
+	resp := model.Response{Data: rows, Message: "Success"}
+	want, err := appendEncodedJSON(nil, resp, "snake")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Twice each, so that the second write gets the buffer the first one grew
+	for _, hint := range []int{0, 0, 64 << 10, 64 << 10} {
+		rr := httptest.NewRecorder()
+		writeSizedResponse(rr, http.StatusOK, resp, hint)
+		if rr.Body.String() != string(want) {
+			t.Errorf("Hint %d: expected the whole body of %d bytes, got %d", hint, len(want), rr.Body.Len())
+		}
+	}
+}
+
+func TestAppendResponse_FastPathMatchesEncoder(t *testing.T) {
+	weather := &model.WeatherResponse{Location: "London <UK>", Temperature: 15.2, Description: "clear sky", Cached: true}
+	for _, format := range []responseFormat{{keyCase: "snake", envelope: true}, {keyCase: "snake", envelope: false}} {
+		for _, msg := range []string{"Success", "Served from cache"} {
+			for _, quality := range []*model.Quality{nil, {Score: 0.81, Freshness: 0.75, ProviderHealth: 1, Completeness: 0.5}} {
+				resp := model.Response{Data: weather, Quality: quality, Message: msg}
+				got, err := appendResponse(nil, resp, format)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				var payload interface{} = resp
+				if !format.envelope {
+					payload = weather
+				}
+				want, _ := appendEncodedJSON(nil, payload, "snake")
+				if string(got) != string(want) {
+					t.Errorf("Fast path differs from encoder\n got: %s\nwant: %s", got, want)
+				}
+			}
+		}
+	}
+}
+
+// cacheHitAllocBudget is the maximum number of allocations HandleWeather may make to serve a cache
+// hit (query parsing and request-scoped context values). Raise it only with a good reason.
+const cacheHitAllocBudget = 8
+
+func TestHandleWeather_CacheHitAllocBudget(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true,
+	}})
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+
+	allocs := testing.AllocsPerRun(200, func() { h.HandleWeather(w, req) })
+	if allocs > cacheHitAllocBudget {
+		t.Errorf("Cache hit made %v allocations, budget is %d", allocs, cacheHitAllocBudget)
+	}
+}
+
+func BenchmarkHandleWeather_CacheHit(b *testing.B) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true,
+	}})
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	b.ReportAllocs()
+	for b.Loop() {
+		h.HandleWeather(w, req)
+	}
+}
+
+func TestAppendResponse_CamelCaseMsgPack(t *testing.T) {
+	format := responseFormat{keyCase: "camel", envelope: false, encoder: encoding.MsgPack}
+	got, err := appendResponse(nil, model.Response{Data: map[string]interface{}{"feels_like": 1}, Message: "Success"}, format)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := append([]byte{0x81, 0xa9}, "feelsLike"...)
+	want = append(want, 0x01)
+	if string(got) != string(want) {
+		t.Errorf("Expected camelCase msgpack % x, got % x", want, got)
+	}
+}
+
+func TestHandleWeather_JSONP(t *testing.T) {
+	viper.Set("server.jsonp", true)
+	defer viper.Set("server.jsonp", nil)
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{Location: "London", Temperature: 15.2, Description: "clear sky"}})
+
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&callback=widget.show", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/javascript" {
+		t.Errorf("Expected application/javascript, got %s", ct)
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected X-Content-Type-Options: nosniff")
+	}
+	want := `/**/widget.show({"data":{"location":"London","temperature":15.2,"description":"clear sky","cached":false},"message":"Success"});` + "\n"
+	if rr.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, rr.Body)
+	}
+
+	// Errors reach the callback with a 200, as a <script> tag cannot read other statuses
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?callback=cb", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), `/**/cb({"error":"Missing 'location' query parameter"`) {
+		t.Errorf("Expected the error envelope passed to the callback, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&callback=alert(1)", nil))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON 400 for an unsafe callback, got %d (%s)", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	for _, format := range []string{"geojson", "homeassistant", "display"} {
+		rr = httptest.NewRecorder()
+		h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&callback=cb&format="+format, nil))
+		if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON 400 for callback with format=%s, got %d (%s): %s", format, rr.Code, rr.Header().Get("Content-Type"), rr.Body)
+		}
+	}
+}
+
+func TestHandleWeather_JSONPDisabled(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{Location: "London"}})
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&callback=cb", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" || strings.Contains(rr.Body.String(), "cb(") {
+		t.Errorf("Expected plain JSON with JSONP off, got %d: %s", rr.Code, rr.Body)
+	}
+}
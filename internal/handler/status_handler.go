@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+var (
+	//go:embed status/status.html
+	statusHTML string
+	//go:embed status/status.css
+	statusCSS string
+
+	statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+		"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+		"ago":     ago,
+	}).Parse(statusHTML))
+)
+
+// statusRefreshSeconds is how often the page reloads itself on a NOC screen.
+const statusRefreshSeconds = 30
+
+// StatusHandler renders a human-readable status page.
+type StatusHandler struct {
+	WeatherService service.WeatherServiceInterface
+	Started        time.Time
+}
+
+func NewStatusHandler(weatherService ...service.WeatherServiceInterface) *StatusHandler {
+	var svc service.WeatherServiceInterface
+	if len(weatherService) > 0 && weatherService[0] != nil {
+		svc = weatherService[0]
+	} else {
+		svc = service.NewWeatherService()
+	}
+	return &StatusHandler{WeatherService: svc, Started: time.Now()}
+}
+
+type cityStatus struct {
+	Name    string
+	Weather *model.WeatherResponse
+}
+
+type buildInfo struct {
+	Version   string
+	Revision  string
+	Modified  bool
+	GoVersion string
+}
+
+type statusPage struct {
+	Generated      time.Time
+	RefreshSeconds int
+	Cities         []cityStatus
+	Cache          repository.CacheStats
+	Provider       repository.ProviderHealth
+	Build          buildInfo
+	Uptime         time.Duration
+	CSS            template.CSS
+}
+
+// HandleStatus serves GET /status: current conditions for warm-listed cities, cache hit rate,
+// provider health and build info. Conditions are read from the cache only, so the page never
+// spends provider calls however often it refreshes.
+func (h *StatusHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	ctx := repository.WithCachePolicy(r.Context(), repository.CachePolicyCacheOnly)
+	page := statusPage{
+		Generated:      time.Now(),
+		RefreshSeconds: statusRefreshSeconds,
+		Cache:          repository.GetCacheStats(),
+		Provider:       repository.GetProviderHealth(),
+		Build:          readBuildInfo(),
+		Uptime:         time.Since(h.Started).Truncate(time.Second),
+		CSS:            template.CSS(statusCSS),
+	}
+	for _, city := range config.GetWarmCities() {
+		weather, err := h.WeatherService.GetWeather(ctx, city)
+		if err != nil {
+			weather = nil
+		}
+		page.Cities = append(page.Cities, cityStatus{Name: city, Weather: weather})
+	}
+
+	var buf bytes.Buffer
+	if err := statusTemplate.Execute(&buf, page); err != nil {
+		logger(r.Context()).Errorw("Failed to render status page", "error", err)
+		errMsg := "Failed to render status page"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// readBuildInfo reports the module version and VCS stamp embedded by the Go toolchain.
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: "unknown", Revision: "unknown"}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// ago renders how long before now t was, or "never" for the zero time.
+func ago(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return time.Since(t).Truncate(time.Second).String() + " ago"
+}
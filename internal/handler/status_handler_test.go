@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/spf13/viper"
+)
+
+// cacheOnlyService returns cached weather per city and records whether every lookup was cache-only.
+type cacheOnlyService struct {
+	cached         map[string]*model.WeatherResponse
+	sawOtherPolicy bool
+}
+
+func (s *cacheOnlyService) GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error) {
+	if repository.CachePolicyFrom(ctx) != repository.CachePolicyCacheOnly {
+		s.sawOtherPolicy = true
+	}
+	if w, ok := s.cached[location]; ok {
+		return w, nil
+	}
+	return nil, repository.ErrCacheOnlyMiss
+}
+
+func TestHandleStatus(t *testing.T) {
+	viper.Set("cache.warm_cities", []string{"London", "Tokyo"})
+	defer viper.Set("cache.warm_cities", nil)
+
+	svc := &cacheOnlyService{cached: map[string]*model.WeatherResponse{
+		"London": {Location: "London", Temperature: 15.24, Description: "clear <sky>", Cached: true},
+	}}
+	h := NewStatusHandler(svc)
+	h.Started = time.Now().Add(-time.Hour)
+
+	w := httptest.NewRecorder()
+	h.HandleStatus(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if svc.sawOtherPolicy {
+		t.Error("Expected the status page to read the cache only")
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"15.2 &deg;C",
+		"clear &lt;sky&gt;", // escaped by html/template
+		"<td>Tokyo</td><td colspan=\"3\" class=\"muted\">no cached data</td>",
+		"hit rate",
+		"Uptime</dt><dd>1h0m0s",
+		"<style>body {", // embedded stylesheet
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected page to contain %q", want)
+		}
+	}
+}
+
+func TestHandleStatus_MethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewStatusHandler(&cacheOnlyService{}).HandleStatus(w, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
+
+func TestAgo(t *testing.T) {
+	if got := ago(time.Time{}); got != "never" {
+		t.Errorf("Expected never, got %q", got)
+	}
+	if got := ago(time.Now().Add(-90 * time.Second)); !strings.HasPrefix(got, "1m30s") {
+		t.Errorf("Expected 1m30s ago, got %q", got)
+	}
+}
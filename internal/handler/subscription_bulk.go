@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/webhook"
+)
+
+const (
+	// maxBulkSubscriptions caps the desired state of one API key.
+	maxBulkSubscriptions = 1000
+	// maxBulkSubscriptionBody caps the size of PUT /subscriptions/bulk bodies.
+	maxBulkSubscriptionBody = 1 << 20
+)
+
+// bulkSubscriptionRequest is the body of PUT /subscriptions/bulk: every subscription the caller's
+// API key should have.
+type bulkSubscriptionRequest struct {
+	Subscriptions []desiredSubscription `json:"subscriptions"`
+}
+
+// desiredSubscription is one subscription of a desired state, where subscriptions are identified
+// by their location and URL, or the body of PUT /subscriptions/{id}.
+type desiredSubscription struct {
+	Location string `json:"location"`
+	URL      string `json:"url"`
+	// Secret replaces the signing secret when set; a new subscription gets a generated one.
+	Secret string `json:"secret"`
+	// Status is active when empty.
+	Status string `json:"status"`
+}
+
+// subscriptionSync is the response of PUT /subscriptions/bulk.
+type subscriptionSync struct {
+	// Created subscriptions carry their signing secret, as from POST /subscriptions.
+	Created   []*model.Subscription `json:"created"`
+	Updated   []*model.Subscription `json:"updated"`
+	Deleted   []*model.Subscription `json:"deleted"`
+	Unchanged int                   `json:"unchanged"`
+	DryRun    bool                  `json:"dry_run,omitempty"`
+}
+
+// subscriptionIdentity is what makes two subscriptions the same one for a sync.
+func subscriptionIdentity(location, url string) string {
+	return repository.SubscriptionLocation(location) + " " + url
+}
+
+// HandleBulkSubscriptions serves PUT /subscriptions/bulk, which makes the caller's subscriptions
+// match the desired state in the body: missing ones are created, differing ones updated and the
+// rest deleted. Repeating a request changes nothing, so infrastructure-as-code tools can apply it
+// on every run. With ?dry_run=true the changes are reported without being made.
+func (h *WebhookHandler) HandleBulkSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodPut) {
+		return
+	}
+	dryRun, err := parseBoolParam(r.URL.Query(), "dry_run")
+	if err != nil {
+		errMsg := "Invalid 'dry_run' query parameter"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	var req bulkSubscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkSubscriptionBody)).Decode(&req); err != nil {
+		errMsg := "Invalid JSON body"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if errMsg := h.validateBulkSubscriptions(req.Subscriptions); errMsg != "" {
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	owner := subscriptionOwner(r)
+	current, err := h.ownedSubscriptions(r.Context(), owner)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to list subscriptions", "error", err)
+		errMsg := "Failed to list subscriptions"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	plan := planSubscriptionSync(current, req.Subscriptions, owner)
+	plan.DryRun = dryRun
+	if !dryRun {
+		err := h.applySubscriptionSync(r.Context(), &plan)
+		if errors.Is(err, repository.ErrRevisionMismatch) {
+			errMsg := "Subscriptions changed during the sync; apply the desired state again"
+			writeResponse(w, http.StatusConflict, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		if err != nil {
+			// Nothing is rolled back; applying the same state again finishes the sync
+			logger(r.Context()).Errorw("Failed to sync subscriptions", "error", err)
+			errMsg := "Failed to sync subscriptions"
+			writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+	}
+
+	if dryRun {
+		for i, sub := range plan.Created {
+			plan.Created[i] = withoutSecret(sub)
+		}
+	}
+	for i, sub := range plan.Updated {
+		plan.Updated[i] = withoutSecret(sub)
+	}
+	for i, sub := range plan.Deleted {
+		plan.Deleted[i] = withoutSecret(sub)
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: plan, Message: "Success"})
+}
+
+// validateBulkSubscriptions returns an error message, naming the offending entry, for an invalid
+// desired state, or "".
+func (h *WebhookHandler) validateBulkSubscriptions(desired []desiredSubscription) string {
+	if len(desired) > maxBulkSubscriptions {
+		return fmt.Sprintf("At most %d subscriptions can be synced", maxBulkSubscriptions)
+	}
+	seen := make(map[string]int, len(desired))
+	for i, d := range desired {
+		if errMsg := h.validateDesiredSubscription(d); errMsg != "" {
+			return fmt.Sprintf("subscriptions[%d]: %s", i, errMsg)
+		}
+		id := subscriptionIdentity(d.Location, d.URL)
+		if first, ok := seen[id]; ok {
+			return fmt.Sprintf("subscriptions[%d]: same location and url as subscriptions[%d]", i, first)
+		}
+		seen[id] = i
+	}
+	return ""
+}
+
+// validateDesiredSubscription returns an error message for an invalid desired subscription, or "".
+func (h *WebhookHandler) validateDesiredSubscription(d desiredSubscription) string {
+	if errMsg := h.validateSubscription(&subscriptionRequest{Location: d.Location, URL: d.URL}); errMsg != "" {
+		return errMsg
+	}
+	if d.Status != "" && !validSubscriptionStatus(d.Status) {
+		return "'status' must be active or paused"
+	}
+	return ""
+}
+
+// desiredStatus is the status d asks for, active when it names none.
+func desiredStatus(d desiredSubscription) string {
+	if d.Status == "" {
+		return model.SubscriptionActive
+	}
+	return d.Status
+}
+
+// ownedSubscriptions returns every subscription of owner, secrets included.
+func (h *WebhookHandler) ownedSubscriptions(ctx context.Context, owner string) ([]*model.Subscription, error) {
+	var subs []*model.Subscription
+	cursor := ""
+	for {
+		page, next, err := h.Subscriptions.List(ctx, repository.SubscriptionFilter{Owner: owner}, cursor, maxSubscriptionLimit)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, page...)
+		if next == "" {
+			return subs, nil
+		}
+		cursor = next
+	}
+}
+
+// planSubscriptionSync works out the changes that turn current into desired. Each desired
+// subscription keeps the oldest current one with its identity, by CreatedAt and then ID, so the
+// plan does not depend on the order current is listed in; any others, such as duplicates created
+// one at a time, are deleted. New subscriptions get their ID and secret when applied.
+func planSubscriptionSync(current []*model.Subscription, desired []desiredSubscription, owner string) subscriptionSync {
+	current = slices.Clone(current)
+	slices.SortFunc(current, func(a, b *model.Subscription) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	byIdentity := make(map[string]*model.Subscription, len(current))
+	plan := subscriptionSync{Created: []*model.Subscription{}, Updated: []*model.Subscription{}, Deleted: []*model.Subscription{}}
+	for _, sub := range current {
+		id := subscriptionIdentity(sub.Location, sub.URL)
+		if _, ok := byIdentity[id]; ok {
+			plan.Deleted = append(plan.Deleted, sub)
+			continue
+		}
+		byIdentity[id] = sub
+	}
+
+	for _, d := range desired {
+		status := desiredStatus(d)
+		location := strings.TrimSpace(d.Location)
+		id := subscriptionIdentity(d.Location, d.URL)
+		existing, ok := byIdentity[id]
+		if !ok {
+			plan.Created = append(plan.Created, &model.Subscription{Owner: owner, Location: location, URL: d.URL, Secret: d.Secret, Status: status})
+			continue
+		}
+		delete(byIdentity, id)
+		updated := *existing
+		updated.Location, updated.Status = location, status
+		if d.Secret != "" {
+			updated.Secret = d.Secret
+		}
+		if updated == *existing {
+			plan.Unchanged++
+			continue
+		}
+		plan.Updated = append(plan.Updated, &updated)
+	}
+
+	for _, sub := range current {
+		if byIdentity[subscriptionIdentity(sub.Location, sub.URL)] == sub {
+			plan.Deleted = append(plan.Deleted, sub)
+		}
+	}
+	return plan
+}
+
+// applySubscriptionSync makes the planned changes. Deletions come last, so replacing a
+// subscription with one for another URL leaves no gap in deliveries. Updates and deletions only
+// apply to the revisions planned against, so a concurrent change stops the sync with
+// ErrRevisionMismatch instead of being overwritten.
+func (h *WebhookHandler) applySubscriptionSync(ctx context.Context, plan *subscriptionSync) error {
+	now := time.Now().UTC()
+	for _, sub := range plan.Created {
+		sub.ID = webhook.NewSubscriptionID()
+		sub.CreatedAt = now
+		if sub.Secret == "" {
+			sub.Secret = webhook.NewSecret()
+		}
+		if err := h.Subscriptions.Create(ctx, sub); err != nil {
+			return err
+		}
+	}
+	for _, sub := range plan.Updated {
+		if err := h.Subscriptions.Update(ctx, sub); err != nil {
+			return err
+		}
+	}
+	for _, sub := range plan.Deleted {
+		if err := h.Subscriptions.Delete(ctx, sub.ID, sub.Revision); err != nil && !errors.Is(err, repository.ErrSubscriptionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
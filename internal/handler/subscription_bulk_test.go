@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func putBulk(h *WebhookHandler, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.HandleBulkSubscriptions(w, httptest.NewRequest(http.MethodPut, target, strings.NewReader(body)))
+	return w
+}
+
+func TestHandleBulkSubscriptions(t *testing.T) {
+	store := &mockSubscriptionStore{subs: map[string]*model.Subscription{
+		"sub_1": {ID: "sub_1", Location: "London", URL: "https://example.com/hook", Secret: "whsec_1", Status: model.SubscriptionActive},
+		"sub_2": {ID: "sub_2", Location: "london,GB", URL: "https://example.com/hook", Secret: "whsec_2", Status: model.SubscriptionActive},
+		"sub_3": {ID: "sub_3", Location: "Paris", URL: "https://example.com/hook", Secret: "whsec_3", Status: model.SubscriptionActive},
+		"sub_4": {ID: "sub_4", Location: "Tokyo", URL: "https://example.com/hook", Secret: "whsec_4", Status: model.SubscriptionActive},
+		"sub_9": {ID: "sub_9", Owner: "team-b", Location: "Oslo", URL: "https://example.com/hook", Status: model.SubscriptionActive},
+	}}
+	h := &WebhookHandler{Subscriptions: store}
+	body := `{"subscriptions":[
+		{"location":"London","url":"https://example.com/hook"},
+		{"location":"Paris","url":"https://example.com/hook","status":"paused"},
+		{"location":"Oslo","url":"https://example.com/hook","secret":"whsec_oslo"}
+	]}`
+
+	// A dry run reports the plan without making it
+	w := putBulk(h, "/subscriptions/bulk?dry_run=true", body)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"dry_run":true`) || len(store.subs) != 5 {
+		t.Fatalf("Expected a dry run with nothing changed, got %d: %s", w.Code, w.Body)
+	}
+
+	w = putBulk(h, "/subscriptions/bulk", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data struct {
+			Created   []model.Subscription `json:"created"`
+			Updated   []model.Subscription `json:"updated"`
+			Deleted   []model.Subscription `json:"deleted"`
+			Unchanged int                  `json:"unchanged"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	sync := resp.Data
+	if len(sync.Created) != 1 || sync.Created[0].Location != "Oslo" || sync.Created[0].Secret != "whsec_oslo" || !strings.HasPrefix(sync.Created[0].ID, "sub_") {
+		t.Errorf("Expected Oslo to be created with its secret, got %+v", sync.Created)
+	}
+	if len(sync.Updated) != 1 || sync.Updated[0].ID != "sub_3" || sync.Updated[0].Status != model.SubscriptionPaused || sync.Updated[0].Secret != "" {
+		t.Errorf("Expected Paris to be paused, without its secret, got %+v", sync.Updated)
+	}
+	if len(sync.Deleted) != 2 || sync.Unchanged != 1 {
+		t.Errorf("Expected the London duplicate and Tokyo deleted and London unchanged, got %+v", sync)
+	}
+	if _, ok := store.subs["sub_1"]; !ok || len(store.subs) != 4 || store.subs["sub_9"] == nil {
+		t.Errorf("Expected London, Paris, the new Oslo and team-b's subscription to remain, got %v", store.subs)
+	}
+
+	// Applying the same state again changes nothing
+	w = putBulk(h, "/subscriptions/bulk", body)
+	if !strings.Contains(w.Body.String(), `"created":[],"updated":[],"deleted":[],"unchanged":3`) {
+		t.Errorf("Expected no changes on a repeat, got %s", w.Body)
+	}
+}
+
+func TestPlanSubscriptionSync_KeepsOldest(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	current := []*model.Subscription{
+		{ID: "sub_1", Location: "London", URL: "https://example.com/hook", CreatedAt: t0.Add(time.Hour)},
+		{ID: "sub_3", Location: "london", URL: "https://example.com/hook", CreatedAt: t0},
+		{ID: "sub_2", Location: "London", URL: "https://example.com/hook", CreatedAt: t0},
+	}
+	desired := []desiredSubscription{{Location: "London", URL: "https://example.com/hook"}}
+	// Whatever order the subscriptions are listed in, the same one is kept
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2}} {
+		listed := make([]*model.Subscription, len(order))
+		for i, j := range order {
+			listed[i] = current[j]
+		}
+		plan := planSubscriptionSync(listed, desired, "")
+		var deleted []string
+		for _, sub := range plan.Deleted {
+			deleted = append(deleted, sub.ID)
+		}
+		if plan.Unchanged+len(plan.Updated) != 1 || strings.Join(deleted, ",") != "sub_3,sub_1" {
+			t.Errorf("Order %v: expected sub_2 kept and sub_3 and sub_1 deleted, got %+v", order, plan)
+		}
+	}
+}
+
+func TestHandleBulkSubscriptions_Errors(t *testing.T) {
+	h := &WebhookHandler{Subscriptions: &mockSubscriptionStore{}}
+	for name, tc := range map[string]struct {
+		target, body, want string
+	}{
+		"json":      {"/subscriptions/bulk", `{"subscriptions":`, "Invalid JSON body"},
+		"dry run":   {"/subscriptions/bulk?dry_run=maybe", `{}`, "Invalid 'dry_run' query parameter"},
+		"url":       {"/subscriptions/bulk", `{"subscriptions":[{"location":"London","url":"https://example.com/a"},{"location":"Paris","url":"ftp://x"}]}`, "subscriptions[1]: 'url' must be an absolute http(s) URL"},
+		"status":    {"/subscriptions/bulk", `{"subscriptions":[{"location":"London","url":"https://example.com/a","status":"gone"}]}`, "subscriptions[0]: 'status' must be active or paused"},
+		"duplicate": {"/subscriptions/bulk", `{"subscriptions":[{"location":"London","url":"https://example.com/a"},{"location":"london,gb","url":"https://example.com/a"}]}`, "subscriptions[1]: same location and url as subscriptions[0]"},
+	} {
+		w := putBulk(h, tc.target, tc.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s: expected 400 %q, got %d: %s", name, tc.want, w.Code, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleBulkSubscriptions(w, httptest.NewRequest(http.MethodPost, "/subscriptions/bulk", strings.NewReader(`{}`)))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", w.Code)
+	}
+}
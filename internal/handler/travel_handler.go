@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// TravelHandler forecasts the conditions along a route at the estimated arrival time at each
+// waypoint.
+type TravelHandler struct {
+	Forecasts    repository.ForecastRepository
+	SpeedKmh     float64
+	MaxWaypoints int
+	// Now returns the current time; replaced in tests.
+	Now func() time.Time
+}
+
+func NewTravelHandler(forecasts ...repository.ForecastRepository) *TravelHandler {
+	cfg := config.GetTravelConfig()
+	h := &TravelHandler{SpeedKmh: cfg.SpeedKmh, MaxWaypoints: cfg.MaxWaypoints, Now: time.Now}
+	if len(forecasts) > 0 && forecasts[0] != nil {
+		h.Forecasts = forecasts[0]
+	} else {
+		h.Forecasts = repository.NewForecastRepository()
+	}
+	return h
+}
+
+// travelWaypoint is one stop of a travel forecast.
+type travelWaypoint struct {
+	Location string    `json:"location"`
+	Arrival  time.Time `json:"arrival"`
+	// DistanceKm is the straight-line distance travelled from the first waypoint.
+	DistanceKm  float64  `json:"distance_km"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Description string   `json:"description,omitempty"`
+	// ForecastAvailable is false when the arrival is outside the provider's forecast.
+	ForecastAvailable bool `json:"forecast_available"`
+}
+
+// travelForecast is the response of GET /travel.
+type travelForecast struct {
+	Departure time.Time        `json:"departure"`
+	SpeedKmh  float64          `json:"speed_kmh"`
+	Waypoints []travelWaypoint `json:"waypoints"`
+}
+
+// HandleTravel serves GET /travel?route=Jakarta,Semarang,Surabaya&departure=2024-06-01T08:00.
+// Arrival times assume straight lines between waypoints at travel.speed_kmh; the conditions at each
+// are interpolated from the waypoint's forecast. departure is RFC 3339, or UTC without a zone,
+// and defaults to now.
+func (h *TravelHandler) HandleTravel(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	departure, ok := h.parseDeparture(q.Get("departure"))
+	if !ok {
+		errMsg := "'departure' must be RFC 3339 or YYYY-MM-DDTHH:MM (UTC)"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	cities, errMsg, unknown := h.parseRoute(q.Get("route"))
+	if errMsg != "" {
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if unknown.Len() > 0 {
+		errMsg := unknown.Error()
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Errors: unknown.Errors, Message: "Error"})
+		return
+	}
+
+	ctx := r.Context()
+	precision := config.GetTemperaturePrecision()
+	resp := travelForecast{Departure: departure, SpeedKmh: h.SpeedKmh, Waypoints: make([]travelWaypoint, 0, len(cities))}
+	distance := 0.0
+	// Every waypoint is looked up even after a failure, so the response names all of them
+	var failures apperror.Multi
+	for i, city := range cities {
+		if i > 0 {
+			distance += geodata.Distance(cities[i-1], city)
+		}
+		wp := travelWaypoint{
+			Location:   city.Name,
+			Arrival:    departure.Add(time.Duration(distance / h.SpeedKmh * float64(time.Hour))).Truncate(time.Minute),
+			DistanceKm: math.Round(distance*10) / 10,
+		}
+		forecast, err := h.Forecasts.GetForecast(ctx, city.Name)
+		if err != nil {
+			failures.Add(forecastErrorCode(err), "", city.Name, err)
+			continue
+		}
+		if entry, ok := forecast.At(wp.Arrival); ok {
+			temperature := model.RoundTemperature(entry.Temperature, precision)
+			wp.Temperature = &temperature
+			wp.Description = entry.Description
+			wp.ForecastAvailable = true
+		}
+		resp.Waypoints = append(resp.Waypoints, wp)
+	}
+	if failures.Len() > 0 {
+		writeProviderErrors(w, r, &failures)
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: resp, Message: "Success"})
+}
+
+func (h *TravelHandler) parseDeparture(raw string) (time.Time, bool) {
+	if raw == "" {
+		return h.Now().UTC().Truncate(time.Minute), true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), true
+	}
+	t, err := time.Parse("2006-01-02T15:04", raw)
+	return t, err == nil
+}
+
+// parseRoute looks up every comma-separated waypoint of route in the city dataset, which provides
+// the coordinates arrival times are estimated from. A malformed route is reported as a message and
+// unknown cities are collected, so the response lists them all.
+func (h *TravelHandler) parseRoute(route string) ([]geodata.City, string, *apperror.Multi) {
+	names := strings.Split(route, ",")
+	if route == "" || len(names) < 2 {
+		return nil, "'route' must list at least two comma-separated cities", nil
+	}
+	if len(names) > h.MaxWaypoints {
+		return nil, "'route' may list at most " + strconv.Itoa(h.MaxWaypoints) + " cities", nil
+	}
+	cities := make([]geodata.City, 0, len(names))
+	unknown := &apperror.Multi{}
+	for _, name := range names {
+		city, ok := geodata.Lookup(name)
+		if !ok {
+			name = strings.TrimSpace(name)
+			unknown.Add(apperror.CodeInvalidInput, "", name, errors.New("unknown city in route: "+name))
+			continue
+		}
+		cities = append(cities, city)
+	}
+	return cities, "", unknown
+}
+
+// forecastErrorCode classifies a failed forecast lookup.
+func forecastErrorCode(err error) apperror.Code {
+	var notFound *repository.LocationNotFoundError
+	var coolDown *repository.CoolDownError
+	switch {
+	case errors.As(err, &notFound), errors.Is(err, repository.ErrCacheOnlyMiss):
+		return apperror.CodeNotFound
+	case errors.As(err, &coolDown):
+		return apperror.CodeUnavailable
+	default:
+		return apperror.CodeUpstream
+	}
+}
+
+// writeProviderErrors writes every failed provider lookup, such as forecasts. The status is 404
+// when all of them were not found, 503 with Retry-After when the provider is cooling down, and
+// 502 otherwise.
+func writeProviderErrors(w http.ResponseWriter, r *http.Request, failures *apperror.Multi) {
+	respondProviderErrors(w, r, writeResponse, failures)
+}
+
+// respondProviderErrors is writeProviderErrors with the response written by respond.
+func respondProviderErrors(w http.ResponseWriter, r *http.Request, respond func(w http.ResponseWriter, status int, resp model.Response), failures *apperror.Multi) {
+	status := http.StatusNotFound
+	var retryAfter time.Duration
+	for _, e := range failures.Errors {
+		var coolDown *repository.CoolDownError
+		switch {
+		case errors.As(e, &coolDown):
+			status = http.StatusServiceUnavailable
+			retryAfter = max(retryAfter, coolDown.RetryAfter)
+		case e.Code != apperror.CodeNotFound && status != http.StatusServiceUnavailable:
+			status = http.StatusBadGateway
+		}
+	}
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	if status != http.StatusNotFound {
+		logger(r.Context()).Warnw("Provider request failed", "errors", failures)
+	}
+	errMsg := failures.Error()
+	respond(w, status, model.Response{Error: &errMsg, Errors: failures.Errors, Message: "Error"})
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockForecastRepository struct {
+	start time.Time
+	err   error
+	// errs fails individual locations.
+	errs map[string]error
+}
+
+// GetForecast returns 3-hourly entries over a day from start, warming by 3°C per entry.
+func (m *mockForecastRepository) GetForecast(_ context.Context, location string) (*model.ForecastResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if err := m.errs[location]; err != nil {
+		return nil, err
+	}
+	f := &model.ForecastResponse{Location: location}
+	for i := 0; i < 8; i++ {
+		f.Entries = append(f.Entries, model.ForecastEntry{Time: m.start.Add(time.Duration(i) * 3 * time.Hour), Temperature: float64(20 + 3*i), Description: location + " sky"})
+	}
+	return f, nil
+}
+
+// GetHourlyForecast returns hours hourly entries from start, warming by 1°C per entry.
+func (m *mockForecastRepository) GetHourlyForecast(_ context.Context, location string, hours int) (*model.ForecastResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	f := &model.ForecastResponse{Location: location, Hours: hours}
+	for i := 0; i < hours; i++ {
+		f.Entries = append(f.Entries, model.ForecastEntry{Time: m.start.Add(time.Duration(i) * time.Hour), Temperature: float64(20 + i), Description: location + " sky"})
+	}
+	return f, nil
+}
+
+func TestHandleTravel(t *testing.T) {
+	start := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	h := &TravelHandler{Forecasts: &mockForecastRepository{start: start}, SpeedKmh: 60, MaxWaypoints: 10, Now: time.Now}
+
+	w := httptest.NewRecorder()
+	h.HandleTravel(w, httptest.NewRequest(http.MethodGet, "/travel?route=Jakarta,Semarang,Surabaya&departure=2024-06-01T08:00", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data travelForecast `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	wps := resp.Data.Waypoints
+	if len(wps) != 3 || wps[0].Location != "Jakarta" || wps[2].Location != "Surabaya" {
+		t.Fatalf("Unexpected waypoints %+v", wps)
+	}
+	if !wps[0].Arrival.Equal(start.Add(2*time.Hour)) || wps[0].DistanceKm != 0 {
+		t.Errorf("Expected to leave Jakarta at departure, got %+v", wps[0])
+	}
+	// 1 hour past the 06:00 entry (20°C) towards the 09:00 one (23°C)
+	if wps[0].Temperature == nil || *wps[0].Temperature != 22 || wps[0].Description != "Jakarta sky" || !wps[0].ForecastAvailable {
+		t.Errorf("Unexpected conditions at Jakarta %+v", wps[0])
+	}
+	for i := 1; i < len(wps); i++ {
+		if wps[i].DistanceKm <= wps[i-1].DistanceKm || !wps[i].Arrival.After(wps[i-1].Arrival) {
+			t.Errorf("Expected later arrivals further along the route, got %+v after %+v", wps[i], wps[i-1])
+		}
+	}
+	want := time.Duration(wps[2].DistanceKm / 60 * float64(time.Hour))
+	if got := wps[2].Arrival.Sub(resp.Data.Departure); got < want-time.Minute || got > want+time.Minute {
+		t.Errorf("Expected arrival after %v at 60km/h, got %v", want, got)
+	}
+}
+
+func TestHandleTravel_OutsideForecast(t *testing.T) {
+	h := &TravelHandler{Forecasts: &mockForecastRepository{start: time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)}, SpeedKmh: 60, MaxWaypoints: 10, Now: time.Now}
+	w := httptest.NewRecorder()
+	h.HandleTravel(w, httptest.NewRequest(http.MethodGet, "/travel?route=Jakarta,Surabaya&departure=2024-07-01T08:00:00Z", nil))
+	var resp struct {
+		Data travelForecast `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Data.Waypoints) != 2 || resp.Data.Waypoints[0].ForecastAvailable || resp.Data.Waypoints[0].Temperature != nil {
+		t.Errorf("Expected waypoints without forecast, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleTravel_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		err        error
+		wantStatus int
+	}{
+		{name: "Single waypoint", target: "/travel?route=Jakarta", wantStatus: http.StatusBadRequest},
+		{name: "Too many waypoints", target: "/travel?route=Jakarta,Semarang,Surabaya,Jakarta", wantStatus: http.StatusBadRequest},
+		{name: "Unknown city", target: "/travel?route=Jakarta,Atlantis", wantStatus: http.StatusBadRequest},
+		{name: "Invalid departure", target: "/travel?route=Jakarta,Surabaya&departure=tomorrow", wantStatus: http.StatusBadRequest},
+		{name: "City not found upstream", target: "/travel?route=Jakarta,Surabaya", err: &repository.LocationNotFoundError{Message: "city not found"}, wantStatus: http.StatusNotFound},
+		{name: "Provider cooling down", target: "/travel?route=Jakarta,Surabaya", err: &repository.CoolDownError{RetryAfter: time.Minute}, wantStatus: http.StatusServiceUnavailable},
+		{name: "Provider error", target: "/travel?route=Jakarta,Surabaya", err: repository.ErrExternalAPI, wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &TravelHandler{Forecasts: &mockForecastRepository{err: tt.err}, SpeedKmh: 60, MaxWaypoints: 3, Now: time.Now}
+			w := httptest.NewRecorder()
+			h.HandleTravel(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleTravel_AggregatesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		errs       map[string]error
+		wantStatus int
+		wantErrors []string
+	}{
+		{
+			name:       "Unknown cities",
+			target:     "/travel?route=Atlantis,Jakarta,Lemuria",
+			wantStatus: http.StatusBadRequest,
+			wantErrors: []string{"Atlantis", "Lemuria"},
+		},
+		{
+			name:   "Failed waypoints",
+			target: "/travel?route=Jakarta,Semarang,Surabaya",
+			errs: map[string]error{
+				"Jakarta":  &repository.LocationNotFoundError{Message: "city not found"},
+				"Surabaya": &repository.CoolDownError{RetryAfter: 90 * time.Second},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantErrors: []string{"Jakarta", "Surabaya"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &TravelHandler{Forecasts: &mockForecastRepository{errs: tt.errs}, SpeedKmh: 60, MaxWaypoints: 3, Now: time.Now}
+			w := httptest.NewRecorder()
+			h.HandleTravel(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+			var resp model.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Expected JSON, got %v", err)
+			}
+			if len(resp.Errors) != len(tt.wantErrors) {
+				t.Fatalf("Expected %d errors, got %+v", len(tt.wantErrors), resp.Errors)
+			}
+			for i, location := range tt.wantErrors {
+				if resp.Errors[i].Location != location {
+					t.Errorf("Expected error %d for %s, got %+v", i, location, resp.Errors[i])
+				}
+			}
+		})
+	}
+}
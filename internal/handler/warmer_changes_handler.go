@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// WarmerChangesHandler reports how often the weather of each warm-listed city changes between
+// refreshes, to tune the warmer interval and TTLs per city.
+type WarmerChangesHandler struct {
+	Store repository.ChangeStore
+	// Cities returns the warm list the report covers.
+	Cities func() []string
+}
+
+func NewWarmerChangesHandler(store ...repository.ChangeStore) *WarmerChangesHandler {
+	h := &WarmerChangesHandler{Cities: config.GetWarmCities}
+	if len(store) > 0 && store[0] != nil {
+		h.Store = store[0]
+	} else {
+		h.Store = repository.NewChangeStore()
+	}
+	return h
+}
+
+// warmerChangesReport is the response of /admin/warmer/changes.
+type warmerChangesReport struct {
+	// Interval is the configured warm cycle interval the cities are compared with.
+	Interval string                   `json:"interval"`
+	Cities   []repository.CityChanges `json:"cities"`
+}
+
+// HandleChanges serves GET /admin/warmer/changes with, per warm-listed city, the refreshes
+// compared, how many found the weather changed, and the mean time between refreshes and between
+// changes. A city refreshed far more often than it changes can be warmed less often.
+func (h *WarmerChangesHandler) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	cities, err := h.Store.Report(r.Context(), h.Cities())
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to read warmer change stats", "error", err)
+		errMsg := "Failed to read warmer change stats"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	report := warmerChangesReport{Interval: config.GetWarmerConfig().Interval.String(), Cities: cities}
+	writeResponse(w, http.StatusOK, model.Response{Data: report, Message: "Success"})
+}
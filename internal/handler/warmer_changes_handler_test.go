@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockChangeStore struct {
+	cities []string
+	err    error
+}
+
+func (m *mockChangeStore) Record(context.Context, string, *model.WeatherResponse, time.Time) (bool, error) {
+	return false, nil
+}
+
+func (m *mockChangeStore) Report(_ context.Context, cities []string) ([]repository.CityChanges, error) {
+	m.cities = cities
+	if m.err != nil {
+		return nil, m.err
+	}
+	report := make([]repository.CityChanges, len(cities))
+	for i, city := range cities {
+		report[i] = repository.CityChanges{City: city, Refreshes: 10, Changes: 2, ChangeRate: 0.2}
+	}
+	return report, nil
+}
+
+func TestHandleWarmerChanges(t *testing.T) {
+	store := &mockChangeStore{}
+	h := NewWarmerChangesHandler(store)
+	h.Cities = func() []string { return []string{"Jakarta", "Bandung"} }
+
+	w := httptest.NewRecorder()
+	h.HandleChanges(w, httptest.NewRequest(http.MethodGet, "/admin/warmer/changes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data warmerChangesReport `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if resp.Data.Interval == "" || len(resp.Data.Cities) != 2 || resp.Data.Cities[1].City != "Bandung" || resp.Data.Cities[1].ChangeRate != 0.2 {
+		t.Errorf("Unexpected report %+v", resp.Data)
+	}
+
+	store.err = errors.New("redis down")
+	w = httptest.NewRecorder()
+	h.HandleChanges(w, httptest.NewRequest(http.MethodGet, "/admin/warmer/changes", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleChanges(w, httptest.NewRequest(http.MethodPost, "/admin/warmer/changes", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
@@ -2,15 +2,42 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/encoding"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geoip"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
 	"github.com/fakhrymubarak/weather-api-redis/internal/service"
 )
 
+// cacheHeader reports whether weather came from the cache; see cacheState.
+const cacheHeader = "X-Cache"
+
+// maxSuggestions caps the "did you mean" locations returned with a not-found error.
+const maxSuggestions = 5
+
 type WeatherHandler struct {
 	WeatherService service.WeatherServiceInterface
+	// Experiments route a share of clients through alternate code paths; see assignExperiment.
+	Experiments []config.Experiment
+	// GeoIP, when set, resolves requests without a location to the caller's approximate city.
+	GeoIP geoip.Locator
+	// Quality configures the quality score of responses. When nil, the current config is used.
+	Quality *config.QualityConfig
+	// format is read once by NewWeatherHandler so the hot path does not consult the config per
+	// request. When nil, the current config is used.
+	format *responseFormat
 }
 
 func NewWeatherHandler(svc ...service.WeatherServiceInterface) *WeatherHandler {
@@ -20,60 +47,415 @@ func NewWeatherHandler(svc ...service.WeatherServiceInterface) *WeatherHandler {
 	} else {
 		weatherService = service.NewWeatherService()
 	}
+	format := currentResponseFormat()
+	quality := config.GetQualityConfig()
 	return &WeatherHandler{
 		WeatherService: weatherService,
+		Experiments:    config.GetExperiments(),
+		GeoIP:          geoip.Configured(),
+		Quality:        &quality,
+		format:         &format,
+	}
+}
+
+func (h *WeatherHandler) qualityConfig() config.QualityConfig {
+	if h.Quality == nil {
+		return config.GetQualityConfig()
 	}
+	return *h.Quality
 }
 
-func (h *WeatherHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+// responseFormat returns the format for r: the handler's configured format with the encoder
+// negotiated from the Accept header. ok is false when no registered encoding is acceptable.
+func (h *WeatherHandler) responseFormat(r *http.Request) (responseFormat, bool) {
+	format := h.format
+	if format == nil {
+		current := currentResponseFormat()
+		format = &current
+	}
+	return negotiate(r, *format)
+}
+
+// jsonpAllowed reports whether ?callback= selects JSONP output.
+func (h *WeatherHandler) jsonpAllowed() bool {
+	if h.format == nil {
+		return config.IsJSONPEnabled()
+	}
+	return h.format.jsonp
+}
+
+// respond writes data in the encoding negotiated for r, falling back to the configured encoding.
+func (h *WeatherHandler) respond(w http.ResponseWriter, r *http.Request, statusCode int, data model.Response) {
+	format, _ := h.responseFormat(r)
+	writeFormattedResponse(w, statusCode, data, format, 0)
 }
 
 func (h *WeatherHandler) HandleWeather(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		errMsg := "Method not allowed"
-		w.Header().Set("Allow", http.MethodGet)
-		h.writeJSONResponse(w, http.StatusMethodNotAllowed, model.Response{
+	if !methodServed(r.Method, http.MethodGet) {
+		respond := func(w http.ResponseWriter, status int, resp model.Response) { h.respond(w, r, status, resp) }
+		serveMethods(w, r, respond, http.MethodGet)
+		return
+	}
+
+	w.Header()["Vary"] = varyAccept
+	if _, ok := h.responseFormat(r); !ok {
+		errMsg := "None of the accepted media types are supported: " + strings.Join(acceptableMediaTypes(), ", ")
+		h.respond(w, r, http.StatusNotAcceptable, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	if h.jsonpAllowed() && query.Has("callback") && !encoding.ValidCallback(query.Get("callback")) {
+		errMsg := "Invalid 'callback' query parameter"
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+	loc, errMsg := locationParam(query)
+	resolvedFrom := ""
+	if errMsg != "" && !hasLocationParam(query) {
+		if located, ok := h.locateClient(r); ok {
+			loc, errMsg, resolvedFrom = located, "", "ip"
+		}
+	}
+	if errMsg != "" {
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+
+	refresh, err := parseBoolParam(query, "refresh")
+	if err != nil {
+		errMsg := "Invalid 'refresh' query parameter"
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+	cacheOnly, err := parseBoolParam(query, "cache_only")
+	if err != nil {
+		errMsg := "Invalid 'cache_only' query parameter"
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+	// Cache-Control: only-if-cached never contacts the provider, so it wins over no-cache
+	noCache, onlyIfCached := cacheControlHints(r.Header)
+	cacheOnly = cacheOnly || onlyIfCached
+	// HEAD reports the cache state of a location without ever calling the provider
+	if r.Method == http.MethodHead {
+		if refresh {
+			errMsg := "'refresh' is not supported with HEAD"
+			h.respond(w, r, http.StatusBadRequest, model.Response{
+				Error:   &errMsg,
+				Message: "Error",
+			})
+			return
+		}
+		cacheOnly = true
+	}
+	refresh = refresh || noCache && !cacheOnly
+	if refresh && cacheOnly {
+		errMsg := "'refresh' and 'cache_only' cannot be combined"
+		h.respond(w, r, http.StatusBadRequest, model.Response{
 			Error:   &errMsg,
 			Message: "Error",
 		})
 		return
 	}
 
-	location := r.URL.Query().Get("location")
-	if location == "" {
-		errMsg := "Missing 'location' query parameter"
-		h.writeJSONResponse(w, http.StatusBadRequest, model.Response{
+	shape, ok := responseShape(r, query.Get("format"))
+	if !ok {
+		errMsg := "Invalid 'format' query parameter"
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+	include, errMsg := includeParam(query)
+	if errMsg != "" {
+		h.respond(w, r, http.StatusBadRequest, model.Response{
+			Error:   &errMsg,
+			Message: "Error",
+		})
+		return
+	}
+	// The other shapes are fixed documents that JSONP cannot wrap, so the error is not sent
+	// through the callback either
+	if shape != "" && h.jsonpAllowed() && query.Has("callback") {
+		errMsg := "'callback' is not supported with the " + shape + " format"
+		writeResponse(w, http.StatusBadRequest, model.Response{
 			Error:   &errMsg,
 			Message: "Error",
 		})
 		return
 	}
 
-	ctx := context.Background()
-	weather, err := h.WeatherService.GetWeather(ctx, location)
+	// Coordinates have no name to suggest alternatives to
+	location := loc.Name
+	ctx := logctx.WithLocation(r.Context(), loc.Key())
+	switch {
+	case refresh:
+		ctx = repository.WithCachePolicy(ctx, repository.CachePolicyRefresh)
+	case cacheOnly:
+		ctx = repository.WithCachePolicy(ctx, repository.CachePolicyCacheOnly)
+	}
+
+	ctx = h.assignExperiment(ctx, r)
+	weather, err := h.getWeather(ctx, loc)
+	recordExperiment(ctx, err)
+	w.Header().Set(cacheHeader, cacheState(weather, err))
 	if err != nil {
-		// Check for downstream city not found error
-		if err.Error() == "city not found" || err.Error() == "location not found" {
+		// Aggregated errors are returned in full so clients can see every failure
+		var multi *apperror.Multi
+		if errors.As(err, &multi) {
+			logger(ctx).Warnw("Weather request failed", "errors", multi)
+			h.writeMultiErrorResponse(w, r, multi, location)
+			return
+		}
+		if errors.Is(err, service.ErrRejected) {
+			errMsg := err.Error()
+			h.respond(w, r, http.StatusForbidden, model.Response{
+				Error:   &errMsg,
+				Message: "Error",
+			})
+			return
+		}
+		if errors.Is(err, geodata.ErrInvalidLocation) || errors.Is(err, model.ErrCoordinatesOutOfRange) || errors.Is(err, service.ErrCoordinatesUnsupported) {
+			errMsg := err.Error()
+			h.respond(w, r, http.StatusBadRequest, model.Response{
+				Error:       &errMsg,
+				Message:     "Error",
+				Suggestions: geodata.Suggest(location, maxSuggestions),
+			})
+			return
+		}
+		// only-if-cached with nothing cached is a 504, as from an HTTP cache
+		if onlyIfCached && errors.Is(err, repository.ErrCacheOnlyMiss) {
 			errMsg := err.Error()
-			h.writeJSONResponse(w, http.StatusNotFound, model.Response{
+			h.respond(w, r, http.StatusGatewayTimeout, model.Response{
+				Error:   &errMsg,
+				Message: "Error",
+			})
+			return
+		}
+		// Check for downstream city not found error, or a cache-only request with nothing cached
+		if err.Error() == "city not found" || err.Error() == "location not found" || errors.Is(err, repository.ErrCacheOnlyMiss) {
+			errMsg := err.Error()
+			resp := model.Response{
+				Error:   &errMsg,
+				Message: "Error",
+			}
+			if !errors.Is(err, repository.ErrCacheOnlyMiss) {
+				resp.Suggestions = geodata.Suggest(location, maxSuggestions)
+			}
+			h.respond(w, r, http.StatusNotFound, resp)
+			return
+		}
+		var coolDown *repository.CoolDownError
+		if errors.As(err, &coolDown) {
+			errMsg := "Weather provider is rate limiting requests and nothing is cached for this location"
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(coolDown.RetryAfter.Seconds()))))
+			h.respond(w, r, http.StatusServiceUnavailable, model.Response{
 				Error:   &errMsg,
 				Message: "Error",
 			})
 			return
 		}
 		errMsg := "Failed to fetch weather data"
-		h.writeJSONResponse(w, http.StatusInternalServerError, model.Response{
+		h.respond(w, r, http.StatusInternalServerError, model.Response{
 			Error:   &errMsg,
 			Message: "Error",
 		})
 		return
 	}
 
-	h.writeJSONResponse(w, http.StatusOK, model.Response{
+	if resolvedFrom != "" {
+		// The service may return shared cached values, so the flag is set on a copy
+		resolved := *weather
+		resolved.ResolvedFrom = resolvedFrom
+		weather = &resolved
+	}
+
+	// Quality is scored on everything known of the weather, whatever blocks were asked for
+	full := weather
+	weather = include.apply(weather)
+
+	switch shape {
+	case formatHomeAssistant:
+		writeHomeAssistant(w, weather)
+		return
+	case formatDisplay:
+		h.writeDisplay(w, r, weather)
+		return
+	case formatGeoJSON:
+		writeGeoJSON(w, toGeoJSONFeature(weather))
+		return
+	}
+	h.respond(w, r, http.StatusOK, model.Response{
 		Data:    weather,
+		Quality: weatherQuality(h.qualityConfig(), full),
 		Message: "Success",
 	})
 }
+
+// getWeather looks up loc, by coordinates when the service supports them.
+func (h *WeatherHandler) getWeather(ctx context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	if loc.Coords == nil {
+		return h.WeatherService.GetWeather(ctx, loc.Name)
+	}
+	svc, ok := h.WeatherService.(service.LocationWeatherService)
+	if !ok {
+		return nil, service.ErrCoordinatesUnsupported
+	}
+	return svc.GetWeatherAt(ctx, loc)
+}
+
+// locationParam reads what a weather request is for: ?location=, or ?lat= and ?lon= together. It
+// returns the error message to answer with when neither or both are given, or the coordinates are
+// invalid.
+func locationParam(query url.Values) (model.Location, string) {
+	location := query.Get("location")
+	if !query.Has("lat") && !query.Has("lon") {
+		if location == "" {
+			return model.Location{}, "Missing 'location' query parameter"
+		}
+		return model.Location{Name: location}, ""
+	}
+	if location != "" {
+		return model.Location{}, "'location' and 'lat'/'lon' cannot be combined"
+	}
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(query.Get("lon"), 64)
+	if latErr != nil || lonErr != nil {
+		return model.Location{}, "'lat' and 'lon' must both be given in decimal degrees"
+	}
+	loc := model.LocationAt(lat, lon)
+	if loc.Coords.Validate() != nil {
+		return model.Location{}, "'lat' must be within [-90, 90] and 'lon' within [-180, 180]"
+	}
+	return loc, ""
+}
+
+// hasLocationParam reports whether the request names a location at all, rightly or wrongly.
+func hasLocationParam(query url.Values) bool {
+	return query.Get("location") != "" || query.Has("lat") || query.Has("lon")
+}
+
+// locateClient resolves the caller's address to its city, "City,CC", or to the coordinates when
+// the database knows no city there. ok is false without a GeoIP database or a match.
+func (h *WeatherHandler) locateClient(r *http.Request) (model.Location, bool) {
+	if h.GeoIP == nil {
+		return model.Location{}, false
+	}
+	addr, ok := clientAddr(r)
+	if !ok {
+		return model.Location{}, false
+	}
+	place, err := h.GeoIP.Locate(addr)
+	if err != nil {
+		logger(r.Context()).Debugw("Client address not located", "addr", addr, "error", err)
+		return model.Location{}, false
+	}
+	switch {
+	case place.City != "" && place.Country != "":
+		return model.Location{Name: place.City + "," + place.Country}, true
+	case place.City != "":
+		return model.Location{Name: place.City}, true
+	case place.HasCoordinates:
+		return model.LocationAt(place.Lat, place.Lon), true
+	default:
+		return model.Location{}, false
+	}
+}
+
+// clientAddr is the caller's address: the first X-Forwarded-For entry, with or without a port,
+// or the connection's remote address.
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		first, _, _ := strings.Cut(xff, ",")
+		host = strings.TrimSpace(first)
+	}
+	if addrPort, err := netip.ParseAddrPort(host); err == nil {
+		return addrPort.Addr(), true
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr, err == nil
+}
+
+// cacheState is the X-Cache value for a weather lookup: HIT for fresh cached data, STALE for
+// expired data served while the provider is unavailable, and MISS otherwise.
+func cacheState(weather *model.WeatherResponse, err error) string {
+	switch {
+	case err != nil:
+		return "MISS"
+	case weather.Stale:
+		return "STALE"
+	case weather.Cached:
+		return "HIT"
+	default:
+		return "MISS"
+	}
+}
+
+// writeMultiErrorResponse writes every aggregated error. The status is 404 only when all errors are
+// not-found errors, and 502 otherwise.
+func (h *WeatherHandler) writeMultiErrorResponse(w http.ResponseWriter, r *http.Request, multi *apperror.Multi, location string) {
+	status := http.StatusNotFound
+	for _, e := range multi.Errors {
+		if e.Code != apperror.CodeNotFound {
+			status = http.StatusBadGateway
+			break
+		}
+	}
+	errMsg := multi.Error()
+	resp := model.Response{
+		Error:   &errMsg,
+		Errors:  multi.Errors,
+		Message: "Error",
+	}
+	if status == http.StatusNotFound {
+		resp.Suggestions = geodata.Suggest(location, maxSuggestions)
+	}
+	h.respond(w, r, status, resp)
+}
+
+// cacheControlHints reads the request's Cache-Control header: no-cache asks for fresh data and
+// only-if-cached for a cached response or none at all.
+func cacheControlHints(h http.Header) (noCache, onlyIfCached bool) {
+	for _, v := range h.Values("Cache-Control") {
+		for v != "" {
+			var directive string
+			directive, v, _ = strings.Cut(v, ",")
+			directive, _, _ = strings.Cut(directive, "=")
+			switch directive = strings.TrimSpace(directive); {
+			case strings.EqualFold(directive, "no-cache"):
+				noCache = true
+			case strings.EqualFold(directive, "only-if-cached"):
+				onlyIfCached = true
+			}
+		}
+	}
+	return noCache, onlyIfCached
+}
+
+// parseBoolParam parses an optional boolean query parameter. A missing parameter is false.
+func parseBoolParam(query url.Values, name string) (bool, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
@@ -4,11 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fakhrymubarak/weather-api-redis/api/proto/weatherpb"
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geoip"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
 	"github.com/fakhrymubarak/weather-api-redis/internal/service"
 )
 
@@ -217,6 +226,61 @@ func TestWeatherHandler_HandleWeather_EdgeCases(t *testing.T) {
 	}
 }
 
+func TestWeatherHandler_HandleWeather_InvalidLocation(t *testing.T) {
+	handler := &WeatherHandler{
+		WeatherService: &mockWeatherService{error: fmt.Errorf("%w: no letters", geodata.ErrInvalidLocation)},
+	}
+	req, _ := http.NewRequest("GET", "/weather?location=12345", nil)
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid location: no letters") {
+		t.Errorf("Expected 400 with the validation error, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestWeatherHandler_HandleWeather_Rejected(t *testing.T) {
+	handler := &WeatherHandler{
+		WeatherService: &mockWeatherService{error: fmt.Errorf("%w: region is blocked", service.ErrRejected)},
+	}
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "region is blocked") {
+		t.Errorf("Expected 403 with the hook's error, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestWeatherHandler_HandleWeather_ProviderCoolingDown(t *testing.T) {
+	handler := &WeatherHandler{
+		WeatherService: &mockWeatherService{error: &repository.CoolDownError{RetryAfter: 1500 * time.Millisecond}},
+	}
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected 503 with Retry-After: 2, got %d with %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestWeatherHandler_HandleWeather_Suggestions(t *testing.T) {
+	handler := &WeatherHandler{WeatherService: &mockWeatherService{error: errLocationNotFound}}
+	req, _ := http.NewRequest("GET", "/weather?location=Makasar", nil)
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"suggestions":["Makassar"]`) {
+		t.Errorf("Expected 404 with suggestions, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	handler = &WeatherHandler{WeatherService: &mockWeatherService{error: repository.ErrCacheOnlyMiss}}
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+	if strings.Contains(rr.Body.String(), "suggestions") {
+		t.Errorf("Expected no suggestions for a cache-only miss, got %s", rr.Body.String())
+	}
+}
+
 func TestWeatherHandler_HandleWeather_NonGETMethod(t *testing.T) {
 	handler := &WeatherHandler{
 		WeatherService: &mockWeatherService{
@@ -238,8 +302,8 @@ func TestWeatherHandler_HandleWeather_NonGETMethod(t *testing.T) {
 	}
 
 	allow := rr.Header().Get("Allow")
-	if allow != http.MethodGet {
-		t.Errorf("Expected Allow header to be 'GET', got '%s'", allow)
+	if allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow header to be 'GET, HEAD, OPTIONS', got '%s'", allow)
 	}
 
 	var response model.Response
@@ -262,3 +326,363 @@ func BenchmarkWeatherHandler_HandleWeather(b *testing.B) {
 		handler.HandleWeather(rr, req)
 	}
 }
+
+func TestWeatherHandler_HandleWeather_MultiError(t *testing.T) {
+	var notFound apperror.Multi
+	notFound.Add(apperror.CodeNotFound, "openweathermap", "Atlantis", errors.New("city not found"))
+
+	var mixed apperror.Multi
+	mixed.Add(apperror.CodeNotFound, "openweathermap", "Atlantis", errors.New("city not found"))
+	mixed.Add(apperror.CodeUpstream, "openweathermap", "Jakarta", errWeatherService)
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedErrors int
+	}{
+		{name: "All not found", err: &notFound, expectedStatus: http.StatusNotFound, expectedErrors: 1},
+		{name: "Mixed failures", err: &mixed, expectedStatus: http.StatusBadGateway, expectedErrors: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &WeatherHandler{WeatherService: &mockWeatherService{error: tt.err}}
+			req, _ := http.NewRequest(http.MethodGet, "/weather?location=Atlantis", nil)
+			rr := httptest.NewRecorder()
+			handler.HandleWeather(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			var response model.Response
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode JSON error response: %v", err)
+			}
+			if len(response.Errors) != tt.expectedErrors {
+				t.Errorf("Expected %d errors, got %d", tt.expectedErrors, len(response.Errors))
+			}
+		})
+	}
+}
+
+// ctxCapturingService records the context it was called with
+type ctxCapturingService struct {
+	ctx context.Context
+}
+
+func (c *ctxCapturingService) GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error) {
+	c.ctx = ctx
+	return &model.WeatherResponse{Location: location}, nil
+}
+
+func TestWeatherHandler_HandleWeather_Refresh(t *testing.T) {
+	svc := &ctxCapturingService{}
+	handler := &WeatherHandler{WeatherService: svc}
+
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&refresh=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if repository.CachePolicyFrom(svc.ctx) != repository.CachePolicyRefresh {
+		t.Error("Expected refresh cache policy on the service context")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&refresh=false", nil))
+	if repository.CachePolicyFrom(svc.ctx) != repository.CachePolicyDefault {
+		t.Error("Expected default cache policy when refresh=false")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&refresh=maybe", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid refresh value, got %d", rr.Code)
+	}
+}
+
+// coordinatesService is a ctxCapturingService that also looks up coordinates.
+type coordinatesService struct {
+	ctxCapturingService
+	loc model.Location
+}
+
+func (c *coordinatesService) GetWeatherAt(ctx context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	c.ctx, c.loc = ctx, loc
+	return &model.WeatherResponse{Location: "Jakarta", Temperature: 27}, nil
+}
+
+func TestWeatherHandler_HandleWeather_Coordinates(t *testing.T) {
+	svc := &coordinatesService{}
+	handler := &WeatherHandler{WeatherService: svc}
+
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?lat=-6.2088&lon=106.8456&refresh=true", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"Jakarta"`) {
+		t.Fatalf("Expected 200 with Jakarta, got %d: %s", rr.Code, rr.Body)
+	}
+	if svc.loc.Coords == nil || *svc.loc.Coords != (model.Coordinates{Lat: -6.2088, Lon: 106.8456}) {
+		t.Errorf("Expected the coordinates passed to the service, got %+v", svc.loc)
+	}
+	if repository.CachePolicyFrom(svc.ctx) != repository.CachePolicyRefresh {
+		t.Error("Expected refresh cache policy on the service context")
+	}
+
+	for _, tt := range []struct {
+		query, want string
+	}{
+		{"lat=-6.2", "'lat' and 'lon' must both be given in decimal degrees"},
+		{"lat=-6.2&lon=east", "'lat' and 'lon' must both be given in decimal degrees"},
+		{"lat=-91&lon=0", "'lat' must be within [-90, 90] and 'lon' within [-180, 180]"},
+		{"lat=0&lon=NaN", "'lat' must be within [-90, 90] and 'lon' within [-180, 180]"},
+		{"location=Jakarta&lat=-6.2&lon=106.8", "'location' and 'lat'/'lon' cannot be combined"},
+	} {
+		rr := httptest.NewRecorder()
+		handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?"+tt.query, nil))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tt.want) {
+			t.Errorf("%s: expected 400 %q, got %d: %s", tt.query, tt.want, rr.Code, rr.Body)
+		}
+	}
+
+	// A service looking up names only cannot serve coordinates
+	handler = &WeatherHandler{WeatherService: &ctxCapturingService{}}
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?lat=0&lon=0", nil))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), service.ErrCoordinatesUnsupported.Error()) {
+		t.Errorf("Expected 400 for unsupported coordinates, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+// fakeLocator places every address it knows.
+type fakeLocator map[string]geoip.Place
+
+func (f fakeLocator) Locate(addr netip.Addr) (geoip.Place, error) {
+	if place, ok := f[addr.String()]; ok {
+		return place, nil
+	}
+	return geoip.Place{}, geoip.ErrNotFound
+}
+
+func TestWeatherHandler_HandleWeather_GeoIP(t *testing.T) {
+	svc := &coordinatesService{}
+	handler := &WeatherHandler{WeatherService: svc, GeoIP: fakeLocator{
+		"203.0.113.7":  {City: "Jakarta", Country: "ID", Lat: -6.2, Lon: 106.8, HasCoordinates: true},
+		"2001:db8::1":  {City: "Paris", Country: "FR"},
+		"198.51.100.1": {Country: "ID", Lat: -2.5, Lon: 118, HasCoordinates: true},
+	}}
+
+	for _, tt := range []struct {
+		remoteAddr, forwardedFor, want string
+	}{
+		{"203.0.113.7:51234", "", "Jakarta,ID"},
+		{"10.0.0.1:80", "203.0.113.7, 10.0.0.2", "Jakarta,ID"},
+		{"10.0.0.1:80", "[2001:db8::1]:443", "Paris,FR"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		handler.HandleWeather(rr, req)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, `"location":"`+tt.want+`"`) || !strings.Contains(body, `"resolved_from":"ip"`) {
+			t.Errorf("%s %s: expected 200 for %s resolved from the IP, got %d: %s", tt.remoteAddr, tt.forwardedFor, tt.want, rr.Code, body)
+		}
+	}
+
+	// Without a city the lookup is by coordinates
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+	if rr.Code != http.StatusOK || svc.loc.Coords == nil || *svc.loc.Coords != (model.Coordinates{Lat: -2.5, Lon: 118}) {
+		t.Errorf("Expected a lookup at the located coordinates, got %d %+v", rr.Code, svc.loc)
+	}
+
+	// Unknown addresses, and requests naming a location wrongly, keep the 400
+	for _, tt := range []struct {
+		target, remoteAddr string
+	}{
+		{"/weather", "192.0.2.1:1234"},
+		{"/weather", "not an address"},
+		{"/weather?lat=1", "203.0.113.7:1234"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.RemoteAddr = tt.remoteAddr
+		rr := httptest.NewRecorder()
+		handler.HandleWeather(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s from %s: expected 400, got %d: %s", tt.target, tt.remoteAddr, rr.Code, rr.Body)
+		}
+	}
+
+	// A given location is never replaced, nor flagged
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "resolved_from") {
+		t.Errorf("Expected London without resolved_from, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestWeatherHandler_HandleWeather_CacheOnly(t *testing.T) {
+	svc := &ctxCapturingService{}
+	handler := &WeatherHandler{WeatherService: svc}
+
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&cache_only=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if repository.CachePolicyFrom(svc.ctx) != repository.CachePolicyCacheOnly {
+		t.Error("Expected cache-only policy on the service context")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&cache_only=true&refresh=true", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when combining refresh and cache_only, got %d", rr.Code)
+	}
+
+	handler = &WeatherHandler{WeatherService: &mockWeatherService{error: repository.ErrCacheOnlyMiss}}
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London&cache_only=true", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a cache-only miss, got %d", rr.Code)
+	}
+}
+
+func TestWeatherHandler_HandleWeather_CacheControl(t *testing.T) {
+	svc := &ctxCapturingService{}
+	handler := &WeatherHandler{WeatherService: svc}
+	tests := []struct {
+		method, cacheControl string
+		want                 repository.CachePolicy
+	}{
+		{http.MethodGet, "no-cache", repository.CachePolicyRefresh},
+		{http.MethodGet, "max-age=0, No-Cache", repository.CachePolicyRefresh},
+		{http.MethodGet, "only-if-cached", repository.CachePolicyCacheOnly},
+		{http.MethodGet, "no-cache, only-if-cached", repository.CachePolicyCacheOnly},
+		{http.MethodGet, "no-store", repository.CachePolicyDefault},
+		{http.MethodHead, "no-cache", repository.CachePolicyCacheOnly},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/weather?location=London", nil)
+		req.Header.Set("Cache-Control", tt.cacheControl)
+		rr := httptest.NewRecorder()
+		handler.HandleWeather(rr, req)
+		if rr.Code != http.StatusOK || repository.CachePolicyFrom(svc.ctx) != tt.want {
+			t.Errorf("%s with Cache-Control: %s: expected policy %v, got %v (status %d)", tt.method, tt.cacheControl, tt.want, repository.CachePolicyFrom(svc.ctx), rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London&refresh=true", nil)
+	req.Header.Set("Cache-Control", "only-if-cached")
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when combining refresh and only-if-cached, got %d", rr.Code)
+	}
+
+	handler = &WeatherHandler{WeatherService: &mockWeatherService{error: repository.ErrCacheOnlyMiss}}
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.Header.Set("Cache-Control", "only-if-cached")
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, req)
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 for only-if-cached with nothing cached, got %d", rr.Code)
+	}
+}
+
+func TestWeatherHandler_HandleWeather_Head(t *testing.T) {
+	svc := &ctxCapturingService{}
+	handler := &WeatherHandler{WeatherService: svc}
+
+	rr := httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodHead, "/weather?location=London", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if repository.CachePolicyFrom(svc.ctx) != repository.CachePolicyCacheOnly {
+		t.Error("Expected HEAD to never call the provider")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodHead, "/weather?location=London&refresh=true", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for HEAD with refresh, got %d", rr.Code)
+	}
+
+	handler = &WeatherHandler{WeatherService: &mockWeatherService{error: repository.ErrCacheOnlyMiss}}
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodHead, "/weather?location=London", nil))
+	if rr.Code != http.StatusNotFound || rr.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected 404 with X-Cache MISS, got %d %q", rr.Code, rr.Header().Get("X-Cache"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.HandleWeather(rr, httptest.NewRequest(http.MethodOptions, "/weather", nil))
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected 204 with the Allow list, got %d %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
+
+func TestCacheState(t *testing.T) {
+	tests := []struct {
+		weather *model.WeatherResponse
+		err     error
+		want    string
+	}{
+		{&model.WeatherResponse{Cached: true}, nil, "HIT"},
+		{&model.WeatherResponse{Cached: true, Stale: true}, nil, "STALE"},
+		{&model.WeatherResponse{}, nil, "MISS"},
+		{nil, repository.ErrCacheOnlyMiss, "MISS"},
+	}
+	for _, tt := range tests {
+		if got := cacheState(tt.weather, tt.err); got != tt.want {
+			t.Errorf("cacheState(%+v, %v) = %q, want %q", tt.weather, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherHandler_HandleWeather_ContentNegotiation(t *testing.T) {
+	h := NewWeatherHandler(&mockWeatherService{mockData: &model.WeatherResponse{Location: "London", Temperature: 15}})
+
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rr := httptest.NewRecorder()
+	h.HandleWeather(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/msgpack" {
+		t.Fatalf("Expected a msgpack response, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected Vary: Accept, got %q", rr.Header().Get("Vary"))
+	}
+	if body := rr.Body.Bytes(); len(body) == 0 || body[0]&0xf0 != 0x80 {
+		t.Errorf("Expected a msgpack map, got % x", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, req)
+	var env weatherpb.Envelope
+	if err := env.Unmarshal(rr.Body.Bytes()); err != nil || env.Weather == nil || env.Weather.Location != "London" {
+		t.Errorf("Expected a protobuf envelope, got %+v (%v)", env, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.Header.Set("Accept", "text/html")
+	rr = httptest.NewRecorder()
+	h.HandleWeather(rr, req)
+	if rr.Code != http.StatusNotAcceptable {
+		t.Fatalf("Expected 406, got %d", rr.Code)
+	}
+	var resp model.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.Error == nil {
+		t.Errorf("Expected a JSON error body, got %v", err)
+	}
+}
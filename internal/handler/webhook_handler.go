@@ -0,0 +1,476 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/webhook"
+)
+
+const (
+	defaultDeadLetterLimit   = 50
+	maxDeadLetterLimit       = 1000
+	defaultSubscriptionLimit = 50
+	maxSubscriptionLimit     = 200
+	// maxSubscriptionBody caps the size of subscription request bodies.
+	maxSubscriptionBody = 16 << 10
+)
+
+// WebhookHandler manages webhook subscriptions and their failed deliveries.
+type WebhookHandler struct {
+	Subscriptions repository.SubscriptionStore
+	DeadLetters   repository.DeadLetterStore
+	Queue         repository.DeliveryQueue
+	// AllowPrivateTargets accepts subscription URLs on loopback, private and link-local hosts.
+	AllowPrivateTargets bool
+}
+
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		Subscriptions:       repository.NewSubscriptionStore(),
+		DeadLetters:         repository.NewDeadLetterStore(),
+		Queue:               repository.NewDeliveryQueue(),
+		AllowPrivateTargets: config.GetWebhookConfig().AllowPrivateTargets,
+	}
+}
+
+// subscriptionRequest is the body of POST /subscriptions.
+type subscriptionRequest struct {
+	Location string `json:"location"`
+	URL      string `json:"url"`
+	// Secret is generated when empty.
+	Secret string `json:"secret"`
+}
+
+// subscriptionUpdate is the body of PATCH /subscriptions/{id}. Omitted fields are left unchanged.
+type subscriptionUpdate struct {
+	Location *string `json:"location"`
+	URL      *string `json:"url"`
+	Status   *string `json:"status"`
+}
+
+// subscriptionPage is the response of GET /subscriptions.
+type subscriptionPage struct {
+	Subscriptions []*model.Subscription `json:"subscriptions"`
+	// NextCursor is passed as ?cursor= to fetch the next page; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// HandleSubscriptions serves /subscriptions. GET lists the caller's subscriptions, POST creates one.
+func (h *WebhookHandler) HandleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		h.createSubscription(w, r)
+		return
+	}
+	h.listSubscriptions(w, r)
+}
+
+// createSubscription creates a subscription owned by the caller's API key. The response is the
+// only place the signing secret is returned.
+func (h *WebhookHandler) createSubscription(w http.ResponseWriter, r *http.Request) {
+	var req subscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBody)).Decode(&req); err != nil {
+		errMsg := "Invalid JSON body"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if errMsg := h.validateSubscription(&req); errMsg != "" {
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	sub := &model.Subscription{
+		ID:        webhook.NewSubscriptionID(),
+		Location:  strings.TrimSpace(req.Location),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Status:    model.SubscriptionActive,
+		CreatedAt: time.Now().UTC(),
+	}
+	if sub.Secret == "" {
+		sub.Secret = webhook.NewSecret()
+	}
+	sub.Owner = subscriptionOwner(r)
+	if err := h.Subscriptions.Create(r.Context(), sub); err != nil {
+		logger(r.Context()).Errorw("Failed to create subscription", "error", err)
+		errMsg := "Failed to create subscription"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	w.Header().Set("Location", "/subscriptions/"+sub.ID)
+	setResourceETag(w, sub.Revision)
+	writeResponse(w, http.StatusCreated, model.Response{Data: sub, Message: "Success"})
+}
+
+// listSubscriptions serves GET /subscriptions?location=&status=&cursor=&limit=, scoped to the
+// caller's API key.
+func (h *WebhookHandler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := repository.SubscriptionFilter{
+		Owner:    subscriptionOwner(r),
+		Location: q.Get("location"),
+		Status:   q.Get("status"),
+	}
+	if filter.Status != "" && !validSubscriptionStatus(filter.Status) {
+		errMsg := "'status' must be active or paused"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	limit := defaultSubscriptionLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSubscriptionLimit {
+			errMsg := "'limit' must be between 1 and " + strconv.Itoa(maxSubscriptionLimit)
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		limit = n
+	}
+
+	subs, next, err := h.Subscriptions.List(r.Context(), filter, q.Get("cursor"), limit)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to list subscriptions", "error", err)
+		errMsg := "Failed to list subscriptions"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	page := subscriptionPage{Subscriptions: make([]*model.Subscription, 0, len(subs)), NextCursor: next}
+	for _, sub := range subs {
+		page.Subscriptions = append(page.Subscriptions, withoutSecret(sub))
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: page, Message: "Success"})
+}
+
+// HandleSubscription serves a single subscription owned by the caller's API key:
+//
+//	GET    /subscriptions/{id}
+//	PUT    /subscriptions/{id}         {"location", "url", "status", "secret"}
+//	PATCH  /subscriptions/{id}         {"location", "url", "status"}
+//	DELETE /subscriptions/{id}
+//	POST   /subscriptions/{id}/pause
+//	POST   /subscriptions/{id}/resume
+//
+// PUT replaces the subscription, or creates it under the ID when there is none, so tooling can
+// pick IDs and repeat the request safely. Writes honour If-Match and If-None-Match, see
+// writePrecondition. Subscriptions owned by another key are reported as not found.
+func (h *WebhookHandler) HandleSubscription(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/subscriptions/"), "/")
+	if id == "" || (action != "" && action != "pause" && action != "resume") {
+		errMsg := "Subscription not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	methods := []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	if action != "" {
+		methods = []string{http.MethodPost}
+	}
+	if !serveMethods(w, r, writeResponse, methods...) {
+		return
+	}
+
+	sub, err := h.Subscriptions.Get(r.Context(), id)
+	if err != nil && !errors.Is(err, repository.ErrSubscriptionNotFound) {
+		logger(r.Context()).Errorw("Failed to read subscription", "error", err)
+		errMsg := "Failed to read subscription"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	switch {
+	case err != nil && r.Method == http.MethodPut:
+		h.putNewSubscription(w, r, id)
+		return
+	case err == nil && sub.Owner != subscriptionOwner(r) && r.Method == http.MethodPut:
+		errMsg := "Subscription ID is in use"
+		writeResponse(w, http.StatusConflict, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	case err != nil || sub.Owner != subscriptionOwner(r):
+		errMsg := "Subscription not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if notModified(w, r, sub.Revision) {
+			return
+		}
+		setResourceETag(w, sub.Revision)
+		writeResponse(w, http.StatusOK, model.Response{Data: withoutSecret(sub), Message: "Success"})
+		return
+	}
+	revision, ok := writePrecondition(w, r, sub.Revision)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodDelete {
+		h.deleteSubscription(w, r, id, revision)
+		return
+	}
+
+	updated := *sub
+	updated.Revision = revision
+	switch {
+	case action == "pause":
+		updated.Status = model.SubscriptionPaused
+	case action == "resume":
+		updated.Status = model.SubscriptionActive
+	case r.Method == http.MethodPut:
+		var req desiredSubscription
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBody)).Decode(&req); err != nil {
+			errMsg := "Invalid JSON body"
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		if errMsg := h.validateDesiredSubscription(req); errMsg != "" {
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		updated.Location, updated.URL, updated.Status = strings.TrimSpace(req.Location), req.URL, desiredStatus(req)
+		if req.Secret != "" {
+			updated.Secret = req.Secret
+		}
+	default:
+		var req subscriptionUpdate
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBody)).Decode(&req); err != nil {
+			errMsg := "Invalid JSON body"
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		if errMsg := h.applySubscriptionUpdate(&updated, req); errMsg != "" {
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+	}
+	if updated.Revision = sub.Revision; updated == *sub {
+		// Nothing to change: the revision, and so the ETag, stays the same
+		setResourceETag(w, sub.Revision)
+		writeResponse(w, http.StatusOK, model.Response{Data: withoutSecret(sub), Message: "Success"})
+		return
+	}
+	updated.Revision = revision
+	err = h.Subscriptions.Update(r.Context(), &updated)
+	switch {
+	case errors.Is(err, repository.ErrRevisionMismatch):
+		writePreconditionFailed(w, "")
+	case errors.Is(err, repository.ErrSubscriptionNotFound):
+		errMsg := "Subscription not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to update subscription", "error", err)
+		errMsg := "Failed to update subscription"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+	default:
+		setResourceETag(w, updated.Revision)
+		writeResponse(w, http.StatusOK, model.Response{Data: withoutSecret(&updated), Message: "Success"})
+	}
+}
+
+// putNewSubscription creates the subscription of a PUT to an unused ID. Like POST /subscriptions,
+// the response is the only place the signing secret is returned.
+func (h *WebhookHandler) putNewSubscription(w http.ResponseWriter, r *http.Request, id string) {
+	revision, ok := writePrecondition(w, r, "")
+	if !ok {
+		return
+	}
+	if !validSubscriptionID(id) {
+		errMsg := "Subscription IDs are 1 to 64 letters, digits, '_' or '-'"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	var req desiredSubscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBody)).Decode(&req); err != nil {
+		errMsg := "Invalid JSON body"
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	if errMsg := h.validateDesiredSubscription(req); errMsg != "" {
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	sub := &model.Subscription{
+		ID:        id,
+		Owner:     subscriptionOwner(r),
+		Location:  strings.TrimSpace(req.Location),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Status:    desiredStatus(req),
+		CreatedAt: time.Now().UTC(),
+	}
+	if sub.Secret == "" {
+		sub.Secret = webhook.NewSecret()
+	}
+	err := h.Subscriptions.Create(r.Context(), sub)
+	switch {
+	case errors.Is(err, repository.ErrSubscriptionExists) && revision == repository.RevisionNone:
+		writePreconditionFailed(w, "")
+	case errors.Is(err, repository.ErrSubscriptionExists):
+		// Created by a concurrent request since the read
+		errMsg := "Subscription ID is in use"
+		writeResponse(w, http.StatusConflict, model.Response{Error: &errMsg, Message: "Error"})
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to create subscription", "error", err)
+		errMsg := "Failed to create subscription"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+	default:
+		w.Header().Set("Location", "/subscriptions/"+sub.ID)
+		setResourceETag(w, sub.Revision)
+		writeResponse(w, http.StatusCreated, model.Response{Data: sub, Message: "Success"})
+	}
+}
+
+// deleteSubscription serves DELETE /subscriptions/{id}, at revision when given.
+func (h *WebhookHandler) deleteSubscription(w http.ResponseWriter, r *http.Request, id, revision string) {
+	err := h.Subscriptions.Delete(r.Context(), id, revision)
+	switch {
+	case errors.Is(err, repository.ErrRevisionMismatch):
+		writePreconditionFailed(w, "")
+	case errors.Is(err, repository.ErrSubscriptionNotFound):
+		errMsg := "Subscription not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+	case err != nil:
+		logger(r.Context()).Errorw("Failed to delete subscription", "error", err)
+		errMsg := "Failed to delete subscription"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validSubscriptionID reports whether id can name a subscription created with PUT: IDs appear
+// in paths and Redis members, so they are kept to a safe alphabet.
+func validSubscriptionID(id string) bool {
+	if len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return id != ""
+}
+
+// applySubscriptionUpdate applies req to sub and returns an error message for an invalid update, or "".
+func (h *WebhookHandler) applySubscriptionUpdate(sub *model.Subscription, req subscriptionUpdate) string {
+	check := subscriptionRequest{Location: sub.Location, URL: sub.URL}
+	if req.Location != nil {
+		check.Location = *req.Location
+	}
+	if req.URL != nil {
+		check.URL = *req.URL
+	}
+	if errMsg := h.validateSubscription(&check); errMsg != "" {
+		return errMsg
+	}
+	if req.Status != nil {
+		if !validSubscriptionStatus(*req.Status) {
+			return "'status' must be active or paused"
+		}
+		sub.Status = *req.Status
+	}
+	sub.Location = strings.TrimSpace(check.Location)
+	sub.URL = check.URL
+	return ""
+}
+
+func validSubscriptionStatus(status string) bool {
+	return status == model.SubscriptionActive || status == model.SubscriptionPaused
+}
+
+// subscriptionOwner returns the name of the caller's API key. The subscription routes are served
+// behind middleware.KeyMiddleware, so there always is one outside tests.
+func subscriptionOwner(r *http.Request) string {
+	if key, ok := middleware.APIKeyFromContext(r.Context()); ok {
+		return key.Name
+	}
+	return ""
+}
+
+// withoutSecret returns a copy of sub that is safe to return after creation.
+func withoutSecret(sub *model.Subscription) *model.Subscription {
+	c := *sub
+	c.Secret = ""
+	return &c
+}
+
+// validateSubscription returns an error message for an invalid request, or "". URLs naming a
+// non-public host directly are refused up front; hostnames are checked again on every delivery,
+// once resolved.
+func (h *WebhookHandler) validateSubscription(req *subscriptionRequest) string {
+	if strings.TrimSpace(req.Location) == "" {
+		return "'location' is required"
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "'url' must be an absolute http(s) URL"
+	}
+	if !h.AllowPrivateTargets && !publicHost(u.Hostname()) {
+		return "'url' must point to a public host"
+	}
+	return ""
+}
+
+// publicHost reports whether host may be public: a public IP address, or a hostname other than
+// localhost.
+func publicHost(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return webhook.IsPublicAddr(ip)
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+// HandleDeadLetters serves GET /admin/webhooks/deadletters?limit=N, newest first.
+func (h *WebhookHandler) HandleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	limit := defaultDeadLetterLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDeadLetterLimit {
+			errMsg := "'limit' must be between 1 and " + strconv.Itoa(maxDeadLetterLimit)
+			writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+			return
+		}
+		limit = n
+	}
+
+	letters, err := h.DeadLetters.List(r.Context(), limit)
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to read dead letters", "error", err)
+		errMsg := "Failed to read dead letters"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: letters, Message: "Success"})
+}
+
+// HandleDeliveryQueue serves GET /admin/webhooks/queue, the backlog of the delivery queue shared
+// by every replica: deliveries not yet read (lag), read but not finished (pending) and waiting
+// for a retry (scheduled).
+func (h *WebhookHandler) HandleDeliveryQueue(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+	stats, err := h.Queue.Stats(r.Context())
+	if err != nil {
+		logger(r.Context()).Errorw("Failed to read the webhook delivery queue", "error", err)
+		errMsg := "Failed to read the delivery queue"
+		writeResponse(w, http.StatusInternalServerError, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	writeResponse(w, http.StatusOK, model.Response{Data: stats, Message: "Success"})
+}
@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type mockSubscriptionStore struct {
+	subs   map[string]*model.Subscription
+	err    error
+	filter repository.SubscriptionFilter
+	cursor string
+	// writes numbers the revisions of stored subscriptions.
+	writes int
+}
+
+func (m *mockSubscriptionStore) store(sub *model.Subscription) {
+	if m.subs == nil {
+		m.subs = map[string]*model.Subscription{}
+	}
+	m.writes++
+	sub.Revision = "rev-" + strconv.Itoa(m.writes)
+	stored := *sub
+	m.subs[sub.ID] = &stored
+}
+
+func (m *mockSubscriptionStore) Create(_ context.Context, sub *model.Subscription) error {
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := m.subs[sub.ID]; ok {
+		return repository.ErrSubscriptionExists
+	}
+	m.store(sub)
+	return nil
+}
+
+func (m *mockSubscriptionStore) Get(_ context.Context, id string) (*model.Subscription, error) {
+	if sub, ok := m.subs[id]; ok {
+		c := *sub
+		return &c, nil
+	}
+	return nil, repository.ErrSubscriptionNotFound
+}
+
+func (m *mockSubscriptionStore) Update(_ context.Context, sub *model.Subscription) error {
+	if m.err != nil {
+		return m.err
+	}
+	current, ok := m.subs[sub.ID]
+	if !ok {
+		return repository.ErrSubscriptionNotFound
+	}
+	if sub.Revision != "" && sub.Revision != current.Revision {
+		return repository.ErrRevisionMismatch
+	}
+	m.store(sub)
+	return nil
+}
+
+func (m *mockSubscriptionStore) List(_ context.Context, filter repository.SubscriptionFilter, cursor string, limit int) ([]*model.Subscription, string, error) {
+	if m.err != nil {
+		return nil, "", m.err
+	}
+	m.filter, m.cursor = filter, cursor
+	var out []*model.Subscription
+	for _, sub := range m.subs {
+		if sub.Owner == filter.Owner {
+			out = append(out, sub)
+		}
+	}
+	// By ID, as the store's index lists them
+	slices.SortFunc(out, func(a, b *model.Subscription) int { return strings.Compare(a.ID, b.ID) })
+	if len(out) > limit {
+		return out[:limit], out[limit-1].ID, nil
+	}
+	return out, "", nil
+}
+
+func (m *mockSubscriptionStore) ListByLocation(context.Context, string) ([]*model.Subscription, error) {
+	return nil, nil
+}
+
+func (m *mockSubscriptionStore) Delete(_ context.Context, id, revision string) error {
+	if m.err != nil {
+		return m.err
+	}
+	current, ok := m.subs[id]
+	if !ok {
+		return repository.ErrSubscriptionNotFound
+	}
+	if revision != "" && revision != current.Revision {
+		return repository.ErrRevisionMismatch
+	}
+	delete(m.subs, id)
+	return nil
+}
+
+type mockDeadLetterStore struct {
+	letters []model.DeadLetter
+	limit   int
+}
+
+func (m *mockDeadLetterStore) Add(context.Context, model.DeadLetter) error { return nil }
+
+func (m *mockDeadLetterStore) List(_ context.Context, limit int) ([]model.DeadLetter, error) {
+	m.limit = limit
+	return m.letters, nil
+}
+
+func TestHandleSubscriptions_Create(t *testing.T) {
+	store := &mockSubscriptionStore{}
+	h := &WebhookHandler{Subscriptions: store}
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{"location":" London ","url":"https://example.com/hook"}`))
+	w := httptest.NewRecorder()
+	h.HandleSubscriptions(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data model.Subscription `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	sub := resp.Data
+	if !strings.HasPrefix(sub.ID, "sub_") || !strings.HasPrefix(sub.Secret, "whsec_") || sub.Location != "London" || sub.Status != model.SubscriptionActive {
+		t.Errorf("Unexpected subscription %+v", sub)
+	}
+	if w.Header().Get("Location") != "/subscriptions/"+sub.ID || store.subs[sub.ID] == nil {
+		t.Error("Expected the subscription stored and its location returned")
+	}
+}
+
+func TestHandleSubscriptions_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		err    error
+		status int
+	}{
+		{"method", http.MethodDelete, "", nil, http.StatusMethodNotAllowed},
+		{"bad json", http.MethodPost, "{", nil, http.StatusBadRequest},
+		{"missing location", http.MethodPost, `{"url":"https://example.com"}`, nil, http.StatusBadRequest},
+		{"bad url", http.MethodPost, `{"location":"London","url":"ftp://example.com"}`, nil, http.StatusBadRequest},
+		{"loopback url", http.MethodPost, `{"location":"London","url":"http://127.0.0.1:8080/hook"}`, nil, http.StatusBadRequest},
+		{"metadata url", http.MethodPost, `{"location":"London","url":"http://169.254.169.254/latest"}`, nil, http.StatusBadRequest},
+		{"private url", http.MethodPost, `{"location":"London","url":"http://[fd00::1]/hook"}`, nil, http.StatusBadRequest},
+		{"localhost url", http.MethodPost, `{"location":"London","url":"http://localhost/hook"}`, nil, http.StatusBadRequest},
+		{"store error", http.MethodPost, `{"location":"London","url":"https://example.com"}`, errors.New("redis down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &WebhookHandler{Subscriptions: &mockSubscriptionStore{err: tt.err}}
+			w := httptest.NewRecorder()
+			h.HandleSubscriptions(w, httptest.NewRequest(tt.method, "/subscriptions", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleSubscriptions_List(t *testing.T) {
+	store := &mockSubscriptionStore{subs: map[string]*model.Subscription{
+		"sub_1": {ID: "sub_1", Location: "London", Secret: "whsec_1", Status: model.SubscriptionActive},
+		"sub_2": {ID: "sub_2", Owner: "team-b", Location: "Paris", Secret: "whsec_2", Status: model.SubscriptionActive},
+	}}
+	h := &WebhookHandler{Subscriptions: store}
+
+	w := httptest.NewRecorder()
+	h.HandleSubscriptions(w, httptest.NewRequest(http.MethodGet, "/subscriptions?location=London&status=active&cursor=sub_0&limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data subscriptionPage `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data.Subscriptions) != 1 || resp.Data.Subscriptions[0].ID != "sub_1" || resp.Data.Subscriptions[0].Secret != "" {
+		t.Errorf("Expected only the unowned subscription without its secret, got %+v", resp.Data.Subscriptions)
+	}
+	want := repository.SubscriptionFilter{Location: "London", Status: model.SubscriptionActive}
+	if store.filter != want || store.cursor != "sub_0" {
+		t.Errorf("Unexpected filter %+v, cursor %q", store.filter, store.cursor)
+	}
+
+	for _, query := range []string{"?status=deleted", "?limit=0", "?limit=201"} {
+		w = httptest.NewRecorder()
+		h.HandleSubscriptions(w, httptest.NewRequest(http.MethodGet, "/subscriptions"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHandleSubscription(t *testing.T) {
+	newStore := func() *mockSubscriptionStore {
+		return &mockSubscriptionStore{subs: map[string]*model.Subscription{
+			"sub_1": {ID: "sub_1", Location: "London", URL: "https://example.com/hook", Secret: "whsec_1", Status: model.SubscriptionActive, Revision: "rev-a"},
+			"sub_2": {ID: "sub_2", Owner: "team-b", Location: "Paris", URL: "https://example.com/hook", Status: model.SubscriptionActive, Revision: "rev-b"},
+		}}
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"get", http.MethodGet, "/subscriptions/sub_1", "", http.StatusOK, `"id":"sub_1"`},
+		{"other owner", http.MethodGet, "/subscriptions/sub_2", "", http.StatusNotFound, ""},
+		{"unknown", http.MethodGet, "/subscriptions/sub_9", "", http.StatusNotFound, ""},
+		{"unknown action", http.MethodPost, "/subscriptions/sub_1/delete", "", http.StatusNotFound, ""},
+		{"patch", http.MethodPatch, "/subscriptions/sub_1", `{"location":" Paris ","status":"paused"}`, http.StatusOK, `"location":"Paris"`},
+		{"patch bad url", http.MethodPatch, "/subscriptions/sub_1", `{"url":"nope"}`, http.StatusBadRequest, ""},
+		{"patch bad status", http.MethodPatch, "/subscriptions/sub_1", `{"status":"deleted"}`, http.StatusBadRequest, ""},
+		{"pause", http.MethodPost, "/subscriptions/sub_1/pause", "", http.StatusOK, `"status":"paused"`},
+		{"pause method", http.MethodGet, "/subscriptions/sub_1/pause", "", http.StatusMethodNotAllowed, ""},
+		{"put", http.MethodPut, "/subscriptions/sub_1", `{"location":"London","url":"https://example.com/other"}`, http.StatusOK, `"url":"https://example.com/other"`},
+		{"put bad status", http.MethodPut, "/subscriptions/sub_1", `{"location":"London","url":"https://example.com/hook","status":"gone"}`, http.StatusBadRequest, ""},
+		{"put other owner", http.MethodPut, "/subscriptions/sub_2", `{"location":"Paris","url":"https://example.com/hook"}`, http.StatusConflict, ""},
+		{"put bad id", http.MethodPut, "/subscriptions/sub.9", `{"location":"Paris","url":"https://example.com/hook"}`, http.StatusBadRequest, ""},
+		{"delete", http.MethodDelete, "/subscriptions/sub_1", "", http.StatusNoContent, ""},
+		{"delete other owner", http.MethodDelete, "/subscriptions/sub_2", "", http.StatusNotFound, ""},
+		{"method", http.MethodPost, "/subscriptions/sub_1", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &WebhookHandler{Subscriptions: newStore()}
+			w := httptest.NewRecorder()
+			h.HandleSubscription(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Expected %d with %s, got %d: %s", tt.status, tt.want, w.Code, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "whsec_") {
+				t.Error("Expected the secret to be omitted")
+			}
+		})
+	}
+}
+
+func TestHandleDeadLetters(t *testing.T) {
+	store := &mockDeadLetterStore{letters: []model.DeadLetter{{SubscriptionID: "sub_1", Attempts: 5, LastError: "endpoint responded 500"}}}
+	h := &WebhookHandler{DeadLetters: store}
+
+	w := httptest.NewRecorder()
+	h.HandleDeadLetters(w, httptest.NewRequest(http.MethodGet, "/admin/webhooks/deadletters?limit=10", nil))
+	if w.Code != http.StatusOK || store.limit != 10 || !strings.Contains(w.Body.String(), `"last_error":"endpoint responded 500"`) {
+		t.Errorf("Unexpected response %d (limit %d): %s", w.Code, store.limit, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleDeadLetters(w, httptest.NewRequest(http.MethodGet, "/admin/webhooks/deadletters?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for limit=0, got %d", w.Code)
+	}
+}
+
+type mockDeliveryQueue struct {
+	repository.DeliveryQueue
+	stats repository.DeliveryQueueStats
+	err   error
+}
+
+func (m *mockDeliveryQueue) Stats(context.Context) (repository.DeliveryQueueStats, error) {
+	return m.stats, m.err
+}
+
+func TestHandleDeliveryQueue(t *testing.T) {
+	h := &WebhookHandler{Queue: &mockDeliveryQueue{stats: repository.DeliveryQueueStats{Length: 40, Lag: 3, Pending: 2, Scheduled: 1, Consumers: 4}}}
+	w := httptest.NewRecorder()
+	h.HandleDeliveryQueue(w, httptest.NewRequest(http.MethodGet, "/admin/webhooks/queue", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"lag":3,"pending":2,"scheduled":1`) {
+		t.Errorf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	h = &WebhookHandler{Queue: &mockDeliveryQueue{err: errors.New("connection refused")}}
+	w = httptest.NewRecorder()
+	h.HandleDeliveryQueue(w, httptest.NewRequest(http.MethodGet, "/admin/webhooks/queue", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when Redis fails, got %d", w.Code)
+	}
+}
+
+func TestHandleSubscription_PutCreates(t *testing.T) {
+	store := &mockSubscriptionStore{}
+	h := &WebhookHandler{Subscriptions: store}
+	put := func(body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/subscriptions/tf-london", strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.HandleSubscription(w, req)
+		return w
+	}
+	body := `{"location":"London","url":"https://example.com/hook","secret":"whsec_tf"}`
+
+	w := put(body, "If-None-Match", "*")
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/subscriptions/tf-london" || w.Header().Get("ETag") != `"rev-1"` {
+		t.Fatalf("Expected 201 with a Location and an ETag, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	if sub := store.subs["tf-london"]; sub == nil || sub.Secret != "whsec_tf" || sub.Status != model.SubscriptionActive {
+		t.Errorf("Unexpected subscription %+v", sub)
+	}
+
+	// Repeating the request changes nothing, and keeps the ETag
+	if w := put(body); w.Code != http.StatusOK || w.Header().Get("ETag") != `"rev-1"` || store.writes != 1 {
+		t.Errorf("Expected an unchanged 200 without a write, got %d %q after %d writes", w.Code, w.Header().Get("ETag"), store.writes)
+	}
+	if w := put(body, "If-None-Match", "*"); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 creating an existing subscription, got %d", w.Code)
+	}
+}
+
+func TestHandleSubscription_Preconditions(t *testing.T) {
+	store := &mockSubscriptionStore{subs: map[string]*model.Subscription{
+		"sub_1": {ID: "sub_1", Location: "London", URL: "https://example.com/hook", Status: model.SubscriptionActive, Revision: "rev-a"},
+	}}
+	h := &WebhookHandler{Subscriptions: store}
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.HandleSubscription(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/subscriptions/sub_1", ""); w.Header().Get("ETag") != `"rev-a"` {
+		t.Fatalf("Expected the revision as ETag, got %v", w.Header())
+	}
+	if w := do(http.MethodGet, "/subscriptions/sub_1", "", "If-None-Match", `"rev-a"`); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a current ETag, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "/subscriptions/sub_1", `{"status":"paused"}`, "If-Match", `"rev-old"`); w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"rev-a"` {
+		t.Errorf("Expected 412 with the current ETag for a stale If-Match, got %d %v", w.Code, w.Header())
+	}
+	if store.subs["sub_1"].Status != model.SubscriptionActive {
+		t.Error("Expected a failed precondition to change nothing")
+	}
+
+	w := do(http.MethodPatch, "/subscriptions/sub_1", `{"status":"paused"}`, "If-Match", `"rev-a"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"rev-1"` {
+		t.Fatalf("Expected 200 with a new ETag, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	if w := do(http.MethodPost, "/subscriptions/sub_1/resume", "", "If-Match", `"rev-a"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 resuming with the old ETag, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/subscriptions/sub_1", `{"location":"London","url":"https://example.com/hook"}`, "If-None-Match", `"rev-1"`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for If-None-Match other than * on a write, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/subscriptions/sub_1", "", "If-Match", `"rev-a"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 deleting with the old ETag, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/subscriptions/sub_1", "", "If-Match", "*"); w.Code != http.StatusNoContent || len(store.subs) != 0 {
+		t.Errorf("Expected 204 deleting with If-Match: *, got %d", w.Code)
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+// WidgetHandler serves SVG badges of a city's weather, for embedding in READMEs and dashboards.
+type WidgetHandler struct {
+	WeatherService service.WeatherServiceInterface
+	// Config is read once by NewWidgetHandler. When nil, the current config is used.
+	Config *config.WidgetConfig
+}
+
+func NewWidgetHandler(svc ...service.WeatherServiceInterface) *WidgetHandler {
+	cfg := config.GetWidgetConfig()
+	if len(svc) > 0 && svc[0] != nil {
+		return &WidgetHandler{WeatherService: svc[0], Config: &cfg}
+	}
+	return &WidgetHandler{WeatherService: service.NewWeatherService(), Config: &cfg}
+}
+
+func (h *WidgetHandler) widgetConfig() config.WidgetConfig {
+	if h.Config == nil {
+		return config.GetWidgetConfig()
+	}
+	return *h.Config
+}
+
+// HandleWidget serves GET /widget/{city}.svg?theme=dark. The badge is rendered from the weather
+// the service returns, cached data whenever there is some, and may be cached for widget.max_age.
+// A failed lookup is answered with an error badge, so embeds show what went wrong.
+func (h *WidgetHandler) HandleWidget(w http.ResponseWriter, r *http.Request) {
+	if !serveMethods(w, r, writeResponse, http.MethodGet) {
+		return
+	}
+
+	name, _ := strings.CutPrefix(r.URL.Path, "/widget/")
+	city, ok := strings.CutSuffix(name, ".svg")
+	if !ok || city == "" || strings.Contains(city, "/") {
+		errMsg := "Widget not found"
+		writeResponse(w, http.StatusNotFound, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+	cfg := h.widgetConfig()
+	themeName := cfg.Theme
+	if q := r.URL.Query(); q.Has("theme") {
+		themeName = strings.ToLower(q.Get("theme"))
+	}
+	theme, ok := cfg.Themes[themeName]
+	if !ok {
+		names := make([]string, 0, len(cfg.Themes))
+		for name := range cfg.Themes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		errMsg := "Invalid 'theme' query parameter, expected one of: " + strings.Join(names, ", ")
+		writeResponse(w, http.StatusBadRequest, model.Response{Error: &errMsg, Message: "Error"})
+		return
+	}
+
+	weather, err := h.WeatherService.GetWeather(r.Context(), city)
+	w.Header().Set(cacheHeader, cacheState(weather, err))
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	if err != nil {
+		status, message := widgetError(err)
+		if status >= http.StatusInternalServerError {
+			logger(r.Context()).Warnw("Widget weather lookup failed", "city", city, "error", err)
+		}
+		var coolDown *repository.CoolDownError
+		if errors.As(err, &coolDown) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(coolDown.RetryAfter.Seconds()))))
+		}
+		// Errors are not cached, so the widget recovers as soon as the lookup does
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(status)
+		_, _ = w.Write(renderWidget(theme, city, message, "", city+": "+message))
+		return
+	}
+
+	body := renderWidget(theme, weather.Location, widgetTemperature(weather.Temperature), homeAssistantCondition(weather.Description),
+		fmt.Sprintf("%s: %s, %s", weather.Location, widgetTemperature(weather.Temperature), weather.Description))
+	etag := widgetETag(body)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(cfg.MaxAge/time.Second)))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// widgetError is the status and badge text of a failed lookup.
+func widgetError(err error) (int, string) {
+	switch weatherErrorCode(err) {
+	case apperror.CodeInvalidInput:
+		return http.StatusBadRequest, "invalid location"
+	case apperror.CodeNotFound:
+		return http.StatusNotFound, "not found"
+	case apperror.CodeUnavailable:
+		return http.StatusServiceUnavailable, "unavailable"
+	default:
+		return http.StatusBadGateway, "unavailable"
+	}
+}
+
+// widgetTemperature rounds to whole degrees, which is all a badge has room for. Values rounding
+// to zero are "0°C", never "-0°C".
+func widgetTemperature(celsius float64) string {
+	rounded := math.Round(celsius)
+	if rounded == 0 {
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', 0, 64) + "°C"
+}
+
+func widgetETag(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, weakly compared.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Badge layout, in pixels: text is estimated at widgetCharWidth per character, as the font is
+// chosen by the viewer.
+const (
+	widgetHeight    = 28
+	widgetIconSize  = 20
+	widgetPadding   = 8
+	widgetCharWidth = 7
+)
+
+// widgetIcons draws each Home Assistant condition on a 20x20 grid in the currentColor.
+var widgetIcons = map[string]string{
+	"sunny":           `<circle cx="10" cy="10" r="4.5"/><path d="M10 1v3M10 16v3M1 10h3M16 10h3M3.6 3.6l2.1 2.1M14.3 14.3l2.1 2.1M3.6 16.4l2.1-2.1M14.3 5.7l2.1-2.1" stroke="currentColor" stroke-width="1.6" stroke-linecap="round"/>`,
+	"partlycloudy":    `<circle cx="7" cy="7" r="3.5"/>` + widgetCloud,
+	"cloudy":          widgetCloud,
+	"fog":             `<path d="M2 6h16M4 10h12M2 14h16" stroke="currentColor" stroke-width="1.6" stroke-linecap="round"/>`,
+	"rainy":           widgetCloud + `<path d="M7 16l-1 2.5M11 16l-1 2.5" stroke="currentColor" stroke-width="1.4" stroke-linecap="round"/>`,
+	"pouring":         widgetCloud + `<path d="M6 16l-1 2.5M9.5 16l-1 2.5M13 16l-1 2.5" stroke="currentColor" stroke-width="1.6" stroke-linecap="round"/>`,
+	"snowy":           widgetCloud + `<circle cx="7" cy="17.5" r="1"/><circle cx="11" cy="17.5" r="1"/>`,
+	"snowy-rainy":     widgetCloud + `<circle cx="7" cy="17.5" r="1"/><path d="M11 16l-1 2.5" stroke="currentColor" stroke-width="1.4" stroke-linecap="round"/>`,
+	"hail":            widgetCloud + `<circle cx="7" cy="17.5" r="1.3"/><circle cx="11.5" cy="17.5" r="1.3"/>`,
+	"lightning":       widgetCloud + `<path d="M10 14l-2 3.5h3l-1.5 2.5"/>`,
+	"lightning-rainy": widgetCloud + `<path d="M10 14l-2 3.5h3l-1.5 2.5M14 16l-1 2.5" stroke="currentColor" stroke-width="1.2" fill="none"/>`,
+	"windy":           `<path d="M2 7h10a2.5 2.5 0 1 0-2.5-2.5M2 11h14a2.5 2.5 0 1 1-2.5 2.5M2 15h7" stroke="currentColor" stroke-width="1.6" fill="none" stroke-linecap="round"/>`,
+}
+
+const widgetCloud = `<path d="M5.5 15a3.5 3.5 0 0 1-.4-7A4.5 4.5 0 0 1 13.8 6.6 3.7 3.7 0 0 1 15 15z"/>`
+
+// renderWidget draws a badge: the condition icon and label on the theme's background, then the
+// value on its accent. Without a condition there is no icon; title is the accessible text.
+func renderWidget(theme config.WidgetTheme, label, value, condition, title string) []byte {
+	icon, hasIcon := widgetIcons[condition]
+	labelX := widgetPadding
+	if hasIcon {
+		labelX += widgetIconSize + widgetPadding/2
+	}
+	labelWidth := labelX + utf8.RuneCountInString(label)*widgetCharWidth + widgetPadding
+	valueWidth := utf8.RuneCountInString(value)*widgetCharWidth + 2*widgetPadding
+	width := labelWidth + valueWidth
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d" role="img" aria-label="%s">`,
+		width, widgetHeight, escapeXML(title))
+	fmt.Fprintf(&b, `<title>%s</title>`, escapeXML(title))
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" rx="4" fill="%s"/>`, width, widgetHeight, theme.Background)
+	fmt.Fprintf(&b, `<rect x="%d" width="%d" height="%d" rx="4" fill="%s"/>`, labelWidth, valueWidth, widgetHeight, theme.Accent)
+	// Square off the accent's left corners where it meets the label
+	fmt.Fprintf(&b, `<rect x="%d" width="4" height="%d" fill="%s"/>`, labelWidth, widgetHeight, theme.Accent)
+	if hasIcon {
+		fmt.Fprintf(&b, `<g transform="translate(%d %d)" color="%s" fill="%[3]s">%s</g>`,
+			widgetPadding, (widgetHeight-widgetIconSize)/2, theme.Text, icon)
+	}
+	b.WriteString(`<g font-family="Verdana,DejaVu Sans,sans-serif" font-size="12" dominant-baseline="central">`)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`, labelX, widgetHeight/2, theme.Text, escapeXML(label))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" font-weight="bold">%s</text>`, labelWidth+widgetPadding, widgetHeight/2, theme.Background, escapeXML(value))
+	b.WriteString(`</g></svg>`)
+	return []byte(b.String())
+}
+
+// escapeXML escapes s for SVG text and attribute values.
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
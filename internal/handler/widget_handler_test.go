@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+func testWidgetHandler(svc *mockWeatherService) *WidgetHandler {
+	return &WidgetHandler{WeatherService: svc, Config: &config.WidgetConfig{
+		MaxAge: 5 * time.Minute,
+		Theme:  "light",
+		Themes: map[string]config.WidgetTheme{
+			"light": {Background: "#ffffff", Text: "#24292f", Accent: "#0969da"},
+			"dark":  {Background: "#0d1117", Text: "#e6edf3", Accent: "#58a6ff"},
+		},
+	}}
+}
+
+func TestWidgetHandler_HandleWidget(t *testing.T) {
+	h := testWidgetHandler(&mockWeatherService{mockData: &model.WeatherResponse{
+		Location: "Jakarta <ID>", Temperature: 30.6, Description: "light rain", Cached: true,
+	}})
+
+	rr := httptest.NewRecorder()
+	h.HandleWidget(rr, httptest.NewRequest(http.MethodGet, "/widget/Jakarta.svg?theme=Dark", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "image/svg+xml; charset=utf-8" {
+		t.Errorf("Expected an SVG content type, got %q", ct)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Errorf("Expected widget.max_age in Cache-Control, got %q", cc)
+	}
+	if rr.Header().Get(cacheHeader) != "HIT" {
+		t.Errorf("Expected X-Cache HIT, got %q", rr.Header().Get(cacheHeader))
+	}
+	body := rr.Body.String()
+	if err := xml.Unmarshal(rr.Body.Bytes(), new(struct{})); err != nil {
+		t.Errorf("Expected well-formed SVG, got %v: %s", err, body)
+	}
+	for _, want := range []string{"31°C", "Jakarta &lt;ID&gt;", `fill="#0d1117"`, "<title>Jakarta &lt;ID&gt;: 31°C, light rain</title>", widgetIcons["rainy"]} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the widget to contain %q, got %s", want, body)
+		}
+	}
+
+	// The same badge is not sent twice
+	req := httptest.NewRequest(http.MethodGet, "/widget/Jakarta.svg?theme=dark", nil)
+	req.Header.Set("If-None-Match", `"other", `+rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	h.HandleWidget(rr, req)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected 304 for a matching ETag, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestWidgetHandler_HandleWidget_Errors(t *testing.T) {
+	h := testWidgetHandler(&mockWeatherService{mockData: &model.WeatherResponse{Location: "Oslo"}})
+	for _, tt := range []struct {
+		target string
+		status int
+	}{
+		{"/widget/Oslo", http.StatusNotFound},
+		{"/widget/.svg", http.StatusNotFound},
+		{"/widget/a/b.svg", http.StatusNotFound},
+		{"/widget/Oslo.svg?theme=neon", http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		h.HandleWidget(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rr.Code != tt.status || !strings.Contains(rr.Header().Get("Content-Type"), "json") {
+			t.Errorf("%s: expected a %d JSON error, got %d: %s", tt.target, tt.status, rr.Code, rr.Body)
+		}
+	}
+
+	// Failed lookups are error badges that are not cached
+	for _, tt := range []struct {
+		err    error
+		status int
+		text   string
+	}{
+		{repository.ErrLocationNotFound, http.StatusNotFound, "not found"},
+		{&repository.CoolDownError{RetryAfter: 1500 * time.Millisecond}, http.StatusServiceUnavailable, "unavailable"},
+		{errWeatherService, http.StatusBadGateway, "unavailable"},
+	} {
+		h := testWidgetHandler(&mockWeatherService{error: tt.err})
+		rr := httptest.NewRecorder()
+		h.HandleWidget(rr, httptest.NewRequest(http.MethodGet, "/widget/Atlantis.svg", nil))
+		if rr.Code != tt.status || !strings.Contains(rr.Body.String(), tt.text) || !strings.HasPrefix(rr.Body.String(), "<svg") {
+			t.Errorf("%v: expected a %d badge reading %q, got %d: %s", tt.err, tt.status, tt.text, rr.Code, rr.Body)
+		}
+		if rr.Header().Get("Cache-Control") != "no-cache" || rr.Header().Get("ETag") != "" {
+			t.Errorf("%v: expected an uncached error badge, got %v", tt.err, rr.Header())
+		}
+	}
+}
+
+func TestWidgetTemperature(t *testing.T) {
+	for celsius, want := range map[float64]string{30.6: "31°C", -0.4: "0°C", -12.5: "-13°C", 0: "0°C"} {
+		if got := widgetTemperature(celsius); got != want {
+			t.Errorf("Expected %v to render as %q, got %q", celsius, want, got)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Package jobs runs the service's periodic background jobs and records every run, so operators
+// can see when each job last ran, how it went and when it runs next, and trigger one on demand.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var (
+	// ErrSkipped is returned by a job that had nothing to do on this replica, e.g. because
+	// another replica is the leader. The run is recorded as skipped rather than failed.
+	ErrSkipped = errors.New("job skipped")
+	// ErrNotFound is returned by Trigger for unknown job names.
+	ErrNotFound = errors.New("job not found")
+	// ErrRunning is returned when a job is started while a run is already in progress.
+	ErrRunning = errors.New("job already running")
+)
+
+// Run outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+var runs = metrics.NewCounterVec("weather_job_runs_total", "Background job runs by job and outcome.", "job", "outcome")
+
+// Status is a job's schedule and the result of its last run.
+type Status struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	Running  bool   `json:"running"`
+	// Runs counts completed runs since the process started.
+	Runs                uint64     `json:"runs"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	LastDurationSeconds float64    `json:"last_duration_seconds,omitempty"`
+	LastOutcome         string     `json:"last_outcome,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	// NextRun is when the job is next scheduled; empty while it is not scheduled.
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+// Leader reports whether this replica should run cluster-wide jobs; see leader.Elector.
+type Leader interface {
+	IsLeader() bool
+}
+
+// Job is a named task run every Interval once scheduled.
+type Job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+	clock    clock.Clock
+	// leader, when set, limits runs to the replica it reports as the leader.
+	leader Leader
+
+	mu      sync.Mutex
+	running bool
+	status  Status
+	// ctx is the scheduling context, also used by triggered runs so stopping the job stops them.
+	ctx context.Context
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Job{}
+)
+
+// New creates a job and registers it for List and Trigger, replacing any job with the same name.
+func New(name string, interval time.Duration, run func(ctx context.Context) error) *Job {
+	j := &Job{name: name, interval: interval, run: run, clock: clock.Real, ctx: context.Background()}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = j
+	return j
+}
+
+// LeaderOnly makes the job cluster-wide: it only runs while l reports leadership, and its runs on
+// other replicas, scheduled or triggered, are recorded as skipped. A nil l leaves the job running
+// on every replica. It must be called before Schedule.
+func (j *Job) LeaderOnly(l Leader) *Job {
+	j.leader = l
+	return j
+}
+
+// WithClock makes the job schedule its runs and time them with c instead of the wall clock, so
+// tests can advance time rather than wait. It must be called before Schedule.
+func (j *Job) WithClock(c clock.Clock) *Job {
+	j.clock = c
+	return j
+}
+
+// Run runs the job once and records the outcome. It returns ErrRunning without running the job if
+// a run is already in progress.
+func (j *Job) Run(ctx context.Context) error {
+	if !j.begin() {
+		return ErrRunning
+	}
+	return j.execute(ctx)
+}
+
+func (j *Job) begin() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running {
+		return false
+	}
+	j.running = true
+	return true
+}
+
+func (j *Job) execute(ctx context.Context) error {
+	start := j.clock.Now()
+	err := ErrSkipped
+	if j.leader == nil || j.leader.IsLeader() {
+		err = j.run(ctx)
+	}
+	elapsed := j.clock.Since(start)
+
+	outcome := OutcomeSuccess
+	switch {
+	case errors.Is(err, ErrSkipped):
+		outcome = OutcomeSkipped
+	case err != nil:
+		outcome = OutcomeFailure
+		if ctx.Err() == nil {
+			logger().Warnw("Background job failed", "job", j.name, "duration", elapsed, "error", err)
+		}
+	}
+	runs.WithLabelValues(j.name, outcome).Inc()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running = false
+	j.status.Runs++
+	j.status.LastRun = &start
+	j.status.LastDurationSeconds = elapsed.Seconds()
+	j.status.LastOutcome = outcome
+	j.status.LastError = ""
+	if outcome == OutcomeFailure {
+		j.status.LastError = err.Error()
+	}
+	return err
+}
+
+// Schedule runs the job every interval until the returned function is called, immediately first
+// when runAtStart is set. stop cancels a run in progress, scheduled or triggered, and waits for
+// the scheduler to return.
+func (j *Job) Schedule(runAtStart bool) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	j.mu.Lock()
+	j.ctx = ctx
+	j.mu.Unlock()
+
+	// The ticker is started before Schedule returns, so a test advancing a fake clock right
+	// afterwards cannot miss a tick
+	ticker := j.clock.NewTicker(j.interval)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer j.setNextRun(time.Time{})
+		defer ticker.Stop()
+		if runAtStart {
+			j.setNextRun(j.clock.Now())
+			_ = j.Run(ctx)
+		}
+		for {
+			j.setNextRun(j.clock.Now().Add(j.interval))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if err := j.Run(ctx); errors.Is(err, ErrRunning) {
+					logger().Debugw("Skipping scheduled run, job still running", "job", j.name)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (j *Job) setNextRun(t time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if t.IsZero() {
+		j.status.NextRun = nil
+		return
+	}
+	j.status.NextRun = &t
+}
+
+// Status returns the job's schedule and last run.
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.status
+	s.Name = j.name
+	s.Interval = j.interval.String()
+	s.Running = j.running
+	return s
+}
+
+// List returns the status of every registered job, ordered by name.
+func List() []Status {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	statuses := make([]Status, 0, len(registry))
+	for _, j := range registry {
+		statuses = append(statuses, j.Status())
+	}
+	sort.Slice(statuses, func(a, b int) bool { return statuses[a].Name < statuses[b].Name })
+	return statuses
+}
+
+// Get returns the status of the named job.
+func Get(name string) (Status, bool) {
+	registryMu.RLock()
+	j, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return Status{}, false
+	}
+	return j.Status(), true
+}
+
+// Trigger starts a run of the named job in the background without waiting for it. It returns
+// ErrNotFound for unknown jobs and ErrRunning if the job is already running.
+func Trigger(name string) error {
+	registryMu.RLock()
+	j, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	if !j.begin() {
+		return ErrRunning
+	}
+	j.mu.Lock()
+	ctx := j.ctx
+	j.mu.Unlock()
+	logger().Infow("Background job triggered", "job", name)
+	go func() { _ = j.execute(ctx) }()
+	return nil
+}
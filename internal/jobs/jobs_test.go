@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
+)
+
+func TestJob_RunRecordsOutcome(t *testing.T) {
+	var fail error
+	j := New("test_outcome", time.Hour, func(context.Context) error { return fail })
+	ctx := context.Background()
+	// runs is global, so the failure is counted from its value before the runs
+	failures := runs.WithLabelValues("test_outcome", OutcomeFailure).Value()
+
+	if err := j.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s := j.Status()
+	if s.Runs != 1 || s.LastOutcome != OutcomeSuccess || s.LastRun == nil || s.NextRun != nil {
+		t.Errorf("Unexpected status after success %+v", s)
+	}
+
+	fail = errors.New("redis down")
+	_ = j.Run(ctx)
+	if s := j.Status(); s.LastOutcome != OutcomeFailure || s.LastError != "redis down" {
+		t.Errorf("Unexpected status after failure %+v", s)
+	}
+
+	fail = ErrSkipped
+	_ = j.Run(ctx)
+	if s := j.Status(); s.LastOutcome != OutcomeSkipped || s.LastError != "" || s.Runs != 3 {
+		t.Errorf("Unexpected status after skip %+v", s)
+	}
+	if runs.WithLabelValues("test_outcome", OutcomeFailure).Value() != failures+1 {
+		t.Error("Expected the failure counted")
+	}
+}
+
+type fakeLeader struct{ leader bool }
+
+func (l *fakeLeader) IsLeader() bool { return l.leader }
+
+func TestJob_LeaderOnly(t *testing.T) {
+	var count int
+	l := &fakeLeader{}
+	j := New("test_leader_only", time.Hour, func(context.Context) error {
+		count++
+		return nil
+	}).LeaderOnly(l)
+
+	_ = j.Run(context.Background())
+	if s := j.Status(); count != 0 || s.LastOutcome != OutcomeSkipped {
+		t.Errorf("Expected a follower run skipped, ran %d times with status %+v", count, s)
+	}
+	l.leader = true
+	_ = j.Run(context.Background())
+	if s := j.Status(); count != 1 || s.LastOutcome != OutcomeSuccess {
+		t.Errorf("Expected the leader to run the job, ran %d times with status %+v", count, s)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	New("test_trigger", time.Hour, func(context.Context) error {
+		<-release
+		close(finished)
+		return nil
+	})
+
+	if err := Trigger("test_trigger"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if s, _ := Get("test_trigger"); !s.Running {
+		t.Error("Expected the job running")
+	}
+	if err := Trigger("test_trigger"); !errors.Is(err, ErrRunning) {
+		t.Errorf("Expected ErrRunning, got %v", err)
+	}
+	if err := Trigger("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	close(release)
+	<-finished
+}
+
+func TestJob_Schedule(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	ran := make(chan time.Time)
+	j := New("test_schedule", time.Minute, func(ctx context.Context) error {
+		ran <- c.Now()
+		return nil
+	}).WithClock(c)
+	stop := j.Schedule(true)
+
+	if at := <-ran; !at.Equal(start) {
+		t.Errorf("Expected a run at start, got %v", at)
+	}
+	for i := 1; i <= 2; i++ {
+		c.Advance(time.Minute)
+		if at := <-ran; !at.Equal(start.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("Expected run %d after %d minutes, got %v", i+1, i, at)
+		}
+	}
+	stop()
+
+	s := j.Status()
+	if s.Runs != 3 || s.LastRun == nil || !s.LastRun.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected 3 runs, the last at 00:02, got %+v", s)
+	}
+	if s.NextRun != nil {
+		t.Errorf("Expected no next run once stopped, got %v", s.NextRun)
+	}
+}
+
+func TestJob_ScheduleReportsNextRun(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	j := New("test_schedule_next", time.Minute, func(context.Context) error { return nil }).WithClock(c)
+	stop := j.Schedule(false)
+	defer stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for j.Status().NextRun == nil && time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+	if next := j.Status().NextRun; next == nil || !next.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the next run at 00:01, got %v", next)
+	}
+}
+
+func TestList(t *testing.T) {
+	New("test_list_b", time.Minute, func(context.Context) error { return nil })
+	New("test_list_a", time.Minute, func(context.Context) error { return nil })
+	var names []string
+	for _, s := range List() {
+		names = append(names, s.Name)
+	}
+	a, b := -1, -1
+	for i, n := range names {
+		switch n {
+		case "test_list_a":
+			a = i
+		case "test_list_b":
+			b = i
+		}
+	}
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("Expected both jobs listed by name, got %v", names)
+	}
+}
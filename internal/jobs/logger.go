@@ -0,0 +1,11 @@
+package jobs
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the jobs module logger, whose level is set by logging.levels.jobs.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("jobs")
+}
@@ -0,0 +1,148 @@
+// Package leader elects one replica to run cluster-wide background jobs, such as the cache
+// warmer. The leader holds a Redis key set with SET NX and a TTL, and keeps it alive with a
+// heartbeat; if the leader dies, the key expires and another replica takes over.
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+var isLeader = metrics.NewGaugeVec("weather_leader",
+	"1 while this replica holds the named leader lease, 0 otherwise.", "name")
+
+// renewScript extends the lease only while it still holds this replica's ID, so a replica whose
+// lease expired cannot extend a successor's.
+const renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+
+// releaseScript deletes the lease only while it still holds this replica's ID.
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+
+// Client is the subset of Redis operations needed for leader election.
+type Client interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.BoolCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redisv9.Cmd
+}
+
+// Elector campaigns for one named lease.
+type Elector struct {
+	client  Client
+	name    string
+	id      string
+	ttl     time.Duration
+	enabled bool
+	leader  atomic.Bool
+}
+
+// NewElector creates an Elector for the lease name, configured by leader.* and backed by the
+// shared Redis client. When leader.enabled is false it always reports leadership.
+func NewElector(name string, client ...Client) *Elector {
+	var c Client = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	cfg := config.GetLeaderConfig()
+	return &Elector{client: c, name: name, id: replicaID(), ttl: cfg.TTL, enabled: cfg.Enabled}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	return !e.enabled || e.leader.Load()
+}
+
+// Start campaigns for the lease until the returned function is called, trying to acquire it or
+// renewing it every TTL/3. The first attempt is made before Start returns, so jobs started right
+// after it see the outcome. stop releases the lease so another replica can take over at once.
+func (e *Elector) Start() (stop func()) {
+	if !e.enabled {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.campaign(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+		e.release(context.Background())
+	}
+}
+
+// campaign renews the lease when it is held and tries to acquire it otherwise. A failed renewal,
+// including a Redis error, gives up leadership: another replica may already have taken over.
+func (e *Elector) campaign(ctx context.Context) {
+	key := e.key()
+	if e.leader.Load() {
+		renewed, err := e.client.Eval(ctx, renewScript, []string{key}, e.id, e.ttl.Milliseconds()).Int()
+		if err == nil && renewed == 1 {
+			return
+		}
+		logger().Warnw("Lost leadership", "name", e.name, "error", err)
+		e.setLeader(false)
+		return
+	}
+	acquired, err := e.client.SetNX(ctx, key, e.id, e.ttl).Result()
+	if err != nil {
+		logger().Warnw("Leader election failed", "name", e.name, "error", err)
+		return
+	}
+	if acquired {
+		logger().Infow("Acquired leadership", "name", e.name, "id", e.id)
+		e.setLeader(true)
+	}
+}
+
+func (e *Elector) release(ctx context.Context) {
+	if !e.leader.Load() {
+		return
+	}
+	if err := e.client.Eval(ctx, releaseScript, []string{e.key()}, e.id).Err(); err != nil {
+		logger().Warnw("Failed to release leadership", "name", e.name, "error", err)
+	}
+	e.setLeader(false)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.leader.Store(leader)
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	isLeader.WithLabelValues(e.name).Set(value)
+}
+
+func (e *Elector) key() string {
+	return "leader:" + e.name
+}
+
+// replicaID identifies this process in the lease: the hostname plus a random suffix, so restarts
+// and replicas sharing a hostname never mistake each other's lease for their own.
+func replicaID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
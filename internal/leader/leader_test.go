@@ -0,0 +1,95 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func newElectors(t *testing.T, n int) ([]*Elector, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	electors := make([]*Elector, n)
+	for i := range electors {
+		electors[i] = &Elector{client: client, name: "jobs", id: replicaID(), ttl: 3 * time.Second, enabled: true}
+	}
+	return electors, mr
+}
+
+func TestElector_SingleLeader(t *testing.T) {
+	electors, mr := newElectors(t, 2)
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("Expected only the first replica to lead, got %v and %v", a.IsLeader(), b.IsLeader())
+	}
+	if got, _ := mr.Get("leader:jobs"); got != a.id {
+		t.Errorf("Expected the lease to hold the leader's ID, got %q", got)
+	}
+	if isLeader.WithLabelValues("jobs").Value() != 1 {
+		t.Error("Expected the leader gauge set")
+	}
+
+	// Heartbeats keep the lease alive past its TTL
+	for i := 0; i < 3; i++ {
+		mr.FastForward(time.Second)
+		a.campaign(ctx)
+		b.campaign(ctx)
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Error("Expected the heartbeat to keep the lease")
+	}
+}
+
+func TestElector_Failover(t *testing.T) {
+	electors, mr := newElectors(t, 2)
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+	a.campaign(ctx)
+
+	// The leader stops heartbeating and its lease expires
+	mr.FastForward(4 * time.Second)
+	b.campaign(ctx)
+	if !b.IsLeader() {
+		t.Fatal("Expected the second replica to take over")
+	}
+	a.campaign(ctx)
+	if a.IsLeader() {
+		t.Error("Expected the old leader to step down instead of renewing the new lease")
+	}
+	if got, _ := mr.Get("leader:jobs"); got != b.id {
+		t.Errorf("Expected the new leader's lease kept, got %q", got)
+	}
+}
+
+func TestElector_Release(t *testing.T) {
+	electors, mr := newElectors(t, 2)
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+	a.campaign(ctx)
+	b.release(ctx) // not the leader: must not delete the lease
+	if !mr.Exists("leader:jobs") {
+		t.Fatal("Expected a non-leader release to leave the lease")
+	}
+	a.release(ctx)
+	b.campaign(ctx)
+	if a.IsLeader() || !b.IsLeader() {
+		t.Error("Expected the lease handed over after release")
+	}
+}
+
+func TestElector_Disabled(t *testing.T) {
+	e := &Elector{name: "jobs"}
+	if !e.IsLeader() {
+		t.Error("Expected every replica to lead when election is disabled")
+	}
+	e.Start()()
+}
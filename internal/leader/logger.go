@@ -0,0 +1,11 @@
+package leader
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the leader module logger, whose level is set by logging.levels.leader.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("leader")
+}
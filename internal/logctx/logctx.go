@@ -0,0 +1,113 @@
+// Package logctx carries request-scoped logging fields (request ID, client IP, API key, location
+// and experiment) through a context.Context so that loggers deep in the call chain can be
+// annotated with them.
+package logctx
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// Fields are the request attributes attached to every context-aware log line.
+type Fields struct {
+	RequestID string
+	ClientIP  string
+	APIKey    string // name of the authenticated key, never the secret itself
+	Location  string
+	// Experiment is the experiment the request takes part in, with its variant, e.g. "ttl/adaptive_ttl".
+	Experiment string
+	// Debug is set for requests whose debug tracing was forced: they log at debug level in every
+	// module.
+	Debug bool
+}
+
+type fieldsKey struct{}
+
+// FieldsFrom returns the fields carried by ctx.
+func FieldsFrom(ctx context.Context) Fields {
+	if ctx == nil {
+		return Fields{}
+	}
+	f, _ := ctx.Value(fieldsKey{}).(Fields)
+	return f
+}
+
+func with(ctx context.Context, set func(*Fields)) context.Context {
+	f := FieldsFrom(ctx)
+	set(&f)
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return with(ctx, func(f *Fields) { f.RequestID = id })
+}
+
+// WithClientIP returns a copy of ctx carrying the client IP.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return with(ctx, func(f *Fields) { f.ClientIP = ip })
+}
+
+// WithAPIKey returns a copy of ctx carrying the name of the authenticated API key.
+func WithAPIKey(ctx context.Context, name string) context.Context {
+	return with(ctx, func(f *Fields) { f.APIKey = name })
+}
+
+// WithLocation returns a copy of ctx carrying the requested location.
+func WithLocation(ctx context.Context, location string) context.Context {
+	return with(ctx, func(f *Fields) { f.Location = location })
+}
+
+// WithExperiment returns a copy of ctx carrying the request's experiment and variant.
+func WithExperiment(ctx context.Context, experiment string) context.Context {
+	return with(ctx, func(f *Fields) { f.Experiment = experiment })
+}
+
+// WithDebug returns a copy of ctx that forces debug logging.
+func WithDebug(ctx context.Context) context.Context {
+	return with(ctx, func(f *Fields) { f.Debug = true })
+}
+
+// Module returns the logger of module annotated with the fields carried by ctx. Its level is
+// logging.levels.<module>, or debug when ctx forces debug logging.
+func Module(ctx context.Context, module string) *zap.SugaredLogger {
+	if FieldsFrom(ctx).Debug {
+		return Annotate(config.GetModuleDebugLogger(module), ctx)
+	}
+	return Annotate(config.GetModuleLogger(module), ctx)
+}
+
+// From returns the application logger annotated with the fields carried by ctx.
+func From(ctx context.Context) *zap.SugaredLogger {
+	return Annotate(config.GetLogger(), ctx)
+}
+
+// Annotate returns l with the fields carried by ctx added. Empty fields are omitted.
+func Annotate(l *zap.SugaredLogger, ctx context.Context) *zap.SugaredLogger {
+	f := FieldsFrom(ctx)
+	var kv []interface{}
+	if f.RequestID != "" {
+		kv = append(kv, "request_id", f.RequestID)
+	}
+	if f.ClientIP != "" {
+		kv = append(kv, "client_ip", f.ClientIP)
+	}
+	if f.APIKey != "" {
+		kv = append(kv, "api_key", f.APIKey)
+	}
+	if f.Location != "" {
+		kv = append(kv, "location", f.Location)
+	}
+	if f.Experiment != "" {
+		kv = append(kv, "experiment", f.Experiment)
+	}
+	if f.Debug {
+		kv = append(kv, "debug_trace", true)
+	}
+	if len(kv) == 0 {
+		return l
+	}
+	return l.With(kv...)
+}
@@ -0,0 +1,68 @@
+package logctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFieldsAccumulate(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithClientIP(ctx, "10.0.0.1")
+	child := WithLocation(ctx, "London")
+
+	if got := FieldsFrom(child); got != (Fields{RequestID: "req-1", ClientIP: "10.0.0.1", Location: "London"}) {
+		t.Errorf("Unexpected fields %+v", got)
+	}
+	if FieldsFrom(ctx).Location != "" {
+		t.Error("Expected the parent context to be unchanged")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	base := zap.New(core).Sugar()
+
+	ctx := WithAPIKey(WithRequestID(context.Background(), "req-1"), "team-a")
+	ctx = WithExperiment(ctx, "ttl/adaptive_ttl")
+	Annotate(base, ctx).Infow("hello")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["api_key"] != "team-a" || fields["experiment"] != "ttl/adaptive_ttl" {
+		t.Errorf("Expected request fields on the entry, got %v", fields)
+	}
+	if _, ok := fields["location"]; ok {
+		t.Error("Expected empty fields to be omitted")
+	}
+
+	if Annotate(base, context.Background()) != base {
+		t.Error("Expected the logger to be returned unchanged without fields")
+	}
+}
+
+func TestModule_Debug(t *testing.T) {
+	ctx := WithDebug(WithRequestID(context.Background(), "req-1"))
+	if !FieldsFrom(ctx).Debug {
+		t.Fatal("Expected debug to be recorded")
+	}
+	core, logs := observer.New(zap.DebugLevel)
+	Annotate(zap.New(core).Sugar(), ctx).Debugw("hello")
+	if fields := logs.All()[0].ContextMap(); fields["debug_trace"] != true {
+		t.Errorf("Expected debug_trace on the entry, got %v", fields)
+	}
+	viper.Set("logging.levels.logctx_test", "error")
+	defer viper.Set("logging.levels.logctx_test", nil)
+	if Module(context.Background(), "logctx_test").Desugar().Core().Enabled(zap.DebugLevel) {
+		t.Error("Expected the module's configured level without forced debug logging")
+	}
+	if !Module(ctx, "logctx_test").Desugar().Core().Enabled(zap.DebugLevel) {
+		t.Error("Expected forced debug logging to enable debug level")
+	}
+}
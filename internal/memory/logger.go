@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the memory module logger, whose level is set by logging.levels.memory.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("memory")
+}
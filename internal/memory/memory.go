@@ -0,0 +1,95 @@
+// Package memory exports process memory and goroutine gauges and guards a soft memory limit: when
+// the heap grows past it, in-process caches registered with OnPressure are trimmed before a small
+// container is OOM-killed.
+package memory
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var (
+	heapBytes = metrics.NewGauge("weather_process_heap_bytes",
+		"Bytes of allocated heap objects, as of the last memory sample.")
+	sysBytes = metrics.NewGauge("weather_process_memory_sys_bytes",
+		"Bytes of memory obtained from the OS by the Go runtime, as of the last memory sample.")
+	goroutines = metrics.NewGauge("weather_process_goroutines",
+		"Number of goroutines, as of the last memory sample.")
+	softLimitBytes = metrics.NewGauge("weather_memory_soft_limit_bytes",
+		"The configured memory.soft_limit; 0 when disabled.")
+	pressureEvents = metrics.NewCounter("weather_memory_pressure_total",
+		"Times the heap exceeded memory.soft_limit and caches were trimmed.")
+)
+
+type releaser struct {
+	name    string
+	release func()
+}
+
+var (
+	releasersMu sync.Mutex
+	releasers   []releaser
+)
+
+// OnPressure registers release to free memory whenever the heap exceeds the soft limit.
+func OnPressure(name string, release func()) {
+	releasersMu.Lock()
+	defer releasersMu.Unlock()
+	releasers = append(releasers, releaser{name, release})
+}
+
+// guard samples memory and trims caches when the heap exceeds limit.
+type guard struct {
+	limit uint64
+	// read reads memory statistics; replaced in tests.
+	read func(*runtime.MemStats)
+}
+
+// Start schedules the "memory_guard" job, which samples memory every memory.interval until the
+// returned function is called. With memory.soft_limit set, the limit is also handed to the Go
+// runtime so the garbage collector works harder as the heap approaches it.
+func Start() (stop func()) {
+	cfg := config.GetMemoryConfig()
+	softLimitBytes.Set(float64(cfg.SoftLimit))
+	if cfg.SoftLimit > 0 {
+		debug.SetMemoryLimit(int64(cfg.SoftLimit))
+	}
+	g := &guard{limit: cfg.SoftLimit, read: runtime.ReadMemStats}
+	return jobs.New("memory_guard", cfg.Interval, g.check).Schedule(true)
+}
+
+// check records the memory gauges and, when the heap is over the limit, runs every registered
+// releaser, returns freed memory to the OS and logs a warning.
+func (g *guard) check(context.Context) error {
+	var m runtime.MemStats
+	g.read(&m)
+	heapBytes.Set(float64(m.HeapAlloc))
+	sysBytes.Set(float64(m.Sys))
+	goroutines.Set(float64(runtime.NumGoroutine()))
+	if g.limit == 0 || m.HeapAlloc < g.limit {
+		return nil
+	}
+
+	pressureEvents.Inc()
+	logger().Warnw("Heap above the soft memory limit, trimming caches", "heapBytes", m.HeapAlloc, "softLimitBytes", g.limit)
+	releasersMu.Lock()
+	current := append([]releaser(nil), releasers...)
+	releasersMu.Unlock()
+	for _, r := range current {
+		r.release()
+		logger().Debugw("Released cache", "name", r.name)
+	}
+	debug.FreeOSMemory()
+
+	g.read(&m)
+	heapBytes.Set(float64(m.HeapAlloc))
+	sysBytes.Set(float64(m.Sys))
+	logger().Infow("Caches trimmed", "heapBytes", m.HeapAlloc)
+	return nil
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+func TestGuard_Check(t *testing.T) {
+	var released []string
+	OnPressure("test", func() { released = append(released, "test") })
+	heap := uint64(100)
+	g := &guard{limit: 1000, read: func(m *runtime.MemStats) {
+		m.HeapAlloc = heap
+		m.Sys = 2 * heap
+	}}
+
+	_ = g.check(context.Background())
+	if len(released) != 0 {
+		t.Errorf("Expected nothing released below the limit, got %v", released)
+	}
+	if heapBytes.Value() != 100 || sysBytes.Value() != 200 || goroutines.Value() < 1 {
+		t.Errorf("Expected the gauges recorded, got heap %v sys %v goroutines %v", heapBytes.Value(), sysBytes.Value(), goroutines.Value())
+	}
+
+	heap = 1500
+	before := pressureEvents.Value()
+	_ = g.check(context.Background())
+	if len(released) != 1 || pressureEvents.Value() != before+1 {
+		t.Errorf("Expected caches released over the limit, got %v", released)
+	}
+
+	g.limit = 0
+	_ = g.check(context.Background())
+	if len(released) != 1 {
+		t.Error("Expected no trimming without a soft limit")
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds suited to an HTTP API.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	mu         sync.Mutex
+	counts     []uint64 // per bucket, non-cumulative; the last entry is +Inf
+	sum        float64
+	count      uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Histogram{name: name, help: help, buckets: b, counts: make([]uint64, len(b)+1)}
+}
+
+// NewHistogram creates and registers a histogram in the Default registry. Buckets are upper
+// bounds; DefBuckets is used when none are given.
+func NewHistogram(name, help string, buckets ...float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	h := newHistogram(name, help, buckets)
+	Default.Register(h)
+	return h
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// Snapshot returns the number and sum of observations so far.
+func (h *Histogram) Snapshot() (count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count, h.sum
+}
+
+// Name returns the metric family name.
+func (h *Histogram) Name() string { return h.name }
+
+func (h *Histogram) write(b *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(upper), cumulative)
+	}
+	cumulative += h.counts[len(h.buckets)]
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", h.name, cumulative)
+	fmt.Fprintf(b, "%s_sum %s\n%s_count %d\n", h.name, formatValue(h.sum), h.name, h.count)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	reg := NewRegistry()
+	h := newHistogram("test_duration_seconds", "Durations.", []float64{1, 0.1})
+	reg.Register(h)
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v)
+	}
+
+	var b bytes.Buffer
+	reg.WriteText(&b)
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 3.65
+test_duration_seconds_count 4
+`
+	if b.String() != want {
+		t.Errorf("Unexpected exposition\n got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,256 @@
+// Package metrics is a small, dependency-free metrics registry that renders the Prometheus text
+// exposition format. It supports counters and gauges, optionally partitioned by labels, histograms
+// and SLO burn-rate tracking.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Collector is a metric family that can render itself in the text exposition format.
+type Collector interface {
+	// Name returns the metric family name.
+	Name() string
+	// write appends the HELP/TYPE lines and samples of the family to b.
+	write(b *bytes.Buffer)
+}
+
+// Registry holds metric families and serves them over HTTP.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Default is the registry used by the package-level constructors and Handler.
+var Default = NewRegistry()
+
+// Register adds c to the registry. Registering two families with the same name panics, as it
+// would produce an invalid exposition.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.collectors[c.Name()]; dup {
+		panic("metrics: duplicate metric " + c.Name())
+	}
+	r.collectors[c.Name()] = c
+}
+
+// WriteText renders every registered family, sorted by name.
+func (r *Registry) WriteText(b *bytes.Buffer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(b)
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var b bytes.Buffer
+		r.WriteText(&b)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(b.Bytes())
+	})
+}
+
+// Handler serves the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// value is a float64 updated atomically.
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64)     { v.bits.Store(math.Float64bits(f)) }
+func (v *value) get() float64      { return math.Float64frombits(v.bits.Load()) }
+func formatValue(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+
+// Counter is a monotonically increasing value.
+type Counter struct{ v value }
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { c.v.add(1) }
+
+// Add adds delta, which must not be negative, to the counter.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() float64 { return c.v.get() }
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ v value }
+
+// Set replaces the gauge value.
+func (g *Gauge) Set(f float64) { g.v.set(f) }
+
+// Add adds delta (possibly negative) to the gauge.
+func (g *Gauge) Add(delta float64) { g.v.add(delta) }
+
+// Value returns the current gauge value.
+func (g *Gauge) Value() float64 { return g.v.get() }
+
+// family is a metric family with a fixed label set. Families without labels hold one series.
+type family[T any] struct {
+	name, help, kind string
+	labels           []string
+	mu               sync.Mutex
+	series           map[string]*T
+	sample           func(*T) float64
+}
+
+func newFamily[T any](name, help, kind string, labels []string, sample func(*T) float64) *family[T] {
+	return &family[T]{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*T), sample: sample}
+}
+
+func (f *family[T]) Name() string { return f.name }
+
+func (f *family[T]) with(values ...string) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = new(T)
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family[T]) delete(values ...string) {
+	f.mu.Lock()
+	delete(f.series, strings.Join(values, "\xff"))
+	f.mu.Unlock()
+}
+
+func (f *family[T]) write(b *bytes.Buffer) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	for _, k := range keys {
+		b.WriteString(f.name)
+		writeLabels(b, f.labels, k)
+		b.WriteByte(' ')
+		b.WriteString(formatValue(f.sample(f.series[k])))
+		b.WriteByte('\n')
+	}
+	f.mu.Unlock()
+}
+
+// writeLabels renders {name="value",...} for the joined label values in key.
+func writeLabels(b *bytes.Buffer, names []string, key string) {
+	if len(names) == 0 {
+		return
+	}
+	values := strings.Split(key, "\xff")
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// CounterVec is a family of counters partitioned by label values.
+type CounterVec struct{ f *family[Counter] }
+
+// WithLabelValues returns the counter for the given label values, creating it if needed.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter { return v.f.with(values...) }
+
+// Name returns the metric family name.
+func (v *CounterVec) Name() string { return v.f.name }
+
+func (v *CounterVec) write(b *bytes.Buffer) { v.f.write(b) }
+
+// GaugeVec is a family of gauges partitioned by label values.
+type GaugeVec struct{ f *family[Gauge] }
+
+// WithLabelValues returns the gauge for the given label values, creating it if needed.
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge { return v.f.with(values...) }
+
+// Delete removes the series for the given label values.
+func (v *GaugeVec) Delete(values ...string) { v.f.delete(values...) }
+
+// Name returns the metric family name.
+func (v *GaugeVec) Name() string { return v.f.name }
+
+func (v *GaugeVec) write(b *bytes.Buffer) { v.f.write(b) }
+
+// NewCounter creates and registers an unlabelled counter in the Default registry.
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).WithLabelValues()
+}
+
+// NewCounterVec creates and registers a labelled counter family in the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{f: newFamily(name, help, "counter", labels, (*Counter).Value)}
+	Default.Register(v)
+	return v
+}
+
+// NewGauge creates and registers an unlabelled gauge in the Default registry.
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).WithLabelValues()
+}
+
+// NewGaugeVec creates and registers a labelled gauge family in the Default registry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{f: newFamily(name, help, "gauge", labels, (*Gauge).Value)}
+	Default.Register(v)
+	return v
+}
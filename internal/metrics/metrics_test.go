@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	reg := NewRegistry()
+	c := &CounterVec{f: newFamily("test_requests_total", "Requests.", "counter", []string{"code"}, (*Counter).Value)}
+	g := &GaugeVec{f: newFamily("test_ttl_seconds", "TTL.", "gauge", []string{"location"}, (*Gauge).Value)}
+	reg.Register(c)
+	reg.Register(g)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.WithLabelValues("200").Inc()
+		}()
+	}
+	wg.Wait()
+	c.WithLabelValues("500").Add(2)
+	c.WithLabelValues("500").Add(-1) // ignored
+	g.WithLabelValues(`Quote"d`).Set(600)
+	g.WithLabelValues("London").Set(300)
+	g.WithLabelValues("London").Add(-60)
+
+	var b bytes.Buffer
+	reg.WriteText(&b)
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 10
+test_requests_total{code="500"} 2
+# HELP test_ttl_seconds TTL.
+# TYPE test_ttl_seconds gauge
+test_ttl_seconds{location="London"} 240
+test_ttl_seconds{location="Quote\"d"} 600
+`
+	if b.String() != want {
+		t.Errorf("Unexpected exposition\n got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	g.Delete("London")
+	b.Reset()
+	reg.WriteText(&b)
+	if strings.Contains(b.String(), "London") {
+		t.Error("Expected deleted series to be gone")
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&CounterVec{f: newFamily("dup", "", "counter", nil, (*Counter).Value)})
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	reg.Register(&CounterVec{f: newFamily("dup", "", "counter", nil, (*Counter).Value)})
+}
+
+func TestHandler(t *testing.T) {
+	// A registry of its own, so the counter is registered anew on every run
+	defer func(d *Registry) { Default = d }(Default)
+	Default = NewRegistry()
+	c := NewCounter("metrics_test_handler_total", "Handler test counter.")
+	c.Inc()
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), "metrics_test_handler_total 1\n") {
+		t.Errorf("Expected counter in output, got %s", rr.Body.String())
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected content type %s", rr.Header().Get("Content-Type"))
+	}
+}
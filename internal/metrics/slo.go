@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SLOWindows are the look-back windows exported for multi-window burn-rate alerting: the
+// 1h/5m, 6h/30m, 1d/2h and 3d/6h pairs recommended by the Google SRE workbook.
+var SLOWindows = []time.Duration{
+	5 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour, 72 * time.Hour,
+}
+
+// SLOObjectives are the targets an SLO is measured against.
+type SLOObjectives struct {
+	// Availability is the share of requests that must succeed, e.g. 0.999.
+	Availability float64
+	// LatencyTarget is the response time a request must beat to count as fast.
+	LatencyTarget time.Duration
+	// Latency is the share of requests that must be faster than LatencyTarget, e.g. 0.99.
+	Latency float64
+}
+
+// SLIs are the service level indicators over one window. Both ratios are 1 for a window
+// without requests.
+type SLIs struct {
+	Requests     uint64
+	Availability float64
+	Latency      float64
+}
+
+// sloBucket aggregates the requests of one minute.
+type sloBucket struct {
+	minute              int64
+	total, failed, slow uint64
+}
+
+// SLO tracks request outcomes in one-minute buckets over the longest SLOWindows entry and exports
+// SLIs, error-budget burn rates and objectives per window, so burn-rate alerts need only simple
+// threshold rules. It renders these families:
+//
+//	<prefix>_sli_availability{window}     share of successful requests
+//	<prefix>_sli_latency{window}          share of requests faster than the latency target
+//	<prefix>_slo_burn_rate{slo,window}    (1 - SLI) / (1 - objective)
+//	<prefix>_slo_requests{window}         requests in the window
+//	<prefix>_slo_objective{slo}           configured objectives
+type SLO struct {
+	prefix  string
+	obj     SLOObjectives
+	mu      sync.Mutex
+	buckets []sloBucket
+	now     func() time.Time
+}
+
+func newSLO(prefix string, obj SLOObjectives) *SLO {
+	longest := SLOWindows[len(SLOWindows)-1]
+	return &SLO{
+		prefix:  prefix,
+		obj:     obj,
+		buckets: make([]sloBucket, int(longest/time.Minute)),
+		now:     time.Now,
+	}
+}
+
+// NewSLO creates and registers an SLO in the Default registry.
+func NewSLO(prefix string, obj SLOObjectives) *SLO {
+	s := newSLO(prefix, obj)
+	Default.Register(s)
+	return s
+}
+
+// Record counts one request. success is false for requests that failed through the service's
+// fault (typically 5xx responses).
+func (s *SLO) Record(success bool, latency time.Duration) {
+	minute := s.now().Unix() / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[minute%int64(len(s.buckets))]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.total++
+	if !success {
+		b.failed++
+	}
+	if latency > s.obj.LatencyTarget {
+		b.slow++
+	}
+}
+
+// Window returns the SLIs over the last d, in whole minutes including the current one.
+func (s *SLO) Window(d time.Duration) SLIs {
+	now := s.now().Unix() / 60
+	oldest := now - int64(d/time.Minute) + 1
+	var total, failed, slow uint64
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		if b.minute >= oldest && b.minute <= now {
+			total += b.total
+			failed += b.failed
+			slow += b.slow
+		}
+	}
+	s.mu.Unlock()
+	if total == 0 {
+		return SLIs{Availability: 1, Latency: 1}
+	}
+	return SLIs{
+		Requests:     total,
+		Availability: 1 - float64(failed)/float64(total),
+		Latency:      1 - float64(slow)/float64(total),
+	}
+}
+
+// burnRate is how fast the error budget of objective is spent at sli; 1 spends it exactly over
+// the SLO period.
+func burnRate(sli, objective float64) float64 {
+	if objective >= 1 {
+		return 0
+	}
+	return (1 - sli) / (1 - objective)
+}
+
+// Name returns the metric name prefix.
+func (s *SLO) Name() string { return s.prefix }
+
+func (s *SLO) write(b *bytes.Buffer) {
+	slis := make([]SLIs, len(SLOWindows))
+	for i, d := range SLOWindows {
+		slis[i] = s.Window(d)
+	}
+
+	gauge := func(suffix, help string) string {
+		name := s.prefix + "_" + suffix
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		return name
+	}
+
+	name := gauge("sli_availability", "Share of successful requests per window.")
+	for i, d := range SLOWindows {
+		fmt.Fprintf(b, "%s{window=\"%s\"} %s\n", name, windowLabel(d), formatValue(slis[i].Availability))
+	}
+	name = gauge("sli_latency", "Share of requests faster than the latency target per window.")
+	for i, d := range SLOWindows {
+		fmt.Fprintf(b, "%s{window=\"%s\"} %s\n", name, windowLabel(d), formatValue(slis[i].Latency))
+	}
+	name = gauge("slo_burn_rate", "Error budget burn rate per SLO and window; 1 spends the budget exactly over the SLO period.")
+	for i, d := range SLOWindows {
+		fmt.Fprintf(b, "%s{slo=\"availability\",window=\"%s\"} %s\n", name, windowLabel(d), formatValue(burnRate(slis[i].Availability, s.obj.Availability)))
+	}
+	for i, d := range SLOWindows {
+		fmt.Fprintf(b, "%s{slo=\"latency\",window=\"%s\"} %s\n", name, windowLabel(d), formatValue(burnRate(slis[i].Latency, s.obj.Latency)))
+	}
+	name = gauge("slo_requests", "Requests counted per window.")
+	for i, d := range SLOWindows {
+		fmt.Fprintf(b, "%s{window=\"%s\"} %d\n", name, windowLabel(d), slis[i].Requests)
+	}
+	name = gauge("slo_objective", "Configured SLO objectives.")
+	fmt.Fprintf(b, "%s{slo=\"availability\"} %s\n", name, formatValue(s.obj.Availability))
+	fmt.Fprintf(b, "%s{slo=\"latency\"} %s\n", name, formatValue(s.obj.Latency))
+}
+
+// windowLabel renders a window as Prometheus-style duration, e.g. "30m", "6h", "3d".
+func windowLabel(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
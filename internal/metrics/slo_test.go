@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLO_Windows(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 30, 0, time.UTC)
+	s := newSLO("test", SLOObjectives{Availability: 0.99, LatencyTarget: 100 * time.Millisecond, Latency: 0.9})
+	s.now = func() time.Time { return now }
+
+	// An hour ago: 10 requests, all failing
+	now = now.Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		s.Record(false, time.Millisecond)
+	}
+	// Now: 90 successful requests, 10 of them slow
+	now = now.Add(time.Hour)
+	for i := 0; i < 90; i++ {
+		latency := time.Millisecond
+		if i < 10 {
+			latency = time.Second
+		}
+		s.Record(true, latency)
+	}
+
+	short := s.Window(5 * time.Minute)
+	if short.Requests != 90 || short.Availability != 1 {
+		t.Errorf("Expected only recent requests in 5m window, got %+v", short)
+	}
+	if math.Abs(short.Latency-80.0/90) > 1e-9 {
+		t.Errorf("Expected latency SLI 80/90, got %v", short.Latency)
+	}
+	long := s.Window(2 * time.Hour)
+	if long.Requests != 100 || long.Availability != 0.9 {
+		t.Errorf("Expected both minutes in 2h window, got %+v", long)
+	}
+	if got := burnRate(long.Availability, 0.99); math.Abs(got-10) > 1e-9 {
+		t.Errorf("Expected burn rate 10, got %v", got)
+	}
+	// Buckets older than the longest window are reused
+	now = now.Add(72 * time.Hour)
+	if w := s.Window(72 * time.Hour); w.Requests != 0 || w.Availability != 1 || w.Latency != 1 {
+		t.Errorf("Expected an empty window after 3 days, got %+v", w)
+	}
+}
+
+func TestSLO_Exposition(t *testing.T) {
+	reg := NewRegistry()
+	s := newSLO("test", SLOObjectives{Availability: 0.75, LatencyTarget: time.Second, Latency: 0.99})
+	reg.Register(s)
+	s.Record(true, time.Millisecond)
+	s.Record(false, time.Millisecond)
+
+	var b bytes.Buffer
+	reg.WriteText(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE test_sli_availability gauge\n",
+		`test_sli_availability{window="5m"} 0.5`,
+		`test_sli_latency{window="3d"} 1`,
+		`test_slo_burn_rate{slo="availability",window="1h"} 2`,
+		`test_slo_burn_rate{slo="latency",window="6h"} 0`,
+		`test_slo_requests{window="1d"} 2`,
+		`test_slo_objective{slo="availability"} 0.75`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected exposition to contain %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWindowLabel(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		5 * time.Minute: "5m", 90 * time.Minute: "90m", 6 * time.Hour: "6h", 72 * time.Hour: "3d",
+	} {
+		if got := windowLabel(d); got != want {
+			t.Errorf("windowLabel(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
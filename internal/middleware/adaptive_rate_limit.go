@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+// UpstreamSample is a cumulative snapshot of upstream provider calls (see
+// repository.GetUpstreamSample). The adaptive limiter compares consecutive samples.
+type UpstreamSample struct {
+	Requests       float64
+	Errors         float64
+	LatencySeconds float64
+}
+
+var rateLimitFactorGauge = metrics.NewGauge("weather_rate_limit_factor",
+	"Share of the configured global rate limit currently enforced by adaptive rate limiting.")
+
+// rateLimitFactor holds the float64 bits of the current global limit factor, in (0, 1].
+var rateLimitFactor atomic.Uint64
+
+func init() {
+	setRateLimitFactor(1)
+}
+
+func currentRateLimitFactor() float64 {
+	return math.Float64frombits(rateLimitFactor.Load())
+}
+
+// setRateLimitFactor scales every global limiter, existing and future, to factor times the
+// configured rate and burst.
+func setRateLimitFactor(factor float64) {
+	rateLimitFactor.Store(math.Float64bits(factor))
+	rateLimitFactorGauge.Set(factor)
+	r, burst := adaptiveGlobalLimits()
+	now := rateLimitClock.Now()
+	globalVisitors.each(func(_ string, v *visitor) {
+		v.limiter.SetLimitAt(now, rate.Limit(r/60.0))
+		v.limiter.SetBurstAt(now, burst)
+	})
+}
+
+// adaptiveGlobalLimits returns the global rate and burst scaled by the adaptive factor. The burst
+// never drops below 1 so clients are slowed down rather than locked out.
+func adaptiveGlobalLimits() (float64, int) {
+	r, burst := config.GetGlobalRateLimiterConfig()
+	factor := currentRateLimitFactor()
+	return r * factor, max(1, int(math.Ceil(float64(burst)*factor)))
+}
+
+// adaptiveLimiter decides the global limit factor from upstream health, one interval at a time.
+type adaptiveLimiter struct {
+	cfg    config.AdaptiveRateLimitConfig
+	sample func() UpstreamSample
+	last   UpstreamSample
+	factor float64
+}
+
+func newAdaptiveLimiter(cfg config.AdaptiveRateLimitConfig, sample func() UpstreamSample) *adaptiveLimiter {
+	return &adaptiveLimiter{cfg: cfg, sample: sample, last: sample(), factor: 1}
+}
+
+// evaluate judges the upstream calls made since the previous evaluation and returns the new factor.
+// An unhealthy interval multiplies the factor by TightenFactor, down to MinFactor; any other
+// interval, including one with too few calls to judge, adds RelaxStep back, up to 1.
+func (a *adaptiveLimiter) evaluate() float64 {
+	current := a.sample()
+	requests := current.Requests - a.last.Requests
+	errors := current.Errors - a.last.Errors
+	latency := current.LatencySeconds - a.last.LatencySeconds
+	a.last = current
+
+	unhealthy := requests >= float64(a.cfg.MinRequests) &&
+		(errors/requests >= a.cfg.ErrorRate || latency/requests >= a.cfg.Latency.Seconds())
+	if unhealthy {
+		a.factor = max(a.cfg.MinFactor, a.factor*a.cfg.TightenFactor)
+	} else {
+		a.factor = min(1, a.factor+a.cfg.RelaxStep)
+	}
+	return a.factor
+}
+
+// StartAdaptiveRateLimit tightens the global rate limit while the upstream provider is failing or
+// slow, and relaxes it again once it recovers, evaluating sample every rate_limiter.adaptive.interval.
+// It does nothing unless rate_limiter.adaptive.enabled is set. The returned function stops it and
+// restores the configured limit.
+func StartAdaptiveRateLimit(sample func() UpstreamSample) (stop func()) {
+	cfg := config.GetAdaptiveRateLimitConfig()
+	if !cfg.Enabled {
+		return func() {}
+	}
+	a := newAdaptiveLimiter(cfg, sample)
+	stopJob := jobs.New("adaptive_rate_limit", cfg.Interval, func(context.Context) error {
+		previous := currentRateLimitFactor()
+		factor := a.evaluate()
+		if factor != previous {
+			logger().Warnw("Adaptive rate limit changed", "factor", factor, "previous", previous)
+			setRateLimitFactor(factor)
+		}
+		return nil
+	}).Schedule(false)
+	return func() {
+		stopJob()
+		setRateLimitFactor(1)
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/spf13/viper"
+)
+
+func TestAdaptiveLimiter_Evaluate(t *testing.T) {
+	cfg := config.AdaptiveRateLimitConfig{
+		MinRequests: 10, ErrorRate: 0.2, Latency: time.Second, TightenFactor: 0.5, MinFactor: 0.2, RelaxStep: 0.25,
+	}
+	var current UpstreamSample
+	a := newAdaptiveLimiter(cfg, func() UpstreamSample { return current })
+
+	steps := []struct {
+		name                   string
+		requests, errors, slow float64
+		want                   float64
+	}{
+		{"healthy", 100, 5, 10, 1},
+		{"error spike", 100, 30, 10, 0.5},
+		{"slow", 20, 0, 30, 0.25},
+		{"floor", 50, 50, 0, 0.2},
+		{"too few calls to judge", 5, 5, 0, 0.45},
+		{"recovering", 100, 0, 10, 0.7},
+		{"recovered", 100, 0, 10, 0.95},
+		{"capped", 100, 0, 10, 1},
+	}
+	for _, s := range steps {
+		current.Requests += s.requests
+		current.Errors += s.errors
+		current.LatencySeconds += s.slow
+		if got := a.evaluate(); got < s.want-1e-9 || got > s.want+1e-9 {
+			t.Errorf("%s: expected factor %v, got %v", s.name, s.want, got)
+		}
+	}
+}
+
+func TestSetRateLimitFactor(t *testing.T) {
+	ResetVisitors()
+	defer setRateLimitFactor(1)
+	viper.Set("rate_limiter.global.rate", 60)
+	viper.Set("rate_limiter.global.burst", 10)
+	defer viper.Set("rate_limiter.global.rate", nil)
+	defer viper.Set("rate_limiter.global.burst", nil)
+
+	existing := GetGlobalLimiter("10.0.0.1")
+	setRateLimitFactor(0.25)
+	if existing.Limit() != 0.25 || existing.Burst() != 3 {
+		t.Errorf("Expected the existing limiter scaled, got limit %v burst %d", existing.Limit(), existing.Burst())
+	}
+	if created := GetGlobalLimiter("10.0.0.2"); created.Limit() != 0.25 || created.Burst() != 3 {
+		t.Errorf("Expected new limiters scaled, got limit %v burst %d", created.Limit(), created.Burst())
+	}
+	if got := rateLimitFactorGauge.Value(); got != 0.25 {
+		t.Errorf("Expected the factor gauge at 0.25, got %v", got)
+	}
+
+	setRateLimitFactor(0.01)
+	if existing.Burst() != 1 {
+		t.Errorf("Expected the burst never below 1, got %d", existing.Burst())
+	}
+}
+
+func TestStartAdaptiveRateLimit(t *testing.T) {
+	stop := StartAdaptiveRateLimit(func() UpstreamSample { return UpstreamSample{} })
+	stop()
+
+	viper.Set("rate_limiter.adaptive.enabled", true)
+	viper.Set("rate_limiter.adaptive.interval", "10ms")
+	defer viper.Set("rate_limiter.adaptive.enabled", nil)
+	defer viper.Set("rate_limiter.adaptive.interval", nil)
+
+	var calls float64
+	stop = StartAdaptiveRateLimit(func() UpstreamSample {
+		calls += 100
+		return UpstreamSample{Requests: calls, Errors: calls}
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for currentRateLimitFactor() == 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if currentRateLimitFactor() >= 1 {
+		t.Error("Expected the factor to tighten while every upstream call fails")
+	}
+	stop()
+	if currentRateLimitFactor() != 1 {
+		t.Errorf("Expected stop to restore the configured limit, got %v", currentRateLimitFactor())
+	}
+}
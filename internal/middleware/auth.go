@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+)
+
+// APIKeyHeader is the header clients use to present their API key.
+const APIKeyHeader = "X-API-Key"
+
+type apiKeyKey struct{}
+
+// AuthMiddleware returns an HTTP middleware that requires a valid API key (X-API-Key header or
+// `Authorization: Bearer <key>`) on every route when auth.enabled is true, except on the routes
+// auth.routes marks public, and on the routes it marks required when auth.enabled is false. The
+// matched key is stored in the request context, along with the key's own provider account when it
+// has one. A valid key presented on a public route is recognised too; an invalid one is ignored.
+// When no route requires a key, next is returned unchanged.
+func AuthMiddleware(next http.Handler) http.Handler {
+	enabled := config.IsAuthEnabled()
+	routes := config.GetAuthRoutes()
+	if !enabled && !slices.Contains(slices.Collect(maps.Values(routes)), config.AuthRequired) {
+		return next
+	}
+	keys := config.GetAPIKeys()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := enabled
+		if route, ok := matchRoute(routes, r.URL.Path); ok {
+			required = routes[route] == config.AuthRequired
+		}
+		presented := presentedAPIKey(r)
+		key, ok := lookupAPIKey(keys, presented)
+		switch {
+		case ok:
+			r = r.WithContext(withAPIKey(r.Context(), key))
+		case !required:
+		case presented == "":
+			writeError(w, http.StatusUnauthorized, "Missing API key", "Unauthorized")
+			return
+		default:
+			writeError(w, http.StatusUnauthorized, "Invalid API key", "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// KeyMiddleware returns an HTTP middleware that requires a valid API key, whatever auth.enabled
+// and auth.routes say, for routes that act on behalf of a key. A request without a valid key is
+// rejected with 401.
+func KeyMiddleware(next http.Handler) http.Handler {
+	keys := config.GetAPIKeys()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r, ok := requireAPIKey(w, r, keys); ok {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// AdminMiddleware returns an HTTP middleware that requires an API key with admin: true, whatever
+// auth.enabled and auth.routes say. A request without a valid key is rejected with 401, one with a
+// key that is not an admin key with 403. With no admin key configured, the wrapped routes are
+// unreachable.
+func AdminMiddleware(next http.Handler) http.Handler {
+	keys := config.GetAPIKeys()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := requireAPIKey(w, r, keys)
+		if !ok {
+			return
+		}
+		if key, _ := APIKeyFromContext(r.Context()); !key.Admin {
+			writeError(w, http.StatusForbidden, "Admin API key required", "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIKey returns r with the presented key in its context, unless AuthMiddleware already
+// stored one. Without a valid key it writes a 401 and returns false.
+func requireAPIKey(w http.ResponseWriter, r *http.Request, keys []config.APIKey) (*http.Request, bool) {
+	if _, ok := APIKeyFromContext(r.Context()); ok {
+		return r, true
+	}
+	presented := presentedAPIKey(r)
+	if presented == "" {
+		writeError(w, http.StatusUnauthorized, "Missing API key", "Unauthorized")
+		return r, false
+	}
+	key, ok := lookupAPIKey(keys, presented)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Invalid API key", "Unauthorized")
+		return r, false
+	}
+	return r.WithContext(withAPIKey(r.Context(), key)), true
+}
+
+// withAPIKey returns a copy of ctx carrying key and, when it has one, its provider account.
+func withAPIKey(ctx context.Context, key config.APIKey) context.Context {
+	ctx = logctx.WithAPIKey(context.WithValue(ctx, apiKeyKey{}, key), key.Name)
+	if p := key.Provider; p != nil {
+		ctx = tenant.WithProvider(ctx, tenant.Provider{
+			Tenant:     key.Name,
+			Name:       p.Name,
+			APIKey:     p.APIKey(),
+			APIURL:     p.APIURL,
+			DailyQuota: p.DailyQuota,
+		})
+	}
+	return ctx
+}
+
+// APIKeyFromContext returns the API key authenticated by AuthMiddleware, if any.
+func APIKeyFromContext(ctx context.Context) (config.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(config.APIKey)
+	return key, ok
+}
+
+// presentedAPIKey extracts the API key from the X-API-Key or Authorization header.
+func presentedAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// lookupAPIKey finds the configured key matching presented using constant-time comparison.
+func lookupAPIKey(keys []config.APIKey, presented string) (config.APIKey, bool) {
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(presented)) == 1 {
+			return k, true
+		}
+	}
+	return config.APIKey{}, false
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/spf13/viper"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "secret-1", "name": "team-a", "tier": "premium"},
+	})
+	defer func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	}()
+
+	var name string
+	h := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := APIKeyFromContext(r.Context())
+		if logctx.FieldsFrom(r.Context()).APIKey != key.Name {
+			t.Error("Expected the key name to be recorded for logging")
+		}
+		name = key.Name
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{name: "Missing key", wantStatus: http.StatusUnauthorized},
+		{name: "Invalid key", header: APIKeyHeader, value: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "Valid X-API-Key", header: APIKeyHeader, value: "secret-1", wantStatus: http.StatusOK},
+		{name: "Valid bearer token", header: "Authorization", value: "Bearer secret-1", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name = ""
+			req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusOK && name != "team-a" {
+				t.Errorf("Expected the API key in context, got %q", name)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_TenantProvider(t *testing.T) {
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "shared", "name": "team-a"},
+		{"key": "own", "name": "acme", "provider": map[string]interface{}{
+			"api_key_env": "ACME_OWM_KEY", "api_url": "https://owm.example.com", "daily_quota": 500,
+		}},
+	})
+	defer func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	}()
+	t.Setenv("ACME_OWM_KEY", "acme-secret")
+
+	var got tenant.Provider
+	var ok bool
+	h := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = tenant.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set(APIKeyHeader, "own")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	want := tenant.Provider{Tenant: "acme", Name: "openweathermap", APIKey: "acme-secret", APIURL: "https://owm.example.com", DailyQuota: 500}
+	if !ok || got != want {
+		t.Errorf("Expected %+v in context, got %+v", want, got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set(APIKeyHeader, "shared")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ok {
+		t.Error("Expected keys without a provider to use the shared account")
+	}
+}
+
+func TestAuthMiddleware_Disabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, ok := AuthMiddleware(next).(http.HandlerFunc); !ok {
+		t.Error("Expected next to be returned unchanged when auth is disabled")
+	}
+}
+
+func TestAuthMiddleware_Routes(t *testing.T) {
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "secret-1", "name": "team-a"},
+	})
+	viper.Set("auth.routes", map[string]interface{}{"/weather": "public", "/forecast": "required", "/admin/": "required"})
+	defer func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+		viper.Set("auth.routes", nil)
+	}()
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		path       string
+		key        string
+		wantStatus int
+		wantKey    string
+	}{
+		{name: "Public route without key", path: "/weather", wantStatus: http.StatusOK},
+		{name: "Public route with valid key", path: "/weather", key: "secret-1", wantStatus: http.StatusOK, wantKey: "team-a"},
+		{name: "Public route ignores invalid key", path: "/weather", key: "nope", wantStatus: http.StatusOK},
+		{name: "Required route without key", path: "/forecast", wantStatus: http.StatusUnauthorized},
+		{name: "Required prefix with invalid key", path: "/admin/config", key: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "Required route with valid key", path: "/forecast", key: "secret-1", wantStatus: http.StatusOK, wantKey: "team-a"},
+		{name: "Unlisted route with auth disabled", path: "/status", wantStatus: http.StatusOK},
+		{name: "Unlisted route with auth enabled", enabled: true, path: "/status", wantStatus: http.StatusUnauthorized},
+		{name: "Public route with auth enabled", enabled: true, path: "/weather", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("auth.enabled", tt.enabled)
+			var gotKey string
+			h := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key, _ := APIKeyFromContext(r.Context())
+				gotKey = key.Name
+			}))
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("Expected key %q, got %q", tt.wantKey, gotKey)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_PublicRoutesOnly(t *testing.T) {
+	viper.Set("auth.routes", map[string]interface{}{"/weather": "public"})
+	defer viper.Set("auth.routes", nil)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, ok := AuthMiddleware(next).(http.HandlerFunc); !ok {
+		t.Error("Expected next to be returned unchanged when no route requires a key")
+	}
+}
+
+func TestKeyMiddleware(t *testing.T) {
+	viper.Set("auth.api_keys", []map[string]interface{}{{"key": "user-1", "name": "team-a"}})
+	defer viper.Set("auth.api_keys", nil)
+
+	h := KeyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := APIKeyFromContext(r.Context()); !ok || key.Name != "team-a" {
+			t.Errorf("Expected the key in context, got %+v", key)
+		}
+	}))
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "nope": http.StatusUnauthorized, "user-1": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Key %q: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "admin-1", "name": "ops", "admin": true},
+		{"key": "user-1", "name": "team-a"},
+	})
+	defer viper.Set("auth.api_keys", nil)
+
+	h := AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := APIKeyFromContext(r.Context()); !ok || key.Name != "ops" {
+			t.Errorf("Expected the admin key in context, got %+v", key)
+		}
+	}))
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{name: "Missing key", wantStatus: http.StatusUnauthorized},
+		{name: "Invalid key", key: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "Non-admin key", key: "user-1", wantStatus: http.StatusForbidden},
+		{name: "Admin key", key: "admin-1", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/jobs", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// CaptureSink stores a captured request/response pair.
+type CaptureSink func(ctx context.Context, capture model.Capture) error
+
+// captureSink receives the captures of CaptureMiddleware.
+var captureSink CaptureSink
+
+// SetCaptureSink sets where CaptureMiddleware stores captures. It must be called before the
+// middleware is built; without a sink nothing is captured.
+func SetCaptureSink(sink CaptureSink) {
+	captureSink = sink
+}
+
+var capturesStored = metrics.NewCounter("weather_captures_total",
+	"Request/response pairs captured for debugging.")
+
+// CaptureMiddleware returns an HTTP middleware that records requests matching every capture filter
+// (location, response status, API key name) with their responses, credentials and secret body
+// fields redacted and bodies cut to capture.max_body_bytes, and hands them to the CaptureSink. The client's response is
+// unaffected. When capture is disabled or no sink is set, next is returned unchanged.
+func CaptureMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetCaptureConfig()
+	sink := captureSink
+	if !cfg.Enabled || sink == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The status filter can only be applied once the response is known
+		if !captureRequestMatches(cfg, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		reqBody, reqTruncated := peekBody(r, cfg.MaxBodyBytes)
+		rw := &captureResponseWriter{ResponseWriter: w, limit: cfg.MaxBodyBytes}
+		next.ServeHTTP(rw, r)
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		if len(cfg.Statuses) > 0 && !slices.Contains(cfg.Statuses, rw.status) {
+			return
+		}
+
+		fields := logctx.FieldsFrom(r.Context())
+		key, _ := APIKeyFromContext(r.Context())
+		capture := model.Capture{
+			At:         start.UTC(),
+			RequestID:  fields.RequestID,
+			APIKey:     key.Name,
+			ClientIP:   fields.ClientIP,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Location:   getParam(r),
+			Request:    model.CapturedMsg{Headers: traceHeaders(r.Header), Body: redactBody(reqBody), Truncated: reqTruncated},
+			Response:   model.CapturedMsg{Headers: traceHeaders(rw.Header()), Body: redactBody(rw.body.String()), Truncated: rw.truncated},
+			Status:     rw.status,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		// The client may already be gone; the capture is still worth keeping
+		if err := sink(context.WithoutCancel(r.Context()), capture); err != nil {
+			logger().Warnw("Failed to store capture", "path", r.URL.Path, "error", err)
+			return
+		}
+		capturesStored.Inc()
+	})
+}
+
+// secretField matches a JSON member whose name contains secret, password or token, such as a
+// webhook subscription's "secret", up to the end of its string value or of a truncated body.
+var secretField = regexp.MustCompile(`("[^"]*(?i:secret|password|token)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// redactBody replaces the values of secret JSON members in a captured body, as /admin/config does
+// for settings.
+func redactBody(body string) string {
+	return secretField.ReplaceAllString(body, `$1"[redacted]"`)
+}
+
+// captureRequestMatches applies the location and API key filters.
+func captureRequestMatches(cfg config.CaptureConfig, r *http.Request) bool {
+	if len(cfg.Locations) > 0 {
+		location := strings.TrimSpace(getParam(r))
+		if !slices.ContainsFunc(cfg.Locations, func(l string) bool { return strings.EqualFold(strings.TrimSpace(l), location) }) {
+			return false
+		}
+	}
+	if len(cfg.APIKeys) > 0 {
+		key, ok := APIKeyFromContext(r.Context())
+		if !ok || !slices.Contains(cfg.APIKeys, key.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// peekBody returns up to limit bytes of the request body, leaving the whole body readable by the
+// handler. truncated is set when the body is longer.
+func peekBody(r *http.Request, limit int) (body string, truncated bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", false
+	}
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if len(buf) > limit {
+		return string(buf[:limit]), true
+	}
+	return string(buf), false
+}
+
+// captureResponseWriter passes writes through to the client while keeping the status and the first
+// limit bytes of the body.
+type captureResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rw *captureResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *captureResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if room := rw.limit - rw.body.Len(); room < len(b) {
+		rw.body.Write(b[:max(room, 0)])
+		rw.truncated = true
+	} else {
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. for flushing).
+func (rw *captureResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+// setCaptureSink records captures in the returned slice for the duration of the test.
+func setCaptureSink(t *testing.T) *[]model.Capture {
+	t.Helper()
+	var got []model.Capture
+	SetCaptureSink(func(_ context.Context, c model.Capture) error {
+		got = append(got, c)
+		return nil
+	})
+	t.Cleanup(func() { SetCaptureSink(nil) })
+	return &got
+}
+
+func TestCaptureMiddleware_Filters(t *testing.T) {
+	viper.Set("capture.enabled", true)
+	viper.Set("capture.locations", []string{"London"})
+	viper.Set("capture.statuses", []int{http.StatusBadGateway})
+	defer func() {
+		viper.Set("capture.enabled", nil)
+		viper.Set("capture.locations", nil)
+		viper.Set("capture.statuses", nil)
+	}()
+	got := setCaptureSink(t)
+
+	h := CaptureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte(`{"message":"done"}`))
+	}))
+
+	tests := []struct {
+		target      string
+		wantCapture bool
+	}{
+		{target: "/weather?location=london&fail=true", wantCapture: true},
+		{target: "/weather?location=London"},
+		{target: "/weather?location=Paris&fail=true"},
+	}
+	for _, tt := range tests {
+		*got = nil
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rr.Body.String() != `{"message":"done"}` {
+			t.Errorf("%s: expected the response to reach the client, got %q", tt.target, rr.Body)
+		}
+		if captured := len(*got) == 1; captured != tt.wantCapture {
+			t.Errorf("%s: expected capture %v, got %d captures", tt.target, tt.wantCapture, len(*got))
+		}
+	}
+}
+
+func TestCaptureMiddleware_Capture(t *testing.T) {
+	viper.Set("capture.enabled", true)
+	viper.Set("capture.max_body_bytes", 8)
+	viper.Set("capture.api_keys", []string{"team-a"})
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "secret-1", "name": "team-a"},
+		{"key": "secret-2", "name": "team-b"},
+	})
+	defer func() {
+		viper.Set("capture.enabled", nil)
+		viper.Set("capture.max_body_bytes", nil)
+		viper.Set("capture.api_keys", nil)
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	}()
+	got := setCaptureSink(t)
+
+	var handlerBody string
+	h := RequestIDMiddleware(AuthMiddleware(CaptureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		handlerBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"sub-123"}`))
+	}))))
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions?location=Jakarta", strings.NewReader(`{"url":"https://example.com/hook"}`))
+	req.Header.Set(APIKeyHeader, "secret-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if handlerBody != `{"url":"https://example.com/hook"}` {
+		t.Errorf("Expected the handler to read the full body, got %q", handlerBody)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("Expected one capture, got %d", len(*got))
+	}
+	c := (*got)[0]
+	if c.APIKey != "team-a" || c.Method != http.MethodPost || c.Path != "/subscriptions" || c.Location != "Jakarta" || c.Status != http.StatusCreated {
+		t.Errorf("Unexpected capture %+v", c)
+	}
+	if c.RequestID == "" || c.RequestID != rr.Header().Get(RequestIDHeader) {
+		t.Errorf("Expected the request ID %q, got %q", rr.Header().Get(RequestIDHeader), c.RequestID)
+	}
+	if c.Request.Headers.Get(APIKeyHeader) != "[redacted]" {
+		t.Errorf("Expected the API key to be redacted, got %v", c.Request.Headers)
+	}
+	if c.Request.Body != `{"url":"` || !c.Request.Truncated || c.Response.Body != `{"id":"s` || !c.Response.Truncated {
+		t.Errorf("Expected bodies cut to 8 bytes, got %+v and %+v", c.Request, c.Response)
+	}
+	if c.Response.Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the response headers, got %v", c.Response.Headers)
+	}
+
+	*got = nil
+	req = httptest.NewRequest(http.MethodGet, "/weather?location=Jakarta", nil)
+	req.Header.Set(APIKeyHeader, "secret-2")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(*got) != 0 {
+		t.Errorf("Expected other keys not to be captured, got %+v", *got)
+	}
+}
+
+func TestCaptureMiddleware_RedactsSecrets(t *testing.T) {
+	viper.Set("capture.enabled", true)
+	defer viper.Set("capture.enabled", nil)
+	got := setCaptureSink(t)
+
+	h := CaptureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ss10n"})
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"data":{"id":"sub_1","location":"Jakarta","secret":"whsec_created"},"message":"Success"}`))
+	}))
+	body := `{"location":"Jakarta","url":"https://example.com/hook","secret": "whsec_\"chosen\""}`
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body)))
+
+	if len(*got) != 1 {
+		t.Fatalf("Expected one capture, got %d", len(*got))
+	}
+	c := (*got)[0]
+	for _, captured := range []string{c.Request.Body, c.Response.Body} {
+		if strings.Contains(captured, "whsec_") || !strings.Contains(captured, `"secret":`) {
+			t.Errorf("Expected the secret redacted, got %s", captured)
+		}
+	}
+	if !strings.Contains(c.Request.Body, `"url":"https://example.com/hook"`) || !strings.Contains(c.Response.Body, `"id":"sub_1"`) {
+		t.Errorf("Expected the other fields kept, got %s and %s", c.Request.Body, c.Response.Body)
+	}
+	if c.Response.Headers.Get("Set-Cookie") != "[redacted]" {
+		t.Errorf("Expected Set-Cookie redacted, got %v", c.Response.Headers)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct{ body, want string }{
+		{`{"secret":"abc","status":"active"}`, `{"secret":"[redacted]","status":"active"}`},
+		{`{"webhookSecret":"abc","client_token" : "def"}`, `{"webhookSecret":"[redacted]","client_token" : "[redacted]"}`},
+		// A body cut in the middle of a secret still hides what was captured of it
+		{`{"url":"https://x","secret":"whsec_ab`, `{"url":"https://x","secret":"[redacted]"`},
+		{`{"description":"token"}`, `{"description":"token"}`},
+		{`location=Jakarta`, `location=Jakarta`},
+	}
+	for _, tt := range tests {
+		if got := redactBody(tt.body); got != tt.want {
+			t.Errorf("redactBody(%s): expected %s, got %s", tt.body, tt.want, got)
+		}
+	}
+}
+
+func TestCaptureMiddleware_Disabled(t *testing.T) {
+	setCaptureSink(t)
+	next := http.RedirectHandler("/", http.StatusFound)
+	if CaptureMiddleware(next) != next {
+		t.Error("Expected next to be returned unchanged when capture is disabled")
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// Middleware wraps an http.Handler with additional behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Named pairs a middleware with the name used to identify and disable it.
+type Named struct {
+	Name       string
+	Middleware Middleware
+}
+
+// Chain composes middlewares in order: the first middleware is the outermost and sees the request first.
+type Chain struct {
+	items []Named
+}
+
+// NewChain creates a chain from the given middlewares, outermost first.
+func NewChain(items ...Named) *Chain {
+	return &Chain{items: append([]Named(nil), items...)}
+}
+
+// Use appends a middleware to the inside of the chain and returns the chain for further calls.
+func (c *Chain) Use(name string, mw Middleware) *Chain {
+	c.items = append(c.items, Named{Name: name, Middleware: mw})
+	return c
+}
+
+// Without returns a copy of the chain with the named middlewares removed.
+func (c *Chain) Without(names ...string) *Chain {
+	out := &Chain{}
+	for _, item := range c.items {
+		if !slices.Contains(names, item.Name) {
+			out.items = append(out.items, item)
+		}
+	}
+	return out
+}
+
+// Names returns the middleware names in order, outermost first.
+func (c *Chain) Names() []string {
+	names := make([]string, len(c.items))
+	for i, item := range c.items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+// Then wraps h with every middleware in the chain.
+func (c *Chain) Then(h http.Handler) http.Handler {
+	for i := len(c.items) - 1; i >= 0; i-- {
+		h = c.items[i].Middleware(h)
+	}
+	return h
+}
+
+// ThenFunc wraps fn with every middleware in the chain.
+func (c *Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
+
+// DefaultChain returns the standard chain for public API routes:
+// recovery → request_id → logging → metrics → timeout → signing → cors → auth → debug_trace → capture → read_only → priority → request_cost → deprecation → rate_limit → mirror → handler.
+// Middlewares listed in middleware.disabled are left out, except recovery and read_only which are always applied.
+func DefaultChain() *Chain {
+	chain := NewChain(
+		Named{Name: "recovery", Middleware: RecoveryMiddleware},
+		Named{Name: "request_id", Middleware: RequestIDMiddleware},
+		Named{Name: "logging", Middleware: LoggingMiddleware},
+		Named{Name: "metrics", Middleware: MetricsMiddleware},
+		Named{Name: "timeout", Middleware: TimeoutMiddleware},
+		Named{Name: "signing", Middleware: SigningMiddleware},
+		Named{Name: "cors", Middleware: CORSMiddleware},
+		Named{Name: "auth", Middleware: AuthMiddleware},
+		Named{Name: "debug_trace", Middleware: DebugTraceMiddleware},
+		Named{Name: "capture", Middleware: CaptureMiddleware},
+		Named{Name: "read_only", Middleware: ReadOnlyMiddleware},
+		Named{Name: "priority", Middleware: PriorityMiddleware},
+		Named{Name: "request_cost", Middleware: RequestCostMiddleware},
+		Named{Name: "deprecation", Middleware: DeprecationMiddleware},
+		Named{Name: "rate_limit", Middleware: RateLimitMiddleware},
+		Named{Name: "mirror", Middleware: MirrorMiddleware},
+	)
+	disabled := slices.DeleteFunc(config.GetDisabledMiddlewares(), func(name string) bool {
+		return name == "recovery" || name == "read_only"
+	})
+	return chain.Without(disabled...)
+}
+
+// KeyChain returns the chain for routes scoped to the caller's API key: the default chain with key
+// innermost, so every request must present a valid key. key cannot be disabled.
+func KeyChain() *Chain {
+	return DefaultChain().Use("key", KeyMiddleware)
+}
+
+// AdminChain returns the chain for /admin/ routes: the default chain with admin innermost, so every
+// request must present an admin API key. admin cannot be disabled.
+func AdminChain() *Chain {
+	return DefaultChain().Use("admin", AdminMiddleware)
+}
+
+// writeError writes the standard JSON error envelope.
+func writeError(w http.ResponseWriter, status int, errMsg, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(model.Response{
+		Error:   &errMsg,
+		Message: message,
+	})
+}
+
+// statusResponseWriter records the status code and number of bytes written.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rw *statusResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *statusResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. for flushing).
+func (rw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func tagging(name string, order *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*order = append(*order, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChain_OrderOutermostFirst(t *testing.T) {
+	var order []string
+	chain := NewChain(Named{Name: "a", Middleware: tagging("a", &order)}).
+		Use("b", tagging("b", &order)).
+		Use("c", tagging("c", &order))
+
+	h := chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"a", "b", "c", "handler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected order %v, got %v", want, order)
+	}
+}
+
+func TestChain_Without(t *testing.T) {
+	var order []string
+	chain := NewChain(
+		Named{Name: "a", Middleware: tagging("a", &order)},
+		Named{Name: "b", Middleware: tagging("b", &order)},
+	)
+	trimmed := chain.Without("a")
+	if !reflect.DeepEqual(trimmed.Names(), []string{"b"}) {
+		t.Errorf("Expected [b], got %v", trimmed.Names())
+	}
+	if !reflect.DeepEqual(chain.Names(), []string{"a", "b"}) {
+		t.Errorf("Expected the original chain to be unchanged, got %v", chain.Names())
+	}
+}
+
+func TestDefaultChain_Order(t *testing.T) {
+	want := []string{"recovery", "request_id", "logging", "metrics", "timeout", "signing", "cors", "auth", "debug_trace", "capture", "read_only", "priority", "request_cost", "deprecation", "rate_limit", "mirror"}
+	if got := DefaultChain().Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected default order %v, got %v", want, got)
+	}
+}
+
+func TestDefaultChain_DisabledFromConfig(t *testing.T) {
+	viper.Set("middleware.disabled", []string{"cors", "recovery", "read_only", "mirror"})
+	defer viper.Set("middleware.disabled", nil)
+
+	want := []string{"recovery", "request_id", "logging", "metrics", "timeout", "signing", "auth", "debug_trace", "capture", "read_only", "priority", "request_cost", "deprecation", "rate_limit"}
+	if got := DefaultChain().Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v (recovery and read_only cannot be disabled), got %v", want, got)
+	}
+}
+
+func TestKeyAndAdminChains(t *testing.T) {
+	viper.Set("middleware.disabled", []string{"key", "admin"})
+	defer viper.Set("middleware.disabled", nil)
+
+	if names := KeyChain().Names(); names[len(names)-1] != "key" {
+		t.Errorf("Expected key innermost and not disabled, got %v", names)
+	}
+	if names := AdminChain().Names(); names[len(names)-1] != "admin" {
+		t.Errorf("Expected admin innermost and not disabled, got %v", names)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// CORSMiddleware returns an HTTP middleware that sets CORS headers for allowed origins
+// (cors.allowed_origins) and answers preflight requests with 204 No Content.
+func CORSMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetCORSConfig()
+	allowAll := slices.Contains(cfg.AllowedOrigins, "*")
+	allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowAll && !slices.Contains(cfg.AllowedOrigins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if allowAll {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		h.Set("Access-Control-Expose-Headers", RequestIDHeader)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", allowedHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
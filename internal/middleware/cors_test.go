@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	called := false
+	h := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	req := httptest.NewRequest(http.MethodOptions, "/weather", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent || called {
+		t.Errorf("Expected preflight to be answered with 204, got %d (handler called: %v)", rr.Code, called)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected wildcard origin, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rr.Header().Get("Access-Control-Allow-Methods") != "GET, HEAD, OPTIONS" {
+		t.Errorf("Unexpected allowed methods %q", rr.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
+
+func TestCORSMiddleware_AllowList(t *testing.T) {
+	viper.Set("cors.allowed_origins", []string{"https://allowed.example"})
+	defer viper.Set("cors.allowed_origins", nil)
+	h := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set("Origin", "https://allowed.example")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://allowed.example" {
+		t.Errorf("Expected the allowed origin to be echoed, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS headers for a disallowed origin")
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/spf13/viper"
+)
+
+func TestDebugTraceMiddleware(t *testing.T) {
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "admin-key", "name": "ops", "admin": true},
+		{"key": "user-key", "name": "team-a"},
+	})
+	defer func() {
+		viper.Set("auth.enabled", nil)
+		viper.Set("auth.api_keys", nil)
+	}()
+
+	var debug bool
+	h := AuthMiddleware(DebugTraceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		debug = logctx.FieldsFrom(r.Context()).Debug
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	tests := []struct {
+		name      string
+		key       string
+		trace     string
+		wantDebug bool
+	}{
+		{name: "Admin forcing a trace", key: "admin-key", trace: "force", wantDebug: true},
+		{name: "Header value is case-insensitive", key: "admin-key", trace: "FORCE", wantDebug: true},
+		{name: "Admin without the header", key: "admin-key"},
+		{name: "Unknown header value", key: "admin-key", trace: "yes"},
+		{name: "Non-admin key", key: "user-key", trace: "force"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debug = false
+			req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+			req.Header.Set(APIKeyHeader, tt.key)
+			if tt.trace != "" {
+				req.Header.Set(DebugTraceHeader, tt.trace)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusTeapot {
+				t.Errorf("Expected the handler's status, got %d", rr.Code)
+			}
+			if debug != tt.wantDebug {
+				t.Errorf("Expected debug %v, got %v", tt.wantDebug, debug)
+			}
+			if forced := rr.Header().Get(DebugTraceHeader) == "forced"; forced != tt.wantDebug {
+				t.Errorf("Expected the forced header only on traced requests, got %q", rr.Header().Get(DebugTraceHeader))
+			}
+		})
+	}
+}
+
+func TestTraceHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set(APIKeyHeader, "secret")
+	h.Set("Accept", "application/json")
+	got := traceHeaders(h)
+	if got.Get("Authorization") != "[redacted]" || got.Get(APIKeyHeader) != "[redacted]" || got.Get("Accept") != "application/json" {
+		t.Errorf("Unexpected headers %v", got)
+	}
+	if h.Get(APIKeyHeader) != "secret" {
+		t.Error("Expected the request headers to be unchanged")
+	}
+	if _, ok := got["Cookie"]; ok {
+		t.Error("Expected absent headers to stay absent")
+	}
+}
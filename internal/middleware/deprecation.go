@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// DeprecationMiddleware returns an HTTP middleware that announces deprecated routes and parameters
+// configured under `deprecations`. Matching requests receive a `Deprecation` header (RFC 9745), a
+// `Sunset` header (RFC 8594) when a sunset date is set, a `Link` to the replacement, and a
+// `Warning: 299` header that the response writer surfaces as `warnings` in the JSON envelope.
+func DeprecationMiddleware(next http.Handler) http.Handler {
+	rules := config.GetDeprecations()
+	if len(rules) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, d := range rules {
+			if d.Path != r.URL.Path {
+				continue
+			}
+			if d.Param != "" && !r.URL.Query().Has(d.Param) {
+				continue
+			}
+			applyDeprecationHeaders(w.Header(), d)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func applyDeprecationHeaders(h http.Header, d config.Deprecation) {
+	if d.Since.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+	}
+	h.Add("Warning", "299 - "+strconv.Quote(deprecationMessage(d)))
+}
+
+func deprecationMessage(d config.Deprecation) string {
+	msg := d.Message
+	if msg == "" {
+		if d.Param != "" {
+			msg = fmt.Sprintf("The '%s' parameter on %s is deprecated", d.Param, d.Path)
+		} else {
+			msg = fmt.Sprintf("%s is deprecated", d.Path)
+		}
+	}
+	if !d.Sunset.IsZero() {
+		msg += fmt.Sprintf(" and will be removed after %s", d.Sunset.UTC().Format("2006-01-02"))
+	}
+	return msg
+}
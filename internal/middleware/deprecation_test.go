@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestDeprecationMiddleware_RouteAndParam(t *testing.T) {
+	viper.Set("deprecations", []map[string]interface{}{
+		{
+			"path":    "/weather",
+			"since":   "2025-01-01T00:00:00Z",
+			"sunset":  "2025-12-31T00:00:00Z",
+			"link":    "https://example.com/v1",
+			"message": "Use /v1/weather",
+		},
+		{"path": "/v1/weather", "param": "units"},
+	})
+	defer viper.Set("deprecations", nil)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := DeprecationMiddleware(next)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	if got := rr.Header().Get("Deprecation"); got != "@"+strconv.FormatInt(since, 10) {
+		t.Errorf("Unexpected Deprecation header %q", got)
+	}
+	if got := rr.Header().Get("Sunset"); got != "Wed, 31 Dec 2025 00:00:00 GMT" {
+		t.Errorf("Unexpected Sunset header %q", got)
+	}
+	if got := rr.Header().Get("Link"); got != `<https://example.com/v1>; rel="deprecation"` {
+		t.Errorf("Unexpected Link header %q", got)
+	}
+	if got := rr.Header().Get("Warning"); !strings.HasPrefix(got, `299 - "Use /v1/weather and will be removed after 2025-12-31"`) {
+		t.Errorf("Unexpected Warning header %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/weather?location=London", nil))
+	if rr.Header().Get("Deprecation") != "" {
+		t.Error("Expected no Deprecation header when the deprecated parameter is absent")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/weather?location=London&units=metric", nil))
+	if rr.Header().Get("Deprecation") != "true" {
+		t.Errorf("Expected Deprecation: true for the deprecated parameter, got %q", rr.Header().Get("Deprecation"))
+	}
+	if !strings.Contains(rr.Header().Get("Warning"), "'units' parameter") {
+		t.Errorf("Expected a default parameter warning, got %q", rr.Header().Get("Warning"))
+	}
+}
+
+func TestDeprecationMiddleware_NoRules(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, ok := DeprecationMiddleware(next).(http.HandlerFunc); !ok {
+		t.Error("Expected next to be returned unchanged without deprecation rules")
+	}
+}
@@ -0,0 +1,11 @@
+package middleware
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the middleware module logger, whose level is set by logging.levels.middleware.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("middleware")
+}
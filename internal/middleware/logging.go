@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+)
+
+// LoggingMiddleware returns an HTTP middleware that writes one access log line per request to the
+// "access" module logger (level set by logging.levels.access).
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		// Fields added to the context further down the chain (API key, location) are not visible
+		// here; the access log records the request ID and client IP set by RequestIDMiddleware.
+		logctx.Annotate(config.GetModuleLogger("access"), r.Context()).Infow("Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+		)
+	})
+}
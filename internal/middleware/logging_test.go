@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggingMiddleware_PassesThrough(t *testing.T) {
+	h := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+	if rr.Code != http.StatusTeapot || rr.Body.String() != "short and stout" {
+		t.Errorf("Expected the response to pass through unchanged, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestStatusResponseWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rw := &statusResponseWriter{ResponseWriter: rr}
+	_, _ = rw.Write([]byte("abc"))
+	rw.WriteHeader(http.StatusNotFound)
+	if rw.status != http.StatusOK || rw.bytes != 3 {
+		t.Errorf("Expected implicit 200 and 3 bytes, got %d and %d", rw.status, rw.bytes)
+	}
+	if rw.Unwrap() != rr {
+		t.Error("Expected Unwrap to return the underlying writer")
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("weather_http_requests_total",
+		"API requests counted towards the SLO, by status code.", "code")
+	httpDuration = metrics.NewHistogram("weather_http_request_duration_seconds",
+		"Latency of API requests counted towards the SLO.")
+
+	// apiSLO is created on first use, as its objectives come from config.
+	apiSLO     *metrics.SLO
+	apiSLOOnce sync.Once
+)
+
+func getAPISLO(cfg config.SLOConfig) *metrics.SLO {
+	apiSLOOnce.Do(func() {
+		apiSLO = metrics.NewSLO("weather_http", metrics.SLOObjectives{
+			Availability:  cfg.Availability,
+			LatencyTarget: cfg.LatencyTarget,
+			Latency:       cfg.Latency,
+		})
+	})
+	return apiSLO
+}
+
+// MetricsMiddleware returns an HTTP middleware that records the status and latency of requests to
+// the routes in slo.routes, feeding the request metrics and the SLO burn-rate gauges exported at
+// /metrics. 5xx responses and panics count against availability; everything else, including
+// 4xx and 429, counts as available.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetSLOConfig()
+	slo := getAPISLO(cfg)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(cfg.Routes, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &statusResponseWriter{ResponseWriter: w}
+		defer func() {
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			rec := recover()
+			if rec != nil {
+				status = http.StatusInternalServerError
+			}
+			elapsed := time.Since(start)
+			httpRequests.WithLabelValues(strconv.Itoa(status)).Inc()
+			httpDuration.Observe(elapsed.Seconds())
+			slo.Record(status < http.StatusInternalServerError, elapsed)
+			if rec != nil {
+				panic(rec)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	h := MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("case") {
+		case "error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "panic":
+			panic("boom")
+		case "limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	before := apiSLO.Window(5 * time.Minute)
+	ok := httpRequests.WithLabelValues("200").Value()
+	serverErrors := httpRequests.WithLabelValues("500").Value()
+
+	for _, target := range []string{"/weather", "/weather?case=error", "/v1/weather?case=limited", "/metrics", "/status?case=error"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to propagate to the recovery middleware")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather?case=panic", nil))
+	}()
+
+	after := apiSLO.Window(5 * time.Minute)
+	if got := after.Requests - before.Requests; got != 4 {
+		t.Errorf("Expected only the 4 SLO route requests counted, got %d", got)
+	}
+	failed := func(s float64, n uint64) float64 { return (1 - s) * float64(n) }
+	if got := failed(after.Availability, after.Requests) - failed(before.Availability, before.Requests); got < 1.99 || got > 2.01 {
+		t.Errorf("Expected the 502 and the panic to count as failures, got %v", got)
+	}
+	if httpRequests.WithLabelValues("200").Value() != ok+1 || httpRequests.WithLabelValues("500").Value() != serverErrors+1 {
+		t.Error("Expected request counter to record status codes, including 500 for the panic")
+	}
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// recordingResponseWriter passes writes through to the client while keeping a copy of the status and,
+// up to limit bytes when limit is positive, the body. truncated is set when the body is longer.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	switch {
+	case rw.truncated:
+	case rw.limit > 0 && rw.body.Len()+len(b) > rw.limit:
+		// A partial body would only show up as differences, so none is kept
+		rw.body = bytes.Buffer{}
+		rw.truncated = true
+	default:
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. for flushing).
+func (rw *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// mirror replays sampled requests against a canary deployment and logs any response differences.
+type mirror struct {
+	cfg    config.MirrorConfig
+	client *http.Client
+	slots  chan struct{}
+	sample func() bool
+	// done is invoked after each mirrored comparison finishes. Used primarily for testing.
+	done func(diffs []string)
+}
+
+// MirrorMiddleware returns an HTTP middleware that asynchronously replays a configurable percentage of
+// GET requests to the canary base URL (mirror.target_url) and logs differences between the primary and
+// canary responses. The client always receives the primary response; canary failures never affect it.
+// Paths in mirror.skip_paths are not mirrored, nor responses longer than mirror.max_body_bytes.
+// When mirroring is disabled, next is returned unchanged.
+func MirrorMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetMirrorConfig()
+	if !cfg.Enabled {
+		return next
+	}
+	return newMirror(cfg).wrap(next)
+}
+
+func newMirror(cfg config.MirrorConfig) *mirror {
+	return &mirror{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		slots:  make(chan struct{}, cfg.MaxInFlight),
+		sample: func() bool { return rand.Float64()*100 < cfg.Percentage },
+	}
+}
+
+func (m *mirror) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || slices.Contains(m.cfg.SkipPaths, r.URL.Path) || !m.sample() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recordingResponseWriter{ResponseWriter: w, limit: m.cfg.MaxBodyBytes}
+		next.ServeHTTP(rec, r)
+		if rec.truncated {
+			logger().Debugw("Response too long to mirror", "path", r.URL.Path)
+			return
+		}
+
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			logger().Debugw("Mirror queue full, dropping request", "path", r.URL.Path)
+			return
+		}
+		header := r.Header.Clone()
+		uri := r.URL.RequestURI()
+		status, body := rec.status, rec.body.Bytes()
+		go func() {
+			defer func() { <-m.slots }()
+			m.compare(uri, header, status, body)
+		}()
+	})
+}
+
+// compare sends the request to the canary and logs the differences against the primary response.
+func (m *mirror) compare(uri string, header http.Header, primaryStatus int, primaryBody []byte) {
+	var diffs []string
+	defer func() {
+		if m.done != nil {
+			m.done(diffs)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(m.cfg.TargetURL, "/")+uri, nil)
+	if err != nil {
+		logger().Warnw("Mirror request build failed", "uri", uri, "error", err)
+		return
+	}
+	req.Header = header
+	req.Header.Set("X-Mirrored-Request", "true")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		diffs = []string{"canary request failed: " + err.Error()}
+		logger().Warnw("Mirror request failed", "uri", uri, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	canaryBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		diffs = []string{"canary body unreadable: " + err.Error()}
+		logger().Warnw("Mirror response unreadable", "uri", uri, "error", err)
+		return
+	}
+
+	if resp.StatusCode != primaryStatus {
+		diffs = append(diffs, fmt.Sprintf("status: primary=%d canary=%d", primaryStatus, resp.StatusCode))
+	}
+	diffs = append(diffs, diffJSON(primaryBody, canaryBody, m.cfg.IgnoreFields)...)
+
+	if len(diffs) > 0 {
+		logger().Warnw("Mirror response differs", "uri", uri, "diffs", diffs)
+	} else {
+		logger().Debugw("Mirror response matches", "uri", uri)
+	}
+}
+
+// diffJSON returns the paths at which two JSON documents differ, skipping keys listed in ignore.
+// Bodies that are not valid JSON are compared byte for byte.
+func diffJSON(a, b []byte, ignore []string) []string {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		if !bytes.Equal(a, b) {
+			return []string{"body: non-JSON bodies differ"}
+		}
+		return nil
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, k := range ignore {
+		ignored[k] = true
+	}
+	var diffs []string
+	diffValues("$", va, vb, ignored, &diffs)
+	return diffs
+}
+
+func diffValues(path string, a, b interface{}, ignored map[string]bool, diffs *[]string) {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if okA && okB {
+		keys := make(map[string]struct{}, len(ma)+len(mb))
+		for k := range ma {
+			keys[k] = struct{}{}
+		}
+		for k := range mb {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			if !ignored[k] {
+				sorted = append(sorted, k)
+			}
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			diffValues(path+"."+k, ma[k], mb[k], ignored, diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: primary=%v canary=%v", path, a, b))
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestDiffJSON(t *testing.T) {
+	a := []byte(`{"data":{"location":"London","temperature":15.2,"cached":true},"message":"Success"}`)
+	b := []byte(`{"data":{"location":"London","temperature":16.0,"cached":false},"message":"Success"}`)
+
+	diffs := diffJSON(a, b, []string{"cached"})
+	if len(diffs) != 1 || !strings.HasPrefix(diffs[0], "$.data.temperature") {
+		t.Errorf("Expected only a temperature diff, got %v", diffs)
+	}
+
+	if diffs := diffJSON(a, a, nil); len(diffs) != 0 {
+		t.Errorf("Expected no diffs for identical bodies, got %v", diffs)
+	}
+	if diffs := diffJSON([]byte("ok"), []byte("nope"), nil); len(diffs) != 1 {
+		t.Errorf("Expected a non-JSON diff, got %v", diffs)
+	}
+}
+
+func TestMirrorMiddleware_DisabledReturnsNext(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := MirrorMiddleware(next)
+	if _, ok := h.(http.HandlerFunc); !ok {
+		t.Errorf("Expected the next handler to be returned unchanged when mirroring is disabled")
+	}
+}
+
+func TestMirror_ReplaysAndRecordsDiffs(t *testing.T) {
+	canaryHits := make(chan *http.Request, 1)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		canaryHits <- r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"location":"London","temperature":20},"message":"Success"}`))
+	}))
+	defer canary.Close()
+
+	m := newMirror(config.MirrorConfig{
+		Enabled:     true,
+		TargetURL:   canary.URL,
+		Percentage:  100,
+		Timeout:     time.Second,
+		MaxInFlight: 1,
+	})
+	m.sample = func() bool { return true }
+	diffsCh := make(chan []string, 1)
+	m.done = func(diffs []string) { diffsCh <- diffs }
+
+	primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"location":"London","temperature":15},"message":"Success"}`))
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	m.wrap(primary).ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), `"temperature":15`) {
+		t.Errorf("Expected the client to receive the primary response, got %s", rr.Body.String())
+	}
+
+	select {
+	case r := <-canaryHits:
+		if r.URL.RequestURI() != "/weather?location=London" || r.Header.Get("X-Mirrored-Request") != "true" {
+			t.Errorf("Unexpected mirrored request %s headers=%v", r.URL.RequestURI(), r.Header)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the canary to receive the mirrored request")
+	}
+
+	select {
+	case diffs := <-diffsCh:
+		if len(diffs) != 1 || !strings.Contains(diffs[0], "temperature") {
+			t.Errorf("Expected a temperature diff, got %v", diffs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the comparison to complete")
+	}
+}
+
+func TestMirror_SkipsNonGETAndUnsampled(t *testing.T) {
+	m := newMirror(config.MirrorConfig{Enabled: true, TargetURL: "http://127.0.0.1:0", MaxInFlight: 1, Timeout: time.Second})
+	m.sample = func() bool { return false }
+	called := false
+	m.done = func([]string) { called = true }
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	m.wrap(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather", nil))
+	m.sample = func() bool { return true }
+	m.wrap(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/weather", nil))
+
+	time.Sleep(50 * time.Millisecond)
+	if called {
+		t.Error("Expected no mirrored request for unsampled or non-GET requests")
+	}
+}
+
+func TestMirror_SkipsStreamedAndLongResponses(t *testing.T) {
+	m := newMirror(config.MirrorConfig{Enabled: true, TargetURL: "http://127.0.0.1:0", MaxInFlight: 2, Timeout: time.Second,
+		SkipPaths: []string{"/weather/poll"}, MaxBodyBytes: 16})
+	m.sample = func() bool { return true }
+	called := false
+	m.done = func([]string) { called = true }
+
+	flushed := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":"a response longer than the cap"}`))
+		flushed = http.NewResponseController(w).Flush() == nil
+	})
+	rr := httptest.NewRecorder()
+	m.wrap(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	m.wrap(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather/poll?location=London", nil))
+
+	time.Sleep(50 * time.Millisecond)
+	if called {
+		t.Error("Expected no mirrored request for a skipped path or a response over the cap")
+	}
+	if !flushed || !rr.Flushed || !strings.Contains(rr.Body.String(), "longer than the cap") {
+		t.Errorf("Expected the whole response flushed to the client, got flushed=%v %s", flushed, rr.Body)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build perf
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+	"github.com/spf13/viper"
+)
+
+// discardWriter drops the response, so only admission is timed.
+type discardWriter struct{ header http.Header }
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+func TestPerf_LimiterAdmission(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	useFakeRateLimitClock(t)
+	// Cache hits are only checked against the global and per-param limits, never charged, so
+	// every request is admitted however many are timed.
+	viper.Set("rate_limiter.cached.enabled", true)
+	viper.Set("rate_limiter.cached.rate", 0)
+	t.Cleanup(func() {
+		viper.Set("rate_limiter.cached.enabled", nil)
+		viper.Set("rate_limiter.cached.rate", nil)
+	})
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	w := &discardWriter{header: http.Header{}}
+
+	mw.ServeHTTP(w, req)
+	testutil.CheckPerf(t, "LimiterAdmission", func() { mw.ServeHTTP(w, req) })
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/priority"
+)
+
+// PriorityHeader lets a client lower the priority of its own requests, e.g. for batch jobs.
+const PriorityHeader = "X-Priority"
+
+var shedRequests = metrics.NewCounterVec("weather_requests_shed_total",
+	"Requests rejected with 503 because the concurrency limit for their priority was reached.", "priority")
+
+// PriorityMiddleware classifies each request and sheds load when too many are in flight.
+//
+// The class comes from the API key's tier via priority.tiers, else priority.default. X-Priority
+// may lower it but never raise it. With priority.max_in_flight set, a request is rejected with a
+// 503 when the number in flight has reached its class's share of the limit, so low-priority
+// requests are shed first and high-priority ones last. The class is stored in the request context
+// for the upstream budget (see repository.CachePolicyFrom).
+func PriorityMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetPriorityConfig()
+	limits := classLimits(cfg)
+	var inFlight atomic.Int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := requestClass(r, cfg)
+		if cfg.MaxInFlight > 0 {
+			if inFlight.Add(1) > limits[class] {
+				inFlight.Add(-1)
+				shedRequests.WithLabelValues(class.String()).Inc()
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable, "Server is busy, please retry", "Service Unavailable")
+				return
+			}
+			defer inFlight.Add(-1)
+		}
+		next.ServeHTTP(w, r.WithContext(priority.WithClass(r.Context(), class)))
+	})
+}
+
+// classLimits returns the in-flight limit for each class. Every class may use at least one slot.
+func classLimits(cfg config.PriorityConfig) map[priority.Class]int64 {
+	share := func(s float64) int64 { return max(1, int64(math.Ceil(float64(cfg.MaxInFlight)*s))) }
+	return map[priority.Class]int64{
+		priority.Low:    share(cfg.LowShare),
+		priority.Normal: share(cfg.NormalShare),
+		priority.High:   int64(cfg.MaxInFlight),
+	}
+}
+
+// requestClass derives the priority of r from its API key tier and X-Priority header.
+func requestClass(r *http.Request, cfg config.PriorityConfig) priority.Class {
+	class, _ := priority.Parse(cfg.Default)
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		if tierClass, ok := priority.Parse(cfg.Tiers[strings.ToLower(key.Tier)]); ok {
+			class = tierClass
+		}
+	}
+	if requested, ok := priority.Parse(r.Header.Get(PriorityHeader)); ok && requested < class {
+		class = requested
+	}
+	return class
+}
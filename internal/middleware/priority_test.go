@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/priority"
+	"github.com/spf13/viper"
+)
+
+func TestRequestClass(t *testing.T) {
+	cfg := config.PriorityConfig{Default: "normal", Tiers: map[string]string{"free": "low", "pro": "high"}}
+	tests := []struct {
+		name   string
+		tier   string
+		header string
+		want   priority.Class
+	}{
+		{"no key", "", "", priority.Normal},
+		{"free tier", "Free", "", priority.Low},
+		{"paying tier", "pro", "", priority.High},
+		{"unknown tier", "gold", "", priority.Normal},
+		{"header lowers", "pro", "low", priority.Low},
+		{"header cannot raise", "free", "high", priority.Low},
+		{"invalid header", "pro", "urgent", priority.High},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/weather", nil)
+			if tt.tier != "" {
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, config.APIKey{Name: "k", Tier: tt.tier}))
+			}
+			if tt.header != "" {
+				r.Header.Set(PriorityHeader, tt.header)
+			}
+			if got := requestClass(r, cfg); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClassLimits(t *testing.T) {
+	limits := classLimits(config.PriorityConfig{MaxInFlight: 10, NormalShare: 0.9, LowShare: 0.6})
+	if limits[priority.Low] != 6 || limits[priority.Normal] != 9 || limits[priority.High] != 10 {
+		t.Errorf("Unexpected limits %v", limits)
+	}
+	if limits := classLimits(config.PriorityConfig{MaxInFlight: 1, NormalShare: 0.1, LowShare: 0.1}); limits[priority.Low] != 1 {
+		t.Errorf("Expected at least one slot per class, got %v", limits)
+	}
+}
+
+func TestPriorityMiddleware_ShedsLowPriorityFirst(t *testing.T) {
+	viper.Set("priority.max_in_flight", 2)
+	viper.Set("priority.low_share", 0.5)
+	defer viper.Set("priority.max_in_flight", nil)
+	defer viper.Set("priority.low_share", nil)
+
+	release := make(chan struct{})
+	started := make(chan priority.Class, 1)
+	h := PriorityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- priority.FromContext(r.Context())
+		<-release
+	}))
+
+	// One normal request in flight uses the only slot available to low priority
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather", nil))
+	}()
+	if class := <-started; class != priority.Normal {
+		t.Errorf("Expected the class in the context, got %v", class)
+	}
+
+	low := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	low.Header.Set(PriorityHeader, "low")
+	w := httptest.NewRecorder()
+	shed := shedRequests.WithLabelValues("low").Value()
+	h.ServeHTTP(w, low)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected the low-priority request shed, got %d", w.Code)
+	}
+	if shedRequests.WithLabelValues("low").Value() != shed+1 {
+		t.Error("Expected the shed counter to grow")
+	}
+
+	// A normal request still fits
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather", nil))
+	}()
+	<-started
+	close(release)
+	wg.Wait()
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, low)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected slots released after completion, got %d", w.Code)
+	}
+}
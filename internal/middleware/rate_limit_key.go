@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// KeyExtractor derives one part of the rate limiting key from a request. ok is false when the
+// request carries no value for it, e.g. an anonymous request for the "api_key" extractor.
+type KeyExtractor func(r *http.Request) (key string, ok bool)
+
+// headerKeyPrefix selects a header extractor in rate_limiter.key, as in "header:X-Client-ID".
+const headerKeyPrefix = "header:"
+
+var (
+	keyExtractorsMu sync.RWMutex
+	keyExtractors   = map[string]KeyExtractor{
+		"ip": func(r *http.Request) (string, bool) {
+			ip := getIP(r)
+			return ip, ip != ""
+		},
+		"api_key": func(r *http.Request) (string, bool) {
+			key, ok := APIKeyFromContext(r.Context())
+			return key.Name, ok
+		},
+	}
+)
+
+// RegisterKeyExtractor makes e available to rate_limiter.key under name, replacing any extractor
+// already registered with that name.
+func RegisterKeyExtractor(name string, e KeyExtractor) {
+	keyExtractorsMu.Lock()
+	defer keyExtractorsMu.Unlock()
+	keyExtractors[name] = e
+}
+
+// headerKeyExtractor keys requests by the value of a client-supplied header.
+func headerKeyExtractor(header string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		v := strings.TrimSpace(r.Header.Get(header))
+		return v, v != ""
+	}
+}
+
+// newRateLimitKey combines the extractors named in strategies into a single key function. Parts a
+// request has no value for are left out, and a request with no parts at all is keyed by client IP,
+// so anonymous clients never share one bucket. Unknown names are logged and ignored.
+func newRateLimitKey(strategies []string) func(r *http.Request) string {
+	keyExtractorsMu.RLock()
+	defer keyExtractorsMu.RUnlock()
+	type part struct {
+		name    string
+		extract KeyExtractor
+	}
+	var parts []part
+	for _, name := range strategies {
+		if header, ok := strings.CutPrefix(name, headerKeyPrefix); ok && header != "" {
+			parts = append(parts, part{name, headerKeyExtractor(header)})
+			continue
+		}
+		e, ok := keyExtractors[name]
+		if !ok {
+			logger().Warnw("Unknown rate limit key, ignoring", "key", name)
+			continue
+		}
+		parts = append(parts, part{name, e})
+	}
+	return func(r *http.Request) string {
+		var b strings.Builder
+		for _, p := range parts {
+			v, ok := p.extract(r)
+			if !ok {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteByte('|')
+			}
+			b.WriteString(p.name)
+			b.WriteByte('=')
+			b.WriteString(v)
+		}
+		if b.Len() == 0 {
+			return "ip=" + getIP(r)
+		}
+		return b.String()
+	}
+}
+
+// rateLimitKey builds the key function configured by rate_limiter.key.
+func rateLimitKey() func(r *http.Request) string {
+	return newRateLimitKey(config.GetRateLimitKey())
+}
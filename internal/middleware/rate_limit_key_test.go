@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/spf13/viper"
+)
+
+func TestNewRateLimitKey(t *testing.T) {
+	withKey := func(r *http.Request) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, config.APIKey{Name: "team-a"}))
+	}
+	tests := []struct {
+		name       string
+		strategies []string
+		prepare    func(r *http.Request) *http.Request
+		want       string
+	}{
+		{"ip", []string{"ip"}, nil, "ip=192.0.2.1"},
+		{"api key", []string{"api_key"}, withKey, "api_key=team-a"},
+		{"anonymous falls back to ip", []string{"api_key"}, nil, "ip=192.0.2.1"},
+		{"header", []string{"header:X-Client-ID"}, func(r *http.Request) *http.Request {
+			r.Header.Set("X-Client-ID", " kiosk-7 ")
+			return r
+		}, "header:X-Client-ID=kiosk-7"},
+		{"combination", []string{"api_key", "ip"}, withKey, "api_key=team-a|ip=192.0.2.1"},
+		{"unknown ignored", []string{"cookie", "ip"}, nil, "ip=192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/weather", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.prepare != nil {
+				r = tt.prepare(r)
+			}
+			if got := newRateLimitKey(tt.strategies)(r); got != tt.want {
+				t.Errorf("Expected key %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRegisterKeyExtractor(t *testing.T) {
+	RegisterKeyExtractor("tenant", func(r *http.Request) (string, bool) {
+		return r.URL.Query().Get("tenant"), r.URL.Query().Has("tenant")
+	})
+	r := httptest.NewRequest(http.MethodGet, "/weather?tenant=acme", nil)
+	if got := newRateLimitKey([]string{"tenant"})(r); got != "tenant=acme" {
+		t.Errorf("Expected the registered extractor used, got %q", got)
+	}
+}
+
+func TestRateLimitMiddleware_HeaderKey(t *testing.T) {
+	ResetVisitors()
+	viper.Set("rate_limiter.key", []string{"header:X-Client-ID"})
+	defer viper.Set("rate_limiter.key", nil)
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	_, burst := config.GetParamRateLimiterConfig()
+
+	// Clients behind one NAT address get separate buckets
+	for _, client := range []string{"a", "b"} {
+		for i := 0; i < burst; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/weather?location=Lima", nil)
+			req.RemoteAddr = "203.0.113.5:1000"
+			req.Header.Set("X-Client-ID", client)
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected client %s request %d allowed, got %d", client, i+1, w.Code)
+			}
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
 	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
 	"golang.org/x/time/rate"
 )
 
@@ -21,6 +28,10 @@ func SetParamKey(key string) {
 	paramKey = key
 }
 
+// rateLimitClock refills the limiters and ages visitors. Tests replace it with a clock.Fake to
+// refill a limiter or expire a visitor without waiting.
+var rateLimitClock clock.Clock = clock.Real
+
 // the visitor holds the rate limiter and last seen time for a specific IP address.
 type visitor struct {
 	limiter  *rate.Limiter
@@ -35,111 +46,137 @@ type paramVisitor struct {
 
 var (
 	// globalVisitors maps IP addresses to their corresponding visitor struct for global rate limiting.
-	globalVisitors = make(map[string]*visitor) // key: ip
+	globalVisitors = newShardedMap[*visitor]() // key: ip
 	// paramVisitors maps IP addresses and parameter values to their corresponding paramVisitor struct for per-param rate limiting.
-	paramVisitors = make(map[string]map[string]*paramVisitor) // key: ip -> paramValue -> visitor
-	muGlobal      sync.Mutex
-	muParam       sync.Mutex
+	paramVisitors = newShardedMap[map[string]*paramVisitor]() // key: ip -> paramValue -> visitor
+	// refreshVisitors maps IP addresses to their visitor struct for force-refresh (?refresh=true) rate limiting.
+	refreshVisitors = newShardedMap[*visitor]() // key: ip
+	// cachedVisitors maps IP addresses to their visitor struct for requests served from cache.
+	cachedVisitors = newShardedMap[*visitor]() // key: ip
 )
 
-// GetGlobalLimiter returns the rate limiter for the given IP address, creating one if it does not exist.
-// The global limiter allows a configurable number of requests per minute with a configurable burst.
+var cachedRequests = metrics.NewCounter("weather_rate_limit_cached_requests_total",
+	"Requests answered without an upstream call, charged to the cached-response limit only.")
+
+// GetGlobalLimiter returns the rate limiter for the given client key (see KeyExtractor), creating one if it does not exist.
+// The global limiter allows a configurable number of requests per minute with a configurable burst,
+// scaled down by the adaptive factor while the upstream provider is unhealthy.
 func GetGlobalLimiter(ip string) *rate.Limiter {
-	muGlobal.Lock()
-	defer muGlobal.Unlock()
-	v, exists := globalVisitors[ip]
-	if !exists {
-		r, burst := config.GetGlobalRateLimiterConfig()
-		limiter := rate.NewLimiter(rate.Limit(r/60.0), burst)
-		globalVisitors[ip] = &visitor{limiter, time.Now()}
-		return limiter
-	}
-	v.lastSeen = time.Now()
-	return v.limiter
+	return touchVisitor(globalVisitors, ip, adaptiveGlobalLimits)
 }
 
-// getParamLimiter returns the rate limiter for the given IP address and parameter value, creating one if it does not exist.
+// getParamLimiter returns the rate limiter for the given client key and parameter value, creating one if it does not exist.
 // The per-param limiter allows a configurable number of requests per minute with a configurable burst.
 func getParamLimiter(ip, param string) *rate.Limiter {
-	muParam.Lock()
-	defer muParam.Unlock()
-	if _, ok := paramVisitors[ip]; !ok {
-		paramVisitors[ip] = make(map[string]*paramVisitor)
-	}
-	v, exists := paramVisitors[ip][param]
+	var limiter *rate.Limiter
+	paramVisitors.update(ip, func(params map[string]*paramVisitor, ok bool) map[string]*paramVisitor {
+		if !ok {
+			params = make(map[string]*paramVisitor)
+		}
+		v, exists := params[param]
+		if !exists {
+			r, burst := config.GetParamRateLimiterConfig()
+			v = &paramVisitor{rate.NewLimiter(rate.Limit(r/60.0), burst), rateLimitClock.Now()}
+			params[param] = v
+		} else {
+			v.lastSeen = rateLimitClock.Now()
+		}
+		limiter = v.limiter
+		return params
+	})
+	return limiter
+}
+
+// getRefreshLimiter returns the force-refresh rate limiter for the given client key, creating one if it does not exist.
+// Refresh requests bypass the cache and always hit the upstream provider, so their limit is stricter than the global one.
+func getRefreshLimiter(ip string) *rate.Limiter {
+	return touchVisitor(refreshVisitors, ip, config.GetRefreshRateLimiterConfig)
+}
+
+// getCachedLimiter returns the rate limiter for requests the cache will answer for the given client
+// key, creating one if it does not exist.
+func getCachedLimiter(ip string) *rate.Limiter {
+	return touchVisitor(cachedVisitors, ip, func() (float64, int) {
+		cfg := config.GetCachedRateLimitConfig()
+		return cfg.Rate, cfg.Burst
+	})
+}
+
+// touchVisitor returns the limiter stored for ip in visitors, creating it from limits if it does not exist,
+// and marks the visitor as seen.
+func touchVisitor(visitors *shardedMap[*visitor], ip string, limits func() (float64, int)) *rate.Limiter {
+	sh := visitors.shard(ip)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	v, exists := sh.m[ip]
 	if !exists {
-		r, burst := config.GetParamRateLimiterConfig()
-		limiter := rate.NewLimiter(rate.Limit(r/60.0), burst)
-		paramVisitors[ip][param] = &paramVisitor{limiter, time.Now()}
-		return limiter
+		r, burst := limits()
+		v = &visitor{rate.NewLimiter(rate.Limit(r/60.0), burst), rateLimitClock.Now()}
+		sh.m[ip] = v
+		return v.limiter
 	}
-	v.lastSeen = time.Now()
+	v.lastSeen = rateLimitClock.Now()
 	return v.limiter
 }
 
-// cleanupGlobalVisitorsOnce removes globalVisitors entries that have not been seen for over the configured cleanup timeout.
+// cleanupGlobalVisitorsOnce removes globalVisitors, refreshVisitors and cachedVisitors entries that have not been seen for over the configured cleanup timeout.
 func cleanupGlobalVisitorsOnce() {
 	timeout := config.GetRateLimiterCleanupTimeout()
-	muGlobal.Lock()
-	for ip, v := range globalVisitors {
-		if time.Since(v.lastSeen) > timeout {
-			delete(globalVisitors, ip)
-		}
-	}
-	muGlobal.Unlock()
+	keep := func(_ string, v *visitor) bool { return rateLimitClock.Since(v.lastSeen) <= timeout }
+	globalVisitors.prune(keep)
+	refreshVisitors.prune(keep)
+	cachedVisitors.prune(keep)
 }
 
 // cleanupParamVisitorsOnce removes paramVisitors entries that have not been seen for over the configured cleanup timeout.
 func cleanupParamVisitorsOnce() {
 	timeout := config.GetRateLimiterCleanupTimeout()
-	muParam.Lock()
-	for ip, paramMap := range paramVisitors {
+	paramVisitors.prune(func(_ string, paramMap map[string]*paramVisitor) bool {
 		for param, v := range paramMap {
-			if time.Since(v.lastSeen) > timeout {
+			if rateLimitClock.Since(v.lastSeen) > timeout {
 				delete(paramMap, param)
 			}
 		}
-		if len(paramMap) == 0 {
-			delete(paramVisitors, ip)
-		}
-	}
-	muParam.Unlock()
+		return len(paramMap) > 0
+	})
 }
 
-// cleanupGlobalVisitors periodically removes globalVisitors entries that have not been seen for over the configured cleanup timeout.
-func cleanupGlobalVisitors() {
-	for {
-		time.Sleep(time.Minute)
+// StartRateLimiterCleanup schedules the "rate_limiter_cleanup" job, which removes stale visitors from both
+// global and per-param limiters every minute, until the returned function is called.
+func StartRateLimiterCleanup() (stop func()) {
+	return jobs.New("rate_limiter_cleanup", time.Minute, func(context.Context) error {
 		cleanupGlobalVisitorsOnce()
-	}
-}
-
-// cleanupParamVisitors periodically removes paramVisitors entries that have not been seen for over the configured cleanup timeout.
-func cleanupParamVisitors() {
-	for {
-		time.Sleep(time.Minute)
 		cleanupParamVisitorsOnce()
-	}
+		return nil
+	}).Schedule(false)
 }
 
-// StartRateLimiterCleanup starts background goroutines to clean up stale visitors for both global and per-param limiters.
-func StartRateLimiterCleanup() {
-	go cleanupGlobalVisitors()
-	go cleanupParamVisitors()
+// ReleaseIdleVisitors frees the memory of every visitor whose limiters are full. Such a visitor is
+// indistinguishable from a new one, so dropping it loses no rate limiting state. It is called
+// under memory pressure, in addition to the periodic cleanup of visitors unseen for a while.
+func ReleaseIdleVisitors() {
+	now := rateLimitClock.Now()
+	full := func(l *rate.Limiter) bool { return l == nil || l.TokensAt(now) >= float64(l.Burst()) }
+	keep := func(_ string, v *visitor) bool { return !full(v.limiter) }
+	globalVisitors.shrink(keep)
+	refreshVisitors.shrink(keep)
+	cachedVisitors.shrink(keep)
+	paramVisitors.shrink(func(_ string, params map[string]*paramVisitor) bool {
+		for param, v := range params {
+			if full(v.limiter) {
+				delete(params, param)
+			}
+		}
+		return len(params) > 0
+	})
 }
 
 // ResetVisitors clears all visitor states for both global and per-param limiters. Used primarily for testing.
 func ResetVisitors() {
-	muGlobal.Lock()
-	for k := range globalVisitors {
-		delete(globalVisitors, k)
-	}
-	muGlobal.Unlock()
-	muParam.Lock()
-	for k := range paramVisitors {
-		delete(paramVisitors, k)
-	}
-	muParam.Unlock()
+	globalVisitors.reset()
+	paramVisitors.reset()
+	refreshVisitors.reset()
+	cachedVisitors.reset()
 }
 
 // getIP extracts the client's IP address from the HTTP request, considering X-Forwarded-For headers.
@@ -160,40 +197,138 @@ func getParam(r *http.Request) string {
 	return r.URL.Query().Get(paramKey)
 }
 
+// isRefreshRequest reports whether the request asks to bypass the cache with ?refresh=true or
+// Cache-Control: no-cache. only-if-cached takes precedence over no-cache, as in the weather handler.
+func isRefreshRequest(r *http.Request) bool {
+	if refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh")); refresh {
+		return true
+	}
+	noCache := false
+	for _, v := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			directive, _, _ = strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(directive, "only-if-cached") {
+				return false
+			}
+			noCache = noCache || strings.EqualFold(directive, "no-cache")
+		}
+	}
+	return noCache
+}
+
 // RateLimitMiddleware returns an HTTP middleware that enforces global and per-parameter rate limiting.
-// If the rate limit is exceeded, it responds with a 429 status and a JSON error message.
+// If the rate limit is exceeded, it responds with a 429 status and a JSON body describing the limit.
+// Clients are identified by rate_limiter.key (see KeyExtractor).
+//
+// With rate_limiter.cached.enabled, requests are charged by what they cost: every request is
+// charged to the rate_limiter.cached limit, unless its rate is 0, and only those that called the
+// provider are also charged to the global and per-parameter limits, once the handler has run.
+// Clients do not burn quota on data the cache answered. A client that has spent its global or
+// per-parameter limit is turned away up front. Refresh requests are always charged up front, and a
+// request one limit turns away is charged to none of them.
 func RateLimitMiddleware(next http.Handler) http.Handler {
+	clientKey := rateLimitKey()
+	cached := config.GetCachedRateLimitConfig()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIP(r)
+		client := clientKey(r)
 		param := getParam(r)
 		if param == "" {
 			// If param is missing, treat as a single bucket
 			param = "__none__"
 		}
-		globalLimiter := GetGlobalLimiter(ip)
-		paramLimiter := getParamLimiter(ip, param)
-		if !globalLimiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			errMsg := "Rate limit exceeded: max 10 requests per minute per user/IP"
-			resp := model.Response{
-				Error:   &errMsg,
-				Message: "Too Many Requests (global limit)",
+		globalLimiter := GetGlobalLimiter(client)
+		paramLimiter := getParamLimiter(client, param)
+		refresh := isRefreshRequest(r)
+		now := rateLimitClock.Now()
+		if cached.Enabled && !refresh {
+			if cached.Rate > 0 {
+				if cachedLimiter := getCachedLimiter(client); !cachedLimiter.AllowN(now, 1) {
+					writeRateLimited(w, "cached", "Too Many Requests (cached limit)", "cached requests per minute per client", cachedLimiter)
+					return
+				}
+			}
+			if globalLimiter.TokensAt(now) < 1 {
+				writeRateLimited(w, "global", "Too Many Requests (global limit)", "requests per minute per client", globalLimiter)
+				return
+			}
+			if paramLimiter.TokensAt(now) < 1 {
+				writeRateLimited(w, "param", "Too Many Requests (per-param limit)", "requests per minute per unique "+paramKey+" per client", paramLimiter)
+				return
 			}
-			_ = json.NewEncoder(w).Encode(resp)
+			ctx, tracker := upstream.WithTracker(r.Context())
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if !tracker.Called() {
+				cachedRequests.Inc()
+				return
+			}
+			// Charged at the time the handler finished, which a slow provider call has moved on
+			now = rateLimitClock.Now()
+			globalLimiter.ReserveN(now, 1)
+			paramLimiter.ReserveN(now, 1)
 			return
 		}
-		if !paramLimiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			errMsg := "Rate limit exceeded: max 2 requests per minute per unique param per user/IP"
-			resp := model.Response{
-				Error:   &errMsg,
-				Message: "Too Many Requests (per-param limit)",
+		// A request takes a token from every limiter or from none, so a rejected refresh does not
+		// spend the client's global or per-param tokens
+		var refreshLimiter *rate.Limiter
+		if refresh {
+			refreshLimiter = getRefreshLimiter(client)
+		}
+		switch rejected := allowAll(now, refreshLimiter, globalLimiter, paramLimiter); {
+		case rejected == nil:
+			next.ServeHTTP(w, r)
+		case rejected == refreshLimiter:
+			writeRateLimited(w, "refresh", "Too Many Requests (refresh limit)", "force-refresh requests per minute per client", refreshLimiter)
+		case rejected == globalLimiter:
+			writeRateLimited(w, "global", "Too Many Requests (global limit)", "requests per minute per client", globalLimiter)
+		default:
+			writeRateLimited(w, "param", "Too Many Requests (per-param limit)", "requests per minute per unique "+paramKey+" per client", paramLimiter)
+		}
+	})
+}
+
+// allowAll takes a token at now from each of limiters, skipping nil ones, or from none of them: it
+// returns the first limiter without a token, after giving back the tokens already taken, or nil
+// when every limiter had one.
+func allowAll(now time.Time, limiters ...*rate.Limiter) *rate.Limiter {
+	taken := make([]*rate.Reservation, 0, len(limiters))
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+		r := limiter.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			for _, t := range taken {
+				t.CancelAt(now)
 			}
-			_ = json.NewEncoder(w).Encode(resp)
-			return
+			return limiter
 		}
-		next.ServeHTTP(w, r)
+		taken = append(taken, r)
+	}
+	return nil
+}
+
+// writeRateLimited writes the 429 for the limiter that rejected a request. The limit is read from
+// the limiter itself, so the body reports what is enforced, adaptive scaling included.
+func writeRateLimited(w http.ResponseWriter, name, message, unit string, limiter *rate.Limiter) {
+	perMinute := float64(limiter.Limit()) * 60
+	retryAfter := 60.0
+	if limiter.Limit() > 0 {
+		retryAfter = max(0, (1-limiter.TokensAt(rateLimitClock.Now()))/float64(limiter.Limit()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter)))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	errMsg := fmt.Sprintf("Rate limit exceeded: max %g %s", perMinute, unit)
+	_ = json.NewEncoder(w).Encode(model.Response{
+		Error:   &errMsg,
+		Message: message,
+		RateLimit: &model.RateLimit{
+			Limiter:    name,
+			Limit:      perMinute,
+			Burst:      limiter.Burst(),
+			RetryAfter: math.Round(retryAfter*1000) / 1000,
+			DocsURL:    config.GetRateLimitDocsURL(),
+		},
 	})
 }
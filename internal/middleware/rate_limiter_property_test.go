@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
+)
+
+// The tests in this file check invariants of the rate limiters against generated request timings,
+// driven by a fake clock, rather than hand-picked cases.
+
+// quickConfig runs enough cases to hit bursts, idle gaps and refills at token boundaries.
+var quickConfig = &quick.Config{MaxCount: 200}
+
+// arrival is one request of a generated sequence.
+type arrival struct {
+	// Gap is the time since the previous request.
+	Gap time.Duration
+	// Location indexes a small set of locations, so the per-param limits are exercised too.
+	Location int
+	// Upstream makes the request call the provider rather than be answered from the cache.
+	Upstream bool
+}
+
+// arrivals is a generated request sequence from one client. Gaps are mostly zero or short, to
+// spend bursts, with some long enough to refill them, and land on whole and fractional seconds.
+type arrivals []arrival
+
+func (arrivals) Generate(r *rand.Rand, size int) reflect.Value {
+	seq := make(arrivals, 1+r.Intn(3*size+1))
+	for i := range seq {
+		var gap time.Duration
+		switch r.Intn(4) {
+		case 0:
+			gap = 0
+		case 1:
+			gap = time.Duration(r.Intn(1000)) * time.Millisecond
+		case 2:
+			gap = time.Duration(r.Intn(60)) * time.Second
+		default:
+			gap = time.Duration(r.Int63n(int64(3 * time.Minute)))
+		}
+		seq[i] = arrival{Gap: gap, Location: r.Intn(3), Upstream: r.Intn(2) == 0}
+	}
+	return reflect.ValueOf(seq)
+}
+
+// withinRate reports whether times, sorted, never hold more events in any window than a token
+// bucket of the given rate per minute and burst lets through: burst plus what refills over it.
+func withinRate(times []time.Time, perMinute float64, burst int) bool {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := range times {
+		for j := i; j < len(times); j++ {
+			refill := perMinute / 60 * times[j].Sub(times[i]).Seconds()
+			if float64(j-i+1) > float64(burst)+refill+1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// replay sends seq through mw from one client, advancing the clock by each gap, and returns when
+// each request was let through, overall and per location, and when those that called the
+// provider were.
+func replay(t *testing.T, seq arrivals) (all []time.Time, byLocation map[int][]time.Time, upstreamCalls []time.Time) {
+	t.Helper()
+	ResetVisitors()
+	c := useFakeRateLimitClock(t)
+	var current arrival
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if current.Upstream {
+			upstream.RecordCall(r.Context())
+		}
+	}))
+	byLocation = make(map[int][]time.Time)
+	for _, a := range seq {
+		c.Advance(a.Gap)
+		current = a
+		req := httptest.NewRequest("GET", "/weather?location=city"+strconv.Itoa(a.Location), nil)
+		req.RemoteAddr = "10.9.8.7:1234"
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			continue
+		}
+		now := c.Now()
+		all = append(all, now)
+		byLocation[a.Location] = append(byLocation[a.Location], now)
+		if a.Upstream {
+			upstreamCalls = append(upstreamCalls, now)
+		}
+	}
+	return all, byLocation, upstreamCalls
+}
+
+func TestRateLimitMiddleware_NeverExceedsRate(t *testing.T) {
+	SetParamKey("location")
+	globalRate, globalBurst := config.GetGlobalRateLimiterConfig()
+	paramRate, paramBurst := config.GetParamRateLimiterConfig()
+
+	property := func(seq arrivals) bool {
+		all, byLocation, _ := replay(t, seq)
+		if !withinRate(all, globalRate, globalBurst) {
+			return false
+		}
+		for _, times := range byLocation {
+			if !withinRate(times, paramRate, paramBurst) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRateLimitMiddleware_CachedNeverExceedsRate(t *testing.T) {
+	SetParamKey("location")
+	viper.Set("rate_limiter.cached.enabled", true)
+	viper.Set("rate_limiter.cached.rate", 20)
+	viper.Set("rate_limiter.cached.burst", 5)
+	defer viper.Set("rate_limiter.cached.enabled", nil)
+	defer viper.Set("rate_limiter.cached.rate", nil)
+	defer viper.Set("rate_limiter.cached.burst", nil)
+	globalRate, globalBurst := config.GetGlobalRateLimiterConfig()
+	cached := config.GetCachedRateLimitConfig()
+
+	// Every request is charged to the cached limit, and only provider calls to the global one
+	property := func(seq arrivals) bool {
+		all, _, upstreamCalls := replay(t, seq)
+		return withinRate(all, cached.Rate, cached.Burst) && withinRate(upstreamCalls, globalRate, globalBurst)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLimiter_RefillIsMonotonic(t *testing.T) {
+	property := func(spend []uint8, steps []uint16) bool {
+		ResetVisitors()
+		c := useFakeRateLimitClock(t)
+		limiter := GetGlobalLimiter("10.9.8.6")
+		for _, n := range spend {
+			limiter.AllowN(c.Now(), int(n%4))
+		}
+		// Without requests, tokens only grow, one step at a time, up to the burst
+		last := limiter.TokensAt(c.Now())
+		for _, ms := range steps {
+			c.Advance(time.Duration(ms) * time.Millisecond)
+			tokens := limiter.TokensAt(c.Now())
+			if tokens < last || tokens > float64(limiter.Burst()) {
+				return false
+			}
+			last = tokens
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdaptiveLimiter_FactorStaysInBounds(t *testing.T) {
+	cfg := config.AdaptiveRateLimitConfig{
+		MinRequests: 10, ErrorRate: 0.2, Latency: time.Second, TightenFactor: 0.5, MinFactor: 0.2, RelaxStep: 0.25,
+	}
+	configuredRate, configuredBurst := config.GetGlobalRateLimiterConfig()
+
+	// Whatever the provider does, the factor stays in [MinFactor, 1], and the scaled global limit
+	// never exceeds the configured one nor locks clients out
+	property := func(requests, errs []uint8) bool {
+		defer setRateLimitFactor(1)
+		var sample UpstreamSample
+		a := newAdaptiveLimiter(cfg, func() UpstreamSample { return sample })
+		for i, n := range requests {
+			sample.Requests += float64(n)
+			if i < len(errs) {
+				sample.Errors += float64(min(errs[i], n))
+			}
+			factor := a.evaluate()
+			if factor < cfg.MinFactor || factor > 1 {
+				return false
+			}
+			setRateLimitFactor(factor)
+			r, burst := adaptiveGlobalLimits()
+			if r > configuredRate || burst > configuredBurst || burst < 1 {
+				return false
+			}
+			if l := GetGlobalLimiter("10.9.8.5"); l.Limit() != rate.Limit(r/60.0) || l.Burst() != burst {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
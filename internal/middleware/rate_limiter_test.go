@@ -6,12 +6,26 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+	"github.com/spf13/viper"
 )
 
-// Note: The burst for both global and per-param is 2, so only 2 requests are allowed instantly.
-// The 3rd request is blocked unless you wait for token refill (not practical for unit tests).
+// useFakeRateLimitClock makes the rate limiters refill, and visitors age, only as the returned
+// clock is advanced.
+func useFakeRateLimitClock(t *testing.T) *clock.Fake {
+	t.Helper()
+	c := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	rateLimitClock = c
+	t.Cleanup(func() { rateLimitClock = clock.Real })
+	return c
+}
 
 func TestRateLimitMiddleware_GlobalBurst(t *testing.T) {
 	ResetVisitors()
@@ -86,37 +100,77 @@ func TestRateLimitMiddleware_PerParamBurst(t *testing.T) {
 	}
 }
 
+func TestRateLimitMiddleware_Refill(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	c := useFakeRateLimitClock(t)
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func() int {
+		req := httptest.NewRequest("GET", "/weather?location=London", nil)
+		req.RemoteAddr = "2.3.4.6:2345"
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The per-param limit is 2 per minute with a burst of 2
+	for i := 1; i <= 2; i++ {
+		if code := get(); code != http.StatusOK {
+			t.Fatalf("Expected request %d within the burst, got %d", i, code)
+		}
+	}
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the burst spent, got %d", code)
+	}
+	c.Advance(29 * time.Second)
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected no token before 30s, got %d", code)
+	}
+	c.Advance(time.Second)
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("Expected one token after 30s, got %d", code)
+	}
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected only one token after 30s, got %d", code)
+	}
+}
+
 func TestCleanupGlobalVisitors_RemovesStaleEntries(t *testing.T) {
 	ResetVisitors()
+	c := useFakeRateLimitClock(t)
 	ip := "9.8.7.6:9999"
-	limiter := &visitor{
-		limiter:  nil,
-		lastSeen: time.Now().Add(-2 * time.Second), // older than 1s timeout in config_test.yaml
+	GetGlobalLimiter(ip)
+	timeout := config.GetRateLimiterCleanupTimeout()
+
+	c.Advance(timeout)
+	cleanupGlobalVisitorsOnce()
+	if _, exists := globalVisitors.load(ip); !exists {
+		t.Fatal("Expected a visitor seen within the cleanup timeout to be kept")
 	}
-	globalVisitors[ip] = limiter
+	c.Advance(time.Millisecond)
 	cleanupGlobalVisitorsOnce()
-	muGlobal.Lock()
-	_, exists := globalVisitors[ip]
-	muGlobal.Unlock()
-	if exists {
+	if _, exists := globalVisitors.load(ip); exists {
 		t.Errorf("Expected global visitor to be cleaned up, but still exists")
 	}
 }
 
 func TestCleanupParamVisitors_RemovesStaleEntries(t *testing.T) {
 	ResetVisitors()
+	c := useFakeRateLimitClock(t)
 	ip := "8.7.6.5:8888"
-	param := "testparam"
-	limiter := &paramVisitor{
-		limiter:  nil,
-		lastSeen: time.Now().Add(-2 * time.Second), // older than 1s timeout in config_test.yaml
+	getParamLimiter(ip, "stale")
+	c.Advance(config.GetRateLimiterCleanupTimeout())
+	getParamLimiter(ip, "recent")
+
+	c.Advance(time.Millisecond)
+	cleanupParamVisitorsOnce()
+	params, exists := paramVisitors.load(ip)
+	if !exists || len(params) != 1 || params["recent"] == nil {
+		t.Fatalf("Expected only the recently seen param kept, got %v", params)
 	}
-	paramVisitors[ip] = map[string]*paramVisitor{param: limiter}
+	c.Advance(config.GetRateLimiterCleanupTimeout())
 	cleanupParamVisitorsOnce()
-	muParam.Lock()
-	_, exists := paramVisitors[ip]
-	muParam.Unlock()
-	if exists {
+	if _, exists := paramVisitors.load(ip); exists {
 		t.Errorf("Expected param visitor to be cleaned up, but still exists")
 	}
 }
@@ -125,3 +179,310 @@ func TestStartRateLimiterCleanup_DoesNotPanic(t *testing.T) {
 	// Just ensure it starts goroutines without panic
 	StartRateLimiterCleanup()
 }
+
+func TestRateLimitMiddleware_RefreshLimit(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RateLimitMiddleware(h)
+	ip := "3.4.5.6:3456"
+
+	// The refresh burst (1) allows a single force-refresh
+	req := httptest.NewRequest("GET", "/weather?location=Paris&refresh=true", nil)
+	req.RemoteAddr = ip
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+
+	// A second refresh for another city is blocked by the refresh limiter
+	req = httptest.NewRequest("GET", "/weather?location=Rome&refresh=true", nil)
+	req.RemoteAddr = ip
+	w = httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Result().StatusCode)
+	}
+	var resp map[string]interface{}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp["message"] != "Too Many Requests (refresh limit)" {
+		t.Errorf("expected refresh limit message, got %v", resp["message"])
+	}
+
+	// Plain requests are unaffected
+	req = httptest.NewRequest("GET", "/weather?location=Rome", nil)
+	req.RemoteAddr = ip
+	w = httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestRateLimitMiddleware_RejectionsSpendNoTokens(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	useFakeRateLimitClock(t)
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(target string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "3.4.5.7:3456"
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// One refresh is allowed; the rest are turned away by the refresh limit, and Paris by its
+	// per-param limit, without spending any of the 10 global tokens
+	if code := serve("/weather?location=Paris&refresh=true"); code != http.StatusOK {
+		t.Fatalf("Expected the first refresh allowed, got %d", code)
+	}
+	for i := 0; i < 5; i++ {
+		if code := serve(fmt.Sprintf("/weather?location=city%d&refresh=true", i)); code != http.StatusTooManyRequests {
+			t.Fatalf("Expected refresh %d turned away, got %d", i+2, code)
+		}
+	}
+	serve("/weather?location=Paris")
+	if code := serve("/weather?location=Paris"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected Paris turned away by its per-param limit, got %d", code)
+	}
+	for i := 0; i < 8; i++ {
+		if code := serve(fmt.Sprintf("/weather?location=town%d", i)); code != http.StatusOK {
+			t.Fatalf("Expected plain request %d allowed by the global tokens left, got %d", i+1, code)
+		}
+	}
+	if code := serve("/weather?location=town9"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the global limit spent after 10 allowed requests, got %d", code)
+	}
+}
+
+// upstreamUnlessParis is a handler that calls the provider for every location except Paris,
+// which the cache answers.
+var upstreamUnlessParis = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("location") != "Paris" {
+		upstream.RecordCall(r.Context())
+	}
+})
+
+func TestRateLimitMiddleware_CachedLimit(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	viper.Set("rate_limiter.cached.enabled", true)
+	viper.Set("rate_limiter.cached.rate", 60)
+	viper.Set("rate_limiter.cached.burst", 5)
+	defer func() {
+		viper.Set("rate_limiter.cached.enabled", nil)
+		viper.Set("rate_limiter.cached.rate", nil)
+		viper.Set("rate_limiter.cached.burst", nil)
+	}()
+	mw := RateLimitMiddleware(upstreamUnlessParis)
+	do := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "4.5.6.7:4567"
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w
+	}
+
+	// Uncached locations are charged to the per-location limit (burst 2) once served
+	for i := 0; i < 2; i++ {
+		if w := do("/weather?location=Rome"); w.Code != http.StatusOK {
+			t.Fatalf("uncached request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	if w := do("/weather?location=Rome"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the per-location limit, got %d", w.Code)
+	}
+
+	before := cachedRequests.Value()
+	// Every request is charged to the cached burst (5): cached ones are served with what is left
+	for i := 0; i < 2; i++ {
+		if w := do("/weather?location=Paris"); w.Code != http.StatusOK {
+			t.Fatalf("cached request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	w := do("/weather?location=Paris")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	var resp model.Response
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.RateLimit == nil || resp.RateLimit.Limiter != "cached" {
+		t.Errorf("expected the cached limiter to be named, got %+v", resp.RateLimit)
+	}
+	if got := cachedRequests.Value() - before; got != 2 {
+		t.Errorf("expected 2 cached requests counted, got %v", got)
+	}
+}
+
+func TestRateLimitMiddleware_CachedExempt(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	viper.Set("rate_limiter.cached.enabled", true)
+	viper.Set("rate_limiter.cached.rate", 0)
+	defer func() {
+		viper.Set("rate_limiter.cached.enabled", nil)
+		viper.Set("rate_limiter.cached.rate", nil)
+	}()
+	mw := RateLimitMiddleware(upstreamUnlessParis)
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest("GET", "/weather?location=Paris", nil)
+		req.RemoteAddr = "5.6.7.8:5678"
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+
+	// Refresh requests always call the provider and are charged normally
+	req := httptest.NewRequest("GET", "/weather?location=Paris&refresh=true", nil)
+	req.RemoteAddr = "5.6.7.8:5678"
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	req = httptest.NewRequest("GET", "/weather?location=Paris&refresh=true", nil)
+	req.RemoteAddr = "5.6.7.8:5678"
+	w = httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the refresh limit, got %d", w.Code)
+	}
+}
+
+func TestIsRefreshRequest(t *testing.T) {
+	tests := []struct {
+		target, cacheControl string
+		want                 bool
+	}{
+		{"/weather?location=Paris&refresh=true", "", true},
+		{"/weather?location=Paris", "no-cache", true},
+		{"/weather?location=Paris", "max-age=0, NO-CACHE", true},
+		{"/weather?location=Paris", "no-cache, only-if-cached", false},
+		{"/weather?location=Paris", "no-store", false},
+		{"/weather?location=Paris", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.cacheControl != "" {
+			req.Header.Set("Cache-Control", tt.cacheControl)
+		}
+		if got := isRefreshRequest(req); got != tt.want {
+			t.Errorf("isRefreshRequest(%s, Cache-Control: %s) = %v, want %v", tt.target, tt.cacheControl, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitMiddleware_StructuredBody(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ip := "4.5.6.7:4567"
+	r, burst := config.GetParamRateLimiterConfig()
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i <= burst; i++ {
+		req := httptest.NewRequest("GET", "/weather?location=Oslo", nil)
+		req.RemoteAddr = ip
+		w = httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	var resp model.Response
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	limit := resp.RateLimit
+	if limit == nil {
+		t.Fatal("expected a rate_limit object")
+	}
+	if limit.Limiter != "param" || limit.Limit != r || limit.Burst != burst {
+		t.Errorf("expected the configured param limit, got %+v", limit)
+	}
+	if limit.RetryAfter <= 0 || limit.RetryAfter > 60/r {
+		t.Errorf("expected the remaining window within one token interval, got %v", limit.RetryAfter)
+	}
+	if limit.DocsURL != config.GetRateLimitDocsURL() {
+		t.Errorf("expected the docs URL, got %q", limit.DocsURL)
+	}
+	if want := fmt.Sprintf("max %g requests per minute per unique location", r); !strings.Contains(*resp.Error, want) {
+		t.Errorf("expected %q in the error, got %q", want, *resp.Error)
+	}
+}
+
+func TestReleaseIdleVisitors(t *testing.T) {
+	ResetVisitors()
+	GetGlobalLimiter("idle")
+	busy := GetGlobalLimiter("busy")
+	busy.Allow()
+	getParamLimiter("idle", "London")
+	getParamLimiter("busy", "London").Allow()
+	getParamLimiter("busy", "Paris")
+
+	ReleaseIdleVisitors()
+	if _, ok := globalVisitors.load("idle"); ok {
+		t.Error("Expected the idle visitor released")
+	}
+	if _, ok := globalVisitors.load("busy"); !ok {
+		t.Error("Expected the visitor with spent tokens kept")
+	}
+	if _, ok := paramVisitors.load("idle"); ok {
+		t.Error("Expected the idle param visitor released")
+	}
+	if params, _ := paramVisitors.load("busy"); len(params) != 1 || params["London"] == nil {
+		t.Errorf("Expected only the busy param kept, got %v", params)
+	}
+}
+
+func TestRateLimitMiddleware_Concurrent(t *testing.T) {
+	ResetVisitors()
+	SetParamKey("location")
+	defer setRateLimitFactor(1)
+	mw := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream.RecordCall(r.Context())
+	}))
+
+	// Clients share visitors while the cleanup, the memory guard and adaptive scaling change them
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				req := httptest.NewRequest("GET", fmt.Sprintf("/weather?location=city%d", j%5), nil)
+				req.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i%3)
+				w := httptest.NewRecorder()
+				mw.ServeHTTP(w, req)
+				if w.Code != http.StatusOK && w.Code != http.StatusTooManyRequests {
+					t.Errorf("Unexpected status %d", w.Code)
+					return
+				}
+			}
+		}(i)
+	}
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		for factor := 1.0; ; factor = 1.5 - factor {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			cleanupGlobalVisitorsOnce()
+			cleanupParamVisitorsOnce()
+			ReleaseIdleVisitors()
+			setRateLimitFactor(factor)
+		}
+	}()
+	wg.Wait()
+	close(done)
+	background.Wait()
+}
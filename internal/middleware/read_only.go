@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// ReadOnlyMiddleware returns an HTTP middleware that rejects requests which would change state, any
+// method other than GET, HEAD and OPTIONS, with a 503 while read_only is set. Reads are served as
+// usual. When read_only is off, next is returned unchanged.
+func ReadOnlyMiddleware(next http.Handler) http.Handler {
+	if !config.IsReadOnly() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusServiceUnavailable, "The service is read-only during maintenance", "Service Unavailable")
+		}
+	})
+}
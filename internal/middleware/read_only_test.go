@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	viper.Set("read_only", true)
+	defer viper.Set("read_only", nil)
+
+	h := ReadOnlyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodHead, http.StatusOK},
+		{http.MethodOptions, http.StatusOK},
+		{http.MethodPost, http.StatusServiceUnavailable},
+		{http.MethodPatch, http.StatusServiceUnavailable},
+		{http.MethodDelete, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(tt.method, "/subscriptions", nil))
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestReadOnlyMiddleware_Off(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, ok := ReadOnlyMiddleware(next).(http.HandlerFunc); !ok {
+		t.Error("Expected next to be returned unchanged when read_only is off")
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+)
+
+// RecoveryMiddleware returns an HTTP middleware that turns panics in later handlers into a
+// 500 JSON error response and logs the panic with its stack trace.
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logctx.Annotate(logger(), r.Context()).Errorw("Panic while serving request",
+				"path", r.URL.Path,
+				"panic", rec,
+				"stack", string(debug.Stack()),
+			)
+			writeError(w, http.StatusInternalServerError, "Internal server error", "Error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	h := RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rr.Code)
+	}
+	var resp model.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode JSON error response: %v", err)
+	}
+	if resp.Error == nil || *resp.Error != "Internal server error" {
+		t.Errorf("Unexpected error body %+v", resp)
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// RequestCostHeader tells the client what its request cost against the upstream quota.
+const RequestCostHeader = "X-Request-Cost"
+
+// RequestCostMiddleware sets X-Request-Cost on every response, as a structured field dictionary
+// (RFC 8941), e.g.
+//
+//	X-Request-Cost: cache=miss, upstream-calls=1, quota-remaining=4119, quota-limit=5000
+//
+// cache is hit when serving the request made no provider call, and miss when it made at least
+// one; upstream-calls counts them. quota-remaining and quota-limit are the calls left today on the
+// account the request is billed to, the API key's own provider account or the shared one, as of
+// the last budget update. They are left out when the account has no daily quota.
+//
+// It must run after auth, which binds the request to its provider account.
+func RequestCostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, tracker := upstream.WithTracker(r.Context())
+		cw := &costResponseWriter{ResponseWriter: w, ctx: ctx, tracker: tracker}
+		next.ServeHTTP(cw, r.WithContext(ctx))
+		cw.setHeader()
+	})
+}
+
+// requestCost formats the X-Request-Cost value for a request that made calls provider calls.
+func requestCost(ctx context.Context, calls int64) string {
+	cache := "hit"
+	if calls > 0 {
+		cache = "miss"
+	}
+	v := "cache=" + cache + ", upstream-calls=" + strconv.FormatInt(calls, 10)
+	if remaining, quota, ok := repository.QuotaRemaining(ctx); ok {
+		v += ", quota-remaining=" + strconv.FormatInt(remaining, 10) + ", quota-limit=" + strconv.FormatInt(quota, 10)
+	}
+	return v
+}
+
+// costResponseWriter sets X-Request-Cost just before the headers are sent, once the handler has
+// made its provider calls.
+type costResponseWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	tracker *upstream.Tracker
+	set     bool
+}
+
+func (rw *costResponseWriter) setHeader() {
+	if !rw.set {
+		rw.set = true
+		rw.Header().Set(RequestCostHeader, requestCost(rw.ctx, rw.tracker.Calls()))
+	}
+}
+
+func (rw *costResponseWriter) WriteHeader(status int) {
+	rw.setHeader()
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *costResponseWriter) Write(b []byte) (int, error) {
+	rw.setHeader()
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. for flushing).
+func (rw *costResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+	"github.com/spf13/viper"
+)
+
+func TestRequestCostMiddleware(t *testing.T) {
+	viper.Set("costs.providers", map[string]interface{}{"openweathermap": map[string]interface{}{"daily_quota": 1000}})
+	t.Cleanup(func() { viper.Set("costs.providers", nil) })
+
+	h := RequestCostMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("miss") != "" {
+			upstream.RecordCall(r.Context())
+		}
+		if r.URL.Query().Get("empty") == "" {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	tests := []struct {
+		name   string
+		target string
+		tenant bool
+		want   string
+	}{
+		{"hit", "/weather", false, "cache=hit, upstream-calls=0, quota-remaining=1000, quota-limit=1000"},
+		{"miss", "/weather?miss=1", false, "cache=miss, upstream-calls=1, quota-remaining=1000, quota-limit=1000"},
+		{"nothing written", "/weather?empty=1", false, "cache=hit, upstream-calls=0, quota-remaining=1000, quota-limit=1000"},
+		{"tenant account", "/weather?miss=1", true, "cache=miss, upstream-calls=1, quota-remaining=50, quota-limit=50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.tenant {
+				r = r.WithContext(tenant.WithProvider(r.Context(), tenant.Provider{Tenant: "acme", Name: "openweathermap", DailyQuota: 50}))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if got := rr.Header().Get(RequestCostHeader); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestCostMiddleware_NoQuota(t *testing.T) {
+	viper.Set("costs.providers", map[string]interface{}{"openweathermap": map[string]interface{}{"daily_quota": 0}})
+	t.Cleanup(func() { viper.Set("costs.providers", nil) })
+
+	rr := httptest.NewRecorder()
+	RequestCostMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	if got := rr.Header().Get(RequestCostHeader); got != "cache=hit, upstream-calls=0" {
+		t.Errorf("Expected the quota left out, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+)
+
+// RequestIDHeader is the header used to accept and return request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware returns an HTTP middleware that assigns every request an ID, reusing a
+// well-formed client-supplied X-Request-ID, and echoes it in the response headers. The ID and
+// client IP are recorded in the request context for logctx.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := logctx.WithClientIP(logctx.WithRequestID(r.Context(), id), getIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the request ID assigned by RequestIDMiddleware, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	return logctx.FieldsFrom(ctx).RequestID
+}
+
+// validRequestID accepts IDs of up to 128 visible ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
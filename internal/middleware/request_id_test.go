@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	if len(seen) != 32 || rr.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("Expected a generated 32-char ID echoed in the header, got %q / %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set(RequestIDHeader, "client-abc-123")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if seen != "client-abc-123" {
+		t.Errorf("Expected the client ID to be reused, got %q", seen)
+	}
+
+	for _, bad := range []string{"has space", strings.Repeat("x", 129), "tab\there"} {
+		req = httptest.NewRequest(http.MethodGet, "/weather", nil)
+		req.Header.Set(RequestIDHeader, bad)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if seen == bad {
+			t.Errorf("Expected malformed ID %q to be replaced", bad)
+		}
+	}
+}
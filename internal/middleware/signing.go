@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/client"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// signer produces the signature bytes for a payload.
+type signer struct {
+	alg, keyID string
+	sign       func(payload []byte) []byte
+}
+
+// newSigner builds a signer from the signing config.
+func newSigner(cfg config.SigningConfig) (*signer, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("RESPONSE_SIGNING_KEY is not set")
+	}
+	switch cfg.Algorithm {
+	case client.AlgHMACSHA256:
+		secret := []byte(cfg.Key)
+		return &signer{alg: cfg.Algorithm, keyID: cfg.KeyID, sign: func(payload []byte) []byte {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(payload)
+			return mac.Sum(nil)
+		}}, nil
+	case client.AlgEd25519:
+		raw, err := base64.StdEncoding.DecodeString(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("RESPONSE_SIGNING_KEY is not base64: %w", err)
+		}
+		var key ed25519.PrivateKey
+		switch len(raw) {
+		case ed25519.SeedSize:
+			key = ed25519.NewKeyFromSeed(raw)
+		case ed25519.PrivateKeySize:
+			key = ed25519.PrivateKey(raw)
+		default:
+			return nil, fmt.Errorf("ed25519 key must be a %d-byte seed or %d-byte private key", ed25519.SeedSize, ed25519.PrivateKeySize)
+		}
+		return &signer{alg: cfg.Algorithm, keyID: cfg.KeyID, sign: func(payload []byte) []byte {
+			return ed25519.Sign(key, payload)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm %q", cfg.Algorithm)
+	}
+}
+
+// header signs body at now and renders the X-Signature value.
+func (s *signer) header(now time.Time, body []byte) string {
+	ts := now.Unix()
+	return client.FormatSignature(client.Signature{
+		Timestamp: ts,
+		Algorithm: s.alg,
+		KeyID:     s.keyID,
+		Value:     s.sign(client.SignedPayload(ts, body)),
+	})
+}
+
+// signingResponseWriter buffers the response so its body can be signed before headers are sent.
+// A handler that flushes (a streaming response) switches it to pass-through and the response goes
+// out unsigned.
+type signingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (rw *signingResponseWriter) WriteHeader(status int) {
+	if rw.streaming {
+		return
+	}
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+func (rw *signingResponseWriter) Write(b []byte) (int, error) {
+	if rw.streaming {
+		return rw.ResponseWriter.Write(b)
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.buf.Write(b)
+}
+
+// FlushError sends what was buffered so far unsigned and streams the rest.
+func (rw *signingResponseWriter) FlushError() error {
+	if !rw.streaming {
+		rw.streaming = true
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		rw.ResponseWriter.WriteHeader(rw.status)
+		if _, err := rw.ResponseWriter.Write(rw.buf.Bytes()); err != nil {
+			return err
+		}
+		rw.buf.Reset()
+	}
+	return http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for everything but flushing,
+// such as extending the write deadline of a held response.
+func (rw *signingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// SigningMiddleware returns an HTTP middleware that signs response bodies with the key from
+// RESPONSE_SIGNING_KEY and sends the signature in X-Signature, so downstream aggregators can detect
+// tampering by intermediaries. Verification helpers live in the client SDK. When signing is disabled
+// or misconfigured, next is returned unchanged.
+func SigningMiddleware(next http.Handler) http.Handler {
+	cfg := config.GetSigningConfig()
+	if !cfg.Enabled {
+		return next
+	}
+	s, err := newSigner(cfg)
+	if err != nil {
+		logger().Errorw("Response signing disabled", "error", err)
+		return next
+	}
+	return s.wrap(next)
+}
+
+func (s *signer) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &signingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		if rw.streaming {
+			return
+		}
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		w.Header().Set(client.SignatureHeader, s.header(time.Now(), rw.buf.Bytes()))
+		w.WriteHeader(rw.status)
+		_, _ = w.Write(rw.buf.Bytes())
+	})
+}
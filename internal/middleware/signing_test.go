@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/client"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/spf13/viper"
+)
+
+func TestSigningMiddleware_HMAC(t *testing.T) {
+	s, err := newSigner(config.SigningConfig{Algorithm: client.AlgHMACSHA256, KeyID: "k1", Key: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte(`{"message":`))
+		_, _ = w.Write([]byte(`"Success"}`))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather", nil))
+
+	if w.Code != http.StatusTeapot || w.Body.String() != `{"message":"Success"}` {
+		t.Fatalf("Expected the handler response unchanged, got %d %q", w.Code, w.Body.String())
+	}
+	header := w.Header().Get(client.SignatureHeader)
+	v := client.HMACVerifier{Secret: []byte("s3cret"), MaxAge: time.Minute}
+	if err := v.Verify(header, w.Body.Bytes()); err != nil {
+		t.Errorf("Expected signature %q to verify, got %v", header, err)
+	}
+	if sig, _ := client.ParseSignature(header); sig.KeyID != "k1" {
+		t.Errorf("Expected key id k1, got %q", sig.KeyID)
+	}
+	if err := v.Verify(header, []byte(`{"message":"Tampered"}`)); !errors.Is(err, client.ErrInvalidSignature) {
+		t.Errorf("Expected a tampered body to fail, got %v", err)
+	}
+}
+
+func TestSigningMiddleware_Ed25519(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	s, err := newSigner(config.SigningConfig{Algorithm: client.AlgEd25519, KeyID: "k2", Key: base64.StdEncoding.EncodeToString(priv.Seed())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if err := (client.Ed25519Verifier{PublicKey: pub}).Verify(w.Header().Get(client.SignatureHeader), w.Body.Bytes()); err != nil {
+		t.Errorf("Expected ed25519 signature to verify, got %v", err)
+	}
+}
+
+func TestSigningMiddleware_StreamingGoesUnsigned(t *testing.T) {
+	s, _ := newSigner(config.SigningConfig{Algorithm: client.AlgHMACSHA256, Key: "s3cret"})
+	h := s.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("row 1\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Expected flush to be supported, got %v", err)
+		}
+		_, _ = w.Write([]byte("row 2\n"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/export", nil))
+
+	if !w.Flushed || w.Body.String() != "row 1\nrow 2\n" {
+		t.Errorf("Expected both rows streamed, got %q (flushed %v)", w.Body.String(), w.Flushed)
+	}
+	if w.Header().Get(client.SignatureHeader) != "" {
+		t.Error("Expected streamed responses to be unsigned")
+	}
+}
+
+func TestSigningMiddleware_ExtendsWriteDeadline(t *testing.T) {
+	s, _ := newSigner(config.SigningConfig{Algorithm: client.AlgHMACSHA256, Key: "s3cret"})
+	deadlineErr := errors.New("not called")
+	srv := httptest.NewServer(s.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadlineErr = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute))
+		_, _ = w.Write([]byte("held"))
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if deadlineErr != nil {
+		t.Errorf("Expected the write deadline to reach the connection, got %v", deadlineErr)
+	}
+	if resp.Header.Get(client.SignatureHeader) == "" {
+		t.Error("Expected the response to stay signed")
+	}
+}
+
+func TestNewSigner_Errors(t *testing.T) {
+	for _, cfg := range []config.SigningConfig{
+		{Algorithm: client.AlgHMACSHA256},
+		{Algorithm: client.AlgEd25519, Key: "not base64!"},
+		{Algorithm: client.AlgEd25519, Key: base64.StdEncoding.EncodeToString([]byte("short"))},
+		{Algorithm: "rsa", Key: "x"},
+	} {
+		if _, err := newSigner(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestSigningMiddleware_DisabledOrMisconfigured(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	viper.Set("signing.enabled", true)
+	viper.Set("signing.algorithm", "rsa")
+	defer func() {
+		viper.Set("signing.enabled", nil)
+		viper.Set("signing.algorithm", nil)
+	}()
+	t.Setenv("RESPONSE_SIGNING_KEY", "x")
+
+	w := httptest.NewRecorder()
+	SigningMiddleware(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get(client.SignatureHeader) != "" {
+		t.Error("Expected no signature with a misconfigured signer")
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+var routeTimeoutsTotal = metrics.NewCounterVec(
+	"weather_http_timeouts_total",
+	"Requests answered with 503 because their route's time budget ran out.",
+	"route",
+)
+
+// TimeoutMiddleware returns an HTTP middleware that bounds each route by its budget in
+// server.route_timeouts. A request still running when its budget runs out has its context
+// cancelled and, if its handler has not started the response yet, receives a 503 with the standard
+// JSON error envelope. A response already under way, such as a streamed /admin/export, is left to
+// the handler to end once it sees the cancelled context. Unlike http.TimeoutHandler, responses are
+// not buffered, so handlers can flush. Routes without a budget are not limited.
+func TimeoutMiddleware(next http.Handler) http.Handler {
+	timeouts := config.GetRouteTimeouts()
+	if len(timeouts) == 0 {
+		return next
+	}
+	errMsg := "Request timed out"
+	body, _ := json.Marshal(model.Response{Error: &errMsg, Message: "Error"})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := matchRoute(timeouts, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeouts[route])
+		defer cancel()
+		dw := &deadlineWriter{w: w, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(dw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			// Re-panic here so that the recovery middleware sees it
+			panic(p)
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		if dw.timeOut(body) {
+			if r.Context().Err() == nil {
+				routeTimeoutsTotal.WithLabelValues(route).Inc()
+			}
+			return
+		}
+		// The response has started, so w stays the handler's until it returns
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		}
+	})
+}
+
+// matchRoute returns the configured route covering path: an exact match, or else the longest
+// route ending in "/" that prefixes it.
+func matchRoute[V any](routes map[string]V, path string) (string, bool) {
+	if _, ok := routes[path]; ok {
+		return path, true
+	}
+	best := ""
+	for route := range routes {
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > len(best) {
+			best = route
+		}
+	}
+	return best, best != ""
+}
+
+// deadlineWriter passes a handler's response through as it is written, until TimeoutMiddleware
+// answers a request whose handler has written nothing in time. From then on the handler's writes
+// are dropped. The handler gets its own header map, copied to the response when it starts, so that
+// it can keep setting headers while the timeout response is written.
+type deadlineWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (dw *deadlineWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *deadlineWriter) WriteHeader(status int) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if !dw.timedOut && !dw.wroteHeader {
+		dw.writeHeader(status)
+	}
+}
+
+func (dw *deadlineWriter) Write(b []byte) (int, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !dw.wroteHeader {
+		dw.writeHeader(http.StatusOK)
+	}
+	return dw.w.Write(b)
+}
+
+// FlushError flushes the response through to the client; http.ResponseController prefers it to
+// Unwrap.
+func (dw *deadlineWriter) FlushError() error {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	if !dw.wroteHeader {
+		dw.writeHeader(http.StatusOK)
+	}
+	return http.NewResponseController(dw.w).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for everything but flushing.
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.w
+}
+
+// writeHeader starts the handler's response. dw.mu must be held.
+func (dw *deadlineWriter) writeHeader(status int) {
+	dw.wroteHeader = true
+	dst := dw.w.Header()
+	for k, v := range dw.header {
+		dst[k] = v
+	}
+	dw.w.WriteHeader(status)
+}
+
+// timeOut writes the timeout response and reports true, unless the handler has started its own.
+func (dw *deadlineWriter) timeOut(body []byte) bool {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.wroteHeader {
+		return false
+	}
+	dw.timedOut = true
+	dw.w.Header().Set("Content-Type", "application/json")
+	dw.w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = dw.w.Write(body)
+	return true
+}
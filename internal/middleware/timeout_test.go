@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	viper.Set("server.route_timeouts", map[string]string{"/weather": "20ms", "/feed/": "20ms"})
+	defer viper.Set("server.route_timeouts", nil)
+
+	h := TimeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("slow") {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	before := routeTimeoutsTotal.WithLabelValues("/weather").Value()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather?slow=1", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON timeout body, got Content-Type %q", ct)
+	}
+	var resp model.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error == nil || resp.Message != "Error" {
+		t.Errorf("Expected the JSON error envelope, got %q", rr.Body.String())
+	}
+	if got := routeTimeoutsTotal.WithLabelValues("/weather").Value(); got != before+1 {
+		t.Errorf("Expected the timeout counted, got %v", got-before)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/feed/london?slow=1", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the /feed/ budget to cover /feed/london, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	if rr.Code != http.StatusTeapot || rr.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the handler's own response, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestTimeoutMiddleware_Streaming(t *testing.T) {
+	viper.Set("server.route_timeouts", map[string]string{"/admin/export": "20ms"})
+	defer viper.Set("server.route_timeouts", nil)
+
+	flushed := make(chan bool, 1)
+	h := TimeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = w.Write([]byte("{}\n"))
+		flushed <- http.NewResponseController(w).Flush() == nil
+		<-r.Context().Done()
+		_, _ = w.Write([]byte("{}\n"))
+	}))
+
+	before := routeTimeoutsTotal.WithLabelValues("/admin/export").Value()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/export", nil))
+	if !<-flushed || !rr.Flushed {
+		t.Error("Expected the response flushed through to the client")
+	}
+	if rr.Code != http.StatusOK || rr.Body.String() != "{}\n{}\n" || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Expected the started response left to the handler, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := routeTimeoutsTotal.WithLabelValues("/admin/export").Value(); got != before {
+		t.Errorf("Expected no 503 counted, got %v", got-before)
+	}
+}
+
+func TestTimeoutMiddleware_Panic(t *testing.T) {
+	viper.Set("server.route_timeouts", map[string]string{"/weather": "1s"})
+	defer viper.Set("server.route_timeouts", nil)
+
+	h := RecoveryMiddleware(TimeoutMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected the panic recovered as a 500, got %d", rr.Code)
+	}
+}
+
+func TestTimeoutRoute(t *testing.T) {
+	timeouts := map[string]time.Duration{"/weather": time.Second, "/admin/": time.Second, "/admin/jobs/": time.Second}
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"/weather", "/weather", true},
+		{"/weather/extra", "", false},
+		{"/admin/export", "/admin/", true},
+		{"/admin/jobs/warmer/run", "/admin/jobs/", true},
+		{"/status", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := matchRoute(timeouts, tt.path); got != tt.want || ok != tt.ok {
+			t.Errorf("matchRoute(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
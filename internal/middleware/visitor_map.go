@@ -0,0 +1,121 @@
+package middleware
+
+import "sync"
+
+// visitorShards is the number of independently locked shards in a shardedMap. With thousands of
+// distinct clients, requests from different IPs rarely contend on the same lock.
+const visitorShards = 64
+
+type visitorShard[V any] struct {
+	mu sync.Mutex
+	m  map[string]V
+}
+
+// shardedMap is a string-keyed map split across visitorShards mutex-protected shards, selected by
+// an FNV-1a hash of the key.
+type shardedMap[V any] struct {
+	shards [visitorShards]visitorShard[V]
+}
+
+func newShardedMap[V any]() *shardedMap[V] {
+	s := &shardedMap[V]{}
+	for i := range s.shards {
+		s.shards[i].m = make(map[string]V)
+	}
+	return s
+}
+
+func (s *shardedMap[V]) shard(key string) *visitorShard[V] {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &s.shards[h%visitorShards]
+}
+
+// update calls fn with the current value for key (ok reports whether it exists) under the shard
+// lock and stores the value fn returns.
+func (s *shardedMap[V]) update(key string, fn func(v V, ok bool) V) V {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	v, ok := sh.m[key]
+	v = fn(v, ok)
+	sh.m[key] = v
+	return v
+}
+
+func (s *shardedMap[V]) load(key string) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	v, ok := sh.m[key]
+	return v, ok
+}
+
+func (s *shardedMap[V]) store(key string, v V) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	sh.m[key] = v
+	sh.mu.Unlock()
+}
+
+// prune deletes every entry for which keep returns false, locking one shard at a time.
+func (s *shardedMap[V]) prune(keep func(key string, v V) bool) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for k, v := range sh.m {
+			if !keep(k, v) {
+				delete(sh.m, k)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// shrink is prune for memory pressure: kept entries are copied into fresh maps, because Go maps
+// never give back the buckets of deleted entries.
+func (s *shardedMap[V]) shrink(keep func(key string, v V) bool) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		m := make(map[string]V)
+		for k, v := range sh.m {
+			if keep(k, v) {
+				m[k] = v
+			}
+		}
+		sh.m = m
+		sh.mu.Unlock()
+	}
+}
+
+// each calls fn for every entry, locking one shard at a time.
+func (s *shardedMap[V]) each(fn func(key string, v V)) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for k, v := range sh.m {
+			fn(k, v)
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// len returns the number of entries across all shards.
+func (s *shardedMap[V]) len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.m)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+func (s *shardedMap[V]) reset() {
+	s.prune(func(string, V) bool { return false })
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestShardedMap(t *testing.T) {
+	m := newShardedMap[int]()
+	for i := 0; i < 1000; i++ {
+		m.update(strconv.Itoa(i), func(v int, ok bool) int {
+			if ok {
+				t.Fatalf("Key %d unexpectedly present", i)
+			}
+			return i
+		})
+	}
+	if m.len() != 1000 {
+		t.Fatalf("Expected 1000 entries, got %d", m.len())
+	}
+	if v, ok := m.load("42"); !ok || v != 42 {
+		t.Errorf("Expected 42, got %d (%v)", v, ok)
+	}
+
+	m.prune(func(_ string, v int) bool { return v%2 == 0 })
+	if m.len() != 500 {
+		t.Errorf("Expected 500 entries after pruning odd values, got %d", m.len())
+	}
+	m.reset()
+	if m.len() != 0 {
+		t.Errorf("Expected an empty map after reset, got %d", m.len())
+	}
+}
+
+func TestShardedMap_ConcurrentUpdates(t *testing.T) {
+	m := newShardedMap[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.update(strconv.Itoa(i%100), func(v int, _ bool) int { return v + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+	total := 0
+	m.prune(func(_ string, v int) bool { total += v; return true })
+	if total != 8000 {
+		t.Errorf("Expected 8000 increments, got %d", total)
+	}
+}
+
+// mutexVisitorMap is the previous single-lock layout, kept as the benchmark baseline.
+type mutexVisitorMap struct {
+	mu sync.Mutex
+	m  map[string]*visitor
+}
+
+func (s *mutexVisitorMap) touch(ip string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[ip]
+	if !ok {
+		v = &visitor{rate.NewLimiter(1, 1), time.Now()}
+		s.m[ip] = v
+	}
+	v.lastSeen = time.Now()
+	return v.limiter
+}
+
+func benchmarkIPs(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = "10." + strconv.Itoa(i>>16&255) + "." + strconv.Itoa(i>>8&255) + "." + strconv.Itoa(i&255)
+	}
+	return ips
+}
+
+// BenchmarkVisitors compares the single-mutex map with the sharded map at 10k distinct clients.
+// Run with -cpu=1,4,16 to see contention grow with parallelism for the single mutex.
+func BenchmarkVisitors(b *testing.B) {
+	ips := benchmarkIPs(10000)
+
+	b.Run("mutex", func(b *testing.B) {
+		m := &mutexVisitorMap{m: make(map[string]*visitor)}
+		var n atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m.touch(ips[n.Add(1)%uint64(len(ips))])
+			}
+		})
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		m := newShardedMap[*visitor]()
+		limits := func() (float64, int) { return 60, 1 }
+		var n atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				touchVisitor(m, ips[n.Add(1)%uint64(len(ips))], limits)
+			}
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Alert severities, from the Common Alerting Protocol that government warning services use.
+const (
+	SeverityExtreme  = "extreme"
+	SeveritySevere   = "severe"
+	SeverityModerate = "moderate"
+	SeverityMinor    = "minor"
+	SeverityUnknown  = "unknown"
+)
+
+// WeatherAlert is a government weather alert in force, or announced, for a location.
+type WeatherAlert struct {
+	Event       string    `json:"event"`
+	Severity    string    `json:"severity"`
+	Sender      string    `json:"sender,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Description string    `json:"description"`
+}
+
+// AlertsResponse is a location's weather alerts, soonest first. No alerts is an empty list.
+type AlertsResponse struct {
+	Location    string         `json:"location"`
+	Coordinates Coordinates    `json:"coordinates"`
+	Alerts      []WeatherAlert `json:"alerts"`
+	Cached      bool           `json:"cached"`
+}
+
+// AlertSeverity estimates the severity of an alert from its event name and tags, since the
+// provider does not pass on the issuer's own. A warning colour (red, orange or amber, yellow) is the
+// issuer's level and wins; otherwise the usual extreme, warning, watch and advisory wording is
+// recognised, and anything else is unknown.
+func AlertSeverity(event string, tags []string) string {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(event+" "+strings.Join(tags, " ")), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		words[w] = true
+	}
+	switch {
+	case words["red"]:
+		return SeverityExtreme
+	case words["orange"] || words["amber"]:
+		return SeveritySevere
+	case words["yellow"]:
+		return SeverityModerate
+	case words["extreme"]:
+		return SeverityExtreme
+	case words["warning"]:
+		return SeveritySevere
+	case words["watch"]:
+		return SeverityModerate
+	case words["advisory"] || words["statement"]:
+		return SeverityMinor
+	default:
+		return SeverityUnknown
+	}
+}
+
+// OpenWeatherMapAlertsResponse is the part of a One Call response that carries alerts.
+type OpenWeatherMapAlertsResponse struct {
+	Alerts []struct {
+		SenderName  string   `json:"sender_name"`
+		Event       string   `json:"event"`
+		Start       int64    `json:"start"`
+		End         int64    `json:"end"`
+		Description string   `json:"description"`
+		Tags        []string `json:"tags"`
+	} `json:"alerts"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestAlertSeverity(t *testing.T) {
+	tests := []struct {
+		event string
+		tags  []string
+		want  string
+	}{
+		{"Red thunderstorm warning", nil, SeverityExtreme},
+		{"Amber Warning for Rain", nil, SeveritySevere},
+		{"Yellow wind warning", []string{"Wind"}, SeverityModerate},
+		{"Extreme Cold Warning", nil, SeverityExtreme},
+		{"Winter Storm Warning", []string{"Snow/Ice"}, SeveritySevere},
+		{"Tornado Watch", []string{"Tornado"}, SeverityModerate},
+		{"Wind Advisory", nil, SeverityMinor},
+		{"Special Weather Statement", nil, SeverityMinor},
+		{"Reduced visibility", []string{"Fog"}, SeverityUnknown},
+		{"Vigilance", []string{"Other dangers"}, SeverityUnknown},
+	}
+	for _, tt := range tests {
+		if got := AlertSeverity(tt.event, tt.tags); got != tt.want {
+			t.Errorf("AlertSeverity(%q, %v) = %q, expected %q", tt.event, tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+// Capture is one request/response pair recorded for debugging, with credentials redacted.
+type Capture struct {
+	At         time.Time   `json:"at"`
+	RequestID  string      `json:"request_id,omitempty"`
+	APIKey     string      `json:"api_key,omitempty"` // name of the authenticated key
+	ClientIP   string      `json:"client_ip,omitempty"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query,omitempty"`
+	Location   string      `json:"location,omitempty"`
+	Request    CapturedMsg `json:"request"`
+	Response   CapturedMsg `json:"response"`
+	Status     int         `json:"status"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// CapturedMsg holds the headers and body of a captured request or response. Truncated is set when
+// the body was cut to capture.max_body_bytes.
+type CapturedMsg struct {
+	Headers   http.Header `json:"headers,omitempty"`
+	Body      string      `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
@@ -0,0 +1,91 @@
+package model
+
+// Condition is a provider-independent class of weather, stable across providers and their
+// description wording, for clients to pick icons and behavior by.
+type Condition string
+
+const (
+	ConditionClear        Condition = "clear"
+	ConditionPartlyCloudy Condition = "partly_cloudy"
+	ConditionCloudy       Condition = "cloudy"
+	ConditionDrizzle      Condition = "drizzle"
+	ConditionRain         Condition = "rain"
+	ConditionFreezingRain Condition = "freezing_rain"
+	ConditionSleet        Condition = "sleet"
+	ConditionSnow         Condition = "snow"
+	ConditionThunderstorm Condition = "thunderstorm"
+	ConditionFog          Condition = "fog"
+	ConditionHaze         Condition = "haze"
+	ConditionDust         Condition = "dust"
+	ConditionSquall       Condition = "squall"
+	ConditionTornado      Condition = "tornado"
+	// ConditionUnknown is a condition the provider reported but that has no class yet.
+	ConditionUnknown Condition = "unknown"
+)
+
+// ConditionForOpenWeatherMapID classifies an OpenWeatherMap weather condition ID
+// (https://openweathermap.org/weather-conditions).
+func ConditionForOpenWeatherMapID(id int) Condition {
+	switch {
+	case id >= 200 && id < 300:
+		return ConditionThunderstorm
+	case id >= 300 && id < 400:
+		return ConditionDrizzle
+	case id == 511:
+		return ConditionFreezingRain
+	case id >= 500 && id < 600:
+		return ConditionRain
+	case id >= 611 && id <= 616:
+		return ConditionSleet
+	case id >= 600 && id < 700:
+		return ConditionSnow
+	case id == 701, id == 741:
+		return ConditionFog
+	case id == 711, id == 721:
+		return ConditionHaze
+	case id == 731, id == 751, id == 761, id == 762:
+		return ConditionDust
+	case id == 771:
+		return ConditionSquall
+	case id == 781:
+		return ConditionTornado
+	case id == 800:
+		return ConditionClear
+	case id == 801, id == 802:
+		return ConditionPartlyCloudy
+	case id == 803, id == 804:
+		return ConditionCloudy
+	default:
+		return ConditionUnknown
+	}
+}
+
+// OpenWeatherMapIcon returns the OpenWeatherMap icon code that pictures c, by day or by night, or
+// "" for no icon. It stands in for the icon of providers that have none of OpenWeatherMap's.
+func (c Condition) OpenWeatherMapIcon(night bool) string {
+	var icon string
+	switch c {
+	case ConditionClear:
+		icon = "01"
+	case ConditionPartlyCloudy:
+		icon = "02"
+	case ConditionCloudy:
+		icon = "04"
+	case ConditionDrizzle:
+		icon = "09"
+	case ConditionRain:
+		icon = "10"
+	case ConditionThunderstorm:
+		icon = "11"
+	case ConditionFreezingRain, ConditionSleet, ConditionSnow:
+		icon = "13"
+	case ConditionFog, ConditionHaze, ConditionDust, ConditionSquall, ConditionTornado:
+		icon = "50"
+	default:
+		return ""
+	}
+	if night {
+		return icon + "n"
+	}
+	return icon + "d"
+}
@@ -0,0 +1,46 @@
+package model
+
+import "testing"
+
+func TestConditionForOpenWeatherMapID(t *testing.T) {
+	tests := []struct {
+		id   int
+		want Condition
+	}{
+		{211, ConditionThunderstorm},
+		{301, ConditionDrizzle},
+		{500, ConditionRain},
+		{511, ConditionFreezingRain},
+		{522, ConditionRain},
+		{601, ConditionSnow},
+		{613, ConditionSleet},
+		{621, ConditionSnow},
+		{701, ConditionFog},
+		{721, ConditionHaze},
+		{762, ConditionDust},
+		{771, ConditionSquall},
+		{781, ConditionTornado},
+		{800, ConditionClear},
+		{802, ConditionPartlyCloudy},
+		{804, ConditionCloudy},
+		{0, ConditionUnknown},
+		{900, ConditionUnknown},
+	}
+	for _, tt := range tests {
+		if got := ConditionForOpenWeatherMapID(tt.id); got != tt.want {
+			t.Errorf("Expected ID %d to be %q, got %q", tt.id, tt.want, got)
+		}
+	}
+}
+
+func TestCondition_OpenWeatherMapIcon(t *testing.T) {
+	if got := ConditionRain.OpenWeatherMapIcon(false); got != "10d" {
+		t.Errorf("Expected 10d, got %q", got)
+	}
+	if got := ConditionClear.OpenWeatherMapIcon(true); got != "01n" {
+		t.Errorf("Expected 01n, got %q", got)
+	}
+	if got := ConditionUnknown.OpenWeatherMapIcon(false); got != "" {
+		t.Errorf("Expected no icon for an unknown condition, got %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ForecastEntry is the forecast conditions at one point in time.
+type ForecastEntry struct {
+	Time        time.Time `json:"time"`
+	Temperature float64   `json:"temperature"`
+	Description string    `json:"description"`
+}
+
+// ForecastResponse is a location's forecast, oldest entry first.
+type ForecastResponse struct {
+	Location string          `json:"location"`
+	Entries  []ForecastEntry `json:"entries"`
+	// Hours is the horizon of an hourly forecast, and zero for the 3-hourly one.
+	Hours  int  `json:"hours,omitempty"`
+	Cached bool `json:"cached"`
+}
+
+// At returns the forecast conditions at t, interpolating the temperature linearly between the
+// surrounding entries and taking the description of the nearer one. It reports false when t is
+// outside the forecast.
+func (f *ForecastResponse) At(t time.Time) (ForecastEntry, bool) {
+	for i := 1; i < len(f.Entries); i++ {
+		prev, next := f.Entries[i-1], f.Entries[i]
+		if t.Before(prev.Time) || t.After(next.Time) {
+			continue
+		}
+		span := next.Time.Sub(prev.Time)
+		frac := 0.0
+		if span > 0 {
+			frac = float64(t.Sub(prev.Time)) / float64(span)
+		}
+		entry := ForecastEntry{Time: t, Temperature: prev.Temperature + frac*(next.Temperature-prev.Temperature), Description: prev.Description}
+		if frac > 0.5 {
+			entry.Description = next.Description
+		}
+		return entry, true
+	}
+	if len(f.Entries) == 1 && t.Equal(f.Entries[0].Time) {
+		return f.Entries[0], true
+	}
+	return ForecastEntry{}, false
+}
+
+// OpenWeatherMapForecastResponse is the 5 day / 3 hour forecast returned by OpenWeatherMap.
+type OpenWeatherMapForecastResponse struct {
+	City struct {
+		Name string `json:"name"`
+	} `json:"city"`
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp json.Number `json:"temp"`
+		} `json:"main"`
+		Weather []struct {
+			Description string `json:"description"`
+		} `json:"weather"`
+	} `json:"list"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastResponse_At(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	f := &ForecastResponse{Entries: []ForecastEntry{
+		{Time: t0, Temperature: 27, Description: "rain"},
+		{Time: t0.Add(3 * time.Hour), Temperature: 30, Description: "clouds"},
+	}}
+
+	tests := []struct {
+		name            string
+		at              time.Time
+		wantOK          bool
+		wantTemperature float64
+		wantDescription string
+	}{
+		{name: "First entry", at: t0, wantOK: true, wantTemperature: 27, wantDescription: "rain"},
+		{name: "One hour in", at: t0.Add(time.Hour), wantOK: true, wantTemperature: 28, wantDescription: "rain"},
+		{name: "Two hours in", at: t0.Add(2 * time.Hour), wantOK: true, wantTemperature: 29, wantDescription: "clouds"},
+		{name: "Last entry", at: t0.Add(3 * time.Hour), wantOK: true, wantTemperature: 30, wantDescription: "clouds"},
+		{name: "Before the forecast", at: t0.Add(-time.Minute)},
+		{name: "After the forecast", at: t0.Add(4 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := f.At(tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("Expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if ok && (entry.Temperature != tt.wantTemperature || entry.Description != tt.wantDescription || !entry.Time.Equal(tt.at)) {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+		})
+	}
+}
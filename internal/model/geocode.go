@@ -0,0 +1,35 @@
+package model
+
+// GeocodeCandidate is a place a free-text query may refer to.
+type GeocodeCandidate struct {
+	Name string `json:"name"`
+	// Country is the ISO 3166-1 alpha-2 code.
+	Country string  `json:"country"`
+	State   string  `json:"state,omitempty"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+// Location returns the "<name>,<country>" form weather lookups take.
+func (c GeocodeCandidate) Location() string {
+	if c.Country == "" {
+		return c.Name
+	}
+	return c.Name + "," + c.Country
+}
+
+// GeocodeResponse lists the places a query may refer to, best match first.
+type GeocodeResponse struct {
+	Query      string             `json:"query"`
+	Candidates []GeocodeCandidate `json:"candidates"`
+	Cached     bool               `json:"cached"`
+}
+
+// OpenWeatherMapGeocodeResult is one place returned by the OpenWeatherMap direct geocoding API.
+type OpenWeatherMapGeocodeResult struct {
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+}
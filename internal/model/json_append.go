@@ -0,0 +1,199 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// AppendJSON appends the JSON encoding of w to dst without reflection or intermediate allocations.
+// The output is byte-for-byte identical to json.Marshal(w), including HTML escaping.
+func (w *WeatherResponse) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, `{"location":`...)
+	dst = appendJSONString(dst, w.Location)
+	dst = append(dst, `,"temperature":`...)
+	var err error
+	if dst, err = appendJSONFloat(dst, w.Temperature); err != nil {
+		return dst, err
+	}
+	dst = append(dst, `,"description":`...)
+	dst = appendJSONString(dst, w.Description)
+	if w.Condition != "" {
+		dst = append(dst, `,"condition":`...)
+		dst = appendJSONString(dst, string(w.Condition))
+	}
+	if w.IconURL != "" {
+		dst = append(dst, `,"icon_url":`...)
+		dst = appendJSONString(dst, w.IconURL)
+	}
+	dst = append(dst, `,"cached":`...)
+	dst = strconv.AppendBool(dst, w.Cached)
+	if w.Stale {
+		dst = append(dst, `,"stale":true`...)
+	}
+	if w.ResolvedFrom != "" {
+		dst = append(dst, `,"resolved_from":`...)
+		dst = appendJSONString(dst, w.ResolvedFrom)
+	}
+	optional := [...]struct {
+		prefix string
+		value  *float64
+	}{
+		{`,"uv_index":`, w.UVIndex},
+		{`,"feels_like":`, w.FeelsLike},
+		{`,"temp_min":`, w.TempMin},
+		{`,"temp_max":`, w.TempMax},
+		{`,"humidity":`, w.Humidity},
+		{`,"pressure":`, w.Pressure},
+		{`,"wind_speed":`, w.WindSpeed},
+		{`,"wind_direction":`, w.WindDirection},
+	}
+	for _, f := range optional {
+		if f.value == nil {
+			continue
+		}
+		dst = append(dst, f.prefix...)
+		if dst, err = appendJSONFloat(dst, *f.value); err != nil {
+			return dst, err
+		}
+	}
+	for _, f := range [...]struct {
+		prefix string
+		value  *time.Time
+	}{
+		{`,"sunrise":`, w.Sunrise},
+		{`,"sunset":`, w.Sunset},
+	} {
+		if f.value == nil {
+			continue
+		}
+		dst = append(dst, f.prefix...)
+		if dst, err = appendJSONTime(dst, *f.value); err != nil {
+			return dst, err
+		}
+	}
+	if w.DayLength != 0 {
+		dst = append(dst, `,"day_length":`...)
+		dst = strconv.AppendInt(dst, w.DayLength, 10)
+	}
+	if w.TimezoneOffset != nil {
+		dst = append(dst, `,"timezone_offset":`...)
+		dst = strconv.AppendInt(dst, *w.TimezoneOffset, 10)
+	}
+	if w.ObservedAt != nil {
+		dst = append(dst, `,"observed_at":`...)
+		if dst, err = appendJSONTime(dst, *w.ObservedAt); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// AppendJSON appends the JSON encoding of q to dst, byte-for-byte identical to json.Marshal(q).
+func (q *Quality) AppendJSON(dst []byte) ([]byte, error) {
+	fields := [...]struct {
+		prefix string
+		value  float64
+	}{
+		{`{"score":`, q.Score},
+		{`,"freshness":`, q.Freshness},
+		{`,"provider_health":`, q.ProviderHealth},
+		{`,"completeness":`, q.Completeness},
+	}
+	var err error
+	for _, f := range fields {
+		dst = append(dst, f.prefix...)
+		if dst, err = appendJSONFloat(dst, f.value); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// appendJSONFloat formats f the way encoding/json does for float64 values.
+func appendJSONFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &json.UnsupportedValueError{Value: reflect.ValueOf(f), Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9, as encoding/json does
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendJSONTime formats t the way time.Time.MarshalJSON does.
+func appendJSONTime(dst []byte, t time.Time) ([]byte, error) {
+	dst = append(dst, '"')
+	dst, err := t.AppendText(dst)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, '"'), nil
+}
+
+// appendJSONString appends s as a quoted JSON string using the same escaping as encoding/json.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWeatherResponse_AppendJSONMatchesMarshal(t *testing.T) {
+	night, noon := 0.0, 7.25
+	feels, low, high, humidity, pressure, calm, west := -8.5, -4.0, -2.25, 86.0, 1003.4, 0.0, 270.0
+	oslo := time.FixedZone("", 3600)
+	sunrise, sunset := time.Date(2025, 1, 15, 9, 5, 0, 0, oslo), time.Date(2025, 1, 15, 15, 40, 0, 0, oslo)
+	utc := time.Unix(1736928000, 0).UTC()
+	offset, zero := int64(3600), int64(0)
+	cases := []WeatherResponse{
+		{},
+		{Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true},
+		{Location: "São Paulo", Temperature: -3.75, Description: "light rain", Stale: true},
+		{Location: "Jakarta,ID", Temperature: 31, Description: "haze", ResolvedFrom: "ip"},
+		{Location: "Sydney", Temperature: 12, UVIndex: &night},
+		{Location: "Lima", Temperature: 24, UVIndex: &noon, Stale: true},
+		{Location: "Oslo", Temperature: -3, FeelsLike: &feels, TempMin: &low, TempMax: &high, Humidity: &humidity, Pressure: &pressure, WindSpeed: &calm, WindDirection: &west},
+		{Location: "Bergen", Temperature: 6, UVIndex: &night, Humidity: &humidity, WindSpeed: &noon},
+		{Location: "Oslo", Temperature: -3, Sunrise: &sunrise, Sunset: &sunset, DayLength: 23700},
+		{Location: "Accra", Temperature: 29, Humidity: &humidity, Sunrise: &utc},
+		{Location: "Oslo", Temperature: -3, Sunset: &sunset, TimezoneOffset: &offset, ObservedAt: &sunrise},
+		{Location: "Accra", Temperature: 29, TimezoneOffset: &zero, ObservedAt: &utc},
+		{Location: "London", Temperature: 11, Description: "light rain", Condition: ConditionRain, IconURL: "https://openweathermap.org/img/wn/10d@2x.png?a=1&b=2"},
+		{Location: "Oslo", Temperature: -3, Condition: ConditionUnknown},
+		{Location: `Quote " and \ backslash`, Temperature: 1e21, Description: "<b>&amp;</b>"},
+		{Location: "ctl\n\t\r\b\f\x01\x1f", Temperature: 1e-7, Description: "line\u2028sep\u2029"},
+		{Location: "bad utf8 \xff\xfe", Temperature: 100, Description: "日本語"},
+		{Temperature: 123456789.123456789},
+		{Temperature: -0.000001},
+	}
+	for _, w := range cases {
+		want, err := json.Marshal(&w)
+		if err != nil {
+			t.Fatalf("json.Marshal(%+v) failed: %v", w, err)
+		}
+		got, err := w.AppendJSON(nil)
+		if err != nil {
+			t.Fatalf("AppendJSON(%+v) failed: %v", w, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("AppendJSON mismatch\n got: %s\nwant: %s", got, want)
+		}
+	}
+}
+
+func TestQuality_AppendJSONMatchesMarshal(t *testing.T) {
+	for _, q := range []Quality{{}, {Score: 0.8125, Freshness: 1, ProviderHealth: 0.5, Completeness: 2.0 / 3}} {
+		want, err := json.Marshal(&q)
+		if err != nil {
+			t.Fatalf("json.Marshal(%+v) failed: %v", q, err)
+		}
+		got, err := q.AppendJSON(nil)
+		if err != nil {
+			t.Fatalf("AppendJSON(%+v) failed: %v", q, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("AppendJSON mismatch\n got: %s\nwant: %s", got, want)
+		}
+	}
+}
+
+func TestWeatherResponse_AppendJSONRejectsNaN(t *testing.T) {
+	w := WeatherResponse{Temperature: math.NaN()}
+	if _, err := w.AppendJSON(nil); err == nil {
+		t.Error("Expected NaN temperature to be rejected like json.Marshal")
+	}
+}
+
+func TestWeatherResponse_AppendJSONAllocs(t *testing.T) {
+	w := WeatherResponse{Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true}
+	buf := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = w.AppendJSON(buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("Expected AppendJSON into a sized buffer not to allocate, got %v allocs", allocs)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// CoordinatePrecision is the number of decimals coordinates are rounded to before they are looked
+// up, about 1km at the equator, so that nearby requests share a cache entry.
+const CoordinatePrecision = 2
+
+// ErrCoordinatesOutOfRange is returned for a latitude outside [-90, 90] or a longitude outside
+// [-180, 180].
+var ErrCoordinatesOutOfRange = errors.New("coordinates out of range")
+
+// Coordinates is a point on Earth in decimal degrees.
+type Coordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Validate reports whether c is a point on Earth.
+func (c Coordinates) Validate() error {
+	if math.IsNaN(c.Lat) || math.IsNaN(c.Lon) || c.Lat < -90 || c.Lat > 90 || c.Lon < -180 || c.Lon > 180 {
+		return ErrCoordinatesOutOfRange
+	}
+	return nil
+}
+
+// Rounded returns c rounded to CoordinatePrecision decimals.
+func (c Coordinates) Rounded() Coordinates {
+	return Coordinates{Lat: roundCoordinate(c.Lat), Lon: roundCoordinate(c.Lon)}
+}
+
+// String formats c rounded to CoordinatePrecision decimals, e.g. "-6.21,106.85".
+func (c Coordinates) String() string {
+	r := c.Rounded()
+	return strconv.FormatFloat(r.Lat, 'f', CoordinatePrecision, 64) + "," + strconv.FormatFloat(r.Lon, 'f', CoordinatePrecision, 64)
+}
+
+func roundCoordinate(deg float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(deg, 'f', CoordinatePrecision, 64), 64)
+	if rounded == 0 {
+		return 0 // no -0
+	}
+	return rounded
+}
+
+// Location is what a weather lookup is for: a place name, or coordinates when Coords is set.
+type Location struct {
+	Name   string
+	Coords *Coordinates
+}
+
+// LocationAt returns the location of the given coordinates.
+func LocationAt(lat, lon float64) Location {
+	return Location{Coords: &Coordinates{Lat: lat, Lon: lon}}
+}
+
+// Key identifies the location in cache keys, overrides and logs: the name, or "coord:" and the
+// rounded coordinates, so coordinates never collide with a place name.
+func (l Location) Key() string {
+	if l.Coords != nil {
+		return "coord:" + l.Coords.String()
+	}
+	return l.Name
+}
+
+// String returns the name, or the rounded coordinates.
+func (l Location) String() string {
+	if l.Coords != nil {
+		return l.Coords.String()
+	}
+	return l.Name
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLocation_Key(t *testing.T) {
+	tests := []struct {
+		loc      Location
+		key, str string
+	}{
+		{Location{Name: "Jakarta"}, "Jakarta", "Jakarta"},
+		{LocationAt(-6.2088, 106.8456), "coord:-6.21,106.85", "-6.21,106.85"},
+		{LocationAt(-6.214, 106.849), "coord:-6.21,106.85", "-6.21,106.85"},
+		{LocationAt(-0.001, 0.004), "coord:0.00,0.00", "0.00,0.00"},
+		{LocationAt(90, -180), "coord:90.00,-180.00", "90.00,-180.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.loc.Key(); got != tt.key {
+			t.Errorf("Key() = %q, want %q", got, tt.key)
+		}
+		if got := tt.loc.String(); got != tt.str {
+			t.Errorf("String() = %q, want %q", got, tt.str)
+		}
+	}
+}
+
+func TestCoordinates_Validate(t *testing.T) {
+	for _, c := range []Coordinates{{0, 0}, {90, 180}, {-90, -180}, {-6.2, 106.8}} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Expected %+v valid, got %v", c, err)
+		}
+	}
+	for _, c := range []Coordinates{{90.01, 0}, {0, -180.01}, {math.NaN(), 0}, {0, math.Inf(1)}} {
+		if err := c.Validate(); err != ErrCoordinatesOutOfRange {
+			t.Errorf("Expected %+v out of range, got %v", c, err)
+		}
+	}
+}
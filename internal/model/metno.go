@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MetNoResponse is the part of a Met.no Locationforecast (compact) response used for current
+// weather. Timeseries are hourly, starting with the current hour.
+type MetNoResponse struct {
+	Properties struct {
+		Timeseries []struct {
+			Time time.Time `json:"time"`
+			Data struct {
+				Instant struct {
+					Details struct {
+						AirTemperature        json.Number `json:"air_temperature"`
+						AirPressureAtSeaLevel *float64    `json:"air_pressure_at_sea_level"`
+						RelativeHumidity      *float64    `json:"relative_humidity"`
+						WindSpeed             *float64    `json:"wind_speed"`
+						WindFromDirection     *float64    `json:"wind_from_direction"`
+					} `json:"details"`
+				} `json:"instant"`
+				Next1Hours *struct {
+					Summary struct {
+						SymbolCode string `json:"symbol_code"`
+					} `json:"summary"`
+				} `json:"next_1_hours"`
+				Next6Hours *struct {
+					Summary struct {
+						SymbolCode string `json:"symbol_code"`
+					} `json:"summary"`
+				} `json:"next_6_hours"`
+			} `json:"data"`
+		} `json:"timeseries"`
+	} `json:"properties"`
+}
@@ -1,21 +1,49 @@
 package model
 
+import "encoding/json"
+
+// OpenWeatherMapResponse is the current weather returned by OpenWeatherMap. Temperatures are kept
+// as json.Number so they are rounded from the provider's decimal text (see TemperatureFromNumber).
 type OpenWeatherMapResponse struct {
-	Name string `json:"name"`
-	Main struct {
-		Temp      float64 `json:"temp"`
-		FeelsLike float64 `json:"feels_like"`
-		TempMin   float64 `json:"temp_min"`
-		TempMax   float64 `json:"temp_max"`
-		Pressure  int     `json:"pressure"`
-		Humidity  int     `json:"humidity"`
-		SeaLevel  int     `json:"sea_level"`
-		GrndLevel int     `json:"grnd_level"`
+	Name  string       `json:"name"`
+	Coord *Coordinates `json:"coord"`
+	Main  struct {
+		Temp      json.Number `json:"temp"`
+		FeelsLike json.Number `json:"feels_like"`
+		TempMin   json.Number `json:"temp_min"`
+		TempMax   json.Number `json:"temp_max"`
+		Pressure  int         `json:"pressure"`
+		Humidity  int         `json:"humidity"`
+		SeaLevel  int         `json:"sea_level"`
+		GrndLevel int         `json:"grnd_level"`
 	} `json:"main"`
-	Weather []struct {
+	Wind struct {
+		Speed *float64 `json:"speed"`
+		Deg   *float64 `json:"deg"`
+	} `json:"wind"`
+	Sys struct {
+		Country string `json:"country"`
+		// Sunrise and Sunset are in Unix seconds, 0 when the sun does not rise or set today.
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+	} `json:"sys"`
+	// Dt is when the weather was observed, in Unix seconds.
+	Dt int64 `json:"dt"`
+	// Timezone is the location's offset from UTC in seconds.
+	Timezone *int64 `json:"timezone"`
+	Weather  []struct {
 		ID          int    `json:"id"`
 		Main        string `json:"main"`
 		Description string `json:"description"`
 		Icon        string `json:"icon"`
 	} `json:"weather"`
 }
+
+// OpenWeatherMapUVResponse is the UV index returned by the One Call API, as current.uvi, or by the
+// older UV index API, as value.
+type OpenWeatherMapUVResponse struct {
+	Current *struct {
+		UVI *float64 `json:"uvi"`
+	} `json:"current"`
+	Value *float64 `json:"value"`
+}
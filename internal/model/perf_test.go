@@ -0,0 +1,22 @@
+//go:build perf
+
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+)
+
+func TestPerf_AppendJSON(t *testing.T) {
+	sunrise := time.Date(2025, 6, 21, 3, 43, 0, 0, time.UTC)
+	sunset := sunrise.Add(17*time.Hour + 35*time.Minute)
+	w := model.WeatherResponse{
+		Location: "London", Temperature: 15.2, Description: "clear sky", Cached: true,
+		Sunrise: &sunrise, Sunset: &sunset, DayLength: 63300,
+	}
+	buf := make([]byte, 0, 512)
+	testutil.CheckPerf(t, "AppendJSON", func() { buf, _ = w.AppendJSON(buf[:0]) })
+}
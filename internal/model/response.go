@@ -1,8 +1,37 @@
 package model
 
+import "github.com/fakhrymubarak/weather-api-redis/internal/apperror"
+
 // Response is a generic struct for API responses
 type Response struct {
-	Data    interface{} `json:"data,omitempty"`
-	Error   *string     `json:"error,omitempty"`
-	Message string      `json:"message"`
+	Data     interface{}       `json:"data,omitempty"`
+	Error    *string           `json:"error,omitempty"`
+	Errors   []*apperror.Error `json:"errors,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+	// Suggestions lists known locations close to one that was not found.
+	Suggestions []string `json:"suggestions,omitempty"`
+	// RateLimit describes the limit a 429 response exceeded. Rate limit errors are always JSON.
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// Quality rates the weather data, when quality.enabled is set.
+	Quality *Quality `json:"quality,omitempty"`
+	Message string   `json:"message"`
+}
+
+// RateLimit describes the rate limit that rejected a request.
+type RateLimit struct {
+	Limiter string  `json:"limiter"` // "global", "param" or "refresh"
+	Limit   float64 `json:"limit"`   // requests per minute
+	Burst   int     `json:"burst"`
+	// RetryAfter is the number of seconds until the limiter allows another request.
+	RetryAfter float64 `json:"retry_after_seconds"`
+	DocsURL    string  `json:"docs_url,omitempty"`
+}
+
+// Quality rates how far weather data can be relied on, from 0 to 1. Score is a weighted mean of
+// the other three.
+type Quality struct {
+	Score          float64 `json:"score"`
+	Freshness      float64 `json:"freshness"`
+	ProviderHealth float64 `json:"provider_health"`
+	Completeness   float64 `json:"completeness"`
 }
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// Subscription statuses.
+const (
+	SubscriptionActive = "active"
+	SubscriptionPaused = "paused"
+)
+
+// Subscription asks for a webhook delivery to URL whenever fresh weather for Location is fetched.
+type Subscription struct {
+	ID       string `json:"id"`
+	Owner    string `json:"owner,omitempty"`
+	Location string `json:"location"`
+	URL      string `json:"url"`
+	// Secret signs deliveries. It is only returned when the subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// Revision identifies the stored version, as read; it is served as the ETag.
+	Revision string `json:"-"`
+}
+
+// WebhookEvent is the body of a webhook delivery.
+type WebhookEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Location   string          `json:"location"`
+	Weather    WeatherResponse `json:"weather"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// DeadLetter is a webhook delivery that failed every attempt.
+type DeadLetter struct {
+	SubscriptionID string       `json:"subscription_id"`
+	URL            string       `json:"url"`
+	Event          WebhookEvent `json:"event"`
+	Attempts       int          `json:"attempts"`
+	LastError      string       `json:"last_error"`
+	FailedAt       time.Time    `json:"failed_at"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// DefaultTemperaturePrecision is the number of decimals temperatures keep unless
+// server.temperature_precision says otherwise.
+const DefaultTemperaturePrecision = 2
+
+// RoundTemperature rounds celsius to precision decimals. The rounding is done on the decimal
+// representation, so the result always serializes as the shortest decimal with at most precision
+// digits: 15.2, never 15.199999999999999.
+func RoundTemperature(celsius float64, precision int) float64 {
+	if math.IsInf(celsius, 0) || math.IsNaN(celsius) {
+		return celsius
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(celsius, 'f', precision, 64), 64)
+	if rounded == 0 {
+		return 0 // no -0
+	}
+	return rounded
+}
+
+// TemperatureFromNumber converts an upstream temperature decoded as a json.Number, rounding it to
+// precision decimals. An absent value is 0.
+func TemperatureFromNumber(n json.Number, precision int) (float64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return RoundTemperature(f, precision), nil
+}
+
+// OptionalTemperatureFromNumber is TemperatureFromNumber for temperatures the provider may leave
+// out: an absent value is nil.
+func OptionalTemperatureFromNumber(n json.Number, precision int) (*float64, error) {
+	if n == "" {
+		return nil, nil
+	}
+	t, err := TemperatureFromNumber(n, precision)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
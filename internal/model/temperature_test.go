@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoundTemperature(t *testing.T) {
+	tests := []struct {
+		celsius   float64
+		precision int
+		want      string
+	}{
+		{15.199999999999999, 1, "15.2"},
+		{15.199999999999999, 2, "15.2"},
+		{0.1 + 0.2, 2, "0.3"},
+		{-3.456, 1, "-3.5"},
+		{-0.04, 1, "0"},
+		{21.5, 0, "22"},
+		{283.15 - 273.15, 2, "10"},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(RoundTemperature(tt.celsius, tt.precision))
+		if string(got) != tt.want {
+			t.Errorf("RoundTemperature(%v, %d) = %s, want %s", tt.celsius, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestTemperatureFromNumber(t *testing.T) {
+	if got, err := TemperatureFromNumber("15.199999999", 2); err != nil || got != 15.2 {
+		t.Errorf("Expected 15.2, got %v, %v", got, err)
+	}
+	if got, err := TemperatureFromNumber("", 2); err != nil || got != 0 {
+		t.Errorf("Expected an absent value to be 0, got %v, %v", got, err)
+	}
+	if _, err := TemperatureFromNumber("warm", 2); err == nil {
+		t.Error("Expected an error for a non-numeric value")
+	}
+}
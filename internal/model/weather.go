@@ -1,8 +1,47 @@
 package model
 
+import "time"
+
 type WeatherResponse struct {
 	Location    string  `json:"location"`
 	Temperature float64 `json:"temperature"`
 	Description string  `json:"description"`
-	Cached      bool    `json:"cached"`
+	// Condition classes the weather the same way whichever provider reported it, and IconURL
+	// pictures it. They are unset when the provider reports no condition.
+	Condition Condition `json:"condition,omitempty"`
+	IconURL   string    `json:"icon_url,omitempty"`
+	Cached    bool      `json:"cached"`
+	Stale     bool      `json:"stale,omitempty"`
+	// ResolvedFrom is "ip" when the location was not given but taken from the caller's address.
+	ResolvedFrom string `json:"resolved_from,omitempty"`
+	// UVIndex is the current UV index, when openweathermap.uv_index is enabled and the provider
+	// had one. It is a pointer because 0, at night, is a value.
+	UVIndex *float64 `json:"uv_index,omitempty"`
+	// The details below are set when the provider reports them. FeelsLike, TempMin and TempMax are
+	// in °C, rounded like Temperature. TempMin and TempMax span the area around the location at
+	// this moment, not the day.
+	FeelsLike *float64 `json:"feels_like,omitempty"`
+	TempMin   *float64 `json:"temp_min,omitempty"`
+	TempMax   *float64 `json:"temp_max,omitempty"`
+	// Humidity is the relative humidity in percent.
+	Humidity *float64 `json:"humidity,omitempty"`
+	// Pressure is the atmospheric pressure at sea level in hPa.
+	Pressure *float64 `json:"pressure,omitempty"`
+	// WindSpeed is in m/s, and WindDirection is in degrees, where the wind blows from.
+	WindSpeed     *float64 `json:"wind_speed,omitempty"`
+	WindDirection *float64 `json:"wind_direction,omitempty"`
+	// Sunrise and Sunset are today's at the location, in its time zone. DayLength is the time
+	// between them in seconds. They are unset when the provider does not report them, and in polar
+	// day or night.
+	Sunrise   *time.Time `json:"sunrise,omitempty"`
+	Sunset    *time.Time `json:"sunset,omitempty"`
+	DayLength int64      `json:"day_length,omitempty"`
+	// TimezoneOffset is the location's offset from UTC in seconds. ObservedAt is when the provider
+	// observed the weather, in the location's time zone, so a cached response still tells how old
+	// and how local it is. They are unset when the provider does not report them.
+	TimezoneOffset *int64     `json:"timezone_offset,omitempty"`
+	ObservedAt     *time.Time `json:"observed_at,omitempty"`
+	// FetchedAt is when the provider last confirmed the data, or zero when unknown. It is not part
+	// of the response; the quality score is derived from it.
+	FetchedAt time.Time `json:"-"`
 }
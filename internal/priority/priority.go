@@ -0,0 +1,60 @@
+// Package priority classifies requests so that, under load, low-priority traffic is shed or
+// degraded before high-priority traffic.
+package priority
+
+import (
+	"context"
+	"strings"
+)
+
+// Class is a request priority. Higher values are served first.
+type Class int
+
+const (
+	Low Class = iota
+	Normal
+	High
+)
+
+func (c Class) String() string {
+	switch c {
+	case Low:
+		return "low"
+	case High:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// Parse returns the class named s ("low", "normal" or "high", in any case).
+func Parse(s string) (Class, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return Low, true
+	case "normal":
+		return Normal, true
+	case "high":
+		return High, true
+	default:
+		return Normal, false
+	}
+}
+
+type classKey struct{}
+
+// WithClass returns a copy of ctx carrying class.
+func WithClass(ctx context.Context, class Class) context.Context {
+	return context.WithValue(ctx, classKey{}, class)
+}
+
+// FromContext returns the class carried by ctx, or Normal if none is set.
+func FromContext(ctx context.Context) Class {
+	if ctx == nil {
+		return Normal
+	}
+	if class, ok := ctx.Value(classKey{}).(Class); ok {
+		return class
+	}
+	return Normal
+}
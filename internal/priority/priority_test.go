@@ -0,0 +1,39 @@
+package priority
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Class
+		ok   bool
+	}{
+		{"low", Low, true},
+		{" HIGH ", High, true},
+		{"normal", Normal, true},
+		{"urgent", Normal, false},
+		{"", Normal, false},
+	}
+	for _, tt := range tests {
+		if got, ok := Parse(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("Parse(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+	for _, c := range []Class{Low, Normal, High} {
+		if got, _ := Parse(c.String()); got != c {
+			t.Errorf("Expected %v to round trip, got %v", c, got)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != Normal {
+		t.Errorf("Expected Normal by default, got %v", got)
+	}
+	if got := FromContext(WithClass(context.Background(), High)); got != High {
+		t.Errorf("Expected High, got %v", got)
+	}
+}
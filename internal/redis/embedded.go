@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+)
+
+// embeddedClockInterval is how often the embedded server's clock catches up with the wall clock.
+const embeddedClockInterval = time.Second
+
+// embeddedServer is the in-process Redis started for redis.embedded. miniredis only expires keys
+// when its clock is advanced, so tick moves it forward by the wall-clock time since the last tick.
+type embeddedServer struct {
+	mr  *miniredis.Miniredis
+	now func() time.Time
+
+	mu   sync.Mutex
+	last time.Time
+	stop func()
+}
+
+// startEmbedded starts an in-process Redis on a free local port and the "embedded_redis_clock"
+// job that expires its keys. Everything it holds is lost when the process exits.
+func startEmbedded() (*embeddedServer, error) {
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		return nil, err
+	}
+	s := &embeddedServer{mr: mr, now: time.Now, last: time.Now()}
+	s.stop = jobs.New("embedded_redis_clock", embeddedClockInterval, func(context.Context) error {
+		s.tick()
+		return nil
+	}).Schedule(false)
+	logger().Warnw("Using embedded in-memory Redis; cached data is lost on exit", "addr", mr.Addr())
+	return s, nil
+}
+
+// close stops the clock job and the server.
+func (s *embeddedServer) close() {
+	s.stop()
+	s.mr.Close()
+}
+
+func (s *embeddedServer) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.mr.FastForward(now.Sub(s.last))
+	s.last = now
+}
@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestNewClient_Embedded(t *testing.T) {
+	ResetClientForTest()
+	client = newClient(config.RedisConfig{Mode: "standalone", Embedded: true})
+	defer ResetClientForTest()
+	ctx := context.Background()
+
+	if err := client.Set(ctx, "weather:London", "cached", time.Minute).Err(); err != nil {
+		t.Fatalf("Expected the embedded server to accept writes: %v", err)
+	}
+	if got, err := client.Get(ctx, "weather:London").Result(); err != nil || got != "cached" {
+		t.Errorf("Expected to read back the value, got %q, %v", got, err)
+	}
+
+	addr := embedded.mr.Addr()
+	Close()
+	if err := client.Ping(ctx).Err(); err == nil {
+		t.Error("Expected the client to be closed")
+	}
+	if _, err := redisv9.NewClient(&redisv9.Options{Addr: addr}).Ping(ctx).Result(); err == nil {
+		t.Error("Expected the embedded server to be stopped")
+	}
+}
+
+func TestEmbeddedServer_ExpiresKeys(t *testing.T) {
+	s, err := startEmbedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	now := time.Now()
+	s.mu.Lock()
+	s.now = func() time.Time { return now }
+	s.last = now
+	s.mu.Unlock()
+	// The clock job may tick concurrently, so now is only changed under the server's lock
+	advance := func(d time.Duration) {
+		s.mu.Lock()
+		now = now.Add(d)
+		s.mu.Unlock()
+		s.tick()
+	}
+	client := redisv9.NewClient(&redisv9.Options{Addr: s.mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	_ = client.Set(ctx, "short", "v", 2*time.Second).Err()
+	advance(time.Second)
+	if err := client.Get(ctx, "short").Err(); err != nil {
+		t.Fatalf("Expected the key to live for 2s, got %v", err)
+	}
+	advance(1500 * time.Millisecond)
+	if err := client.Get(ctx, "short").Err(); !errors.Is(err, redisv9.Nil) {
+		t.Errorf("Expected the key to expire with the wall clock, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	redisv9 "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	poolHits = metrics.NewCounterVec("weather_redis_pool_hits_total",
+		"Times a free connection was found in the Redis pool.", "pool")
+	poolMisses = metrics.NewCounterVec("weather_redis_pool_misses_total",
+		"Times no free connection was found in the Redis pool and one was dialled or waited for.", "pool")
+	poolWaits = metrics.NewCounterVec("weather_redis_pool_waits_total",
+		"Times a command waited for a connection because the Redis pool was full.", "pool")
+	poolTimeouts = metrics.NewCounterVec("weather_redis_pool_timeouts_total",
+		"Times a command gave up waiting for a connection from the full Redis pool.", "pool")
+	poolStaleConns = metrics.NewCounterVec("weather_redis_pool_stale_conns_total",
+		"Stale connections removed from the Redis pool.", "pool")
+	poolConns = metrics.NewGaugeVec("weather_redis_pool_connections",
+		"Connections in the Redis pool by state (total or idle), as of the last sample.", "pool", "state")
+	pingSeconds = metrics.NewGauge("weather_redis_ping_seconds",
+		"Round trip of the last Redis PING, in seconds.")
+	pingFailures = metrics.NewCounter("weather_redis_ping_failures_total",
+		"Redis PINGs that failed.")
+)
+
+// logger returns the redis module logger, whose level is set by logging.levels.redis.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("redis")
+}
+
+// poolStatser is implemented by every go-redis client.
+type poolStatser interface {
+	PoolStats() *redisv9.PoolStats
+}
+
+// poolSampler turns the cumulative statistics of one pool into counter increments.
+type poolSampler struct {
+	name  string
+	stats func() *redisv9.PoolStats
+	last  redisv9.PoolStats
+}
+
+// healthCheck samples the connection pools and pings Redis.
+type healthCheck struct {
+	ping  func(ctx context.Context) error
+	pools []*poolSampler
+}
+
+// StartHealthCheck schedules the "redis_health" job, which every redis.health_interval exports
+// the connection pool statistics and the PING latency of the primary, and warns when a pool is
+// exhausted, until the returned function is called. Read replicas are reported as the "replica"
+// pool.
+func StartHealthCheck() (stop func()) {
+	h := &healthCheck{
+		ping:  func(ctx context.Context) error { return GetClient().Ping(ctx).Err() },
+		pools: []*poolSampler{{name: "primary", stats: GetClient().PoolStats}},
+	}
+	if r, ok := GetReadClient().(poolStatser); ok && GetReadClient() != Reader(GetClient()) {
+		h.pools = append(h.pools, &poolSampler{name: "replica", stats: r.PoolStats})
+	}
+	interval := config.GetRedisConfig().HealthInterval
+	return jobs.New("redis_health", interval, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, interval)
+		defer cancel()
+		return h.check(ctx)
+	}).Schedule(true)
+}
+
+func (h *healthCheck) check(ctx context.Context) error {
+	for _, p := range h.pools {
+		p.sample()
+	}
+	start := time.Now()
+	if err := h.ping(ctx); err != nil {
+		pingFailures.Inc()
+		logger().Warnw("Redis ping failed", "error", err)
+		return err
+	}
+	pingSeconds.Set(time.Since(start).Seconds())
+	return nil
+}
+
+// sample records the pool statistics gathered since the previous sample. A pool that is waited
+// on is saturated; one that times out is exhausted and fails commands, so it is sized too small
+// for the load.
+func (p *poolSampler) sample() {
+	cur := *p.stats()
+	hits, misses := delta(cur.Hits, p.last.Hits), delta(cur.Misses, p.last.Misses)
+	waits, timeouts := delta(cur.WaitCount, p.last.WaitCount), delta(cur.Timeouts, p.last.Timeouts)
+	poolHits.WithLabelValues(p.name).Add(hits)
+	poolMisses.WithLabelValues(p.name).Add(misses)
+	poolWaits.WithLabelValues(p.name).Add(waits)
+	poolTimeouts.WithLabelValues(p.name).Add(timeouts)
+	poolStaleConns.WithLabelValues(p.name).Add(delta(cur.StaleConns, p.last.StaleConns))
+	poolConns.WithLabelValues(p.name, "total").Set(float64(cur.TotalConns))
+	poolConns.WithLabelValues(p.name, "idle").Set(float64(cur.IdleConns))
+	p.last = cur
+
+	switch {
+	case timeouts > 0:
+		logger().Warnw("Redis connection pool exhausted, commands timed out waiting for a connection",
+			"pool", p.name, "timeouts", timeouts, "waits", waits, "totalConns", cur.TotalConns)
+	case waits > 0:
+		logger().Infow("Redis connection pool saturated, commands waited for a connection",
+			"pool", p.name, "waits", waits, "totalConns", cur.TotalConns)
+	}
+}
+
+// delta returns the increase of a cumulative pool counter. A counter below its last value
+// belongs to a new client, so all of it is new.
+func delta(cur, last uint32) float64 {
+	if cur < last {
+		return float64(cur)
+	}
+	return float64(cur - last)
+}
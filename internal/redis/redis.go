@@ -2,32 +2,179 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/fakhrymubarak/weather-api-redis/internal/config"
 	redisv9 "github.com/redis/go-redis/v9"
 )
 
+// mu guards the client singletons, which tests reset while other goroutines may still use them.
 var (
-	client *redisv9.Client
-	once   sync.Once
+	mu     sync.Mutex
+	client redisv9.UniversalClient
+	reader Reader
+	// embedded is the in-process server started for redis.embedded
+	embedded *embeddedServer
 )
 
-func GetClient() *redisv9.Client {
-	once.Do(func() {
-		client = redisv9.NewClient(&redisv9.Options{
-			Addr: config.GetRedisAddr(),
-		})
-	})
+// GetClient returns the client for the primary, used for writes and for reads that must see them.
+// Its type follows redis.mode: a single server, a sentinel-managed master or a cluster.
+func GetClient() redisv9.UniversalClient {
+	mu.Lock()
+	defer mu.Unlock()
+	return primaryLocked()
+}
+
+// primaryLocked returns the primary client, creating it on first use. mu is held.
+func primaryLocked() redisv9.UniversalClient {
+	if client == nil {
+		client = newClient(config.GetRedisConfig())
+	}
 	return client
 }
 
+func newClient(cfg config.RedisConfig) redisv9.UniversalClient {
+	if cfg.Embedded {
+		server, err := startEmbedded()
+		if err != nil {
+			logger().Fatalw("Failed to start embedded Redis", "error", err)
+		}
+		embedded = server
+		return redisv9.NewClient(&redisv9.Options{Addr: server.mr.Addr()})
+	}
+	switch cfg.Mode {
+	case "sentinel":
+		return redisv9.NewFailoverClient(&redisv9.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.Addrs,
+		})
+	case "cluster":
+		// ReadOnly lets the cluster client send read-only commands to replicas of each slot
+		return redisv9.NewClusterClient(&redisv9.ClusterOptions{
+			Addrs:         cfg.Addrs,
+			ReadOnly:      cfg.ReadFromReplicas,
+			RouteRandomly: cfg.ReadFromReplicas,
+		})
+	default:
+		return redisv9.NewClient(&redisv9.Options{Addr: cfg.Addr})
+	}
+}
+
+// Reader is the read side of the cache.
+type Reader interface {
+	Get(ctx context.Context, key string) *redisv9.StringCmd
+}
+
+// MultiReader is a Reader that also fetches several keys in one round trip. Every reader
+// GetReadClient returns implements it.
+type MultiReader interface {
+	Reader
+	MGet(ctx context.Context, keys ...string) *redisv9.SliceCmd
+}
+
+// GetReadClient returns the client cache reads go to. Reads may lag writes by the replication delay.
+//   - standalone: redis.replicas in turn, falling back to the primary when a replica fails;
+//   - sentinel with read_from_replicas: a replica-only client, which falls back to the master
+//     when no replica is reachable;
+//   - cluster: the primary client, which routes reads to replicas itself when read_from_replicas
+//     is set.
+//
+// Without replicas, it is the primary client.
+func GetReadClient() Reader {
+	mu.Lock()
+	defer mu.Unlock()
+	if reader == nil {
+		reader = newReader(config.GetRedisConfig(), primaryLocked())
+	}
+	return reader
+}
+
+func newReader(cfg config.RedisConfig, primary redisv9.UniversalClient) MultiReader {
+	switch {
+	case cfg.Mode == "sentinel" && cfg.ReadFromReplicas:
+		return redisv9.NewFailoverClient(&redisv9.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.Addrs,
+			ReplicaOnly:   true,
+		})
+	case cfg.Mode == "standalone" && len(cfg.Replicas) > 0:
+		r := &replicaReader{primary: primary}
+		for _, addr := range cfg.Replicas {
+			r.replicas = append(r.replicas, redisv9.NewClient(&redisv9.Options{Addr: addr}))
+		}
+		return r
+	default:
+		return primary
+	}
+}
+
+// replicaReader spreads reads over standalone replicas in turn.
+type replicaReader struct {
+	primary  MultiReader
+	replicas []*redisv9.Client
+	next     atomic.Uint64
+}
+
+func (r *replicaReader) Get(ctx context.Context, key string) *redisv9.StringCmd {
+	replica := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+	cmd := replica.Get(ctx, key)
+	if err := cmd.Err(); err != nil && !errors.Is(err, redisv9.Nil) && ctx.Err() == nil {
+		return r.primary.Get(ctx, key)
+	}
+	return cmd
+}
+
+func (r *replicaReader) MGet(ctx context.Context, keys ...string) *redisv9.SliceCmd {
+	replica := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+	cmd := replica.MGet(ctx, keys...)
+	if err := cmd.Err(); err != nil && ctx.Err() == nil {
+		return r.primary.MGet(ctx, keys...)
+	}
+	return cmd
+}
+
+// PoolStats returns the connection pool statistics summed over the replicas.
+func (r *replicaReader) PoolStats() *redisv9.PoolStats {
+	var acc redisv9.PoolStats
+	for _, replica := range r.replicas {
+		s := replica.PoolStats()
+		acc.Hits += s.Hits
+		acc.Misses += s.Misses
+		acc.Timeouts += s.Timeouts
+		acc.WaitCount += s.WaitCount
+		acc.WaitDurationNs += s.WaitDurationNs
+		acc.TotalConns += s.TotalConns
+		acc.IdleConns += s.IdleConns
+		acc.StaleConns += s.StaleConns
+	}
+	return &acc
+}
+
+// Close closes the primary client and, with redis.embedded, stops the in-process server. Nothing
+// may use Redis afterwards, so it is the last thing stopped on shutdown.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+	if embedded != nil {
+		embedded.close()
+		embedded = nil
+	}
+}
+
 func GetContext() context.Context {
 	return context.Background()
 }
 
-// ResetClientForTest resets the Redis client singleton. Use only in tests.
+// ResetClientForTest resets the Redis client singletons, so the next GetClient reads the config
+// again. Clients already handed out keep working. Use only in tests.
 func ResetClientForTest() {
-	once = sync.Once{}
+	mu.Lock()
+	defer mu.Unlock()
 	client = nil
+	reader = nil
 }
@@ -1,7 +1,14 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	redisv9 "github.com/redis/go-redis/v9"
 )
 
 func TestGetClient(t *testing.T) {
@@ -52,6 +59,39 @@ func TestResetClientForTest(t *testing.T) {
 	}
 }
 
+func TestGetClient_ConcurrentReset(t *testing.T) {
+	defer ResetClientForTest()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if GetClient() == nil {
+					t.Error("Expected a client while others reset it")
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if GetReadClient() == nil {
+					t.Error("Expected a read client while others reset it")
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ResetClientForTest()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func BenchmarkGetClient(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -65,3 +105,114 @@ func BenchmarkGetContext(b *testing.B) {
 		_ = GetContext()
 	}
 }
+
+func TestNewClient_Modes(t *testing.T) {
+	if _, ok := newClient(config.RedisConfig{Mode: "standalone", Addr: "localhost:6379"}).(*redisv9.Client); !ok {
+		t.Error("Expected a single-server client in standalone mode")
+	}
+	if _, ok := newClient(config.RedisConfig{Mode: "cluster", Addrs: []string{"localhost:7000"}}).(*redisv9.ClusterClient); !ok {
+		t.Error("Expected a cluster client in cluster mode")
+	}
+	sentinel := config.RedisConfig{Mode: "sentinel", Addrs: []string{"localhost:26379"}, MasterName: "mymaster"}
+	if _, ok := newClient(sentinel).(*redisv9.Client); !ok {
+		t.Error("Expected a failover client in sentinel mode")
+	}
+
+	primary := newClient(config.RedisConfig{Mode: "standalone", Addr: "localhost:6379"})
+	if newReader(config.RedisConfig{Mode: "standalone"}, primary) != primary {
+		t.Error("Expected reads on the primary without replicas")
+	}
+	if newReader(sentinel, primary) != primary {
+		t.Error("Expected sentinel reads on the master unless read_from_replicas is set")
+	}
+	sentinel.ReadFromReplicas = true
+	if newReader(sentinel, primary) == primary {
+		t.Error("Expected a replica-only client with read_from_replicas")
+	}
+}
+
+func TestReplicaReader(t *testing.T) {
+	primaryServer := miniredis.RunT(t)
+	replica1, replica2 := miniredis.RunT(t), miniredis.RunT(t)
+	primaryServer.Set("k", "primary")
+	replica1.Set("k", "replica-1")
+	replica2.Set("k", "replica-2")
+
+	primary := redisv9.NewClient(&redisv9.Options{Addr: primaryServer.Addr()})
+	t.Cleanup(func() { _ = primary.Close() })
+	r := newReader(config.RedisConfig{Mode: "standalone", Replicas: []string{replica1.Addr(), replica2.Addr()}}, primary)
+
+	ctx := context.Background()
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		v, err := r.Get(ctx, "k").Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		seen[v] = true
+	}
+	if !seen["replica-1"] || !seen["replica-2"] || seen["primary"] {
+		t.Errorf("Expected reads spread over the replicas only, got %v", seen)
+	}
+
+	if _, err := r.Get(ctx, "missing").Result(); !errors.Is(err, redisv9.Nil) {
+		t.Errorf("Expected a replica miss to be returned as is, got %v", err)
+	}
+
+	if vals, err := r.MGet(ctx, "k", "missing").Result(); err != nil || len(vals) != 2 || vals[0] == "primary" || vals[1] != nil {
+		t.Errorf("Expected a multi-get from a replica, got %v, %v", vals, err)
+	}
+
+	replica1.Close()
+	replica2.Close()
+	if v, err := r.Get(ctx, "k").Result(); err != nil || v != "primary" {
+		t.Errorf("Expected a fallback to the primary, got %q, %v", v, err)
+	}
+	if vals, err := r.MGet(ctx, "k").Result(); err != nil || len(vals) != 1 || vals[0] != "primary" {
+		t.Errorf("Expected a multi-get fallback to the primary, got %v, %v", vals, err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	stats := &redisv9.PoolStats{Hits: 10, Misses: 2, TotalConns: 3, IdleConns: 1}
+	p := &poolSampler{name: "test", stats: func() *redisv9.PoolStats { s := *stats; return &s }}
+	pingErr := error(nil)
+	h := &healthCheck{ping: func(context.Context) error { return pingErr }, pools: []*poolSampler{p}}
+	// The counters are global, so they are compared with their values before the checks
+	hitsBefore := poolHits.WithLabelValues("test").Value()
+	waitsBefore, timeoutsBefore := poolWaits.WithLabelValues("test").Value(), poolTimeouts.WithLabelValues("test").Value()
+
+	if err := h.check(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if v := poolHits.WithLabelValues("test").Value() - hitsBefore; v != 10 {
+		t.Errorf("Expected 10 hits, got %v", v)
+	}
+	if v := poolConns.WithLabelValues("test", "idle").Value(); v != 1 {
+		t.Errorf("Expected 1 idle connection, got %v", v)
+	}
+
+	stats.Hits, stats.WaitCount, stats.Timeouts = 15, 4, 1
+	_ = h.check(context.Background())
+	if v := poolHits.WithLabelValues("test").Value() - hitsBefore; v != 15 {
+		t.Errorf("Expected hits to grow by the difference, got %v", v)
+	}
+	if w, to := poolWaits.WithLabelValues("test").Value()-waitsBefore, poolTimeouts.WithLabelValues("test").Value()-timeoutsBefore; w != 4 || to != 1 {
+		t.Errorf("Expected 4 waits and 1 timeout, got %v and %v", w, to)
+	}
+
+	before := pingFailures.Value()
+	pingErr = errors.New("connection refused")
+	if err := h.check(context.Background()); err == nil || pingFailures.Value() != before+1 {
+		t.Errorf("Expected a failed ping to be counted and returned, got %v", err)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	if d := delta(7, 5); d != 2 {
+		t.Errorf("Expected 2, got %v", d)
+	}
+	if d := delta(3, 5); d != 3 {
+		t.Errorf("Expected a reset counter to count from zero, got %v", d)
+	}
+}
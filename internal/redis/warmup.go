@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// WarmUp opens conns connections to the primary, to every shard in cluster mode, and to each read
+// replica, and leaves them idle in the pools, so the first requests do not wait for a dial.
+func WarmUp(ctx context.Context, conns int) error {
+	primary := GetClient()
+	errs := []error{warmUp(ctx, primary, conns)}
+	switch r := GetReadClient().(type) {
+	case *replicaReader:
+		for _, replica := range r.replicas {
+			errs = append(errs, openConns(ctx, replica, conns))
+		}
+	case *redisv9.Client:
+		if r != primary {
+			errs = append(errs, openConns(ctx, r, conns))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func warmUp(ctx context.Context, c redisv9.UniversalClient, conns int) error {
+	switch c := c.(type) {
+	case *redisv9.ClusterClient:
+		return c.ForEachShard(ctx, func(ctx context.Context, shard *redisv9.Client) error {
+			return openConns(ctx, shard, conns)
+		})
+	case *redisv9.Client:
+		return openConns(ctx, c, conns)
+	default:
+		return c.Ping(ctx).Err()
+	}
+}
+
+// openConns checks n connections out of c's pool at once, pinging each, then returns them all.
+// Holding them together makes the pool dial a new one for each instead of reusing the first.
+func openConns(ctx context.Context, c *redisv9.Client, n int) error {
+	held := make([]*redisv9.Conn, 0, n)
+	defer func() {
+		for _, conn := range held {
+			_ = conn.Close()
+		}
+	}()
+	for range n {
+		conn := c.Conn()
+		held = append(held, conn)
+		if err := conn.Ping(ctx).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
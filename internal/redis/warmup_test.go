@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestWarmUp(t *testing.T) {
+	primaryServer, replicaServer := miniredis.RunT(t), miniredis.RunT(t)
+	primary := redisv9.NewClient(&redisv9.Options{Addr: primaryServer.Addr()})
+	r := newReader(config.RedisConfig{Mode: "standalone", Replicas: []string{replicaServer.Addr()}}, primary).(*replicaReader)
+	mu.Lock()
+	client, reader = primary, r
+	mu.Unlock()
+	t.Cleanup(func() {
+		_ = primary.Close()
+		_ = r.replicas[0].Close()
+		ResetClientForTest()
+	})
+
+	if err := WarmUp(context.Background(), 3); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for name, stats := range map[string]*redisv9.PoolStats{"primary": primary.PoolStats(), "replica": r.PoolStats()} {
+		if stats.TotalConns != 3 || stats.IdleConns != 3 {
+			t.Errorf("Expected 3 idle %s connections, got %+v", name, stats)
+		}
+	}
+
+	replicaServer.Close()
+	if err := WarmUp(context.Background(), 1); err == nil {
+		t.Error("Expected an unreachable replica reported")
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+var (
+	adaptiveTTLDecisions = metrics.NewCounterVec("weather_cache_adaptive_ttl_decisions_total",
+		"Adaptive cache TTL decisions after an upstream fetch.", "decision")
+	adaptiveTTLSeconds = metrics.NewGaugeVec("weather_cache_adaptive_ttl_seconds",
+		"Current adaptive cache TTL per location.", "location")
+)
+
+// Adaptive TTL decisions, used as the metrics label.
+const (
+	ttlLengthen = "lengthen"
+	ttlShorten  = "shorten"
+	ttlKeep     = "keep"
+)
+
+// volatilityStats is the per-location observation history stored under "volatility:<location>".
+type volatilityStats struct {
+	Temperature float64 `json:"temperature"`
+	Description string  `json:"description"`
+	TTLSeconds  int64   `json:"ttl_seconds"`
+	Samples     int     `json:"samples"`
+}
+
+// volatilityStatsTTL keeps stats long enough to outlive several cache cycles at the maximum TTL.
+const volatilityStatsTTL = 24 * time.Hour
+
+// entryTTL returns how long a freshly fetched observation for location stays fresh. With adaptive
+// TTL disabled this is the configured cache expiration, unless the request takes part in an
+// adaptive_ttl experiment.
+func (r *weatherRepository) entryTTL(ctx context.Context, location string, weather *model.WeatherResponse) time.Duration {
+	cfg := config.GetAdaptiveTTLConfig()
+	if !cfg.Enabled && experiment.VariantFrom(ctx) != experiment.AdaptiveTTL {
+		return cacheTTL()
+	}
+
+	key := "volatility:" + location
+	var stats volatilityStats
+	if val, err := r.redisClient.Get(ctx, key).Result(); err == nil {
+		if err := json.Unmarshal([]byte(val), &stats); err != nil {
+			stats = volatilityStats{}
+		}
+	}
+
+	ttl, decision := adaptTTL(cfg, stats, weather)
+	adaptiveTTLDecisions.WithLabelValues(decision).Inc()
+	adaptiveTTLSeconds.WithLabelValues(location).Set(ttl.Seconds())
+	logger(ctx).Debugw("Adaptive TTL", "decision", decision, "ttl", ttl)
+
+	stats = volatilityStats{
+		Temperature: weather.Temperature,
+		Description: weather.Description,
+		TTLSeconds:  int64(ttl / time.Second),
+		Samples:     stats.Samples + 1,
+	}
+	if b, err := json.Marshal(stats); err == nil {
+		_ = r.redisClient.Set(ctx, key, b, volatilityStatsTTL).Err()
+	}
+	return ttl
+}
+
+// adaptTTL doubles the previous TTL when the temperature moved at most StableDelta, halves it when
+// it moved at least VolatileDelta or the description changed, and otherwise keeps it. The result is
+// clamped to [Min, Max]. The first observation starts from the configured cache expiration.
+func adaptTTL(cfg config.AdaptiveTTLConfig, prev volatilityStats, weather *model.WeatherResponse) (time.Duration, string) {
+	clamp := func(d time.Duration) time.Duration { return min(max(d, cfg.Min), cfg.Max) }
+	if prev.Samples == 0 || prev.TTLSeconds <= 0 {
+		return clamp(cacheTTL()), ttlKeep
+	}
+
+	ttl := time.Duration(prev.TTLSeconds) * time.Second
+	delta := math.Abs(weather.Temperature - prev.Temperature)
+	switch {
+	case delta >= cfg.VolatileDelta || weather.Description != prev.Description:
+		return clamp(ttl / 2), ttlShorten
+	case delta <= cfg.StableDelta:
+		return clamp(ttl * 2), ttlLengthen
+	default:
+		return clamp(ttl), ttlKeep
+	}
+}
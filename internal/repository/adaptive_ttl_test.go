@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func TestAdaptTTL(t *testing.T) {
+	cfg := config.AdaptiveTTLConfig{Min: time.Minute, Max: time.Hour, StableDelta: 0.5, VolatileDelta: 2}
+	prev := volatilityStats{Temperature: 15, Description: "clear sky", TTLSeconds: 600, Samples: 3}
+
+	tests := []struct {
+		name         string
+		prev         volatilityStats
+		weather      model.WeatherResponse
+		wantTTL      time.Duration
+		wantDecision string
+	}{
+		{"First observation", volatilityStats{}, model.WeatherResponse{Temperature: 15}, 0, ttlKeep},
+		{"Stable doubles", prev, model.WeatherResponse{Temperature: 15.3, Description: "clear sky"}, 20 * time.Minute, ttlLengthen},
+		{"Moderate keeps", prev, model.WeatherResponse{Temperature: 16, Description: "clear sky"}, 10 * time.Minute, ttlKeep},
+		{"Volatile halves", prev, model.WeatherResponse{Temperature: 18, Description: "clear sky"}, 5 * time.Minute, ttlShorten},
+		{"Description change halves", prev, model.WeatherResponse{Temperature: 15, Description: "rain"}, 5 * time.Minute, ttlShorten},
+		{"Clamped to max", volatilityStats{Temperature: 15, TTLSeconds: 3000, Samples: 1}, model.WeatherResponse{Temperature: 15}, time.Hour, ttlLengthen},
+		{"Clamped to min", volatilityStats{Temperature: 15, TTLSeconds: 90, Samples: 1}, model.WeatherResponse{Temperature: 25}, time.Minute, ttlShorten},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.wantTTL
+			if tt.prev.Samples == 0 {
+				// Starts from the configured cache expiration, clamped
+				want = min(max(cacheTTL(), cfg.Min), cfg.Max)
+			}
+			ttl, decision := adaptTTL(cfg, tt.prev, &tt.weather)
+			if ttl != want || decision != tt.wantDecision {
+				t.Errorf("Expected %v (%s), got %v (%s)", want, tt.wantDecision, ttl, decision)
+			}
+		})
+	}
+}
+
+func TestEntryTTL_PersistsStatsAndMetrics(t *testing.T) {
+	viper.Set("cache.adaptive_ttl.enabled", true)
+	viper.Set("cache.adaptive_ttl.min", "1s")
+	defer func() {
+		viper.Set("cache.adaptive_ttl.enabled", nil)
+		viper.Set("cache.adaptive_ttl.min", nil)
+	}()
+
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	defer client.Close()
+	repo := &weatherRepository{redisClient: client}
+	ctx := context.Background()
+
+	weather := &model.WeatherResponse{Location: "Oslo", Temperature: 3, Description: "snow"}
+	first := repo.entryTTL(ctx, "Oslo", weather)
+	lengthened := adaptiveTTLDecisions.WithLabelValues(ttlLengthen).Value()
+	second := repo.entryTTL(ctx, "Oslo", weather)
+
+	if second != 2*first {
+		t.Errorf("Expected an unchanged observation to double the TTL from %v, got %v", first, second)
+	}
+	if adaptiveTTLDecisions.WithLabelValues(ttlLengthen).Value() != lengthened+1 {
+		t.Error("Expected the lengthen decision to be counted")
+	}
+	if got := adaptiveTTLSeconds.WithLabelValues("Oslo").Value(); got != second.Seconds() {
+		t.Errorf("Expected TTL gauge %v, got %v", second.Seconds(), got)
+	}
+
+	raw, err := mr.Get("volatility:Oslo")
+	if err != nil {
+		t.Fatalf("Expected stats in Redis: %v", err)
+	}
+	var stats volatilityStats
+	_ = json.Unmarshal([]byte(raw), &stats)
+	if stats.Samples != 2 || stats.TTLSeconds != int64(second/time.Second) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestEntryTTL_AdaptiveTTLExperiment(t *testing.T) {
+	client, mr := newRedisClient(t)
+	repo := &weatherRepository{redisClient: client}
+	weather := &model.WeatherResponse{Location: "Oslo", Temperature: 3, Description: "snow"}
+
+	if ttl := repo.entryTTL(context.Background(), "Oslo", weather); ttl != cacheTTL() || mr.Exists("volatility:Oslo") {
+		t.Errorf("Expected the configured expiration with adaptive TTL disabled, got %v", ttl)
+	}
+	ctx := experiment.WithAssignment(context.Background(), experiment.Assignment{ID: "ttl", Variant: experiment.AdaptiveTTL})
+	repo.entryTTL(ctx, "Oslo", weather)
+	if !mr.Exists("volatility:Oslo") {
+		t.Error("Expected adaptive TTL to apply to requests in the experiment")
+	}
+}
+
+// useFakeCacheClock makes cache entries age only as the returned clock is advanced.
+func useFakeCacheClock(t *testing.T) *clock.Fake {
+	t.Helper()
+	c := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cacheClock = c
+	t.Cleanup(func() { cacheClock = clock.Real })
+	return c
+}
+
+func TestGetWeather_AdaptiveTTLExpiry(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	viper.Set("cache.adaptive_ttl.enabled", true)
+	defer viper.Set("cache.adaptive_ttl.enabled", nil)
+	c := useFakeCacheClock(t)
+
+	client, _ := newRedisClient(t)
+	calls := 0
+	repo := &weatherRepository{redisClient: client, httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name":"Oslo","main":{"temp":3},"weather":[{"description":"snow"}]}`)),
+			Header:     make(http.Header),
+		}
+	})}
+	get := func(wantCalls int, msg string) {
+		t.Helper()
+		if _, err := repo.GetWeather(context.Background(), "Oslo"); err != nil {
+			t.Fatalf("GetWeather: %v", err)
+		}
+		if calls != wantCalls {
+			t.Fatalf("%s: expected %d provider calls, got %d", msg, wantCalls, calls)
+		}
+	}
+
+	// The first entry is fresh for the configured expiration
+	ttl := cacheTTL()
+	get(1, "first request")
+	c.Advance(ttl - time.Second)
+	get(1, "just before the expiration")
+	c.Advance(time.Second)
+	get(2, "at the expiration")
+
+	// Unchanged weather doubles the TTL of the next entry
+	c.Advance(2*ttl - time.Second)
+	get(2, "just before the doubled expiration")
+	c.Advance(time.Second)
+	get(3, "at the doubled expiration")
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// AlertsRepository defines the interface for weather alert data access.
+type AlertsRepository interface {
+	GetAlerts(ctx context.Context, location string) (*model.AlertsResponse, error)
+}
+
+// alertsRepository shares the Redis clients, write queue, provider client and cool-down of the
+// weather repository.
+type alertsRepository struct {
+	*weatherRepository
+}
+
+// NewAlertsRepository creates an alerts repository. Provider calls use httpClient when given.
+func NewAlertsRepository(httpClient ...*http.Client) AlertsRepository {
+	return &alertsRepository{NewWeatherRepository(httpClient...).(*weatherRepository)}
+}
+
+// alertsKey is scoped by tenant, like the forecast keys.
+func alertsKey(ctx context.Context, location string) string {
+	return tenantScope(ctx) + "alerts:" + location
+}
+
+// GetAlerts returns the government weather alerts for location from the One Call API, from the
+// cache when it holds them younger than alerts.cache_ttl. The One Call API is asked by
+// coordinates, taken from the city dataset or else geocoded.
+func (r *alertsRepository) GetAlerts(ctx context.Context, location string) (*model.AlertsResponse, error) {
+	ctx = logctx.WithLocation(ctx, location)
+	alerts, cached, err := cachedValue(ctx, r.weatherRepository, alertsKey(ctx, location), config.GetAlertsConfig().CacheTTL, func() (*model.AlertsResponse, error) {
+		coords, err := r.alertCoordinates(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		return r.fetchAlerts(ctx, location, coords)
+	})
+	if err != nil {
+		return nil, err
+	}
+	alerts.Cached = cached
+	return alerts, nil
+}
+
+// alertCoordinates places location by the city dataset, or by its best geocoding match in the
+// country it names.
+func (r *alertsRepository) alertCoordinates(ctx context.Context, location string) (model.Coordinates, error) {
+	if city, ok := geodata.Lookup(location); ok {
+		return model.Coordinates{Lat: city.Lat, Lon: city.Lon}, nil
+	}
+	candidate := r.geocodeMatch(ctx, location, locationCountry(location))
+	if candidate == nil {
+		return model.Coordinates{}, &LocationNotFoundError{Message: "city not found"}
+	}
+	return model.Coordinates{Lat: candidate.Lat, Lon: candidate.Lon}, nil
+}
+
+// fetchAlerts calls the One Call API for the alerts at coords, leaving out everything else.
+func (r *alertsRepository) fetchAlerts(ctx context.Context, location string, coords model.Coordinates) (*model.AlertsResponse, error) {
+	logger(ctx).Debugw("Fetching alerts from external API")
+	apiKey, _, _ := upstreamFor(ctx)
+	if apiKey == "" {
+		return nil, ErrAPIKeyMissing
+	}
+	u := fmt.Sprintf("%s?lat=%s&lon=%s&exclude=current,minutely,hourly,daily&appid=%s", config.GetOpenWeatherOneCallURL(),
+		strconv.FormatFloat(coords.Lat, 'f', -1, 64), strconv.FormatFloat(coords.Lon, 'f', -1, 64), apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	default:
+		return nil, fmt.Errorf("%w: alerts status %d", ErrExternalAPI, resp.StatusCode)
+	}
+	endCoolDownStreak(ctx)
+
+	var data model.OpenWeatherMapAlertsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	alerts := &model.AlertsResponse{Location: location, Coordinates: coords, Alerts: make([]model.WeatherAlert, 0, len(data.Alerts))}
+	for _, a := range data.Alerts {
+		alerts.Alerts = append(alerts.Alerts, model.WeatherAlert{
+			Event:       a.Event,
+			Severity:    model.AlertSeverity(a.Event, a.Tags),
+			Sender:      a.SenderName,
+			Start:       time.Unix(a.Start, 0).UTC(),
+			End:         time.Unix(a.End, 0).UTC(),
+			Description: a.Description,
+		})
+	}
+	sort.SliceStable(alerts.Alerts, func(i, j int) bool { return alerts.Alerts[i].Start.Before(alerts.Alerts[j].Start) })
+	return alerts, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+const alertsBody = `{"lat":59.9127,"lon":10.7461,"alerts":[
+	{"sender_name":"MET Norway","event":"Yellow wind warning","start":1735740000,"end":1735783200,"description":"Strong gusts.","tags":["Wind"]},
+	{"sender_name":"MET Norway","event":"Orange avalanche warning","start":1735725600,"end":1735812000,"description":"Considerable avalanche danger.","tags":["Avalanches"]}
+]}`
+
+func newAlertsRepository(t *testing.T, status int) (*alertsRepository, *[]string) {
+	t.Helper()
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	resetCoolDowns(t)
+	viper.Set("openweathermap.onecall_url", "http://onecall.test/onecall")
+	viper.Set("openweathermap.geocoding_url", "http://geo.test/direct")
+	t.Cleanup(func() {
+		viper.Set("openweathermap.onecall_url", nil)
+		viper.Set("openweathermap.geocoding_url", nil)
+	})
+	client, _ := newRedisClient(t)
+	var calls []string
+	repo := &alertsRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			calls = append(calls, req.URL.Host+"?"+req.URL.RawQuery)
+			body := alertsBody
+			if req.URL.Host == "geo.test" {
+				body = `[{"name":"Longyearbyen","country":"SJ","lat":78.2232,"lon":15.6267}]`
+				if strings.HasPrefix(req.URL.Query().Get("q"), "Nowhere") {
+					body = "[]"
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+		}),
+	}}
+	return repo, &calls
+}
+
+func TestGetAlerts(t *testing.T) {
+	repo, calls := newAlertsRepository(t, http.StatusOK)
+	ctx := context.Background()
+
+	alerts, err := repo.GetAlerts(ctx, "Oslo")
+	if err != nil {
+		t.Fatalf("GetAlerts: %v", err)
+	}
+	if alerts.Cached || alerts.Location != "Oslo" || alerts.Coordinates != (model.Coordinates{Lat: 59.9127, Lon: 10.7461}) || len(alerts.Alerts) != 2 {
+		t.Fatalf("Unexpected alerts %+v", alerts)
+	}
+	first := alerts.Alerts[0]
+	if first.Event != "Orange avalanche warning" || first.Severity != model.SeveritySevere || first.Sender != "MET Norway" || !first.Start.Equal(time.Unix(1735725600, 0)) {
+		t.Errorf("Expected the earlier avalanche warning first, got %+v", first)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "onecall.test?lat=59.9127&lon=10.7461&exclude=current,minutely,hourly,daily&appid=testkey" {
+		t.Errorf("Expected one One Call call at Oslo's coordinates, got %v", *calls)
+	}
+
+	alerts, err = repo.GetAlerts(ctx, "Oslo")
+	if err != nil || !alerts.Cached || len(alerts.Alerts) != 2 || len(*calls) != 1 {
+		t.Errorf("Expected the cached alerts without a provider call, got %+v, %v, %d calls", alerts, err, len(*calls))
+	}
+}
+
+func TestGetAlerts_Geocoded(t *testing.T) {
+	repo, calls := newAlertsRepository(t, http.StatusOK)
+
+	alerts, err := repo.GetAlerts(context.Background(), "Longyearbyen,SJ")
+	if err != nil || alerts.Coordinates != (model.Coordinates{Lat: 78.2232, Lon: 15.6267}) {
+		t.Fatalf("Expected alerts at the geocoded coordinates, got %+v, %v", alerts, err)
+	}
+	if len(*calls) != 2 || !strings.HasPrefix((*calls)[0], "geo.test?") {
+		t.Errorf("Expected a geocoding call then a One Call call, got %v", *calls)
+	}
+
+	var notFound *LocationNotFoundError
+	if _, err := repo.GetAlerts(context.Background(), "Nowhere"); !errors.As(err, &notFound) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+}
+
+func TestGetAlerts_ProviderErrors(t *testing.T) {
+	repo, _ := newAlertsRepository(t, http.StatusUnauthorized)
+	if _, err := repo.GetAlerts(context.Background(), "Oslo"); !errors.Is(err, ErrExternalAPI) {
+		t.Errorf("Expected ErrExternalAPI, got %v", err)
+	}
+
+	repo, _ = newAlertsRepository(t, http.StatusTooManyRequests)
+	var coolDown *CoolDownError
+	if _, err := repo.GetAlerts(context.Background(), "Oslo"); !errors.As(err, &coolDown) {
+		t.Errorf("Expected CoolDownError, got %v", err)
+	}
+}
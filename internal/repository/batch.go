@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/fanout"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+)
+
+// BatchResult is the outcome of one location of a batch lookup: its weather, or why there is none.
+type BatchResult struct {
+	Weather *model.WeatherResponse
+	Err     error
+}
+
+// BatchRepository is implemented by repositories that look up several locations at once.
+type BatchRepository interface {
+	GetWeatherBatch(ctx context.Context, locations []string, concurrency int) []BatchResult
+}
+
+// GetWeatherBatch returns the weather of each location, in order, as GetWeather would. The cache
+// entries are read with a single MGET, and the locations without a fresh one are fetched from the
+// provider concurrently, at most concurrency at a time.
+func (r *weatherRepository) GetWeatherBatch(ctx context.Context, locations []string, concurrency int) []BatchResult {
+	results := make([]BatchResult, len(locations))
+	if CachePolicyFrom(ctx) != CachePolicyDefault {
+		// Refreshes skip the cache read, and cache-only lookups never reach the provider
+		for i, res := range fanout.Map(ctx, locations, fanout.Options{Limit: concurrency}, r.GetWeather) {
+			results[i] = BatchResult{Weather: res.Value, Err: res.Err}
+		}
+		return results
+	}
+
+	var pending []int
+	for i, location := range locations {
+		if weather, ok := overrideFor(location); ok {
+			results[i].Weather = weather
+			continue
+		}
+		pending = append(pending, i)
+	}
+	names := make([]string, len(pending))
+	for j, i := range pending {
+		names[j] = locations[i]
+	}
+	entries := r.readEntries(ctx, names)
+
+	now := cacheClock.Now()
+	var misses []int
+	previous := make(map[int]*cacheEntry)
+	for j, i := range pending {
+		entry := entries[j]
+		switch {
+		case entry != nil && entry.isFresh(now):
+			cacheLookups.WithLabelValues(lookupHit).Inc()
+			recordCallSaved(providerAccount(ctx))
+			weather := entry.WeatherResponse
+			weather.Cached = true
+			results[i].Weather = &weather
+			continue
+		case entry != nil:
+			cacheLookups.WithLabelValues(lookupStale).Inc()
+			previous[i] = entry
+		default:
+			cacheLookups.WithLabelValues(lookupMiss).Inc()
+		}
+		misses = append(misses, i)
+	}
+	logger(ctx).Debugw("Batch cache lookup", "locations", len(locations), "misses", len(misses))
+
+	fetched := fanout.Map(ctx, misses, fanout.Options{Limit: concurrency}, func(ctx context.Context, i int) (*model.WeatherResponse, error) {
+		return r.fetchMiss(logctx.WithLocation(ctx, locations[i]), model.Location{Name: locations[i]}, previous[i])
+	})
+	for j, i := range misses {
+		results[i] = BatchResult{Weather: fetched[j].Value, Err: fetched[j].Err}
+	}
+	return results
+}
+
+// readEntries reads the cache entries of locations with a single MGET. Entries missing or
+// undecodable are nil, and all are after the read times out. Readers without MGET, and an MGET
+// failing otherwise, e.g. across cluster slots, fall back to one GET per location.
+func (r *weatherRepository) readEntries(ctx context.Context, locations []string) []*cacheEntry {
+	entries := make([]*cacheEntry, len(locations))
+	if len(locations) == 0 {
+		return entries
+	}
+	if multi, ok := r.reader().(redis.MultiReader); ok {
+		keys := make([]string, len(locations))
+		for i, location := range locations {
+			keys[i] = cacheKeyFor(ctx, location)
+		}
+		getCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Read)
+		vals, err := multi.MGet(getCtx, keys...).Result()
+		timedOut := err != nil && cacheTimedOut(ctx, getCtx, cacheOpGet)
+		cancel()
+		switch {
+		case err == nil:
+			for i, val := range vals {
+				if s, ok := val.(string); ok {
+					entries[i], _ = decodeEntry(ctx, keys[i], []byte(s))
+				}
+			}
+			return entries
+		case timedOut:
+			logger(ctx).Warnw("Redis mget timed out", "keys", len(keys), "error", err)
+			return entries
+		}
+		logger(ctx).Debugw("Redis mget error, reading entries one by one", "keys", len(keys), "error", err)
+	}
+	for i, location := range locations {
+		entries[i], _ = r.readEntry(ctx, location)
+	}
+	return entries
+}
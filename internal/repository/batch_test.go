@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// countingReader counts the reads made through it.
+type countingReader struct {
+	*redisv9.Client
+	gets, mgets atomic.Int32
+}
+
+func (c *countingReader) Get(ctx context.Context, key string) *redisv9.StringCmd {
+	c.gets.Add(1)
+	return c.Client.Get(ctx, key)
+}
+
+func (c *countingReader) MGet(ctx context.Context, keys ...string) *redisv9.SliceCmd {
+	c.mgets.Add(1)
+	return c.Client.MGet(ctx, keys...)
+}
+
+// getOnlyReader hides the MGet of its reader.
+type getOnlyReader struct {
+	redis.Reader
+}
+
+// newBatchRepository returns a repository calling a mock provider that knows cities.
+func newBatchRepository(t *testing.T, cities ...string) (*weatherRepository, *countingReader, *testutil.MockProvider) {
+	t.Helper()
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	provider := &testutil.MockProvider{Seed: 1, Cities: cities}
+	srv := provider.Start()
+	t.Cleanup(srv.Close)
+	viper.Set("openweathermap.api_url", srv.URL+"/weather")
+	t.Cleanup(func() { viper.Set("openweathermap.api_url", nil) })
+	client, _ := newRedisClient(t)
+	reader := &countingReader{Client: client}
+	return &weatherRepository{redisClient: client, readClient: reader, httpClient: srv.Client()}, reader, provider
+}
+
+func TestGetWeatherBatch(t *testing.T) {
+	repo, reader, provider := newBatchRepository(t, "Jakarta", "Bandung", "Surabaya", "Medan")
+	ctx := context.Background()
+	for _, location := range []string{"Jakarta", "Bandung"} {
+		if _, err := repo.GetWeather(ctx, location); err != nil {
+			t.Fatalf("GetWeather(%s): %v", location, err)
+		}
+	}
+	setLocalOverride(&Override{Location: "Medan", Weather: model.WeatherResponse{Location: "Medan", Temperature: -40}, ExpiresAt: time.Now().Add(time.Minute)})
+	t.Cleanup(func() { deleteLocalOverride("Medan") })
+	reader.gets.Store(0)
+
+	results := repo.GetWeatherBatch(ctx, []string{"Jakarta", "Surabaya", "Medan", "Atlantis", "Bandung"}, 2)
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+	for _, i := range []int{0, 4} {
+		if results[i].Err != nil || !results[i].Weather.Cached {
+			t.Errorf("Result %d: expected a cache hit, got %+v", i, results[i])
+		}
+	}
+	if r := results[1]; r.Err != nil || r.Weather.Cached || r.Weather.Location != "Surabaya" {
+		t.Errorf("Expected Surabaya from the provider, got %+v", r)
+	}
+	if r := results[2]; r.Err != nil || r.Weather.Temperature != -40 {
+		t.Errorf("Expected the Medan override, got %+v", r)
+	}
+	var notFound *LocationNotFoundError
+	if r := results[3]; !errors.As(r.Err, &notFound) {
+		t.Errorf("Expected Atlantis not found, got %+v", r)
+	}
+	if reader.mgets.Load() != 1 || reader.gets.Load() != 0 {
+		t.Errorf("Expected a single MGET, got %d MGET and %d GET", reader.mgets.Load(), reader.gets.Load())
+	}
+	// Two for the warm-up, then one per miss
+	if provider.Calls() != 4 {
+		t.Errorf("Expected 4 provider calls, got %d", provider.Calls())
+	}
+
+	// The fetched location is now cached
+	if results := repo.GetWeatherBatch(ctx, []string{"Surabaya"}, 2); results[0].Err != nil || !results[0].Weather.Cached {
+		t.Errorf("Expected Surabaya cached, got %+v", results[0])
+	}
+}
+
+func TestGetWeatherBatch_WithoutMGet(t *testing.T) {
+	repo, reader, provider := newBatchRepository(t)
+	ctx := context.Background()
+	if _, err := repo.GetWeather(ctx, "Jakarta"); err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	repo.readClient = getOnlyReader{reader}
+
+	results := repo.GetWeatherBatch(ctx, []string{"Jakarta", "Bandung"}, 1)
+	if results[0].Err != nil || !results[0].Weather.Cached || results[1].Err != nil || results[1].Weather.Cached {
+		t.Errorf("Expected a hit and a miss, got %+v", results)
+	}
+	if reader.mgets.Load() != 0 || provider.Calls() != 2 {
+		t.Errorf("Expected one GET per location, got %d MGET and %d provider calls", reader.mgets.Load(), provider.Calls())
+	}
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// encryptedValuePrefix marks values sealed by sealCacheValue. Plaintext values are JSON and never
+// start with it.
+var encryptedValuePrefix = []byte("enc1:")
+
+var (
+	// ErrCacheEncryption is returned when cache encryption is enabled without a valid key, so that
+	// nothing is ever written to Redis in plaintext by mistake.
+	ErrCacheEncryption = errors.New("cache encryption misconfigured")
+	// ErrCachePlaintext is returned for a plaintext value read while encryption is enabled and
+	// cache.encryption.allow_plaintext is off.
+	ErrCachePlaintext = errors.New("unencrypted cache value")
+	// ErrCacheEncrypted is returned for an encrypted value read while encryption is disabled.
+	ErrCacheEncrypted = errors.New("encrypted cache value")
+)
+
+// cacheCipher seals cache values with AES-GCM, bound to the Redis key they are stored under so a
+// value cannot be moved to another key.
+type cacheCipher struct {
+	aead           cipher.AEAD
+	allowPlaintext bool
+	err            error
+}
+
+var (
+	cacheCipherOnce sync.Once
+	currentCipher   *cacheCipher
+)
+
+// loadCacheCipher returns the cipher configured by cache.encryption, or nil when encryption is
+// disabled. It is built once per process.
+func loadCacheCipher() *cacheCipher {
+	cacheCipherOnce.Do(func() {
+		cfg := config.GetCacheEncryptionConfig()
+		if !cfg.Enabled {
+			return
+		}
+		currentCipher = &cacheCipher{allowPlaintext: cfg.AllowPlaintext}
+		currentCipher.aead, currentCipher.err = newCacheAEAD(cfg.Key)
+		if currentCipher.err != nil {
+			config.GetModuleLogger("repository").Errorw("Cache encryption enabled without a valid key, Redis writes will fail", "error", currentCipher.err)
+		}
+	})
+	return currentCipher
+}
+
+func newCacheAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: CACHE_ENCRYPTION_KEY is not base64: %v", ErrCacheEncryption, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheEncryption, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealCacheValue encrypts value for storage under key when cache encryption is enabled, and returns
+// it unchanged otherwise.
+func sealCacheValue(key string, value []byte) ([]byte, error) {
+	c := loadCacheCipher()
+	if c == nil {
+		return value, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	out := make([]byte, len(encryptedValuePrefix)+c.aead.NonceSize(), len(encryptedValuePrefix)+c.aead.NonceSize()+len(value)+c.aead.Overhead())
+	copy(out, encryptedValuePrefix)
+	nonce := out[len(encryptedValuePrefix):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, nonce, value, []byte(key)), nil
+}
+
+// openCacheValue returns the plaintext of a value read from key.
+func openCacheValue(key string, value []byte) ([]byte, error) {
+	c := loadCacheCipher()
+	sealed, encrypted := bytes.CutPrefix(value, encryptedValuePrefix)
+	switch {
+	case c == nil && !encrypted:
+		return value, nil
+	case c == nil:
+		return nil, ErrCacheEncrypted
+	case c.err != nil:
+		return nil, c.err
+	case !encrypted && c.allowPlaintext:
+		return value, nil
+	case !encrypted:
+		return nil, ErrCachePlaintext
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated value", ErrCacheEncryption)
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, []byte(key))
+}
+
+// marshalCacheValue encodes v as JSON and seals it for storage under key.
+func marshalCacheValue(key string, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return sealCacheValue(key, b)
+}
+
+// unmarshalCacheValue opens a value read from key and decodes its JSON into v.
+func unmarshalCacheValue(key string, value []byte, v interface{}) error {
+	b, err := openCacheValue(key, value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+// withCacheEncryption enables cache encryption with key for the test and rebuilds the cipher.
+func withCacheEncryption(t *testing.T, key string, allowPlaintext bool) {
+	t.Helper()
+	viper.Set("cache.encryption.enabled", true)
+	viper.Set("cache.encryption.allow_plaintext", allowPlaintext)
+	t.Setenv("CACHE_ENCRYPTION_KEY", key)
+	resetCacheCipher()
+	t.Cleanup(func() {
+		viper.Set("cache.encryption.enabled", nil)
+		viper.Set("cache.encryption.allow_plaintext", nil)
+		resetCacheCipher()
+	})
+}
+
+func resetCacheCipher() {
+	cacheCipherOnce = sync.Once{}
+	currentCipher = nil
+}
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+
+func TestCacheCipher_RoundTrip(t *testing.T) {
+	withCacheEncryption(t, testEncryptionKey, false)
+
+	sealed, err := sealCacheValue("weather:London", []byte(`{"location":"London"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.HasPrefix(sealed, encryptedValuePrefix) || bytes.Contains(sealed, []byte("London")) {
+		t.Errorf("Expected an opaque sealed value, got %q", sealed)
+	}
+	if plain, err := openCacheValue("weather:London", sealed); err != nil || string(plain) != `{"location":"London"}` {
+		t.Errorf("Expected the plaintext back, got %q, %v", plain, err)
+	}
+	if _, err := openCacheValue("weather:Paris", sealed); err == nil {
+		t.Error("Expected a value moved to another key to fail authentication")
+	}
+	if _, err := openCacheValue("weather:London", []byte(`{"location":"London"}`)); !errors.Is(err, ErrCachePlaintext) {
+		t.Errorf("Expected plaintext rejected, got %v", err)
+	}
+
+	withCacheEncryption(t, testEncryptionKey, true)
+	if plain, err := openCacheValue("weather:London", []byte(`{}`)); err != nil || string(plain) != `{}` {
+		t.Errorf("Expected plaintext accepted while migrating, got %q, %v", plain, err)
+	}
+}
+
+func TestCacheCipher_Disabled(t *testing.T) {
+	resetCacheCipher()
+	t.Cleanup(resetCacheCipher)
+	if v, err := sealCacheValue("k", []byte("{}")); err != nil || string(v) != "{}" {
+		t.Errorf("Expected values stored as is, got %q, %v", v, err)
+	}
+	if _, err := openCacheValue("k", append([]byte("enc1:"), 1, 2, 3)); !errors.Is(err, ErrCacheEncrypted) {
+		t.Errorf("Expected ErrCacheEncrypted, got %v", err)
+	}
+}
+
+func TestCacheCipher_InvalidKeyFailsClosed(t *testing.T) {
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		withCacheEncryption(t, key, false)
+		if _, err := sealCacheValue("k", []byte("{}")); !errors.Is(err, ErrCacheEncryption) {
+			t.Errorf("Key %q: expected ErrCacheEncryption, got %v", key, err)
+		}
+	}
+}
+
+func TestCacheCipher_Stores(t *testing.T) {
+	withCacheEncryption(t, testEncryptionKey, false)
+	client, mr := newRedisClient(t)
+	ctx := context.Background()
+
+	repo := &weatherRepository{redisClient: client}
+	repo.storeEntry(ctx, "London", &cacheEntry{WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 15.2}})
+	if raw, _ := mr.Get("weather:London"); !strings.HasPrefix(raw, "enc1:") {
+		t.Errorf("Expected the weather entry encrypted in Redis, got %q", raw)
+	}
+	if weather, err := repo.getFromCache(ctx, "London"); err != nil || weather.Temperature != 15.2 {
+		t.Errorf("Expected the entry decrypted, got %+v, %v", weather, err)
+	}
+
+	store := NewSubscriptionStore(client)
+	sub := &model.Subscription{ID: "sub-1", Location: "London", URL: "https://example.com/hook", Secret: "s3cret"}
+	if err := store.Create(ctx, sub); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if raw := mr.HGet(subscriptionsKey, "sub-1"); strings.Contains(raw, "s3cret") {
+		t.Errorf("Expected the subscription encrypted in Redis, got %q", raw)
+	}
+	if got, err := store.Get(ctx, "sub-1"); err != nil || got.Secret != "s3cret" {
+		t.Errorf("Expected the subscription decrypted, got %+v, %v", got, err)
+	}
+	if subs, err := store.ListByLocation(ctx, "London"); err != nil || len(subs) != 1 {
+		t.Errorf("Expected the subscription listed, got %+v, %v", subs, err)
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/clock"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// cacheEntryVersion is the schema version of the cache entries written by this build. Entries
+// written before versioning decode with version 0.
+const cacheEntryVersion = 1
+
+// cacheClock stamps stored entries and decides whether they are still fresh. Tests replace it
+// with a clock.Fake to let entries expire without waiting.
+var cacheClock clock.Clock = clock.Real
+
+// cacheEntry is the value stored under a weather cache key. The weather fields are inlined so
+// entries written before metadata was added still decode, and are treated as fresh.
+type cacheEntry struct {
+	model.WeatherResponse
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	FreshUntil   int64  `json:"fresh_until,omitempty"`
+	Version      int    `json:"v,omitempty"`
+	// StoredAt is when the entry was written, in Unix milliseconds.
+	StoredAt int64 `json:"stored_at,omitempty"`
+	// Provider is the provider account the weather came from.
+	Provider string `json:"provider,omitempty"`
+}
+
+// isFresh reports whether the entry is still within its cache expiration at now.
+func (e *cacheEntry) isFresh(now time.Time) bool {
+	return e.FreshUntil == 0 || now.Unix() < e.FreshUntil
+}
+
+// upstreamResult carries a provider response along with the validators it returned.
+type upstreamResult struct {
+	Weather      *model.WeatherResponse
+	ETag         string
+	LastModified string
+	NotModified  bool
+	// Coords is where the provider placed the location, when it said.
+	Coords *model.Coordinates
+}
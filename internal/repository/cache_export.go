@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// CacheExporter streams every cached weather entry.
+type CacheExporter interface {
+	// Export calls fn once per cached entry. Iteration stops at the first error returned by fn or
+	// when ctx is cancelled, so a slow consumer paces the scan instead of buffering the keyspace.
+	Export(ctx context.Context, fn func(*model.WeatherResponse) error) error
+}
+
+// ScanClient is the subset of Redis operations needed to walk the cache keyspace.
+type ScanClient interface {
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redisv9.ScanCmd
+	Get(ctx context.Context, key string) *redisv9.StringCmd
+}
+
+type cacheExporter struct {
+	client    ScanClient
+	batchSize int64
+}
+
+// NewCacheExporter creates a CacheExporter backed by the shared Redis client.
+func NewCacheExporter(client ...ScanClient) CacheExporter {
+	var c ScanClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &cacheExporter{client: c, batchSize: 100}
+}
+
+func (e *cacheExporter) Export(ctx context.Context, fn func(*model.WeatherResponse) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := e.client.Scan(ctx, cursor, "weather:*", e.batchSize).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			val, err := e.client.Get(ctx, key).Result()
+			if errors.Is(err, redisv9.Nil) {
+				continue // expired between SCAN and GET
+			} else if err != nil {
+				return err
+			}
+			var entry cacheEntry
+			if err := unmarshalCacheValue(key, []byte(val), &entry); err != nil {
+				logger(ctx).Warnw("Skipping undecodable cache entry", "cacheKey", key, "error", err)
+				continue
+			}
+			weather := entry.WeatherResponse
+			if weather.Location == "" {
+				weather.Location = strings.TrimPrefix(key, "weather:")
+			}
+			weather.Cached = true
+			weather.Stale = !entry.isFresh(cacheClock.Now())
+			if err := fn(&weather); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func newExportFixture(t *testing.T) CacheExporter {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	put := func(key string, entry cacheEntry) {
+		b, _ := json.Marshal(entry)
+		_ = mr.Set(key, string(b))
+	}
+	put("weather:London", cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 15},
+		FreshUntil:      time.Now().Add(time.Minute).Unix(),
+	})
+	put("weather:Paris", cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "Paris", Temperature: 20},
+		FreshUntil:      time.Now().Add(-time.Minute).Unix(),
+	})
+	_ = mr.Set("weather:Broken", "{not json")
+	_ = mr.Set("other:key", "ignored")
+
+	e := NewCacheExporter(client).(*cacheExporter)
+	e.batchSize = 1
+	return e
+}
+
+func TestCacheExporter_Export(t *testing.T) {
+	exporter := newExportFixture(t)
+
+	var got []model.WeatherResponse
+	err := exporter.Export(context.Background(), func(w *model.WeatherResponse) error {
+		got = append(got, *w)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Location < got[j].Location })
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 weather entries, got %+v", got)
+	}
+	if got[0].Location != "London" || got[0].Stale || !got[0].Cached {
+		t.Errorf("Unexpected London entry %+v", got[0])
+	}
+	if got[1].Location != "Paris" || !got[1].Stale {
+		t.Errorf("Expected Paris to be flagged stale, got %+v", got[1])
+	}
+}
+
+func TestCacheExporter_StopsOnCallbackError(t *testing.T) {
+	exporter := newExportFixture(t)
+	stop := errors.New("consumer gone")
+
+	calls := 0
+	err := exporter.Export(context.Background(), func(*model.WeatherResponse) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected export to stop after the first row, got %v after %d calls", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := exporter.Export(ctx, func(*model.WeatherResponse) error { return nil }); err == nil {
+		t.Error("Expected a cancelled context to abort the export")
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrCacheKeyNotFound is returned by CacheInspector.Inspect for a key that is not stored.
+	ErrCacheKeyNotFound = errors.New("cache key not found")
+	// ErrNotWeatherKey is returned by CacheInspector.Inspect for keys outside the weather cache,
+	// which may hold secrets such as webhook subscriptions.
+	ErrNotWeatherKey = errors.New("not a weather cache key")
+)
+
+// CacheEntryInfo describes a stored weather cache entry.
+type CacheEntryInfo struct {
+	Key string `json:"key"`
+	// Payload is the stored JSON, decrypted when cache encryption is on.
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Encrypted bool            `json:"encrypted"`
+	// DecodeError explains why Payload is missing or the metadata could not be read.
+	DecodeError string `json:"decode_error,omitempty"`
+	// SizeBytes is the size of the value as stored in Redis.
+	SizeBytes int `json:"size_bytes"`
+	// TTLSeconds is how long the key has left in Redis, stale window included; -1 without expiry.
+	TTLSeconds    float64    `json:"ttl_seconds"`
+	SchemaVersion int        `json:"schema_version"`
+	StoredAt      *time.Time `json:"stored_at,omitempty"`
+	FreshUntil    *time.Time `json:"fresh_until,omitempty"`
+	Fresh         bool       `json:"fresh"`
+	Provider      string     `json:"provider,omitempty"`
+}
+
+// CacheInspector reads single weather cache entries with their storage metadata.
+type CacheInspector interface {
+	Inspect(ctx context.Context, key string) (*CacheEntryInfo, error)
+}
+
+// InspectClient is the subset of Redis operations needed to inspect a cache entry.
+type InspectClient interface {
+	Get(ctx context.Context, key string) *redisv9.StringCmd
+	TTL(ctx context.Context, key string) *redisv9.DurationCmd
+}
+
+type cacheInspector struct {
+	client InspectClient
+}
+
+// NewCacheInspector creates a CacheInspector backed by the shared Redis client.
+func NewCacheInspector(client ...InspectClient) CacheInspector {
+	var c InspectClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &cacheInspector{client: c}
+}
+
+// Inspect returns the entry stored under key, which must be a weather cache key. An entry that
+// cannot be decrypted or decoded is still described, with DecodeError set.
+func (i *cacheInspector) Inspect(ctx context.Context, key string) (*CacheEntryInfo, error) {
+	if !strings.HasPrefix(key, "weather:") {
+		return nil, ErrNotWeatherKey
+	}
+	val, err := i.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redisv9.Nil) {
+		return nil, ErrCacheKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	ttl, err := i.client.TTL(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CacheEntryInfo{
+		Key:        key,
+		Encrypted:  bytes.HasPrefix(val, encryptedValuePrefix),
+		SizeBytes:  len(val),
+		TTLSeconds: ttl.Seconds(),
+	}
+	if ttl < 0 {
+		info.TTLSeconds = -1
+	}
+	plain, err := openCacheValue(key, val)
+	if err != nil {
+		info.DecodeError = err.Error()
+		return info, nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(plain, &entry); err != nil {
+		info.DecodeError = err.Error()
+		plain, _ = json.Marshal(string(plain))
+	}
+	info.Payload = plain
+	info.SchemaVersion = entry.Version
+	info.Provider = entry.Provider
+	info.Fresh = info.DecodeError == "" && entry.isFresh(cacheClock.Now())
+	if entry.StoredAt > 0 {
+		t := time.UnixMilli(entry.StoredAt).UTC()
+		info.StoredAt = &t
+	}
+	if entry.FreshUntil > 0 {
+		t := time.Unix(entry.FreshUntil, 0).UTC()
+		info.FreshUntil = &t
+	}
+	return info, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestCacheInspector_Inspect(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+
+	repo := &weatherRepository{redisClient: client}
+	repo.storeEntry(ctx, "Jakarta", &cacheEntry{WeatherResponse: model.WeatherResponse{Location: "Jakarta", Temperature: 31}})
+	_ = mr.Set("weather:Broken", "{not json")
+	mr.SetTTL("weather:Broken", time.Minute)
+
+	inspector := NewCacheInspector(client)
+	info, err := inspector.Inspect(ctx, "weather:Jakarta")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if info.SchemaVersion != cacheEntryVersion || info.Provider != providerOpenWeatherMap || !info.Fresh {
+		t.Errorf("Unexpected metadata %+v", info)
+	}
+	if info.StoredAt == nil || time.Since(*info.StoredAt) > time.Minute || info.FreshUntil == nil {
+		t.Errorf("Expected write and freshness timestamps, got %v and %v", info.StoredAt, info.FreshUntil)
+	}
+	stored, _ := mr.Get("weather:Jakarta")
+	if info.TTLSeconds <= 0 || info.SizeBytes != len(stored) {
+		t.Errorf("Expected a TTL and a size, got %v and %d", info.TTLSeconds, info.SizeBytes)
+	}
+	var payload cacheEntry
+	if err := json.Unmarshal(info.Payload, &payload); err != nil || payload.Temperature != 31 {
+		t.Errorf("Expected the stored payload, got %s (%v)", info.Payload, err)
+	}
+
+	info, err = inspector.Inspect(ctx, "weather:Broken")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if info.DecodeError == "" || info.Fresh || string(info.Payload) != `"{not json"` {
+		t.Errorf("Expected an undecodable entry to be described, got %+v", info)
+	}
+
+	if _, err := inspector.Inspect(ctx, "weather:Nowhere"); !errors.Is(err, ErrCacheKeyNotFound) {
+		t.Errorf("Expected ErrCacheKeyNotFound, got %v", err)
+	}
+	if _, err := inspector.Inspect(ctx, "subscription:1"); !errors.Is(err, ErrNotWeatherKey) {
+		t.Errorf("Expected ErrNotWeatherKey, got %v", err)
+	}
+}
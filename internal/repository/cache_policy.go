@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// CachePolicy controls how GetWeather uses the cache for a single request.
+type CachePolicy int
+
+const (
+	// CachePolicyDefault serves fresh cache entries and fetches from the provider otherwise.
+	CachePolicyDefault CachePolicy = iota
+	// CachePolicyRefresh skips the cache read, fetches fresh data and overwrites the cache entry.
+	CachePolicyRefresh
+	// CachePolicyCacheOnly never calls the provider: fresh or stale cache entries are served and a
+	// missing entry results in ErrCacheOnlyMiss.
+	CachePolicyCacheOnly
+)
+
+type cachePolicyKey struct{}
+
+// WithCachePolicy returns a copy of ctx carrying the cache policy for the request.
+func WithCachePolicy(ctx context.Context, policy CachePolicy) context.Context {
+	return context.WithValue(ctx, cachePolicyKey{}, policy)
+}
+
+// CachePolicyFrom returns the cache policy carried by ctx, or CachePolicyDefault if none is set.
+// When offline mode is enabled in config, every request is treated as CachePolicyCacheOnly. The
+// quota degradation ladder may downgrade a refresh or force cache-only, depending on the request's
+// priority class.
+func CachePolicyFrom(ctx context.Context) CachePolicy {
+	if config.IsOfflineMode() {
+		return CachePolicyCacheOnly
+	}
+	policy, ok := ctx.Value(cachePolicyKey{}).(CachePolicy)
+	if !ok {
+		policy = CachePolicyDefault
+	}
+	return degradePolicy(ctx, policy)
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var cacheTimeouts = metrics.NewCounterVec("weather_cache_timeouts_total",
+	"Redis cache operations abandoned after cache.timeouts, by operation (get or set).", "op")
+
+// Cache operations, used as metrics labels.
+const (
+	cacheOpGet = "get"
+	cacheOpSet = "set"
+)
+
+// withCacheTimeout bounds one cache operation by timeout, or only by ctx when timeout is zero.
+func withCacheTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cacheTimedOut reports whether an operation run with opCtx failed because its own timeout
+// expired rather than because the request behind ctx ended, and counts it.
+func cacheTimedOut(ctx, opCtx context.Context, op string) bool {
+	if opCtx.Err() == nil || ctx.Err() != nil {
+		return false
+	}
+	cacheTimeouts.WithLabelValues(op).Inc()
+	return true
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func TestGetWeather_CacheTimeoutFallsThrough(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+	viper.Set("cache.timeouts.read", "10ms")
+	viper.Set("cache.timeouts.write", "10ms")
+	defer func() {
+		viper.Set("cache.timeouts.read", nil)
+		viper.Set("cache.timeouts.write", nil)
+	}()
+
+	// A slow Redis that only answers once the operation's context ends
+	slow := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult("", slow(ctx))
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			return redisv9.NewStatusResult("", slow(ctx))
+		},
+	}
+	upstreamCalled := false
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+		upstreamCalled = true
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name":"London","main":{"temp":21.5},"weather":[{"description":"sunny"}]}`)),
+			Header:     make(http.Header),
+		}
+	})}
+
+	gets, sets := cacheTimeouts.WithLabelValues(cacheOpGet).Value(), cacheTimeouts.WithLabelValues(cacheOpSet).Value()
+	start := time.Now()
+	weather, err := repo.GetWeather(context.Background(), "London")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the slow cache to be abandoned quickly, took %v", elapsed)
+	}
+	if !upstreamCalled || weather.Cached {
+		t.Error("Expected a cache timeout to fall through to the provider")
+	}
+	if cacheTimeouts.WithLabelValues(cacheOpGet).Value() != gets+1 || cacheTimeouts.WithLabelValues(cacheOpSet).Value() != sets+1 {
+		t.Error("Expected the get and set timeouts to be counted")
+	}
+}
+
+func TestCacheTimedOut_RequestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opCtx, opCancel := withCacheTimeout(ctx, time.Minute)
+	defer opCancel()
+	cancel()
+	before := cacheTimeouts.WithLabelValues(cacheOpGet).Value()
+	if cacheTimedOut(ctx, opCtx, cacheOpGet) || cacheTimeouts.WithLabelValues(cacheOpGet).Value() != before {
+		t.Error("Expected a cancelled request not to count as a cache timeout")
+	}
+
+	opCtx, opCancel = withCacheTimeout(context.Background(), 0)
+	defer opCancel()
+	if _, ok := opCtx.Deadline(); ok {
+		t.Error("Expected no deadline when the timeout is disabled")
+	}
+}
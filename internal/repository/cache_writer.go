@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+var (
+	cacheWrites = metrics.NewCounterVec("weather_cache_writes_total",
+		"Weather cache writes by outcome: stored, failed, or dropped because the write queue was full.", "outcome")
+	cacheWriteQueueDepth = metrics.NewGauge("weather_cache_write_queue_depth",
+		"Weather cache writes waiting in the write queues.")
+	cacheWriteBatches = metrics.NewCounter("weather_cache_write_batches_total",
+		"Pipelines of write-behind cache writes sent to Redis.")
+)
+
+// Cache write outcomes, used as metrics labels.
+const (
+	writeStored  = "stored"
+	writeFailed  = "failed"
+	writeDropped = "dropped"
+)
+
+// cacheWrite is one cache entry waiting to be stored.
+type cacheWrite struct {
+	ctx   context.Context
+	key   string
+	value []byte
+	ttl   time.Duration
+}
+
+// cacheWriter stores cache entries in the background from a bounded queue, so requests do not
+// wait for Redis. When the queue is full, new writes are dropped: the entry is simply fetched
+// again on the next miss.
+//
+// Write-behind writes, from bulk callers such as the warmer, go to a second queue and are sent in
+// pipelines of up to batchSize, at least every flushInterval, saving a round trip per entry.
+type cacheWriter struct {
+	client        RedisClient
+	queue         chan cacheWrite
+	behind        chan cacheWrite
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// pipeliner is implemented by Redis clients that can send several commands in one round trip.
+type pipeliner interface {
+	Pipelined(ctx context.Context, fn func(redisv9.Pipeliner) error) ([]redisv9.Cmder, error)
+}
+
+type writeBehindKey struct{}
+
+// WithWriteBehind returns a copy of ctx whose cache writes may be delayed and batched. Bulk
+// callers that do not read their own writes back use it.
+func WithWriteBehind(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeBehindKey{}, true)
+}
+
+func isWriteBehind(ctx context.Context) bool {
+	behind, _ := ctx.Value(writeBehindKey{}).(bool)
+	return behind
+}
+
+var (
+	sharedWriter     *cacheWriter
+	sharedWriterOnce sync.Once
+)
+
+// sharedCacheWriter returns the process-wide writer for the shared Redis client, or nil when
+// cache.write_queue.size is 0 and writes are synchronous.
+func sharedCacheWriter() *cacheWriter {
+	sharedWriterOnce.Do(func() {
+		if cfg := config.GetCacheWriteQueueConfig(); cfg.Size > 0 {
+			sharedWriter = newCacheWriter(redis.GetClient(), cfg)
+		}
+	})
+	return sharedWriter
+}
+
+// newCacheWriter starts cfg.Workers goroutines draining a queue of cfg.Size writes to client, and
+// one batching the write-behind queue of the same size.
+func newCacheWriter(client RedisClient, cfg config.CacheWriteQueueConfig) *cacheWriter {
+	w := &cacheWriter{
+		client:        client,
+		queue:         make(chan cacheWrite, cfg.Size),
+		behind:        make(chan cacheWrite, cfg.Size),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go w.run()
+	}
+	go w.runBatches()
+	return w
+}
+
+func (w *cacheWriter) run() {
+	for write := range w.queue {
+		cacheWriteQueueDepth.Set(float64(len(w.queue) + len(w.behind)))
+		writeCacheEntry(write.ctx, w.client, write.key, write.value, write.ttl)
+	}
+}
+
+// runBatches collects write-behind writes and flushes them when a batch is full or the flush
+// interval ticks.
+func (w *cacheWriter) runBatches() {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch := make([]cacheWrite, 0, w.batchSize)
+	for {
+		select {
+		case write := <-w.behind:
+			batch = append(batch, write)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		w.flush(batch)
+		batch = batch[:0]
+		cacheWriteQueueDepth.Set(float64(len(w.queue) + len(w.behind)))
+	}
+}
+
+// flush sends batch to Redis in one pipeline bounded by cache.timeouts.write, or write by write
+// when the client cannot pipeline.
+func (w *cacheWriter) flush(batch []cacheWrite) {
+	if len(batch) == 0 {
+		return
+	}
+	p, ok := w.client.(pipeliner)
+	if !ok {
+		for _, write := range batch {
+			writeCacheEntry(write.ctx, w.client, write.key, write.value, write.ttl)
+		}
+		return
+	}
+	cacheWriteBatches.Inc()
+	ctx := context.Background()
+	pipeCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Write)
+	defer cancel()
+	cmds, err := p.Pipelined(pipeCtx, func(pipe redisv9.Pipeliner) error {
+		for _, write := range batch {
+			pipe.Set(pipeCtx, write.key, write.value, write.ttl)
+		}
+		return nil
+	})
+	if cacheTimedOut(ctx, pipeCtx, cacheOpSet) {
+		logger(ctx).Warnw("Redis pipeline timed out, cache entries not stored", "entries", len(batch), "error", err)
+	}
+	for i, write := range batch {
+		cmdErr := err
+		if i < len(cmds) {
+			cmdErr = cmds[i].Err()
+		}
+		if cmdErr == nil {
+			cacheWrites.WithLabelValues(writeStored).Inc()
+			continue
+		}
+		cacheWrites.WithLabelValues(writeFailed).Inc()
+		logger(write.ctx).Debugw("Redis set error", "cacheKey", write.key, "error", cmdErr)
+	}
+}
+
+// enqueue queues a write without blocking, on the write-behind queue when ctx allows it. The
+// write outlives the request, so it keeps the values of ctx but not its cancellation. It reports
+// false when the queue is full.
+func (w *cacheWriter) enqueue(ctx context.Context, key string, value []byte, ttl time.Duration) bool {
+	queue := w.queue
+	if isWriteBehind(ctx) {
+		queue = w.behind
+	}
+	select {
+	case queue <- cacheWrite{ctx: context.WithoutCancel(ctx), key: key, value: value, ttl: ttl}:
+		cacheWriteQueueDepth.Set(float64(len(w.queue) + len(w.behind)))
+		return true
+	default:
+		cacheWrites.WithLabelValues(writeDropped).Inc()
+		return false
+	}
+}
+
+// writeCacheEntry stores one entry, bounded by cache.timeouts.write.
+func writeCacheEntry(ctx context.Context, client RedisClient, key string, value []byte, ttl time.Duration) {
+	setCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Write)
+	defer cancel()
+	err := client.Set(setCtx, key, value, ttl).Err()
+	switch {
+	case err == nil:
+		cacheWrites.WithLabelValues(writeStored).Inc()
+	case cacheTimedOut(ctx, setCtx, cacheOpSet):
+		cacheWrites.WithLabelValues(writeFailed).Inc()
+		logger(ctx).Warnw("Redis set timed out, cache entry not stored", "cacheKey", key, "error", err)
+	default:
+		cacheWrites.WithLabelValues(writeFailed).Inc()
+		logger(ctx).Debugw("Redis set error", "cacheKey", key, "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestCacheWriter_StoresInBackground(t *testing.T) {
+	stored := make(chan string, 1)
+	release := make(chan struct{})
+	client := &mockRedisClient{setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+		<-release
+		stored <- key
+		return redisv9.NewStatusResult("OK", nil)
+	}}
+	w := newCacheWriter(client, config.CacheWriteQueueConfig{Size: 1, Workers: 1, BatchSize: 1, FlushInterval: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if !w.enqueue(ctx, "weather:london", []byte("{}"), time.Minute) {
+		t.Fatal("Expected the write to be queued")
+	}
+	cancel() // the request ends before the write runs
+
+	// The worker is blocked on the first write; the second fills the queue and the third is dropped
+	deadline := time.Now().Add(time.Second)
+	for len(w.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	dropped := cacheWrites.WithLabelValues(writeDropped).Value()
+	if !w.enqueue(context.Background(), "weather:paris", []byte("{}"), time.Minute) {
+		t.Fatal("Expected the second write to be queued")
+	}
+	if w.enqueue(context.Background(), "weather:tokyo", []byte("{}"), time.Minute) {
+		t.Error("Expected a write to a full queue to be dropped")
+	}
+	if cacheWrites.WithLabelValues(writeDropped).Value() != dropped+1 {
+		t.Error("Expected the dropped write to be counted")
+	}
+
+	close(release)
+	for _, want := range []string{"weather:london", "weather:paris"} {
+		select {
+		case got := <-stored:
+			if got != want {
+				t.Errorf("Expected %s stored, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %s to be stored", want)
+		}
+	}
+}
+
+func TestCacheWriter_WriteBehindBatches(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	w := newCacheWriter(client, config.CacheWriteQueueConfig{Size: 10, Workers: 1, BatchSize: 3, FlushInterval: 20 * time.Millisecond})
+
+	ctx := WithWriteBehind(context.Background())
+	batches := cacheWriteBatches.Value()
+	for _, key := range []string{"weather:a", "weather:b", "weather:c", "weather:d"} {
+		if !w.enqueue(ctx, key, []byte("{}"), time.Minute) {
+			t.Fatalf("Expected %s to be queued", key)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !server.Exists("weather:d") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, key := range []string{"weather:a", "weather:b", "weather:c", "weather:d"} {
+		if !server.Exists(key) {
+			t.Errorf("Expected %s to be stored", key)
+		}
+	}
+	// A full batch of three, then the last write on the next tick
+	if got := cacheWriteBatches.Value() - batches; got != 2 {
+		t.Errorf("Expected 2 pipelines, got %v", got)
+	}
+	if ttl := server.TTL("weather:a"); ttl != time.Minute {
+		t.Errorf("Expected the TTL to be kept, got %v", ttl)
+	}
+}
+
+func TestStoreEntry_Synchronous(t *testing.T) {
+	var stored string
+	repo := &weatherRepository{redisClient: &mockRedisClient{setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+		stored = key
+		return redisv9.NewStatusResult("OK", nil)
+	}}}
+	before := cacheWrites.WithLabelValues(writeStored).Value()
+	repo.cacheWeather(context.Background(), "london", &model.WeatherResponse{Location: "London"})
+	if stored != "weather:london" || cacheWrites.WithLabelValues(writeStored).Value() != before+1 {
+		t.Errorf("Expected the entry stored before returning, got %q", stored)
+	}
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// capturesKey is the Redis list holding captures, newest first.
+const capturesKey = "captures"
+
+// CaptureStore keeps the most recent request captures in a capped Redis list.
+type CaptureStore interface {
+	// Add stores capture, dropping the oldest captures beyond capture.max_entries.
+	Add(ctx context.Context, capture model.Capture) error
+	// List returns up to limit captures, newest first; limit <= 0 returns them all.
+	List(ctx context.Context, limit int) ([]model.Capture, error)
+	// Clear removes every capture.
+	Clear(ctx context.Context) error
+}
+
+// CaptureClient is the subset of Redis operations needed by the capture store.
+type CaptureClient interface {
+	ListClient
+	Del(ctx context.Context, keys ...string) *redisv9.IntCmd
+}
+
+type captureStore struct {
+	client CaptureClient
+}
+
+// NewCaptureStore creates a CaptureStore backed by the shared Redis client.
+func NewCaptureStore(client ...CaptureClient) CaptureStore {
+	var c CaptureClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &captureStore{client: c}
+}
+
+func (s *captureStore) Add(ctx context.Context, capture model.Capture) error {
+	b, err := marshalCacheValue(capturesKey, capture)
+	if err != nil {
+		return err
+	}
+	if err := s.client.LPush(ctx, capturesKey, b).Err(); err != nil {
+		return err
+	}
+	return s.client.LTrim(ctx, capturesKey, 0, int64(config.GetCaptureConfig().MaxEntries-1)).Err()
+}
+
+func (s *captureStore) List(ctx context.Context, limit int) ([]model.Capture, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit - 1)
+	}
+	raw, err := s.client.LRange(ctx, capturesKey, 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	captures := make([]model.Capture, 0, len(raw))
+	for _, v := range raw {
+		var c model.Capture
+		if err := unmarshalCacheValue(capturesKey, []byte(v), &c); err != nil {
+			logger(ctx).Warnw("Skipping undecodable capture", "error", err)
+			continue
+		}
+		captures = append(captures, c)
+	}
+	return captures, nil
+}
+
+func (s *captureStore) Clear(ctx context.Context) error {
+	return s.client.Del(ctx, capturesKey).Err()
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/spf13/viper"
+)
+
+func TestCaptureStore(t *testing.T) {
+	viper.Set("capture.max_entries", 2)
+	defer viper.Set("capture.max_entries", nil)
+	client, _ := newRedisClient(t)
+	store := NewCaptureStore(client)
+	ctx := context.Background()
+
+	for _, id := range []string{"req-1", "req-2", "req-3"} {
+		if err := store.Add(ctx, model.Capture{RequestID: id, Status: 500}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	captures, err := store.List(ctx, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(captures) != 2 || captures[0].RequestID != "req-3" || captures[1].RequestID != "req-2" {
+		t.Errorf("Expected the two newest captures, got %+v", captures)
+	}
+	if captures, _ = store.List(ctx, 1); len(captures) != 1 || captures[0].RequestID != "req-3" {
+		t.Errorf("Expected the newest capture, got %+v", captures)
+	}
+
+	if err := store.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if captures, _ = store.List(ctx, 0); len(captures) != 0 {
+		t.Errorf("Expected no captures after Clear, got %+v", captures)
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	changeStatsPrefix = "warmer:changes:"
+	// changeStatsRetention is how long a city's stats outlive its last refresh, so cities dropped
+	// from the warm list fade out.
+	changeStatsRetention = 30 * 24 * time.Hour
+)
+
+// CityChanges reports how often a warm-listed city's weather changed between refreshes.
+type CityChanges struct {
+	City string `json:"city"`
+	// Refreshes counts the refreshes compared with the one before; the first refresh of a city only
+	// records its weather.
+	Refreshes int64 `json:"refreshes"`
+	// Changes counts the refreshes that found the temperature or description changed.
+	Changes    int64   `json:"changes"`
+	ChangeRate float64 `json:"change_rate"`
+	// MeanRefreshIntervalSeconds and MeanChangeIntervalSeconds compare how often the city is
+	// refreshed with how often its weather actually changes.
+	MeanRefreshIntervalSeconds float64    `json:"mean_refresh_interval_seconds,omitempty"`
+	MeanChangeIntervalSeconds  float64    `json:"mean_change_interval_seconds,omitempty"`
+	ObservedSince              *time.Time `json:"observed_since,omitempty"`
+	LastRefresh                *time.Time `json:"last_refresh,omitempty"`
+	LastChange                 *time.Time `json:"last_change,omitempty"`
+}
+
+// ChangeStore tracks, per city, how often refreshed weather differs from the previous refresh.
+type ChangeStore interface {
+	// Record notes that city was refreshed at at with weather, and reports whether it changed
+	// since the previous refresh.
+	Record(ctx context.Context, city string, weather *model.WeatherResponse, at time.Time) (changed bool, err error)
+	// Report returns the stats of cities, in order. Cities never recorded have zero refreshes.
+	Report(ctx context.Context, cities []string) ([]CityChanges, error)
+}
+
+// ChangeClient is the subset of Redis operations needed to store change stats.
+type ChangeClient interface {
+	HGetAll(ctx context.Context, key string) *redisv9.MapStringStringCmd
+	HSet(ctx context.Context, key string, values ...interface{}) *redisv9.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redisv9.BoolCmd
+}
+
+type changeStore struct {
+	client ChangeClient
+}
+
+// NewChangeStore creates a ChangeStore backed by the shared Redis client. Only the warmer leader
+// records, so a city's stats are read and rewritten without a transaction.
+func NewChangeStore(client ...ChangeClient) ChangeStore {
+	var c ChangeClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &changeStore{client: c}
+}
+
+// fingerprint is the part of weather a change is detected on.
+func fingerprint(weather *model.WeatherResponse) string {
+	return strconv.FormatFloat(weather.Temperature, 'f', -1, 64) + "|" + weather.Description
+}
+
+func (s *changeStore) Record(ctx context.Context, city string, weather *model.WeatherResponse, at time.Time) (bool, error) {
+	key := changeStatsPrefix + city
+	fields, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	stats := parseChangeStats(city, fields)
+	fp := fingerprint(weather)
+	values := []interface{}{"fingerprint", fp, "last_refresh", at.Unix()}
+	changed := false
+	if stats.ObservedSince == nil {
+		values = append(values, "since", at.Unix())
+	} else {
+		changed = fields["fingerprint"] != fp
+		values = append(values, "refreshes", stats.Refreshes+1)
+		if changed {
+			values = append(values, "changes", stats.Changes+1, "last_change", at.Unix())
+		}
+	}
+	if err := s.client.HSet(ctx, key, values...).Err(); err != nil {
+		return false, err
+	}
+	return changed, s.client.Expire(ctx, key, changeStatsRetention).Err()
+}
+
+func (s *changeStore) Report(ctx context.Context, cities []string) ([]CityChanges, error) {
+	report := make([]CityChanges, 0, len(cities))
+	for _, city := range cities {
+		fields, err := s.client.HGetAll(ctx, changeStatsPrefix+city).Result()
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, parseChangeStats(city, fields))
+	}
+	return report, nil
+}
+
+// parseChangeStats reads a city's stats hash and derives the rates and mean intervals.
+func parseChangeStats(city string, fields map[string]string) CityChanges {
+	stats := CityChanges{City: city}
+	stats.Refreshes, _ = strconv.ParseInt(fields["refreshes"], 10, 64)
+	stats.Changes, _ = strconv.ParseInt(fields["changes"], 10, 64)
+	unix := func(field string) *time.Time {
+		sec, err := strconv.ParseInt(fields[field], 10, 64)
+		if err != nil {
+			return nil
+		}
+		t := time.Unix(sec, 0).UTC()
+		return &t
+	}
+	stats.ObservedSince, stats.LastRefresh, stats.LastChange = unix("since"), unix("last_refresh"), unix("last_change")
+	if stats.Refreshes == 0 || stats.ObservedSince == nil || stats.LastRefresh == nil {
+		return stats
+	}
+	span := stats.LastRefresh.Sub(*stats.ObservedSince).Seconds()
+	stats.ChangeRate = float64(stats.Changes) / float64(stats.Refreshes)
+	stats.MeanRefreshIntervalSeconds = span / float64(stats.Refreshes)
+	if stats.Changes > 0 {
+		stats.MeanChangeIntervalSeconds = span / float64(stats.Changes)
+	}
+	return stats
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+func TestChangeStore(t *testing.T) {
+	client, mr := newRedisClient(t)
+	store := NewChangeStore(client)
+	ctx := context.Background()
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Four refreshes 10 minutes apart: the first sets the baseline, then one change in three
+	for i, temp := range []float64{20, 20, 21.5, 21.5} {
+		weather := &model.WeatherResponse{Location: "Jakarta", Temperature: temp, Description: "haze"}
+		changed, err := store.Record(ctx, "Jakarta", weather, start.Add(time.Duration(i)*10*time.Minute))
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		if want := i == 2; changed != want {
+			t.Errorf("Refresh %d: expected changed = %v, got %v", i, want, changed)
+		}
+	}
+	changed, _ := store.Record(ctx, "Jakarta", &model.WeatherResponse{Temperature: 21.5, Description: "rain"}, start.Add(40*time.Minute))
+	if !changed {
+		t.Error("Expected a new description to be a change")
+	}
+	if ttl := mr.TTL("warmer:changes:Jakarta"); ttl != changeStatsRetention {
+		t.Errorf("Expected the stats to expire after %v, got %v", changeStatsRetention, ttl)
+	}
+
+	report, err := store.Report(ctx, []string{"Jakarta", "Medan"})
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	jakarta := report[0]
+	if jakarta.Refreshes != 4 || jakarta.Changes != 2 || jakarta.ChangeRate != 0.5 {
+		t.Errorf("Expected 2 changes in 4 refreshes, got %+v", jakarta)
+	}
+	if jakarta.MeanRefreshIntervalSeconds != 600 || jakarta.MeanChangeIntervalSeconds != 1200 {
+		t.Errorf("Expected refreshes every 600s and changes every 1200s, got %+v", jakarta)
+	}
+	if !jakarta.ObservedSince.Equal(start) || !jakarta.LastChange.Equal(start.Add(40*time.Minute)) {
+		t.Errorf("Unexpected timestamps %+v", jakarta)
+	}
+	if medan := report[1]; medan.City != "Medan" || medan.Refreshes != 0 || medan.ObservedSince != nil {
+		t.Errorf("Expected Medan unobserved, got %+v", medan)
+	}
+}
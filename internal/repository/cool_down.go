@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// ErrProviderRateLimited is wrapped by CoolDownError.
+var ErrProviderRateLimited = errors.New("provider rate limited")
+
+// CoolDownError is returned while provider calls are paused after a 429 and no cached entry,
+// even a stale one, can be served instead.
+type CoolDownError struct {
+	// RetryAfter is how long the cool-down has left.
+	RetryAfter time.Duration
+}
+
+func (e *CoolDownError) Error() string { return "provider cooling down after rate limiting" }
+func (e *CoolDownError) Unwrap() error { return ErrProviderRateLimited }
+
+var providerCoolDowns = metrics.NewCounterVec("weather_provider_cool_downs_total",
+	"Cool-downs started after the provider answered 429, by provider account.", "account")
+
+// coolDownState is this replica's view of the cool-down of each provider account. The shared
+// state lives in Redis under coolDownKey, so every replica pauses together.
+var (
+	coolDownMu     sync.Mutex
+	coolDownUntil  = map[string]time.Time{}
+	coolDownStreak = map[string]int{}
+)
+
+func coolDownKey(account string) string {
+	return "provider:cooldown:" + account
+}
+
+// coolingDown reports whether calls to the request's provider account are paused, and for how
+// much longer. Redis errors leave the provider available.
+func (r *weatherRepository) coolingDown(ctx context.Context) (time.Duration, bool) {
+	account := providerAccount(ctx)
+	now := time.Now()
+	coolDownMu.Lock()
+	until := coolDownUntil[account]
+	coolDownMu.Unlock()
+	if until.After(now) {
+		return until.Sub(now), true
+	}
+
+	getCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Read)
+	defer cancel()
+	millis, err := r.redisClient.Get(getCtx, coolDownKey(account)).Int64()
+	if err != nil {
+		return 0, false
+	}
+	if until = time.UnixMilli(millis); !until.After(now) {
+		return 0, false
+	}
+	coolDownMu.Lock()
+	coolDownUntil[account] = until
+	coolDownMu.Unlock()
+	return until.Sub(now), true
+}
+
+// startCoolDown pauses calls to the request's provider account after a 429, for the response's
+// Retry-After or, without one, for cool_down.default doubled with every 429 in a row, capped at
+// cool_down.max. It returns the pause.
+func (r *weatherRepository) startCoolDown(ctx context.Context, retryAfter string) time.Duration {
+	cfg := config.GetCoolDownConfig()
+	account := providerAccount(ctx)
+	now := time.Now()
+
+	coolDownMu.Lock()
+	coolDownStreak[account]++
+	streak := coolDownStreak[account]
+	pause := parseRetryAfter(retryAfter, now)
+	if pause <= 0 {
+		pause = cfg.Default << min(streak-1, 20)
+	}
+	pause = min(pause, cfg.Max)
+	until := now.Add(pause)
+	coolDownUntil[account] = until
+	coolDownMu.Unlock()
+
+	providerCoolDowns.WithLabelValues(account).Inc()
+	logger(ctx).Warnw("Provider rate limited, cooling down", "account", account, "retryAfter", retryAfter, "pause", pause, "streak", streak)
+	setCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Write)
+	defer cancel()
+	if err := r.redisClient.Set(setCtx, coolDownKey(account), until.UnixMilli(), pause).Err(); err != nil {
+		logger(ctx).Warnw("Cool-down not shared with other replicas", "account", account, "error", err)
+	}
+	return pause
+}
+
+// endCoolDownStreak resets the doubling once the provider answers normally again.
+func endCoolDownStreak(ctx context.Context) {
+	account := providerAccount(ctx)
+	coolDownMu.Lock()
+	delete(coolDownStreak, account)
+	coolDownMu.Unlock()
+}
+
+// parseRetryAfter returns the wait asked for by a Retry-After header, in seconds or as an HTTP
+// date, or 0 when there is none.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// serveStale answers a request the provider cannot serve during a cool-down with entry, flagged
+// as stale, or with err when nothing is cached.
+func serveStale(ctx context.Context, entry *cacheEntry, err error) (*model.WeatherResponse, error) {
+	if entry == nil {
+		return nil, err
+	}
+	logger(ctx).Debugw("Serving stale entry during provider cool-down")
+	weather := entry.WeatherResponse
+	weather.Cached = true
+	weather.Stale = true
+	return &weather, nil
+}
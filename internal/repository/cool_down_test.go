@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// resetCoolDowns forgets this replica's cool-down state.
+func resetCoolDowns(t *testing.T) {
+	t.Helper()
+	clear := func() {
+		coolDownMu.Lock()
+		coolDownUntil = map[string]time.Time{}
+		coolDownStreak = map[string]int{}
+		coolDownMu.Unlock()
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func TestGetWeather_CoolDownServesStale(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+	resetCoolDowns(t)
+	client, mr := newRedisClient(t)
+
+	stale, _ := json.Marshal(cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 12, Description: "rain"},
+		FreshUntil:      time.Now().Add(-time.Minute).Unix(),
+	})
+	mr.Set("weather:London", string(stale))
+
+	calls := 0
+	repo := &weatherRepository{redisClient: client, httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+		calls++
+		header := make(http.Header)
+		header.Set("Retry-After", "120")
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(`{"cod":429}`)), Header: header}
+	})}
+
+	ctx := context.Background()
+	weather, err := repo.GetWeather(ctx, "London")
+	if err != nil {
+		t.Fatalf("Expected the stale entry instead of an error, got %v", err)
+	}
+	if !weather.Stale || !weather.Cached || weather.Temperature != 12 {
+		t.Errorf("Expected the stale entry, got %+v", weather)
+	}
+	if ttl := mr.TTL(coolDownKey(providerOpenWeatherMap)); ttl != 2*time.Minute {
+		t.Errorf("Expected the cool-down shared for the Retry-After, got TTL %v", ttl)
+	}
+
+	// Another replica, without local state, honours the shared cool-down
+	resetCoolDowns(t)
+	if _, err := repo.GetWeather(ctx, "London"); err != nil || calls != 1 {
+		t.Errorf("Expected no provider call during the cool-down, got %d calls, %v", calls, err)
+	}
+	if _, err := repo.GetWeather(WithCachePolicy(ctx, CachePolicyRefresh), "London"); err != nil || calls != 1 {
+		t.Errorf("Expected refresh to serve the stale entry during the cool-down, got %d calls, %v", calls, err)
+	}
+
+	_, err = repo.GetWeather(ctx, "Paris")
+	var coolDown *CoolDownError
+	if !errors.As(err, &coolDown) || coolDown.RetryAfter <= time.Minute || !errors.Is(err, ErrProviderRateLimited) {
+		t.Errorf("Expected a CoolDownError without a cached entry, got %v", err)
+	}
+}
+
+func TestStartCoolDown_Doubles(t *testing.T) {
+	resetCoolDowns(t)
+	client, _ := newRedisClient(t)
+	repo := &weatherRepository{redisClient: client}
+	ctx := context.Background()
+
+	for _, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute} {
+		if got := repo.startCoolDown(ctx, ""); got != want {
+			t.Errorf("Expected a %v cool-down, got %v", want, got)
+		}
+	}
+	if got := repo.startCoolDown(ctx, "86400"); got != 10*time.Minute {
+		t.Errorf("Expected Retry-After capped at cool_down.max, got %v", got)
+	}
+	endCoolDownStreak(ctx)
+	if got := repo.startCoolDown(ctx, ""); got != 30*time.Second {
+		t.Errorf("Expected the doubling reset after a normal answer, got %v", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"90":                            90 * time.Second,
+		"-5":                            0,
+		"Mon, 01 Jan 2024 12:02:00 GMT": 2 * time.Minute,
+		"soon":                          0,
+	}
+	for v, want := range tests {
+		if got := parseRetryAfter(v, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
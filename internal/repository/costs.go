@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// providerOpenWeatherMap names the OpenWeatherMap provider in cost accounting.
+const providerOpenWeatherMap = "openweathermap"
+
+// providerAccount names the account a request's upstream calls are billed to: the tenant's own
+// account, e.g. "openweathermap:acme", or the shared account of the provider it was routed to.
+func providerAccount(ctx context.Context) string {
+	if p, ok := tenant.FromContext(ctx); ok {
+		return p.Account()
+	}
+	return routedProvider(ctx)
+}
+
+// tenantAccounts returns the provider accounts of API keys bound to their own credentials.
+func tenantAccounts() []tenant.Provider {
+	var accounts []tenant.Provider
+	for _, k := range config.GetAPIKeys() {
+		if k.Provider != nil {
+			accounts = append(accounts, tenant.Provider{Tenant: k.Name, Name: k.Provider.Name, DailyQuota: k.Provider.DailyQuota})
+		}
+	}
+	return accounts
+}
+
+const (
+	costDayFormat = "2006-01-02"
+	// costRetention is how long daily aggregates are kept in Redis.
+	costRetention = 90 * 24 * time.Hour
+)
+
+// costKey identifies the counts of one provider on one UTC day.
+type costKey struct {
+	provider string
+	day      string
+}
+
+// costCounts are provider calls made and calls avoided by cache hits.
+type costCounts struct {
+	calls int64
+	saved int64
+}
+
+// pendingCosts holds counts recorded since the last flush, so the request path never waits on
+// Redis for accounting.
+var (
+	pendingCostsMu sync.Mutex
+	pendingCosts   = map[costKey]*costCounts{}
+)
+
+func addCost(provider string, calls, saved int64) {
+	key := costKey{provider: provider, day: time.Now().UTC().Format(costDayFormat)}
+	pendingCostsMu.Lock()
+	defer pendingCostsMu.Unlock()
+	c, ok := pendingCosts[key]
+	if !ok {
+		c = &costCounts{}
+		pendingCosts[key] = c
+	}
+	c.calls += calls
+	c.saved += saved
+}
+
+// recordProviderCall counts one billable upstream call.
+func recordProviderCall(provider string) { addCost(provider, 1, 0) }
+
+// recordCallSaved counts one request answered from the cache instead of the provider.
+func recordCallSaved(provider string) { addCost(provider, 0, 1) }
+
+// CostClient is the subset of Redis operations needed to aggregate daily costs.
+type CostClient interface {
+	HIncrBy(ctx context.Context, key, field string, incr int64) *redisv9.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redisv9.BoolCmd
+	HGetAll(ctx context.Context, key string) *redisv9.MapStringStringCmd
+	HSet(ctx context.Context, key string, values ...interface{}) *redisv9.IntCmd
+}
+
+// DailyCost is the upstream usage of one provider over one day, or over a whole report.
+type DailyCost struct {
+	Date       string  `json:"date,omitempty"`
+	Calls      int64   `json:"calls"`
+	CallsSaved int64   `json:"calls_saved"`
+	Cost       float64 `json:"cost"`
+	Savings    float64 `json:"savings"`
+}
+
+// ProviderCosts reports a provider's estimated cost and what caching saved, newest day first.
+type ProviderCosts struct {
+	Provider     string  `json:"provider"`
+	Currency     string  `json:"currency"`
+	PricePerCall float64 `json:"price_per_call"`
+	DailyQuota   int64   `json:"daily_quota,omitempty"`
+	// QuotaUsed is the share of today's quota already spent.
+	QuotaUsed float64     `json:"quota_used,omitempty"`
+	NearQuota bool        `json:"near_quota"`
+	Total     DailyCost   `json:"total"`
+	Days      []DailyCost `json:"days"`
+}
+
+// CostLedger aggregates upstream call counts per provider and day in Redis.
+type CostLedger interface {
+	// Flush moves the counts recorded since the last flush into Redis.
+	Flush(ctx context.Context) error
+	// Report returns the costs of every known provider over the last days days, today included.
+	Report(ctx context.Context, days int) ([]ProviderCosts, error)
+}
+
+type costLedger struct {
+	client CostClient
+	now    func() time.Time
+}
+
+// NewCostLedger creates a CostLedger backed by the shared Redis client.
+func NewCostLedger(client ...CostClient) CostLedger {
+	var c CostClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &costLedger{client: c, now: time.Now}
+}
+
+// StartCostFlush schedules the cost jobs until the returned function is called:
+//   - "cost_flush" runs on every replica. Every costs.flush_interval it flushes the upstream calls
+//     this replica recorded to Redis, then applies the quota shares published by the leader.
+//   - "cost_budget" runs on the leader only. It re-evaluates the degradation ladder from today's
+//     aggregated usage and publishes the shares for the other replicas.
+//
+// In read_only mode counts are kept in memory and every replica evaluates the ladder itself.
+func StartCostFlush(l jobs.Leader) (stop func()) {
+	ledger := NewCostLedger().(*costLedger)
+	interval := config.GetCostConfig().FlushInterval
+	if config.IsReadOnly() {
+		return jobs.New("cost_flush", interval, func(ctx context.Context) error {
+			if err := ledger.updateBudgetUsed(ctx); err != nil {
+				return fmt.Errorf("update upstream budget: %w", err)
+			}
+			return nil
+		}).Schedule(false)
+	}
+	stopFlush := jobs.New("cost_flush", interval, func(ctx context.Context) error {
+		if err := ledger.Flush(ctx); err != nil {
+			return fmt.Errorf("flush cost counts: %w", err)
+		}
+		if err := ledger.loadBudgetUsed(ctx); err != nil {
+			return fmt.Errorf("load upstream budget: %w", err)
+		}
+		return nil
+	}).Schedule(false)
+	stopBudget := jobs.New("cost_budget", interval, func(ctx context.Context) error {
+		if err := ledger.publishBudgetUsed(ctx); err != nil {
+			return fmt.Errorf("publish upstream budget: %w", err)
+		}
+		return nil
+	}).LeaderOnly(l).Schedule(false)
+	return func() {
+		stopBudget()
+		stopFlush()
+	}
+}
+
+func costRedisKey(provider, day string) string {
+	return "costs:" + provider + ":" + day
+}
+
+func (l *costLedger) Flush(ctx context.Context) error {
+	pendingCostsMu.Lock()
+	batch := pendingCosts
+	pendingCosts = map[costKey]*costCounts{}
+	pendingCostsMu.Unlock()
+
+	for key, counts := range batch {
+		if err := l.store(ctx, key, counts); err != nil {
+			// Put back whatever was not stored so the next flush retries it
+			requeueCosts(batch)
+			return err
+		}
+		delete(batch, key)
+	}
+	return nil
+}
+
+// requeueCosts merges counts that could not be flushed back into the pending counts.
+func requeueCosts(batch map[costKey]*costCounts) {
+	pendingCostsMu.Lock()
+	defer pendingCostsMu.Unlock()
+	for key, counts := range batch {
+		if c, ok := pendingCosts[key]; ok {
+			c.calls += counts.calls
+			c.saved += counts.saved
+			continue
+		}
+		pendingCosts[key] = counts
+	}
+}
+
+func (l *costLedger) store(ctx context.Context, key costKey, counts *costCounts) error {
+	redisKey := costRedisKey(key.provider, key.day)
+	if counts.calls > 0 {
+		if err := l.client.HIncrBy(ctx, redisKey, "calls", counts.calls).Err(); err != nil {
+			return err
+		}
+		counts.calls = 0
+	}
+	if counts.saved > 0 {
+		if err := l.client.HIncrBy(ctx, redisKey, "saved", counts.saved).Err(); err != nil {
+			return err
+		}
+		counts.saved = 0
+	}
+	return l.client.Expire(ctx, redisKey, costRetention).Err()
+}
+
+func (l *costLedger) Report(ctx context.Context, days int) ([]ProviderCosts, error) {
+	if err := l.Flush(ctx); err != nil {
+		return nil, err
+	}
+	cfg := config.GetCostConfig()
+	providers := []string{providerOpenWeatherMap}
+	for name := range cfg.Providers {
+		if name != providerOpenWeatherMap {
+			providers = append(providers, name)
+		}
+	}
+	sort.Strings(providers[1:])
+	pricings := make(map[string]config.ProviderPricing, len(providers))
+	for _, provider := range providers {
+		pricings[provider] = cfg.Providers[provider]
+	}
+	// Tenant accounts are priced like their provider but spend their own quota
+	for _, account := range tenantAccounts() {
+		providers = append(providers, account.Account())
+		pricings[account.Account()] = config.ProviderPricing{
+			PricePerCall: cfg.Providers[account.Name].PricePerCall,
+			DailyQuota:   account.DailyQuota,
+		}
+	}
+
+	today := l.now().UTC()
+	reports := make([]ProviderCosts, 0, len(providers))
+	for _, provider := range providers {
+		pricing := pricings[provider]
+		report := ProviderCosts{
+			Provider:     provider,
+			Currency:     cfg.Currency,
+			PricePerCall: pricing.PricePerCall,
+			DailyQuota:   pricing.DailyQuota,
+			Days:         make([]DailyCost, 0, days),
+		}
+		for i := 0; i < days; i++ {
+			day := today.AddDate(0, 0, -i).Format(costDayFormat)
+			fields, err := l.client.HGetAll(ctx, costRedisKey(provider, day)).Result()
+			if err != nil {
+				return nil, err
+			}
+			calls, _ := strconv.ParseInt(fields["calls"], 10, 64)
+			saved, _ := strconv.ParseInt(fields["saved"], 10, 64)
+			d := DailyCost{
+				Date:       day,
+				Calls:      calls,
+				CallsSaved: saved,
+				Cost:       float64(calls) * pricing.PricePerCall,
+				Savings:    float64(saved) * pricing.PricePerCall,
+			}
+			report.Days = append(report.Days, d)
+			report.Total.Calls += d.Calls
+			report.Total.CallsSaved += d.CallsSaved
+			report.Total.Cost += d.Cost
+			report.Total.Savings += d.Savings
+		}
+		if pricing.DailyQuota > 0 && len(report.Days) > 0 {
+			report.QuotaUsed = float64(report.Days[0].Calls) / float64(pricing.DailyQuota)
+			report.NearQuota = report.QuotaUsed >= cfg.QuotaWarning
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
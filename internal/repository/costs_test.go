@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func newCostFixture(t *testing.T) (*costLedger, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	pendingCostsMu.Lock()
+	pendingCosts = map[costKey]*costCounts{}
+	pendingCostsMu.Unlock()
+
+	viper.Set("costs.providers", map[string]interface{}{
+		"openweathermap": map[string]interface{}{"price_per_call": 0.01, "daily_quota": 4},
+		"weatherapi":     map[string]interface{}{"price_per_call": 0.02},
+	})
+	t.Cleanup(func() { viper.Set("costs.providers", nil) })
+	return NewCostLedger(client).(*costLedger), mr
+}
+
+func TestCostLedger_Report(t *testing.T) {
+	ledger, mr := newCostFixture(t)
+	ctx := context.Background()
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(costDayFormat)
+	mr.HSet(costRedisKey(providerOpenWeatherMap, yesterday), "calls", "10", "saved", "90")
+
+	for i := 0; i < 3; i++ {
+		recordProviderCall(providerOpenWeatherMap)
+	}
+	recordCallSaved(providerOpenWeatherMap)
+	recordCallSaved(providerOpenWeatherMap)
+
+	reports, err := ledger.Report(ctx, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reports) != 2 || reports[0].Provider != providerOpenWeatherMap || reports[1].Provider != "weatherapi" {
+		t.Fatalf("Expected openweathermap then weatherapi, got %+v", reports)
+	}
+	owm := reports[0]
+	if owm.Days[0].Calls != 3 || owm.Days[0].CallsSaved != 2 || owm.Days[1].Date != yesterday || owm.Days[1].Calls != 10 {
+		t.Errorf("Unexpected days %+v", owm.Days)
+	}
+	if owm.Total.Calls != 13 || owm.Total.CallsSaved != 92 {
+		t.Errorf("Unexpected total %+v", owm.Total)
+	}
+	if math.Abs(owm.Total.Cost-0.13) > 1e-9 || math.Abs(owm.Total.Savings-0.92) > 1e-9 {
+		t.Errorf("Unexpected cost %v and savings %v", owm.Total.Cost, owm.Total.Savings)
+	}
+	if owm.QuotaUsed != 0.75 || owm.NearQuota {
+		t.Errorf("Expected 75%% of quota used and not yet near it, got %v %v", owm.QuotaUsed, owm.NearQuota)
+	}
+
+	recordProviderCall(providerOpenWeatherMap)
+	reports, _ = ledger.Report(ctx, 1)
+	if !reports[0].NearQuota {
+		t.Error("Expected near quota at 100% of the daily quota")
+	}
+	if ttl := mr.TTL(costRedisKey(providerOpenWeatherMap, time.Now().UTC().Format(costDayFormat))); ttl != costRetention {
+		t.Errorf("Expected daily aggregate to expire after %v, got %v", costRetention, ttl)
+	}
+}
+
+func TestCostLedger_FlushRequeuesOnError(t *testing.T) {
+	ledger, mr := newCostFixture(t)
+	recordProviderCall(providerOpenWeatherMap)
+	recordProviderCall("weatherapi")
+
+	mr.SetError("redis down")
+	if err := ledger.Flush(context.Background()); err == nil {
+		t.Fatal("Expected flush to fail")
+	}
+	mr.SetError("")
+
+	reports, err := ledger.Report(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if reports[0].Days[0].Calls != 1 || reports[1].Days[0].Calls != 1 {
+		t.Errorf("Expected counts kept across a failed flush, got %+v", reports)
+	}
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/priority"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+)
+
+// DegradationLevel is a step of the quota-aware degradation ladder. Each level includes the
+// measures of the levels below it.
+type DegradationLevel int32
+
+const (
+	// DegradationNormal applies no measures.
+	DegradationNormal DegradationLevel = iota
+	// DegradationExtendedTTL lengthens fresh cache TTLs.
+	DegradationExtendedTTL
+	// DegradationNoRefresh also ignores refresh=true.
+	DegradationNoRefresh
+	// DegradationCacheOnly also serves every request from the cache only.
+	DegradationCacheOnly
+)
+
+func (l DegradationLevel) String() string {
+	switch l {
+	case DegradationExtendedTTL:
+		return "extended_ttl"
+	case DegradationNoRefresh:
+		return "no_refresh"
+	case DegradationCacheOnly:
+		return "cache_only"
+	default:
+		return "normal"
+	}
+}
+
+var (
+	degradationLevelGauge = metrics.NewGauge("weather_degradation_level",
+		"Current degradation ladder step: 0 normal, 1 extended TTL, 2 no refresh, 3 cache only.")
+	upstreamBudgetUsed = metrics.NewGauge("weather_upstream_budget_used_ratio",
+		"Share of today's upstream daily quota already spent.")
+
+	degradationLevel atomic.Int32
+	budgetUsedBits   atomic.Uint64
+	// tenantBudgetUsed holds today's quota share of each tenant account, keyed by account.
+	tenantBudgetUsed sync.Map
+)
+
+// DegradationState is the current degradation level and the quota share that caused it.
+type DegradationState struct {
+	Level      string  `json:"level"`
+	BudgetUsed float64 `json:"budget_used"`
+}
+
+// CurrentDegradation returns the current degradation state.
+func CurrentDegradation() DegradationState {
+	return DegradationState{
+		Level:      currentDegradationLevel().String(),
+		BudgetUsed: math.Float64frombits(budgetUsedBits.Load()),
+	}
+}
+
+func currentDegradationLevel() DegradationLevel {
+	return DegradationLevel(degradationLevel.Load())
+}
+
+// degradationFor returns the ladder step for a quota share used.
+func degradationFor(cfg config.DegradationConfig, used float64) DegradationLevel {
+	switch {
+	case !cfg.Enabled:
+		return DegradationNormal
+	case used >= cfg.CacheOnlyAt:
+		return DegradationCacheOnly
+	case used >= cfg.DisableRefreshAt:
+		return DegradationNoRefresh
+	case used >= cfg.ExtendTTLAt:
+		return DegradationExtendedTTL
+	default:
+		return DegradationNormal
+	}
+}
+
+// setBudgetUsed records the quota share used and moves to the matching degradation level.
+func setBudgetUsed(ctx context.Context, used float64) {
+	level := degradationFor(config.GetDegradationConfig(), used)
+	budgetUsedBits.Store(math.Float64bits(used))
+	upstreamBudgetUsed.Set(used)
+	degradationLevelGauge.Set(float64(level))
+	if prev := DegradationLevel(degradationLevel.Swap(int32(level))); prev != level {
+		logger(ctx).Warnw("Degradation level changed", "from", prev.String(), "to", level.String(), "budgetUsed", used)
+	}
+}
+
+// degradedTTL lengthens ttl while the ladder of the account that served the request is at
+// DegradationExtendedTTL or above.
+func degradedTTL(ctx context.Context, ttl time.Duration) time.Duration {
+	if level, _ := budgetFor(ctx); level < DegradationExtendedTTL {
+		return ttl
+	}
+	return time.Duration(float64(ttl) * config.GetDegradationConfig().TTLMultiplier)
+}
+
+// budgetFor returns the ladder step and quota share of the account a request is billed to: a
+// tenant's own account, or the shared one.
+func budgetFor(ctx context.Context) (DegradationLevel, float64) {
+	if p, ok := tenant.FromContext(ctx); ok {
+		used, _ := tenantBudgetUsed.Load(p.Account())
+		share, _ := used.(float64)
+		return degradationFor(config.GetDegradationConfig(), share), share
+	}
+	return currentDegradationLevel(), math.Float64frombits(budgetUsedBits.Load())
+}
+
+// QuotaRemaining returns how many provider calls are left today on the account a request is billed
+// to, and that account's daily quota, as of the last budget update. ok is false when the account
+// has no daily quota.
+func QuotaRemaining(ctx context.Context) (remaining, quota int64, ok bool) {
+	quota = config.GetCostConfig().Providers[providerOpenWeatherMap].DailyQuota
+	if p, isTenant := tenant.FromContext(ctx); isTenant {
+		quota = p.DailyQuota
+	}
+	if quota <= 0 {
+		return 0, 0, false
+	}
+	_, used := budgetFor(ctx)
+	return max(quota-int64(math.Round(used*float64(quota))), 0), quota, true
+}
+
+// degradationLevelFor returns the ladder step that applies to a request, given its account's quota
+// and its priority. High-priority requests keep calling the provider until the quota is exhausted;
+// low-priority requests step down once priority.low_budget_share of each threshold is used.
+func degradationLevelFor(ctx context.Context) DegradationLevel {
+	level, used := budgetFor(ctx)
+	switch priority.FromContext(ctx) {
+	case priority.High:
+		if level < DegradationCacheOnly {
+			return DegradationNormal
+		}
+	case priority.Low:
+		return max(level, degradationFor(config.GetDegradationConfig(), used/config.GetPriorityConfig().LowBudgetShare))
+	}
+	return level
+}
+
+// degradePolicy applies the ladder to the cache policy of a request.
+func degradePolicy(ctx context.Context, policy CachePolicy) CachePolicy {
+	switch level := degradationLevelFor(ctx); {
+	case level >= DegradationCacheOnly:
+		return CachePolicyCacheOnly
+	case level >= DegradationNoRefresh && policy == CachePolicyRefresh:
+		return CachePolicyDefault
+	default:
+		return policy
+	}
+}
+
+// budgetRedisKey holds the quota shares last published by the leader, one field per account.
+const budgetRedisKey = "costs:budget"
+
+// budgetShares returns today's quota share of the primary provider, and of every tenant account,
+// from the ledger.
+func (l *costLedger) budgetShares(ctx context.Context) (map[string]float64, error) {
+	used, err := l.quotaUsed(ctx, providerOpenWeatherMap, config.GetCostConfig().Providers[providerOpenWeatherMap].DailyQuota)
+	if err != nil {
+		return nil, err
+	}
+	shares := map[string]float64{providerOpenWeatherMap: used}
+	for _, account := range tenantAccounts() {
+		used, err := l.quotaUsed(ctx, account.Account(), account.DailyQuota)
+		if err != nil {
+			return nil, err
+		}
+		shares[account.Account()] = used
+	}
+	return shares, nil
+}
+
+// applyBudgetShares moves the ladder of every account to its quota share.
+func applyBudgetShares(ctx context.Context, shares map[string]float64) {
+	setBudgetUsed(ctx, shares[providerOpenWeatherMap])
+	for account, used := range shares {
+		if account != providerOpenWeatherMap {
+			tenantBudgetUsed.Store(account, used)
+		}
+	}
+}
+
+// updateBudgetUsed recomputes today's quota shares from the ledger and applies them.
+func (l *costLedger) updateBudgetUsed(ctx context.Context) error {
+	shares, err := l.budgetShares(ctx)
+	if err != nil {
+		return err
+	}
+	applyBudgetShares(ctx, shares)
+	return nil
+}
+
+// publishBudgetUsed recomputes and applies the quota shares, then publishes them for the other
+// replicas. They expire after three flush intervals, so that followers compute the shares
+// themselves if the leader stops publishing.
+func (l *costLedger) publishBudgetUsed(ctx context.Context) error {
+	shares, err := l.budgetShares(ctx)
+	if err != nil {
+		return err
+	}
+	applyBudgetShares(ctx, shares)
+	fields := make(map[string]interface{}, len(shares))
+	for account, used := range shares {
+		fields[account] = strconv.FormatFloat(used, 'f', -1, 64)
+	}
+	if err := l.client.HSet(ctx, budgetRedisKey, fields).Err(); err != nil {
+		return err
+	}
+	return l.client.Expire(ctx, budgetRedisKey, 3*config.GetCostConfig().FlushInterval).Err()
+}
+
+// loadBudgetUsed applies the quota shares published by the leader, or computes them when none are
+// published.
+func (l *costLedger) loadBudgetUsed(ctx context.Context) error {
+	raw, err := l.client.HGetAll(ctx, budgetRedisKey).Result()
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return l.updateBudgetUsed(ctx)
+	}
+	shares := make(map[string]float64, len(raw))
+	for account, value := range raw {
+		if used, err := strconv.ParseFloat(value, 64); err == nil {
+			shares[account] = used
+		}
+	}
+	applyBudgetShares(ctx, shares)
+	return nil
+}
+
+// quotaUsed returns the share of quota the account has spent today, or 0 when quota is unlimited.
+func (l *costLedger) quotaUsed(ctx context.Context, account string, quota int64) (float64, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	day := l.now().UTC().Format(costDayFormat)
+	raw, err := l.client.HGetAll(ctx, costRedisKey(account, day)).Result()
+	if err != nil {
+		return 0, err
+	}
+	calls, _ := strconv.ParseInt(raw["calls"], 10, 64)
+	return float64(calls) / float64(quota), nil
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/priority"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/spf13/viper"
+)
+
+// withDegradation enables the ladder and restores the normal level after the test.
+func withDegradation(t *testing.T) {
+	t.Helper()
+	viper.Set("degradation.enabled", true)
+	t.Cleanup(func() {
+		viper.Set("degradation.enabled", nil)
+		setBudgetUsed(context.Background(), 0)
+	})
+}
+
+func TestDegradationFor(t *testing.T) {
+	cfg := config.DegradationConfig{Enabled: true, ExtendTTLAt: 0.75, DisableRefreshAt: 0.9, CacheOnlyAt: 1}
+	for used, want := range map[float64]DegradationLevel{
+		0:    DegradationNormal,
+		0.74: DegradationNormal,
+		0.75: DegradationExtendedTTL,
+		0.95: DegradationNoRefresh,
+		1:    DegradationCacheOnly,
+		1.3:  DegradationCacheOnly,
+	} {
+		if got := degradationFor(cfg, used); got != want {
+			t.Errorf("degradationFor(%v) = %v, want %v", used, got, want)
+		}
+	}
+	cfg.Enabled = false
+	if got := degradationFor(cfg, 2); got != DegradationNormal {
+		t.Errorf("Expected no degradation when disabled, got %v", got)
+	}
+}
+
+func TestDegradationLadder(t *testing.T) {
+	withDegradation(t)
+	ctx := context.Background()
+	refresh := WithCachePolicy(ctx, CachePolicyRefresh)
+
+	setBudgetUsed(ctx, 0.8)
+	if got := CurrentDegradation(); got.Level != "extended_ttl" || got.BudgetUsed != 0.8 {
+		t.Errorf("Unexpected state %+v", got)
+	}
+	if got := degradedTTL(ctx, time.Minute); got != 4*time.Minute {
+		t.Errorf("Expected TTL multiplied by 4, got %v", got)
+	}
+	if CachePolicyFrom(refresh) != CachePolicyRefresh {
+		t.Error("Expected refresh still allowed at extended_ttl")
+	}
+
+	setBudgetUsed(ctx, 0.9)
+	if CachePolicyFrom(refresh) != CachePolicyDefault {
+		t.Error("Expected refresh downgraded at no_refresh")
+	}
+	if CachePolicyFrom(ctx) != CachePolicyDefault {
+		t.Error("Expected default policy unchanged at no_refresh")
+	}
+
+	setBudgetUsed(ctx, 1)
+	if CachePolicyFrom(ctx) != CachePolicyCacheOnly || CachePolicyFrom(refresh) != CachePolicyCacheOnly {
+		t.Error("Expected cache-only once the quota is spent")
+	}
+	if degradationLevelGauge.Value() != 3 || upstreamBudgetUsed.Value() != 1 {
+		t.Errorf("Expected gauges to follow, got level %v budget %v", degradationLevelGauge.Value(), upstreamBudgetUsed.Value())
+	}
+
+	setBudgetUsed(ctx, 0.1)
+	if degradedTTL(ctx, time.Minute) != time.Minute || CachePolicyFrom(refresh) != CachePolicyRefresh {
+		t.Error("Expected the ladder to step back down when usage drops")
+	}
+}
+
+func TestDegradationLadder_Priority(t *testing.T) {
+	withDegradation(t)
+	ctx := context.Background()
+	low := WithCachePolicy(priority.WithClass(ctx, priority.Low), CachePolicyRefresh)
+	high := WithCachePolicy(priority.WithClass(ctx, priority.High), CachePolicyRefresh)
+	normal := WithCachePolicy(ctx, CachePolicyRefresh)
+
+	// 0.7 is below every threshold, but low priority steps down at 75% of them (0.675 for no_refresh)
+	setBudgetUsed(ctx, 0.7)
+	if CachePolicyFrom(normal) != CachePolicyRefresh || CachePolicyFrom(low) != CachePolicyDefault {
+		t.Error("Expected only low-priority refreshes downgraded")
+	}
+
+	setBudgetUsed(ctx, 0.95)
+	if CachePolicyFrom(normal) != CachePolicyDefault || CachePolicyFrom(high) != CachePolicyRefresh {
+		t.Error("Expected high-priority refreshes allowed at no_refresh")
+	}
+	if CachePolicyFrom(priority.WithClass(ctx, priority.Low)) != CachePolicyCacheOnly {
+		t.Error("Expected low priority cache-only at 75% of the quota")
+	}
+
+	setBudgetUsed(ctx, 1)
+	if CachePolicyFrom(high) != CachePolicyCacheOnly {
+		t.Error("Expected high priority cache-only once the quota is spent")
+	}
+}
+
+func TestCostLedger_UpdateBudgetUsed(t *testing.T) {
+	withDegradation(t)
+	ledger, _ := newCostFixture(t) // openweathermap daily_quota: 4
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		recordProviderCall(providerOpenWeatherMap)
+	}
+	if err := ledger.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.updateBudgetUsed(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := CurrentDegradation(); got.BudgetUsed != 0.75 || got.Level != "extended_ttl" {
+		t.Errorf("Unexpected state %+v", got)
+	}
+}
+
+func TestCostLedger_PublishBudgetUsed(t *testing.T) {
+	withDegradation(t)
+	ledger, mr := newCostFixture(t) // openweathermap daily_quota: 4
+	ctx := context.Background()
+
+	// Without a published budget a follower computes it itself
+	recordProviderCall(providerOpenWeatherMap)
+	if err := ledger.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.loadBudgetUsed(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := CurrentDegradation(); got.BudgetUsed != 0.25 {
+		t.Errorf("Expected the budget computed locally, got %+v", got)
+	}
+
+	if err := ledger.publishBudgetUsed(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mr.HGet(budgetRedisKey, providerOpenWeatherMap); got != "0.25" {
+		t.Errorf("Expected the share published, got %q", got)
+	}
+	if mr.TTL(budgetRedisKey) <= 0 {
+		t.Error("Expected the published budget to expire")
+	}
+
+	// A follower applies what the leader published
+	mr.HSet(budgetRedisKey, providerOpenWeatherMap, "0.8")
+	if err := ledger.loadBudgetUsed(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := CurrentDegradation(); got.BudgetUsed != 0.8 || got.Level != "extended_ttl" {
+		t.Errorf("Expected the published budget applied, got %+v", got)
+	}
+}
+
+func TestCostLedger_TenantAccounts(t *testing.T) {
+	withDegradation(t)
+	ledger, _ := newCostFixture(t) // openweathermap price_per_call: 0.01
+	viper.Set("auth.api_keys", []map[string]interface{}{
+		{"key": "k", "name": "acme", "provider": map[string]interface{}{"api_key_env": "ACME_OWM_KEY", "daily_quota": 2}},
+	})
+	t.Cleanup(func() {
+		viper.Set("auth.api_keys", nil)
+		tenantBudgetUsed.Delete("openweathermap:acme")
+	})
+	ctx := context.Background()
+	acme := tenant.WithProvider(ctx, tenant.Provider{Tenant: "acme", Name: "openweathermap"})
+
+	recordProviderCall("openweathermap:acme")
+	recordProviderCall("openweathermap:acme")
+	reports, err := ledger.Report(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reports) != 3 || reports[2].Provider != "openweathermap:acme" {
+		t.Fatalf("Expected the tenant account reported last, got %+v", reports)
+	}
+	if got := reports[2]; got.PricePerCall != 0.01 || got.DailyQuota != 2 || got.QuotaUsed != 1 || got.Total.Calls != 2 {
+		t.Errorf("Unexpected tenant report %+v", got)
+	}
+	if reports[0].Total.Calls != 0 {
+		t.Errorf("Expected tenant calls kept out of the shared account, got %+v", reports[0].Total)
+	}
+
+	if err := ledger.updateBudgetUsed(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if CachePolicyFrom(acme) != CachePolicyCacheOnly {
+		t.Error("Expected the tenant cache-only once its own quota is spent")
+	}
+	if CachePolicyFrom(ctx) != CachePolicyDefault || CurrentDegradation().Level != "normal" {
+		t.Error("Expected shared requests unaffected by the tenant's quota")
+	}
+	if degradedTTL(acme, time.Minute) != 4*time.Minute || degradedTTL(ctx, time.Minute) != time.Minute {
+		t.Error("Expected only the tenant's TTLs extended")
+	}
+
+	// The shared account's ladder does not extend a tenant's TTLs either
+	setBudgetUsed(ctx, 0.8)
+	tenantBudgetUsed.Store("openweathermap:acme", 0.1)
+	if degradedTTL(acme, time.Minute) != time.Minute || degradedTTL(ctx, time.Minute) != 4*time.Minute {
+		t.Error("Expected only the shared account's TTLs extended")
+	}
+}
+
+func TestQuotaRemaining(t *testing.T) {
+	newCostFixture(t) // openweathermap daily_quota: 4
+	t.Cleanup(func() {
+		setBudgetUsed(context.Background(), 0)
+		tenantBudgetUsed.Delete("openweathermap:acme")
+	})
+	ctx := context.Background()
+
+	setBudgetUsed(ctx, 0.25)
+	if remaining, quota, ok := QuotaRemaining(ctx); !ok || remaining != 3 || quota != 4 {
+		t.Errorf("Expected 3 of 4 calls left, got %d of %d (%v)", remaining, quota, ok)
+	}
+	setBudgetUsed(ctx, 1.5)
+	if remaining, _, _ := QuotaRemaining(ctx); remaining != 0 {
+		t.Errorf("Expected nothing left over quota, got %d", remaining)
+	}
+
+	acme := tenant.WithProvider(ctx, tenant.Provider{Tenant: "acme", Name: "openweathermap", DailyQuota: 10})
+	tenantBudgetUsed.Store("openweathermap:acme", 0.3)
+	if remaining, quota, ok := QuotaRemaining(acme); !ok || remaining != 7 || quota != 10 {
+		t.Errorf("Expected the tenant's own quota, got %d of %d (%v)", remaining, quota, ok)
+	}
+	noQuota := tenant.WithProvider(ctx, tenant.Provider{Tenant: "free", Name: "openweathermap"})
+	if _, _, ok := QuotaRemaining(noQuota); ok {
+		t.Error("Expected no quota reported for an account without one")
+	}
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	deliveryQueueKey   = "webhook:deliveries"
+	deliveryRetriesKey = "webhook:retries"
+	// DeliveryGroup is the consumer group every replica's delivery workers read from.
+	DeliveryGroup = "webhook-workers"
+	// deliveryJobField is the stream entry field holding the encoded job.
+	deliveryJobField = "job"
+	// maxClaimScans bounds the XAUTOCLAIM calls Claim makes looking for an abandoned job.
+	maxClaimScans = 10
+)
+
+// promoteRetriesScript moves up to ARGV[2] jobs scheduled at or before ARGV[1] (Unix ms) from
+// the retry set KEYS[1] to the stream KEYS[2], capped at about ARGV[3] entries. It runs as one
+// script so that a job is never in both, or in neither.
+const promoteRetriesScript = `local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, job in ipairs(due) do
+  redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[3], "*", "job", job)
+  redis.call("ZREM", KEYS[1], job)
+end
+return #due`
+
+// DeliveryJob is one webhook delivery waiting to be attempted.
+type DeliveryJob struct {
+	SubscriptionID string             `json:"subscription_id"`
+	Event          model.WebhookEvent `json:"event"`
+	// Attempt numbers the next attempt, from 1.
+	Attempt int `json:"attempt"`
+}
+
+// QueuedDelivery is a job read from the queue. It stays pending for its consumer until acked.
+type QueuedDelivery struct {
+	// ID is the stream entry ID, passed to Ack.
+	ID  string
+	Job DeliveryJob
+}
+
+// DeliveryQueueStats describes the delivery backlog.
+type DeliveryQueueStats struct {
+	// Length is the number of entries kept in the stream, delivered ones included.
+	Length int64 `json:"length"`
+	// Lag is the number of entries no worker has read yet.
+	Lag int64 `json:"lag"`
+	// Pending is the number of entries read by a worker but not yet acknowledged.
+	Pending int64 `json:"pending"`
+	// Scheduled is the number of failed deliveries waiting for their backoff before a retry.
+	Scheduled int64 `json:"scheduled"`
+	// Consumers is the number of workers, across replicas, that have read from the queue.
+	Consumers int64 `json:"consumers"`
+}
+
+// DeliveryQueue holds webhook deliveries in a Redis stream read by a consumer group, so that any
+// replica's workers can deliver them and a delivery outlives the replica that queued it. An entry
+// read by a worker that dies before acknowledging it is claimed by another worker once it has
+// been idle long enough. Retries wait in a sorted set, scored by when they are due, until
+// PromoteDue queues them again.
+type DeliveryQueue interface {
+	Enqueue(ctx context.Context, job DeliveryJob) error
+	// Retry schedules job to be queued again at at.
+	Retry(ctx context.Context, job DeliveryJob, at time.Time) error
+	// PromoteDue queues the retries due by now, and returns how many it queued.
+	PromoteDue(ctx context.Context, now time.Time) (int, error)
+	// Read returns the next job for consumer, waiting up to block for one, or not at all when
+	// negative. It returns nil when none arrives in time, or the one read could not be decoded.
+	Read(ctx context.Context, consumer string, block time.Duration) (*QueuedDelivery, error)
+	// Claim takes over for consumer a job another consumer has left unacknowledged for at least
+	// minIdle. It returns nil when there is none.
+	Claim(ctx context.Context, consumer string, minIdle time.Duration) (*QueuedDelivery, error)
+	// Ack marks a job as handled, whether it was delivered, rescheduled or given up.
+	Ack(ctx context.Context, id string) error
+	Stats(ctx context.Context) (DeliveryQueueStats, error)
+}
+
+// DeliveryQueueClient is the subset of Redis operations needed to queue webhook deliveries.
+type DeliveryQueueClient interface {
+	XAdd(ctx context.Context, a *redisv9.XAddArgs) *redisv9.StringCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redisv9.StatusCmd
+	XReadGroup(ctx context.Context, a *redisv9.XReadGroupArgs) *redisv9.XStreamSliceCmd
+	XAutoClaim(ctx context.Context, a *redisv9.XAutoClaimArgs) *redisv9.XAutoClaimCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redisv9.IntCmd
+	XLen(ctx context.Context, stream string) *redisv9.IntCmd
+	XInfoGroups(ctx context.Context, key string) *redisv9.XInfoGroupsCmd
+	ZAdd(ctx context.Context, key string, members ...redisv9.Z) *redisv9.IntCmd
+	ZCard(ctx context.Context, key string) *redisv9.IntCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redisv9.Cmd
+}
+
+type deliveryQueue struct {
+	client DeliveryQueueClient
+}
+
+// NewDeliveryQueue creates a DeliveryQueue backed by the shared Redis client.
+func NewDeliveryQueue(client ...DeliveryQueueClient) DeliveryQueue {
+	var c DeliveryQueueClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &deliveryQueue{client: c}
+}
+
+func (q *deliveryQueue) Enqueue(ctx context.Context, job DeliveryJob) error {
+	b, err := marshalCacheValue(deliveryQueueKey, job)
+	if err != nil {
+		return err
+	}
+	return q.client.XAdd(ctx, &redisv9.XAddArgs{
+		Stream: deliveryQueueKey,
+		MaxLen: config.GetWebhookConfig().QueueMaxLen,
+		Approx: true,
+		Values: []interface{}{deliveryJobField, b},
+	}).Err()
+}
+
+func (q *deliveryQueue) Retry(ctx context.Context, job DeliveryJob, at time.Time) error {
+	b, err := marshalCacheValue(deliveryQueueKey, job)
+	if err != nil {
+		return err
+	}
+	return q.client.ZAdd(ctx, deliveryRetriesKey, redisv9.Z{Score: float64(at.UnixMilli()), Member: b}).Err()
+}
+
+func (q *deliveryQueue) PromoteDue(ctx context.Context, now time.Time) (int, error) {
+	return q.client.Eval(ctx, promoteRetriesScript, []string{deliveryRetriesKey, deliveryQueueKey},
+		now.UnixMilli(), 100, config.GetWebhookConfig().QueueMaxLen).Int()
+}
+
+func (q *deliveryQueue) Read(ctx context.Context, consumer string, block time.Duration) (*QueuedDelivery, error) {
+	read := func() ([]redisv9.XStream, error) {
+		return q.client.XReadGroup(ctx, &redisv9.XReadGroupArgs{
+			Group:    DeliveryGroup,
+			Consumer: consumer,
+			Streams:  []string{deliveryQueueKey, ">"},
+			Count:    1,
+			Block:    block,
+		}).Result()
+	}
+	streams, err := read()
+	if missingGroup(err) {
+		if err = q.createGroup(ctx); err == nil {
+			streams, err = read()
+		}
+	}
+	if errors.Is(err, redisv9.Nil) {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return q.decode(ctx, streams[0].Messages)
+}
+
+func (q *deliveryQueue) Claim(ctx context.Context, consumer string, minIdle time.Duration) (*QueuedDelivery, error) {
+	claim := func(start string) ([]redisv9.XMessage, string, error) {
+		return q.client.XAutoClaim(ctx, &redisv9.XAutoClaimArgs{
+			Stream:   deliveryQueueKey,
+			Group:    DeliveryGroup,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    1,
+		}).Result()
+	}
+	// Each call only scans a few pending entries, so follow the cursor past entries still being
+	// worked on, up to a bound
+	start := "0-0"
+	for range maxClaimScans {
+		msgs, next, err := claim(start)
+		if missingGroup(err) {
+			if err = q.createGroup(ctx); err == nil {
+				msgs, next, err = claim(start)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) > 0 {
+			return q.decode(ctx, msgs)
+		}
+		if next == "0-0" || next == "" {
+			break
+		}
+		start = next
+	}
+	return nil, nil
+}
+
+// decode returns the first job in msgs. Entries that cannot be decoded are acknowledged and
+// skipped, so they are not claimed over and over.
+func (q *deliveryQueue) decode(ctx context.Context, msgs []redisv9.XMessage) (*QueuedDelivery, error) {
+	for _, msg := range msgs {
+		raw, _ := msg.Values[deliveryJobField].(string)
+		var job DeliveryJob
+		if err := unmarshalCacheValue(deliveryQueueKey, []byte(raw), &job); err != nil {
+			logger(ctx).Warnw("Dropping undecodable webhook delivery", "entry", msg.ID, "error", err)
+			if err := q.Ack(ctx, msg.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return &QueuedDelivery{ID: msg.ID, Job: job}, nil
+	}
+	return nil, nil
+}
+
+func (q *deliveryQueue) Ack(ctx context.Context, id string) error {
+	return q.client.XAck(ctx, deliveryQueueKey, DeliveryGroup, id).Err()
+}
+
+func (q *deliveryQueue) Stats(ctx context.Context) (DeliveryQueueStats, error) {
+	var stats DeliveryQueueStats
+	scheduled, err := q.client.ZCard(ctx, deliveryRetriesKey).Result()
+	if err != nil {
+		return stats, err
+	}
+	stats.Scheduled = scheduled
+	if stats.Length, err = q.client.XLen(ctx, deliveryQueueKey).Result(); err != nil {
+		return stats, err
+	}
+	groups, err := q.client.XInfoGroups(ctx, deliveryQueueKey).Result()
+	if err != nil {
+		if missingStream(err) {
+			return stats, nil
+		}
+		return stats, err
+	}
+	for _, g := range groups {
+		if g.Name != DeliveryGroup {
+			continue
+		}
+		stats.Pending = g.Pending
+		stats.Consumers = g.Consumers
+		stats.Lag = g.Lag
+		if g.Lag < 0 {
+			// Redis cannot tell after some deletions; everything after the last read is a fair bound
+			stats.Lag = max(stats.Length-g.EntriesRead, 0)
+		}
+	}
+	if len(groups) == 0 {
+		// Nothing has been read yet
+		stats.Lag = stats.Length
+	}
+	return stats, nil
+}
+
+// createGroup creates the consumer group, and the stream if needed, starting from the stream's
+// first entry so that jobs queued before any worker started are delivered too.
+func (q *deliveryQueue) createGroup(ctx context.Context) error {
+	err := q.client.XGroupCreateMkStream(ctx, deliveryQueueKey, DeliveryGroup, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func missingGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+func missingStream(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "no such key")
+}
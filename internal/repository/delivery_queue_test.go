@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestDeliveryQueue(t *testing.T) {
+	client, _ := newRedisClient(t)
+	queue := NewDeliveryQueue(client)
+	ctx := context.Background()
+
+	if stats, err := queue.Stats(ctx); err != nil || stats != (DeliveryQueueStats{}) {
+		t.Fatalf("Expected empty stats before the first delivery, got %+v, %v", stats, err)
+	}
+	if delivery, err := queue.Read(ctx, "a", -1); err != nil || delivery != nil {
+		t.Fatalf("Expected nothing to read, got %+v, %v", delivery, err)
+	}
+
+	for _, id := range []string{"sub_1", "sub_2"} {
+		job := DeliveryJob{SubscriptionID: id, Event: model.WebhookEvent{ID: "evt_1", Location: "London"}, Attempt: 1}
+		if err := queue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	first, err := queue.Read(ctx, "a", -1)
+	if err != nil || first == nil || first.Job.SubscriptionID != "sub_1" || first.Job.Event.Location != "London" {
+		t.Fatalf("Expected the first job, got %+v, %v", first, err)
+	}
+	if stats, _ := queue.Stats(ctx); stats.Length != 2 || stats.Pending != 1 || stats.Consumers != 1 {
+		t.Errorf("Expected one of two jobs pending, got %+v", stats)
+	}
+
+	// Consumer a stops; b takes its job over once it has been idle for long enough
+	if claimed, err := queue.Claim(ctx, "b", time.Hour); err != nil || claimed != nil {
+		t.Errorf("Expected nothing idle for an hour, got %+v, %v", claimed, err)
+	}
+	claimed, err := queue.Claim(ctx, "b", 0)
+	if err != nil || claimed == nil || claimed.ID != first.ID {
+		t.Fatalf("Expected b to claim %s, got %+v, %v", first.ID, claimed, err)
+	}
+	if err := queue.Ack(ctx, claimed.ID); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+
+	second, err := queue.Read(ctx, "b", -1)
+	if err != nil || second == nil || second.Job.SubscriptionID != "sub_2" {
+		t.Fatalf("Expected the second job, got %+v, %v", second, err)
+	}
+	now := time.Now()
+	second.Job.Attempt++
+	if err := queue.Retry(ctx, second.Job, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if err := queue.Ack(ctx, second.ID); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if stats, _ := queue.Stats(ctx); stats.Pending != 0 || stats.Scheduled != 1 || stats.Consumers != 2 {
+		t.Errorf("Expected one scheduled retry, got %+v", stats)
+	}
+
+	if n, err := queue.PromoteDue(ctx, now); err != nil || n != 0 {
+		t.Errorf("Expected no retry due yet, got %d, %v", n, err)
+	}
+	if n, err := queue.PromoteDue(ctx, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("Expected the retry queued, got %d, %v", n, err)
+	}
+	retry, err := queue.Read(ctx, "a", -1)
+	if err != nil || retry == nil || retry.Job.SubscriptionID != "sub_2" || retry.Job.Attempt != 2 {
+		t.Fatalf("Expected the second attempt, got %+v, %v", retry, err)
+	}
+	if stats, _ := queue.Stats(ctx); stats.Length != 3 || stats.Scheduled != 0 {
+		t.Errorf("Expected the retry moved to the stream, got %+v", stats)
+	}
+}
+
+func TestDeliveryQueue_SkipsUndecodableEntries(t *testing.T) {
+	client, _ := newRedisClient(t)
+	queue := NewDeliveryQueue(client)
+	ctx := context.Background()
+
+	if err := client.XAdd(ctx, &redisv9.XAddArgs{Stream: deliveryQueueKey, Values: []interface{}{deliveryJobField, "not json"}}).Err(); err != nil {
+		t.Fatalf("XAdd: %v", err)
+	}
+	if err := queue.Enqueue(ctx, DeliveryJob{SubscriptionID: "sub_1", Attempt: 1}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if delivery, err := queue.Read(ctx, "a", -1); err != nil || delivery != nil {
+		t.Fatalf("Expected the undecodable entry skipped, got %+v, %v", delivery, err)
+	}
+	if stats, _ := queue.Stats(ctx); stats.Pending != 0 {
+		t.Errorf("Expected the undecodable entry acknowledged, got %+v", stats)
+	}
+	if delivery, err := queue.Read(ctx, "a", -1); err != nil || delivery == nil || delivery.Job.SubscriptionID != "sub_1" {
+		t.Errorf("Expected the next job, got %+v, %v", delivery, err)
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// ErrFeedNotFound is returned for cities that are not warm-listed and therefore have no feed.
+var ErrFeedNotFound = errors.New("no feed for city")
+
+// FeedItem is one material change in a city's conditions.
+type FeedItem struct {
+	Weather model.WeatherResponse `json:"weather"`
+	At      time.Time             `json:"at"`
+}
+
+// FeedStore keeps a short history of material condition changes for every warm-listed city.
+type FeedStore interface {
+	// Record appends an item for update when its city is warm-listed and its conditions differ
+	// materially from the latest item.
+	Record(ctx context.Context, update events.CacheUpdate) error
+	// Items returns the feed of city, newest first.
+	Items(ctx context.Context, city string) ([]FeedItem, error)
+}
+
+// ListClient is the subset of Redis operations needed to keep capped lists.
+type ListClient interface {
+	LPush(ctx context.Context, key string, values ...interface{}) *redisv9.IntCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *redisv9.StatusCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redisv9.StringSliceCmd
+}
+
+type feedStore struct {
+	client ListClient
+}
+
+// NewFeedStore creates a FeedStore backed by the shared Redis client.
+func NewFeedStore(client ...ListClient) FeedStore {
+	var c ListClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &feedStore{client: c}
+}
+
+// feedKey normalises a city name so "London" and " london" share one feed.
+func feedKey(city string) string {
+	return "feed:" + strings.ToLower(strings.TrimSpace(city))
+}
+
+// isWarmCity reports whether city is listed in cache.warm_cities.
+func isWarmCity(city string) bool {
+	for _, c := range config.GetWarmCities() {
+		if strings.EqualFold(strings.TrimSpace(c), strings.TrimSpace(city)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *feedStore) Record(ctx context.Context, update events.CacheUpdate) error {
+	if !isWarmCity(update.Location) {
+		return nil
+	}
+	cfg := config.GetFeedConfig()
+	key := feedKey(update.Location)
+
+	latest, err := s.client.LRange(ctx, key, 0, 0).Result()
+	if err != nil {
+		return err
+	}
+	if len(latest) > 0 {
+		var prev FeedItem
+		if err := unmarshalCacheValue(key, []byte(latest[0]), &prev); err == nil && !materialChange(cfg, &prev.Weather, &update.Weather) {
+			return nil
+		}
+	}
+
+	weather := update.Weather
+	weather.Cached = false
+	weather.Stale = false
+	b, err := marshalCacheValue(key, FeedItem{Weather: weather, At: update.At.UTC()})
+	if err != nil {
+		return err
+	}
+	if err := s.client.LPush(ctx, key, b).Err(); err != nil {
+		return err
+	}
+	return s.client.LTrim(ctx, key, 0, int64(cfg.MaxItems-1)).Err()
+}
+
+func (s *feedStore) Items(ctx context.Context, city string) ([]FeedItem, error) {
+	if !isWarmCity(city) {
+		return nil, ErrFeedNotFound
+	}
+	key := feedKey(city)
+	raw, err := s.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]FeedItem, 0, len(raw))
+	for _, v := range raw {
+		var item FeedItem
+		if err := unmarshalCacheValue(key, []byte(v), &item); err != nil {
+			logger(ctx).Warnw("Skipping undecodable feed item", "city", city, "error", err)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// materialChange reports whether the conditions moved enough to be worth a feed item: a new
+// description or a temperature change of at least feed.temp_delta.
+func materialChange(cfg config.FeedConfig, prev, next *model.WeatherResponse) bool {
+	return prev.Description != next.Description || math.Abs(next.Temperature-prev.Temperature) >= cfg.TempDelta
+}
+
+// RecordCacheUpdates feeds every published cache update into store until unsubscribed.
+func RecordCacheUpdates(store FeedStore) (unsubscribe func()) {
+	return events.CacheUpdates.Subscribe(func(update events.CacheUpdate) {
+		ctx := logctx.WithLocation(context.Background(), update.Location)
+		if err := store.Record(ctx, update); err != nil {
+			logger(ctx).Warnw("Failed to record feed item", "error", err)
+		}
+	})
+}
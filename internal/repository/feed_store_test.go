@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func newFeedFixture(t *testing.T) FeedStore {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	viper.Set("cache.warm_cities", []string{"London"})
+	viper.Set("feed.max_items", 3)
+	t.Cleanup(func() {
+		viper.Set("cache.warm_cities", nil)
+		viper.Set("feed.max_items", nil)
+	})
+	return NewFeedStore(client)
+}
+
+func feedUpdate(location string, temp float64, desc string, at time.Time) events.CacheUpdate {
+	return events.CacheUpdate{
+		Location: location,
+		Weather:  model.WeatherResponse{Location: "London", Temperature: temp, Description: desc},
+		At:       at,
+	}
+}
+
+func TestFeedStore_RecordsMaterialChangesOnly(t *testing.T) {
+	store := newFeedFixture(t)
+	ctx := context.Background()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, u := range []events.CacheUpdate{
+		feedUpdate("london", 15, "clear sky", at),
+		feedUpdate("london", 15.4, "clear sky", at.Add(time.Minute)),   // below temp_delta, skipped
+		feedUpdate("london", 16.2, "clear sky", at.Add(2*time.Minute)), // +1.2°C
+		feedUpdate("London", 16.2, "light rain", at.Add(3*time.Minute)),
+		feedUpdate("Paris", 30, "clear sky", at), // not warm-listed
+	} {
+		if err := store.Record(ctx, u); err != nil {
+			t.Fatalf("Record %d: %v", i, err)
+		}
+	}
+
+	items, err := store.Items(ctx, "LONDON")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items, got %+v", items)
+	}
+	if items[0].Weather.Description != "light rain" || items[2].Weather.Temperature != 15 {
+		t.Errorf("Expected newest first, got %+v", items)
+	}
+	if !items[0].At.Equal(at.Add(3 * time.Minute)) {
+		t.Errorf("Unexpected item time %v", items[0].At)
+	}
+
+	if _, err := store.Items(ctx, "Paris"); !errors.Is(err, ErrFeedNotFound) {
+		t.Errorf("Expected ErrFeedNotFound for a city outside the warm list, got %v", err)
+	}
+}
+
+func TestFeedStore_CapsItems(t *testing.T) {
+	store := newFeedFixture(t)
+	ctx := context.Background()
+	at := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = store.Record(ctx, feedUpdate("London", float64(i*5), "clear sky", at.Add(time.Duration(i)*time.Minute)))
+	}
+	items, _ := store.Items(ctx, "London")
+	if len(items) != 3 {
+		t.Fatalf("Expected feed capped at 3 items, got %d", len(items))
+	}
+	if items[0].Weather.Temperature != 20 {
+		t.Errorf("Expected the newest item kept, got %+v", items[0])
+	}
+}
+
+func TestMaterialChange(t *testing.T) {
+	cfg := config.FeedConfig{TempDelta: 1}
+	prev := &model.WeatherResponse{Temperature: 10, Description: "clouds"}
+	if materialChange(cfg, prev, &model.WeatherResponse{Temperature: 10.9, Description: "clouds"}) {
+		t.Error("Expected a 0.9°C change to be immaterial")
+	}
+	if !materialChange(cfg, prev, &model.WeatherResponse{Temperature: 9, Description: "clouds"}) {
+		t.Error("Expected a 1°C drop to be material")
+	}
+	if !materialChange(cfg, prev, &model.WeatherResponse{Temperature: 10, Description: "rain"}) {
+		t.Error("Expected a new description to be material")
+	}
+}
+
+func TestRecordCacheUpdates(t *testing.T) {
+	store := newFeedFixture(t)
+	unsubscribe := RecordCacheUpdates(store)
+	events.CacheUpdates.Publish(feedUpdate("London", 12, "clouds", time.Now()))
+	unsubscribe() // waits for the published update to be recorded
+
+	items, err := store.Items(context.Background(), "London")
+	if err != nil || len(items) != 1 || items[0].Weather.Description != "clouds" {
+		t.Errorf("Expected the published update in the feed, got %+v, %v", items, err)
+	}
+}
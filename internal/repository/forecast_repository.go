@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// MaxForecastHours is the longest horizon of an hourly forecast.
+const MaxForecastHours = 48
+
+// ErrForecastHours is returned for an hourly forecast horizon outside 1 to MaxForecastHours.
+var ErrForecastHours = errors.New("forecast hours must be from 1 to " + strconv.Itoa(MaxForecastHours))
+
+// ForecastRepository defines the interface for forecast data access.
+type ForecastRepository interface {
+	GetForecast(ctx context.Context, location string) (*model.ForecastResponse, error)
+	GetHourlyForecast(ctx context.Context, location string, hours int) (*model.ForecastResponse, error)
+}
+
+// forecastRepository shares the Redis clients, write queue, provider client and cool-down of the
+// weather repository.
+type forecastRepository struct {
+	*weatherRepository
+}
+
+// NewForecastRepository creates a forecast repository. Provider calls use httpClient when given.
+func NewForecastRepository(httpClient ...*http.Client) ForecastRepository {
+	return &forecastRepository{NewWeatherRepository(httpClient...).(*weatherRepository)}
+}
+
+// forecastKey is scoped by tenant, like every forecast key.
+func forecastKey(ctx context.Context, location string) string {
+	return tenantScope(ctx) + "forecast:" + location
+}
+
+// hourlyForecastKey is per horizon, so a 12 hour forecast is not served from, or in place of, a
+// 48 hour one.
+func hourlyForecastKey(ctx context.Context, location string, hours int) string {
+	return tenantScope(ctx) + "forecast:hourly:" + strconv.Itoa(hours) + ":" + location
+}
+
+// GetForecast returns the 5 day / 3 hour forecast of location, from the cache when it holds one
+// younger than forecast.cache_ttl, otherwise from the provider.
+func (r *forecastRepository) GetForecast(ctx context.Context, location string) (*model.ForecastResponse, error) {
+	ctx = logctx.WithLocation(ctx, location)
+	return r.cachedForecast(ctx, forecastKey(ctx, location), func() (*model.ForecastResponse, error) {
+		return r.fetchForecast(ctx, config.GetOpenWeatherForecastURL(), location, 0)
+	})
+}
+
+// GetHourlyForecast returns the next hours hourly entries of location's forecast, cached like
+// GetForecast under a key of its own.
+func (r *forecastRepository) GetHourlyForecast(ctx context.Context, location string, hours int) (*model.ForecastResponse, error) {
+	if hours < 1 || hours > MaxForecastHours {
+		return nil, ErrForecastHours
+	}
+	ctx = logctx.WithLocation(ctx, location)
+	return r.cachedForecast(ctx, hourlyForecastKey(ctx, location, hours), func() (*model.ForecastResponse, error) {
+		forecast, err := r.fetchForecast(ctx, config.GetOpenWeatherHourlyForecastURL(), location, hours)
+		if err != nil {
+			return nil, err
+		}
+		forecast.Hours = hours
+		return forecast, nil
+	})
+}
+
+// cachedForecast returns the forecast cached under key, or fetches and caches it.
+func (r *forecastRepository) cachedForecast(ctx context.Context, key string, fetch func() (*model.ForecastResponse, error)) (*model.ForecastResponse, error) {
+	forecast, cached, err := cachedValue(ctx, r.weatherRepository, key, config.GetForecastConfig().CacheTTL, fetch)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Cached = cached
+	return forecast, nil
+}
+
+// cachedValue returns the value cached under key, reporting it as cached, or fetches the value
+// and caches it for ttl. Values are not fetched while the provider is rate limiting us. The cache
+// policy applies as to weather: a refresh skips the cache read, and a cache-only request, as in
+// offline mode or on the cache-only rung of the degradation ladder, returns ErrCacheOnlyMiss
+// rather than fetch.
+func cachedValue[T any](ctx context.Context, r *weatherRepository, key string, ttl time.Duration, fetch func() (*T, error)) (*T, bool, error) {
+	policy := CachePolicyFrom(ctx)
+	if policy != CachePolicyRefresh {
+		getCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Read)
+		val, err := r.reader().Get(getCtx, key).Bytes()
+		cancel()
+		if err == nil {
+			var value T
+			if err := unmarshalCacheValue(key, val, &value); err == nil {
+				return &value, true, nil
+			}
+			logger(ctx).Warnw("Undecodable cached value", "cacheKey", key, "error", err)
+		}
+	}
+	if policy == CachePolicyCacheOnly {
+		return nil, false, ErrCacheOnlyMiss
+	}
+
+	if retryAfter, cooling := r.coolingDown(ctx); cooling {
+		return nil, false, &CoolDownError{RetryAfter: retryAfter}
+	}
+	value, err := fetch()
+	if err != nil {
+		return nil, false, err
+	}
+	b, err := marshalCacheValue(key, value)
+	if err != nil {
+		logger(ctx).Errorw("Value not cached", "cacheKey", key, "error", err)
+		return value, false, nil
+	}
+	if r.writer == nil {
+		writeCacheEntry(ctx, r.redisClient, key, b, ttl)
+	} else if !r.writer.enqueue(ctx, key, b, ttl) {
+		logger(ctx).Warnw("Cache write queue full, value not cached", "cacheKey", key)
+	}
+	return value, false, nil
+}
+
+// fetchForecast calls the OpenWeatherMap forecast API at endpoint, with the tenant's own account
+// when the request carries one. A positive count keeps only the first count entries.
+func (r *forecastRepository) fetchForecast(ctx context.Context, endpoint, location string, count int) (*model.ForecastResponse, error) {
+	logger(ctx).Debugw("Fetching forecast from external API")
+	apiKey := config.GetOpenWeatherMapAPIKey()
+	if p, ok := tenant.FromContext(ctx); ok {
+		apiKey = p.APIKey
+	}
+	if apiKey == "" {
+		return nil, ErrAPIKeyMissing
+	}
+
+	u := fmt.Sprintf("%s?q=%s&appid=%s&units=metric", endpoint, url.QueryEscape(location), apiKey)
+	if count > 0 {
+		u += "&cnt=" + strconv.Itoa(count)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	case http.StatusNotFound:
+		return nil, &LocationNotFoundError{Message: "city not found"}
+	default:
+		return nil, ErrExternalAPI
+	}
+	endCoolDownStreak(ctx)
+
+	var data model.OpenWeatherMapForecastResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if count > 0 && len(data.List) > count {
+		data.List = data.List[:count]
+	}
+	precision := config.GetTemperaturePrecision()
+	forecast := &model.ForecastResponse{Location: data.City.Name, Entries: make([]model.ForecastEntry, 0, len(data.List))}
+	if forecast.Location == "" {
+		forecast.Location = location
+	}
+	for _, item := range data.List {
+		temperature, err := model.TemperatureFromNumber(item.Main.Temp, precision)
+		if err != nil {
+			return nil, err
+		}
+		entry := model.ForecastEntry{Time: time.Unix(item.Dt, 0).UTC(), Temperature: temperature}
+		if len(item.Weather) > 0 {
+			entry.Description = item.Weather[0].Description
+		}
+		forecast.Entries = append(forecast.Entries, entry)
+	}
+	return forecast, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/fakhrymubarak/weather-api-redis/internal/testutil"
+	"github.com/spf13/viper"
+)
+
+const forecastBody = `{"city": {"name": "Jakarta"}, "list": [
+	{"dt": 1717221600, "main": {"temp": 28.4}, "weather": [{"description": "light rain"}]},
+	{"dt": 1717232400, "main": {"temp": 31.2}, "weather": [{"description": "clouds"}]}
+]}`
+
+func TestGetForecast(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, mr := newRedisClient(t)
+	calls := 0
+	repo := &forecastRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			calls++
+			if !strings.HasSuffix(req.URL.Path, "/forecast") || req.URL.Query().Get("q") != "Jakarta" {
+				t.Errorf("Unexpected forecast request %s", req.URL)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(forecastBody)), Header: make(http.Header)}
+		}),
+	}}
+	ctx := context.Background()
+
+	forecast, err := repo.GetForecast(ctx, "Jakarta")
+	if err != nil {
+		t.Fatalf("GetForecast: %v", err)
+	}
+	if forecast.Cached || len(forecast.Entries) != 2 || forecast.Entries[1].Description != "clouds" || forecast.Entries[0].Temperature != 28.4 {
+		t.Errorf("Unexpected forecast %+v", forecast)
+	}
+	if !forecast.Entries[0].Time.Equal(time.Unix(1717221600, 0)) {
+		t.Errorf("Unexpected entry time %v", forecast.Entries[0].Time)
+	}
+	if ttl := mr.TTL(forecastKey(context.Background(), "Jakarta")); ttl <= 0 {
+		t.Errorf("Expected the forecast to be cached with a TTL, got %v", ttl)
+	}
+
+	forecast, err = repo.GetForecast(ctx, "Jakarta")
+	if err != nil || !forecast.Cached || len(forecast.Entries) != 2 {
+		t.Errorf("Expected the cached forecast, got %+v, %v", forecast, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected one provider call, got %d", calls)
+	}
+}
+
+func TestCachedValue_OfflineMode(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	viper.Set("offline_mode", true)
+	defer viper.Set("offline_mode", nil)
+	client, mr := newRedisClient(t)
+	calls := 0
+	weatherRepo := &weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+			calls++
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(forecastBody)), Header: make(http.Header)}
+		}),
+	}
+	ctx := context.Background()
+
+	forecasts := &forecastRepository{weatherRepo}
+	if _, err := forecasts.GetForecast(ctx, "Jakarta"); !errors.Is(err, ErrCacheOnlyMiss) {
+		t.Errorf("Expected ErrCacheOnlyMiss for the forecast, got %v", err)
+	}
+	if _, err := forecasts.GetHourlyForecast(ctx, "Jakarta", 12); !errors.Is(err, ErrCacheOnlyMiss) {
+		t.Errorf("Expected ErrCacheOnlyMiss for the hourly forecast, got %v", err)
+	}
+	if _, err := (&geocodeRepository{weatherRepo}).Geocode(ctx, "Jakarta"); !errors.Is(err, ErrCacheOnlyMiss) {
+		t.Errorf("Expected ErrCacheOnlyMiss for the geocode, got %v", err)
+	}
+	if _, err := (&alertsRepository{weatherRepo}).GetAlerts(ctx, "Jakarta"); !errors.Is(err, ErrCacheOnlyMiss) {
+		t.Errorf("Expected ErrCacheOnlyMiss for the alerts, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no provider call in offline mode, got %d", calls)
+	}
+
+	// What is cached is still served
+	if err := mr.Set(forecastKey(context.Background(), "Jakarta"), `{"location":"Jakarta","entries":[]}`); err != nil {
+		t.Fatal(err)
+	}
+	if forecast, err := forecasts.GetForecast(ctx, "Jakarta"); err != nil || !forecast.Cached {
+		t.Errorf("Expected the cached forecast, got %+v, %v", forecast, err)
+	}
+}
+
+func TestGetForecast_MockProvider(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	provider := &testutil.MockProvider{Seed: 11, Now: func() time.Time { return now }, APIKey: "testkey"}
+	srv := provider.Start()
+	defer srv.Close()
+	viper.Set("openweathermap.forecast_url", srv.URL+"/forecast")
+	defer viper.Set("openweathermap.forecast_url", nil)
+	client, _ := newRedisClient(t)
+	repo := &forecastRepository{&weatherRepository{redisClient: client, httpClient: srv.Client()}}
+
+	forecast, err := repo.GetForecast(context.Background(), "Oslo")
+	if err != nil {
+		t.Fatalf("GetForecast: %v", err)
+	}
+	want := provider.Forecast("Oslo", now)
+	if len(forecast.Entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(forecast.Entries))
+	}
+	for i, entry := range forecast.Entries {
+		if !entry.Time.Equal(want[i].Time) || entry.Temperature != want[i].Temperature || entry.Description != want[i].Description {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want[i], entry)
+		}
+	}
+}
+
+func TestGetHourlyForecast(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	now := time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)
+	provider := &testutil.MockProvider{Seed: 5, Now: func() time.Time { return now }}
+	srv := provider.Start()
+	defer srv.Close()
+	viper.Set("openweathermap.hourly_forecast_url", srv.URL+"/forecast/hourly")
+	defer viper.Set("openweathermap.hourly_forecast_url", nil)
+	client, mr := newRedisClient(t)
+	repo := &forecastRepository{&weatherRepository{redisClient: client, httpClient: srv.Client()}}
+	ctx := context.Background()
+
+	forecast, err := repo.GetHourlyForecast(ctx, "Jakarta", 12)
+	if err != nil {
+		t.Fatalf("GetHourlyForecast: %v", err)
+	}
+	want := provider.HourlyForecast("Jakarta", now)[:12]
+	if forecast.Hours != 12 || forecast.Cached || len(forecast.Entries) != 12 {
+		t.Fatalf("Unexpected forecast %+v", forecast)
+	}
+	for i, entry := range forecast.Entries {
+		if !entry.Time.Equal(want[i].Time) || entry.Temperature != want[i].Temperature {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want[i], entry)
+		}
+	}
+
+	// Another horizon has its own cache entry; the same one is served from the cache
+	long, err := repo.GetHourlyForecast(ctx, "Jakarta", 48)
+	if err != nil || long.Cached || len(long.Entries) != 48 || long.Hours != 48 {
+		t.Errorf("Expected a fresh 48 hour forecast, got %+v, %v", long, err)
+	}
+	forecast, err = repo.GetHourlyForecast(ctx, "Jakarta", 12)
+	if err != nil || !forecast.Cached || len(forecast.Entries) != 12 {
+		t.Errorf("Expected the cached 12 hour forecast, got %+v, %v", forecast, err)
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", provider.Calls())
+	}
+	for _, key := range []string{hourlyForecastKey(context.Background(), "Jakarta", 12), hourlyForecastKey(context.Background(), "Jakarta", 48)} {
+		if !mr.Exists(key) {
+			t.Errorf("Expected %s cached", key)
+		}
+	}
+
+	for _, hours := range []int{0, -1, MaxForecastHours + 1} {
+		if _, err := repo.GetHourlyForecast(ctx, "Jakarta", hours); !errors.Is(err, ErrForecastHours) {
+			t.Errorf("Expected ErrForecastHours for %d hours, got %v", hours, err)
+		}
+	}
+}
+
+func TestGetForecast_NotFound(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, _ := newRedisClient(t)
+	repo := &forecastRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`)), Header: make(http.Header)}
+		}),
+	}}
+	var notFound *LocationNotFoundError
+	if _, err := repo.GetForecast(context.Background(), "Atlantis"); !errors.As(err, &notFound) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+}
+
+func TestGetForecast_ScopedByTenant(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, mr := newRedisClient(t)
+	keys := map[string]int{}
+	repo := &forecastRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			keys[req.URL.Query().Get("appid")]++
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(forecastBody)), Header: make(http.Header)}
+		}),
+	}}
+	shared := context.Background()
+	acme := tenant.WithProvider(shared, tenant.Provider{Tenant: "acme", Name: "openweathermap", APIKey: "acme-key"})
+	globex := tenant.WithProvider(shared, tenant.Provider{Tenant: "globex", Name: "openweathermap", APIKey: "globex-key"})
+
+	for _, ctx := range []context.Context{shared, acme, globex, shared, acme, globex} {
+		if _, err := repo.GetForecast(ctx, "Jakarta"); err != nil {
+			t.Fatalf("GetForecast: %v", err)
+		}
+	}
+	// Each account pays for its own forecast once, and serves it only to itself
+	for _, key := range []string{"testkey", "acme-key", "globex-key"} {
+		if keys[key] != 1 {
+			t.Errorf("Expected one provider call with %s, got %v", key, keys)
+		}
+	}
+	for _, key := range []string{"forecast:Jakarta", "tenant:acme:forecast:Jakarta", "tenant:globex:forecast:Jakarta"} {
+		if !mr.Exists(key) {
+			t.Errorf("Expected the forecast cached under %s, got keys %v", key, mr.Keys())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// GeocodeRepository resolves free-text place names into candidate places.
+type GeocodeRepository interface {
+	Geocode(ctx context.Context, query string) (*model.GeocodeResponse, error)
+}
+
+// geocodeRepository shares the Redis clients, write queue, provider client and cool-down of the
+// weather repository.
+type geocodeRepository struct {
+	*weatherRepository
+}
+
+// NewGeocodeRepository creates a geocode repository. Provider calls use httpClient when given.
+func NewGeocodeRepository(httpClient ...*http.Client) GeocodeRepository {
+	return &geocodeRepository{NewWeatherRepository(httpClient...).(*weatherRepository)}
+}
+
+// geocodeKey is per tenant and limit, and case-insensitive, so "london" and "London" share an
+// entry.
+func geocodeKey(ctx context.Context, query string, limit int) string {
+	return tenantScope(ctx) + "geocode:" + strconv.Itoa(limit) + ":" + strings.ToLower(query)
+}
+
+// Geocode returns up to geocode.limit places query may refer to, best match first, from the cache
+// when it holds them, otherwise from the provider. A query matching nothing has no candidates, and
+// is cached all the same.
+func (r *geocodeRepository) Geocode(ctx context.Context, query string) (*model.GeocodeResponse, error) {
+	cfg := config.GetGeocodeConfig()
+	geocode, cached, err := cachedValue(ctx, r.weatherRepository, geocodeKey(ctx, query, cfg.Limit), cfg.CacheTTL, func() (*model.GeocodeResponse, error) {
+		return r.fetchGeocode(ctx, query, cfg.Limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	geocode.Query = query
+	geocode.Cached = cached
+	return geocode, nil
+}
+
+// fetchGeocode calls the OpenWeatherMap direct geocoding API, with the tenant's own account when
+// the request carries one.
+func (r *geocodeRepository) fetchGeocode(ctx context.Context, query string, limit int) (*model.GeocodeResponse, error) {
+	logger(ctx).Debugw("Fetching geocode from external API", "query", query)
+	apiKey := config.GetOpenWeatherMapAPIKey()
+	if p, ok := tenant.FromContext(ctx); ok {
+		apiKey = p.APIKey
+	}
+	if apiKey == "" {
+		return nil, ErrAPIKeyMissing
+	}
+
+	u := fmt.Sprintf("%s?q=%s&limit=%d&appid=%s", config.GetOpenWeatherGeocodingURL(), url.QueryEscape(query), limit, apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	default:
+		return nil, ErrExternalAPI
+	}
+	endCoolDownStreak(ctx)
+
+	var results []model.OpenWeatherMapGeocodeResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	geocode := &model.GeocodeResponse{Candidates: make([]model.GeocodeCandidate, 0, len(results))}
+	for _, res := range results {
+		geocode.Candidates = append(geocode.Candidates, model.GeocodeCandidate{
+			Name: res.Name, Country: res.Country, State: res.State, Lat: res.Lat, Lon: res.Lon,
+		})
+	}
+	return geocode, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const geocodeBody = `[
+	{"name": "Springfield", "local_names": {"en": "Springfield"}, "lat": 39.7990, "lon": -89.6440, "country": "US", "state": "Illinois"},
+	{"name": "Springfield", "lat": 37.2153, "lon": -93.2982, "country": "US", "state": "Missouri"}
+]`
+
+func TestGeocode(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, mr := newRedisClient(t)
+	calls := 0
+	repo := &geocodeRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			calls++
+			body := "[]"
+			if q := req.URL.Query(); q.Get("q") == "Springfield" && q.Get("limit") == "5" {
+				body = geocodeBody
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+		}),
+	}}
+	ctx := context.Background()
+
+	geocode, err := repo.Geocode(ctx, "Springfield")
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+	if geocode.Cached || len(geocode.Candidates) != 2 || geocode.Candidates[1].State != "Missouri" || geocode.Candidates[0].Lat != 39.799 {
+		t.Errorf("Unexpected candidates %+v", geocode)
+	}
+	if ttl := mr.TTL("geocode:5:springfield"); ttl <= 0 {
+		t.Errorf("Expected the candidates cached with a TTL, got %v", ttl)
+	}
+
+	// The cache is shared whatever the case of the query
+	geocode, err = repo.Geocode(ctx, "SPRINGFIELD")
+	if err != nil || !geocode.Cached || geocode.Query != "SPRINGFIELD" || len(geocode.Candidates) != 2 {
+		t.Errorf("Expected the cached candidates, got %+v, %v", geocode, err)
+	}
+
+	// Queries matching nothing are cached too
+	for i := 0; i < 2; i++ {
+		if geocode, err := repo.Geocode(ctx, "Xyzzy"); err != nil || len(geocode.Candidates) != 0 {
+			t.Errorf("Expected no candidates, got %+v, %v", geocode, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", calls)
+	}
+}
+
+func TestGeocode_ProviderErrors(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, _ := newRedisClient(t)
+	repo := &geocodeRepository{&weatherRepository{
+		redisClient: client,
+		httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+		}),
+	}}
+	if _, err := repo.Geocode(context.Background(), "Jakarta"); !errors.Is(err, ErrExternalAPI) {
+		t.Errorf("Expected ErrExternalAPI, got %v", err)
+	}
+
+	t.Setenv("OPENWEATHERMAP_API_KEY", "")
+	if _, err := repo.Geocode(context.Background(), "Jakarta"); !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected ErrAPIKeyMissing, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Snapshot is a location's conditions as fetched from the provider at one time.
+type Snapshot struct {
+	Weather model.WeatherResponse `json:"weather"`
+	At      time.Time             `json:"at"`
+}
+
+// HistoryStore keeps every fetched snapshot of every location, up to history.max_items per
+// location and for history.retention after its last fetch. Unlike the feed, it is not limited to
+// warm-listed cities or to material changes.
+type HistoryStore interface {
+	// Record appends a snapshot for update.
+	Record(ctx context.Context, update events.CacheUpdate) error
+	// Snapshots returns the history of the canonical location, newest first.
+	Snapshots(ctx context.Context, location string) ([]Snapshot, error)
+}
+
+// HistoryClient is the subset of Redis operations needed to keep expiring capped lists.
+type HistoryClient interface {
+	ListClient
+	Expire(ctx context.Context, key string, expiration time.Duration) *redisv9.BoolCmd
+}
+
+type historyStore struct {
+	client HistoryClient
+}
+
+// NewHistoryStore creates a HistoryStore backed by the shared Redis client.
+func NewHistoryStore(client ...HistoryClient) HistoryStore {
+	var c HistoryClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &historyStore{client: c}
+}
+
+// historyKey normalises a location so "London" and "london" share one history.
+func historyKey(location string) string {
+	return "history:" + strings.ToLower(location)
+}
+
+func (s *historyStore) Record(ctx context.Context, update events.CacheUpdate) error {
+	cfg := config.GetHistoryConfig()
+	key := historyKey(update.Location)
+	weather := update.Weather
+	weather.Cached = false
+	weather.Stale = false
+	b, err := marshalCacheValue(key, Snapshot{Weather: weather, At: update.At.UTC()})
+	if err != nil {
+		return err
+	}
+	if err := s.client.LPush(ctx, key, b).Err(); err != nil {
+		return err
+	}
+	if err := s.client.LTrim(ctx, key, 0, int64(cfg.MaxItems-1)).Err(); err != nil {
+		return err
+	}
+	return s.client.Expire(ctx, key, cfg.Retention).Err()
+}
+
+func (s *historyStore) Snapshots(ctx context.Context, location string) ([]Snapshot, error) {
+	key := historyKey(location)
+	raw, err := s.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0, len(raw))
+	for _, v := range raw {
+		var snapshot Snapshot
+		if err := unmarshalCacheValue(key, []byte(v), &snapshot); err != nil {
+			logger(ctx).Warnw("Skipping undecodable snapshot", "location", location, "error", err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// RecordHistory records every published cache update in store until unsubscribed.
+func RecordHistory(store HistoryStore) (unsubscribe func()) {
+	return events.CacheUpdates.Subscribe(func(update events.CacheUpdate) {
+		ctx := logctx.WithLocation(context.Background(), update.Location)
+		if err := store.Record(ctx, update); err != nil {
+			logger(ctx).Warnw("Failed to record snapshot", "error", err)
+		}
+	})
+}
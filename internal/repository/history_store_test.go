@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func TestHistoryStore_RecordsEverySnapshot(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	viper.Set("history.max_items", 2)
+	viper.Set("history.retention", "1h")
+	t.Cleanup(func() {
+		viper.Set("history.max_items", nil)
+		viper.Set("history.retention", nil)
+	})
+	store := NewHistoryStore(client)
+	ctx := context.Background()
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Not warm-listed, and the second snapshot is no material change: both are still kept
+	for i, temp := range []float64{10, 10.1, 10.2} {
+		if err := store.Record(ctx, feedUpdate("Oslo", temp, "clear sky", at.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	snapshots, err := store.Snapshots(ctx, "oslo")
+	if err != nil {
+		t.Fatalf("Snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Weather.Temperature != 10.2 || !snapshots[1].At.Equal(at.Add(time.Minute)) {
+		t.Errorf("Expected the 2 newest snapshots, newest first, got %+v", snapshots)
+	}
+	if ttl := mr.TTL(historyKey("Oslo")); ttl != time.Hour {
+		t.Errorf("Expected the history to expire after history.retention, got %v", ttl)
+	}
+
+	if snapshots, err := store.Snapshots(ctx, "Rome"); err != nil || len(snapshots) != 0 {
+		t.Errorf("Expected no history for Rome, got %+v, %v", snapshots, err)
+	}
+}
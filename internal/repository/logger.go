@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
+	"go.uber.org/zap"
+)
+
+// logger returns the repository module logger, whose level is set by logging.levels.repository, annotated
+// with the request fields carried by ctx. Requests with forced debug tracing log at debug level.
+func logger(ctx context.Context) *zap.SugaredLogger {
+	return logctx.Module(ctx, "repository")
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// providerMetNo names the Met.no provider in cost accounting and cool-downs.
+const providerMetNo = config.ProviderMetNo
+
+// metNoDescriptions translates Met.no symbol codes, without their _day, _night or _polartwilight
+// variant, into OpenWeatherMap's descriptions, so responses read the same whichever provider
+// served them.
+var metNoDescriptions = map[string]string{
+	"clearsky":                   "clear sky",
+	"fair":                       "few clouds",
+	"partlycloudy":               "scattered clouds",
+	"cloudy":                     "overcast clouds",
+	"fog":                        "fog",
+	"lightrain":                  "light rain",
+	"rain":                       "moderate rain",
+	"heavyrain":                  "heavy intensity rain",
+	"lightrainshowers":           "light intensity shower rain",
+	"rainshowers":                "shower rain",
+	"heavyrainshowers":           "heavy intensity shower rain",
+	"lightsleet":                 "light shower sleet",
+	"sleet":                      "sleet",
+	"heavysleet":                 "sleet",
+	"lightsleetshowers":          "light shower sleet",
+	"sleetshowers":               "shower sleet",
+	"heavysleetshowers":          "shower sleet",
+	"lightsnow":                  "light snow",
+	"snow":                       "snow",
+	"heavysnow":                  "heavy snow",
+	"lightsnowshowers":           "light shower snow",
+	"snowshowers":                "shower snow",
+	"heavysnowshowers":           "heavy shower snow",
+	"lightrainandthunder":        "thunderstorm with light rain",
+	"rainandthunder":             "thunderstorm with rain",
+	"heavyrainandthunder":        "thunderstorm with heavy rain",
+	"lightrainshowersandthunder": "thunderstorm with light rain",
+	"rainshowersandthunder":      "thunderstorm with rain",
+	"heavyrainshowersandthunder": "thunderstorm with heavy rain",
+}
+
+// metNoDescription describes a Met.no symbol code, e.g. "lightrain_night".
+func metNoDescription(symbol string) string {
+	base, _, _ := strings.Cut(symbol, "_")
+	if d, ok := metNoDescriptions[base]; ok {
+		return d
+	}
+	if strings.Contains(base, "thunder") {
+		return "thunderstorm"
+	}
+	return base
+}
+
+// metNoCondition classes a Met.no symbol code, or is "" without one.
+func metNoCondition(symbol string) model.Condition {
+	base, _, _ := strings.Cut(symbol, "_")
+	switch {
+	case base == "":
+		return ""
+	case base == "clearsky":
+		return model.ConditionClear
+	case base == "fair", base == "partlycloudy":
+		return model.ConditionPartlyCloudy
+	case base == "cloudy":
+		return model.ConditionCloudy
+	case base == "fog":
+		return model.ConditionFog
+	case strings.Contains(base, "thunder"):
+		return model.ConditionThunderstorm
+	case strings.Contains(base, "sleet"):
+		return model.ConditionSleet
+	case strings.Contains(base, "snow"):
+		return model.ConditionSnow
+	case strings.Contains(base, "rain"):
+		return model.ConditionRain
+	default:
+		return model.ConditionUnknown
+	}
+}
+
+// metNoIcon returns the OpenWeatherMap icon code that pictures a Met.no symbol code, by night for
+// its _night variant.
+func metNoIcon(symbol string) string {
+	return metNoCondition(symbol).OpenWeatherMapIcon(strings.HasSuffix(symbol, "_night"))
+}
+
+// fetchMetNo calls the Met.no Locationforecast API for loc's coordinates and reads the current
+// hour. Met.no identifies clients by User-Agent and answers If-Modified-Since with 304.
+func (r *weatherRepository) fetchMetNo(ctx context.Context, loc model.Location, previous *cacheEntry) (*upstreamResult, error) {
+	logger(ctx).Debugw("Fetching from Met.no")
+	cfg := config.GetProviderRoutingConfig()
+	// Met.no asks for at most four decimals, so nearby requests share its cache
+	u := fmt.Sprintf("%s?lat=%s&lon=%s", cfg.MetNoURL,
+		strconv.FormatFloat(loc.Coords.Lat, 'f', 4, 64), strconv.FormatFloat(loc.Coords.Lon, 'f', 4, 64))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	req.Header.Set("User-Agent", cfg.MetNoUserAgent)
+	if previous != nil && previous.LastModified != "" {
+		req.Header.Set("If-Modified-Since", previous.LastModified)
+	}
+
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	case resp.StatusCode == http.StatusNotModified && previous != nil:
+		endCoolDownStreak(ctx)
+		return &upstreamResult{NotModified: true}, nil
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNonAuthoritativeInfo:
+		// 203 marks a deprecated API version that still answers
+		return nil, ErrExternalAPI
+	}
+	endCoolDownStreak(ctx)
+
+	var data model.MetNoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if len(data.Properties.Timeseries) == 0 {
+		return nil, ErrExternalAPI
+	}
+	now := data.Properties.Timeseries[0].Data
+	details := now.Instant.Details
+	temperature, err := model.TemperatureFromNumber(details.AirTemperature, config.GetTemperaturePrecision())
+	if err != nil {
+		return nil, err
+	}
+	var symbol string
+	switch {
+	case now.Next1Hours != nil:
+		symbol = now.Next1Hours.Summary.SymbolCode
+	case now.Next6Hours != nil:
+		symbol = now.Next6Hours.Summary.SymbolCode
+	}
+
+	// Met.no knows no place names, so the location is named as asked, without its country code
+	name, _, _ := strings.Cut(loc.Name, ",")
+	return &upstreamResult{
+		Weather: &model.WeatherResponse{
+			Location:    strings.TrimSpace(name),
+			Temperature: temperature,
+			Description: metNoDescription(symbol),
+			Condition:   metNoCondition(symbol),
+			IconURL:     iconURL(metNoIcon(symbol)),
+			// Met.no has no feels-like or area temperature range
+			Humidity:      details.RelativeHumidity,
+			Pressure:      details.AirPressureAtSeaLevel,
+			WindSpeed:     details.WindSpeed,
+			WindDirection: details.WindFromDirection,
+			FetchedAt:     cacheClock.Now(),
+		},
+		LastModified: resp.Header.Get("Last-Modified"),
+		Coords:       loc.Coords,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// ErrOverrideNotFound is returned for locations without an override in force.
+var ErrOverrideNotFound = errors.New("no override for location")
+
+var overridesServed = metrics.NewCounterVec("weather_overrides_served_total",
+	"Weather responses served from an operator override instead of the cache or provider, by location.", "location")
+
+// Override is weather pinned by an operator for a location until ExpiresAt, served instead of the
+// cache and the provider.
+type Override struct {
+	Location  string                `json:"location"`
+	Weather   model.WeatherResponse `json:"weather"`
+	ExpiresAt time.Time             `json:"expires_at"`
+	Reason    string                `json:"reason,omitempty"`
+	// SetBy is the name of the API key that set the override.
+	SetBy string    `json:"set_by,omitempty"`
+	SetAt time.Time `json:"set_at"`
+	// Revision identifies the stored version, as read; it is served as the ETag.
+	Revision string `json:"-"`
+}
+
+// OverrideStore keeps overrides in Redis, shared by every replica.
+//
+// Overrides read from the store carry their Revision. Set given o.Revision only replaces that
+// revision, or with RevisionNone only creates, and Delete given a revision only deletes it;
+// otherwise they return ErrRevisionMismatch, or ErrOverrideNotFound when there is no override.
+type OverrideStore interface {
+	// Set stores o, setting o.Revision to the stored revision.
+	Set(ctx context.Context, o *Override) error
+	// Get returns the override in force for location.
+	Get(ctx context.Context, location string) (*Override, error)
+	Delete(ctx context.Context, location, revision string) error
+	// List returns every override in force.
+	List(ctx context.Context) ([]*Override, error)
+}
+
+// OverrideClient is the subset of Redis operations needed to keep overrides.
+type OverrideClient interface {
+	Get(ctx context.Context, key string) *redisv9.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd
+	Del(ctx context.Context, keys ...string) *redisv9.IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redisv9.ScanCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redisv9.Cmd
+}
+
+type overrideStore struct {
+	client OverrideClient
+}
+
+// NewOverrideStore creates an OverrideStore backed by the shared Redis client.
+func NewOverrideStore(client ...OverrideClient) OverrideStore {
+	var c OverrideClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &overrideStore{client: c}
+}
+
+func overrideKey(location string) string {
+	return "override:" + location
+}
+
+// Set stores o until it expires, and applies it on this replica at once; other replicas pick it
+// up on their next sync.
+func (s *overrideStore) Set(ctx context.Context, o *Override) error {
+	ttl := time.Until(o.ExpiresAt)
+	if ttl <= 0 {
+		return errors.New("override already expired")
+	}
+	key := overrideKey(o.Location)
+	b, err := marshalCacheValue(key, o)
+	if err != nil {
+		return err
+	}
+	if o.Revision != "" {
+		n, err := s.client.Eval(ctx, setIfScript, []string{key}, o.Revision, b, ttl.Milliseconds()).Int()
+		if err != nil {
+			return err
+		}
+		if err := conditionalResult(n, ErrOverrideNotFound); err != nil {
+			return err
+		}
+	} else if err := s.client.Set(ctx, key, b, ttl).Err(); err != nil {
+		return err
+	}
+	o.Revision = revision(b)
+	setLocalOverride(o)
+	return nil
+}
+
+func (s *overrideStore) Get(ctx context.Context, location string) (*Override, error) {
+	key := overrideKey(location)
+	val, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redisv9.Nil) {
+		return nil, ErrOverrideNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var o Override
+	if err := unmarshalCacheValue(key, val, &o); err != nil {
+		return nil, err
+	}
+	o.Revision = revision(val)
+	return &o, nil
+}
+
+// Delete lifts the override of location, on this replica at once.
+func (s *overrideStore) Delete(ctx context.Context, location, rev string) error {
+	if rev != "" {
+		n, err := s.client.Eval(ctx, delIfScript, []string{overrideKey(location)}, rev).Int()
+		if err != nil {
+			return err
+		}
+		if err := conditionalResult(n, ErrOverrideNotFound); err != nil {
+			return err
+		}
+		deleteLocalOverride(location)
+		return nil
+	}
+	n, err := s.client.Del(ctx, overrideKey(location)).Result()
+	if err != nil {
+		return err
+	}
+	deleteLocalOverride(location)
+	if n == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
+
+func (s *overrideStore) List(ctx context.Context) ([]*Override, error) {
+	var out []*Override
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, "override:*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			o, err := s.Get(ctx, strings.TrimPrefix(key, "override:"))
+			if errors.Is(err, ErrOverrideNotFound) {
+				continue // expired between SCAN and GET
+			} else if err != nil {
+				logger(ctx).Warnw("Skipping unreadable override", "key", key, "error", err)
+				continue
+			}
+			out = append(out, o)
+		}
+		if next == 0 {
+			return out, nil
+		}
+		cursor = next
+	}
+}
+
+// localOverrides is this replica's copy of the overrides in Redis, so weather requests check
+// for one without a Redis round trip.
+var (
+	localOverridesMu sync.RWMutex
+	localOverrides   = map[string]*Override{}
+)
+
+func setLocalOverride(o *Override) {
+	localOverridesMu.Lock()
+	localOverrides[o.Location] = o
+	localOverridesMu.Unlock()
+}
+
+func deleteLocalOverride(location string) {
+	localOverridesMu.Lock()
+	delete(localOverrides, location)
+	localOverridesMu.Unlock()
+}
+
+// overrideFor returns the weather pinned for location, if an override is in force.
+func overrideFor(location string) (*model.WeatherResponse, bool) {
+	localOverridesMu.RLock()
+	o, ok := localOverrides[location]
+	localOverridesMu.RUnlock()
+	if !ok || !time.Now().Before(o.ExpiresAt) {
+		return nil, false
+	}
+	overridesServed.WithLabelValues(location).Inc()
+	weather := o.Weather
+	weather.Location = location
+	weather.Cached = true
+	weather.Stale = false
+	return &weather, true
+}
+
+// StartOverrideSync schedules the "override_sync" job, which reloads this replica's overrides
+// from Redis every overrides.sync_interval, until the returned function is called.
+func StartOverrideSync() (stop func()) {
+	store := NewOverrideStore()
+	return jobs.New("override_sync", config.GetOverrideConfig().SyncInterval, func(ctx context.Context) error {
+		overrides, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+		loaded := make(map[string]*Override, len(overrides))
+		for _, o := range overrides {
+			loaded[o.Location] = o
+		}
+		localOverridesMu.Lock()
+		localOverrides = loaded
+		localOverridesMu.Unlock()
+		return nil
+	}).Schedule(true)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+func TestOverrideStore(t *testing.T) {
+	client, mr := newRedisClient(t)
+	store := NewOverrideStore(client)
+	ctx := context.Background()
+	t.Cleanup(func() { deleteLocalOverride("Jakarta") })
+
+	o := &Override{
+		Location:  "Jakarta",
+		Weather:   model.WeatherResponse{Temperature: 30, Description: "clear sky"},
+		ExpiresAt: time.Now().Add(time.Hour),
+		SetBy:     "ops",
+	}
+	if err := store.Set(ctx, o); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := mr.TTL(overrideKey("Jakarta")); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected the key to expire with the override, got %v", ttl)
+	}
+	got, err := store.Get(ctx, "Jakarta")
+	if err != nil || got.Weather.Description != "clear sky" || got.SetBy != "ops" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if list, err := store.List(ctx); err != nil || len(list) != 1 {
+		t.Errorf("List = %v, %v", list, err)
+	}
+
+	weather, ok := overrideFor("Jakarta")
+	if !ok || weather.Location != "Jakarta" || weather.Temperature != 30 {
+		t.Errorf("Expected the override to be served locally, got %+v", weather)
+	}
+
+	if err := store.Delete(ctx, "Jakarta", ""); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := overrideFor("Jakarta"); ok {
+		t.Error("Expected the lifted override not to be served")
+	}
+	if err := store.Delete(ctx, "Jakarta", ""); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("Expected ErrOverrideNotFound, got %v", err)
+	}
+	if err := store.Set(ctx, &Override{Location: "Jakarta", ExpiresAt: time.Now().Add(-time.Second)}); err == nil {
+		t.Error("Expected an expired override to be rejected")
+	}
+}
+
+func TestOverrideStore_Revisions(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewOverrideStore(client)
+	ctx := context.Background()
+	t.Cleanup(func() { deleteLocalOverride("Jakarta") })
+
+	o := &Override{Location: "Jakarta", Weather: model.WeatherResponse{Temperature: 30}, ExpiresAt: time.Now().Add(time.Hour), Revision: RevisionNone}
+	if err := store.Set(ctx, o); err != nil || o.Revision == RevisionNone {
+		t.Fatalf("Set: %v, revision %q", err, o.Revision)
+	}
+	created := o.Revision
+	if err := store.Set(ctx, &Override{Location: "Jakarta", ExpiresAt: time.Now().Add(time.Hour), Revision: RevisionNone}); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch creating an existing override, got %v", err)
+	}
+	if got, _ := store.Get(ctx, "Jakarta"); got.Revision != created {
+		t.Errorf("Expected Get to report revision %q, got %q", created, got.Revision)
+	}
+
+	o.Weather.Temperature = 31
+	if err := store.Set(ctx, o); err != nil || o.Revision == created {
+		t.Fatalf("Set at the current revision: %v, revision %q", err, o.Revision)
+	}
+	if err := store.Set(ctx, &Override{Location: "Jakarta", ExpiresAt: time.Now().Add(time.Hour), Revision: created}); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch replacing a stale revision, got %v", err)
+	}
+	if err := store.Delete(ctx, "Jakarta", created); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch deleting a stale revision, got %v", err)
+	}
+	if err := store.Delete(ctx, "Jakarta", o.Revision); err != nil {
+		t.Fatalf("Delete at the current revision: %v", err)
+	}
+	if err := store.Set(ctx, &Override{Location: "Jakarta", ExpiresAt: time.Now().Add(time.Hour), Revision: o.Revision}); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("Expected ErrOverrideNotFound replacing a lifted override, got %v", err)
+	}
+}
+
+func TestOverrideFor_Expired(t *testing.T) {
+	setLocalOverride(&Override{Location: "Oslo", ExpiresAt: time.Now().Add(-time.Second)})
+	t.Cleanup(func() { deleteLocalOverride("Oslo") })
+	if _, ok := overrideFor("Oslo"); ok {
+		t.Error("Expected an expired override not to be served")
+	}
+}
+
+func TestGetWeather_Override(t *testing.T) {
+	setLocalOverride(&Override{
+		Location:  "Reykjavik",
+		Weather:   model.WeatherResponse{Temperature: -2, Description: "snow"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	t.Cleanup(func() { deleteLocalOverride("Reykjavik") })
+
+	repo := &weatherRepository{
+		redisClient: &mockRedisClient{
+			getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+				t.Errorf("Expected no cache lookup, got Get(%q)", key)
+				return nil
+			},
+		},
+	}
+	for _, ctx := range []context.Context{context.Background(), WithCachePolicy(context.Background(), CachePolicyRefresh)} {
+		weather, err := repo.GetWeather(ctx, "Reykjavik")
+		if err != nil || weather.Description != "snow" || weather.Location != "Reykjavik" {
+			t.Errorf("Expected the override, got %+v, %v", weather, err)
+		}
+	}
+}
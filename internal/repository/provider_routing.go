@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+type routedProviderKey struct{}
+
+// routedProvider returns the provider providers.routing chose for a request, or OpenWeatherMap.
+func routedProvider(ctx context.Context) string {
+	if provider, ok := ctx.Value(routedProviderKey{}).(string); ok {
+		return provider
+	}
+	return providerOpenWeatherMap
+}
+
+// route picks the provider of loc from providers.routing by its country, and returns ctx carrying
+// it with the location to ask that provider for. Met.no is asked by coordinates, taken from the
+// city dataset or else geocoded; a location without any stays with OpenWeatherMap, as do requests
+// on a tenant's account or in an experiment, which have an upstream of their own.
+func (r *weatherRepository) route(ctx context.Context, loc model.Location) (context.Context, model.Location) {
+	if _, _, scope := upstreamFor(ctx); scope != "" || loc.Coords != nil {
+		return ctx, loc
+	}
+	routes := config.GetProviderRoutingConfig().Routes
+	if len(routes) == 0 {
+		return ctx, loc
+	}
+	city, known := geodata.Lookup(loc.Name)
+	country := locationCountry(loc.Name)
+	if country == "" && known {
+		country = city.Country
+	}
+	var candidate *model.GeocodeCandidate
+	if country == "" || (routes[country] == config.ProviderMetNo && !known) {
+		candidate = r.geocodeMatch(ctx, loc.Name, country)
+		if country == "" && candidate != nil {
+			country = strings.ToUpper(candidate.Country)
+		}
+	}
+
+	switch routes[country] {
+	case config.ProviderMetNo:
+		coords := &model.Coordinates{Lat: city.Lat, Lon: city.Lon}
+		if !known {
+			if candidate == nil {
+				logger(ctx).Debugw("No coordinates to route to Met.no, using OpenWeatherMap", "country", country)
+				return ctx, loc
+			}
+			coords = &model.Coordinates{Lat: candidate.Lat, Lon: candidate.Lon}
+		}
+		logger(ctx).Debugw("Routed to Met.no", "country", country)
+		return context.WithValue(ctx, routedProviderKey{}, providerMetNo), model.Location{Name: loc.Name, Coords: coords}
+	default:
+		return ctx, loc
+	}
+}
+
+// locationCountry returns the country code of "city,CC" or "city,state,CC", upper-cased.
+func locationCountry(location string) string {
+	i := strings.LastIndex(location, ",")
+	if i < 0 {
+		return ""
+	}
+	if country := strings.TrimSpace(location[i+1:]); len(country) == 2 {
+		return strings.ToUpper(country)
+	}
+	return ""
+}
+
+// geocodeMatch returns the best geocoding match for name in country, or in any country when
+// country is empty, or nil when geocoding fails or finds none. Matches are cached by the geocode
+// repository, so routing costs a provider call only for places new to the cache.
+func (r *weatherRepository) geocodeMatch(ctx context.Context, name, country string) *model.GeocodeCandidate {
+	geocode, err := (&geocodeRepository{r}).Geocode(ctx, name)
+	if err != nil {
+		logger(ctx).Debugw("Geocoding for provider routing failed", "error", err)
+		return nil
+	}
+	for i, c := range geocode.Candidates {
+		if country == "" || strings.EqualFold(c.Country, country) {
+			return &geocode.Candidates[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/spf13/viper"
+)
+
+const metNoBody = `{"properties":{"timeseries":[
+  {"time":"2025-01-01T12:00:00Z","data":{"instant":{"details":{"air_temperature":-3.4,"air_pressure_at_sea_level":1012.3,"relative_humidity":81.5,"wind_speed":4.2,"wind_from_direction":205.1}},"next_1_hours":{"summary":{"symbol_code":"lightsnowshowers_day"}}}},
+  {"time":"2025-01-01T13:00:00Z","data":{"instant":{"details":{"air_temperature":-3.9}},"next_1_hours":{"summary":{"symbol_code":"cloudy"}}}}
+]}}`
+
+// routingProvider answers OpenWeatherMap weather and geocoding calls and Met.no calls, recording
+// each request.
+type routingProvider struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (p *routingProvider) roundTrip(req *http.Request) *http.Response {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+	body := `{"name":"OWM","main":{"temp":20},"weather":[{"description":"clear sky"}]}`
+	header := make(http.Header)
+	switch req.URL.Host {
+	case "metno.test":
+		body = metNoBody
+		header.Set("Last-Modified", "Wed, 01 Jan 2025 12:00:00 GMT")
+	case "geo.test":
+		body = `[{"name":"Tromsø","country":"NO","lat":69.6489,"lon":18.9551},{"name":"Tromsø","country":"US","lat":1,"lon":2}]`
+		if req.URL.Query().Get("q") == "Nowhere" {
+			body = "[]"
+		}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: header}
+}
+
+func (p *routingProvider) last() *http.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+func newRoutingRepository(t *testing.T) (*weatherRepository, *routingProvider) {
+	t.Helper()
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	viper.Set("openweathermap.api_url", "http://owm.test/weather")
+	viper.Set("openweathermap.geocoding_url", "http://geo.test/direct")
+	viper.Set("providers.metno.url", "http://metno.test/compact")
+	viper.Set("providers.metno.user_agent", "test-agent contact@example.com")
+	viper.Set("providers.routing", map[string]any{"NO": "metno", "SE": "metno"})
+	t.Cleanup(func() {
+		for _, key := range []string{"openweathermap.api_url", "openweathermap.geocoding_url", "providers.metno.url", "providers.metno.user_agent", "providers.routing"} {
+			viper.Set(key, nil)
+		}
+	})
+	client, _ := newRedisClient(t)
+	provider := &routingProvider{}
+	return &weatherRepository{redisClient: client, httpClient: newMockHTTPClient(provider.roundTrip)}, provider
+}
+
+func TestGetWeather_ProviderRouting(t *testing.T) {
+	repo, provider := newRoutingRepository(t)
+	ctx := context.Background()
+
+	// A dataset city in a routed country is asked by its coordinates
+	weather, err := repo.GetWeather(ctx, "Oslo")
+	if err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	req := provider.last()
+	if req.URL.Host != "metno.test" || req.URL.RawQuery != "lat=59.9127&lon=10.7461" || req.Header.Get("User-Agent") != "test-agent contact@example.com" {
+		t.Errorf("Expected a Met.no call for Oslo's coordinates, got %s %q", req.URL, req.Header.Get("User-Agent"))
+	}
+	if weather.Location != "Oslo" || weather.Temperature != -3.4 || weather.Description != "light shower snow" {
+		t.Errorf("Unexpected Met.no weather %+v", weather)
+	}
+	if weather.Condition != model.ConditionSnow || weather.IconURL != "https://openweathermap.org/img/wn/13d@2x.png" {
+		t.Errorf("Expected a snow condition and icon, got %q %q", weather.Condition, weather.IconURL)
+	}
+	if weather.Humidity == nil || *weather.Humidity != 81.5 || weather.Pressure == nil || *weather.Pressure != 1012.3 ||
+		weather.WindSpeed == nil || *weather.WindSpeed != 4.2 || weather.WindDirection == nil || *weather.WindDirection != 205.1 || weather.FeelsLike != nil {
+		t.Errorf("Unexpected Met.no details %+v", weather)
+	}
+	entry, err := repo.readEntry(ctx, "Oslo")
+	if err != nil || entry.Provider != providerMetNo || entry.LastModified == "" {
+		t.Errorf("Expected the entry cached as Met.no's with its validator, got %+v, %v", entry, err)
+	}
+
+	// Unknown places in a routed country are geocoded, in that country
+	if weather, err := repo.GetWeather(ctx, "Tromsø,NO"); err != nil || weather.Location != "Tromsø" {
+		t.Errorf("Expected Met.no weather for Tromsø, got %+v, %v", weather, err)
+	}
+	if req := provider.last(); req.URL.Host != "metno.test" || req.URL.RawQuery != "lat=69.6489&lon=18.9551" {
+		t.Errorf("Expected a Met.no call for the geocoded coordinates, got %s", req.URL)
+	}
+
+	// Other countries, places that cannot be placed, tenants and coordinates stay with OpenWeatherMap
+	for name, call := range map[string]func() error{
+		"Jakarta": func() error { _, err := repo.GetWeather(ctx, "Jakarta"); return err },
+		"Nowhere": func() error { _, err := repo.GetWeather(ctx, "Nowhere"); return err },
+		"tenant": func() error {
+			_, err := repo.GetWeather(tenant.WithProvider(ctx, tenant.Provider{Tenant: "acme", Name: "openweathermap", APIKey: "k"}), "Stockholm")
+			return err
+		},
+	} {
+		if err := call(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if req := provider.last(); req.URL.Host != "owm.test" {
+			t.Errorf("%s: expected an OpenWeatherMap call, got %s", name, req.URL)
+		}
+	}
+}
+
+func TestMetNoDescription(t *testing.T) {
+	for symbol, want := range map[string]string{
+		"clearsky_night":             "clear sky",
+		"heavyrain":                  "heavy intensity rain",
+		"lightrainandthunder":        "thunderstorm with light rain",
+		"heavysleetandthunder":       "thunderstorm",
+		"partlycloudy_polartwilight": "scattered clouds",
+		"":                           "",
+	} {
+		if got := metNoDescription(symbol); got != want {
+			t.Errorf("Expected %q to be described as %q, got %q", symbol, want, got)
+		}
+	}
+}
+
+func TestMetNoCondition(t *testing.T) {
+	for symbol, want := range map[string]struct {
+		condition model.Condition
+		icon      string
+	}{
+		"clearsky_night":             {model.ConditionClear, "01n"},
+		"fair_day":                   {model.ConditionPartlyCloudy, "02d"},
+		"heavyrainshowers_day":       {model.ConditionRain, "10d"},
+		"heavysleetandthunder":       {model.ConditionThunderstorm, "11d"},
+		"lightsleet":                 {model.ConditionSleet, "13d"},
+		"fog":                        {model.ConditionFog, "50d"},
+		"partlycloudy_polartwilight": {model.ConditionPartlyCloudy, "02d"},
+		"sandstorm":                  {model.ConditionUnknown, ""},
+		"":                           {"", ""},
+	} {
+		if got := metNoCondition(symbol); got != want.condition {
+			t.Errorf("Expected %q to be %q, got %q", symbol, want.condition, got)
+		}
+		if got := metNoIcon(symbol); got != want.icon {
+			t.Errorf("Expected %q to have icon %q, got %q", symbol, want.icon, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var (
+	providerResolutions = metrics.NewCounterVec("weather_provider_resolutions_total",
+		"Resolutions of the provider host by source (system, dns or static) and outcome (ok or error).", "source", "outcome")
+	providerResolutionDuration = metrics.NewHistogram("weather_provider_resolution_duration_seconds",
+		"Latency of DNS lookups of the provider host.")
+)
+
+// Resolution sources, used as metrics labels.
+const (
+	resolveSystem = "system"
+	resolveDNS    = "dns"
+	resolveStatic = "static"
+)
+
+// sharedProviderClient is the HTTP client every repository calls providers with, so that they
+// share its idle connections, including those opened by WarmUpProviders.
+var sharedProviderClient = sync.OnceValue(providerClient)
+
+// providerClient returns the HTTP client for provider calls: the default client, or one whose
+// transport resolves hosts as openweathermap.resolve says.
+func providerClient() *http.Client {
+	cfg := config.GetProviderResolveConfig()
+	if !cfg.Configured() {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = newProviderDialer(cfg).DialContext
+	return &http.Client{Transport: transport}
+}
+
+// providerDialer dials hosts by the addresses it resolves itself, so TLS still verifies the
+// certificate against the hostname.
+type providerDialer struct {
+	cfg    config.ProviderResolveConfig
+	dialer *net.Dialer
+	// lookup resolves a host with DNS; replaced in tests.
+	lookup func(ctx context.Context, host string) ([]string, error)
+	source string
+}
+
+func newProviderDialer(cfg config.ProviderResolveConfig) *providerDialer {
+	d := &providerDialer{
+		cfg:    cfg,
+		dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		lookup: net.DefaultResolver.LookupHost,
+		source: resolveSystem,
+	}
+	if cfg.DNSServer != "" {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return d.dialer.DialContext(ctx, network, cfg.DNSServer)
+			},
+		}
+		d.lookup = resolver.LookupHost
+		d.source = resolveDNS
+	}
+	return d
+}
+
+// DialContext resolves the host of addr and connects to its addresses in turn.
+func (d *providerDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, ip := range ips {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// resolve returns the addresses of host: the static ones when pinned, otherwise the DNS answer,
+// falling back to the static ones when DNS fails.
+func (d *providerDialer) resolve(ctx context.Context, host string) ([]string, error) {
+	static := d.cfg.Static[strings.ToLower(host)]
+	if d.cfg.Pin && len(static) > 0 {
+		providerResolutions.WithLabelValues(resolveStatic, "ok").Inc()
+		return static, nil
+	}
+
+	start := time.Now()
+	ips, err := d.lookup(ctx, host)
+	providerResolutionDuration.Observe(time.Since(start).Seconds())
+	if err == nil {
+		providerResolutions.WithLabelValues(d.source, "ok").Inc()
+		return ips, nil
+	}
+	providerResolutions.WithLabelValues(d.source, "error").Inc()
+	if len(static) == 0 {
+		return nil, err
+	}
+	logger(ctx).Warnw("Provider host lookup failed, using static addresses", "host", host, "error", err)
+	providerResolutions.WithLabelValues(resolveStatic, "ok").Inc()
+	return static, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestProviderDialer_Resolve(t *testing.T) {
+	lookups := 0
+	d := newProviderDialer(config.ProviderResolveConfig{Static: map[string][]string{"api.example.com": {"203.0.113.10"}}})
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		if strings.EqualFold(host, "api.example.com") {
+			return nil, errors.New("no such host")
+		}
+		return []string{"198.51.100.1"}, nil
+	}
+
+	ips, err := d.resolve(context.Background(), "other.example.com")
+	if err != nil || len(ips) != 1 || ips[0] != "198.51.100.1" {
+		t.Errorf("Expected the DNS answer, got %v, %v", ips, err)
+	}
+
+	fallbacks := providerResolutions.WithLabelValues(resolveStatic, "ok").Value()
+	ips, err = d.resolve(context.Background(), "API.example.com")
+	if err != nil || len(ips) != 1 || ips[0] != "203.0.113.10" {
+		t.Errorf("Expected the static fallback, got %v, %v", ips, err)
+	}
+	if providerResolutions.WithLabelValues(resolveStatic, "ok").Value() != fallbacks+1 {
+		t.Error("Expected the fallback to be counted")
+	}
+
+	d.cfg.Pin = true
+	lookups = 0
+	if _, err := d.resolve(context.Background(), "api.example.com"); err != nil || lookups != 0 {
+		t.Errorf("Expected pinned addresses without a lookup, got %v after %d lookups", err, lookups)
+	}
+
+	if _, err := d.resolve(context.Background(), "missing.example.com"); err != nil {
+		t.Errorf("Expected hosts without static addresses to use DNS, got %v", err)
+	}
+}
+
+func TestProviderClient_PinnedHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	d := newProviderDialer(config.ProviderResolveConfig{Static: map[string][]string{"api.weather.invalid": {"127.0.0.1"}}, Pin: true})
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = d.DialContext
+	resp, err := (&http.Client{Transport: transport}).Get("http://api.weather.invalid:" + port + "/")
+	if err != nil {
+		t.Fatalf("Expected the pinned address to be dialled, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "api.weather.invalid:"+port {
+		t.Errorf("Expected the request to keep its host, got %q", body)
+	}
+}
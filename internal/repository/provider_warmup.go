@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+// WarmUpProviders connects to the hosts current weather is fetched from, TLS handshake included,
+// and leaves the connections idle in the provider client's pool: OpenWeatherMap's and, when
+// providers.routing sends countries to it, Met.no's. It asks each for its root with HEAD, which
+// no provider bills.
+func WarmUpProviders(ctx context.Context) error {
+	urls := []string{config.GetOpenWeatherApiUrl()}
+	routing := config.GetProviderRoutingConfig()
+	if slices.Contains(slices.Collect(maps.Values(routing.Routes)), config.ProviderMetNo) {
+		urls = append(urls, routing.MetNoURL)
+	}
+	return warmUpHosts(ctx, sharedProviderClient(), urls, routing.MetNoUserAgent)
+}
+
+// warmUpHosts sends one HEAD request to the root of each distinct host in urls. Any answer will
+// do; only failing to connect is an error.
+func warmUpHosts(ctx context.Context, client *http.Client, urls []string, userAgent string) error {
+	seen := make(map[string]bool)
+	var errs []error
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		root := u.Scheme + "://" + u.Host + "/"
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, root, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Drained and closed, the connection goes back to the pool
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	return errors.Join(errs...)
+}
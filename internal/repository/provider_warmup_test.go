@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWarmUpHosts(t *testing.T) {
+	var conns, heads atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && r.URL.Path == "/" {
+			heads.Add(1)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+	client := server.Client()
+
+	urls := []string{server.URL + "/data/2.5/weather", server.URL + "/data/2.5/forecast", "", "::"}
+	if err := warmUpHosts(context.Background(), client, urls, "test"); err != nil {
+		t.Fatalf("Expected an answer of any status to do, got %v", err)
+	}
+	if heads.Load() != 1 {
+		t.Errorf("Expected one HEAD per host, got %d", heads.Load())
+	}
+
+	resp, err := client.Get(server.URL + "/data/2.5/weather")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = resp.Body.Close()
+	if conns.Load() != 1 {
+		t.Errorf("Expected the first request to reuse the warm connection, got %d connections", conns.Load())
+	}
+
+	if err := warmUpHosts(context.Background(), client, []string{"https://127.0.0.1:1/"}, "test"); err == nil {
+		t.Error("Expected an unreachable host reported")
+	}
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrRevisionMismatch is returned by conditional writes when the stored resource is not the
+// revision the write was made against: it changed, or was created or removed, in the meantime.
+var ErrRevisionMismatch = errors.New("resource changed since it was read")
+
+// RevisionNone, as the revision of a conditional write, requires that nothing is stored yet.
+const RevisionNone = "none"
+
+// Conditional writes compare revisions inside Redis, so that no other write can land between
+// the check and the write. A revision is the SHA-1 of the stored value, which Redis scripts can
+// compute with redis.sha1hex. The scripts return 1 when written, 0 on a revision mismatch and -1
+// when nothing is stored.
+const (
+	// hashSetIfScript sets field ARGV[1] of hash KEYS[1] to ARGV[3] if it holds revision ARGV[2].
+	hashSetIfScript = `local cur = redis.call("HGET", KEYS[1], ARGV[1])
+if not cur then return -1 end
+if redis.sha1hex(cur) ~= ARGV[2] then return 0 end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+return 1`
+	// hashDelIfScript deletes field ARGV[1] of hash KEYS[1] if it holds revision ARGV[2].
+	hashDelIfScript = `local cur = redis.call("HGET", KEYS[1], ARGV[1])
+if not cur then return -1 end
+if redis.sha1hex(cur) ~= ARGV[2] then return 0 end
+redis.call("HDEL", KEYS[1], ARGV[1])
+return 1`
+	// setIfScript sets KEYS[1] to ARGV[2] for ARGV[3] milliseconds if it holds revision ARGV[1],
+	// or, for RevisionNone, if it does not exist.
+	setIfScript = `local cur = redis.call("GET", KEYS[1])
+if ARGV[1] == "none" then
+  if cur then return 0 end
+elseif not cur then return -1
+elseif redis.sha1hex(cur) ~= ARGV[1] then return 0 end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1`
+	// delIfScript deletes KEYS[1] if it holds revision ARGV[1].
+	delIfScript = `local cur = redis.call("GET", KEYS[1])
+if not cur then return -1 end
+if redis.sha1hex(cur) ~= ARGV[1] then return 0 end
+redis.call("DEL", KEYS[1])
+return 1`
+)
+
+// revision identifies a stored value. Sealed values get a fresh nonce on every write, so with
+// cache encryption on even an unchanged rewrite is a new revision.
+func revision(stored []byte) string {
+	sum := sha1.Sum(stored)
+	return hex.EncodeToString(sum[:])
+}
+
+// conditionalResult maps the result of a conditional write script to an error, with notFound for
+// a resource that is not stored.
+func conditionalResult(n int, notFound error) error {
+	switch n {
+	case 1:
+		return nil
+	case -1:
+		return notFound
+	default:
+		return ErrRevisionMismatch
+	}
+}
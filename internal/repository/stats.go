@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+)
+
+var (
+	cacheLookups = metrics.NewCounterVec("weather_cache_lookups_total",
+		"Cache lookups for requests that may fall through to the provider, by result.", "result")
+	upstreamRequests = metrics.NewCounterVec("weather_upstream_requests_total",
+		"Calls to the weather provider by outcome.", "outcome")
+	upstreamDuration = metrics.NewHistogram("weather_upstream_request_duration_seconds",
+		"Latency of calls to the weather provider.")
+)
+
+// Cache lookup results and upstream outcomes, used as metrics labels.
+const (
+	lookupHit   = "hit"
+	lookupStale = "stale"
+	lookupMiss  = "miss"
+
+	upstreamOK          = "ok"
+	upstreamNotModified = "not_modified"
+	upstreamNotFound    = "not_found"
+	upstreamRateLimited = "rate_limited"
+	upstreamError       = "error"
+)
+
+// CacheStats counts cache lookups since the process started.
+type CacheStats struct {
+	Hits   float64
+	Stale  float64
+	Misses float64
+}
+
+// HitRate returns the share of lookups served from a fresh entry, or 0 before the first lookup.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Stale + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return s.Hits / total
+}
+
+// GetCacheStats returns the cache lookup counts.
+func GetCacheStats() CacheStats {
+	return CacheStats{
+		Hits:   cacheLookups.WithLabelValues(lookupHit).Value(),
+		Stale:  cacheLookups.WithLabelValues(lookupStale).Value(),
+		Misses: cacheLookups.WithLabelValues(lookupMiss).Value(),
+	}
+}
+
+// ProviderHealth summarises recent calls to the weather provider. Unknown locations count as
+// successful calls, since the provider answered.
+type ProviderHealth struct {
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+	ConsecutiveFailures int
+}
+
+// Healthy reports whether the latest provider call succeeded (or none has been made yet).
+func (h ProviderHealth) Healthy() bool {
+	return h.ConsecutiveFailures == 0
+}
+
+var (
+	providerHealthMu sync.Mutex
+	providerHealth   ProviderHealth
+)
+
+// GetProviderHealth returns the provider health since the process started.
+func GetProviderHealth() ProviderHealth {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+	return providerHealth
+}
+
+// UpstreamSample is a cumulative snapshot of provider calls, read from the upstream metrics.
+// Differences between two samples describe the calls made in between.
+type UpstreamSample struct {
+	Requests float64
+	Errors   float64
+	// LatencySeconds is the total time spent in provider calls.
+	LatencySeconds float64
+}
+
+// GetUpstreamSample returns the provider call totals since the process started.
+func GetUpstreamSample() UpstreamSample {
+	count, sum := upstreamDuration.Snapshot()
+	return UpstreamSample{
+		Requests:       float64(count),
+		Errors:         upstreamRequests.WithLabelValues(upstreamError).Value(),
+		LatencySeconds: sum,
+	}
+}
+
+// recordUpstream updates the provider metrics and health after a fetchUpstream call.
+func recordUpstream(result *upstreamResult, err error, elapsed time.Duration) {
+	var notFound *LocationNotFoundError
+	outcome := upstreamOK
+	switch {
+	case errors.As(err, &notFound):
+		outcome = upstreamNotFound
+	case errors.Is(err, ErrProviderRateLimited):
+		outcome = upstreamRateLimited
+	case err != nil:
+		outcome = upstreamError
+	case result.NotModified:
+		outcome = upstreamNotModified
+	}
+	upstreamRequests.WithLabelValues(outcome).Inc()
+	upstreamDuration.Observe(elapsed.Seconds())
+
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+	if outcome == upstreamError || outcome == upstreamRateLimited {
+		providerHealth.LastFailure = time.Now()
+		providerHealth.LastError = err.Error()
+		providerHealth.ConsecutiveFailures++
+		return
+	}
+	providerHealth.LastSuccess = time.Now()
+	providerHealth.ConsecutiveFailures = 0
+}
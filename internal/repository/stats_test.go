@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheStats_HitRate(t *testing.T) {
+	if rate := (CacheStats{}).HitRate(); rate != 0 {
+		t.Errorf("Expected 0 before any lookup, got %v", rate)
+	}
+	if rate := (CacheStats{Hits: 3, Stale: 1}).HitRate(); rate != 0.75 {
+		t.Errorf("Expected 0.75, got %v", rate)
+	}
+}
+
+func TestGetCacheStats(t *testing.T) {
+	before := GetCacheStats()
+	cacheLookups.WithLabelValues(lookupHit).Inc()
+	cacheLookups.WithLabelValues(lookupMiss).Inc()
+	after := GetCacheStats()
+	if after.Hits != before.Hits+1 || after.Misses != before.Misses+1 || after.Stale != before.Stale {
+		t.Errorf("Unexpected stats change from %+v to %+v", before, after)
+	}
+}
+
+func TestRecordUpstream(t *testing.T) {
+	providerHealthMu.Lock()
+	saved := providerHealth
+	providerHealth = ProviderHealth{}
+	providerHealthMu.Unlock()
+	defer func() {
+		providerHealthMu.Lock()
+		providerHealth = saved
+		providerHealthMu.Unlock()
+	}()
+
+	errors := upstreamRequests.WithLabelValues(upstreamError).Value()
+	before := GetUpstreamSample()
+	recordUpstream(nil, ErrExternalAPI, time.Second)
+	recordUpstream(nil, ErrExternalAPI, time.Second)
+	h := GetProviderHealth()
+	if h.Healthy() || h.ConsecutiveFailures != 2 || h.LastError != ErrExternalAPI.Error() {
+		t.Errorf("Expected two consecutive failures, got %+v", h)
+	}
+	if got := upstreamRequests.WithLabelValues(upstreamError).Value(); got != errors+2 {
+		t.Errorf("Expected error counter to grow by 2, got %v -> %v", errors, got)
+	}
+
+	// The provider answering "city not found" is a healthy provider
+	recordUpstream(nil, &LocationNotFoundError{Message: "city not found"}, time.Millisecond)
+	h = GetProviderHealth()
+	if !h.Healthy() || h.LastSuccess.IsZero() || h.LastFailure.IsZero() {
+		t.Errorf("Expected recovery after a provider answer, got %+v", h)
+	}
+
+	recordUpstream(&upstreamResult{NotModified: true}, nil, time.Millisecond)
+	if !GetProviderHealth().Healthy() {
+		t.Error("Expected healthy after not modified")
+	}
+
+	after := GetUpstreamSample()
+	if after.Requests-before.Requests != 4 || after.Errors-before.Errors != 2 || after.LatencySeconds-before.LatencySeconds < 2 {
+		t.Errorf("Unexpected upstream sample delta %+v -> %+v", before, after)
+	}
+}
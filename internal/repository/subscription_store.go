@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrSubscriptionNotFound is returned for unknown subscription IDs.
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionExists is returned when creating a subscription with an ID in use.
+	ErrSubscriptionExists = errors.New("subscription ID in use")
+)
+
+const (
+	subscriptionsKey     = "webhook:subscriptions"
+	subscriptionIndexKey = "webhook:index"
+	deadLettersKey       = "webhook:deadletters"
+)
+
+// SubscriptionFilter narrows List results. Empty fields match everything except Owner, which
+// always scopes the results: subscriptions created without an API key have an empty owner.
+type SubscriptionFilter struct {
+	Owner    string
+	Location string
+	Status   string
+}
+
+func (f SubscriptionFilter) matches(sub *model.Subscription) bool {
+	return sub.Owner == f.Owner &&
+		(f.Location == "" || SubscriptionLocation(f.Location) == SubscriptionLocation(sub.Location)) &&
+		(f.Status == "" || f.Status == sub.Status)
+}
+
+// SubscriptionStore persists webhook subscriptions. Subscriptions live in one Redis hash keyed by
+// ID, with a set of IDs per location for dispatch and a lexicographically sorted index of every ID
+// for cursor pagination.
+//
+// Subscriptions read from the store carry their Revision. Update and Delete given a revision only
+// succeed while it is still the stored one, and otherwise return ErrRevisionMismatch; both set
+// sub.Revision to the stored one on success, as does Create.
+type SubscriptionStore interface {
+	// Create stores a new subscription, or returns ErrSubscriptionExists if its ID is in use.
+	Create(ctx context.Context, sub *model.Subscription) error
+	Get(ctx context.Context, id string) (*model.Subscription, error)
+	// Update replaces a stored subscription, moving it between locations if needed.
+	Update(ctx context.Context, sub *model.Subscription) error
+	// List returns up to limit subscriptions matching filter with IDs after cursor ("" for the
+	// first page), ordered by ID. next is the cursor for the following page, or "" at the end.
+	List(ctx context.Context, filter SubscriptionFilter, cursor string, limit int) (subs []*model.Subscription, next string, err error)
+	// ListByLocation returns every subscription for location, secrets included.
+	ListByLocation(ctx context.Context, location string) ([]*model.Subscription, error)
+	// Delete removes a subscription and its index entries, if it is at revision, when given.
+	Delete(ctx context.Context, id, revision string) error
+}
+
+// SubscriptionClient is the subset of Redis operations needed to store subscriptions.
+type SubscriptionClient interface {
+	HSet(ctx context.Context, key string, values ...interface{}) *redisv9.IntCmd
+	HSetNX(ctx context.Context, key, field string, value interface{}) *redisv9.BoolCmd
+	HDel(ctx context.Context, key string, fields ...string) *redisv9.IntCmd
+	HGet(ctx context.Context, key, field string) *redisv9.StringCmd
+	HMGet(ctx context.Context, key string, fields ...string) *redisv9.SliceCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redisv9.IntCmd
+	SRem(ctx context.Context, key string, members ...interface{}) *redisv9.IntCmd
+	SMembers(ctx context.Context, key string) *redisv9.StringSliceCmd
+	ZAdd(ctx context.Context, key string, members ...redisv9.Z) *redisv9.IntCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redisv9.IntCmd
+	ZRangeByLex(ctx context.Context, key string, opt *redisv9.ZRangeBy) *redisv9.StringSliceCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redisv9.Cmd
+}
+
+type subscriptionStore struct {
+	client SubscriptionClient
+}
+
+// NewSubscriptionStore creates a SubscriptionStore backed by the shared Redis client.
+func NewSubscriptionStore(client ...SubscriptionClient) SubscriptionStore {
+	var c SubscriptionClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &subscriptionStore{client: c}
+}
+
+// subscriptionValueKey binds an encrypted subscription to its field of the subscriptions hash.
+func subscriptionValueKey(id string) string {
+	return subscriptionsKey + ":" + id
+}
+
+func subscriptionLocationKey(location string) string {
+	return "webhook:location:" + SubscriptionLocation(location)
+}
+
+// SubscriptionLocation identifies the place a location names, so that "London", "london" and
+// "London,GB" match each other and the canonical location of a cache update. Known cities are
+// identified by name and country, other locations by their lowercased, trimmed spelling.
+func SubscriptionLocation(location string) string {
+	if city, ok := geodata.Lookup(location); ok {
+		location = city.Name + "," + city.Country
+	}
+	return strings.ToLower(strings.Join(strings.Fields(location), " "))
+}
+
+func (s *subscriptionStore) Create(ctx context.Context, sub *model.Subscription) error {
+	b, err := marshalCacheValue(subscriptionValueKey(sub.ID), sub)
+	if err != nil {
+		return err
+	}
+	created, err := s.client.HSetNX(ctx, subscriptionsKey, sub.ID, b).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrSubscriptionExists
+	}
+	sub.Revision = revision(b)
+	if err := s.client.ZAdd(ctx, subscriptionIndexKey, redisv9.Z{Member: sub.ID}).Err(); err != nil {
+		return err
+	}
+	return s.client.SAdd(ctx, subscriptionLocationKey(sub.Location), sub.ID).Err()
+}
+
+func (s *subscriptionStore) Update(ctx context.Context, sub *model.Subscription) error {
+	current, err := s.Get(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	if sub.Revision != "" && sub.Revision != current.Revision {
+		return ErrRevisionMismatch
+	}
+	b, err := marshalCacheValue(subscriptionValueKey(sub.ID), sub)
+	if err != nil {
+		return err
+	}
+	if sub.Revision != "" {
+		n, err := s.client.Eval(ctx, hashSetIfScript, []string{subscriptionsKey}, sub.ID, sub.Revision, b).Int()
+		if err != nil {
+			return err
+		}
+		if err := conditionalResult(n, ErrSubscriptionNotFound); err != nil {
+			return err
+		}
+	} else if err := s.client.HSet(ctx, subscriptionsKey, sub.ID, b).Err(); err != nil {
+		return err
+	}
+	sub.Revision = revision(b)
+	if oldKey, newKey := subscriptionLocationKey(current.Location), subscriptionLocationKey(sub.Location); oldKey != newKey {
+		if err := s.client.SAdd(ctx, newKey, sub.ID).Err(); err != nil {
+			return err
+		}
+		return s.client.SRem(ctx, oldKey, sub.ID).Err()
+	}
+	return nil
+}
+
+func (s *subscriptionStore) List(ctx context.Context, filter SubscriptionFilter, cursor string, limit int) ([]*model.Subscription, string, error) {
+	min := "-"
+	if cursor != "" {
+		min = "(" + cursor
+	}
+	batch := int64(max(limit*2, 100))
+	var subs []*model.Subscription
+	for {
+		ids, err := s.client.ZRangeByLex(ctx, subscriptionIndexKey, &redisv9.ZRangeBy{Min: min, Max: "+", Count: batch}).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(ids) == 0 {
+			return subs, "", nil
+		}
+		page, err := s.getMany(ctx, ids)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, sub := range page {
+			if !filter.matches(sub) {
+				continue
+			}
+			subs = append(subs, sub)
+			if len(subs) == limit {
+				return subs, sub.ID, nil
+			}
+		}
+		if int64(len(ids)) < batch {
+			return subs, "", nil
+		}
+		min = "(" + ids[len(ids)-1]
+	}
+}
+
+func (s *subscriptionStore) Get(ctx context.Context, id string) (*model.Subscription, error) {
+	val, err := s.client.HGet(ctx, subscriptionsKey, id).Result()
+	if errors.Is(err, redisv9.Nil) {
+		return nil, ErrSubscriptionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var sub model.Subscription
+	if err := unmarshalCacheValue(subscriptionValueKey(id), []byte(val), &sub); err != nil {
+		return nil, err
+	}
+	sub.Revision = revision([]byte(val))
+	return &sub, nil
+}
+
+func (s *subscriptionStore) ListByLocation(ctx context.Context, location string) ([]*model.Subscription, error) {
+	ids, err := s.client.SMembers(ctx, subscriptionLocationKey(location)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	return s.getMany(ctx, ids)
+}
+
+func (s *subscriptionStore) Delete(ctx context.Context, id, rev string) error {
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rev != "" && rev != current.Revision {
+		return ErrRevisionMismatch
+	}
+	// The subscription goes first: index entries left behind by a failure part way are skipped
+	// when read, and a conditional delete leaves the indexes alone when it loses
+	if rev != "" {
+		n, err := s.client.Eval(ctx, hashDelIfScript, []string{subscriptionsKey}, id, rev).Int()
+		if err != nil {
+			return err
+		}
+		if err := conditionalResult(n, ErrSubscriptionNotFound); err != nil {
+			return err
+		}
+	} else if err := s.client.HDel(ctx, subscriptionsKey, id).Err(); err != nil {
+		return err
+	}
+	if err := s.client.SRem(ctx, subscriptionLocationKey(current.Location), id).Err(); err != nil {
+		return err
+	}
+	return s.client.ZRem(ctx, subscriptionIndexKey, id).Err()
+}
+
+// getMany loads the subscriptions with the given IDs, in order, skipping missing ones.
+func (s *subscriptionStore) getMany(ctx context.Context, ids []string) ([]*model.Subscription, error) {
+	vals, err := s.client.HMGet(ctx, subscriptionsKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]*model.Subscription, 0, len(vals))
+	for i, v := range vals {
+		raw, ok := v.(string)
+		if !ok {
+			continue // removed since the set was read
+		}
+		var sub model.Subscription
+		if err := unmarshalCacheValue(subscriptionValueKey(ids[i]), []byte(raw), &sub); err != nil {
+			logger(ctx).Warnw("Skipping undecodable subscription", "id", ids[i], "error", err)
+			continue
+		}
+		sub.Revision = revision([]byte(raw))
+		subs = append(subs, &sub)
+	}
+	return subs, nil
+}
+
+// DeadLetterStore keeps webhook deliveries that exhausted their retries, newest first.
+type DeadLetterStore interface {
+	Add(ctx context.Context, letter model.DeadLetter) error
+	List(ctx context.Context, limit int) ([]model.DeadLetter, error)
+}
+
+type deadLetterStore struct {
+	client ListClient
+}
+
+// NewDeadLetterStore creates a DeadLetterStore backed by the shared Redis client.
+func NewDeadLetterStore(client ...ListClient) DeadLetterStore {
+	var c ListClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &deadLetterStore{client: c}
+}
+
+func (s *deadLetterStore) Add(ctx context.Context, letter model.DeadLetter) error {
+	b, err := marshalCacheValue(deadLettersKey, letter)
+	if err != nil {
+		return err
+	}
+	if err := s.client.LPush(ctx, deadLettersKey, b).Err(); err != nil {
+		return err
+	}
+	return s.client.LTrim(ctx, deadLettersKey, 0, int64(config.GetWebhookConfig().DeadLetterLimit-1)).Err()
+}
+
+func (s *deadLetterStore) List(ctx context.Context, limit int) ([]model.DeadLetter, error) {
+	raw, err := s.client.LRange(ctx, deadLettersKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]model.DeadLetter, 0, len(raw))
+	for _, v := range raw {
+		var letter model.DeadLetter
+		if err := unmarshalCacheValue(deadLettersKey, []byte(v), &letter); err != nil {
+			logger(ctx).Warnw("Skipping undecodable dead letter", "error", err)
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+func newRedisClient(t *testing.T) (*redisv9.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client, mr
+}
+
+func TestSubscriptionStore(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewSubscriptionStore(client)
+	ctx := context.Background()
+
+	for i, loc := range []string{"London", "london ", "Paris", "London,GB", "London,CA"} {
+		sub := &model.Subscription{ID: "sub_" + strconv.Itoa(i), Location: loc, URL: "https://example.com/hook", Secret: "s", Status: model.SubscriptionActive}
+		if err := store.Create(ctx, sub); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	got, err := store.Get(ctx, "sub_2")
+	if err != nil || got.Location != "Paris" || got.Secret != "s" {
+		t.Errorf("Unexpected Get result %+v, %v", got, err)
+	}
+	if _, err := store.Get(ctx, "sub_9"); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected ErrSubscriptionNotFound, got %v", err)
+	}
+
+	subs, err := store.ListByLocation(ctx, "LONDON")
+	if err != nil || len(subs) != 3 {
+		t.Errorf("Expected the three subscriptions for London, GB, got %+v, %v", subs, err)
+	}
+	if subs, _ := store.ListByLocation(ctx, "London,CA"); len(subs) != 1 || subs[0].ID != "sub_4" {
+		t.Errorf("Expected only the London, CA subscription, got %+v", subs)
+	}
+	if subs, _ := store.ListByLocation(ctx, "Tokyo"); len(subs) != 0 {
+		t.Errorf("Expected none for Tokyo, got %+v", subs)
+	}
+
+	if err := store.Delete(ctx, "sub_0", ""); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "sub_0"); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected a deleted subscription to be gone, got %v", err)
+	}
+	if subs, _ := store.ListByLocation(ctx, "London"); len(subs) != 2 {
+		t.Errorf("Expected two London subscriptions left, got %+v", subs)
+	}
+	if subs, _, _ := store.List(ctx, SubscriptionFilter{}, "", 10); len(subs) != 4 {
+		t.Errorf("Expected four subscriptions listed, got %+v", subs)
+	}
+	if err := store.Delete(ctx, "sub_0", ""); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected ErrSubscriptionNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestSubscriptionStore_ListAndUpdate(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewSubscriptionStore(client)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		sub := &model.Subscription{ID: "sub_" + strconv.Itoa(i), Location: "London", Status: model.SubscriptionActive}
+		if i == 4 {
+			sub.Owner = "team-b"
+		}
+		if err := store.Create(ctx, sub); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	var ids []string
+	cursor := ""
+	for page := 0; ; page++ {
+		subs, next, err := store.List(ctx, SubscriptionFilter{}, cursor, 2)
+		if err != nil || page > 3 {
+			t.Fatalf("List: %v (page %d)", err, page)
+		}
+		for _, sub := range subs {
+			ids = append(ids, sub.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if strings.Join(ids, ",") != "sub_0,sub_1,sub_2,sub_3" {
+		t.Errorf("Expected the unowned subscriptions in ID order, got %v", ids)
+	}
+
+	sub, _ := store.Get(ctx, "sub_1")
+	sub.Location, sub.Status = "Paris", model.SubscriptionPaused
+	if err := store.Update(ctx, sub); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if subs, _ := store.ListByLocation(ctx, "London"); len(subs) != 4 {
+		t.Errorf("Expected sub_1 moved out of London, got %d", len(subs))
+	}
+	subs, _, _ := store.List(ctx, SubscriptionFilter{Location: "paris", Status: model.SubscriptionPaused}, "", 10)
+	if len(subs) != 1 || subs[0].ID != "sub_1" {
+		t.Errorf("Expected the paused Paris subscription, got %+v", subs)
+	}
+	if subs, _, _ := store.List(ctx, SubscriptionFilter{Owner: "team-b"}, "", 10); len(subs) != 1 || subs[0].ID != "sub_4" {
+		t.Errorf("Expected only team-b's subscription, got %+v", subs)
+	}
+	if err := store.Update(ctx, &model.Subscription{ID: "sub_9"}); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+func TestSubscriptionStore_Revisions(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewSubscriptionStore(client)
+	ctx := context.Background()
+
+	sub := &model.Subscription{ID: "sub_1", Location: "London", URL: "https://example.com/hook", Status: model.SubscriptionActive}
+	if err := store.Create(ctx, sub); err != nil || sub.Revision == "" {
+		t.Fatalf("Create: %v, revision %q", err, sub.Revision)
+	}
+	if err := store.Create(ctx, &model.Subscription{ID: "sub_1", Location: "Paris"}); !errors.Is(err, ErrSubscriptionExists) {
+		t.Errorf("Expected ErrSubscriptionExists for a used ID, got %v", err)
+	}
+	read, _ := store.Get(ctx, "sub_1")
+	if read.Revision != sub.Revision {
+		t.Errorf("Expected Get to report the created revision %q, got %q", sub.Revision, read.Revision)
+	}
+
+	// A writer holding the first revision loses to one that wrote since
+	stale := *read
+	read.Status = model.SubscriptionPaused
+	if err := store.Update(ctx, read); err != nil || read.Revision == stale.Revision {
+		t.Fatalf("Update: %v, revision %q", err, read.Revision)
+	}
+	stale.Location = "Paris"
+	if err := store.Update(ctx, &stale); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch updating a stale revision, got %v", err)
+	}
+	if err := store.Delete(ctx, "sub_1", stale.Revision); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch deleting a stale revision, got %v", err)
+	}
+	if got, _ := store.Get(ctx, "sub_1"); got.Location != "London" || got.Status != model.SubscriptionPaused {
+		t.Errorf("Expected the stale writes to change nothing, got %+v", got)
+	}
+	if err := store.Delete(ctx, "sub_1", read.Revision); err != nil {
+		t.Errorf("Delete at the current revision: %v", err)
+	}
+}
+
+func TestDeadLetterStore(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewDeadLetterStore(client)
+	ctx := context.Background()
+	viper.Set("webhooks.dead_letter_limit", 2)
+	defer viper.Set("webhooks.dead_letter_limit", nil)
+
+	for i := 0; i < 3; i++ {
+		letter := model.DeadLetter{SubscriptionID: "sub_" + strconv.Itoa(i), Attempts: 5, FailedAt: time.Now()}
+		if err := store.Add(ctx, letter); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	letters, err := store.List(ctx, 10)
+	if err != nil || len(letters) != 2 || letters[0].SubscriptionID != "sub_2" {
+		t.Errorf("Expected the 2 newest dead letters, got %+v, %v", letters, err)
+	}
+	if letters, _ := store.List(ctx, 1); len(letters) != 1 {
+		t.Errorf("Expected limit to apply, got %d", len(letters))
+	}
+}
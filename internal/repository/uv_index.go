@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+)
+
+// addUVIndex sets the UV index of freshly fetched weather when openweathermap.uv_index is enabled,
+// so it is cached with the rest of the entry. The UV index comes from OpenWeatherMap whichever
+// provider served the weather, at the coordinates that provider placed the location at. It is
+// best-effort: without coordinates, or when the call fails, the weather is served without one.
+func (r *weatherRepository) addUVIndex(ctx context.Context, result *upstreamResult) {
+	cfg := config.GetUVIndexConfig()
+	if !cfg.Enabled || result.Coords == nil {
+		return
+	}
+	// The call is billed to, and paused with, the OpenWeatherMap account of the request
+	ctx = context.WithValue(ctx, routedProviderKey{}, providerOpenWeatherMap)
+	if _, cooling := r.coolingDown(ctx); cooling {
+		logger(ctx).Debugw("UV index skipped during provider cool-down")
+		return
+	}
+	uv, err := r.fetchUVIndex(ctx, cfg.URL, *result.Coords)
+	if err != nil {
+		logger(ctx).Warnw("UV index not fetched", "error", err)
+		return
+	}
+	result.Weather.UVIndex = &uv
+}
+
+// fetchUVIndex calls the One Call API, or the older UV index API, for the current UV index at
+// coords. Both take the same query; One Call is asked to leave out everything else.
+func (r *weatherRepository) fetchUVIndex(ctx context.Context, apiURL string, coords model.Coordinates) (float64, error) {
+	apiKey, _, _ := upstreamFor(ctx)
+	if apiKey == "" {
+		return 0, ErrAPIKeyMissing
+	}
+	u := fmt.Sprintf("%s?lat=%s&lon=%s&exclude=minutely,hourly,daily,alerts&appid=%s", apiURL,
+		strconv.FormatFloat(coords.Lat, 'f', -1, 64), strconv.FormatFloat(coords.Lon, 'f', -1, 64), apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, ErrExternalAPI
+	}
+
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, ErrExternalAPI
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return 0, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	default:
+		return 0, fmt.Errorf("%w: UV index status %d", ErrExternalAPI, resp.StatusCode)
+	}
+	endCoolDownStreak(ctx)
+
+	var data model.OpenWeatherMapUVResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, err
+	}
+	switch {
+	case data.Current != nil && data.Current.UVI != nil:
+		return *data.Current.UVI, nil
+	case data.Value != nil:
+		return *data.Value, nil
+	default:
+		return 0, fmt.Errorf("%w: no UV index in response", ErrExternalAPI)
+	}
+}
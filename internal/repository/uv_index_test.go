@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// uvProvider answers current weather and UV index calls, counting the UV index calls.
+type uvProvider struct {
+	mu       sync.Mutex
+	uvCalls  []string
+	uvStatus int
+	uvBody   string
+}
+
+func (p *uvProvider) roundTrip(req *http.Request) *http.Response {
+	if req.URL.Host != "uv.test" {
+		body := `{"name":"Lima","coord":{"lon":-77.0282,"lat":-12.0432},"main":{"temp":24},"weather":[{"description":"clear sky"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.uvCalls = append(p.uvCalls, req.URL.RawQuery)
+	return &http.Response{StatusCode: p.uvStatus, Body: io.NopCloser(strings.NewReader(p.uvBody)), Header: make(http.Header)}
+}
+
+func newUVRepository(t *testing.T, enabled bool) (*weatherRepository, *uvProvider) {
+	t.Helper()
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	viper.Set("openweathermap.api_url", "http://owm.test/weather")
+	viper.Set("openweathermap.uv_index.enabled", enabled)
+	viper.Set("openweathermap.uv_index.url", "http://uv.test/onecall")
+	t.Cleanup(func() {
+		viper.Set("openweathermap.api_url", nil)
+		viper.Set("openweathermap.uv_index.enabled", nil)
+		viper.Set("openweathermap.uv_index.url", nil)
+	})
+	client, _ := newRedisClient(t)
+	provider := &uvProvider{uvStatus: http.StatusOK, uvBody: `{"current":{"uvi":11.3}}`}
+	return &weatherRepository{redisClient: client, httpClient: newMockHTTPClient(provider.roundTrip)}, provider
+}
+
+func TestGetWeather_UVIndex(t *testing.T) {
+	repo, provider := newUVRepository(t, true)
+	ctx := context.Background()
+
+	weather, err := repo.GetWeather(ctx, "Lima")
+	if err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	if weather.UVIndex == nil || *weather.UVIndex != 11.3 {
+		t.Fatalf("Expected UV index 11.3, got %v", weather.UVIndex)
+	}
+	if len(provider.uvCalls) != 1 || provider.uvCalls[0] != "lat=-12.0432&lon=-77.0282&exclude=minutely,hourly,daily,alerts&appid=testkey" {
+		t.Errorf("Expected one UV index call at Lima's coordinates, got %v", provider.uvCalls)
+	}
+
+	// The UV index is cached with the weather
+	weather, err = repo.GetWeather(ctx, "Lima")
+	if err != nil || !weather.Cached || weather.UVIndex == nil || *weather.UVIndex != 11.3 {
+		t.Errorf("Expected the cached UV index, got %+v, %v", weather, err)
+	}
+	if len(provider.uvCalls) != 1 {
+		t.Errorf("Expected no UV index call on a cache hit, got %d calls", len(provider.uvCalls))
+	}
+}
+
+func TestGetWeather_UVIndex_BestEffort(t *testing.T) {
+	repo, provider := newUVRepository(t, true)
+	ctx := context.Background()
+
+	// The older UV index API answers with value, which may be 0 at night
+	provider.uvBody = `{"lat":-12.04,"lon":-77.03,"value":0}`
+	weather, err := repo.GetWeather(WithCachePolicy(ctx, CachePolicyRefresh), "Lima")
+	if err != nil || weather.UVIndex == nil || *weather.UVIndex != 0 {
+		t.Fatalf("Expected UV index 0, got %+v, %v", weather, err)
+	}
+
+	// A failed UV index call leaves the weather without one
+	provider.uvStatus, provider.uvBody = http.StatusUnauthorized, `{"cod":401}`
+	weather, err = repo.GetWeather(WithCachePolicy(ctx, CachePolicyRefresh), "Lima")
+	if err != nil || weather.UVIndex != nil {
+		t.Errorf("Expected weather without a UV index, got %+v, %v", weather, err)
+	}
+}
+
+func TestGetWeather_UVIndex_Disabled(t *testing.T) {
+	repo, provider := newUVRepository(t, false)
+	weather, err := repo.GetWeather(context.Background(), "Lima")
+	if err != nil || weather.UVIndex != nil {
+		t.Errorf("Expected weather without a UV index, got %+v, %v", weather, err)
+	}
+	if len(provider.uvCalls) != 0 {
+		t.Errorf("Expected no UV index calls when disabled, got %v", provider.uvCalls)
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const warmerCheckpointKey = "warmer:checkpoint"
+
+// WarmerCheckpoint records how far the cache warmer got through its current cycle.
+type WarmerCheckpoint struct {
+	// ListHash identifies the warm list Cursor refers to; a changed list starts a new cycle.
+	ListHash string `json:"list_hash"`
+	// Cursor is the index of the next city to refresh.
+	Cursor    int       `json:"cursor"`
+	LastCity  string    `json:"last_city"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckpointStore persists the cache warmer's progress across restarts.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil when there is none.
+	Load(ctx context.Context) (*WarmerCheckpoint, error)
+	Save(ctx context.Context, cp WarmerCheckpoint) error
+	// Clear removes the checkpoint once a cycle completes.
+	Clear(ctx context.Context) error
+}
+
+// CheckpointClient is the subset of Redis operations needed to store checkpoints.
+type CheckpointClient interface {
+	Get(ctx context.Context, key string) *redisv9.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd
+	Del(ctx context.Context, keys ...string) *redisv9.IntCmd
+}
+
+type checkpointStore struct {
+	client CheckpointClient
+}
+
+// NewCheckpointStore creates a CheckpointStore backed by the shared Redis client.
+func NewCheckpointStore(client ...CheckpointClient) CheckpointStore {
+	var c CheckpointClient = redis.GetClient()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &checkpointStore{client: c}
+}
+
+func (s *checkpointStore) Load(ctx context.Context) (*WarmerCheckpoint, error) {
+	raw, err := s.client.Get(ctx, warmerCheckpointKey).Bytes()
+	if errors.Is(err, redisv9.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp WarmerCheckpoint
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (s *checkpointStore) Save(ctx context.Context, cp WarmerCheckpoint) error {
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, warmerCheckpointKey, raw, 0).Err()
+}
+
+func (s *checkpointStore) Clear(ctx context.Context) error {
+	return s.client.Del(ctx, warmerCheckpointKey).Err()
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	client, _ := newRedisClient(t)
+	store := NewCheckpointStore(client)
+	ctx := context.Background()
+
+	if cp, err := store.Load(ctx); cp != nil || err != nil {
+		t.Fatalf("Expected no checkpoint, got %+v, %v", cp, err)
+	}
+	want := WarmerCheckpoint{ListHash: "abc", Cursor: 3, LastCity: "Paris", StartedAt: time.Unix(1700000000, 0).UTC(), UpdatedAt: time.Unix(1700000060, 0).UTC()}
+	if err := store.Save(ctx, want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if cp, err := store.Load(ctx); err != nil || *cp != want {
+		t.Errorf("Expected %+v, got %+v, %v", want, cp, err)
+	}
+	if err := store.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if cp, _ := store.Load(ctx); cp != nil {
+		t.Errorf("Expected the checkpoint cleared, got %+v", cp)
+	}
+}
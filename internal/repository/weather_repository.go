@@ -6,11 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
+	"github.com/fakhrymubarak/weather-api-redis/internal/logctx"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
 	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
 	redisv9 "github.com/redis/go-redis/v9"
 )
 
@@ -19,6 +27,8 @@ var (
 	ErrLocationNotFound = errors.New("location not found")
 	ErrAPIKeyMissing    = errors.New("API key missing")
 	ErrExternalAPI      = errors.New("external API error")
+	ErrCacheStale       = errors.New("cache entry stale")
+	ErrCacheOnlyMiss    = errors.New("no cached data available for location")
 )
 
 type LocationNotFoundError struct {
@@ -34,6 +44,11 @@ type WeatherRepository interface {
 	GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error)
 }
 
+// LocationRepository is implemented by repositories that also look up weather by coordinates.
+type LocationRepository interface {
+	GetWeatherAt(ctx context.Context, loc model.Location) (*model.WeatherResponse, error)
+}
+
 // RedisClient defines a minimal interface for Redis operations
 type RedisClient interface {
 	Get(ctx context.Context, key string) *redisv9.StringCmd
@@ -43,82 +58,323 @@ type RedisClient interface {
 // weatherRepository implements WeatherRepository
 type weatherRepository struct {
 	redisClient RedisClient
-	httpClient  *http.Client
+	// readClient serves cache reads, from replicas when configured; redisClient is used when nil.
+	readClient redis.Reader
+	// writer stores entries in the background; entries are stored synchronously when nil.
+	writer     *cacheWriter
+	httpClient *http.Client
 }
 
-// NewWeatherRepository creates a new weather repository instance
+// NewWeatherRepository creates a new weather repository instance. Provider calls use httpClient
+// when given, otherwise a client resolving the provider host as openweathermap.resolve says.
 func NewWeatherRepository(httpClient ...*http.Client) WeatherRepository {
-	client := http.DefaultClient
+	client := sharedProviderClient()
 	if len(httpClient) > 0 && httpClient[0] != nil {
 		client = httpClient[0]
 	}
 	return &weatherRepository{
 		redisClient: redis.GetClient(),
+		readClient:  redis.GetReadClient(),
+		writer:      sharedCacheWriter(),
 		httpClient:  client,
 	}
 }
 
-// GetWeather retrieves weather data, checking cache first, then external API
+// GetWeather retrieves weather data, checking cache first, then external API. An operator
+// override in force for the location takes precedence over both.
 func (r *weatherRepository) GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error) {
-	if cached, err := r.getFromCache(ctx, location); err == nil {
-		config.GetLogger().Debugw("Cache hit", "location", location)
-		return cached, nil
+	return r.GetWeatherAt(ctx, model.Location{Name: location})
+}
+
+// GetWeatherAt is GetWeather for a place name or coordinates. Coordinates are looked up rounded,
+// and cached under "weather:coord:<lat>,<lon>".
+func (r *weatherRepository) GetWeatherAt(ctx context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	if loc.Coords != nil {
+		rounded := loc.Coords.Rounded()
+		loc.Coords = &rounded
+	}
+	location := loc.Key()
+	ctx = logctx.WithLocation(ctx, location)
+	if weather, ok := overrideFor(location); ok {
+		logger(ctx).Debugw("Serving operator override")
+		return weather, nil
+	}
+	switch CachePolicyFrom(ctx) {
+	case CachePolicyRefresh:
+		logger(ctx).Debugw("Cache bypassed by refresh")
+		return r.refresh(ctx, loc)
+	case CachePolicyCacheOnly:
+		return r.getCacheOnly(ctx, location)
+	}
+
+	entry, err := r.readEntry(ctx, location)
+	if err == nil && entry.isFresh(cacheClock.Now()) {
+		cacheLookups.WithLabelValues(lookupHit).Inc()
+		recordCallSaved(providerAccount(ctx))
+		logger(ctx).Debugw("Cache hit")
+		weather := entry.WeatherResponse
+		weather.Cached = true
+		return &weather, nil
+	} else if err == nil {
+		cacheLookups.WithLabelValues(lookupStale).Inc()
+		logger(ctx).Debugw("Cache stale")
 	} else {
-		config.GetLogger().Debugw("Cache miss", "location", location, "error", err)
+		cacheLookups.WithLabelValues(lookupMiss).Inc()
+		logger(ctx).Debugw("Cache miss", "error", err)
+	}
+
+	// A stale entry keeps its validators so the provider can answer 304 Not Modified
+	var previous *cacheEntry
+	if err == nil {
+		previous = entry
+	}
+	return r.fetchMiss(ctx, loc, previous)
+}
+
+// fetchMiss fetches location from the provider after a cache miss, or after previous, a stale
+// entry, and caches the result. previous is served instead while the provider rate limits us.
+func (r *weatherRepository) fetchMiss(ctx context.Context, loc model.Location, previous *cacheEntry) (*model.WeatherResponse, error) {
+	location := loc.Key()
+	ctx, loc = r.route(ctx, loc)
+	// While the provider is rate limiting us, a stale entry beats an error
+	if retryAfter, cooling := r.coolingDown(ctx); cooling {
+		return serveStale(ctx, previous, &CoolDownError{RetryAfter: retryAfter})
 	}
 
 	// If not in cache, fetch from external API
-	weather, err := r.fetchFromExternalAPI(location)
-	if err != nil {
-		config.GetLogger().Warnw("External API error", "location", location, "error", err)
+	result, err := r.fetchUpstream(ctx, loc, previous)
+	if errors.Is(err, ErrProviderRateLimited) {
+		return serveStale(ctx, previous, err)
+	} else if err != nil {
+		logger(ctx).Warnw("External API error", "error", err)
 		return nil, err
 	}
-	config.GetLogger().Debugw("Fetched from API", "location", location)
+
+	if result.NotModified {
+		logger(ctx).Debugw("Upstream not modified, extending TTL")
+		r.storeEntry(ctx, location, previous)
+		weather := previous.WeatherResponse
+		weather.Cached = true
+		weather.FetchedAt = cacheClock.Now()
+		return &weather, nil
+	}
+	logger(ctx).Debugw("Fetched from API")
+	r.addUVIndex(ctx, result)
 
 	// Cache the result
-	r.cacheWeather(ctx, location, weather)
+	r.storeEntry(ctx, location, &cacheEntry{
+		WeatherResponse: *result.Weather,
+		ETag:            result.ETag,
+		LastModified:    result.LastModified,
+	})
+	publishUpdate(location, result.Weather)
 
-	return weather, nil
+	return result.Weather, nil
 }
 
-// getFromCache retrieves weather data from Redis cache
+// refresh fetches fresh data from the external API unconditionally and overwrites the cache entry.
+// During a provider cool-down, the cached entry is served as stale instead.
+func (r *weatherRepository) refresh(ctx context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	location := loc.Key()
+	ctx, loc = r.route(ctx, loc)
+	var err error
+	if retryAfter, cooling := r.coolingDown(ctx); cooling {
+		err = &CoolDownError{RetryAfter: retryAfter}
+	}
+	var result *upstreamResult
+	if err == nil {
+		result, err = r.fetchUpstream(ctx, loc, nil)
+	}
+	if errors.Is(err, ErrProviderRateLimited) {
+		entry, _ := r.readEntry(ctx, location)
+		return serveStale(ctx, entry, err)
+	} else if err != nil {
+		logger(ctx).Warnw("External API error", "error", err)
+		return nil, err
+	}
+	r.addUVIndex(ctx, result)
+	r.storeEntry(ctx, location, &cacheEntry{
+		WeatherResponse: *result.Weather,
+		ETag:            result.ETag,
+		LastModified:    result.LastModified,
+	})
+	publishUpdate(location, result.Weather)
+	return result.Weather, nil
+}
+
+// getCacheOnly serves the cache entry without ever calling the external API. Expired entries that
+// are still retained are returned flagged as stale.
+func (r *weatherRepository) getCacheOnly(ctx context.Context, location string) (*model.WeatherResponse, error) {
+	entry, err := r.readEntry(ctx, location)
+	if err != nil {
+		logger(ctx).Debugw("Cache-only miss", "error", err)
+		return nil, ErrCacheOnlyMiss
+	}
+	weather := entry.WeatherResponse
+	weather.Cached = true
+	weather.Stale = !entry.isFresh(cacheClock.Now())
+	return &weather, nil
+}
+
+// getFromCache retrieves fresh weather data from Redis cache
 func (r *weatherRepository) getFromCache(ctx context.Context, location string) (*model.WeatherResponse, error) {
-	cacheKey := "weather:" + location
+	entry, err := r.readEntry(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.isFresh(cacheClock.Now()) {
+		return nil, ErrCacheStale
+	}
 
-	val, err := r.redisClient.Get(ctx, cacheKey).Result()
+	weather := entry.WeatherResponse
+	weather.Cached = true
+	return &weather, nil
+}
+
+// readEntry retrieves the raw cache entry under the request's cache key from Redis, whether fresh
+// or stale. A lookup slower than cache.timeouts.read fails, so the caller falls through to the
+// provider as on a miss.
+func (r *weatherRepository) readEntry(ctx context.Context, location string) (*cacheEntry, error) {
+	cacheKey := cacheKeyFor(ctx, location)
+
+	getCtx, cancel := withCacheTimeout(ctx, config.GetCacheTimeouts().Read)
+	defer cancel()
+	val, err := r.reader().Get(getCtx, cacheKey).Result()
 	if err != nil {
-		config.GetLogger().Debugw("Redis get error", "cacheKey", cacheKey, "error", err)
+		if cacheTimedOut(ctx, getCtx, cacheOpGet) {
+			logger(ctx).Warnw("Redis get timed out", "cacheKey", cacheKey, "error", err)
+			return nil, err
+		}
+		logger(ctx).Debugw("Redis get error", "cacheKey", cacheKey, "error", err)
 		return nil, err
 	}
 
-	config.GetLogger().Debugw("Redis get success", "cacheKey", cacheKey, "value", val)
+	logger(ctx).Debugw("Redis get success", "cacheKey", cacheKey, "value", val)
+	return decodeEntry(ctx, cacheKey, []byte(val))
+}
 
-	var weather model.WeatherResponse
-	if err := json.Unmarshal([]byte(val), &weather); err != nil {
-		config.GetLogger().Errorw("Unmarshal error", "cacheKey", cacheKey, "error", err)
+// decodeEntry decodes the cache entry val read from cacheKey.
+func decodeEntry(ctx context.Context, cacheKey string, val []byte) (*cacheEntry, error) {
+	var entry cacheEntry
+	if err := unmarshalCacheValue(cacheKey, val, &entry); err != nil {
+		logger(ctx).Errorw("Unmarshal error", "cacheKey", cacheKey, "error", err)
 		return nil, err
 	}
+	// Entries cached before a precision change are served at the current precision
+	entry.Temperature = model.RoundTemperature(entry.Temperature, config.GetTemperaturePrecision())
+	if entry.StoredAt > 0 {
+		entry.FetchedAt = time.UnixMilli(entry.StoredAt)
+	}
+	return &entry, nil
+}
 
-	weather.Cached = true
-	return &weather, nil
+// reader returns the client cache reads go to.
+func (r *weatherRepository) reader() redis.Reader {
+	if r.readClient != nil {
+		return r.readClient
+	}
+	return r.redisClient
 }
 
 // fetchFromExternalAPI retrieves weather data from OpenWeatherMap API
-func (r *weatherRepository) fetchFromExternalAPI(location string) (*model.WeatherResponse, error) {
-	config.GetLogger().Debugw("Fetching from external API", "location", location)
-	apiKey := config.GetOpenWeatherMapAPIKey()
+func (r *weatherRepository) fetchFromExternalAPI(ctx context.Context, location string) (*model.WeatherResponse, error) {
+	result, err := r.fetchUpstream(ctx, model.Location{Name: location}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.Weather, nil
+}
+
+// upstreamFor returns the API key and URL of a request's provider calls: the tenant's own account
+// when the request carries one, and the URL of its provider_url experiment unless the tenant sets
+// its own. scope keeps the cache entries of such calls apart from the shared ones; it is empty for
+// the shared account, and otherwise a prefix such as "tenant:acme:" or "experiment:new-api:".
+func upstreamFor(ctx context.Context) (apiKey, apiURL, scope string) {
+	apiKey, apiURL = config.GetOpenWeatherMapAPIKey(), config.GetOpenWeatherApiUrl()
+	if a, ok := experiment.FromContext(ctx); ok && a.Variant == experiment.ProviderURL {
+		apiURL = a.APIURL
+		scope = "experiment:" + a.ID + ":"
+	}
+	if p, ok := tenant.FromContext(ctx); ok {
+		apiKey = p.APIKey
+		if p.APIURL != "" {
+			apiURL = p.APIURL
+			scope = ""
+		}
+		scope = "tenant:" + p.Tenant + ":" + scope
+	}
+	return apiKey, apiURL, scope
+}
+
+// cacheKeyFor returns the Redis key of location's entry for a request: "weather:<location>",
+// prefixed with the request's upstream scope so that tenants and experiments never serve each
+// other's data, or the shared entries.
+func cacheKeyFor(ctx context.Context, location string) string {
+	_, _, scope := upstreamFor(ctx)
+	return scope + "weather:" + location
+}
+
+// tenantScope returns the prefix of the cache keys a request's forecast, geocode and alerts
+// lookups are stored under: "tenant:<name>:" on a tenant's own account, which pays for them, and
+// empty for the shared one. Those lookups are not part of provider_url experiments.
+func tenantScope(ctx context.Context) string {
+	if p, ok := tenant.FromContext(ctx); ok {
+		return "tenant:" + p.Tenant + ":"
+	}
+	return ""
+}
+
+// fetchUpstream calls the OpenWeatherMap API with the account and URL upstreamFor picks, or the
+// provider route chose. When previous carries validators, the request is made conditional and a
+// 304 response is reported as NotModified without parsing a body. Coordinates are sent as lat and
+// lon rather than q.
+func (r *weatherRepository) fetchUpstream(ctx context.Context, loc model.Location, previous *cacheEntry) (result *upstreamResult, err error) {
+	start := time.Now()
+	defer func() { recordUpstream(result, err, time.Since(start)) }()
+	if routedProvider(ctx) == providerMetNo {
+		return r.fetchMetNo(ctx, loc, previous)
+	}
+	logger(ctx).Debugw("Fetching from external API")
+	apiKey, apiURL, _ := upstreamFor(ctx)
 	if apiKey == "" {
 		return nil, ErrAPIKeyMissing
 	}
 
-	apiURL := config.GetOpenWeatherApiUrl()
-	url := fmt.Sprintf("%s?q=%s&appid=%s&units=metric", apiURL, location, apiKey)
-	resp, err := r.httpClient.Get(url)
+	query := "q=" + loc.Name
+	if loc.Coords != nil {
+		query = fmt.Sprintf("lat=%s&lon=%s", strconv.FormatFloat(loc.Coords.Lat, 'f', -1, 64), strconv.FormatFloat(loc.Coords.Lon, 'f', -1, 64))
+	}
+	url := fmt.Sprintf("%s?%s&appid=%s&units=metric", apiURL, query, apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, ErrExternalAPI
+	}
+	if previous != nil {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+
+	recordProviderCall(providerAccount(ctx))
+	upstream.RecordCall(ctx)
+	resp, err := r.httpClient.Do(req)
 	if err != nil {
 		return nil, ErrExternalAPI
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &CoolDownError{RetryAfter: r.startCoolDown(ctx, resp.Header.Get("Retry-After"))}
+	}
+	endCoolDownStreak(ctx)
+
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		return &upstreamResult{NotModified: true}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusNotFound {
 			// Try to parse the error message from the downstream response
@@ -138,30 +394,144 @@ func (r *weatherRepository) fetchFromExternalAPI(location string) (*model.Weathe
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		return nil, err
 	}
+	precision := config.GetTemperaturePrecision()
+	temperature, err := model.TemperatureFromNumber(data.Main.Temp, precision)
+	if err != nil {
+		return nil, err
+	}
 
 	weather := &model.WeatherResponse{
-		Location:    data.Name,
-		Temperature: data.Main.Temp,
-		Description: "",
-		Cached:      false,
+		Location:      data.Name,
+		Temperature:   temperature,
+		Description:   "",
+		Cached:        false,
+		Humidity:      reported(data.Main.Humidity),
+		Pressure:      reported(data.Main.Pressure),
+		WindSpeed:     data.Wind.Speed,
+		WindDirection: data.Wind.Deg,
+		FetchedAt:     cacheClock.Now(),
+	}
+	weather.Sunrise, weather.Sunset, weather.DayLength = sunTimes(&data)
+	weather.TimezoneOffset = data.Timezone
+	if data.Dt != 0 {
+		observed := time.Unix(data.Dt, 0).In(locationZone(&data))
+		weather.ObservedAt = &observed
+	}
+	if weather.FeelsLike, err = model.OptionalTemperatureFromNumber(data.Main.FeelsLike, precision); err != nil {
+		return nil, err
+	}
+	if weather.TempMin, err = model.OptionalTemperatureFromNumber(data.Main.TempMin, precision); err != nil {
+		return nil, err
+	}
+	if weather.TempMax, err = model.OptionalTemperatureFromNumber(data.Main.TempMax, precision); err != nil {
+		return nil, err
+	}
+	if weather.Location == "" {
+		// Coordinates out at sea have no nearby place name
+		weather.Location = loc.String()
 	}
 
 	if len(data.Weather) > 0 {
 		weather.Description = data.Weather[0].Description
+		weather.Condition = model.ConditionForOpenWeatherMapID(data.Weather[0].ID)
+		weather.IconURL = iconURL(data.Weather[0].Icon)
 	}
 
-	return weather, nil
+	return &upstreamResult{
+		Weather:      weather,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Coords:       data.Coord,
+	}, nil
 }
 
 // cacheWeather stores weather data in Redis cache
 func (r *weatherRepository) cacheWeather(ctx context.Context, location string, weather *model.WeatherResponse) {
-	cacheKey := "weather:" + location
+	r.storeEntry(ctx, location, &cacheEntry{WeatherResponse: *weather})
+}
+
+// storeEntry writes entry to Redis under the request's cache key, marking it fresh for the cache
+// expiration (or the location's adaptive TTL, when enabled), lengthened while the quota degradation
+// ladder says so. The key itself lives for an extra stale window so its validators remain available
+// for conditional revalidation. With a write queue, the entry is stored in the background; a write
+// slower than cache.timeouts.write is dropped.
+func (r *weatherRepository) storeEntry(ctx context.Context, location string, entry *cacheEntry) {
+	cacheKey := cacheKeyFor(ctx, location)
+
+	ttl := degradedTTL(ctx, r.entryTTL(ctx, location, &entry.WeatherResponse))
+	now := cacheClock.Now()
+	entry.Cached = false
+	entry.Stale = false
+	entry.FreshUntil = now.Add(ttl).Unix()
+	entry.Version = cacheEntryVersion
+	entry.StoredAt = now.UnixMilli()
+	entry.Provider = providerAccount(ctx)
+	b, err := marshalCacheValue(cacheKey, entry)
+	if err != nil {
+		logger(ctx).Errorw("Cache entry not stored", "cacheKey", cacheKey, "error", err)
+		return
+	}
+	if r.writer == nil {
+		writeCacheEntry(ctx, r.redisClient, cacheKey, b, ttl+config.GetCacheStaleTTL())
+	} else if !r.writer.enqueue(ctx, cacheKey, b, ttl+config.GetCacheStaleTTL()) {
+		logger(ctx).Warnw("Cache write queue full, cache entry not stored", "cacheKey", cacheKey)
+	}
+}
+
+// iconURL returns the URL of an OpenWeatherMap icon code, or "" without one.
+func iconURL(icon string) string {
+	if icon == "" {
+		return ""
+	}
+	return strings.ReplaceAll(config.GetOpenWeatherIconURL(), "{icon}", url.PathEscape(icon))
+}
 
-	if b, err := json.Marshal(weather); err == nil {
-		dur, err := time.ParseDuration(config.GetCacheExpiration())
-		if err != nil {
-			dur = 10 * time.Minute // fallback
+// reported converts a reading OpenWeatherMap sends as an integer, where 0 means it was left out:
+// neither humidity nor pressure is ever really 0.
+func reported(n int) *float64 {
+	if n == 0 {
+		return nil
+	}
+	f := float64(n)
+	return &f
+}
+
+// sunTimes returns the sunrise and sunset OpenWeatherMap reports, in the location's time zone,
+// and the day length between them in seconds. Either is nil when the sun does not rise or set.
+func sunTimes(data *model.OpenWeatherMapResponse) (sunrise, sunset *time.Time, dayLength int64) {
+	zone := locationZone(data)
+	at := func(unix int64) *time.Time {
+		if unix == 0 {
+			return nil
 		}
-		_ = r.redisClient.Set(ctx, cacheKey, b, dur).Err()
+		t := time.Unix(unix, 0).In(zone)
+		return &t
+	}
+	sunrise, sunset = at(data.Sys.Sunrise), at(data.Sys.Sunset)
+	if sunrise != nil && sunset != nil && sunset.After(*sunrise) {
+		dayLength = data.Sys.Sunset - data.Sys.Sunrise
+	}
+	return sunrise, sunset, dayLength
+}
+
+// locationZone returns the time zone OpenWeatherMap reports for the location, or UTC without one.
+func locationZone(data *model.OpenWeatherMapResponse) *time.Location {
+	if data.Timezone == nil {
+		return time.UTC
+	}
+	return time.FixedZone("", int(*data.Timezone))
+}
+
+// publishUpdate announces freshly fetched weather on the cache-update event stream.
+func publishUpdate(location string, weather *model.WeatherResponse) {
+	events.CacheUpdates.Publish(events.CacheUpdate{Location: location, Weather: *weather, At: time.Now()})
+}
+
+// cacheTTL returns the configured cache expiration
+func cacheTTL() time.Duration {
+	dur, err := time.ParseDuration(config.GetCacheExpiration())
+	if err != nil {
+		dur = 10 * time.Minute // fallback
 	}
+	return dur
 }
@@ -8,10 +8,16 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/upstream"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 )
 
 func TestNewWeatherRepository(t *testing.T) {
@@ -21,6 +27,82 @@ func TestNewWeatherRepository(t *testing.T) {
 	}
 }
 
+func TestGetWeather_RecordsUpstreamCalls(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	mr := miniredis.RunT(t)
+	client := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	repo := &weatherRepository{redisClient: client, httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 15.2}}`)),
+			Header:     make(http.Header),
+		}
+	})}
+
+	ctx, tracker := upstream.WithTracker(context.Background())
+	if _, err := repo.GetWeather(ctx, "London"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !tracker.Called() {
+		t.Error("Expected the provider call recorded on a miss")
+	}
+
+	ctx, tracker = upstream.WithTracker(context.Background())
+	if _, err := repo.GetWeather(ctx, "London"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tracker.Called() {
+		t.Error("Expected no provider call recorded on a cache hit")
+	}
+}
+
+func TestGetWeather_Concurrent(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	client, _ := newRedisClient(t)
+	writer := newCacheWriter(client, config.CacheWriteQueueConfig{Size: 16, Workers: 2, BatchSize: 4, FlushInterval: time.Millisecond})
+	repo := &weatherRepository{redisClient: client, writer: writer, httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "` + req.URL.Query().Get("q") + `", "main": {"temp": 15.2}}`)),
+			Header:     make(http.Header),
+		}
+	})}
+	locations := []string{"London", "Paris", "Oslo"}
+	policies := []CachePolicy{CachePolicyDefault, CachePolicyRefresh, CachePolicyCacheOnly}
+	t.Cleanup(func() {
+		for _, location := range locations {
+			deleteLocalOverride(location)
+		}
+	})
+
+	// Reads, refreshes, write-behind stores and override changes on the same keys at once
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 30; j++ {
+				location := locations[(i+j)%len(locations)]
+				ctx := WithCachePolicy(context.Background(), policies[j%len(policies)])
+				if i%4 == 0 {
+					ctx = WithWriteBehind(ctx)
+				}
+				weather, err := repo.GetWeather(ctx, location)
+				if err == nil && weather.Location != location {
+					t.Errorf("Expected weather for %s, got %s", location, weather.Location)
+				}
+				if i%6 == 0 {
+					setLocalOverride(&Override{Location: location, Weather: model.WeatherResponse{Temperature: 1}, ExpiresAt: time.Now().Add(time.Minute)})
+				} else if i%6 == 1 {
+					deleteLocalOverride(location)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 // --- Error Handling Tests ---
 
 func TestWeatherRepository_GetWeather_ErrorCases(t *testing.T) {
@@ -235,19 +317,19 @@ func TestModelMarshaling(t *testing.T) {
 	owmResponse := &model.OpenWeatherMapResponse{
 		Name: "London",
 		Main: struct {
-			Temp      float64 `json:"temp"`
-			FeelsLike float64 `json:"feels_like"`
-			TempMin   float64 `json:"temp_min"`
-			TempMax   float64 `json:"temp_max"`
-			Pressure  int     `json:"pressure"`
-			Humidity  int     `json:"humidity"`
-			SeaLevel  int     `json:"sea_level"`
-			GrndLevel int     `json:"grnd_level"`
+			Temp      json.Number `json:"temp"`
+			FeelsLike json.Number `json:"feels_like"`
+			TempMin   json.Number `json:"temp_min"`
+			TempMax   json.Number `json:"temp_max"`
+			Pressure  int         `json:"pressure"`
+			Humidity  int         `json:"humidity"`
+			SeaLevel  int         `json:"sea_level"`
+			GrndLevel int         `json:"grnd_level"`
 		}{
-			Temp:      15.2,
-			FeelsLike: 14.8,
-			TempMin:   12.0,
-			TempMax:   18.0,
+			Temp:      "15.2",
+			FeelsLike: "14.8",
+			TempMin:   "12.0",
+			TempMax:   "18.0",
 			Pressure:  1013,
 			Humidity:  65,
 			SeaLevel:  1013,
@@ -286,7 +368,7 @@ func TestModelMarshaling(t *testing.T) {
 		t.Errorf("Expected name %s, got %s", owmResponse.Name, unmarshaled.Name)
 	}
 	if unmarshaled.Main.Temp != owmResponse.Main.Temp {
-		t.Errorf("Expected temp %f, got %f", owmResponse.Main.Temp, unmarshaled.Main.Temp)
+		t.Errorf("Expected temp %s, got %s", owmResponse.Main.Temp, unmarshaled.Main.Temp)
 	}
 	if len(unmarshaled.Weather) != len(owmResponse.Weather) {
 		t.Errorf("Expected %d weather items, got %d", len(owmResponse.Weather), len(unmarshaled.Weather))
@@ -355,7 +437,7 @@ func TestWeatherRepository_FetchFromExternalAPIFunction(t *testing.T) {
 	location := "TestExternalAPILocation"
 
 	if r, ok := repo.(*weatherRepository); ok {
-		_, err := r.fetchFromExternalAPI(location)
+		_, err := r.fetchFromExternalAPI(context.Background(), location)
 		if err == nil {
 			t.Error("Expected error for external API call")
 		} else {
@@ -464,3 +546,156 @@ func TestWeatherRepository_ExternalAPI_404_CityNotFound_MissingAPIKey(t *testing
 		t.Errorf("Expected error message 'API key missing', got '%s'", err.Error())
 	}
 }
+
+func TestGetWeatherAt_Coordinates(t *testing.T) {
+	repo, reader, provider := newBatchRepository(t)
+	provider.Places = map[string]model.Coordinates{"Jakarta": {Lat: -6.2088, Lon: 106.8456}, "Bandung": {Lat: -6.9175, Lon: 107.6191}}
+	ctx := context.Background()
+
+	weather, err := repo.GetWeatherAt(ctx, model.LocationAt(-6.2, 106.8))
+	if err != nil {
+		t.Fatalf("GetWeatherAt: %v", err)
+	}
+	if weather.Location != "Jakarta" || weather.Cached {
+		t.Errorf("Expected fresh weather for Jakarta, got %+v", weather)
+	}
+	if n := reader.Exists(ctx, "weather:coord:-6.20,106.80").Val(); n != 1 {
+		t.Errorf("Expected the entry cached under the rounded coordinates")
+	}
+
+	// Coordinates rounding to the same point share the entry
+	weather, err = repo.GetWeatherAt(ctx, model.LocationAt(-6.2031, 106.7964))
+	if err != nil || !weather.Cached {
+		t.Errorf("Expected a cache hit, got %+v, %v", weather, err)
+	}
+	// and never that of the place name
+	if weather, err := repo.GetWeather(ctx, "Jakarta"); err != nil || weather.Cached {
+		t.Errorf("Expected a miss by name, got %+v, %v", weather, err)
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", provider.Calls())
+	}
+}
+
+func TestFetchUpstream_CoordinatesQuery(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	var query string
+	repo := &weatherRepository{httpClient: &http.Client{
+		Transport: RoundTripperFunc(func(req *http.Request) *http.Response {
+			query = req.URL.RawQuery
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"main":{"temp":27.5},"weather":[{"description":"haze"}]}`)),
+				Header:     make(http.Header),
+			}
+		}),
+	}}
+
+	result, err := repo.fetchUpstream(context.Background(), model.LocationAt(-6.21, 106.85), nil)
+	if err != nil {
+		t.Fatalf("fetchUpstream: %v", err)
+	}
+	if !strings.HasPrefix(query, "lat=-6.21&lon=106.85&") {
+		t.Errorf("Expected a lat/lon query, got %s", query)
+	}
+	// With no place name, the coordinates stand in for it
+	if result.Weather.Location != "-6.21,106.85" {
+		t.Errorf("Expected the coordinates as location, got %q", result.Weather.Location)
+	}
+	// Details the provider left out stay unset
+	if w := result.Weather; w.FeelsLike != nil || w.Humidity != nil || w.WindSpeed != nil {
+		t.Errorf("Expected no details, got %+v", w)
+	}
+}
+
+func TestFetchUpstream_Details(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	body := `{"name":"Oslo","main":{"temp":-3.456,"feels_like":-8.514,"temp_min":-4.2,"temp_max":-2.1,"pressure":1003,"humidity":86},` +
+		`"wind":{"speed":0,"deg":270},"weather":[{"description":"light snow"}]}`
+	repo := &weatherRepository{httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	})}
+
+	result, err := repo.fetchUpstream(context.Background(), model.Location{Name: "Oslo"}, nil)
+	if err != nil {
+		t.Fatalf("fetchUpstream: %v", err)
+	}
+	w := result.Weather
+	for name, tc := range map[string]struct {
+		got  *float64
+		want float64
+	}{
+		"feels_like":     {w.FeelsLike, -8.51},
+		"temp_min":       {w.TempMin, -4.2},
+		"temp_max":       {w.TempMax, -2.1},
+		"humidity":       {w.Humidity, 86},
+		"pressure":       {w.Pressure, 1003},
+		"wind_speed":     {w.WindSpeed, 0},
+		"wind_direction": {w.WindDirection, 270},
+	} {
+		if tc.got == nil || *tc.got != tc.want {
+			t.Errorf("Expected %s %v, got %v", name, tc.want, tc.got)
+		}
+	}
+	if w.Temperature != -3.46 {
+		t.Errorf("Expected the temperature rounded to -3.46, got %v", w.Temperature)
+	}
+	if w.Sunrise != nil || w.Sunset != nil || w.DayLength != 0 || w.TimezoneOffset != nil || w.ObservedAt != nil {
+		t.Errorf("Expected no sun times or time zone when not reported, got %+v", w)
+	}
+}
+
+func TestFetchUpstream_SunAndLocalTimes(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	body := `{"name":"Oslo","main":{"temp":-3},"dt":1736935200,"timezone":3600,"sys":{"country":"NO","sunrise":1736928300,"sunset":1736952000}}`
+	repo := &weatherRepository{httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	})}
+
+	result, err := repo.fetchUpstream(context.Background(), model.Location{Name: "Oslo"}, nil)
+	if err != nil {
+		t.Fatalf("fetchUpstream: %v", err)
+	}
+	w := result.Weather
+	if w.Sunrise == nil || w.Sunrise.Format(time.RFC3339) != "2025-01-15T09:05:00+01:00" {
+		t.Errorf("Expected sunrise in local time, got %v", w.Sunrise)
+	}
+	if w.Sunset == nil || w.Sunset.Format(time.RFC3339) != "2025-01-15T15:40:00+01:00" {
+		t.Errorf("Expected sunset in local time, got %v", w.Sunset)
+	}
+	if w.DayLength != 23700 {
+		t.Errorf("Expected a day of 6h35m, got %ds", w.DayLength)
+	}
+	if w.TimezoneOffset == nil || *w.TimezoneOffset != 3600 {
+		t.Errorf("Expected a timezone offset of 3600, got %v", w.TimezoneOffset)
+	}
+	if w.ObservedAt == nil || w.ObservedAt.Format(time.RFC3339) != "2025-01-15T11:00:00+01:00" {
+		t.Errorf("Expected the observation time in local time, got %v", w.ObservedAt)
+	}
+}
+
+func TestFetchUpstream_ConditionAndIcon(t *testing.T) {
+	t.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	body := `{"name":"London","main":{"temp":11},"weather":[{"id":501,"main":"Rain","description":"moderate rain","icon":"10n"}]}`
+	repo := &weatherRepository{httpClient: newMockHTTPClient(func(*http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	})}
+
+	result, err := repo.fetchUpstream(context.Background(), model.Location{Name: "London"}, nil)
+	if err != nil {
+		t.Fatalf("fetchUpstream: %v", err)
+	}
+	if w := result.Weather; w.Condition != model.ConditionRain || w.IconURL != "https://openweathermap.org/img/wn/10n@2x.png" {
+		t.Errorf("Expected a rain condition and night icon, got %q %q", w.Condition, w.IconURL)
+	}
+
+	viper.Set("openweathermap.icon_url", "https://cdn.example.com/icons/{icon}.svg")
+	t.Cleanup(func() { viper.Set("openweathermap.icon_url", nil) })
+	result, err = repo.fetchUpstream(context.Background(), model.Location{Name: "London"}, nil)
+	if err != nil {
+		t.Fatalf("fetchUpstream: %v", err)
+	}
+	if got := result.Weather.IconURL; got != "https://cdn.example.com/icons/10n.svg" {
+		t.Errorf("Expected the configured icon URL, got %q", got)
+	}
+}
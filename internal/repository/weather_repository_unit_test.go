@@ -12,8 +12,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fakhrymubarak/weather-api-redis/internal/experiment"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/tenant"
 	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 )
 
 type mockRedisClient struct {
@@ -88,15 +91,15 @@ func TestGetWeather_CacheMiss_APISuccess(t *testing.T) {
 	mockResp := model.OpenWeatherMapResponse{
 		Name: "London",
 		Main: struct {
-			Temp      float64 `json:"temp"`
-			FeelsLike float64 `json:"feels_like"`
-			TempMin   float64 `json:"temp_min"`
-			TempMax   float64 `json:"temp_max"`
-			Pressure  int     `json:"pressure"`
-			Humidity  int     `json:"humidity"`
-			SeaLevel  int     `json:"sea_level"`
-			GrndLevel int     `json:"grnd_level"`
-		}{Temp: 21.5},
+			Temp      json.Number `json:"temp"`
+			FeelsLike json.Number `json:"feels_like"`
+			TempMin   json.Number `json:"temp_min"`
+			TempMax   json.Number `json:"temp_max"`
+			Pressure  int         `json:"pressure"`
+			Humidity  int         `json:"humidity"`
+			SeaLevel  int         `json:"sea_level"`
+			GrndLevel int         `json:"grnd_level"`
+		}{Temp: "21.5"},
 		Weather: []struct {
 			ID          int    `json:"id"`
 			Main        string `json:"main"`
@@ -234,3 +237,347 @@ func TestGetFromCache_UnmarshalError(t *testing.T) {
 		t.Fatalf("Expected error, got nil")
 	}
 }
+
+func TestGetWeather_StaleEntry_NotModified(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+
+	stale := cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 12.5, Description: "rain"},
+		ETag:            `"abc123"`,
+		LastModified:    "Mon, 02 Jan 2006 15:04:05 GMT",
+		FreshUntil:      time.Now().Add(-time.Minute).Unix(),
+	}
+	b, _ := json.Marshal(stale)
+
+	var stored []byte
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult(string(b), nil)
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			stored = value.([]byte)
+			return redisv9.NewStatusResult("OK", nil)
+		},
+	}
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		if req.Header.Get("If-None-Match") != `"abc123"` {
+			t.Errorf("Expected If-None-Match header, got %q", req.Header.Get("If-None-Match"))
+		}
+		if req.Header.Get("If-Modified-Since") == "" {
+			t.Error("Expected If-Modified-Since header")
+		}
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Body:       io.NopCloser(strings.NewReader("")),
+			Header:     make(http.Header),
+		}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+
+	weather, err := repo.GetWeather(context.Background(), "London")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !weather.Cached || weather.Description != "rain" {
+		t.Errorf("Expected revalidated cached weather, got %+v", weather)
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(stored, &entry); err != nil {
+		t.Fatalf("Expected entry to be re-stored, got %v", err)
+	}
+	if !entry.isFresh(time.Now()) || entry.ETag != `"abc123"` {
+		t.Errorf("Expected refreshed entry with validators, got %+v", entry)
+	}
+}
+
+func TestGetWeather_CacheMiss_StoresValidators(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+
+	var stored []byte
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult("", errors.New("cache miss"))
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			stored = value.([]byte)
+			return redisv9.NewStatusResult("OK", nil)
+		},
+	}
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		if req.Header.Get("If-None-Match") != "" {
+			t.Error("Expected an unconditional request on a cold miss")
+		}
+		header := make(http.Header)
+		header.Set("ETag", `"v1"`)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 20}, "weather": [{"description": "clear sky"}]}`)),
+			Header:     header,
+		}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+
+	if _, err := repo.GetWeather(context.Background(), "London"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(stored, &entry); err != nil {
+		t.Fatalf("Expected entry to be stored, got %v", err)
+	}
+	if entry.ETag != `"v1"` || entry.Location != "London" {
+		t.Errorf("Expected stored ETag and weather, got %+v", entry)
+	}
+}
+
+func TestGetFromCache_StaleEntry(t *testing.T) {
+	b, _ := json.Marshal(cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "London"},
+		FreshUntil:      time.Now().Add(-time.Second).Unix(),
+	})
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult(string(b), nil)
+		},
+	}
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: http.DefaultClient}
+	if _, err := repo.getFromCache(context.Background(), "London"); !errors.Is(err, ErrCacheStale) {
+		t.Errorf("Expected ErrCacheStale, got %v", err)
+	}
+}
+
+func TestGetWeather_RefreshPolicy_BypassesCache(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+
+	setCalled := false
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			if key == coolDownKey(providerOpenWeatherMap) {
+				return redisv9.NewStringResult("", redisv9.Nil)
+			}
+			t.Error("Expected the cache read to be skipped on refresh")
+			return redisv9.NewStringResult(`{"location":"London","temperature":1}`, nil)
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			setCalled = true
+			return redisv9.NewStatusResult("OK", nil)
+		},
+	}
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 21}, "weather": [{"description": "clear sky"}]}`)),
+			Header:     make(http.Header),
+		}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+
+	ctx := WithCachePolicy(context.Background(), CachePolicyRefresh)
+	weather, err := repo.GetWeather(ctx, "London")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if weather.Cached || weather.Temperature != 21 {
+		t.Errorf("Expected fresh upstream data, got %+v", weather)
+	}
+	if !setCalled {
+		t.Error("Expected the cache entry to be overwritten")
+	}
+}
+
+func TestCachePolicyFrom_Default(t *testing.T) {
+	if CachePolicyFrom(context.Background()) != CachePolicyDefault {
+		t.Error("Expected default cache policy for a bare context")
+	}
+}
+
+func TestGetWeather_CacheOnlyPolicy(t *testing.T) {
+	stale, _ := json.Marshal(cacheEntry{
+		WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 9},
+		FreshUntil:      time.Now().Add(-time.Minute).Unix(),
+	})
+	entries := map[string]string{"weather:London": string(stale)}
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			if v, ok := entries[key]; ok {
+				return redisv9.NewStringResult(v, nil)
+			}
+			return redisv9.NewStringResult("", redisv9.Nil)
+		},
+	}
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		t.Error("Expected no upstream call in cache-only mode")
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}")), Header: make(http.Header)}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+	ctx := WithCachePolicy(context.Background(), CachePolicyCacheOnly)
+
+	weather, err := repo.GetWeather(ctx, "London")
+	if err != nil {
+		t.Fatalf("Expected stale data, got error %v", err)
+	}
+	if !weather.Cached || !weather.Stale {
+		t.Errorf("Expected cached stale weather, got %+v", weather)
+	}
+
+	if _, err := repo.GetWeather(ctx, "Paris"); !errors.Is(err, ErrCacheOnlyMiss) {
+		t.Errorf("Expected ErrCacheOnlyMiss, got %v", err)
+	}
+}
+
+func TestCachePolicyFrom_OfflineMode(t *testing.T) {
+	viper.Set("offline_mode", true)
+	defer viper.Set("offline_mode", nil)
+
+	ctx := WithCachePolicy(context.Background(), CachePolicyRefresh)
+	if CachePolicyFrom(ctx) != CachePolicyCacheOnly {
+		t.Error("Expected offline mode to force the cache-only policy")
+	}
+}
+
+func TestGetWeather_RoundsTemperatures(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "test-key")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+	viper.Set("server.temperature_precision", 1)
+	defer viper.Set("server.temperature_precision", nil)
+
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult("", errors.New("cache miss"))
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			return redisv9.NewStatusResult("OK", nil)
+		},
+	}
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 15.199999999}, "weather": [{"description": "clear sky"}]}`)),
+		}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+	weather, err := repo.GetWeather(context.Background(), "London")
+	if err != nil || weather.Temperature != 15.2 {
+		t.Errorf("Expected 15.2, got %+v, %v", weather, err)
+	}
+
+	// Cached entries are served at the current precision
+	b, _ := json.Marshal(cacheEntry{WeatherResponse: model.WeatherResponse{Location: "London", Temperature: 15.26}})
+	mockRedis.getFunc = func(ctx context.Context, key string) *redisv9.StringCmd {
+		return redisv9.NewStringResult(string(b), nil)
+	}
+	if cached, err := repo.getFromCache(context.Background(), "London"); err != nil || cached.Temperature != 15.3 {
+		t.Errorf("Expected the cached temperature rounded to 15.3, got %+v, %v", cached, err)
+	}
+}
+
+func TestGetWeather_TenantProvider(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "shared-key")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+	pendingCostsMu.Lock()
+	pendingCosts = map[costKey]*costCounts{}
+	pendingCostsMu.Unlock()
+
+	mockRedis := &mockRedisClient{
+		getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+			return redisv9.NewStringResult("", errors.New("cache miss"))
+		},
+		setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+			return redisv9.NewStatusResult("OK", nil)
+		},
+	}
+	var requested string
+	mockHTTP := newMockHTTPClient(func(req *http.Request) *http.Response {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 15.2}}`)),
+		}
+	})
+	repo := &weatherRepository{redisClient: mockRedis, httpClient: mockHTTP}
+	ctx := tenant.WithProvider(context.Background(), tenant.Provider{
+		Tenant: "acme", Name: "openweathermap", APIKey: "acme-key", APIURL: "https://owm.example.com/weather",
+	})
+	if _, err := repo.GetWeather(ctx, "London"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(requested, "https://owm.example.com/weather?") || !strings.Contains(requested, "appid=acme-key") {
+		t.Errorf("Expected the tenant's URL and key, got %s", requested)
+	}
+	day := time.Now().UTC().Format(costDayFormat)
+	if c := pendingCosts[costKey{provider: "openweathermap:acme", day: day}]; c == nil || c.calls != 1 {
+		t.Errorf("Expected the call billed to the tenant account, got %+v", pendingCosts)
+	}
+	if _, ok := pendingCosts[costKey{provider: providerOpenWeatherMap, day: day}]; ok {
+		t.Error("Expected nothing billed to the shared account")
+	}
+
+	ctx = tenant.WithProvider(context.Background(), tenant.Provider{Tenant: "acme", Name: "openweathermap"})
+	if _, err := repo.GetWeather(ctx, "London"); !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected a tenant without a key not to fall back to the shared key, got %v", err)
+	}
+}
+
+func TestGetWeather_ProviderURLExperiment(t *testing.T) {
+	os.Setenv("OPENWEATHERMAP_API_KEY", "testkey")
+	defer os.Unsetenv("OPENWEATHERMAP_API_KEY")
+	var host, stored string
+	repo := &weatherRepository{
+		redisClient: &mockRedisClient{
+			getFunc: func(ctx context.Context, key string) *redisv9.StringCmd {
+				return redisv9.NewStringResult("", redisv9.Nil)
+			},
+			setFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) *redisv9.StatusCmd {
+				stored = key
+				return redisv9.NewStatusResult("OK", nil)
+			},
+		},
+		httpClient: newMockHTTPClient(func(req *http.Request) *http.Response {
+			host = req.URL.Host
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "London", "main": {"temp": 21}, "weather": [{"description": "clear sky"}]}`)),
+				Header:     make(http.Header),
+			}
+		}),
+	}
+
+	ctx := experiment.WithAssignment(context.Background(), experiment.Assignment{ID: "edge", Variant: experiment.ProviderURL, APIURL: "https://edge.example.com/weather"})
+	if _, err := repo.GetWeather(ctx, "London"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host != "edge.example.com" {
+		t.Errorf("Expected the experiment's provider URL, got host %q", host)
+	}
+	if stored != "experiment:edge:weather:London" {
+		t.Errorf("Expected the variant cached apart from the shared entry, got %q", stored)
+	}
+}
+
+func TestCacheKeyFor(t *testing.T) {
+	background := context.Background()
+	edge := experiment.WithAssignment(background, experiment.Assignment{ID: "edge", Variant: experiment.ProviderURL, APIURL: "https://edge.example.com/weather"})
+	acme := tenant.Provider{Tenant: "acme", Name: "openweathermap", APIKey: "acme-key"}
+	acmeOwnURL := acme
+	acmeOwnURL.APIURL = "https://owm.example.com/weather"
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"shared", background, "weather:London"},
+		{"other experiment", experiment.WithAssignment(background, experiment.Assignment{ID: "ttl", Variant: experiment.AdaptiveTTL}), "weather:London"},
+		{"provider_url experiment", edge, "experiment:edge:weather:London"},
+		{"tenant", tenant.WithProvider(background, acme), "tenant:acme:weather:London"},
+		{"tenant in experiment", tenant.WithProvider(edge, acme), "tenant:acme:experiment:edge:weather:London"},
+		{"tenant with its own URL", tenant.WithProvider(edge, acmeOwnURL), "tenant:acme:weather:London"},
+	}
+	for _, tt := range tests {
+		if got := cacheKeyFor(tt.ctx, "London"); got != tt.want {
+			t.Errorf("%s: cacheKeyFor = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
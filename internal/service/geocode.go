@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// Disambiguate returns a pre-fetch hook resolving ambiguous locations, such as "ja", to the
+// geocoder's best match, e.g. "Jakarta,ID". Locations with a country code, and cities the
+// embedded dataset knows, are left alone. When geocoding fails or finds nothing, the location is
+// looked up as given.
+func Disambiguate(geocoder repository.GeocodeRepository) PreFetchHook {
+	return func(ctx context.Context, location string) (string, error) {
+		if strings.Contains(location, ",") {
+			return location, nil
+		}
+		if _, ok := geodata.Lookup(location); ok {
+			return location, nil
+		}
+		geocode, err := geocoder.Geocode(ctx, location)
+		if err != nil || len(geocode.Candidates) == 0 {
+			return location, nil
+		}
+		return geocode.Candidates[0].Location(), nil
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// mockGeocoder answers every query with candidates, or err.
+type mockGeocoder struct {
+	candidates []model.GeocodeCandidate
+	err        error
+	queries    []string
+}
+
+func (m *mockGeocoder) Geocode(_ context.Context, query string) (*model.GeocodeResponse, error) {
+	m.queries = append(m.queries, query)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.GeocodeResponse{Query: query, Candidates: m.candidates}, nil
+}
+
+func TestDisambiguate(t *testing.T) {
+	geocoder := &mockGeocoder{candidates: []model.GeocodeCandidate{{Name: "Jakarta", Country: "ID"}, {Name: "Jaén", Country: "ES"}}}
+	repo := &locationRepository{}
+	svc := New(WithRepository(repo), WithPreFetch(Disambiguate(geocoder)))
+	ctx := context.Background()
+
+	if _, err := svc.GetWeather(ctx, "ja"); err != nil || repo.location != "Jakarta,ID" {
+		t.Errorf("Expected ja resolved to Jakarta,ID, got %q, %v", repo.location, err)
+	}
+	// Known cities and explicit countries are not geocoded
+	for _, location := range []string{"London", "Springfield,US"} {
+		if _, err := svc.GetWeather(ctx, location); err != nil || repo.location != location {
+			t.Errorf("Expected %s looked up as given, got %q, %v", location, repo.location, err)
+		}
+	}
+	if len(geocoder.queries) != 1 {
+		t.Errorf("Expected a single geocode, got %v", geocoder.queries)
+	}
+
+	// Without a match, or without the geocoder, the location is looked up as given
+	for _, g := range []*mockGeocoder{{}, {err: errors.New("provider down")}} {
+		svc := New(WithRepository(repo), WithPreFetch(Disambiguate(g)))
+		if _, err := svc.GetWeather(ctx, "ja"); err != nil || repo.location != "ja" {
+			t.Errorf("Expected ja looked up as given, got %q, %v", repo.location, err)
+		}
+	}
+}
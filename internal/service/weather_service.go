@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/fanout"
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
 	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
 )
@@ -11,29 +16,221 @@ type WeatherServiceInterface interface {
 	GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error)
 }
 
+// ErrRejected is wrapped by hooks to refuse a request by policy, e.g. a blocked region. The handler
+// answers it with 403 and the error's message.
+var ErrRejected = errors.New("request rejected")
+
+// PreFetchHook runs before weather is fetched, with the canonical location. It returns the location
+// to fetch, which it may rewrite, or an error to stop the request.
+type PreFetchHook func(ctx context.Context, location string) (string, error)
+
+// PostFetchHook runs on fetched weather before it is returned. It may modify weather, e.g. to scrub
+// fields, or return an error to withhold it.
+type PostFetchHook func(ctx context.Context, location string, weather *model.WeatherResponse) error
+
 // WeatherService handles weather-related business logic
 type WeatherService struct {
 	WeatherRepo repository.WeatherRepository
+	// PreFetch and PostFetch hooks run in order on every request.
+	PreFetch  []PreFetchHook
+	PostFetch []PostFetchHook
+}
+
+// Option configures a WeatherService built by New.
+type Option func(*WeatherService)
+
+// WithRepository sets the repository weather is fetched from.
+func WithRepository(repo repository.WeatherRepository) Option {
+	return func(s *WeatherService) { s.WeatherRepo = repo }
+}
+
+// WithPreFetch adds hooks that run before weather is fetched.
+func WithPreFetch(hooks ...PreFetchHook) Option {
+	return func(s *WeatherService) { s.PreFetch = append(s.PreFetch, hooks...) }
+}
+
+// WithPostFetch adds hooks that run on fetched weather.
+func WithPostFetch(hooks ...PostFetchHook) Option {
+	return func(s *WeatherService) { s.PostFetch = append(s.PostFetch, hooks...) }
+}
+
+// New creates a weather service configured by opts. The repository defaults to
+// repository.NewWeatherRepository.
+func New(opts ...Option) *WeatherService {
+	s := &WeatherService{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.WeatherRepo == nil {
+		s.WeatherRepo = repository.NewWeatherRepository()
+	}
+	return s
 }
 
 // Ensure the WeatherService implements WeatherServiceInterface
 var _ WeatherServiceInterface = (*WeatherService)(nil)
 
-// NewWeatherService creates a new weather service instance
+// NewWeatherService creates a new weather service instance. With geocode.disambiguate, ambiguous
+// locations are resolved by geocoding first; see Disambiguate.
 func NewWeatherService(repo ...repository.WeatherRepository) WeatherServiceInterface {
 	var weatherRepo repository.WeatherRepository
 	if len(repo) > 0 && repo[0] != nil {
 		weatherRepo = repo[0]
-	} else {
-		weatherRepo = repository.NewWeatherRepository()
 	}
-	return &WeatherService{
-		WeatherRepo: weatherRepo,
+	opts := []Option{WithRepository(weatherRepo)}
+	if config.GetGeocodeConfig().Disambiguate {
+		opts = append(opts, WithPreFetch(Disambiguate(repository.NewGeocodeRepository())))
 	}
+	return New(opts...)
 }
 
-// GetWeather retrieves weather data for a given location
+// GetWeather retrieves weather data for a given location. The location is validated and
+// canonicalized against the embedded city dataset first, so nonsense inputs fail with
+// geodata.ErrInvalidLocation without spending an upstream call. Pre-fetch hooks then see the
+// canonical location, and post-fetch hooks the weather about to be returned.
 func (s *WeatherService) GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error) {
-	// Business logic can be added here (validation, transformation, etc.)
-	return s.WeatherRepo.GetWeather(ctx, location)
+	location, err := geodata.Canonicalize(location, config.IsGeodataStrict())
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range s.PreFetch {
+		if location, err = hook(ctx, location); err != nil {
+			return nil, err
+		}
+	}
+	weather, err := s.WeatherRepo.GetWeather(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range s.PostFetch {
+		if err := hook(ctx, location, weather); err != nil {
+			return nil, err
+		}
+	}
+	return weather, nil
+}
+
+// ErrCoordinatesUnsupported is returned for coordinates when the repository only looks up place
+// names.
+var ErrCoordinatesUnsupported = errors.New("coordinates are not supported")
+
+// LocationWeatherService looks up weather by place name or coordinates.
+type LocationWeatherService interface {
+	GetWeatherAt(ctx context.Context, loc model.Location) (*model.WeatherResponse, error)
+}
+
+var _ LocationWeatherService = (*WeatherService)(nil)
+
+// GetWeatherAt retrieves weather data for a place name, as GetWeather does, or for coordinates.
+// Coordinates are checked to be on Earth instead of canonicalized, and the hooks see them as
+// their key, e.g. "coord:-6.21,106.85"; a pre-fetch hook rewriting it makes the lookup one by name.
+func (s *WeatherService) GetWeatherAt(ctx context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	if loc.Coords == nil {
+		return s.GetWeather(ctx, loc.Name)
+	}
+	if err := loc.Coords.Validate(); err != nil {
+		return nil, err
+	}
+	location := loc.Key()
+	var err error
+	for _, hook := range s.PreFetch {
+		if location, err = hook(ctx, location); err != nil {
+			return nil, err
+		}
+	}
+	var weather *model.WeatherResponse
+	if location != loc.Key() {
+		weather, err = s.WeatherRepo.GetWeather(ctx, location)
+	} else if repo, ok := s.WeatherRepo.(repository.LocationRepository); ok {
+		weather, err = repo.GetWeatherAt(ctx, loc)
+	} else {
+		err = ErrCoordinatesUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range s.PostFetch {
+		if err := hook(ctx, location, weather); err != nil {
+			return nil, err
+		}
+	}
+	return weather, nil
+}
+
+// BatchResult is the outcome of one location of a batch: its weather, or why there is none.
+type BatchResult struct {
+	// Location is the location as requested.
+	Location string
+	Weather  *model.WeatherResponse
+	Err      error
+}
+
+// BatchWeatherService looks up the weather of several locations in one call.
+type BatchWeatherService interface {
+	GetWeatherBatch(ctx context.Context, locations []string) []BatchResult
+}
+
+var _ BatchWeatherService = (*WeatherService)(nil)
+
+// GetWeatherBatch returns the weather of each location, in order, validated and passed through
+// the hooks as by GetWeather. Locations naming the same city are looked up once. A repository
+// implementing repository.BatchRepository reads every cached location in one round trip;
+// otherwise the locations are looked up one by one. Either way, at most batch.concurrency
+// lookups run at once.
+func (s *WeatherService) GetWeatherBatch(ctx context.Context, locations []string) []BatchResult {
+	results := make([]BatchResult, len(locations))
+	var unique []string
+	// owners lists the results each unique location answers
+	var owners [][]int
+	index := make(map[string]int)
+	strict := config.IsGeodataStrict()
+	for i, requested := range locations {
+		results[i].Location = requested
+		location, err := geodata.Canonicalize(requested, strict)
+		for _, hook := range s.PreFetch {
+			if err != nil {
+				break
+			}
+			location, err = hook(ctx, location)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		j, ok := index[location]
+		if !ok {
+			j = len(unique)
+			index[location] = j
+			unique = append(unique, location)
+			owners = append(owners, nil)
+		}
+		owners[j] = append(owners[j], i)
+	}
+
+	for j, r := range s.fetchBatch(ctx, unique, config.GetBatchConfig().Concurrency) {
+		for _, hook := range s.PostFetch {
+			if r.Err != nil {
+				break
+			}
+			if err := hook(ctx, unique[j], r.Weather); err != nil {
+				r = repository.BatchResult{Err: err}
+			}
+		}
+		for _, i := range owners[j] {
+			results[i].Weather, results[i].Err = r.Weather, r.Err
+		}
+	}
+	return results
+}
+
+// fetchBatch looks up locations in the repository, at most concurrency at a time.
+func (s *WeatherService) fetchBatch(ctx context.Context, locations []string, concurrency int) []repository.BatchResult {
+	if batch, ok := s.WeatherRepo.(repository.BatchRepository); ok {
+		return batch.GetWeatherBatch(ctx, locations, concurrency)
+	}
+	results := make([]repository.BatchResult, len(locations))
+	for i, res := range fanout.Map(ctx, locations, fanout.Options{Limit: concurrency}, s.WeatherRepo.GetWeather) {
+		results[i] = repository.BatchResult{Weather: res.Value, Err: res.Err}
+	}
+	return results
 }
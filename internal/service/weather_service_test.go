@@ -2,10 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/fakhrymubarak/weather-api-redis/internal/geodata"
 	"github.com/fakhrymubarak/weather-api-redis/internal/model"
 	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/spf13/viper"
 )
 
 // Mock repository for testing
@@ -118,14 +123,40 @@ func TestWeatherService_GetWeather_EmptyLocation(t *testing.T) {
 	service := &WeatherService{WeatherRepo: mockRepo}
 	ctx := context.Background()
 	result, err := service.GetWeather(ctx, "")
-	if err != nil {
-		t.Errorf("Expected no error for empty location, got: %v", err)
+	if !errors.Is(err, geodata.ErrInvalidLocation) {
+		t.Errorf("Expected ErrInvalidLocation for empty location, got: %v", err)
 	}
-	if result == nil {
-		t.Error("Expected result for empty location, got nil")
+	if result != nil {
+		t.Errorf("Expected no result for empty location, got %+v", result)
+	}
+}
+
+func TestWeatherService_GetWeather_Canonicalizes(t *testing.T) {
+	mockRepo := &recordingWeatherRepository{}
+	service := &WeatherService{WeatherRepo: mockRepo}
+
+	if _, err := service.GetWeather(context.Background(), "  new york "); err != nil || mockRepo.location != "New York" {
+		t.Errorf("Expected the canonical name passed to the repository, got %q, %v", mockRepo.location, err)
+	}
+
+	viper.Set("geodata.strict", true)
+	defer viper.Set("geodata.strict", nil)
+	mockRepo.location = ""
+	if _, err := service.GetWeather(context.Background(), "Atlantis"); !errors.Is(err, geodata.ErrInvalidLocation) || mockRepo.location != "" {
+		t.Errorf("Expected unknown cities rejected in strict mode without a repository call, got %v", err)
 	}
 }
 
+// recordingWeatherRepository records the location it was asked for.
+type recordingWeatherRepository struct {
+	location string
+}
+
+func (m *recordingWeatherRepository) GetWeather(_ context.Context, location string) (*model.WeatherResponse, error) {
+	m.location = location
+	return &model.WeatherResponse{Location: location}, nil
+}
+
 func TestWeatherService_GetWeather_NilContext(t *testing.T) {
 	mockRepo := &mockWeatherRepository{shouldError: false, mockData: &model.WeatherResponse{Location: "London", Temperature: 15.2, Description: "clear sky", Cached: false}}
 	service := &WeatherService{WeatherRepo: mockRepo}
@@ -137,3 +168,192 @@ func TestWeatherService_GetWeather_NilContext(t *testing.T) {
 		t.Error("Expected result for nil context, got nil")
 	}
 }
+
+type locationRepository struct {
+	location string
+}
+
+func (m *locationRepository) GetWeather(_ context.Context, location string) (*model.WeatherResponse, error) {
+	m.location = location
+	return &model.WeatherResponse{Location: location, Temperature: 15.2, Description: "clear sky"}, nil
+}
+
+func TestNew_Hooks(t *testing.T) {
+	repo := &locationRepository{}
+	blocked := func(_ context.Context, location string) (string, error) {
+		if location == "Paris" {
+			return "", fmt.Errorf("%w: %s is blocked", ErrRejected, location)
+		}
+		return location, nil
+	}
+	alias := func(_ context.Context, location string) (string, error) {
+		if location == "Londres" {
+			return "London", nil
+		}
+		return location, nil
+	}
+	scrub := func(_ context.Context, _ string, weather *model.WeatherResponse) error {
+		weather.Description = ""
+		return nil
+	}
+	svc := New(WithRepository(repo), WithPreFetch(blocked, alias), WithPostFetch(scrub))
+
+	weather, err := svc.GetWeather(context.Background(), "Londres")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if repo.location != "London" || weather.Description != "" {
+		t.Errorf("Expected the rewritten location and a scrubbed description, got %q %+v", repo.location, weather)
+	}
+
+	repo.location = ""
+	if _, err := svc.GetWeather(context.Background(), "Paris"); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected ErrRejected, got %v", err)
+	}
+	if repo.location != "" {
+		t.Error("Expected a rejected request never to reach the repository")
+	}
+
+	withhold := errors.New("withheld")
+	svc = New(WithRepository(repo), WithPostFetch(func(context.Context, string, *model.WeatherResponse) error { return withhold }))
+	if weather, err := svc.GetWeather(context.Background(), "London"); !errors.Is(err, withhold) || weather != nil {
+		t.Errorf("Expected the post-fetch error and no weather, got %+v %v", weather, err)
+	}
+}
+
+// coordinatesRepository is a locationRepository that also looks up coordinates.
+type coordinatesRepository struct {
+	locationRepository
+	at *model.Location
+}
+
+func (m *coordinatesRepository) GetWeatherAt(_ context.Context, loc model.Location) (*model.WeatherResponse, error) {
+	m.at = &loc
+	return &model.WeatherResponse{Location: "Jakarta", Temperature: 27}, nil
+}
+
+func TestWeatherService_GetWeatherAt(t *testing.T) {
+	repo := &coordinatesRepository{}
+	var seen []string
+	hook := func(_ context.Context, location string) (string, error) {
+		seen = append(seen, location)
+		return location, nil
+	}
+	svc := New(WithRepository(repo), WithPreFetch(hook))
+	ctx := context.Background()
+
+	weather, err := svc.GetWeatherAt(ctx, model.LocationAt(-6.2088, 106.8456))
+	if err != nil || weather.Location != "Jakarta" {
+		t.Fatalf("Expected Jakarta, got %+v, %v", weather, err)
+	}
+	if repo.at == nil || repo.at.Coords == nil || repo.location != "" {
+		t.Errorf("Expected a lookup by coordinates, got %+v", repo)
+	}
+	if len(seen) != 1 || seen[0] != "coord:-6.21,106.85" {
+		t.Errorf("Expected hooks to see the coordinates key, got %v", seen)
+	}
+
+	// Names are canonicalized and looked up as by GetWeather
+	if _, err := svc.GetWeatherAt(ctx, model.Location{Name: "jakarta"}); err != nil || repo.location != "Jakarta" {
+		t.Errorf("Expected a lookup of Jakarta by name, got %q, %v", repo.location, err)
+	}
+
+	repo.at = nil
+	if _, err := svc.GetWeatherAt(ctx, model.LocationAt(91, 0)); !errors.Is(err, model.ErrCoordinatesOutOfRange) || repo.at != nil {
+		t.Errorf("Expected out of range coordinates rejected, got %v", err)
+	}
+
+	svc = New(WithRepository(&locationRepository{}))
+	if _, err := svc.GetWeatherAt(ctx, model.LocationAt(0, 0)); !errors.Is(err, ErrCoordinatesUnsupported) {
+		t.Errorf("Expected ErrCoordinatesUnsupported, got %v", err)
+	}
+}
+
+// countingRepository returns weather for every location but Atlantis and counts the lookups.
+type countingRepository struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (m *countingRepository) GetWeather(_ context.Context, location string) (*model.WeatherResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[location]++
+	if location == "Atlantis" {
+		return nil, &repository.LocationNotFoundError{Message: "city not found"}
+	}
+	return &model.WeatherResponse{Location: location, Temperature: 20}, nil
+}
+
+// batchRepository is a countingRepository that also looks up batches.
+type batchRepository struct {
+	countingRepository
+	batches int
+}
+
+func (m *batchRepository) GetWeatherBatch(ctx context.Context, locations []string, _ int) []repository.BatchResult {
+	m.batches++
+	results := make([]repository.BatchResult, len(locations))
+	for i, location := range locations {
+		results[i].Weather, results[i].Err = m.GetWeather(ctx, location)
+	}
+	return results
+}
+
+func TestWeatherService_GetWeatherBatch(t *testing.T) {
+	repo := &countingRepository{calls: map[string]int{}}
+	service := New(
+		WithRepository(repo),
+		WithPreFetch(func(_ context.Context, location string) (string, error) {
+			if location == "Pyongyang" {
+				return "", fmt.Errorf("%w: blocked", ErrRejected)
+			}
+			return location, nil
+		}),
+		WithPostFetch(func(_ context.Context, _ string, weather *model.WeatherResponse) error {
+			weather.Description = "checked"
+			return nil
+		}),
+	)
+
+	results := service.GetWeatherBatch(context.Background(), []string{"jakarta", "Bandung", "Jakarta", "", "Pyongyang", "Atlantis"})
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+	for _, i := range []int{0, 2} {
+		if r := results[i]; r.Err != nil || r.Weather.Location != "Jakarta" || r.Weather.Description != "checked" {
+			t.Errorf("Result %d: expected Jakarta through the hooks, got %+v", i, r)
+		}
+	}
+	if results[0].Location != "jakarta" {
+		t.Errorf("Expected the location as requested, got %q", results[0].Location)
+	}
+	if r := results[1]; r.Err != nil || r.Weather.Location != "Bandung" {
+		t.Errorf("Expected Bandung, got %+v", r)
+	}
+	if !errors.Is(results[3].Err, geodata.ErrInvalidLocation) {
+		t.Errorf("Expected ErrInvalidLocation for an empty location, got %v", results[3].Err)
+	}
+	if !errors.Is(results[4].Err, ErrRejected) {
+		t.Errorf("Expected the pre-fetch hook to reject, got %v", results[4].Err)
+	}
+	var notFound *repository.LocationNotFoundError
+	if !errors.As(results[5].Err, &notFound) {
+		t.Errorf("Expected Atlantis not found, got %v", results[5].Err)
+	}
+	want := map[string]int{"Jakarta": 1, "Bandung": 1, "Atlantis": 1}
+	if fmt.Sprint(repo.calls) != fmt.Sprint(want) {
+		t.Errorf("Expected each location looked up once, got %v", repo.calls)
+	}
+}
+
+func TestWeatherService_GetWeatherBatch_BatchRepository(t *testing.T) {
+	repo := &batchRepository{countingRepository: countingRepository{calls: map[string]int{}}}
+	results := New(WithRepository(repo)).GetWeatherBatch(context.Background(), []string{"Jakarta", "Bandung"})
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if repo.batches != 1 {
+		t.Errorf("Expected one batch lookup, got %d", repo.batches)
+	}
+}
@@ -0,0 +1,11 @@
+package startup
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the startup module logger, whose level is set by logging.levels.startup.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("startup")
+}
@@ -0,0 +1,148 @@
+// Package startup checks the service's dependencies before it starts serving, optionally waiting
+// for them with retries, and logs a summary of their status.
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/fanout"
+)
+
+// checkTimeout bounds each attempt of a check.
+const checkTimeout = 2 * time.Second
+
+// Dependency statuses reported in Result.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// ErrUnavailable is returned by Run when a dependency it waited for never became available.
+var ErrUnavailable = errors.New("dependency unavailable")
+
+// Check probes one dependency.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+	// Wait retries a failing probe with backoff, up to config.StartupConfig.Retries times.
+	Wait bool
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string
+	Status   string
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Run runs every check in order and logs the status of each dependency. It returns
+// ErrUnavailable when a check it waited for still fails and cfg.ExitOnFailure is set, or the
+// context's error if ctx ends while waiting.
+func Run(ctx context.Context, cfg config.StartupConfig, checks ...Check) ([]Result, error) {
+	start := time.Now()
+	results := make([]Result, 0, len(checks))
+	var unavailable []string
+	for _, c := range checks {
+		res := run(ctx, cfg, c)
+		results = append(results, res)
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		if res.Err != nil && c.Wait {
+			unavailable = append(unavailable, c.Name)
+		}
+	}
+
+	healthy := 0
+	for _, res := range results {
+		if res.Err == nil {
+			healthy++
+			logger().Infow("Dependency status", "dependency", res.Name, "status", res.Status, "attempts", res.Attempts, "elapsed", res.Elapsed)
+		} else {
+			logger().Warnw("Dependency status", "dependency", res.Name, "status", res.Status, "attempts", res.Attempts, "elapsed", res.Elapsed, "error", res.Err)
+		}
+	}
+	logger().Infow("Startup checks complete", "healthy", healthy, "failed", len(results)-healthy, "duration", time.Since(start))
+
+	if len(unavailable) > 0 && cfg.ExitOnFailure {
+		return results, fmt.Errorf("%w: %v", ErrUnavailable, unavailable)
+	}
+	return results, nil
+}
+
+func run(ctx context.Context, cfg config.StartupConfig, c Check) Result {
+	start := time.Now()
+	res := Result{Name: c.Name}
+	backoff := cfg.Backoff
+	for {
+		res.Attempts++
+		attemptCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		res.Err = c.Probe(attemptCtx)
+		cancel()
+		if res.Err == nil || !c.Wait || res.Attempts > cfg.Retries {
+			break
+		}
+		logger().Infow("Waiting for dependency", "dependency", c.Name, "attempt", res.Attempts, "retryIn", backoff, "error", res.Err)
+		if err := sleep(ctx, backoff); err != nil {
+			res.Err = err
+			break
+		}
+		backoff = min(2*backoff, cfg.MaxBackoff)
+	}
+	res.Elapsed = time.Since(start)
+	res.Status = StatusOK
+	if res.Err != nil {
+		res.Status = StatusFailed
+	}
+	return res
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Warmup opens connections to a dependency ahead of the first requests.
+type Warmup struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// WarmUp runs every warmup at once, within cfg.WarmupTimeout, and logs how long each took. A
+// failed warmup is only logged, since the connection is opened by the first request instead.
+func WarmUp(ctx context.Context, cfg config.StartupConfig, warmups ...Warmup) []Result {
+	ctx, cancel := context.WithTimeout(ctx, cfg.WarmupTimeout)
+	defer cancel()
+	elapsed := fanout.Map(ctx, warmups, fanout.Options{Limit: len(warmups)}, func(ctx context.Context, w Warmup) (time.Duration, error) {
+		start := time.Now()
+		err := w.Run(ctx)
+		return time.Since(start), err
+	})
+	results := make([]Result, len(warmups))
+	for i, w := range warmups {
+		results[i] = Result{Name: w.Name, Status: StatusOK, Attempts: 1, Elapsed: elapsed[i].Value, Err: elapsed[i].Err}
+		if results[i].Err != nil {
+			results[i].Status = StatusFailed
+		}
+	}
+	for _, res := range results {
+		if res.Err == nil {
+			logger().Infow("Connections warmed up", "dependency", res.Name, "elapsed", res.Elapsed)
+		} else {
+			logger().Warnw("Connection warmup failed", "dependency", res.Name, "elapsed", res.Elapsed, "error", res.Err)
+		}
+	}
+	return results
+}
@@ -0,0 +1,89 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+var testConfig = config.StartupConfig{Retries: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+// failing returns a probe that fails n times before succeeding.
+func failing(n int) func(context.Context) error {
+	return func(context.Context) error {
+		if n > 0 {
+			n--
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+}
+
+func TestRun_WaitsForDependency(t *testing.T) {
+	results, err := Run(context.Background(), testConfig, Check{Name: "redis", Probe: failing(2), Wait: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if res := results[0]; res.Status != StatusOK || res.Attempts != 3 || res.Err != nil {
+		t.Errorf("Expected success on the third attempt, got %+v", res)
+	}
+}
+
+func TestRun_GivesUp(t *testing.T) {
+	checks := []Check{
+		{Name: "redis", Probe: failing(10), Wait: true},
+		{Name: "openweathermap", Probe: failing(10)},
+	}
+	results, err := Run(context.Background(), testConfig, checks...)
+	if err != nil {
+		t.Fatalf("Expected to start anyway without exit_on_failure, got %v", err)
+	}
+	if res := results[0]; res.Status != StatusFailed || res.Attempts != 4 {
+		t.Errorf("Expected 1 attempt and 3 retries, got %+v", res)
+	}
+	if res := results[1]; res.Status != StatusFailed || res.Attempts != 1 {
+		t.Errorf("Expected a check that does not wait to run once, got %+v", res)
+	}
+
+	cfg := testConfig
+	cfg.ExitOnFailure = true
+	checks[0].Probe = failing(10)
+	if _, err := Run(context.Background(), cfg, checks...); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+	checks[0].Probe = failing(0)
+	if _, err := Run(context.Background(), cfg, checks...); err != nil {
+		t.Errorf("Expected only waited-for checks to stop startup, got %v", err)
+	}
+}
+
+func TestRun_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := testConfig
+	cfg.Backoff = time.Hour
+	if _, err := Run(ctx, cfg, Check{Name: "redis", Probe: failing(10), Wait: true}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation to be returned, got %v", err)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	cfg := testConfig
+	cfg.WarmupTimeout = 50 * time.Millisecond
+	results := WarmUp(context.Background(), cfg,
+		Warmup{Name: "redis", Run: failing(0)},
+		Warmup{Name: "openweathermap", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+	if res := results[0]; res.Name != "redis" || res.Status != StatusOK {
+		t.Errorf("Expected the redis warmup to succeed, got %+v", res)
+	}
+	if res := results[1]; res.Status != StatusFailed || !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Errorf("Expected a hung warmup cut off by the timeout, got %+v", res)
+	}
+}
@@ -0,0 +1,39 @@
+// Package tenant carries the provider account a request's upstream calls are billed to, for API
+// keys bound to their own provider credentials.
+package tenant
+
+import "context"
+
+// Provider is a tenant's provider account.
+type Provider struct {
+	// Tenant is the name of the API key the account belongs to.
+	Tenant string
+	// Name is the provider, e.g. "openweathermap".
+	Name   string
+	APIKey string
+	// APIURL overrides the provider's configured URL when set.
+	APIURL     string
+	DailyQuota int64
+}
+
+// Account identifies the provider account in cost accounting, e.g. "openweathermap:acme".
+func (p Provider) Account() string {
+	return p.Name + ":" + p.Tenant
+}
+
+type providerKey struct{}
+
+// WithProvider returns a copy of ctx carrying p.
+func WithProvider(ctx context.Context, p Provider) context.Context {
+	return context.WithValue(ctx, providerKey{}, p)
+}
+
+// FromContext returns the provider account carried by ctx, if any. Requests without one use the
+// shared account.
+func FromContext(ctx context.Context) (Provider, bool) {
+	if ctx == nil {
+		return Provider{}, false
+	}
+	p, ok := ctx.Value(providerKey{}).(Provider)
+	return p, ok
+}
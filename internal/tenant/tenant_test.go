@@ -0,0 +1,20 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("Expected no provider on a bare context")
+	}
+	p := Provider{Tenant: "acme", Name: "openweathermap", APIKey: "k"}
+	got, ok := FromContext(WithProvider(context.Background(), p))
+	if !ok || got != p {
+		t.Errorf("Expected %+v, got %+v", p, got)
+	}
+	if got.Account() != "openweathermap:acme" {
+		t.Errorf("Unexpected account %q", got.Account())
+	}
+}
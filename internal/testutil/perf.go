@@ -0,0 +1,290 @@
+package testutil
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Perf regression checks time a hot path and compare the p95 of its time per call with a baseline
+// kept in the package's testdata, in the Go benchmark format that benchstat reads:
+//
+//	BenchmarkCacheHit              	    4096	       612.5 ns/op
+//	BenchmarkCacheHit/calibration  	    2048	      1187.0 ns/op
+//
+// Every timing is divided by that of a fixed calibration workload measured in the same run, so a
+// baseline recorded on one machine holds on another: only a change relative to plain CPU work
+// counts as a regression. Baselines are recorded with PERF_UPDATE=1, and compared with
+// `benchstat old.txt testdata/perf_baseline.txt` like any benchmark output.
+const (
+	// PerfBaselineFile is where CheckPerf keeps baselines, relative to the package under test.
+	PerfBaselineFile = "testdata/perf_baseline.txt"
+	// PerfThreshold is how much the p95 may grow over its baseline before CheckPerf fails, unless
+	// PERF_THRESHOLD sets another share.
+	PerfThreshold = 0.30
+
+	perfSamples    = 60
+	perfBatchTime  = 5 * time.Millisecond
+	perfCalibrated = "/calibration"
+	perfAttempts   = 3
+)
+
+// PerfMeasurement is the time per call of a function, averaged over each of several batches.
+type PerfMeasurement struct {
+	// Batch is the number of calls in each batch.
+	Batch int
+	// NsPerOp holds the mean nanoseconds per call of each batch.
+	NsPerOp []float64
+}
+
+// Percentile returns the p-th percentile, from 0 to 100, of the batches' time per call, by
+// nearest rank.
+func (m PerfMeasurement) Percentile(p float64) float64 {
+	if len(m.NsPerOp) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(m.NsPerOp))
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// MeasurePerf times fn in samples batches of about 5ms each, after a warm-up that sizes them.
+func MeasurePerf(fn func(), samples int) PerfMeasurement {
+	m := PerfMeasurement{Batch: batchSize(fn), NsPerOp: make([]float64, samples)}
+	for i := range m.NsPerOp {
+		m.NsPerOp[i] = timeBatch(fn, m.Batch)
+	}
+	return m
+}
+
+// measureCalibrated times fn and the calibration workload in alternating batches, so that CPU
+// frequency changes and noisy neighbours during the run weigh on both alike.
+func measureCalibrated(fn func(), samples int) (got, calibration PerfMeasurement) {
+	runtime.GC()
+	got = PerfMeasurement{Batch: batchSize(fn), NsPerOp: make([]float64, samples)}
+	calibration = PerfMeasurement{Batch: batchSize(calibrate), NsPerOp: make([]float64, samples)}
+	for i := range samples {
+		calibration.NsPerOp[i] = timeBatch(calibrate, calibration.Batch)
+		got.NsPerOp[i] = timeBatch(fn, got.Batch)
+	}
+	return got, calibration
+}
+
+// recordCalibrated measures fn for a baseline: the run of median calibrated p95 out of three, so
+// that the baseline is not an outlier itself.
+func recordCalibrated(fn func()) (got, calibration PerfMeasurement) {
+	type run struct{ got, calibration PerfMeasurement }
+	runs := make([]run, perfAttempts)
+	for i := range runs {
+		runs[i].got, runs[i].calibration = measureCalibrated(fn, perfSamples)
+	}
+	slices.SortFunc(runs, func(a, b run) int {
+		return cmp.Compare(calibrated(a.got, a.calibration).Percentile(95), calibrated(b.got, b.calibration).Percentile(95))
+	})
+	median := runs[len(runs)/2]
+	return median.got, median.calibration
+}
+
+// batchSize returns how many calls of fn take about perfBatchTime, warming fn up on the way.
+func batchSize(fn func()) int {
+	for n := 1; ; n *= 2 {
+		start := time.Now()
+		for range n {
+			fn()
+		}
+		if elapsed := time.Since(start); elapsed >= perfBatchTime/4 {
+			return max(1, int(float64(n)*float64(perfBatchTime)/float64(elapsed)))
+		}
+	}
+}
+
+// timeBatch returns the mean nanoseconds per call of n calls of fn.
+func timeBatch(fn func(), n int) float64 {
+	start := time.Now()
+	for range n {
+		fn()
+	}
+	return float64(time.Since(start).Nanoseconds()) / float64(n)
+}
+
+// calibrationInput is hashed by the calibration workload.
+var calibrationInput = make([]byte, 1024)
+
+// calibrate is the fixed workload timings are divided by.
+func calibrate() {
+	sum := sha256.Sum256(calibrationInput)
+	calibrationInput[0] = sum[0]
+}
+
+// ComparePerf returns the relative change of the p95 of got over that of baseline, as a share: 0.1
+// is 10% slower. Each batch is divided by the calibration batch timed next to it, so what is
+// compared is the p95 of fn's cost relative to plain CPU work.
+func ComparePerf(baseline, baselineCalibration, got, gotCalibration PerfMeasurement) float64 {
+	return calibrated(got, gotCalibration).Percentile(95)/calibrated(baseline, baselineCalibration).Percentile(95) - 1
+}
+
+// calibrated divides each batch of m by the calibration batch timed next to it.
+func calibrated(m, calibration PerfMeasurement) PerfMeasurement {
+	n := min(len(m.NsPerOp), len(calibration.NsPerOp))
+	ratios := PerfMeasurement{Batch: m.Batch, NsPerOp: make([]float64, n)}
+	for i := range n {
+		ratios.NsPerOp[i] = m.NsPerOp[i] / calibration.NsPerOp[i]
+	}
+	return ratios
+}
+
+// CheckPerf fails t when fn has become slower than its baseline under name by more than the
+// threshold. A run over the threshold is measured again, up to three times in all, and fails only
+// when none is within it, so that one noisy run does not fail the build. With PERF_UPDATE=1 it
+// records fn's timing as the new baseline instead.
+func CheckPerf(t *testing.T, name string, fn func()) {
+	t.Helper()
+	if os.Getenv("PERF_UPDATE") != "" {
+		got, calibration := recordCalibrated(fn)
+		err := UpdatePerfBaseline(PerfBaselineFile, map[string]PerfMeasurement{name: got, name + perfCalibrated: calibration})
+		if err != nil {
+			t.Fatalf("Update %s: %v", PerfBaselineFile, err)
+		}
+		t.Logf("Recorded %s: p95 %.1f ns/op", name, got.Percentile(95))
+		return
+	}
+
+	got, calibration := measureCalibrated(fn, perfSamples)
+	baselines, err := ReadPerfBaseline(PerfBaselineFile)
+	if err != nil {
+		t.Fatalf("Read %s: %v", PerfBaselineFile, err)
+	}
+	baseline, ok := baselines[name]
+	baselineCalibration, calibrated := baselines[name+perfCalibrated]
+	if !ok || !calibrated {
+		t.Fatalf("No baseline for %s in %s; record one with PERF_UPDATE=1", name, PerfBaselineFile)
+	}
+	threshold, err := perfThreshold()
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := ComparePerf(baseline, baselineCalibration, got, calibration)
+	for attempt := 1; attempt < perfAttempts && change > threshold; attempt++ {
+		t.Logf("%s p95 %+.0f%% against its baseline, measuring again", name, 100*change)
+		got, calibration = measureCalibrated(fn, perfSamples)
+		change = ComparePerf(baseline, baselineCalibration, got, calibration)
+	}
+	if change > threshold {
+		t.Errorf("%s p95 regressed by %.0f%% (threshold %.0f%%): %.1f ns/op, baseline %.1f ns/op",
+			name, 100*change, 100*threshold, got.Percentile(95), baseline.Percentile(95))
+		return
+	}
+	t.Logf("%s p95 %+.0f%% against its baseline: %.1f ns/op", name, 100*change, got.Percentile(95))
+}
+
+func perfThreshold() (float64, error) {
+	v := os.Getenv("PERF_THRESHOLD")
+	if v == "" {
+		return PerfThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("invalid PERF_THRESHOLD %q: want a non-negative share such as 0.3", v)
+	}
+	return threshold, nil
+}
+
+// ReadPerfBaseline reads the measurements in a benchmark-format file, by benchmark name without
+// its "Benchmark" prefix. Lines that are not benchmark results are skipped.
+func ReadPerfBaseline(path string) (map[string]PerfMeasurement, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	baselines := make(map[string]PerfMeasurement)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, batch, ns, ok := parseBenchmarkLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		m := baselines[name]
+		m.Batch = batch
+		m.NsPerOp = append(m.NsPerOp, ns)
+		baselines[name] = m
+	}
+	return baselines, scanner.Err()
+}
+
+// parseBenchmarkLine parses a line such as "BenchmarkCacheHit 4096 612.5 ns/op".
+func parseBenchmarkLine(line string) (name string, batch int, ns float64, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", 0, 0, false
+	}
+	batch, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	for i := 3; i < len(fields); i++ {
+		if fields[i] != "ns/op" {
+			continue
+		}
+		if ns, err = strconv.ParseFloat(fields[i-1], 64); err != nil {
+			return "", 0, 0, false
+		}
+		return strings.TrimPrefix(fields[0], "Benchmark"), batch, ns, true
+	}
+	return "", 0, 0, false
+}
+
+// UpdatePerfBaseline replaces the measurements of the given names in the file at path, keeping
+// those of other names, and creates the file if needed.
+func UpdatePerfBaseline(path string, measurements map[string]PerfMeasurement) error {
+	var kept []string
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		kept = []string{"goos: " + runtime.GOOS, "goarch: " + runtime.GOARCH}
+	case err != nil:
+		return err
+	default:
+		for _, line := range strings.Split(strings.TrimRight(string(existing), "\n"), "\n") {
+			if name, _, _, ok := parseBenchmarkLine(line); ok {
+				if _, replaced := measurements[name]; replaced {
+					continue
+				}
+			}
+			kept = append(kept, line)
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range kept {
+		b.WriteString(line + "\n")
+	}
+	for _, name := range slices.Sorted(func(yield func(string) bool) {
+		for name := range measurements {
+			if !yield(name) {
+				return
+			}
+		}
+	}) {
+		m := measurements[name]
+		for _, ns := range m.NsPerOp {
+			fmt.Fprintf(&b, "Benchmark%s\t%8d\t%12.1f ns/op\n", name, m.Batch, ns)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
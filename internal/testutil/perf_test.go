@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPerfMeasurement_Percentile(t *testing.T) {
+	m := PerfMeasurement{NsPerOp: []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}}
+	for _, tc := range []struct {
+		p    float64
+		want float64
+	}{{0, 1}, {50, 5}, {95, 10}, {100, 10}} {
+		if got := m.Percentile(tc.p); got != tc.want {
+			t.Errorf("Expected p%v to be %v, got %v", tc.p, tc.want, got)
+		}
+	}
+	if got := (PerfMeasurement{}).Percentile(95); got != 0 {
+		t.Errorf("Expected no samples to give 0, got %v", got)
+	}
+}
+
+func TestComparePerf_Calibrated(t *testing.T) {
+	baseline := PerfMeasurement{NsPerOp: []float64{100}}
+	baselineCalibration := PerfMeasurement{NsPerOp: []float64{50}}
+	// A machine twice as slow takes twice as long on both: no change.
+	if got := ComparePerf(baseline, baselineCalibration, PerfMeasurement{NsPerOp: []float64{200}}, PerfMeasurement{NsPerOp: []float64{100}}); math.Abs(got) > 1e-9 {
+		t.Errorf("Expected a uniformly slower machine to show no change, got %v", got)
+	}
+	if got := ComparePerf(baseline, baselineCalibration, PerfMeasurement{NsPerOp: []float64{130}}, PerfMeasurement{NsPerOp: []float64{50}}); math.Abs(got-0.3) > 1e-9 {
+		t.Errorf("Expected a 30%% regression, got %v", got)
+	}
+}
+
+func TestPerfBaseline_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "perf_baseline.txt")
+	if err := UpdatePerfBaseline(path, map[string]PerfMeasurement{
+		"A": {Batch: 100, NsPerOp: []float64{1.5, 2.5}},
+		"B": {Batch: 10, NsPerOp: []float64{30}},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := UpdatePerfBaseline(path, map[string]PerfMeasurement{"A": {Batch: 200, NsPerOp: []float64{4}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := ReadPerfBaseline(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a := got["A"]; a.Batch != 200 || len(a.NsPerOp) != 1 || a.NsPerOp[0] != 4 {
+		t.Errorf("Expected A to be replaced, got %+v", a)
+	}
+	if b := got["B"]; b.Batch != 10 || len(b.NsPerOp) != 1 || b.NsPerOp[0] != 30 {
+		t.Errorf("Expected B to be kept, got %+v", b)
+	}
+	raw, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(raw), "goos: ") {
+		t.Errorf("Expected a benchstat configuration header, got %q", raw)
+	}
+}
+
+func TestParseBenchmarkLine(t *testing.T) {
+	name, batch, ns, ok := parseBenchmarkLine("BenchmarkCacheHit/calibration-8   \t 2048\t  1187.0 ns/op\t  0 B/op")
+	if !ok || name != "CacheHit/calibration-8" || batch != 2048 || ns != 1187 {
+		t.Errorf("Unexpected parse: %q %d %v %v", name, batch, ns, ok)
+	}
+	for _, line := range []string{"goos: linux", "PASS", "BenchmarkX 10 fast ns/op", "BenchmarkX 10 12 B/op"} {
+		if _, _, _, ok := parseBenchmarkLine(line); ok {
+			t.Errorf("Expected %q not to parse", line)
+		}
+	}
+}
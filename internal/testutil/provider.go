@@ -0,0 +1,311 @@
+// Package testutil holds test doubles shared by the unit and integration tests.
+package testutil
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+// forecastSteps and forecastInterval shape the forecast like OpenWeatherMap's 5 day / 3 hour one,
+// and hourlySteps the hourly one like its 4 day forecast.
+const (
+	forecastSteps    = 40
+	forecastInterval = 3 * time.Hour
+	hourlySteps      = 96
+)
+
+// condition is a weather condition as OpenWeatherMap reports it.
+type condition struct {
+	id          int
+	main        string
+	description string
+}
+
+var (
+	mildConditions = []condition{
+		{800, "Clear", "clear sky"},
+		{801, "Clouds", "few clouds"},
+		{802, "Clouds", "scattered clouds"},
+		{803, "Clouds", "broken clouds"},
+		{500, "Rain", "light rain"},
+		{501, "Rain", "moderate rain"},
+		{211, "Thunderstorm", "thunderstorm"},
+		{741, "Fog", "fog"},
+	}
+	coldConditions = []condition{
+		{800, "Clear", "clear sky"},
+		{803, "Clouds", "broken clouds"},
+		{600, "Snow", "light snow"},
+		{601, "Snow", "snow"},
+	}
+)
+
+// Conditions are the weather MockProvider reports for a city at a time.
+type Conditions struct {
+	City string
+	Time time.Time
+	// Temperature and FeelsLike are in °C, with two decimals.
+	Temperature float64
+	FeelsLike   float64
+	TempMin     float64
+	TempMax     float64
+	Humidity    int
+	Pressure    int
+	// WindSpeed is in m/s.
+	WindSpeed   float64
+	ConditionID int
+	Main        string
+	Description string
+}
+
+// MockProvider is a fake OpenWeatherMap serving deterministic pseudo-random weather. Each city
+// gets a climate from the seed, each of its days a pseudo-random deviation and condition, and
+// its temperature follows a daily curve peaking at 15:00 UTC. The same seed, city and hour always
+// give the same conditions, so tests can assert on realistic, varied data and replay it.
+type MockProvider struct {
+	// Seed picks the generated weather.
+	Seed int64
+	// Now is the provider's clock. It defaults to time.Now.
+	Now func() time.Time
+	// APIKey, when set, must be passed as appid or requests get a 401.
+	APIKey string
+	// Cities, when set, are the only known cities; others get a 404.
+	Cities []string
+	// Places locate cities for lat/lon queries, which are answered for the nearest one. Without
+	// places, the city is named after the coordinates, e.g. "-6.21,106.85".
+	Places map[string]model.Coordinates
+
+	calls atomic.Int64
+}
+
+// NewMockProvider returns a provider generating weather from seed.
+func NewMockProvider(seed int64) *MockProvider {
+	return &MockProvider{Seed: seed}
+}
+
+// Start serves the provider on a local test server. Point openweathermap.api_url at its
+// /weather path, openweathermap.forecast_url at /forecast and
+// openweathermap.hourly_forecast_url at /forecast/hourly.
+func (p *MockProvider) Start() *httptest.Server {
+	return httptest.NewServer(p)
+}
+
+// Calls returns the number of requests served.
+func (p *MockProvider) Calls() int {
+	return int(p.calls.Load())
+}
+
+func (p *MockProvider) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// rng returns a generator seeded by the provider's seed and parts, so each combination of parts
+// has its own reproducible sequence.
+func (p *MockProvider) rng(parts ...string) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(p.Seed, 10)))
+	for _, part := range parts {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(strings.ToLower(part)))
+	}
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// Conditions returns the weather for city at the hour of at.
+func (p *MockProvider) Conditions(city string, at time.Time) Conditions {
+	at = at.UTC().Truncate(time.Hour)
+	climate := p.rng(city)
+	base := -5 + climate.Float64()*35
+	swing := 3 + climate.Float64()*5
+
+	daily := p.rng(city, at.Format(time.DateOnly))
+	mean := base + daily.NormFloat64()*3
+	humidity := 30 + daily.Intn(65)
+	pressure := 990 + daily.Intn(45)
+	wind := round2(daily.Float64() * 12)
+	choices := mildConditions
+	if mean < 2 {
+		choices = coldConditions
+	}
+	cond := choices[daily.Intn(len(choices))]
+
+	// Coldest at 03:00, warmest at 15:00
+	hour := float64(at.Hour())
+	temp := mean + swing/2*math.Sin((hour-9)/24*2*math.Pi)
+	return Conditions{
+		City:        city,
+		Time:        at,
+		Temperature: round2(temp),
+		FeelsLike:   round2(temp - wind/4),
+		TempMin:     round2(mean - swing/2),
+		TempMax:     round2(mean + swing/2),
+		Humidity:    humidity,
+		Pressure:    pressure,
+		WindSpeed:   wind,
+		ConditionID: cond.id,
+		Main:        cond.main,
+		Description: cond.description,
+	}
+}
+
+// Forecast returns the conditions every 3 hours for 5 days, starting at the first 3-hour
+// boundary after from.
+func (p *MockProvider) Forecast(city string, from time.Time) []Conditions {
+	start := from.UTC().Truncate(forecastInterval).Add(forecastInterval)
+	entries := make([]Conditions, forecastSteps)
+	for i := range entries {
+		entries[i] = p.Conditions(city, start.Add(time.Duration(i)*forecastInterval))
+	}
+	return entries
+}
+
+// HourlyForecast returns the conditions every hour for 4 days, starting at the first hour after
+// from.
+func (p *MockProvider) HourlyForecast(city string, from time.Time) []Conditions {
+	start := from.UTC().Truncate(time.Hour).Add(time.Hour)
+	entries := make([]Conditions, hourlySteps)
+	for i := range entries {
+		entries[i] = p.Conditions(city, start.Add(time.Duration(i)*time.Hour))
+	}
+	return entries
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ServeHTTP answers the current weather and forecast APIs: paths ending in /forecast get the
+// forecast, in /forecast/hourly the hourly forecast, cut to cnt entries when given, and any other
+// path the current weather. The city is the q parameter, or the place nearest lat and lon.
+func (p *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	if p.APIKey != "" && query.Get("appid") != p.APIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"cod":401,"message":"Invalid API key"}`))
+		return
+	}
+	city := query.Get("q")
+	if query.Has("lat") || query.Has("lon") {
+		city = p.nearest(query.Get("lat"), query.Get("lon"))
+	}
+	if city == "" || !p.knows(city) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"cod":"404","message":"city not found"}`))
+		return
+	}
+	now := p.now()
+	var entries []Conditions
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/forecast"):
+		entries = p.Forecast(city, now)
+	case strings.HasSuffix(r.URL.Path, "/forecast/hourly"):
+		entries = p.HourlyForecast(city, now)
+		if n, err := strconv.Atoi(query.Get("cnt")); err == nil && n > 0 && n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	if entries != nil {
+		forecast := owmForecast{List: make([]owmWeather, 0, len(entries))}
+		forecast.City.Name = city
+		for _, c := range entries {
+			forecast.List = append(forecast.List, toOWM(c))
+		}
+		_ = json.NewEncoder(w).Encode(forecast)
+		return
+	}
+	current := toOWM(p.Conditions(city, now))
+	current.Name = city
+	_ = json.NewEncoder(w).Encode(current)
+}
+
+// nearest returns the place nearest the coordinates, or "" when they are invalid.
+func (p *MockProvider) nearest(rawLat, rawLon string) string {
+	lat, latErr := strconv.ParseFloat(rawLat, 64)
+	lon, lonErr := strconv.ParseFloat(rawLon, 64)
+	at := model.Coordinates{Lat: lat, Lon: lon}
+	if latErr != nil || lonErr != nil || at.Validate() != nil {
+		return ""
+	}
+	if len(p.Places) == 0 {
+		return at.String()
+	}
+	city, best := "", math.Inf(1)
+	for name, c := range p.Places {
+		if d := math.Hypot(c.Lat-lat, c.Lon-lon); d < best || d == best && name < city {
+			city, best = name, d
+		}
+	}
+	return city
+}
+
+func (p *MockProvider) knows(city string) bool {
+	if len(p.Cities) == 0 {
+		return true
+	}
+	for _, c := range p.Cities {
+		if strings.EqualFold(c, city) {
+			return true
+		}
+	}
+	return false
+}
+
+// owmWeather is the subset of an OpenWeatherMap weather object the service reads.
+type owmWeather struct {
+	Dt   int64  `json:"dt"`
+	Name string `json:"name,omitempty"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int     `json:"pressure"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Weather []owmCondition `json:"weather"`
+	Wind    struct {
+		Speed float64 `json:"speed"`
+	} `json:"wind"`
+}
+
+type owmCondition struct {
+	ID          int    `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+}
+
+type owmForecast struct {
+	City struct {
+		Name string `json:"name"`
+	} `json:"city"`
+	List []owmWeather `json:"list"`
+}
+
+func toOWM(c Conditions) owmWeather {
+	var w owmWeather
+	w.Dt = c.Time.Unix()
+	w.Main.Temp = c.Temperature
+	w.Main.FeelsLike = c.FeelsLike
+	w.Main.TempMin = c.TempMin
+	w.Main.TempMax = c.TempMax
+	w.Main.Pressure = c.Pressure
+	w.Main.Humidity = c.Humidity
+	w.Weather = []owmCondition{{ID: c.ConditionID, Main: c.Main, Description: c.Description}}
+	w.Wind.Speed = c.WindSpeed
+	return w
+}
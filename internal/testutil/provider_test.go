@@ -0,0 +1,135 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+)
+
+var day = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMockProvider_Deterministic(t *testing.T) {
+	a, b := NewMockProvider(42), NewMockProvider(42)
+	at := day.Add(13*time.Hour + 20*time.Minute)
+	if got, want := a.Conditions("Jakarta", at), b.Conditions("Jakarta", at); got != want {
+		t.Errorf("Expected the same seed to give the same conditions, got %+v and %+v", got, want)
+	}
+	if got, want := a.Conditions("jakarta", at), a.Conditions("Jakarta", at.Truncate(time.Hour)); got.Temperature != want.Temperature || got.Description != want.Description {
+		t.Errorf("Expected the city case and minutes not to matter, got %+v and %+v", got, want)
+	}
+	if got, other := a.Conditions("Jakarta", at), NewMockProvider(43).Conditions("Jakarta", at); got == other {
+		t.Errorf("Expected another seed to give other conditions, got %+v for both", got)
+	}
+}
+
+func TestMockProvider_Varies(t *testing.T) {
+	p := NewMockProvider(7)
+	temps := make(map[float64]bool)
+	descriptions := make(map[string]bool)
+	for _, city := range []string{"Jakarta", "Oslo", "Cairo", "Lima"} {
+		for d := range 10 {
+			c := p.Conditions(city, day.AddDate(0, 0, d).Add(12*time.Hour))
+			temps[c.Temperature] = true
+			descriptions[c.Description] = true
+			if c.Temperature < c.TempMin || c.Temperature > c.TempMax {
+				t.Errorf("Expected %v within [%v, %v]", c.Temperature, c.TempMin, c.TempMax)
+			}
+			if c.Humidity < 0 || c.Humidity > 100 {
+				t.Errorf("Expected a humidity percentage, got %d", c.Humidity)
+			}
+		}
+	}
+	if len(temps) < 30 || len(descriptions) < 3 {
+		t.Errorf("Expected varied weather across cities and days, got %d temperatures and %d descriptions", len(temps), len(descriptions))
+	}
+}
+
+func TestMockProvider_Forecast(t *testing.T) {
+	p := NewMockProvider(1)
+	from := day.Add(4 * time.Hour)
+	forecast := p.Forecast("Jakarta", from)
+	if len(forecast) != 40 {
+		t.Fatalf("Expected 40 entries, got %d", len(forecast))
+	}
+	if !forecast[0].Time.Equal(day.Add(6 * time.Hour)) {
+		t.Errorf("Expected the first entry at the next 3-hour boundary, got %v", forecast[0].Time)
+	}
+	for i, c := range forecast {
+		if want := p.Conditions("Jakarta", c.Time); c != want {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, c)
+		}
+	}
+}
+
+func TestMockProvider_ServeHTTP(t *testing.T) {
+	now := day.Add(9 * time.Hour)
+	p := &MockProvider{Seed: 3, Now: func() time.Time { return now }, APIKey: "key", Cities: []string{"Jakarta"}}
+	srv := p.Start()
+	defer srv.Close()
+
+	get := func(path string, v any) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Decode %s: %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var current owmWeather
+	if code := get("/weather?q=Jakarta&appid=key&units=metric", &current); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	want := p.Conditions("Jakarta", now)
+	if current.Name != "Jakarta" || current.Main.Temp != want.Temperature || current.Weather[0].Description != want.Description {
+		t.Errorf("Expected %+v, got %+v", want, current)
+	}
+
+	var forecast owmForecast
+	if code := get("/forecast?q=Jakarta&appid=key", &forecast); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if forecast.City.Name != "Jakarta" || len(forecast.List) != 40 || forecast.List[0].Dt != now.Add(3*time.Hour).Unix() {
+		t.Errorf("Unexpected forecast %+v", forecast)
+	}
+
+	var hourly owmForecast
+	if code := get("/forecast/hourly?q=Jakarta&appid=key&cnt=12", &hourly); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(hourly.List) != 12 || hourly.List[0].Dt != now.Add(time.Hour).Unix() || hourly.List[11].Dt != now.Add(12*time.Hour).Unix() {
+		t.Errorf("Unexpected hourly forecast %+v", hourly)
+	}
+
+	if code := get("/weather?q=Oslo&appid=key", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown city, got %d", code)
+	}
+	if code := get("/weather?q=Jakarta&appid=wrong", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong key, got %d", code)
+	}
+	if p.Calls() != 5 {
+		t.Errorf("Expected 5 calls, got %d", p.Calls())
+	}
+}
+
+func TestMockProvider_Coordinates(t *testing.T) {
+	p := &MockProvider{Seed: 3, Places: map[string]model.Coordinates{"Jakarta": {Lat: -6.21, Lon: 106.85}, "Bandung": {Lat: -6.92, Lon: 107.62}}}
+	if got := p.nearest("-6.8", "107.5"); got != "Bandung" {
+		t.Errorf("Expected Bandung nearest, got %q", got)
+	}
+	if got := p.nearest("-91", "0"); got != "" {
+		t.Errorf("Expected no place for invalid coordinates, got %q", got)
+	}
+	if got := (&MockProvider{}).nearest("-6.2088", "106.8456"); got != "-6.21,106.85" {
+		t.Errorf("Expected a city named after the coordinates, got %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Package upstream records whether serving a request called the upstream provider, so that
+// middleware can charge the request by what it actually cost once the handler has run.
+package upstream
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Tracker records the provider calls made on behalf of one request.
+type Tracker struct {
+	calls atomic.Int64
+}
+
+// Called reports whether the provider was called.
+func (t *Tracker) Called() bool {
+	return t.calls.Load() > 0
+}
+
+// Calls returns the number of provider calls recorded so far.
+func (t *Tracker) Calls() int64 {
+	return t.calls.Load()
+}
+
+type trackerKey struct{}
+
+// WithTracker returns a copy of ctx carrying a Tracker, and the Tracker. A Tracker already carried
+// by ctx is reused, so that every middleware on the way sees the same calls.
+func WithTracker(ctx context.Context) (context.Context, *Tracker) {
+	if t, ok := ctx.Value(trackerKey{}).(*Tracker); ok {
+		return ctx, t
+	}
+	t := &Tracker{}
+	return context.WithValue(ctx, trackerKey{}, t), t
+}
+
+// RecordCall records a provider call on the Tracker carried by ctx, if any. It is safe to call
+// from several goroutines.
+func RecordCall(ctx context.Context) {
+	if t, ok := ctx.Value(trackerKey{}).(*Tracker); ok {
+		t.calls.Add(1)
+	}
+}
@@ -0,0 +1,33 @@
+package upstream
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	// Without a tracker, recording is a no-op
+	RecordCall(context.Background())
+
+	ctx, tracker := WithTracker(context.Background())
+	if tracker.Called() {
+		t.Error("Expected no call recorded yet")
+	}
+	RecordCall(context.WithoutCancel(ctx))
+	if !tracker.Called() {
+		t.Error("Expected the call recorded")
+	}
+}
+
+func TestWithTracker_ReusesTracker(t *testing.T) {
+	ctx, outer := WithTracker(context.Background())
+	inner, tracker := WithTracker(ctx)
+	if tracker != outer {
+		t.Fatal("Expected the tracker already in the context reused")
+	}
+	RecordCall(inner)
+	RecordCall(inner)
+	if outer.Calls() != 2 {
+		t.Errorf("Expected 2 calls seen by the outer tracker, got %d", outer.Calls())
+	}
+}
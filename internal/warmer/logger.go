@@ -0,0 +1,11 @@
+package warmer
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the warmer module logger, whose level is set by logging.levels.warmer.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("warmer")
+}
@@ -0,0 +1,170 @@
+// Package warmer keeps the cache of warm-listed cities fresh by refreshing them on a schedule,
+// checkpointing its progress in Redis so a restart resumes a cycle instead of starting over.
+package warmer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/service"
+)
+
+var (
+	cityRefreshes = metrics.NewCounterVec("weather_warmer_refreshes_total",
+		"Successful warm-list refreshes, by city.", "city")
+	cityChanges = metrics.NewCounterVec("weather_warmer_changes_total",
+		"Warm-list refreshes that found the city's temperature or description changed, by city.", "city")
+)
+
+// Leader reports whether this replica should run cluster-wide jobs; see leader.Elector.
+type Leader = jobs.Leader
+
+// Warmer refreshes every city in cache.warm_cities once per cycle.
+type Warmer struct {
+	Service     service.WeatherServiceInterface
+	Checkpoints repository.CheckpointStore
+	// Changes records how often each city's weather changes between refreshes; nil disables it.
+	Changes repository.ChangeStore
+	Config  config.WarmerConfig
+	// Leader limits cycles to the elected replica; nil runs them unconditionally.
+	Leader Leader
+	// Cities returns the warm list; it is read at the start of every cycle.
+	Cities func() []string
+	now    func() time.Time
+}
+
+// NewWarmer creates a Warmer for the configured warm list, backed by the shared Redis client,
+// that only runs while l reports leadership.
+func NewWarmer(l Leader) *Warmer {
+	return &Warmer{
+		Leader:      l,
+		Service:     service.NewWeatherService(),
+		Checkpoints: repository.NewCheckpointStore(),
+		Changes:     repository.NewChangeStore(),
+		Config:      config.GetWarmerConfig(),
+		Cities:      config.GetWarmCities,
+	}
+}
+
+// Start schedules the "warmer" job, running a cycle immediately and then every Config.Interval
+// until the returned function is called. stop interrupts the running cycle and waits for it to
+// return, leaving its checkpoint in place for the next start.
+func (w *Warmer) Start() (stop func()) {
+	return jobs.New("warmer", w.Config.Interval, w.RunCycle).Schedule(true)
+}
+
+// RunCycle refreshes the warm list, resuming from the saved checkpoint when it belongs to the same
+// list. The checkpoint is saved after every city, so an interrupted cycle loses at most the city
+// in progress; it is cleared once the cycle completes. A city that fails is logged and skipped.
+//
+// Only the leader runs cycles; other replicas return jobs.ErrSkipped. A replica that loses
+// leadership stops after the current city, and the new leader resumes from the shared checkpoint.
+func (w *Warmer) RunCycle(ctx context.Context) error {
+	if !w.isLeader() {
+		return jobs.ErrSkipped
+	}
+	cities := w.Cities()
+	if len(cities) == 0 {
+		return nil
+	}
+	hash := listHash(cities)
+	cp := repository.WarmerCheckpoint{ListHash: hash, StartedAt: w.clock()}
+	saved, err := w.Checkpoints.Load(ctx)
+	if err != nil {
+		logger().Warnw("Failed to load warmer checkpoint, starting a new cycle", "error", err)
+	} else if saved != nil && saved.ListHash == hash && saved.Cursor < len(cities) {
+		cp = *saved
+		logger().Infow("Resuming cache warm cycle", "cursor", cp.Cursor, "last_city", cp.LastCity, "started_at", cp.StartedAt)
+	}
+
+	refreshCtx := repository.WithWriteBehind(repository.WithCachePolicy(ctx, repository.CachePolicyRefresh))
+	for ; cp.Cursor < len(cities); cp.Cursor++ {
+		if cp.Cursor > 0 && w.Config.Delay > 0 {
+			if err := sleep(ctx, w.Config.Delay); err != nil {
+				return err
+			}
+		}
+		if !w.isLeader() {
+			logger().Infow("Lost leadership, leaving the warm cycle to the new leader", "cursor", cp.Cursor)
+			return nil
+		}
+		city := cities[cp.Cursor]
+		if weather, err := w.Service.GetWeather(refreshCtx, city); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger().Warnw("Failed to warm city", "city", city, "error", err)
+		} else {
+			w.recordChange(context.WithoutCancel(ctx), city, weather)
+		}
+		cp.LastCity = city
+		cp.UpdatedAt = w.clock()
+		next := cp
+		next.Cursor++
+		if err := w.Checkpoints.Save(context.WithoutCancel(ctx), next); err != nil {
+			logger().Warnw("Failed to save warmer checkpoint", "city", city, "error", err)
+		}
+	}
+	logger().Infow("Cache warm cycle complete", "cities", len(cities), "duration", w.clock().Sub(cp.StartedAt))
+	return w.Checkpoints.Clear(ctx)
+}
+
+// recordChange counts a refresh of city and whether its weather changed since the previous one.
+// Stale weather, served while the provider is unavailable, is not a refresh.
+func (w *Warmer) recordChange(ctx context.Context, city string, weather *model.WeatherResponse) {
+	if weather.Stale {
+		return
+	}
+	cityRefreshes.WithLabelValues(city).Inc()
+	if w.Changes == nil {
+		return
+	}
+	changed, err := w.Changes.Record(ctx, city, weather, w.clock())
+	if err != nil {
+		logger().Warnw("Failed to record city change", "city", city, "error", err)
+		return
+	}
+	if changed {
+		cityChanges.WithLabelValues(city).Inc()
+	}
+}
+
+func (w *Warmer) isLeader() bool {
+	return w.Leader == nil || w.Leader.IsLeader()
+}
+
+func (w *Warmer) clock() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
+
+// listHash identifies a warm list, ignoring case and surrounding whitespace.
+func listHash(cities []string) string {
+	h := sha256.New()
+	for _, c := range cities {
+		h.Write([]byte(strings.ToLower(strings.TrimSpace(c))))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
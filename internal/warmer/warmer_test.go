@@ -0,0 +1,200 @@
+package warmer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type memoryCheckpoints struct {
+	cp *repository.WarmerCheckpoint
+}
+
+func (m *memoryCheckpoints) Load(context.Context) (*repository.WarmerCheckpoint, error) {
+	if m.cp == nil {
+		return nil, nil
+	}
+	cp := *m.cp
+	return &cp, nil
+}
+
+func (m *memoryCheckpoints) Save(_ context.Context, cp repository.WarmerCheckpoint) error {
+	m.cp = &cp
+	return nil
+}
+
+func (m *memoryCheckpoints) Clear(context.Context) error {
+	m.cp = nil
+	return nil
+}
+
+// recordingService records refreshed cities and calls onFetch after each one.
+type recordingService struct {
+	cities  []string
+	fail    map[string]bool
+	onFetch func(city string)
+}
+
+func (s *recordingService) GetWeather(ctx context.Context, location string) (*model.WeatherResponse, error) {
+	if repository.CachePolicyFrom(ctx) != repository.CachePolicyRefresh {
+		return nil, errors.New("expected a refresh")
+	}
+	s.cities = append(s.cities, location)
+	if s.onFetch != nil {
+		s.onFetch(location)
+	}
+	if s.fail[location] {
+		return nil, errors.New("upstream error")
+	}
+	return &model.WeatherResponse{Location: location}, nil
+}
+
+func newTestWarmer(svc *recordingService, store *memoryCheckpoints, cities ...string) *Warmer {
+	return &Warmer{
+		Service:     svc,
+		Checkpoints: store,
+		Config:      config.WarmerConfig{Interval: time.Hour},
+		Cities:      func() []string { return cities },
+	}
+}
+
+func TestWarmer_ResumesFromCheckpoint(t *testing.T) {
+	store := &memoryCheckpoints{}
+	cities := []string{"London", "Paris", "Tokyo", "Lima"}
+
+	// Stop the first run while Tokyo is being fetched
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &recordingService{onFetch: func(city string) {
+		if city == "Tokyo" {
+			cancel()
+		}
+	}, fail: map[string]bool{"Tokyo": true}}
+	if err := newTestWarmer(svc, store, cities...).RunCycle(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the cycle interrupted, got %v", err)
+	}
+	if store.cp == nil || store.cp.Cursor != 2 || store.cp.LastCity != "Paris" {
+		t.Fatalf("Expected a checkpoint after Paris, got %+v", store.cp)
+	}
+
+	svc = &recordingService{}
+	if err := newTestWarmer(svc, store, cities...).RunCycle(context.Background()); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if len(svc.cities) != 2 || svc.cities[0] != "Tokyo" || svc.cities[1] != "Lima" {
+		t.Errorf("Expected the cycle resumed at Tokyo, got %v", svc.cities)
+	}
+	if store.cp != nil {
+		t.Errorf("Expected the checkpoint cleared after a full cycle, got %+v", store.cp)
+	}
+}
+
+func TestWarmer_ChangedListStartsOver(t *testing.T) {
+	store := &memoryCheckpoints{cp: &repository.WarmerCheckpoint{ListHash: listHash([]string{"London", "Paris"}), Cursor: 1}}
+	svc := &recordingService{}
+	if err := newTestWarmer(svc, store, "London", "Paris", "Rome").RunCycle(context.Background()); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if len(svc.cities) != 3 {
+		t.Errorf("Expected every city refreshed, got %v", svc.cities)
+	}
+}
+
+func TestWarmer_SkipsFailedCities(t *testing.T) {
+	store := &memoryCheckpoints{}
+	svc := &recordingService{fail: map[string]bool{"Paris": true}}
+	if err := newTestWarmer(svc, store, "London", "Paris", "Rome").RunCycle(context.Background()); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if len(svc.cities) != 3 || store.cp != nil {
+		t.Errorf("Expected the cycle completed past the failure, got %v, %+v", svc.cities, store.cp)
+	}
+}
+
+// memoryChanges records the cities refreshed and reports those in changed as changed.
+type memoryChanges struct {
+	recorded []string
+	changed  map[string]bool
+}
+
+func (m *memoryChanges) Record(_ context.Context, city string, _ *model.WeatherResponse, _ time.Time) (bool, error) {
+	m.recorded = append(m.recorded, city)
+	return m.changed[city], nil
+}
+
+func (m *memoryChanges) Report(context.Context, []string) ([]repository.CityChanges, error) {
+	return nil, nil
+}
+
+func TestWarmer_RecordsChanges(t *testing.T) {
+	changes := &memoryChanges{changed: map[string]bool{"Rome": true}}
+	w := newTestWarmer(&recordingService{fail: map[string]bool{"Paris": true}}, &memoryCheckpoints{}, "London", "Paris", "Rome")
+	w.Changes = changes
+	refreshes, changed := cityRefreshes.WithLabelValues("Rome").Value(), cityChanges.WithLabelValues("Rome").Value()
+	londonChanged, parisRefreshes := cityChanges.WithLabelValues("London").Value(), cityRefreshes.WithLabelValues("Paris").Value()
+	if err := w.RunCycle(context.Background()); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if len(changes.recorded) != 2 || changes.recorded[0] != "London" || changes.recorded[1] != "Rome" {
+		t.Errorf("Expected the successful refreshes recorded, got %v", changes.recorded)
+	}
+	if cityRefreshes.WithLabelValues("Rome").Value() != refreshes+1 || cityChanges.WithLabelValues("Rome").Value() != changed+1 {
+		t.Error("Expected Rome's refresh and change counted")
+	}
+	if cityChanges.WithLabelValues("London").Value() != londonChanged || cityRefreshes.WithLabelValues("Paris").Value() != parisRefreshes {
+		t.Error("Expected no change for London and no refresh for Paris")
+	}
+}
+
+func TestWarmer_StartAndStop(t *testing.T) {
+	store := &memoryCheckpoints{}
+	fetched := make(chan string, 10)
+	svc := &recordingService{onFetch: func(city string) { fetched <- city }}
+	w := newTestWarmer(svc, store, "London", "Paris")
+	w.Config.Delay = time.Hour
+	stop := w.Start()
+	<-fetched
+	stop()
+	if store.cp == nil || store.cp.Cursor != 1 {
+		t.Errorf("Expected the checkpoint kept on stop, got %+v", store.cp)
+	}
+}
+
+type leaderFunc func() bool
+
+func (f leaderFunc) IsLeader() bool { return f() }
+
+func TestWarmer_OnlyLeaderRuns(t *testing.T) {
+	store := &memoryCheckpoints{}
+	svc := &recordingService{}
+	w := newTestWarmer(svc, store, "London", "Paris", "Rome")
+	w.Leader = leaderFunc(func() bool { return false })
+	if err := w.RunCycle(context.Background()); !errors.Is(err, jobs.ErrSkipped) || len(svc.cities) != 0 {
+		t.Fatalf("Expected a follower to skip the cycle, got %v, %v", svc.cities, err)
+	}
+
+	// Leadership is lost after London; the checkpoint is left for the new leader
+	leading := true
+	svc.onFetch = func(string) { leading = false }
+	w.Leader = leaderFunc(func() bool { return leading })
+	if err := w.RunCycle(context.Background()); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if len(svc.cities) != 1 || store.cp == nil || store.cp.Cursor != 1 {
+		t.Errorf("Expected the cycle handed over after London, got %v, %+v", svc.cities, store.cp)
+	}
+}
+
+func TestListHash(t *testing.T) {
+	if listHash([]string{"London", "Paris"}) != listHash([]string{" london", "PARIS "}) {
+		t.Error("Expected the hash to ignore case and whitespace")
+	}
+	if listHash([]string{"London", "Paris"}) == listHash([]string{"Paris", "London"}) {
+		t.Error("Expected the hash to depend on order")
+	}
+}
@@ -0,0 +1,304 @@
+// Package webhook delivers cache-update events to webhook subscriptions, signing every delivery
+// and retrying with exponential backoff before giving up to a dead-letter list. Deliveries go
+// through a Redis stream read by workers on every replica, so they survive restarts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/client"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/jobs"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+// EventWeatherUpdated is the type of events sent when fresh weather is fetched.
+const EventWeatherUpdated = "weather.updated"
+
+var (
+	deliveries = metrics.NewCounterVec("weather_webhook_deliveries_total",
+		"Webhook delivery attempts by outcome.", "outcome")
+	queueLag = metrics.NewGauge("weather_webhook_queue_lag",
+		"Queued webhook deliveries no worker has read yet.")
+	queuePending = metrics.NewGauge("weather_webhook_queue_pending",
+		"Webhook deliveries read by a worker and not yet acknowledged.")
+	queueScheduled = metrics.NewGauge("weather_webhook_queue_scheduled",
+		"Failed webhook deliveries waiting for their backoff before a retry.")
+)
+
+// Dispatcher turns cache updates into queued webhook deliveries, and runs the workers that make
+// them.
+type Dispatcher struct {
+	Subscriptions repository.SubscriptionStore
+	DeadLetters   repository.DeadLetterStore
+	Queue         repository.DeliveryQueue
+	Client        *http.Client
+	Config        config.WebhookConfig
+	// Consumer names this replica in the delivery consumer group; each worker adds its number.
+	// Keeping it across restarts lets a restarted replica pick up where it stopped.
+	Consumer string
+	now      func() time.Time
+}
+
+// NewDispatcher creates a Dispatcher backed by the shared Redis client, named after the host. Its
+// HTTP client refuses to connect to non-public addresses unless webhooks.allow_private_targets is
+// set.
+func NewDispatcher() *Dispatcher {
+	cfg := config.GetWebhookConfig()
+	host, _ := os.Hostname()
+	return &Dispatcher{
+		Subscriptions: repository.NewSubscriptionStore(),
+		DeadLetters:   repository.NewDeadLetterStore(),
+		Queue:         repository.NewDeliveryQueue(),
+		Client:        newDeliveryClient(cfg.Timeout, cfg.AllowPrivateTargets),
+		Config:        cfg,
+		Consumer:      host,
+	}
+}
+
+// Start queues a delivery for every published cache update, and runs Config.Workers delivery
+// workers and the webhook_queue job, which queues due retries and refreshes the queue metrics,
+// until the returned function is called. Stopping waits for deliveries in progress; deliveries
+// read but not finished are taken over by another worker after Config.ClaimAfter.
+func (d *Dispatcher) Start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	unsubscribe := events.CacheUpdates.Subscribe(func(update events.CacheUpdate) {
+		d.Dispatch(ctx, update)
+	})
+	stopQueue := jobs.New("webhook_queue", d.Config.PollInterval, d.maintainQueue).Schedule(true)
+	var wg sync.WaitGroup
+	for i := range d.Config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx, d.Consumer+"-"+strconv.Itoa(i))
+		}()
+	}
+	return func() {
+		unsubscribe()
+		stopQueue()
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Dispatch queues a delivery to every active subscription for the update's location.
+func (d *Dispatcher) Dispatch(ctx context.Context, update events.CacheUpdate) {
+	subs, err := d.Subscriptions.ListByLocation(ctx, update.Location)
+	if err != nil {
+		logger().Warnw("Failed to list subscriptions", "location", update.Location, "error", err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+	event := model.WebhookEvent{
+		ID:         "evt_" + randomHex(12),
+		Type:       EventWeatherUpdated,
+		Location:   update.Location,
+		Weather:    update.Weather,
+		OccurredAt: update.At.UTC(),
+	}
+	event.Weather.Cached = false
+	for _, sub := range subs {
+		if sub.Status != model.SubscriptionActive {
+			continue
+		}
+		job := repository.DeliveryJob{SubscriptionID: sub.ID, Event: event, Attempt: 1}
+		if err := d.Queue.Enqueue(ctx, job); err != nil {
+			logger().Errorw("Failed to queue webhook delivery", "subscription", sub.ID, "event", event.ID, "error", err)
+		}
+	}
+}
+
+// work makes deliveries as consumer until ctx is done, taking over abandoned ones first.
+func (d *Dispatcher) work(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		delivery, err := d.Queue.Claim(ctx, consumer, d.Config.ClaimAfter)
+		if err == nil && delivery == nil {
+			delivery, err = d.Queue.Read(ctx, consumer, d.Config.PollInterval)
+		}
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				logger().Warnw("Failed to read webhook deliveries", "consumer", consumer, "error", err)
+				sleep(ctx, d.Config.PollInterval)
+			}
+		case delivery != nil:
+			d.Handle(ctx, delivery)
+		}
+	}
+}
+
+// Handle makes one delivery attempt for a queued job and acknowledges it once it is done with:
+// delivered, scheduled for a retry with exponential backoff, or dead-lettered after
+// Config.MaxAttempts. Jobs for deleted or paused subscriptions are dropped. A job is left
+// unacknowledged, to be taken over later, when it cannot be handled now.
+func (d *Dispatcher) Handle(ctx context.Context, delivery *repository.QueuedDelivery) {
+	job := delivery.Job
+	sub, err := d.Subscriptions.Get(ctx, job.SubscriptionID)
+	switch {
+	case errors.Is(err, repository.ErrSubscriptionNotFound) || err == nil && sub.Status != model.SubscriptionActive:
+		deliveries.WithLabelValues("dropped").Inc()
+		d.ack(ctx, delivery)
+		return
+	case err != nil:
+		logger().Warnw("Failed to read subscription for delivery", "subscription", job.SubscriptionID, "event", job.Event.ID, "error", err)
+		return
+	}
+	body, err := json.Marshal(job.Event)
+	if err != nil {
+		logger().Errorw("Failed to encode webhook event", "event", job.Event.ID, "error", err)
+		d.ack(ctx, delivery)
+		return
+	}
+
+	lastErr := d.attempt(ctx, sub, job.Event.ID, body)
+	switch {
+	case lastErr == nil:
+		deliveries.WithLabelValues("delivered").Inc()
+		logger().Debugw("Webhook delivered", "subscription", sub.ID, "event", job.Event.ID, "attempt", job.Attempt)
+		d.ack(ctx, delivery)
+		return
+	case ctx.Err() != nil:
+		// Shutting down: another worker takes the job over
+		return
+	}
+	logger().Infow("Webhook delivery failed", "subscription", sub.ID, "event", job.Event.ID, "attempt", job.Attempt, "error", lastErr)
+
+	if job.Attempt < d.Config.MaxAttempts {
+		retryAt := d.clock().Add(d.backoff(job.Attempt))
+		job.Attempt++
+		if err := d.Queue.Retry(ctx, job, retryAt); err != nil {
+			logger().Errorw("Failed to schedule webhook retry", "subscription", sub.ID, "event", job.Event.ID, "error", err)
+			return
+		}
+		deliveries.WithLabelValues("retried").Inc()
+		d.ack(ctx, delivery)
+		return
+	}
+
+	deliveries.WithLabelValues("dead_lettered").Inc()
+	letter := model.DeadLetter{
+		SubscriptionID: sub.ID,
+		URL:            sub.URL,
+		Event:          job.Event,
+		Attempts:       job.Attempt,
+		LastError:      lastErr.Error(),
+		FailedAt:       d.clock().UTC(),
+	}
+	if err := d.DeadLetters.Add(ctx, letter); err != nil {
+		logger().Errorw("Failed to record dead letter", "subscription", sub.ID, "event", job.Event.ID, "error", err)
+		return
+	}
+	logger().Warnw("Webhook dead-lettered", "subscription", sub.ID, "event", job.Event.ID, "error", lastErr)
+	d.ack(ctx, delivery)
+}
+
+// ack acknowledges a delivery even when shutting down, so that a finished delivery is not made
+// again by the worker taking over.
+func (d *Dispatcher) ack(ctx context.Context, delivery *repository.QueuedDelivery) {
+	if err := d.Queue.Ack(context.WithoutCancel(ctx), delivery.ID); err != nil {
+		logger().Warnw("Failed to acknowledge webhook delivery", "entry", delivery.ID, "event", delivery.Job.Event.ID, "error", err)
+	}
+}
+
+// backoff is the wait after the given failed attempt: Config.InitialBackoff, doubling per attempt
+// up to Config.MaxBackoff.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.Config.InitialBackoff
+	for i := 1; i < attempt && delay < d.Config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.Config.MaxBackoff)
+}
+
+// maintainQueue queues the retries that are due and refreshes the queue metrics.
+func (d *Dispatcher) maintainQueue(ctx context.Context) error {
+	if _, err := d.Queue.PromoteDue(ctx, d.clock()); err != nil {
+		return err
+	}
+	stats, err := d.Queue.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	queueLag.Set(float64(stats.Lag))
+	queuePending.Set(float64(stats.Pending))
+	queueScheduled.Set(float64(stats.Scheduled))
+	return nil
+}
+
+// attempt makes one signed delivery. Every attempt gets a fresh timestamp and nonce so receivers
+// can reject replays while still accepting retries.
+func (d *Dispatcher) attempt(ctx context.Context, sub *model.Subscription, eventID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := d.clock().Unix()
+	nonce := randomHex(16)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "weather-api-webhooks/1")
+	req.Header.Set(client.WebhookIDHeader, eventID)
+	req.Header.Set(client.WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(client.WebhookNonceHeader, nonce)
+	req.Header.Set(client.WebhookSignatureHeader, client.WebhookSignature([]byte(sub.Secret), ts, nonce, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sleep waits for delay, or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+func (d *Dispatcher) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewSecret returns a random subscription signing secret.
+func NewSecret() string {
+	return "whsec_" + randomHex(24)
+}
+
+// NewSubscriptionID returns a random subscription ID.
+func NewSubscriptionID() string {
+	return "sub_" + randomHex(12)
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisv9 "github.com/redis/go-redis/v9"
+
+	"github.com/fakhrymubarak/weather-api-redis/client"
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"github.com/fakhrymubarak/weather-api-redis/internal/events"
+	"github.com/fakhrymubarak/weather-api-redis/internal/model"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+)
+
+type memorySubscriptions struct {
+	subs []*model.Subscription
+}
+
+func (m *memorySubscriptions) Create(_ context.Context, sub *model.Subscription) error {
+	m.subs = append(m.subs, sub)
+	return nil
+}
+
+func (m *memorySubscriptions) Get(_ context.Context, id string) (*model.Subscription, error) {
+	for _, s := range m.subs {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, repository.ErrSubscriptionNotFound
+}
+
+func (m *memorySubscriptions) Update(context.Context, *model.Subscription) error { return nil }
+
+func (m *memorySubscriptions) Delete(context.Context, string, string) error { return nil }
+
+func (m *memorySubscriptions) List(context.Context, repository.SubscriptionFilter, string, int) ([]*model.Subscription, string, error) {
+	return m.subs, "", nil
+}
+
+func (m *memorySubscriptions) ListByLocation(_ context.Context, location string) ([]*model.Subscription, error) {
+	var out []*model.Subscription
+	for _, s := range m.subs {
+		if s.Location == location {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+type memoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []model.DeadLetter
+}
+
+func (m *memoryDeadLetters) Add(_ context.Context, letter model.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+func (m *memoryDeadLetters) List(_ context.Context, limit int) ([]model.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.letters, nil
+}
+
+// recordingQueue records the time of every retry it schedules.
+type recordingQueue struct {
+	repository.DeliveryQueue
+	retries []time.Time
+}
+
+func (q *recordingQueue) Retry(ctx context.Context, job repository.DeliveryJob, at time.Time) error {
+	q.retries = append(q.retries, at)
+	return q.DeliveryQueue.Retry(ctx, job, at)
+}
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestDispatcher(t *testing.T, subs ...*model.Subscription) (*Dispatcher, *memoryDeadLetters, *recordingQueue) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redisv9.NewClient(&redisv9.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	dead := &memoryDeadLetters{}
+	queue := &recordingQueue{DeliveryQueue: repository.NewDeliveryQueue(rdb)}
+	d := &Dispatcher{
+		Subscriptions: &memorySubscriptions{subs: subs},
+		DeadLetters:   dead,
+		Queue:         queue,
+		Client:        &http.Client{Timeout: time.Second},
+		Config: config.WebhookConfig{
+			MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second,
+			Workers: 2, PollInterval: 20 * time.Millisecond, ClaimAfter: time.Minute,
+		},
+		Consumer: "test",
+		now:      func() time.Time { return testNow },
+	}
+	return d, dead, queue
+}
+
+// deliverQueued handles queued deliveries, queueing retries as soon as they are scheduled, until
+// the queue is empty. It returns how many deliveries it handled.
+func deliverQueued(t *testing.T, d *Dispatcher) int {
+	t.Helper()
+	ctx := context.Background()
+	var handled int
+	for {
+		if _, err := d.Queue.PromoteDue(ctx, d.clock().Add(time.Hour)); err != nil {
+			t.Fatalf("PromoteDue: %v", err)
+		}
+		delivery, err := d.Queue.Read(ctx, "test-0", -1)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if delivery == nil {
+			return handled
+		}
+		d.Handle(ctx, delivery)
+		handled++
+	}
+}
+
+// waits returns the backoff of every retry q scheduled.
+func (q *recordingQueue) waits() []time.Duration {
+	var out []time.Duration
+	for _, at := range q.retries {
+		out = append(out, at.Sub(testNow))
+	}
+	return out
+}
+
+func TestHandle_RetriesWithBackoffAndSigns(t *testing.T) {
+	verifier := &client.WebhookVerifier{Secret: []byte("whsec_test")}
+	var calls atomic.Int32
+	var received model.WebhookEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifier.Verify(r.Header, body); err != nil {
+			t.Errorf("Delivery %d failed verification: %v", calls.Load()+1, err)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.Unmarshal(body, &received)
+	}))
+	defer srv.Close()
+
+	sub := &model.Subscription{ID: "sub_1", Location: "London", URL: srv.URL, Secret: "whsec_test", Status: model.SubscriptionActive}
+	d, dead, queue := newTestDispatcher(t, sub)
+	// The verifier checks timestamps against the wall clock
+	d.now = nil
+	d.Dispatch(context.Background(), events.CacheUpdate{Location: "London", Weather: model.WeatherResponse{Location: "London", Temperature: 15}, At: testNow})
+
+	if n := deliverQueued(t, d); n != 3 {
+		t.Fatalf("Expected the third attempt to succeed, handled %d deliveries", n)
+	}
+	if calls.Load() != 3 || received.Type != EventWeatherUpdated || received.Weather.Temperature != 15 {
+		t.Errorf("Unexpected delivery: %d calls, event %+v", calls.Load(), received)
+	}
+	if len(queue.retries) != 2 || !queue.retries[0].Before(queue.retries[1]) {
+		t.Errorf("Expected two increasing retry times, got %v", queue.retries)
+	}
+	if len(dead.letters) != 0 {
+		t.Errorf("Expected no dead letters, got %+v", dead.letters)
+	}
+	// miniredis does not track the group's lag, so only pending and scheduled entries are checked
+	stats, err := d.Queue.Stats(context.Background())
+	if err != nil || stats.Pending != 0 || stats.Scheduled != 0 {
+		t.Errorf("Expected an empty queue, got %+v, %v", stats, err)
+	}
+}
+
+func TestHandle_DeadLettersAfterMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sub := &model.Subscription{ID: "sub_1", Location: "London", URL: srv.URL, Status: model.SubscriptionActive}
+	d, dead, queue := newTestDispatcher(t, sub)
+	d.Dispatch(context.Background(), events.CacheUpdate{Location: "London", At: testNow})
+	if n := deliverQueued(t, d); n != 4 {
+		t.Fatalf("Expected 4 attempts, got %d", n)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}; !slices.Equal(queue.waits(), want) {
+		t.Errorf("Expected backoff %v capped at max, got %v", want, queue.waits())
+	}
+	if len(dead.letters) != 1 || dead.letters[0].Attempts != 4 || dead.letters[0].LastError != "endpoint responded 500" {
+		t.Errorf("Unexpected dead letters %+v", dead.letters)
+	}
+}
+
+func TestHandle_DropsDeletedAndPausedSubscriptions(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	paused := &model.Subscription{ID: "paused", Location: "London", URL: srv.URL, Status: model.SubscriptionActive}
+	d, _, _ := newTestDispatcher(t, paused)
+	ctx := context.Background()
+	d.Dispatch(ctx, events.CacheUpdate{Location: "London", At: testNow})
+	// Paused, and a subscription deleted, after the deliveries were queued
+	paused.Status = model.SubscriptionPaused
+	if err := d.Queue.Enqueue(ctx, repository.DeliveryJob{SubscriptionID: "deleted", Attempt: 1}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if n := deliverQueued(t, d); n != 2 || calls.Load() != 0 {
+		t.Errorf("Expected both deliveries dropped, handled %d with %d calls", n, calls.Load())
+	}
+	if stats, _ := d.Queue.Stats(ctx); stats.Pending != 0 {
+		t.Errorf("Expected dropped deliveries acknowledged, got %+v", stats)
+	}
+}
+
+func TestHandle_TakesOverAbandonedDeliveries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	d, _, _ := newTestDispatcher(t, &model.Subscription{ID: "sub_1", Location: "London", URL: srv.URL, Status: model.SubscriptionActive})
+	ctx := context.Background()
+	d.Dispatch(ctx, events.CacheUpdate{Location: "London", At: testNow})
+	// A replica reads the delivery and stops before making it
+	if delivery, err := d.Queue.Read(ctx, "gone-0", -1); err != nil || delivery == nil {
+		t.Fatalf("Read: %v, %v", delivery, err)
+	}
+	if delivery, err := d.Queue.Claim(ctx, "test-0", time.Minute); err != nil || delivery != nil {
+		t.Fatalf("Expected no takeover before claim_after, got %v, %v", delivery, err)
+	}
+
+	delivery, err := d.Queue.Claim(ctx, "test-0", 0)
+	if err != nil || delivery == nil {
+		t.Fatalf("Expected the abandoned delivery, got %v, %v", delivery, err)
+	}
+	d.Handle(ctx, delivery)
+	if stats, _ := d.Queue.Stats(ctx); calls.Load() != 1 || stats.Pending != 0 {
+		t.Errorf("Expected the delivery made and acknowledged, got %d calls and %+v", calls.Load(), stats)
+	}
+}
+
+func TestDispatch_OnlyActiveSubscriptions(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	done := make(chan struct{}, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		done <- struct{}{}
+	}))
+	defer srv.Close()
+
+	d, _, _ := newTestDispatcher(t,
+		&model.Subscription{ID: "a", Location: "London", URL: srv.URL + "/a", Status: model.SubscriptionActive},
+		&model.Subscription{ID: "b", Location: "London", URL: srv.URL + "/b", Status: model.SubscriptionPaused},
+		&model.Subscription{ID: "c", Location: "Paris", URL: srv.URL + "/c", Status: model.SubscriptionActive},
+	)
+	stop := d.Start()
+	defer stop()
+	events.CacheUpdates.Publish(events.CacheUpdate{Location: "London", Weather: model.WeatherResponse{Location: "London"}, At: time.Now()})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a delivery")
+	}
+	time.Sleep(50 * time.Millisecond) // give unexpected deliveries a chance to arrive
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/a"] != 1 || hits["/b"] != 0 || hits["/c"] != 0 {
+		t.Errorf("Expected only the active London subscription, got %v", hits)
+	}
+}
@@ -0,0 +1,11 @@
+package webhook
+
+import (
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+	"go.uber.org/zap"
+)
+
+// logger returns the webhook module logger, whose level is set by logging.levels.webhook.
+func logger() *zap.SugaredLogger {
+	return config.GetModuleLogger("webhook")
+}
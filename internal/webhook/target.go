@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateTarget is returned when a delivery would connect to a non-public address.
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// nonPublicPrefixes are ranges that netip.Addr has no predicate for: carrier-grade NAT, IETF
+// protocol assignments, benchmarking, and the IPv6 NAT64 and discard-only blocks.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("100::/64"),
+}
+
+// IsPublicAddr reports whether ip is a globally routable unicast address, as opposed to loopback,
+// private (RFC 1918, unique local), link-local (including the 169.254.169.254 metadata service),
+// unspecified, multicast or otherwise reserved.
+func IsPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnly is a net.Dialer Control hook that refuses connections to non-public addresses. It runs
+// after name resolution, for every address dialled, so a hostname that resolves (or is rebound) to
+// an internal address is refused as well, including on redirects.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddr(ap.Addr()) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// newDeliveryClient returns the HTTP client deliveries are made with. Unless allowPrivate is set,
+// it only connects to public addresses.
+func newDeliveryClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on our behalf, bypassing the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
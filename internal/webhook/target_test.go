@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDeliveryClient_RefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := newDeliveryClient(time.Second, false).Get(srv.URL)
+	if !errors.Is(err, ErrPrivateTarget) {
+		t.Errorf("Expected a loopback target refused, got %v", err)
+	}
+
+	resp, err := newDeliveryClient(time.Second, true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected private targets allowed when configured, got %v", err)
+	}
+	resp.Body.Close()
+}
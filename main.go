@@ -1,23 +1,193 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/fakhrymubarak/weather-api-redis/internal/config"
 	"github.com/fakhrymubarak/weather-api-redis/internal/handler"
+	"github.com/fakhrymubarak/weather-api-redis/internal/leader"
+	"github.com/fakhrymubarak/weather-api-redis/internal/memory"
+	"github.com/fakhrymubarak/weather-api-redis/internal/metrics"
 	"github.com/fakhrymubarak/weather-api-redis/internal/middleware"
+	"github.com/fakhrymubarak/weather-api-redis/internal/redis"
+	"github.com/fakhrymubarak/weather-api-redis/internal/repository"
+	"github.com/fakhrymubarak/weather-api-redis/internal/startup"
+	"github.com/fakhrymubarak/weather-api-redis/internal/warmer"
+	"github.com/fakhrymubarak/weather-api-redis/internal/webhook"
 )
 
 func main() {
-	middleware.StartRateLimiterCleanup()
+	embeddedRedis := flag.Bool("embedded-redis", false, "run an in-memory Redis in the process instead of connecting to one (redis.embedded)")
+	flag.Parse()
+	if *embeddedRedis {
+		config.EnableEmbeddedRedis()
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	if _, err := startup.Run(ctx, config.GetStartupConfig(), dependencyChecks()...); err != nil {
+		config.GetLogger().Fatalw("Startup checks failed", "error", err)
+	}
+	if cfg := config.GetStartupConfig(); cfg.Warmup {
+		startup.WarmUp(ctx, cfg, connectionWarmups(cfg)...)
+	}
+	// stops holds the stop functions of background work, called in reverse order on shutdown.
+	// Redis is closed last, once nothing uses it.
+	stops := []func(){redis.Close}
+	stops = append(stops, middleware.StartRateLimiterCleanup())
+	memory.OnPressure("rate_limiter", middleware.ReleaseIdleVisitors)
+	stops = append(stops, memory.Start())
+	stops = append(stops, redis.StartHealthCheck())
+	stops = append(stops, middleware.StartAdaptiveRateLimit(func() middleware.UpstreamSample {
+		return middleware.UpstreamSample(repository.GetUpstreamSample())
+	}))
+	readOnly := config.IsReadOnly()
+	if readOnly {
+		config.GetLogger().Warnw("Read-only mode: write endpoints and background writers are disabled")
+	}
+	// elector picks the replica that runs cluster-wide jobs. Its stop is called after theirs, and
+	// releases the lease so another replica takes over at once.
+	elector := leader.NewElector("jobs")
+	if !readOnly {
+		stops = append(stops, elector.Start())
+	}
+	stops = append(stops, repository.StartCostFlush(elector))
+	stops = append(stops, repository.StartOverrideSync())
+	if config.GetWarmerConfig().Enabled && !readOnly {
+		stops = append(stops, warmer.NewWarmer(elector).Start())
+	}
+	captureStore := repository.NewCaptureStore()
+	if !readOnly {
+		middleware.SetCaptureSink(captureStore.Add)
+	}
 	weatherHandler := handler.NewWeatherHandler()
 	mux := http.NewServeMux()
-	mux.Handle("/weather", middleware.RateLimitMiddleware(http.HandlerFunc(weatherHandler.HandleWeather)))
+	weatherRoute := middleware.DefaultChain().ThenFunc(weatherHandler.HandleWeather)
+	mux.Handle("/weather", weatherRoute)
+	mux.Handle("/v1/weather", weatherRoute)
+	mux.Handle("/weather/batch", middleware.DefaultChain().ThenFunc(handler.NewBatchHandler().HandleBatch))
+	mux.Handle("/weather/compare", middleware.DefaultChain().ThenFunc(handler.NewCompareHandler().HandleCompare))
+	mux.Handle("/weather/poll", middleware.DefaultChain().ThenFunc(handler.NewPollHandler().HandlePoll))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/readyz", handler.NewReadyHandler().HandleReadyz)
+	mux.Handle("/forecast", middleware.DefaultChain().ThenFunc(handler.NewForecastHandler().HandleForecast))
+	mux.Handle("/alerts", middleware.DefaultChain().ThenFunc(handler.NewAlertsHandler().HandleAlerts))
+	mux.Handle("/geocode", middleware.DefaultChain().ThenFunc(handler.NewGeocodeHandler().HandleGeocode))
+	mux.Handle("/widget/", middleware.DefaultChain().ThenFunc(handler.NewWidgetHandler().HandleWidget))
+	mux.Handle("/travel", middleware.DefaultChain().ThenFunc(handler.NewTravelHandler().HandleTravel))
+	mux.Handle("/status", middleware.DefaultChain().ThenFunc(handler.NewStatusHandler().HandleStatus))
+	mux.Handle("/admin/export", middleware.AdminChain().ThenFunc(handler.NewExportHandler().HandleExport))
+	mux.Handle("/admin/config", middleware.AdminChain().ThenFunc(handler.NewConfigHandler().HandleConfig))
+	overrideHandler := handler.NewOverrideHandler()
+	mux.Handle("/admin/override", middleware.AdminChain().ThenFunc(overrideHandler.HandleOverride))
+	mux.Handle("/admin/overrides", middleware.AdminChain().ThenFunc(overrideHandler.HandleOverrides))
+	mux.Handle("/admin/overrides/", middleware.AdminChain().ThenFunc(overrideHandler.HandleOverrides))
+	mux.Handle("/admin/cache/inspect", middleware.AdminChain().ThenFunc(handler.NewCacheInspectHandler().HandleInspect))
+	mux.Handle("/admin/captures", middleware.AdminChain().ThenFunc(handler.NewCapturesHandler(captureStore).HandleCaptures))
+	mux.Handle("/admin/costs", middleware.AdminChain().ThenFunc(handler.NewCostsHandler().HandleCosts))
+	mux.Handle("/admin/warmer/changes", middleware.AdminChain().ThenFunc(handler.NewWarmerChangesHandler().HandleChanges))
+	jobsHandler := handler.NewJobsHandler()
+	mux.Handle("/admin/jobs", middleware.AdminChain().ThenFunc(jobsHandler.HandleJobs))
+	mux.Handle("/admin/jobs/", middleware.AdminChain().ThenFunc(jobsHandler.HandleJob))
+
+	webhookHandler := handler.NewWebhookHandler()
+	mux.Handle("/subscriptions", middleware.KeyChain().ThenFunc(webhookHandler.HandleSubscriptions))
+	mux.Handle("/subscriptions/", middleware.KeyChain().ThenFunc(webhookHandler.HandleSubscription))
+	mux.Handle("/subscriptions/bulk", middleware.KeyChain().ThenFunc(webhookHandler.HandleBulkSubscriptions))
+	mux.Handle("/admin/webhooks/deadletters", middleware.AdminChain().ThenFunc(webhookHandler.HandleDeadLetters))
+	mux.Handle("/admin/webhooks/queue", middleware.AdminChain().ThenFunc(webhookHandler.HandleDeliveryQueue))
+	if config.GetWebhookConfig().Enabled && !readOnly {
+		stops = append(stops, webhook.NewDispatcher().Start())
+	}
+
+	feedStore := repository.NewFeedStore()
+	historyStore := repository.NewHistoryStore()
+	if !readOnly {
+		repository.RecordCacheUpdates(feedStore)
+		repository.RecordHistory(historyStore)
+	}
+	mux.Handle("/feed/", middleware.DefaultChain().ThenFunc(handler.NewFeedHandler(feedStore).HandleFeed))
+	mux.Handle("/weather/changes", middleware.DefaultChain().ThenFunc(handler.NewChangesHandler(historyStore).HandleChanges))
 
 	port := config.GetServerPort()
 	if port == "" {
 		port = "8080"
 	}
-	config.GetLogger().Infow("Weather API server running", "port", port)
-	config.GetLogger().Fatalw("Server exited", "error", http.ListenAndServe(":"+port, mux))
+	srv := newServer(":"+port, mux, config.GetServerTimeouts())
+	go func() {
+		config.GetLogger().Infow("Weather API server running", "port", port)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			config.GetLogger().Fatalw("Server exited", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdown(srv, stops)
+}
+
+// newServer returns the server for handler on addr, bounded by server.timeouts: slow clients
+// cannot hold a connection open by trickling in headers or a body, and idle keep-alive
+// connections are closed. Handlers that stream or hold responses extend their write deadline.
+func newServer(addr string, handler http.Handler, timeouts config.ServerTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
+
+// shutdown stops accepting requests, waits up to server.timeouts.shutdown for in-flight ones, then
+// stops background work, newest first. The warmer finishes its current city and keeps its
+// checkpoint, and the leader lease is released so another replica takes over at once.
+func shutdown(srv *http.Server, stops []func()) {
+	config.GetLogger().Infow("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		config.GetLogger().Warnw("In-flight requests did not finish in time", "error", err)
+	}
+	for i := len(stops) - 1; i >= 0; i-- {
+		stops[i]()
+	}
+	config.GetLogger().Infow("Server stopped")
+}
+
+// connectionWarmups open the connections the first requests would otherwise wait for. They run
+// before the server listens, so /readyz only answers once they are done.
+func connectionWarmups(cfg config.StartupConfig) []startup.Warmup {
+	return []startup.Warmup{
+		{Name: "redis", Run: func(ctx context.Context) error { return redis.WarmUp(ctx, cfg.WarmupConnections) }},
+		{Name: "providers", Run: repository.WarmUpProviders},
+	}
+}
+
+// dependencyChecks are the dependencies reported at startup. Redis is waited for when
+// startup.wait_for_redis is set; the provider is only checked for an API key, without calling it.
+func dependencyChecks() []startup.Check {
+	return []startup.Check{
+		{
+			Name:  "redis",
+			Probe: func(ctx context.Context) error { return redis.GetClient().Ping(ctx).Err() },
+			Wait:  config.GetStartupConfig().WaitForRedis,
+		},
+		{
+			Name: "openweathermap",
+			Probe: func(context.Context) error {
+				if config.GetOpenWeatherMapAPIKey() == "" {
+					return errors.New("OPENWEATHERMAP_API_KEY is not set")
+				}
+				return nil
+			},
+		},
+	}
 }
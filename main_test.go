@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fakhrymubarak/weather-api-redis/internal/config"
+)
+
+func TestNewServer_Timeouts(t *testing.T) {
+	timeouts := config.ServerTimeouts{
+		ReadHeader: 2 * time.Second,
+		Read:       3 * time.Second,
+		Write:      4 * time.Second,
+		Idle:       5 * time.Second,
+		Shutdown:   6 * time.Second,
+	}
+	srv := newServer(":8080", http.NotFoundHandler(), timeouts)
+	if srv.Addr != ":8080" || srv.Handler == nil {
+		t.Errorf("Expected the address and handler set, got %q %v", srv.Addr, srv.Handler)
+	}
+	if srv.ReadHeaderTimeout != 2*time.Second || srv.ReadTimeout != 3*time.Second || srv.WriteTimeout != 4*time.Second || srv.IdleTimeout != 5*time.Second {
+		t.Errorf("Expected the server timeouts set, got read_header %v, read %v, write %v, idle %v",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	// The defaults apply when server.timeouts is not configured
+	srv = newServer(":8080", http.NotFoundHandler(), config.GetServerTimeouts())
+	if srv.ReadHeaderTimeout <= 0 || srv.ReadTimeout <= 0 || srv.WriteTimeout <= 0 || srv.IdleTimeout <= 0 {
+		t.Errorf("Expected every timeout set from the config, got %+v", srv)
+	}
+}